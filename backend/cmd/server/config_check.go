@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gosveltekit/internal/config"
+)
+
+const configUsage = "uso: server config validate [arquivo]"
+
+// configCommand checks a configuration, with the environment overrides,
+// without starting the server or touching the database
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "validate" || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 2
+	}
+	path := ""
+	if len(args) == 2 {
+		path = args[1]
+	}
+
+	cfg, err := config.LoadConfigFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// Checked when the server builds the hasher
+	if _, err := newPasswordHasher(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("configuração válida (ambiente %s)\n", cfg.Environment)
+	return 0
+}
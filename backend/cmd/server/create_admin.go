@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/seed"
+)
+
+// createAdmin creates an admin account, for the first access to a new
+// deployment
+func createAdmin(args []string) int {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	username := flags.String("username", "", "nome de usuário (obrigatório)")
+	email := flags.String("email", "", "email (obrigatório)")
+	password := flags.String("password", "", "senha, dentro da política de senhas (obrigatória)")
+	displayName := flags.String("display-name", "", "nome de exibição (vazio usa o username)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *username == "" || *email == "" || *password == "" || flags.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "uso: server create-admin --username <nome> --email <email> --password <senha> [--display-name <nome>]")
+		return 2
+	}
+
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
+	}
+	db, ok := openDatabase(cfg)
+	if !ok {
+		return 1
+	}
+	defer closeDatabase(db)
+	if err := prepareDatabase(cfg, db); err != nil {
+		logger.Error("Falha ao preparar o banco de dados", "error", err)
+		return 1
+	}
+	hasher, err := newPasswordHasher(cfg)
+	if err != nil {
+		logger.Error("Falha ao criar administrador", "error", err)
+		return 1
+	}
+
+	admin := config.InitialUserConfig{Username: *username, Email: *email, DisplayName: *displayName, Role: "admin", Password: *password}
+	if err := seed.CreateUser(db, admin, hasher); err != nil {
+		logger.Error("Falha ao criar administrador", "error", err)
+		return 1
+	}
+	return 0
+}
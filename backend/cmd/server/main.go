@@ -0,0 +1,158 @@
+// Package main is the entry point for the GoSvelteKit backend server.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/database"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/migrations"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/pagination"
+	"gosveltekit/internal/seed"
+	"gosveltekit/internal/tenant"
+
+	"gorm.io/gorm"
+)
+
+const usage = `uso: server [comando] [opções]
+
+Comandos:
+  serve          inicia o servidor HTTP (padrão sem comando)
+  migrate        gerencia o schema: up | down [passos] | status
+  create-admin   cria um administrador: --username --email --password [--display-name]
+  routes         lista as rotas registradas
+  config         valida a configuração sem iniciar o servidor: validate [arquivo]
+`
+
+func main() {
+	command, args := "serve", []string(nil)
+	if len(os.Args) > 1 {
+		command, args = os.Args[1], os.Args[2:]
+	}
+
+	var code int
+	switch command {
+	case "serve":
+		code = serve(args)
+	case "migrate":
+		code = migrate(args)
+	case "create-admin":
+		code = createAdmin(args)
+	case "routes":
+		code = routes(args)
+	case "config":
+		code = configCommand(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		code = 2
+	}
+	_ = logger.Sync()
+	os.Exit(code)
+}
+
+// loadConfig loads the config and initializes the logger with it
+func loadConfig() (*config.Config, bool) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		// Initialize logger with defaults before config is loaded
+		logger.Init("info", "text")
+		logger.Error("Falha ao carregar as configurações", "error", err)
+		return nil, false
+	}
+
+	// Initialize logger with config
+	logLevel := cfg.Log.Level
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	logFormat := cfg.Log.Format
+	if logFormat == "" {
+		logFormat = "text"
+	}
+	logger.InitWithService(logLevel, logFormat, cfg.Log.Service)
+
+	pagination.SetDefaults(cfg.Pagination.DefaultPageSize, cfg.Pagination.MaxPageSize)
+	return cfg, true
+}
+
+// openDatabase connects to the database (and the read replica, if configured)
+func openDatabase(cfg *config.Config) (*gorm.DB, bool) {
+	dbDSN := cfg.Redacted().Database.DSN
+	db, err := database.Open(cfg.Database, &gorm.Config{})
+	if err != nil {
+		logger.Error("Falha ao conectar ao banco de dados", "error", err, "driver", cfg.Database.Driver, "dsn", dbDSN)
+		return nil, false
+	}
+	logger.Info("Conectado ao banco de dados", "dsn", dbDSN, "read_replica", cfg.Database.ReadReplicaDSN != "")
+	return db, true
+}
+
+// closeDatabase closes the connection pool; nothing may use db afterwards
+func closeDatabase(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// prepareDatabase brings the schema and the reference data up to date before
+// the app uses the database
+func prepareDatabase(cfg *config.Config, db *gorm.DB) error {
+	if err := models.SetIDStrategy(cfg.Database.IDStrategy); err != nil {
+		return fmt.Errorf("configuração de banco de dados inválida: %w", err)
+	}
+	tenant.SetEnabled(cfg.Tenancy.Enabled)
+
+	migrator, err := migrations.New(db, cfg.Database.Driver)
+	if err != nil {
+		return fmt.Errorf("falha ao carregar migrações: %w", err)
+	}
+	if cfg.Database.MigrateOnStart {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			return fmt.Errorf("falha ao executar migrações: %w", err)
+		}
+	} else {
+		pending, err := migrator.Pending(context.Background())
+		if err != nil {
+			return fmt.Errorf("falha ao consultar migrações: %w", err)
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d migrações pendentes: execute \"server migrate up\"", len(pending))
+		}
+	}
+	if models.UsesUUIDs() {
+		backfilled, err := models.BackfillUserUUIDs(db)
+		if err != nil {
+			return fmt.Errorf("falha ao atribuir UUIDs a usuários existentes: %w", err)
+		}
+		if backfilled > 0 {
+			logger.Info("UUIDs atribuídos a usuários existentes", "count", backfilled)
+		}
+	}
+	logger.Info("Migrações executadas com sucesso")
+
+	if err := seed.EnsureRoles(db, cfg.Roles); err != nil {
+		return fmt.Errorf("falha ao sincronizar papéis e permissões: %w", err)
+	}
+	return nil
+}
+
+func newPasswordHasher(cfg *config.Config) (auth.PasswordHasher, error) {
+	hasher, err := auth.NewPasswordHasher(cfg.Auth.PasswordHashAlgorithm, cfg.Auth.BcryptCost, cfg.Auth.BcryptLongPasswords, auth.Argon2Params{
+		Memory:      cfg.Auth.Argon2Memory,
+		Iterations:  cfg.Auth.Argon2Iterations,
+		Parallelism: cfg.Auth.Argon2Parallelism,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("configuração de hash de senha inválida: %w", err)
+	}
+	return hasher, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/migrations"
+)
+
+const migrateUsage = "uso: server migrate up | down [passos] | status"
+
+// migrate manages the schema without starting the server
+func migrate(args []string) int {
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
+	}
+	db, ok := openDatabase(cfg)
+	if !ok {
+		return 1
+	}
+	defer closeDatabase(db)
+
+	m, err := migrations.New(db, cfg.Database.Driver)
+	if err != nil {
+		logger.Error("Falha ao carregar migrações", "error", err)
+		return 1
+	}
+	return runMigrate(m, args)
+}
+
+// runMigrate runs a migrate subcommand and returns the exit code
+func runMigrate(m *migrations.Migrator, args []string) int {
+	ctx := context.Background()
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			logger.Error("Falha ao executar migrações", "error", err, "applied", applied)
+			return 1
+		}
+		logger.Info("Migrações concluídas", "applied", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+			steps = n
+		}
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			logger.Error("Falha ao reverter migrações", "error", err, "reverted", reverted)
+			return 1
+		}
+		logger.Info("Migrações revertidas", "reverted", reverted)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			logger.Error("Falha ao consultar migrações", "error", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSÃO\tNOME\tAPLICADA EM")
+		for _, status := range statuses {
+			appliedAt := "pendente"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routes prints the registered endpoints. The app is wired against an
+// in-memory sqlite database, so listing them never touches the real one.
+func routes(args []string) int {
+	if err := flag.NewFlagSet("routes", flag.ContinueOnError).Parse(args); err != nil {
+		return 2
+	}
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
+	}
+	// Only failures go to the log, stdout is for the listing
+	logger.InitWithService("error", cfg.Log.Format, cfg.Log.Service)
+	gin.SetMode(gin.ReleaseMode)
+	// A single connection: each one to :memory: is a different database
+	cfg.Database = config.DatabaseConfig{Driver: config.DriverSQLite, DSN: ":memory:", MaxOpenConns: 1, MigrateOnStart: true}
+	cfg.InitialUsers = nil
+
+	db, ok := openDatabase(cfg)
+	if !ok {
+		return 1
+	}
+	defer closeDatabase(db)
+	if err := prepareDatabase(cfg, db); err != nil {
+		logger.Error("Falha ao preparar o banco de dados", "error", err)
+		return 1
+	}
+	hasher, err := newPasswordHasher(cfg)
+	if err != nil {
+		logger.Error("Falha ao listar rotas", "error", err)
+		return 1
+	}
+	a, err := newApp(cfg, db, hasher, time.Now())
+	if err != nil {
+		logger.Error("Falha ao listar rotas", "error", err)
+		return 1
+	}
+
+	registered := a.router.Routes()
+	sort.Slice(registered, func(i, j int) bool {
+		if registered[i].Path != registered[j].Path {
+			return registered[i].Path < registered[j].Path
+		}
+		return registered[i].Method < registered[j].Method
+	})
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MÉTODO\tCAMINHO\tHANDLER")
+	for _, route := range registered {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", route.Method, route.Path, route.Handler)
+	}
+	w.Flush()
+	return 0
+}
@@ -1,108 +1,626 @@
-// Package main is the entry point for the GoSvelteKit backend server.
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"time"
 
+	"gosveltekit/internal/audit"
 	"gosveltekit/internal/auth"
 	gormadapter "gosveltekit/internal/auth/adapter/gorm"
+	redisadapter "gosveltekit/internal/auth/adapter/redis"
+	"gosveltekit/internal/auth/oauth"
+	"gosveltekit/internal/captcha"
+	"gosveltekit/internal/cleanup"
 	"gosveltekit/internal/config"
 	"gosveltekit/internal/email"
 	"gosveltekit/internal/handlers"
+	"gosveltekit/internal/healthcheck"
+	"gosveltekit/internal/https"
+	"gosveltekit/internal/jobs"
 	"gosveltekit/internal/logger"
-	"gosveltekit/internal/models"
+	"gosveltekit/internal/metrics"
+	"gosveltekit/internal/middleware"
+	"gosveltekit/internal/outbox"
+	"gosveltekit/internal/realtime"
+	"gosveltekit/internal/repository"
 	"gosveltekit/internal/router"
+	"gosveltekit/internal/seed"
 	"gosveltekit/internal/service"
+	"gosveltekit/internal/settings"
+	"gosveltekit/internal/sms"
+	"gosveltekit/internal/storage"
+	"gosveltekit/internal/telemetry"
+	"gosveltekit/internal/webhooks"
+	"gosveltekit/internal/worker"
 
-	"golang.org/x/crypto/bcrypt"
-	"gorm.io/driver/sqlite"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
-func main() {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		// Initialize logger with defaults before config is loaded
-		logger.Init("info", "text")
-		logger.Error("Falha ao carregar as configurações", "error", err)
-		os.Exit(1)
-	}
+// app is the wired HTTP handler with the background workers it relies on
+type app struct {
+	router   *gin.Engine
+	workers  *worker.Manager
+	inFlight *middleware.InFlight
+	redis    *redis.Client // nil unless a feature stores in Redis
+	realtime *realtime.Hub // nil unless realtime.enabled
+}
 
-	// Initialize logger with config
-	logLevel := cfg.Log.Level
-	if logLevel == "" {
-		logLevel = "info"
+// serve runs the HTTP server until SIGINT or SIGTERM
+func serve(args []string) int {
+	if err := flag.NewFlagSet("serve", flag.ContinueOnError).Parse(args); err != nil {
+		return 2
 	}
-	logFormat := cfg.Log.Format
-	if logFormat == "" {
-		logFormat = "text"
+	startedAt := time.Now()
+	cfg, ok := loadConfig()
+	if !ok {
+		return 1
 	}
-	logger.Init(logLevel, logFormat)
-
 	logger.Info("Iniciando servidor", "port", cfg.Server.Port)
 
-	dbDSN := cfg.Database.DSN
+	// Values actually in effect after merging file and environment, without secrets
+	logger.Info("Configuração efetiva", "config", cfg.Redacted())
 
-	// Connect to SQLite
-	db, err := gorm.Open(sqlite.Open(dbDSN), &gorm.Config{})
+	db, ok := openDatabase(cfg)
+	if !ok {
+		return 1
+	}
+	if cfg.Metrics.Enabled {
+		if err := db.Use(metrics.GORMPlugin{}); err != nil {
+			logger.Error("Falha ao registrar métricas do banco de dados", "error", err)
+			return 1
+		}
+	}
+	shutdownTelemetry, err := telemetry.Setup(cfg.Telemetry)
 	if err != nil {
-		logger.Error("Falha ao conectar ao banco de dados", "error", err, "dsn", dbDSN)
-		os.Exit(1)
+		logger.Error("Falha ao iniciar telemetria", "error", err)
+		return 1
+	}
+	if cfg.Telemetry.Enabled {
+		if err := db.Use(tracing.NewPlugin(tracing.WithoutMetrics(), tracing.WithoutQueryVariables())); err != nil {
+			logger.Error("Falha ao registrar tracing do banco de dados", "error", err)
+			return 1
+		}
+		logger.Info("Exportando traces", "endpoint", cfg.Telemetry.Endpoint, "sample_ratio", cfg.Telemetry.SampleRatio)
+	}
+	if err := prepareDatabase(cfg, db); err != nil {
+		logger.Error("Falha ao preparar o banco de dados", "error", err)
+		return 1
+	}
+	passwordHasher, err := newPasswordHasher(cfg)
+	if err != nil {
+		logger.Error("Falha ao iniciar servidor", "error", err)
+		return 1
 	}
-	logger.Info("Conectado ao banco de dados", "dsn", dbDSN)
 
-	// Migrate tables (including new Session table)
-	if err := db.AutoMigrate(&models.User{}, &models.Session{}); err != nil {
-		logger.Error("Falha ao executar migrações", "error", err)
-		os.Exit(1)
+	if err := seed.EnsureUsers(db, cfg.InitialUsers, passwordHasher); err != nil {
+		logger.Error("Falha ao criar usuários iniciais", "error", err)
+		return 1
+	}
+	if cfg.DisableDefaultAdmin {
+		if _, err := seed.DisableDefaultAdmin(db); err != nil {
+			logger.Error("Falha ao desativar o administrador padrão", "error", err)
+			return 1
+		}
 	}
-	logger.Info("Migrações executadas com sucesso")
 
-	// Create admin user if not exists
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte("admin"), bcrypt.DefaultCost)
+	a, err := newApp(cfg, db, passwordHasher, startedAt)
 	if err != nil {
-		logger.Error("Falha ao gerar hash da senha do admin", "error", err)
+		logger.Error("Falha ao iniciar servidor", "error", err)
+		return 1
 	}
 
-	result := db.Where(models.User{Username: "admin"}).FirstOrCreate(&models.User{
-		Username:     "admin",
-		Email:        "onyx.views5004@eagereverest.com",
-		DisplayName:  "Administrator",
-		PasswordHash: string(passwordHash),
-		Role:         "admin",
-	})
-	if result.Error != nil {
-		logger.Error("Falha ao criar usuário admin", "error", result.Error)
+	// Start server
+	port := ":8080"
+	if cfg.Server.Port != 0 {
+		port = fmt.Sprintf(":%d", cfg.Server.Port)
+	}
+	maxHeaderBytes := config.DefaultMaxHeaderBytes
+	if cfg.Server.MaxHeaderBytes > 0 {
+		maxHeaderBytes = cfg.Server.MaxHeaderBytes
+	}
+	// Request contexts derive from baseCtx, which is only cancelled when the
+	// drain deadline passes, so handlers still running then stop their queries
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv := &http.Server{
+		Addr:           port,
+		Handler:        a.router,
+		MaxHeaderBytes: maxHeaderBytes,
+		BaseContext:    func(net.Listener) context.Context { return baseCtx },
+	}
+	// Shutdown doesn't wait for WebSocket connections: close them so their
+	// handlers return and the drain below can finish
+	if a.realtime != nil {
+		srv.RegisterOnShutdown(a.realtime.Close)
+	}
+	tlsSetup, err := https.New(cfg.Server.TLS, cfg.Server.Port)
+	if err != nil {
+		logger.Error("Falha ao iniciar servidor", "error", err)
+		return 1
+	}
+	// Plain HTTP listener for ACME challenges and redirects to HTTPS
+	var redirectSrv *http.Server
+	if tlsSetup != nil {
+		srv.TLSConfig = tlsSetup.TLSConfig
+		if tlsSetup.HTTPHandler != nil {
+			redirectSrv = &http.Server{
+				Addr:              fmt.Sprintf(":%d", cfg.Server.TLS.HTTPPort),
+				Handler:           tlsSetup.HTTPHandler,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	a.workers.Start(ctx)
+
+	serverErr := make(chan error, 2)
+	go func() {
+		if tlsSetup == nil {
+			logger.Info("Servidor iniciado", "port", port)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+			return
+		}
+		logger.Info("Servidor iniciado com HTTPS", "port", port, "autocert", cfg.Server.TLS.Autocert())
+		// The certificates are in TLSConfig
+		if err := srv.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+	if redirectSrv != nil {
+		go func() {
+			logger.Info("Redirecionando HTTP para HTTPS", "port", redirectSrv.Addr)
+			if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}()
+	}
+
+	var failed bool
+	select {
+	case err := <-serverErr:
+		logger.Error("Erro ao iniciar servidor", "error", err, "port", port)
+		failed = true
+	case <-ctx.Done():
+		logger.Info("Sinal de desligamento recebido, encerrando servidor")
+	}
+
+	// Stop accepting requests, then stop the workers, within one deadline
+	shutdownTimeout := cfg.Server.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = 10 * time.Second
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Shutdown closes the listener and waits for active connections; drain
+	// meanwhile to report how many requests are left
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- srv.Shutdown(shutdownCtx)
+	}()
+	if redirectSrv != nil {
+		_ = redirectSrv.Shutdown(shutdownCtx)
+	}
+	_ = a.inFlight.Drain(shutdownCtx)
+	if err := <-shutdownErr; err != nil {
+		logger.Error("Erro ao encerrar servidor", "error", err)
+		// Past the deadline: cancel what is left and drop the connections
+		cancelRequests()
+		_ = srv.Close()
+		failed = true
+	}
+	if err := a.workers.Stop(shutdownCtx); err != nil {
+		failed = true
 	}
-	logger.Info("Usuário admin verificado", "rows_affected", result.RowsAffected)
 
+	if a.redis != nil {
+		_ = a.redis.Close()
+	}
+
+	// Export the spans of the last requests and jobs
+	if err := shutdownTelemetry(shutdownCtx); err != nil {
+		logger.Warn("Spans pendentes não exportados", "error", err)
+	}
+
+	// Nothing uses the database anymore
+	if err := closeDatabase(db); err != nil {
+		logger.Error("Erro ao fechar conexões com o banco de dados", "error", err)
+		failed = true
+	}
+	logger.Info("Servidor encerrado")
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// newApp wires the adapters, services, handlers and workers into the router.
+// Nothing runs until the workers are started.
+func newApp(cfg *config.Config, db *gorm.DB, passwordHasher auth.PasswordHasher, startedAt time.Time) (*app, error) {
 	// Initialize adapters
-	userAdapter := gormadapter.NewUserAdapter(db)
-	sessionAdapter := gormadapter.NewSessionAdapter(db)
+	userAdapter := gormadapter.NewUserAdapter(db, gormadapter.WithPasswordHasher(passwordHasher))
+	var redisClient *redis.Client
+	if cfg.RateLimit.Store == config.RateLimitStoreRedis || cfg.Auth.SessionStore == config.SessionStoreRedis || cfg.Jobs.Store == config.JobsStoreRedis ||
+		cfg.HTTPCache.Store == config.HTTPCacheStoreRedis {
+		redisClient = newRedisClient(cfg.Redis)
+	}
+	if err := auth.ValidateTokenBytes(cfg.Auth.TokenBytes); err != nil {
+		return nil, fmt.Errorf("configuração de autenticação inválida: %w", err)
+	}
+	var sessionAdapter auth.SessionAdapter
+	if cfg.Auth.SessionStore == config.SessionStoreRedis {
+		sessionAdapter = redisadapter.NewSessionAdapter(redisClient, redisadapter.WithTokenBytes(cfg.Auth.TokenBytes))
+	} else {
+		gormSessions := gormadapter.NewSessionAdapter(db, gormadapter.WithTokenBytes(cfg.Auth.TokenBytes))
+		if cfg.Metrics.Enabled {
+			metrics.SetActiveSessionsFunc(gormSessions.CountActiveSessions)
+		}
+		sessionAdapter = gormSessions
+	}
 
-	// Initialize auth manager with default config
+	// Initialize auth manager with default config, overridden by app config
 	authConfig := auth.DefaultAuthConfig()
+	authConfig.FailedLoginBackoff = cfg.Auth.FailedLoginBackoff
+	authConfig.MaxFailedLoginBackoff = cfg.Auth.FailedLoginBackoffMax
+	authConfig.LoginAttemptsInMemory = cfg.Auth.LoginAttemptsInMemory
+	authConfig.TokenBytes = cfg.Auth.TokenBytes
+	// Defaulted by the config loader, so 0 turns the leeway off
+	authConfig.ClockSkewLeeway = cfg.Auth.ClockSkewLeeway
+	if cfg.Auth.MaxFailedLogins > 0 {
+		authConfig.MaxFailedAttempts = cfg.Auth.MaxFailedLogins
+	}
+	authConfig.MaxFailedAttemptsPerIP = cfg.Auth.MaxFailedLoginsPerIP
+	if cfg.Auth.LoginLockoutDuration > 0 {
+		authConfig.LockoutDuration = cfg.Auth.LoginLockoutDuration
+	}
+	authConfig.SessionIdleTimeout = cfg.Auth.SessionIdleTimeout
+	if cfg.Auth.SessionActivityInterval > 0 {
+		authConfig.SessionActivityInterval = cfg.Auth.SessionActivityInterval
+	}
+	authConfig.MaxSessionsPerUser = cfg.Auth.MaxSessionsPerUser
+	authConfig.OnSessionLimit = cfg.Auth.OnSessionLimit
+	authConfig.RefreshTokenDuration = cfg.Auth.RefreshTokenDuration
+	authConfig.PasswordHistoryDepth = cfg.Auth.PasswordHistoryDepth
+	authConfig.RequireVerifiedEmail = cfg.Auth.UnverifiedLogin == config.UnverifiedLoginDeny
+	if cfg.Auth.ImpersonationDuration > 0 {
+		authConfig.ImpersonationDuration = cfg.Auth.ImpersonationDuration
+	}
+	if cfg.Auth.SMSCodeTTL > 0 {
+		authConfig.SMSCodeTTL = cfg.Auth.SMSCodeTTL
+	}
+	if cfg.Auth.SMSCodeMaxAttempts > 0 {
+		authConfig.SMSCodeMaxAttempts = cfg.Auth.SMSCodeMaxAttempts
+	}
+	if cfg.Auth.SMSCodeInterval > 0 {
+		authConfig.SMSCodeInterval = cfg.Auth.SMSCodeInterval
+	}
+	if cfg.Auth.SMSCodesPerHour > 0 {
+		authConfig.SMSCodesPerHour = cfg.Auth.SMSCodesPerHour
+	}
+	if cfg.Auth.MagicLinkTTL > 0 {
+		authConfig.MagicLinkTTL = cfg.Auth.MagicLinkTTL
+	}
+	if cfg.Auth.MagicLinkInterval > 0 {
+		authConfig.MagicLinkInterval = cfg.Auth.MagicLinkInterval
+	}
+	if cfg.Auth.MagicLinksPerHour > 0 {
+		authConfig.MagicLinksPerHour = cfg.Auth.MagicLinksPerHour
+	}
+	if cfg.Auth.TwoFactorChallengeTTL > 0 {
+		authConfig.TwoFactorChallengeTTL = cfg.Auth.TwoFactorChallengeTTL
+	}
+	if cfg.Auth.TwoFactorMaxAttempts > 0 {
+		authConfig.TwoFactorMaxAttempts = cfg.Auth.TwoFactorMaxAttempts
+	}
+	if cfg.Auth.TOTPIssuer != "" {
+		authConfig.TOTPIssuer = cfg.Auth.TOTPIssuer
+	}
+	if cfg.Auth.UsernameMinLength > 0 {
+		authConfig.UsernamePolicy.MinLength = cfg.Auth.UsernameMinLength
+	}
+	if cfg.Auth.UsernameMaxLength > 0 {
+		authConfig.UsernamePolicy.MaxLength = cfg.Auth.UsernameMaxLength
+	}
+	if cfg.Auth.UsernamePattern != "" {
+		// Already validated by config.LoadConfig
+		authConfig.UsernamePolicy.Pattern = regexp.MustCompile(cfg.Auth.UsernamePattern)
+	}
+	if cfg.Auth.ReservedUsernames != nil {
+		authConfig.UsernamePolicy.Reserved = cfg.Auth.ReservedUsernames
+	}
+	// Permissions of the roles seeded from config; without roles the admin
+	// role alone gets every permission
+	policy, err := gormadapter.LoadPolicy(context.Background(), db)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao carregar papéis e permissões: %w", err)
+	}
+	var permissions *auth.Policy
+	if len(policy.Roles()) > 0 {
+		permissions = policy
+	}
+	authConfig.Permissions = permissions
 	authManager := auth.NewAuthManager(userAdapter, sessionAdapter, authConfig)
 
+	// Background workers, started with the server and stopped on shutdown
+	workers := worker.NewManager()
+
+	// Job queue for emails and scheduled maintenance, shared by every instance
+	var jobStore jobs.Store = jobs.NewDBStore(db)
+	if cfg.Jobs.Store == config.JobsStoreRedis {
+		jobStore = jobs.NewRedisStore(redisClient, jobs.DefaultKeyPrefix)
+	}
+	queue := jobs.New(jobStore, jobs.Config{
+		Workers:      cfg.Jobs.Workers,
+		PollInterval: cfg.Jobs.PollInterval,
+		MaxAttempts:  cfg.Jobs.MaxAttempts,
+		RetryBackoff: cfg.Jobs.RetryBackoff,
+		StaleAfter:   cfg.Jobs.StaleAfter,
+		Retention:    cfg.Jobs.Retention,
+	})
+	workers.Register("jobs", queue)
+
+	tokenCleanup := cleanup.NewWorker(db, cleanup.WorkerConfig{
+		Interval:  cfg.Auth.TokenCleanupInterval,
+		BatchSize: cfg.Auth.TokenCleanupBatchSize,
+		Sessions:  sessionAdapter,
+	})
+	queue.Handle(cleanup.JobPrune, tokenCleanup.Job)
+	cleanupInterval := cfg.Auth.TokenCleanupInterval
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Hour
+	}
+	if err := queue.Schedule(cleanup.JobPrune, "@every "+cleanupInterval.String()); err != nil {
+		return nil, fmt.Errorf("auth.token_cleanup_interval inválido: %w", err)
+	}
+
+	// Webhooks for external systems, only delivered when endpoints are configured
+	endpoints := make([]webhooks.Endpoint, len(cfg.Webhooks.Endpoints))
+	for i, endpoint := range cfg.Webhooks.Endpoints {
+		endpoints[i] = webhooks.Endpoint{URL: endpoint.URL, Secret: endpoint.Secret, Events: endpoint.Events}
+	}
+	webhookDispatcher, err := webhooks.New(webhooks.Config{
+		Endpoints:    endpoints,
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		RetryBackoff: cfg.Webhooks.RetryBackoff,
+		Timeout:      cfg.Webhooks.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("configuração de webhooks inválida: %w", err)
+	}
+	var webhookPublisher webhooks.Publisher
+	if len(endpoints) > 0 {
+		webhookPublisher = webhookDispatcher
+		workers.Register("webhooks", webhookDispatcher)
+	}
+
+	workers.Register("account-purge", cleanup.NewAccountPurger(db, cleanup.AccountPurgerConfig{
+		Interval: cfg.Auth.AccountPurgeInterval,
+		Events:   webhookPublisher,
+	}))
+
+	// Runtime settings: the config holds the defaults, admins the overrides
+	settingsStore := settings.NewStore(db, settings.Config{
+		Defaults: map[string]bool{
+			settings.KeyMaintenanceMode:     cfg.Settings.MaintenanceMode,
+			settings.KeyRegistrationEnabled: cfg.Settings.RegistrationEnabled,
+			settings.KeyNotifyNewDevice:     cfg.Auth.NotifyNewDevice,
+		},
+		RefreshInterval: cfg.Settings.RefreshInterval,
+	})
+	if err := settingsStore.Refresh(context.Background()); err != nil {
+		return nil, fmt.Errorf("falha ao carregar configurações do banco de dados: %w", err)
+	}
+	workers.Register("settings-refresh", settingsStore)
+
 	// Initialize services
 	emailService := email.NewEmailService(cfg)
-	authService := service.NewAuthService(authManager, userAdapter, emailService)
+	if err := emailService.CheckTemplates(); err != nil {
+		return nil, fmt.Errorf("templates de email inválidos: %w", err)
+	}
+	outbox.Register(queue, emailService)
+	auditLog := audit.NewStore(db)
+	serviceOpts := []service.Option{service.WithJobs(queue), service.WithAudit(auditLog)}
+	if cfg.Email.SendWelcome {
+		serviceOpts = append(serviceOpts, service.WithWelcomeEmail(cfg.Email.WelcomeTrigger))
+	}
+	if cfg.Auth.StrictRegistrationEmail {
+		serviceOpts = append(serviceOpts, service.WithStrictRegistrationEmail())
+	}
+	if cfg.Auth.NotifyOnLockout {
+		serviceOpts = append(serviceOpts, service.WithLockoutNotification())
+	}
+	if cfg.Auth.VerificationReminderAfter > 0 {
+		workers.Register("verification-reminder", cleanup.NewVerificationReminder(db, cleanup.VerificationReminderConfig{
+			Interval:    cfg.Auth.VerificationReminderInterval,
+			RemindAfter: cfg.Auth.VerificationReminderAfter,
+			DeleteAfter: cfg.Auth.UnverifiedAccountDeleteAfter,
+			Email:       emailService,
+		}))
+	}
+	serviceOpts = append(serviceOpts, service.WithSettings(settingsStore))
+	serviceOpts = append(serviceOpts, service.WithAccountDeletionGracePeriod(cfg.Auth.AccountDeletionGracePeriod))
+	if webhookPublisher != nil {
+		serviceOpts = append(serviceOpts, service.WithWebhooks(webhookPublisher))
+	}
+	if cfg.Auth.ResetTokenMode == config.ResetTokenSigned {
+		serviceOpts = append(serviceOpts, service.WithSignedResetTokens([]byte(cfg.Auth.ResetTokenSecret)))
+	}
+	if cfg.Auth.EmailVerificationSecret != "" {
+		serviceOpts = append(serviceOpts, service.WithEmailVerification([]byte(cfg.Auth.EmailVerificationSecret), cfg.Auth.EmailVerificationTTL))
+	}
+	if cfg.Auth.MagicLinkEnabled {
+		serviceOpts = append(serviceOpts, service.WithMagicLinks())
+	}
+	captchaVerifier, err := captcha.New(cfg.Auth.CaptchaProvider, cfg.Auth.CaptchaSecret)
+	if err != nil {
+		return nil, fmt.Errorf("configuração de captcha inválida: %w", err)
+	}
+	if _, noop := captchaVerifier.(captcha.NoopVerifier); noop && cfg.Environment.IsProduction() {
+		logger.Warn("Captcha desativado em produção: cadastros não são protegidos contra bots")
+	}
+	serviceOpts = append(serviceOpts, service.WithCaptcha(captchaVerifier))
+	smsSender, err := sms.New(cfg.SMS.Provider, sms.TwilioConfig{
+		AccountSID: cfg.SMS.TwilioAccountSID,
+		AuthToken:  cfg.SMS.TwilioAuthToken,
+		From:       cfg.SMS.TwilioFrom,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("configuração de SMS inválida: %w", err)
+	}
+	if _, noop := smsSender.(sms.NoopSender); noop && cfg.Environment.IsProduction() {
+		logger.Warn("Envio de SMS desativado em produção: códigos de verificação só aparecem no log")
+	}
+	serviceOpts = append(serviceOpts, service.WithSMSSender(smsSender))
+	serviceOpts = append(serviceOpts, service.WithPasswordChangeSessionRevocation(cfg.Auth.RevokeSessionsOnReset, cfg.Auth.KeepCurrentSessionOnPasswordChange))
+
+	// WebSocket notifications, for the clients connected to this instance
+	var hub *realtime.Hub
+	if cfg.Realtime.Enabled {
+		// With "*" other sites don't send cookies to the API, but a handshake
+		// would carry them: only listed origins may connect
+		checkOrigin := cfg.Server.CORS.AllowsOrigin
+		if cfg.Server.CORS.AllowsAllOrigins() {
+			checkOrigin = nil
+		}
+		hub = realtime.NewHub(realtime.Config{
+			PingInterval:          cfg.Realtime.PingInterval,
+			PongTimeout:           cfg.Realtime.PongTimeout,
+			MaxConnectionsPerUser: cfg.Realtime.MaxConnectionsPerUser,
+			CheckOrigin:           checkOrigin,
+			Sessions:              authManager,
+		})
+		serviceOpts = append(serviceOpts, service.WithRealtime(hub))
+	}
+	authService := service.NewAuthService(authManager, userAdapter, emailService, serviceOpts...)
+
+	// Uploaded files; links to local ones go through the files route
+	filesURL := cfg.Server.AbsoluteURL("/files")
+	if cfg.Server.PublicURL == "" {
+		filesURL = cfg.Server.NormalizedBasePath() + "/files"
+	}
+	fileStorage, err := storage.New(cfg.Storage, filesURL)
+	if err != nil {
+		return nil, fmt.Errorf("configuração de armazenamento inválida: %w", err)
+	}
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(authService)
+	authHandler := handlers.NewAuthHandler(authService,
+		handlers.WithRegistrationResponse(cfg.Auth.AutoLoginAfterRegister, cfg.Auth.RequireEmailVerification || authConfig.RequireVerifiedEmail),
+		handlers.WithEmailAvailability(cfg.Auth.RevealEmailAvailability),
+		handlers.WithAvatarStorage(fileStorage, cfg.Storage),
+	)
+	userHandler := handlers.NewUserHandler(service.NewUserService(repository.NewUserRepository(db),
+		service.WithAllowedRoles(cfg.Auth.Roles...),
+		service.WithUsernamePolicy(authManager.UsernamePolicy()),
+		service.WithSessionRevocation(authManager),
+		service.WithPasswordHasher(passwordHasher),
+	), handlers.WithAuditRecorder(auditLog))
+
+	// Health checks reported by the readiness endpoint
+	healthAggregator := healthcheck.NewAggregator(healthcheck.DefaultTimeout,
+		healthcheck.Component{Name: "database", Checker: healthcheck.DatabaseChecker(db), Critical: true},
+	)
+	if cfg.Email.SMTPHost != "" {
+		healthAggregator.Register(healthcheck.Component{
+			Name:    "email",
+			Checker: healthcheck.TCPChecker(fmt.Sprintf("%s:%d", cfg.Email.SMTPHost, cfg.Email.SMTPPort)),
+		})
+	}
+	if redisClient != nil {
+		// Without Redis nobody can log in; rate limits alone fail open
+		pingRedis := func(ctx context.Context) error { return redisClient.Ping(ctx).Err() }
+		healthAggregator.Register(healthcheck.Component{
+			Name:     "redis",
+			Checker:  healthcheck.CheckerFunc(pingRedis),
+			Critical: cfg.Auth.SessionStore == config.SessionStoreRedis,
+		})
+	}
+	healthHandler := handlers.NewHealthHandler(healthAggregator, handlers.WithVersionMaxAge(cfg.Server.VersionMaxAge))
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("falha ao obter conexão do banco de dados: %w", err)
+	}
 
 	// Setup router
-	r := router.SetupRouter(authHandler, authManager)
+	inFlight := middleware.NewInFlight()
+	routerOpts := []router.Option{
+		router.WithConfig(cfg),
+		router.WithInFlight(inFlight),
+		router.WithHealthHandler(healthHandler),
+		router.WithUserHandler(userHandler),
+		router.WithMaintenanceHandler(handlers.NewMaintenanceHandler(tokenCleanup)),
+		router.WithDiagnosticsHandler(handlers.NewDiagnosticsHandler(sqlDB, startedAt)),
+		router.WithSettingsHandler(handlers.NewSettingsHandler(settingsStore, handlers.WithAuditRecorder(auditLog))),
+		router.WithSessionHandler(handlers.NewSessionHandler(authManager)),
+		router.WithLockoutHandler(handlers.NewLockoutHandler(authManager, handlers.WithAuditRecorder(auditLog))),
+		router.WithAPIKeyHandler(handlers.NewAPIKeyHandler(authManager, handlers.WithAuditRecorder(auditLog))),
+		router.WithJobHandler(handlers.NewJobHandler(queue)),
+		router.WithAuditHandler(handlers.NewAuditHandler(auditLog)),
+		router.WithMaintenanceMode(func() bool { return settingsStore.Bool(settings.KeyMaintenanceMode) }),
+	}
+	if local, ok := fileStorage.(*storage.Local); ok {
+		routerOpts = append(routerOpts, router.WithFileHandler(handlers.NewFileHandler(local)))
+	}
+	if permissions != nil {
+		routerOpts = append(routerOpts, router.WithPermissionPolicy(permissions), router.WithRoleHandler(handlers.NewRoleHandler(permissions)))
+	}
+	if providers := oauthProviders(cfg); len(providers) > 0 {
+		routerOpts = append(routerOpts, router.WithOAuthHandler(handlers.NewOAuthHandler(authService, providers, cfg.OAuth.RedirectURL, handlers.WithOAuthTokenGenerator(authManager.Tokens()))))
+	}
+	if cfg.RateLimit.Store == config.RateLimitStoreRedis {
+		routerOpts = append(routerOpts, router.WithRateLimitStore(middleware.NewRedisRateLimitStore(redisClient, "ratelimit:")))
+	}
+	if cfg.HTTPCache.Store == config.HTTPCacheStoreRedis {
+		routerOpts = append(routerOpts, router.WithResponseCacheStore(middleware.NewRedisResponseCache(redisClient, "httpcache:")))
+	}
+	if hub != nil {
+		routerOpts = append(routerOpts, router.WithRealtimeHandler(handlers.NewRealtimeHandler(hub)))
+	}
+	r := router.SetupRouter(authHandler, authManager, routerOpts...)
+	return &app{router: r, workers: workers, inFlight: inFlight, redis: redisClient, realtime: hub}, nil
+}
 
-	// Start server
-	port := ":8080"
-	if cfg.Server.Port != 0 {
-		port = fmt.Sprintf(":%d", cfg.Server.Port)
+// newRedisClient connects to the redis section of the config
+func newRedisClient(cfg config.RedisConfig) *redis.Client {
+	return redis.NewClient(&redis.Options{Addr: cfg.Addr, Password: cfg.Password, DB: cfg.DB})
+}
+
+// oauthProviders returns the social login providers enabled in the config
+func oauthProviders(cfg *config.Config) []oauth.Provider {
+	callbackURL := func(name string) string {
+		return cfg.Server.AbsoluteURL("/auth/oauth/" + name + "/callback")
 	}
-	logger.Info("Servidor iniciado", "port", port)
-	if err := r.Run(port); err != nil {
-		logger.Error("Erro ao iniciar servidor", "error", err, "port", port)
-		os.Exit(1)
+	var providers []oauth.Provider
+	if p := cfg.OAuth.Google; p.Enabled() {
+		providers = append(providers, oauth.NewGoogle(config.OAuthGoogle, p.ClientID, p.ClientSecret, callbackURL(config.OAuthGoogle)))
+	}
+	if p := cfg.OAuth.GitHub; p.Enabled() {
+		providers = append(providers, oauth.NewGitHub(config.OAuthGitHub, p.ClientID, p.ClientSecret, callbackURL(config.OAuthGitHub)))
+	}
+	if p := cfg.OAuth.OIDC; p.Enabled() {
+		providers = append(providers, oauth.NewOIDC(config.OAuthOIDC, p.IssuerURL, p.ClientID, p.ClientSecret, callbackURL(config.OAuthOIDC), p.Scopes))
+	}
+	for _, p := range providers {
+		logger.Info("Login social ativado", "provider", p.Name(), "callback_url", callbackURL(p.Name()))
 	}
+	return providers
 }
@@ -0,0 +1,171 @@
+// Package apierror answers API errors as RFC 7807 problem details
+// (application/problem+json), so every endpoint fails the same way.
+//
+// A problem carries the standard members (type, title, status, detail,
+// instance) plus "code", a stable identifier clients can branch on, "errors"
+// with the invalid fields of a request and "error", the same text as detail,
+// for clients written against the former {"error": "..."} bodies.
+package apierror
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of problem responses
+const ContentType = "application/problem+json"
+
+// Codes of the typed errors. Errors built with New default to the status
+// text in snake case, e.g. "not_found" or "service_unavailable".
+const (
+	CodeBadRequest   = "bad_request"
+	CodeValidation   = "validation_failed"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeRateLimited  = "rate_limited"
+	CodeInternal     = "internal_error"
+)
+
+// Error is an error answered to the client
+type Error struct {
+	Status int
+	Code   string // see the Code constants; empty uses the default of Status
+	Detail string // message shown to the user
+
+	// Fields lists the invalid fields of the request, for validation errors
+	Fields []FieldError
+
+	// Extensions are extra members of the problem, e.g. the stack trace of
+	// verbose 500 responses
+	Extensions map[string]any
+}
+
+// FieldError is an invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"` // JSON name, dotted for nested fields
+	Rule    string `json:"rule"`  // rule the value broke, e.g. "required" or "email"
+	Message string `json:"message"`
+}
+
+// New returns an error answered with status
+func New(status int, detail string) *Error {
+	return &Error{Status: status, Detail: detail}
+}
+
+// BadRequest is a malformed request, answered with 400
+func BadRequest(detail string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeBadRequest, Detail: detail}
+}
+
+// Validation is a well-formed request with invalid values, answered with 400
+// and the offending fields when known
+func Validation(detail string, fields ...FieldError) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidation, Detail: detail, Fields: fields}
+}
+
+// Unauthorized is a missing or invalid credential, answered with 401
+func Unauthorized(detail string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Detail: detail}
+}
+
+// Forbidden is an authenticated request that isn't allowed, answered with 403
+func Forbidden(detail string) *Error {
+	return &Error{Status: http.StatusForbidden, Code: CodeForbidden, Detail: detail}
+}
+
+// NotFound is a missing resource, answered with 404
+func NotFound(detail string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Detail: detail}
+}
+
+// Conflict is a request clashing with the current state, answered with 409
+func Conflict(detail string) *Error {
+	return &Error{Status: http.StatusConflict, Code: CodeConflict, Detail: detail}
+}
+
+// RateLimited is a request over a rate limit, answered with 429. The limiter
+// sets Retry-After itself.
+func RateLimited(detail string) *Error {
+	return &Error{Status: http.StatusTooManyRequests, Code: CodeRateLimited, Detail: detail}
+}
+
+// Internal is an unexpected failure, answered with 500. Log the cause: the
+// detail is all the client sees.
+func Internal(detail string) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Detail: detail}
+}
+
+// WithCode replaces the code of e and returns it
+func (e *Error) WithCode(code string) *Error {
+	e.Code = code
+	return e
+}
+
+// With adds the extension member key to e and returns it
+func (e *Error) With(key string, value any) *Error {
+	if e.Extensions == nil {
+		e.Extensions = make(map[string]any)
+	}
+	e.Extensions[key] = value
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// code returns the code of e, defaulting to the status text in snake case
+func (e *Error) code() string {
+	if e.Code != "" {
+		return e.Code
+	}
+	return strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(strings.ToLower(http.StatusText(e.Status)))
+}
+
+// Problem returns the problem details document of e. instance identifies the
+// occurrence, usually the request path.
+func (e *Error) Problem(instance string) map[string]any {
+	problem := make(map[string]any, len(e.Extensions)+8)
+	for key, value := range e.Extensions {
+		problem[key] = value
+	}
+	problem["type"] = "about:blank"
+	problem["title"] = http.StatusText(e.Status)
+	problem["status"] = e.Status
+	problem["code"] = e.code()
+	if e.Detail != "" {
+		problem["detail"] = e.Detail
+	}
+	problem["error"] = e.Detail
+	if instance != "" {
+		problem["instance"] = instance
+	}
+	if len(e.Fields) > 0 {
+		problem["errors"] = e.Fields
+	}
+	return problem
+}
+
+// Respond answers the request with err. Handlers return right after; use
+// Abort in middleware.
+func Respond(c *gin.Context, err *Error) {
+	c.Header("Content-Type", ContentType)
+	c.JSON(err.Status, err.Problem(instance(c)))
+}
+
+// Abort answers the request with err and stops the handler chain
+func Abort(c *gin.Context, err *Error) {
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(err.Status, err.Problem(instance(c)))
+}
+
+func instance(c *gin.Context) string {
+	if c.Request == nil || c.Request.URL == nil {
+		return ""
+	}
+	return c.Request.URL.Path
+}
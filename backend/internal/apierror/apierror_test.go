@@ -0,0 +1,112 @@
+package apierror
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func respond(t *testing.T, err *Error) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodGet, "/api/items/7", nil)
+	Respond(c, err)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return w, body
+}
+
+func TestRespond(t *testing.T) {
+	w, body := respond(t, Conflict("nome já em uso"))
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, map[string]any{
+		"type":     "about:blank",
+		"title":    "Conflict",
+		"status":   float64(http.StatusConflict),
+		"code":     CodeConflict,
+		"detail":   "nome já em uso",
+		"error":    "nome já em uso",
+		"instance": "/api/items/7",
+	}, body)
+}
+
+func TestRespond_CodesAndExtensions(t *testing.T) {
+	_, body := respond(t, New(http.StatusServiceUnavailable, "em manutenção"))
+	assert.Equal(t, "service_unavailable", body["code"], "defaults to the status text")
+
+	_, body = respond(t, Forbidden("token CSRF ausente ou inválido").WithCode("csrf_token_invalid"))
+	assert.Equal(t, "csrf_token_invalid", body["code"])
+
+	_, body = respond(t, Internal("falha").With("details", "db down").With("status", "ignored"))
+	assert.Equal(t, "db down", body["details"])
+	assert.Equal(t, float64(http.StatusInternalServerError), body["status"], "extensions can't replace standard members")
+}
+
+type bindRequest struct {
+	Email   string   `json:"email" binding:"required,email"`
+	Name    string   `json:"name" binding:"required,notblank,max=5"`
+	Locale  string   `json:"locale" binding:"omitempty,locale"`
+	Tags    []string `json:"tags" binding:"omitempty,min=2"`
+	Enabled *bool    `json:"enabled"`
+}
+
+func bind(t *testing.T, body string) *Error {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	var req bindRequest
+	err := c.ShouldBindJSON(&req)
+	require.Error(t, err)
+	return FromBinding(err)
+}
+
+func TestFromBinding(t *testing.T) {
+	t.Run("InvalidFields", func(t *testing.T) {
+		err := bind(t, `{"email":"nope","name":"   ","locale":"not a locale!","tags":["a"]}`)
+
+		assert.Equal(t, http.StatusBadRequest, err.Status)
+		assert.Equal(t, CodeValidation, err.Code)
+		assert.Equal(t, []FieldError{
+			{Field: "email", Rule: "email", Message: "endereço de email inválido"},
+			{Field: "name", Rule: "notblank", Message: "não pode estar em branco"},
+			{Field: "locale", Rule: "locale", Message: "idioma inválido, use uma tag como pt-BR ou en"},
+			{Field: "tags", Rule: "min", Message: "deve ter pelo menos 2 itens"},
+		}, err.Fields)
+		assert.Contains(t, err.Detail, "email: endereço de email inválido")
+	})
+
+	t.Run("Required", func(t *testing.T) {
+		err := bind(t, `{"name":"toolongname"}`)
+		assert.Equal(t, []FieldError{
+			{Field: "email", Rule: "required", Message: "campo obrigatório"},
+			{Field: "name", Rule: "max", Message: "não pode ter mais de 5 caracteres"},
+		}, err.Fields)
+	})
+
+	t.Run("WrongType", func(t *testing.T) {
+		err := bind(t, `{"email":"a@example.com","name":"ok","enabled":"yes"}`)
+		assert.Equal(t, CodeValidation, err.Code)
+		assert.Equal(t, []FieldError{{Field: "enabled", Rule: "type", Message: "deve ser do tipo boolean"}}, err.Fields)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		for _, body := range []string{"", `{"email":`, `not json`} {
+			err := bind(t, body)
+			assert.Equal(t, CodeBadRequest, err.Code, body)
+			assert.Empty(t, err.Fields, body)
+		}
+	})
+}
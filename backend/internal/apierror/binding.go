@@ -0,0 +1,149 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"gosveltekit/internal/validation"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Custom rules for binding tags, registered on gin's validator when the
+// package is loaded
+const (
+	// RuleLocale accepts a language tag such as pt-BR, see
+	// validation.ValidateLocale. Combine with omitempty when optional.
+	RuleLocale = "locale"
+	// RuleNotBlank rejects strings made only of whitespace
+	RuleNotBlank = "notblank"
+)
+
+func init() {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	// Report fields by the name clients send
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	_ = engine.RegisterValidation(RuleLocale, func(fl validator.FieldLevel) bool {
+		return validation.ValidateLocale(fl.Field().String()) == nil
+	})
+	_ = engine.RegisterValidation(RuleNotBlank, func(fl validator.FieldLevel) bool {
+		return strings.TrimSpace(fl.Field().String()) != ""
+	})
+}
+
+// FromBinding turns an error of c.ShouldBindJSON (or another gin binding)
+// into the error to answer: invalid fields become a validation error listing
+// each of them, unreadable bodies a bad request.
+func FromBinding(err error) *Error {
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		fields := make([]FieldError, len(invalid))
+		for i, fe := range invalid {
+			fields[i] = FieldError{Field: fieldPath(fe), Rule: fe.Tag(), Message: fieldMessage(fe)}
+		}
+		return invalidFields(fields)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return invalidFields([]FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("deve ser do tipo %s", jsonType(typeErr.Type)),
+		}})
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &syntaxErr) {
+		return BadRequest("corpo da requisição não é um JSON válido")
+	}
+	return BadRequest(err.Error())
+}
+
+// invalidFields is the validation error of fields, whose detail lists them
+// for clients that only show the message
+func invalidFields(fields []FieldError) *Error {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field.Field + ": " + field.Message
+	}
+	return Validation("dados inválidos: "+strings.Join(parts, "; "), fields...)
+}
+
+// fieldPath returns the dotted JSON path of fe, without the struct name
+func fieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
+
+// fieldMessage describes the rule fe broke, in the language of the API
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "campo obrigatório"
+	case "email":
+		return "endereço de email inválido"
+	case "min", "gte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("deve ter pelo menos %s caracteres", fe.Param())
+		}
+		if fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map {
+			return fmt.Sprintf("deve ter pelo menos %s itens", fe.Param())
+		}
+		return fmt.Sprintf("deve ser pelo menos %s", fe.Param())
+	case "max", "lte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("não pode ter mais de %s caracteres", fe.Param())
+		}
+		if fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map {
+			return fmt.Sprintf("não pode ter mais de %s itens", fe.Param())
+		}
+		return fmt.Sprintf("não pode ser maior que %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("deve ser um de: %s", strings.ReplaceAll(fe.Param(), " ", ", "))
+	case RuleLocale:
+		return validation.ErrLocaleInvalid.Error()
+	case RuleNotBlank:
+		return "não pode estar em branco"
+	default:
+		return fmt.Sprintf("não atende à regra %s", fe.Tag())
+	}
+}
+
+// jsonType names t as a JSON type
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
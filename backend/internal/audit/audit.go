@@ -0,0 +1,80 @@
+// Package audit records security-relevant events: logins, password and role
+// changes, revoked sessions and admin actions, with who did it, to whom, from
+// where and when.
+//
+// Events are recorded through a Recorder. Recording never fails the action
+// that triggered it: errors are logged. Store keeps them in the audit_logs
+// table, inside the transaction of the request when there is one (see
+// database.Conn), so a rolled back admin action leaves no trace claiming it
+// happened.
+package audit
+
+import (
+	"context"
+)
+
+// Events
+const (
+	EventLoginSucceeded         = "login.succeeded"
+	EventLoginFailed            = "login.failed"
+	EventPasswordChanged        = "password.changed"
+	EventPasswordReset          = "password.reset"
+	EventPasswordResetRequested = "password.reset_requested" // by an admin
+	EventPasswordExpired        = "password.expired"
+	EventSessionRevoked         = "session.revoked"
+	EventImpersonationStarted   = "session.impersonated"
+	EventUserCreated            = "user.created"
+	EventUserUpdated            = "user.updated"
+	EventUserRoleChanged        = "user.role_changed"
+	EventUserDisabled           = "user.disabled"
+	EventUserEnabled            = "user.enabled"
+	EventUserUnlocked           = "user.unlocked"
+	EventAccountRestored        = "user.restored"
+	EventLockoutRemoved         = "lockout.removed"
+	EventSettingChanged         = "setting.changed"
+	EventAPIKeyCreated          = "api_key.created"
+	EventAPIKeyRevoked          = "api_key.revoked"
+)
+
+// Event is an action to record
+type Event struct {
+	Type string
+	// ActorID is the user performing the action; empty when anonymous, such
+	// as a failed login
+	ActorID string
+	// TargetID is the user affected by the action
+	TargetID string
+	// IP and UserAgent default to the ones stored by WithClient
+	IP        string
+	UserAgent string
+	// Data holds event details, serialized as JSON. Never put credentials
+	// or tokens in it.
+	Data map[string]any
+}
+
+// Recorder records events. Record must not fail the caller's action, so
+// implementations log their errors instead of returning them.
+type Recorder interface {
+	Record(ctx context.Context, event Event)
+}
+
+type clientKey struct{}
+
+type client struct {
+	ip, userAgent string
+}
+
+// WithClient returns a copy of ctx carrying the IP and user agent of the
+// request, recorded with every event of the request that doesn't set its own
+func WithClient(ctx context.Context, ip, userAgent string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client{ip: ip, userAgent: userAgent})
+}
+
+// ClientFromContext returns the IP and user agent stored by WithClient
+func ClientFromContext(ctx context.Context) (ip, userAgent string) {
+	if ctx == nil {
+		return "", ""
+	}
+	c, _ := ctx.Value(clientKey{}).(client)
+	return c.ip, c.userAgent
+}
@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gosveltekit/internal/database"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/pagination"
+	"gosveltekit/internal/query"
+	"gosveltekit/internal/tenant"
+
+	"gorm.io/gorm"
+)
+
+// Filter narrows a listing. Zero values match every entry.
+type Filter struct {
+	Event    string
+	ActorID  string
+	TargetID string
+	Since    time.Time // inclusive
+	Until    time.Time // exclusive
+}
+
+// Store records events in the audit_logs table
+type Store struct {
+	db *gorm.DB
+}
+
+// NewStore creates a Store
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// Record saves the event with the tenant and request ID of ctx
+func (s *Store) Record(ctx context.Context, event Event) {
+	entry := &models.AuditLog{
+		TenantID:  tenant.FromContext(ctx),
+		Event:     event.Type,
+		ActorID:   event.ActorID,
+		TargetID:  event.TargetID,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		RequestID: logger.RequestIDFromContext(ctx),
+	}
+	ip, userAgent := ClientFromContext(ctx)
+	if entry.IP == "" {
+		entry.IP = ip
+	}
+	if entry.UserAgent == "" {
+		entry.UserAgent = userAgent
+	}
+	if len(event.Data) > 0 {
+		data, err := json.Marshal(event.Data)
+		if err != nil {
+			logger.FromContext(ctx).Error("Erro ao serializar evento de auditoria", "error", err, "event", event.Type)
+		}
+		entry.Data = string(data)
+	}
+
+	if err := database.Conn(ctx, s.db).Create(entry).Error; err != nil {
+		logger.FromContext(ctx).Error("Erro ao registrar evento de auditoria", "error", err, "event", event.Type, "actor_id", event.ActorID, "target_id", event.TargetID)
+	}
+}
+
+// List returns the entries matching filter, most recent first, along with
+// their total count
+func (s *Store) List(ctx context.Context, filter Filter, offset, limit int) ([]*models.AuditLog, int64, error) {
+	return query.Paginate[*models.AuditLog](s.filtered(ctx, filter), query.Options{
+		Offset: offset,
+		Limit:  limit,
+		Sort:   pagination.Sort{Column: "id", Desc: true},
+	})
+}
+
+// Each calls fn for every entry matching filter, most recent first, reading
+// them from a cursor so exports of any size stay out of memory
+func (s *Store) Each(ctx context.Context, filter Filter, fn func(*models.AuditLog) error) error {
+	return query.Each(s.filtered(ctx, filter).Order("id DESC"), fn)
+}
+
+// filtered returns the query of the entries matching filter
+func (s *Store) filtered(ctx context.Context, filter Filter) *gorm.DB {
+	db := database.Conn(ctx, s.db).Clauses(database.ReadReplica()).Model(&models.AuditLog{})
+	if filter.Event != "" {
+		db = db.Where("event = ?", filter.Event)
+	}
+	if filter.ActorID != "" {
+		db = db.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetID != "" {
+		db = db.Where("target_id = ?", filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		db = db.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		db = db.Where("created_at < ?", filter.Until)
+	}
+	return db
+}
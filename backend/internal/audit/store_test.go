@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/database"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/query"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&models.AuditLog{}))
+	return db
+}
+
+func TestStore(t *testing.T) {
+	db := setupTestDB(t)
+	store := NewStore(db)
+	ctx := WithClient(logger.WithRequestID(context.Background(), "req-1"), "203.0.113.7", "curl/8.0")
+
+	store.Record(ctx, Event{Type: EventLoginFailed, Data: map[string]any{"identifier": "alice", "reason": "invalid_credentials"}})
+	store.Record(ctx, Event{Type: EventLoginSucceeded, ActorID: "1", TargetID: "1"})
+	store.Record(ctx, Event{Type: EventUserRoleChanged, ActorID: "1", TargetID: "2", IP: "198.51.100.1"})
+
+	entries, total, err := store.List(context.Background(), Filter{}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	require.Len(t, entries, 3)
+
+	// Most recent first, with the client of the request unless overridden
+	assert.Equal(t, EventUserRoleChanged, entries[0].Event)
+	assert.Equal(t, "198.51.100.1", entries[0].IP)
+	assert.Equal(t, "curl/8.0", entries[0].UserAgent)
+	assert.Equal(t, "req-1", entries[0].RequestID)
+	assert.Equal(t, "203.0.113.7", entries[2].IP)
+	assert.JSONEq(t, `{"identifier":"alice","reason":"invalid_credentials"}`, entries[2].Data)
+
+	_, total, err = store.List(context.Background(), Filter{ActorID: "1"}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	entries, total, err = store.List(context.Background(), Filter{TargetID: "2", Event: EventUserRoleChanged}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "2", entries[0].TargetID)
+
+	var exported []string
+	require.NoError(t, store.Each(context.Background(), Filter{ActorID: "1"}, func(entry *models.AuditLog) error {
+		exported = append(exported, entry.Event)
+		return nil
+	}))
+	assert.Equal(t, []string{EventUserRoleChanged, EventLoginSucceeded}, exported)
+
+	_, total, err = store.List(context.Background(), Filter{Since: time.Now().Add(time.Hour)}, 0, 10)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	entries, total, err = store.List(context.Background(), Filter{Until: time.Now().Add(time.Hour)}, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	require.Len(t, entries, 1)
+	assert.Equal(t, EventLoginSucceeded, entries[0].Event)
+
+	_, _, err = store.List(context.Background(), Filter{}, -1, 10)
+	assert.ErrorIs(t, err, query.ErrInvalidPage)
+}
+
+func TestStore_RecordInTransaction(t *testing.T) {
+	db := setupTestDB(t)
+	store := NewStore(db)
+
+	// A rolled back admin action leaves no entry
+	tx := db.Begin()
+	store.Record(database.WithTx(context.Background(), tx), Event{Type: EventUserDisabled, ActorID: "1", TargetID: "2"})
+	require.NoError(t, tx.Rollback().Error)
+
+	_, total, err := store.List(context.Background(), Filter{}, 0, 10)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
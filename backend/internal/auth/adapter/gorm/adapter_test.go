@@ -0,0 +1,507 @@
+// Package gorm tests
+package gorm
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Session{}, &models.PasswordHistory{}, &models.ExternalIdentity{}, &models.TOTPSecret{}, &models.TwoFactorChallenge{}, &models.RefreshToken{}, &models.LoginAttempt{}, &models.APIKey{}, &models.MagicLink{}))
+	return db
+}
+
+func TestUserAdapter_NotFound(t *testing.T) {
+	adapter := NewUserAdapter(setupTestDB(t))
+	ctx := context.Background()
+	unknownUUID := "6f1c2b9e-3a4d-4e5f-8a7b-9c0d1e2f3a4b"
+
+	_, err := adapter.GetUserModel(ctx, "999")
+	assertTyped(t, err, auth.ErrUserNotFound)
+	_, err = adapter.GetUserModel(ctx, unknownUUID)
+	assertTyped(t, err, auth.ErrUserNotFound)
+	_, err = adapter.FindByEmail(ctx, "nobody@example.com")
+	assertTyped(t, err, auth.ErrUserNotFound)
+	_, err = adapter.FindByResetToken(ctx, "unknown")
+	assertTyped(t, err, auth.ErrUserNotFound)
+	_, err = adapter.VerifyPassword(ctx, "999", "password123")
+	assertTyped(t, err, auth.ErrUserNotFound)
+	_, err = adapter.IsRecentPassword(ctx, "999", "password123", 3)
+	assertTyped(t, err, auth.ErrUserNotFound)
+	err = adapter.UpdatePasswordWithHistory(ctx, "999", "password123", 3)
+	assertTyped(t, err, auth.ErrUserNotFound)
+	err = adapter.ClearResetToken(ctx, unknownUUID)
+	assertTyped(t, err, auth.ErrUserNotFound)
+
+	// Lookups used for login keep answering with invalid credentials
+	_, err = adapter.FindUserByIdentifier(ctx, "nobody")
+	assertTyped(t, err, auth.ErrInvalidCredentials)
+	_, err = adapter.FindUserByID(ctx, "999")
+	assertTyped(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestUserAdapter_ExternalIdentity(t *testing.T) {
+	adapter := NewUserAdapter(setupTestDB(t))
+	ctx := context.Background()
+	user, err := adapter.CreateUser(ctx, auth.CreateUserInput{Identifier: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	_, err = adapter.FindUserByExternalIdentity(ctx, "github", "42")
+	assertTyped(t, err, auth.ErrUserNotFound)
+
+	require.NoError(t, adapter.LinkExternalIdentity(ctx, user.ID, "github", "42", "alice@example.com"))
+	found, err := adapter.FindUserByExternalIdentity(ctx, "github", "42")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	// Subjects are per provider
+	_, err = adapter.FindUserByExternalIdentity(ctx, "google", "42")
+	assertTyped(t, err, auth.ErrUserNotFound)
+
+	other, err := adapter.CreateUser(ctx, auth.CreateUserInput{Identifier: "bob", Email: "bob@example.com", Password: "password123"})
+	require.NoError(t, err)
+	assert.Error(t, adapter.LinkExternalIdentity(ctx, other.ID, "github", "42", "bob@example.com"), "a subject belongs to one user")
+	err = adapter.LinkExternalIdentity(ctx, "6f1c2b9e-3a4d-4e5f-8a7b-9c0d1e2f3a4b", "google", "7", "")
+	assertTyped(t, err, auth.ErrUserNotFound)
+}
+
+func TestUserAdapter_ConsumeResetToken(t *testing.T) {
+	db := setupTestDB(t)
+	adapter := NewUserAdapter(db)
+	ctx := context.Background()
+	user, err := adapter.CreateUser(ctx, auth.CreateUserInput{Identifier: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&models.User{}).Where("email = ?", user.Email).Updates(map[string]any{"reset_token": "hashed", "reset_token_expiry": time.Now().Add(time.Hour)}).Error)
+
+	consumed, err := adapter.ConsumeResetToken(ctx, "other")
+	require.NoError(t, err)
+	assert.False(t, consumed)
+	consumed, err = adapter.ConsumeResetToken(ctx, "hashed")
+	require.NoError(t, err)
+	assert.True(t, consumed)
+
+	// Only the first use wins
+	consumed, err = adapter.ConsumeResetToken(ctx, "hashed")
+	require.NoError(t, err)
+	assert.False(t, consumed)
+	_, err = adapter.FindByResetToken(ctx, "hashed")
+	assertTyped(t, err, auth.ErrUserNotFound)
+}
+
+func TestUserAdapter_ConsumePasswordVersion(t *testing.T) {
+	db := setupTestDB(t)
+	adapter := NewUserAdapter(db)
+	ctx := context.Background()
+	user, err := adapter.CreateUser(ctx, auth.CreateUserInput{Identifier: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&models.User{}).Where("email = ?", user.Email).Update("reset_token", "hashed").Error)
+
+	consumed, err := adapter.ConsumePasswordVersion(ctx, user.ID, 1)
+	require.NoError(t, err)
+	assert.False(t, consumed, "not the current version")
+	consumed, err = adapter.ConsumePasswordVersion(ctx, user.ID, 0)
+	require.NoError(t, err)
+	assert.True(t, consumed)
+
+	// Only the first use wins
+	consumed, err = adapter.ConsumePasswordVersion(ctx, user.ID, 0)
+	require.NoError(t, err)
+	assert.False(t, consumed)
+	stored, err := adapter.GetUserModel(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), stored.PasswordVersion)
+	assert.Empty(t, stored.ResetToken)
+}
+
+func TestUserAdapter_TOTPSecret(t *testing.T) {
+	adapter := NewUserAdapter(setupTestDB(t))
+	ctx := context.Background()
+	user, err := adapter.CreateUser(ctx, auth.CreateUserInput{Identifier: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	_, err = adapter.GetTOTPSecret(ctx, user.ID)
+	assertTyped(t, err, auth.ErrTOTPNotEnrolled)
+	assertTyped(t, adapter.ConfirmTOTPSecret(ctx, user.ID), auth.ErrTOTPNotEnrolled)
+
+	require.NoError(t, adapter.SaveTOTPSecret(ctx, user.ID, "OLDSECRET"))
+	require.NoError(t, adapter.SaveTOTPSecret(ctx, user.ID, "NEWSECRET"))
+	secret, err := adapter.GetTOTPSecret(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, auth.TOTPSecret{Secret: "NEWSECRET"}, *secret, "a new enrollment replaces the pending one")
+
+	require.NoError(t, adapter.ConfirmTOTPSecret(ctx, user.ID))
+	used, err := adapter.UseTOTPStep(ctx, user.ID, 100)
+	require.NoError(t, err)
+	assert.True(t, used)
+	for _, step := range []int64{100, 99} {
+		used, err = adapter.UseTOTPStep(ctx, user.ID, step)
+		require.NoError(t, err)
+		assert.False(t, used, "step %d is not newer than the last one used", step)
+	}
+	secret, err = adapter.GetTOTPSecret(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, auth.TOTPSecret{Secret: "NEWSECRET", Confirmed: true, LastUsedStep: 100}, *secret)
+
+	require.NoError(t, adapter.DeleteTOTPSecret(ctx, user.ID))
+	_, err = adapter.GetTOTPSecret(ctx, user.ID)
+	assertTyped(t, err, auth.ErrTOTPNotEnrolled)
+}
+
+func TestUserAdapter_APIKeys(t *testing.T) {
+	adapter := NewUserAdapter(setupTestDB(t))
+	ctx := context.Background()
+	user, err := adapter.CreateUser(ctx, auth.CreateUserInput{Identifier: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+	other, err := adapter.CreateUser(ctx, auth.CreateUserInput{Identifier: "bob", Email: "bob@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	_, _, err = adapter.GetAPIKey(ctx, "0123456789abcdef")
+	assertTyped(t, err, auth.ErrAPIKeyNotFound)
+
+	created := time.Date(2024, 2, 11, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, adapter.CreateAPIKey(ctx, auth.APIKey{ID: "0123456789abcdef", UserID: user.ID, Name: "cron", Scopes: []string{"audit:read", "users:read"}, CreatedAt: created}, "hash"))
+	require.NoError(t, adapter.CreateAPIKey(ctx, auth.APIKey{ID: "fedcba9876543210", UserID: user.ID, Name: "ci", Scopes: []string{"users:read"}, CreatedAt: created.Add(time.Minute)}, "hash2"))
+
+	key, hash, err := adapter.GetAPIKey(ctx, "0123456789abcdef")
+	require.NoError(t, err)
+	assert.Equal(t, "hash", hash)
+	assert.Equal(t, []string{"audit:read", "users:read"}, key.Scopes)
+	assert.Nil(t, key.LastUsedAt)
+
+	require.NoError(t, adapter.UpdateAPIKeyLastUsed(ctx, key.ID, created.Add(time.Hour)))
+	keys, err := adapter.ListAPIKeys(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "ci", keys[0].Name, "newest first")
+	require.NotNil(t, keys[1].LastUsedAt)
+	assert.True(t, keys[1].LastUsedAt.Equal(created.Add(time.Hour)))
+
+	assertTyped(t, adapter.DeleteAPIKey(ctx, other.ID, key.ID), auth.ErrAPIKeyNotFound)
+	require.NoError(t, adapter.DeleteAPIKey(ctx, user.ID, key.ID))
+	assertTyped(t, adapter.DeleteAPIKey(ctx, user.ID, key.ID), auth.ErrAPIKeyNotFound)
+}
+
+func TestUserAdapter_MagicLinks(t *testing.T) {
+	adapter := NewUserAdapter(setupTestDB(t))
+	ctx := context.Background()
+	user, err := adapter.CreateUser(ctx, auth.CreateUserInput{Identifier: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	_, err = adapter.ConsumeMagicLink(ctx, "missing")
+	assertTyped(t, err, auth.ErrInvalidMagicLink)
+
+	expiresAt := time.Now().Add(15 * time.Minute)
+	require.NoError(t, adapter.CreateMagicLink(ctx, "first", auth.MagicLink{UserID: user.ID, Email: user.Email, ExpiresAt: expiresAt}))
+	require.NoError(t, adapter.CreateMagicLink(ctx, "second", auth.MagicLink{UserID: user.ID, Email: user.Email, ExpiresAt: expiresAt}))
+
+	sent, err := adapter.MagicLinksSentSince(ctx, user.ID, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, sent, 2, "replaced links still count towards the rate limit")
+
+	_, err = adapter.ConsumeMagicLink(ctx, "first")
+	assertTyped(t, err, auth.ErrInvalidMagicLink)
+
+	link, err := adapter.ConsumeMagicLink(ctx, "second")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, link.UserID)
+	assert.Equal(t, "alice@example.com", link.Email)
+
+	_, err = adapter.ConsumeMagicLink(ctx, "second")
+	assertTyped(t, err, auth.ErrInvalidMagicLink)
+}
+
+func TestSessionAdapter_TwoFactorChallenge(t *testing.T) {
+	db := setupTestDB(t)
+	users := NewUserAdapter(db)
+	adapter := NewSessionAdapter(db)
+	ctx := context.Background()
+	user, err := users.CreateUser(ctx, auth.CreateUserInput{Identifier: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	_, err = adapter.GetTwoFactorChallenge(ctx, "missing")
+	assertTyped(t, err, auth.ErrInvalidTwoFactorChallenge)
+
+	expiresAt := time.Now().Add(5 * time.Minute).Truncate(time.Second)
+	metadata := auth.SessionMetadata{UserAgent: "test-agent", IP: "203.0.113.7"}
+	require.NoError(t, adapter.CreateTwoFactorChallenge(ctx, "hash", auth.TwoFactorChallenge{UserID: user.ID, ExpiresAt: expiresAt, Metadata: metadata}))
+	require.NoError(t, adapter.RecordTwoFactorAttempt(ctx, "hash"))
+
+	challenge, err := adapter.GetTwoFactorChallenge(ctx, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, challenge.UserID)
+	assert.True(t, expiresAt.Equal(challenge.ExpiresAt))
+	assert.Equal(t, 1, challenge.Attempts)
+	assert.Equal(t, metadata, challenge.Metadata)
+
+	deleted, err := adapter.DeleteTwoFactorChallenge(ctx, "hash")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = adapter.DeleteTwoFactorChallenge(ctx, "hash")
+	require.NoError(t, err)
+	assert.False(t, deleted, "a challenge is completed only once")
+}
+
+func TestSessionAdapter_RefreshTokens(t *testing.T) {
+	db := setupTestDB(t)
+	users := NewUserAdapter(db)
+	adapter := NewSessionAdapter(db)
+	ctx := context.Background()
+	user, err := users.CreateUser(ctx, auth.CreateUserInput{Identifier: "alice", Email: "alice@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	_, err = adapter.GetRefreshToken(ctx, "missing")
+	assertTyped(t, err, auth.ErrInvalidRefreshToken)
+
+	// Two sessions of one family, and one of another
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	var sessions []*auth.Session
+	for i, family := range []string{"family-1", "family-1", "family-2"} {
+		session, err := adapter.CreateSession(ctx, user.ID, expiresAt, auth.SessionMetadata{})
+		require.NoError(t, err)
+		sessions = append(sessions, session)
+		token := auth.RefreshToken{FamilyID: family, SessionID: session.ID, UserID: user.ID, ExpiresAt: expiresAt}
+		require.NoError(t, adapter.CreateRefreshToken(ctx, fmt.Sprintf("hash-%d", i), token))
+	}
+
+	token, err := adapter.GetRefreshToken(ctx, "hash-0")
+	require.NoError(t, err)
+	assert.Equal(t, auth.RefreshToken{FamilyID: "family-1", SessionID: sessions[0].ID, UserID: user.ID, ExpiresAt: token.ExpiresAt}, *token)
+	assert.True(t, expiresAt.Equal(token.ExpiresAt))
+
+	marked, err := adapter.MarkRefreshTokenUsed(ctx, "hash-0")
+	require.NoError(t, err)
+	assert.True(t, marked)
+	marked, err = adapter.MarkRefreshTokenUsed(ctx, "hash-0")
+	require.NoError(t, err)
+	assert.False(t, marked, "a token is exchanged only once")
+	token, err = adapter.GetRefreshToken(ctx, "hash-0")
+	require.NoError(t, err)
+	assert.True(t, token.Used)
+
+	require.NoError(t, adapter.RevokeRefreshTokenFamily(ctx, "family-1"))
+	for _, hash := range []string{"hash-0", "hash-1"} {
+		_, err = adapter.GetRefreshToken(ctx, hash)
+		assertTyped(t, err, auth.ErrInvalidRefreshToken)
+	}
+	for _, session := range sessions[:2] {
+		_, err = adapter.GetSession(ctx, session.ID)
+		assertTyped(t, err, auth.ErrSessionNotFound)
+	}
+	_, err = adapter.GetSession(ctx, sessions[2].ID)
+	assert.NoError(t, err, "other families are kept")
+	_, err = adapter.GetRefreshToken(ctx, "hash-2")
+	assert.NoError(t, err)
+}
+
+func TestSessionAdapter_NotFound(t *testing.T) {
+	adapter := NewSessionAdapter(setupTestDB(t))
+	ctx := context.Background()
+	unknownUUID := "6f1c2b9e-3a4d-4e5f-8a7b-9c0d1e2f3a4b"
+
+	_, err := adapter.GetSession(ctx, "missing")
+	assertTyped(t, err, auth.ErrSessionNotFound)
+	_, err = adapter.CreateSession(ctx, unknownUUID, time.Now().Add(time.Hour), auth.SessionMetadata{})
+	assertTyped(t, err, auth.ErrUserNotFound)
+	_, err = adapter.ListUserSessions(ctx, unknownUUID)
+	assertTyped(t, err, auth.ErrUserNotFound)
+	err = adapter.DeleteUserSessions(ctx, unknownUUID)
+	assertTyped(t, err, auth.ErrUserNotFound)
+}
+
+func TestSessionAdapter_TokenBytes(t *testing.T) {
+	db := setupTestDB(t)
+	adapter := NewSessionAdapter(db, WithTokenBytes(auth.MinTokenBytes))
+	require.NoError(t, db.Create(&models.User{Username: "alice", Email: "alice@example.com", PasswordHash: "hash", Active: true}).Error)
+
+	session, err := adapter.CreateSession(context.Background(), "1", time.Now().Add(time.Hour), auth.SessionMetadata{})
+	require.NoError(t, err)
+	decoded, err := base64.RawURLEncoding.DecodeString(session.ID)
+	require.NoError(t, err)
+	assert.Len(t, decoded, auth.MinTokenBytes)
+}
+
+func TestSessionAdapter_DeleteExpiredSessions(t *testing.T) {
+	db := setupTestDB(t)
+	adapter := NewSessionAdapter(db)
+	ctx := context.Background()
+
+	require.NoError(t, db.Create(&models.User{Username: "alice", Email: "alice@example.com", PasswordHash: "hash", Active: true}).Error)
+	for i := range 5 {
+		require.NoError(t, db.Create(&models.Session{ID: fmt.Sprintf("expired-%d", i), UserID: 1, ExpiresAt: time.Now().Add(-time.Hour)}).Error)
+	}
+	require.NoError(t, db.Create(&models.Session{ID: "valid", UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}).Error)
+
+	for _, want := range []int64{2, 2, 1, 0} {
+		deleted, err := adapter.DeleteExpiredSessions(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, want, deleted)
+	}
+
+	var remaining []string
+	require.NoError(t, db.Model(&models.Session{}).Pluck("id", &remaining).Error)
+	assert.Equal(t, []string{"valid"}, remaining)
+}
+
+func TestSessionAdapter_ListAll(t *testing.T) {
+	db := setupTestDB(t)
+	adapter := NewSessionAdapter(db)
+	ctx := context.Background()
+
+	for _, username := range []string{"alice", "bob"} {
+		require.NoError(t, db.Create(&models.User{Username: username, Email: username + "@example.com", PasswordHash: "hash", Active: true}).Error)
+	}
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, session := range []models.Session{
+		{ID: "alice-old", UserID: 1, IP: "10.0.0.1", CreatedAt: base, ExpiresAt: base.Add(48 * time.Hour)},
+		{ID: "alice-new", UserID: 1, IP: "10.0.0.2", CreatedAt: base.Add(24 * time.Hour), ExpiresAt: base.Add(72 * time.Hour)},
+		{ID: "bob", UserID: 2, IP: "10.0.0.1", CreatedAt: base.Add(12 * time.Hour), ExpiresAt: base.Add(72 * time.Hour)},
+		{ID: "bob-expired", UserID: 2, IP: "10.0.0.1", CreatedAt: base.Add(-48 * time.Hour), ExpiresAt: base.Add(-24 * time.Hour)},
+	} {
+		require.NoError(t, db.Create(&session).Error)
+	}
+
+	ids := func(sessions []*auth.Session) []string {
+		result := make([]string, len(sessions))
+		for i, session := range sessions {
+			result[i] = session.ID
+		}
+		return result
+	}
+
+	tests := []struct {
+		name   string
+		filter auth.SessionFilter
+		want   []string
+	}{
+		{"all active", auth.SessionFilter{ActiveAt: base}, []string{"alice-new", "bob", "alice-old"}},
+		{"by user", auth.SessionFilter{UserID: "1", ActiveAt: base}, []string{"alice-new", "alice-old"}},
+		{"by ip", auth.SessionFilter{IP: "10.0.0.1"}, []string{"bob", "alice-old", "bob-expired"}},
+		{"created range", auth.SessionFilter{CreatedAfter: base, CreatedBefore: base.Add(24 * time.Hour)}, []string{"bob", "alice-old"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions, total, err := adapter.ListAll(ctx, tt.filter, 0, 10)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids(sessions))
+			assert.Equal(t, int64(len(tt.want)), total)
+		})
+	}
+
+	// Pages count every match
+	sessions, total, err := adapter.ListAll(ctx, auth.SessionFilter{ActiveAt: base}, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, ids(sessions))
+	assert.Equal(t, int64(3), total)
+
+	_, _, err = adapter.ListAll(ctx, auth.SessionFilter{UserID: "6f1c2b9e-3a4d-4e5f-8a7b-9c0d1e2f3a4b"}, 0, 10)
+	assertTyped(t, err, auth.ErrUserNotFound)
+}
+
+func TestUserAdapter_LoginAttempts(t *testing.T) {
+	adapter := NewUserAdapter(setupTestDB(t))
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	attempts, err := adapter.GetLoginAttempts(ctx, auth.LoginAttemptsAccount, "testuser")
+	require.NoError(t, err)
+	assert.Zero(t, attempts)
+
+	counting := auth.LoginAttempts{Failures: 1, LastFailureAt: now, ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, adapter.SaveLoginAttempts(ctx, auth.LoginAttemptsAccount, "testuser", counting))
+	locked := auth.LoginAttempts{Failures: 5, LastFailureAt: now, LockedAt: now, ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, adapter.SaveLoginAttempts(ctx, auth.LoginAttemptsIP, "192.0.2.1", locked))
+
+	// Saving again replaces the counter
+	counting.Failures = 2
+	require.NoError(t, adapter.SaveLoginAttempts(ctx, auth.LoginAttemptsAccount, "testuser", counting))
+	attempts, err = adapter.GetLoginAttempts(ctx, auth.LoginAttemptsAccount, "testuser")
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts.Failures)
+	assert.True(t, attempts.LockedAt.IsZero())
+	assert.True(t, now.Equal(attempts.LastFailureAt))
+
+	lockouts, err := adapter.ListLoginLockouts(ctx, now.Add(-time.Minute))
+	require.NoError(t, err)
+	require.Len(t, lockouts, 1)
+	assert.Equal(t, auth.LoginAttemptsIP, lockouts[0].Kind)
+	assert.Equal(t, "192.0.2.1", lockouts[0].Key)
+	assert.Equal(t, 5, lockouts[0].Failures)
+	assert.True(t, now.Equal(lockouts[0].LockedAt))
+
+	lockouts, err = adapter.ListLoginLockouts(ctx, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, lockouts)
+
+	require.NoError(t, adapter.DeleteLoginAttempts(ctx, auth.LoginAttemptsIP, "192.0.2.1"))
+	require.NoError(t, adapter.DeleteLoginAttempts(ctx, auth.LoginAttemptsIP, "192.0.2.1"))
+	attempts, err = adapter.GetLoginAttempts(ctx, auth.LoginAttemptsIP, "192.0.2.1")
+	require.NoError(t, err)
+	assert.Zero(t, attempts)
+}
+
+// assertTyped checks that err is target and doesn't leak GORM's error
+func assertTyped(t *testing.T, err, target error) {
+	t.Helper()
+	assert.ErrorIs(t, err, target)
+	assert.NotErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestLoadPolicy(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.Role{}, &models.RolePermission{}))
+	require.NoError(t, db.Create(&[]models.Role{
+		{Name: "support", Description: "Suporte", Permissions: []models.RolePermission{{Permission: "users:read"}}},
+		{Name: "admin", Permissions: []models.RolePermission{{Permission: "users:write"}, {Permission: "users:read"}}},
+	}).Error)
+
+	policy, err := LoadPolicy(context.Background(), db)
+	require.NoError(t, err)
+	assert.True(t, policy.Allows("support", "users:read"))
+	assert.False(t, policy.Allows("support", "users:read", "users:write"))
+	assert.True(t, policy.Allows("admin", "users:read", "users:write"))
+	assert.Equal(t, []auth.Role{
+		{Name: "admin", Permissions: []string{"users:read", "users:write"}},
+		{Name: "support", Description: "Suporte", Permissions: []string{"users:read"}},
+	}, policy.Roles())
+}
+
+func TestUserAdapter_UpgradesPasswordHash(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	bcryptHash, err := auth.NewBcryptHasher(bcrypt.MinCost).Hash("password123")
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&models.User{Username: "alice", Email: "alice@example.com", PasswordHash: bcryptHash, Active: true}).Error)
+
+	// After switching to argon2id, the bcrypt hash still logs in and is upgraded
+	adapter := NewUserAdapter(db, WithPasswordHasher(auth.NewArgon2idHasher(auth.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1})))
+	_, err = adapter.ValidateCredentials(ctx, "alice", "password123")
+	require.NoError(t, err)
+
+	var user models.User
+	require.NoError(t, db.First(&user, "username = ?", "alice").Error)
+	assert.True(t, strings.HasPrefix(user.PasswordHash, "$argon2id$"), user.PasswordHash)
+	_, err = adapter.ValidateCredentials(ctx, "alice", "password123")
+	assert.NoError(t, err)
+
+	// A failed login leaves the hash alone
+	_, err = adapter.ValidateCredentials(ctx, "alice", "wrong-password")
+	assertTyped(t, err, auth.ErrInvalidCredentials)
+	var after models.User
+	require.NoError(t, db.First(&after, user.ID).Error)
+	assert.Equal(t, user.PasswordHash, after.PasswordHash)
+}
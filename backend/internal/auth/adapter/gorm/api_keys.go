@@ -0,0 +1,93 @@
+package gorm
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+)
+
+// CreateAPIKey stores key for its UserID
+func (a *UserAdapter) CreateAPIKey(ctx context.Context, key auth.APIKey, hashedSecret string) error {
+	uid, err := resolveUserID(a.conn(ctx), key.UserID)
+	if err != nil {
+		return err
+	}
+
+	model := models.APIKey{
+		ID:         key.ID,
+		UserID:     uid,
+		Name:       key.Name,
+		SecretHash: hashedSecret,
+		Scopes:     strings.Join(key.Scopes, " "),
+		ExpiresAt:  key.ExpiresAt,
+		CreatedAt:  key.CreatedAt,
+	}
+	return a.conn(ctx).Create(&model).Error
+}
+
+// GetAPIKey returns a key with the hash of its secret
+func (a *UserAdapter) GetAPIKey(ctx context.Context, id string) (*auth.APIKey, string, error) {
+	var key models.APIKey
+	if err := a.conn(ctx).Where("id = ?", id).First(&key).Error; err != nil {
+		return nil, "", notFound(err, auth.ErrAPIKeyNotFound)
+	}
+	return toAPIKey(&key), key.SecretHash, nil
+}
+
+// ListAPIKeys returns the keys of the user, newest first
+func (a *UserAdapter) ListAPIKeys(ctx context.Context, userID string) ([]*auth.APIKey, error) {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []models.APIKey
+	if err := a.conn(ctx).Where("user_id = ?", uid).Order("created_at DESC, id").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*auth.APIKey, len(keys))
+	for i := range keys {
+		result[i] = toAPIKey(&keys[i])
+	}
+	return result, nil
+}
+
+// DeleteAPIKey removes a key of the user
+func (a *UserAdapter) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return err
+	}
+
+	result := a.conn(ctx).Where("id = ? AND user_id = ?", id, uid).Delete(&models.APIKey{})
+	if result.Error != nil {
+		logger.Error("Erro ao remover chave de API", "error", result.Error, "user_id", userID, "api_key_id", id)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// UpdateAPIKeyLastUsed records a use of the key
+func (a *UserAdapter) UpdateAPIKeyLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error {
+	return a.conn(ctx).Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", lastUsedAt).Error
+}
+
+func toAPIKey(key *models.APIKey) *auth.APIKey {
+	return &auth.APIKey{
+		ID:         key.ID,
+		UserID:     strconv.FormatUint(uint64(key.UserID), 10),
+		Name:       key.Name,
+		Scopes:     strings.Fields(key.Scopes),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
@@ -0,0 +1,26 @@
+package gorm
+
+import (
+	"errors"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// notFound translates gorm.ErrRecordNotFound into target, so callers of the
+// adapters never see GORM's error. Other errors are returned unchanged.
+func notFound(err, target error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return target
+	}
+	return err
+}
+
+// resolveUserID is models.ResolveUserID returning auth.ErrUserNotFound for
+// unknown UUIDs
+func resolveUserID(db *gorm.DB, id string) (uint, error) {
+	uid, err := models.ResolveUserID(db, id)
+	return uid, notFound(err, auth.ErrUserNotFound)
+}
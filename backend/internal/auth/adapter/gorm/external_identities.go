@@ -0,0 +1,55 @@
+package gorm
+
+import (
+	"context"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/tenant"
+)
+
+// FindUserByExternalIdentity finds the user linked to subject at provider,
+// within the tenant of ctx
+func (a *UserAdapter) FindUserByExternalIdentity(ctx context.Context, provider, subject string) (*auth.UserData, error) {
+	db := a.conn(ctx)
+
+	var identity models.ExternalIdentity
+	if err := db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, notFound(err, auth.ErrUserNotFound)
+	}
+	var user models.User
+	if err := db.Scopes(tenant.Scope(ctx)).First(&user, identity.UserID).Error; err != nil {
+		return nil, notFound(err, auth.ErrUserNotFound)
+	}
+	return a.toUserData(&user), nil
+}
+
+// ListExternalIdentities returns the provider accounts linked to the user,
+// oldest first
+func (a *UserAdapter) ListExternalIdentities(ctx context.Context, userID string) ([]models.ExternalIdentity, error) {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var identities []models.ExternalIdentity
+	if err := a.conn(ctx).Where("user_id = ?", uid).Order("created_at, id").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// LinkExternalIdentity links subject at provider to the user. A subject can
+// only be linked to one user; linking it again fails on the unique index.
+func (a *UserAdapter) LinkExternalIdentity(ctx context.Context, userID, provider, subject, email string) error {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return err
+	}
+	return a.conn(ctx).Create(&models.ExternalIdentity{
+		UserID:   uid,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	}).Error
+}
@@ -0,0 +1,77 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Counters are written outside any request transaction: a failed login must
+// still count when the request is rolled back
+
+// GetLoginAttempts returns a failed login counter, zero if there is none
+func (a *UserAdapter) GetLoginAttempts(ctx context.Context, kind, key string) (auth.LoginAttempts, error) {
+	var row models.LoginAttempt
+	err := a.db.WithContext(ctx).Where("kind = ? AND key = ?", kind, key).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return auth.LoginAttempts{}, nil
+	}
+	if err != nil {
+		return auth.LoginAttempts{}, err
+	}
+
+	attempts := auth.LoginAttempts{
+		Failures:      row.Failures,
+		LastFailureAt: row.LastFailureAt,
+		ExpiresAt:     row.ExpiresAt,
+	}
+	if row.LockedAt != nil {
+		attempts.LockedAt = *row.LockedAt
+	}
+	return attempts, nil
+}
+
+// SaveLoginAttempts creates or replaces a failed login counter
+func (a *UserAdapter) SaveLoginAttempts(ctx context.Context, kind, key string, attempts auth.LoginAttempts) error {
+	row := models.LoginAttempt{
+		Kind:          kind,
+		Key:           key,
+		Failures:      attempts.Failures,
+		LastFailureAt: attempts.LastFailureAt,
+		ExpiresAt:     attempts.ExpiresAt,
+	}
+	if !attempts.LockedAt.IsZero() {
+		row.LockedAt = &attempts.LockedAt
+	}
+	return a.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kind"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"failures", "last_failure_at", "locked_at", "expires_at"}),
+	}).Create(&row).Error
+}
+
+// DeleteLoginAttempts removes a failed login counter
+func (a *UserAdapter) DeleteLoginAttempts(ctx context.Context, kind, key string) error {
+	return a.db.WithContext(ctx).Where("kind = ? AND key = ?", kind, key).Delete(&models.LoginAttempt{}).Error
+}
+
+// ListLoginLockouts returns the counters locked at or after since, most
+// recent first
+func (a *UserAdapter) ListLoginLockouts(ctx context.Context, since time.Time) ([]auth.Lockout, error) {
+	var rows []models.LoginAttempt
+	err := a.db.WithContext(ctx).Where("locked_at >= ?", since).Order("locked_at DESC").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	lockouts := make([]auth.Lockout, len(rows))
+	for i, row := range rows {
+		lockouts[i] = auth.Lockout{Kind: row.Kind, Key: row.Key, Failures: row.Failures, LockedAt: *row.LockedAt}
+	}
+	return lockouts, nil
+}
@@ -0,0 +1,85 @@
+package gorm
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// CreateMagicLink stores a hashed magic link token. Pending links of the user
+// are expired in the same transaction, but kept so they still count towards
+// the sending rate limit.
+func (a *UserAdapter) CreateMagicLink(ctx context.Context, hashedToken string, link auth.MagicLink) error {
+	uid, err := resolveUserID(a.conn(ctx), link.UserID)
+	if err != nil {
+		return err
+	}
+
+	return a.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		now := a.clock.Now()
+		if err := tx.Model(&models.MagicLink{}).
+			Where("user_id = ? AND used_at IS NULL AND expires_at > ?", uid, now).
+			Update("expires_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.MagicLink{
+			ID:        hashedToken,
+			UserID:    uid,
+			Email:     link.Email,
+			ExpiresAt: link.ExpiresAt,
+			CreatedAt: now,
+		}).Error
+	})
+}
+
+// MagicLinksSentSince returns the creation times of the user's magic links
+// issued after since, newest first
+func (a *UserAdapter) MagicLinksSentSince(ctx context.Context, userID string, since time.Time) ([]time.Time, error) {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sent []time.Time
+	if err := a.conn(ctx).Model(&models.MagicLink{}).
+		Where("user_id = ? AND created_at > ?", uid, since).
+		Order("created_at DESC").
+		Pluck("created_at", &sent).Error; err != nil {
+		return nil, err
+	}
+	return sent, nil
+}
+
+// ConsumeMagicLink marks a pending link as used with a conditional update, so
+// concurrent use of the same link succeeds only once
+func (a *UserAdapter) ConsumeMagicLink(ctx context.Context, hashedToken string) (*auth.MagicLink, error) {
+	db := a.conn(ctx)
+	now := a.clock.Now()
+
+	result := db.Model(&models.MagicLink{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", hashedToken, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		logger.Error("Erro ao marcar link de acesso como usado", "error", result.Error)
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, auth.ErrInvalidMagicLink
+	}
+
+	var link models.MagicLink
+	if err := db.Where("id = ?", hashedToken).First(&link).Error; err != nil {
+		return nil, notFound(err, auth.ErrInvalidMagicLink)
+	}
+	return &auth.MagicLink{
+		UserID:    strconv.FormatUint(uint64(link.UserID), 10),
+		Email:     link.Email,
+		ExpiresAt: link.ExpiresAt,
+	}, nil
+}
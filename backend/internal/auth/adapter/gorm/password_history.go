@@ -0,0 +1,94 @@
+package gorm
+
+import (
+	"context"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// IsRecentPassword reports whether password matches the user's current
+// password or one of the previous depth-1 ones
+func (a *UserAdapter) IsRecentPassword(ctx context.Context, userID string, password string, depth int) (bool, error) {
+	if depth <= 0 {
+		return false, nil
+	}
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return false, err
+	}
+
+	db := a.conn(ctx)
+
+	var user models.User
+	if err := db.Select("password_hash").First(&user, uid).Error; err != nil {
+		return false, notFound(err, auth.ErrUserNotFound)
+	}
+	hashes := []string{user.PasswordHash}
+
+	if depth > 1 {
+		var history []models.PasswordHistory
+		if err := db.Where("user_id = ?", uid).Order("created_at DESC, id DESC").Limit(depth - 1).Find(&history).Error; err != nil {
+			return false, err
+		}
+		for _, entry := range history {
+			hashes = append(hashes, entry.PasswordHash)
+		}
+	}
+
+	for _, hash := range hashes {
+		if a.hasher.Verify(hash, password) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// UpdatePasswordWithHistory changes the password, moving the current hash into
+// the history and pruning entries beyond keep, in a single transaction
+func (a *UserAdapter) UpdatePasswordWithHistory(ctx context.Context, userID string, newPassword string, keep int) error {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := a.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	return a.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Select("password_hash").First(&user, uid).Error; err != nil {
+			return notFound(err, auth.ErrUserNotFound)
+		}
+
+		if keep > 0 {
+			if err := tx.Create(&models.PasswordHistory{UserID: uint(uid), PasswordHash: user.PasswordHash}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", uid).Updates(map[string]any{
+			"password_hash":        hashedPassword,
+			"password_version":     gorm.Expr("password_version + 1"),
+			"must_change_password": false,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Keep only the newest entries
+		prune := tx.Where("user_id = ?", uid)
+		if keep > 0 {
+			var keepIDs []uint
+			if err := tx.Model(&models.PasswordHistory{}).Where("user_id = ?", uid).
+				Order("created_at DESC, id DESC").Limit(keep).Pluck("id", &keepIDs).Error; err != nil {
+				return err
+			}
+			prune = prune.Where("id NOT IN ?", keepIDs)
+		}
+		return prune.Delete(&models.PasswordHistory{}).Error
+	})
+}
@@ -0,0 +1,68 @@
+package gorm
+
+import (
+	"context"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ReplaceRecoveryCodes stores a new set of hashed recovery codes for the user,
+// deleting the previous set in the same transaction
+func (a *UserAdapter) ReplaceRecoveryCodes(ctx context.Context, userID string, hashedCodes []string) error {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return err
+	}
+
+	return a.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", uid).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]models.RecoveryCode, len(hashedCodes))
+		for i, hash := range hashedCodes {
+			codes[i] = models.RecoveryCode{UserID: uint(uid), CodeHash: hash}
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// ConsumeRecoveryCode marks an unused recovery code as used.
+// The conditional update makes concurrent use of the same code succeed only once.
+func (a *UserAdapter) ConsumeRecoveryCode(ctx context.Context, userID string, hashedCode string) (bool, error) {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return false, nil
+	}
+
+	result := a.conn(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", uid, hashedCode).
+		Update("used_at", a.clock.Now())
+	if result.Error != nil {
+		logger.Error("Erro ao marcar código de recuperação como usado", "error", result.Error, "user_id", userID)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes of the user
+func (a *UserAdapter) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err := a.conn(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", uid).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
@@ -0,0 +1,67 @@
+package gorm
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// CreateRefreshToken stores a refresh token
+func (a *SessionAdapter) CreateRefreshToken(ctx context.Context, hashedToken string, token auth.RefreshToken) error {
+	uid, err := resolveUserID(a.conn(ctx), token.UserID)
+	if err != nil {
+		return err
+	}
+
+	return a.conn(ctx).Create(&models.RefreshToken{
+		ID:        hashedToken,
+		FamilyID:  token.FamilyID,
+		SessionID: token.SessionID,
+		UserID:    uid,
+		ExpiresAt: token.ExpiresAt,
+	}).Error
+}
+
+// GetRefreshToken returns a refresh token by hash
+func (a *SessionAdapter) GetRefreshToken(ctx context.Context, hashedToken string) (*auth.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := a.conn(ctx).Where("id = ?", hashedToken).First(&token).Error; err != nil {
+		return nil, notFound(err, auth.ErrInvalidRefreshToken)
+	}
+	return &auth.RefreshToken{
+		FamilyID:  token.FamilyID,
+		SessionID: token.SessionID,
+		UserID:    strconv.FormatUint(uint64(token.UserID), 10),
+		ExpiresAt: token.ExpiresAt,
+		Used:      token.UsedAt != nil,
+	}, nil
+}
+
+// MarkRefreshTokenUsed marks an unused refresh token as used, reporting
+// whether this call did it
+func (a *SessionAdapter) MarkRefreshTokenUsed(ctx context.Context, hashedToken string) (bool, error) {
+	result := a.conn(ctx).Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", hashedToken).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RevokeRefreshTokenFamily deletes the refresh tokens of a family and every
+// session issued with them
+func (a *SessionAdapter) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	return a.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		sessions := tx.Model(&models.RefreshToken{}).Select("session_id").Where("family_id = ?", familyID)
+		if err := tx.Where("id IN (?)", sessions).Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("family_id = ?", familyID).Delete(&models.RefreshToken{}).Error
+	})
+}
@@ -0,0 +1,29 @@
+package gorm
+
+import (
+	"context"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// LoadPolicy builds the permission policy from the roles stored by
+// seed.EnsureRoles
+func LoadPolicy(ctx context.Context, db *gorm.DB) (*auth.Policy, error) {
+	var stored []models.Role
+	if err := db.WithContext(ctx).Preload("Permissions").Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	roles := make([]auth.Role, len(stored))
+	for i, role := range stored {
+		permissions := make([]string, len(role.Permissions))
+		for j, rp := range role.Permissions {
+			permissions[j] = rp.Permission
+		}
+		roles[i] = auth.Role{Name: role.Name, Description: role.Description, Permissions: permissions}
+	}
+	return auth.NewPolicy(roles), nil
+}
@@ -1,52 +1,100 @@
 package gorm
 
 import (
+	"context"
+	"errors"
 	"strconv"
 	"time"
 
 	"gosveltekit/internal/auth"
+	"gosveltekit/internal/database"
 	"gosveltekit/internal/logger"
 	"gosveltekit/internal/models"
+	"gosveltekit/internal/pagination"
+	"gosveltekit/internal/query"
+	"gosveltekit/internal/tokens"
 
 	"gorm.io/gorm"
 )
 
 // SessionAdapter implements auth.SessionAdapter using GORM
 type SessionAdapter struct {
-	db *gorm.DB
+	db         *gorm.DB
+	tokens     tokens.Generator
+	tokenBytes int // random bytes of session IDs, 0 for auth.DefaultTokenBytes
+}
+
+// SessionAdapterOption configures a SessionAdapter
+type SessionAdapterOption func(*SessionAdapter)
+
+// WithTokenGenerator sets the random source of session IDs. Defaults to
+// tokens.Secure.
+func WithTokenGenerator(g tokens.Generator) SessionAdapterOption {
+	return func(a *SessionAdapter) {
+		a.tokens = g
+	}
+}
+
+// WithTokenBytes sets how many random bytes session IDs carry, see
+// auth.ValidateTokenBytes. Defaults to auth.DefaultTokenBytes.
+func WithTokenBytes(n int) SessionAdapterOption {
+	return func(a *SessionAdapter) {
+		a.tokenBytes = n
+	}
 }
 
 // NewSessionAdapter creates a new GORM-based session adapter
-func NewSessionAdapter(db *gorm.DB) *SessionAdapter {
-	return &SessionAdapter{db: db}
+func NewSessionAdapter(db *gorm.DB, opts ...SessionAdapterOption) *SessionAdapter {
+	a := &SessionAdapter{db: db, tokens: tokens.Secure{}}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// conn returns the request transaction of ctx, if any, or the database
+func (a *SessionAdapter) conn(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, a.db)
 }
 
 // CreateSession creates a new session for a user
-func (a *SessionAdapter) CreateSession(userID string, expiresAt time.Time, metadata auth.SessionMetadata) (*auth.Session, error) {
-	// Parse userID as uint for GORM model
-	uid, err := strconv.ParseUint(userID, 10, 64)
+func (a *SessionAdapter) CreateSession(ctx context.Context, userID string, expiresAt time.Time, metadata auth.SessionMetadata) (*auth.Session, error) {
+	// Resolve userID (integer or UUID) to the primary key
+	uid, err := resolveUserID(a.conn(ctx), userID)
 	if err != nil {
 		logger.Error("Erro ao parsear userID para criar sessão", "error", err, "user_id", userID)
 		return nil, err
 	}
 
 	// Generate session ID
-	sessionID, err := auth.GenerateSessionID()
+	sessionID, err := auth.NewSessionID(a.tokens, a.tokenBytes)
 	if err != nil {
 		logger.Error("Erro ao gerar ID de sessão", "error", err, "user_id", userID)
 		return nil, err
 	}
 
+	now := time.Now()
 	session := &models.Session{
-		ID:        sessionID,
-		UserID:    uint(uid),
-		ExpiresAt: expiresAt,
-		CreatedAt: time.Now(),
-		UserAgent: metadata.UserAgent,
-		IP:        metadata.IP,
+		ID:         sessionID,
+		UserID:     uint(uid),
+		ExpiresAt:  expiresAt,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		UserAgent:  metadata.UserAgent,
+		IP:         metadata.IP,
+	}
+	if metadata.ImpersonatorID != "" {
+		impersonatorID, err := resolveUserID(a.conn(ctx), metadata.ImpersonatorID)
+		if err != nil {
+			logger.Error("Erro ao parsear impersonatorID para criar sessão", "error", err, "user_id", userID)
+			return nil, err
+		}
+		id := uint(impersonatorID)
+		session.ImpersonatorID = &id
+		session.ImpersonatorSessionID = metadata.ImpersonatorSessionID
 	}
 
-	if err := a.db.Create(session).Error; err != nil {
+	if err := a.conn(ctx).Create(session).Error; err != nil {
 		logger.Error("Erro ao criar sessão no banco de dados", "error", err, "user_id", userID, "session_id", sessionID)
 		return nil, err
 	}
@@ -55,10 +103,10 @@ func (a *SessionAdapter) CreateSession(userID string, expiresAt time.Time, metad
 }
 
 // GetSession retrieves a session by ID
-func (a *SessionAdapter) GetSession(sessionID string) (*auth.Session, error) {
+func (a *SessionAdapter) GetSession(ctx context.Context, sessionID string) (*auth.Session, error) {
 	var session models.Session
-	if err := a.db.Where("id = ?", sessionID).First(&session).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	if err := a.conn(ctx).Where("id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, auth.ErrSessionNotFound
 		}
 		logger.Error("Erro ao buscar sessão no banco de dados", "error", err, "session_id", sessionID)
@@ -69,17 +117,26 @@ func (a *SessionAdapter) GetSession(sessionID string) (*auth.Session, error) {
 }
 
 // UpdateSessionExpiry updates the expiration time of a session
-func (a *SessionAdapter) UpdateSessionExpiry(sessionID string, expiresAt time.Time) error {
-	if err := a.db.Model(&models.Session{}).Where("id = ?", sessionID).Update("expires_at", expiresAt).Error; err != nil {
+func (a *SessionAdapter) UpdateSessionExpiry(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	if err := a.conn(ctx).Model(&models.Session{}).Where("id = ?", sessionID).Update("expires_at", expiresAt).Error; err != nil {
 		logger.Error("Erro ao atualizar expiração da sessão", "error", err, "session_id", sessionID)
 		return err
 	}
 	return nil
 }
 
+// UpdateSessionLastUsed records the last time a session was used
+func (a *SessionAdapter) UpdateSessionLastUsed(ctx context.Context, sessionID string, lastUsedAt time.Time) error {
+	if err := a.conn(ctx).Model(&models.Session{}).Where("id = ?", sessionID).Update("last_used_at", lastUsedAt).Error; err != nil {
+		logger.Error("Erro ao atualizar último uso da sessão", "error", err, "session_id", sessionID)
+		return err
+	}
+	return nil
+}
+
 // DeleteSession removes a session
-func (a *SessionAdapter) DeleteSession(sessionID string) error {
-	if err := a.db.Where("id = ?", sessionID).Delete(&models.Session{}).Error; err != nil {
+func (a *SessionAdapter) DeleteSession(ctx context.Context, sessionID string) error {
+	if err := a.conn(ctx).Where("id = ?", sessionID).Delete(&models.Session{}).Error; err != nil {
 		logger.Error("Erro ao deletar sessão", "error", err, "session_id", sessionID)
 		return err
 	}
@@ -87,31 +144,117 @@ func (a *SessionAdapter) DeleteSession(sessionID string) error {
 }
 
 // DeleteUserSessions removes all sessions for a user
-func (a *SessionAdapter) DeleteUserSessions(userID string) error {
-	uid, err := strconv.ParseUint(userID, 10, 64)
+func (a *SessionAdapter) DeleteUserSessions(ctx context.Context, userID string) error {
+	uid, err := resolveUserID(a.conn(ctx), userID)
 	if err != nil {
 		logger.Error("Erro ao parsear userID para deletar sessões", "error", err, "user_id", userID)
 		return err
 	}
-	if err := a.db.Where("user_id = ?", uid).Delete(&models.Session{}).Error; err != nil {
+	if err := a.conn(ctx).Where("user_id = ?", uid).Delete(&models.Session{}).Error; err != nil {
 		logger.Error("Erro ao deletar sessões do usuário", "error", err, "user_id", userID)
 		return err
 	}
 	return nil
 }
 
-// DeleteExpiredSessions cleans up expired sessions
-func (a *SessionAdapter) DeleteExpiredSessions() error {
-	return a.db.Where("expires_at < ?", time.Now()).Delete(&models.Session{}).Error
+// ListUserSessions returns all sessions of a user, newest first
+func (a *SessionAdapter) ListUserSessions(ctx context.Context, userID string) ([]*auth.Session, error) {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		logger.Error("Erro ao parsear userID para listar sessões", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	var sessions []models.Session
+	if err := a.conn(ctx).Where("user_id = ?", uid).Order("created_at DESC").Find(&sessions).Error; err != nil {
+		logger.Error("Erro ao listar sessões do usuário", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	result := make([]*auth.Session, len(sessions))
+	for i := range sessions {
+		result[i] = a.toAuthSession(&sessions[i])
+	}
+	return result, nil
+}
+
+// ListAll returns a page of the sessions of all users matching filter,
+// newest first, and the total number of matches. An unknown filter.UserID
+// returns auth.ErrUserNotFound.
+func (a *SessionAdapter) ListAll(ctx context.Context, filter auth.SessionFilter, offset, limit int) ([]*auth.Session, int64, error) {
+	db := a.conn(ctx).Model(&models.Session{})
+	if filter.UserID != "" {
+		uid, err := resolveUserID(a.conn(ctx), filter.UserID)
+		if err != nil {
+			return nil, 0, err
+		}
+		db = db.Where("user_id = ?", uid)
+	}
+	if filter.IP != "" {
+		db = db.Where("ip = ?", filter.IP)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		db = db.Where("created_at >= ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		db = db.Where("created_at < ?", filter.CreatedBefore)
+	}
+	if !filter.ActiveAt.IsZero() {
+		db = db.Where("expires_at > ?", filter.ActiveAt)
+	}
+
+	sessions, total, err := query.Paginate[models.Session](db, query.Options{
+		Offset: offset,
+		Limit:  limit,
+		Sort:   pagination.Sort{Column: "created_at", Desc: true},
+	})
+	if err != nil {
+		logger.Error("Erro ao listar sessões", "error", err)
+		return nil, 0, err
+	}
+
+	result := make([]*auth.Session, len(sessions))
+	for i := range sessions {
+		result[i] = a.toAuthSession(&sessions[i])
+	}
+	return result, total, nil
+}
+
+// CountActiveSessions returns how many sessions have not expired
+func (a *SessionAdapter) CountActiveSessions(ctx context.Context) (int64, error) {
+	var count int64
+	err := a.conn(ctx).Model(&models.Session{}).Where("expires_at > ?", time.Now()).Count(&count).Error
+	return count, err
+}
+
+// DeleteExpiredSessions removes up to limit expired sessions. The IDs are
+// selected first, as MySQL can't limit a subquery of the deleted table.
+func (a *SessionAdapter) DeleteExpiredSessions(ctx context.Context, limit int) (int64, error) {
+	var ids []string
+	err := a.conn(ctx).Model(&models.Session{}).
+		Where("expires_at < ?", time.Now()).
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	res := a.conn(ctx).Where("id IN ?", ids).Delete(&models.Session{})
+	return res.RowsAffected, res.Error
 }
 
 func (a *SessionAdapter) toAuthSession(session *models.Session) *auth.Session {
-	return &auth.Session{
-		ID:        session.ID,
-		UserID:    strconv.FormatUint(uint64(session.UserID), 10),
-		ExpiresAt: session.ExpiresAt,
-		CreatedAt: session.CreatedAt,
-		UserAgent: session.UserAgent,
-		IP:        session.IP,
+	result := &auth.Session{
+		ID:         session.ID,
+		UserID:     strconv.FormatUint(uint64(session.UserID), 10),
+		ExpiresAt:  session.ExpiresAt,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		UserAgent:  session.UserAgent,
+		IP:         session.IP,
+	}
+	if session.ImpersonatorID != nil {
+		result.ImpersonatorID = strconv.FormatUint(uint64(*session.ImpersonatorID), 10)
+		result.ImpersonatorSessionID = session.ImpersonatorSessionID
 	}
+	return result
 }
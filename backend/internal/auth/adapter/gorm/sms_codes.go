@@ -0,0 +1,101 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// CreateSMSCode stores a hashed SMS code. Pending codes of the same purpose are
+// expired in the same transaction, but kept so they still count towards the
+// sending rate limit.
+func (a *UserAdapter) CreateSMSCode(ctx context.Context, userID, purpose, hashedCode string, expiresAt time.Time) error {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return err
+	}
+
+	return a.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		now := a.clock.Now()
+		if err := tx.Model(&models.SMSCode{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", uid, purpose, now).
+			Update("expires_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.SMSCode{
+			UserID:    uint(uid),
+			Purpose:   purpose,
+			CodeHash:  hashedCode,
+			ExpiresAt: expiresAt,
+		}).Error
+	})
+}
+
+// SMSCodesSentSince returns the creation times of the user's SMS codes issued
+// after since, newest first
+func (a *UserAdapter) SMSCodesSentSince(ctx context.Context, userID string, since time.Time) ([]time.Time, error) {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sent []time.Time
+	if err := a.conn(ctx).Model(&models.SMSCode{}).
+		Where("user_id = ? AND created_at > ?", uid, since).
+		Order("created_at DESC").
+		Pluck("created_at", &sent).Error; err != nil {
+		return nil, err
+	}
+	return sent, nil
+}
+
+// ConsumeSMSCode checks hashedCode against the newest pending code of the user
+// for purpose. On a match the code is marked as used with a conditional update,
+// so concurrent use of the same code succeeds only once; otherwise its failed
+// attempts are incremented. maxAttempts <= 0 disables the attempt limit.
+func (a *UserAdapter) ConsumeSMSCode(ctx context.Context, userID, purpose, hashedCode string, maxAttempts int) (bool, error) {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return false, nil
+	}
+
+	db := a.conn(ctx)
+	now := a.clock.Now()
+
+	var code models.SMSCode
+	err = db.Where("user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", uid, purpose, now).
+		Order("created_at DESC, id DESC").
+		First(&code).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if maxAttempts > 0 && code.Attempts >= maxAttempts {
+		return false, nil
+	}
+
+	if code.CodeHash != hashedCode {
+		if err := db.Model(&models.SMSCode{}).Where("id = ?", code.ID).
+			Update("attempts", gorm.Expr("attempts + 1")).Error; err != nil {
+			logger.Error("Erro ao registrar tentativa de código SMS", "error", err, "user_id", userID)
+			return false, err
+		}
+		return false, nil
+	}
+
+	result := db.Model(&models.SMSCode{}).
+		Where("id = ? AND used_at IS NULL", code.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		logger.Error("Erro ao marcar código SMS como usado", "error", result.Error, "user_id", userID)
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,89 @@
+package gorm
+
+import (
+	"context"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// SaveTOTPSecret stores a new, unconfirmed TOTP secret for the user, deleting
+// the previous one in the same transaction
+func (a *UserAdapter) SaveTOTPSecret(ctx context.Context, userID, secret string) error {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return err
+	}
+
+	return a.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", uid).Delete(&models.TOTPSecret{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.TOTPSecret{UserID: uid, Secret: secret}).Error
+	})
+}
+
+// GetTOTPSecret returns the user's TOTP secret
+func (a *UserAdapter) GetTOTPSecret(ctx context.Context, userID string) (*auth.TOTPSecret, error) {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var secret models.TOTPSecret
+	if err := a.conn(ctx).Where("user_id = ?", uid).First(&secret).Error; err != nil {
+		return nil, notFound(err, auth.ErrTOTPNotEnrolled)
+	}
+	return &auth.TOTPSecret{
+		Secret:       secret.Secret,
+		Confirmed:    secret.ConfirmedAt != nil,
+		LastUsedStep: secret.LastUsedStep,
+	}, nil
+}
+
+// ConfirmTOTPSecret marks the user's TOTP secret as confirmed
+func (a *UserAdapter) ConfirmTOTPSecret(ctx context.Context, userID string) error {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return err
+	}
+
+	result := a.conn(ctx).Model(&models.TOTPSecret{}).
+		Where("user_id = ?", uid).
+		Update("confirmed_at", a.clock.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return auth.ErrTOTPNotEnrolled
+	}
+	return nil
+}
+
+// UseTOTPStep records step as the last used one. The conditional update makes
+// concurrent use of the same code succeed only once.
+func (a *UserAdapter) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return false, err
+	}
+
+	result := a.conn(ctx).Model(&models.TOTPSecret{}).
+		Where("user_id = ? AND last_used_step < ?", uid, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteTOTPSecret removes the user's TOTP secret, if any
+func (a *UserAdapter) DeleteTOTPSecret(ctx context.Context, userID string) error {
+	uid, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return err
+	}
+	return a.conn(ctx).Where("user_id = ?", uid).Delete(&models.TOTPSecret{}).Error
+}
@@ -0,0 +1,60 @@
+package gorm
+
+import (
+	"context"
+	"strconv"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// CreateTwoFactorChallenge stores a pending two-factor login
+func (a *SessionAdapter) CreateTwoFactorChallenge(ctx context.Context, hashedToken string, challenge auth.TwoFactorChallenge) error {
+	uid, err := resolveUserID(a.conn(ctx), challenge.UserID)
+	if err != nil {
+		return err
+	}
+
+	return a.conn(ctx).Create(&models.TwoFactorChallenge{
+		ID:        hashedToken,
+		UserID:    uid,
+		ExpiresAt: challenge.ExpiresAt,
+		UserAgent: challenge.Metadata.UserAgent,
+		IP:        challenge.Metadata.IP,
+	}).Error
+}
+
+// GetTwoFactorChallenge returns a pending two-factor login by token hash
+func (a *SessionAdapter) GetTwoFactorChallenge(ctx context.Context, hashedToken string) (*auth.TwoFactorChallenge, error) {
+	var challenge models.TwoFactorChallenge
+	if err := a.conn(ctx).Where("id = ?", hashedToken).First(&challenge).Error; err != nil {
+		return nil, notFound(err, auth.ErrInvalidTwoFactorChallenge)
+	}
+	return &auth.TwoFactorChallenge{
+		UserID:    strconv.FormatUint(uint64(challenge.UserID), 10),
+		ExpiresAt: challenge.ExpiresAt,
+		Attempts:  challenge.Attempts,
+		Metadata: auth.SessionMetadata{
+			UserAgent: challenge.UserAgent,
+			IP:        challenge.IP,
+		},
+	}, nil
+}
+
+// RecordTwoFactorAttempt increments the wrong codes of a challenge
+func (a *SessionAdapter) RecordTwoFactorAttempt(ctx context.Context, hashedToken string) error {
+	return a.conn(ctx).Model(&models.TwoFactorChallenge{}).
+		Where("id = ?", hashedToken).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+// DeleteTwoFactorChallenge removes a challenge, reporting whether it existed
+func (a *SessionAdapter) DeleteTwoFactorChallenge(ctx context.Context, hashedToken string) (bool, error) {
+	result := a.conn(ctx).Where("id = ?", hashedToken).Delete(&models.TwoFactorChallenge{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
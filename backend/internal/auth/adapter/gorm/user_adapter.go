@@ -1,13 +1,20 @@
-// Package gorm provides GORM-based implementations of auth adapters
+// Package gorm provides GORM-based implementations of auth adapters.
+//
+// Missing rows are reported with the auth package's errors (e.g.
+// auth.ErrUserNotFound, auth.ErrSessionNotFound), never gorm.ErrRecordNotFound,
+// so callers don't depend on the ORM.
 package gorm
 
 import (
-	"strconv"
+	"context"
+	"errors"
 	"time"
 
 	"gosveltekit/internal/auth"
+	"gosveltekit/internal/database"
 	"gosveltekit/internal/logger"
 	"gosveltekit/internal/models"
+	"gosveltekit/internal/tenant"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -15,20 +22,50 @@ import (
 
 // UserAdapter implements auth.UserAdapter using GORM
 type UserAdapter struct {
-	db *gorm.DB
+	db     *gorm.DB
+	hasher auth.PasswordHasher
+	clock  auth.Clock
+
+	// inTx is set on the adapters passed to Transaction callbacks, whose db
+	// is the transaction and takes precedence over the one of the request
+	inTx bool
+}
+
+// UserAdapterOption configures a UserAdapter
+type UserAdapterOption func(*UserAdapter)
+
+// WithPasswordHasher sets the hasher used for new passwords. Defaults to
+// bcrypt with bcrypt.DefaultCost.
+func WithPasswordHasher(hasher auth.PasswordHasher) UserAdapterOption {
+	return func(a *UserAdapter) {
+		a.hasher = hasher
+	}
+}
+
+// WithClock sets the time source for SMS code expiry and timestamps. Defaults
+// to auth.SystemClock; it should match AuthConfig.Clock.
+func WithClock(clock auth.Clock) UserAdapterOption {
+	return func(a *UserAdapter) {
+		a.clock = clock
+	}
 }
 
 // NewUserAdapter creates a new GORM-based user adapter
-func NewUserAdapter(db *gorm.DB) *UserAdapter {
-	return &UserAdapter{db: db}
+func NewUserAdapter(db *gorm.DB, opts ...UserAdapterOption) *UserAdapter {
+	a := &UserAdapter{db: db, hasher: auth.NewBcryptHasher(bcrypt.DefaultCost), clock: auth.SystemClock{}}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
-// FindUserByIdentifier looks up user by username or email
-func (a *UserAdapter) FindUserByIdentifier(identifier string) (*auth.UserData, error) {
+// FindUserByIdentifier looks up user by username or email, within the tenant
+// of ctx
+func (a *UserAdapter) FindUserByIdentifier(ctx context.Context, identifier string) (*auth.UserData, error) {
 	var user models.User
-	err := a.db.Where("username = ? OR email = ?", identifier, identifier).First(&user).Error
+	err := a.conn(ctx).Scopes(tenant.Scope(ctx)).Where("username = ? OR email = ?", identifier, identifier).First(&user).Error
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, auth.ErrInvalidCredentials
 		}
 		logger.Error("Erro ao buscar usuário por identificador", "error", err, "identifier", identifier)
@@ -38,16 +75,16 @@ func (a *UserAdapter) FindUserByIdentifier(identifier string) (*auth.UserData, e
 }
 
 // FindUserByID looks up user by ID
-func (a *UserAdapter) FindUserByID(id string) (*auth.UserData, error) {
-	userID, err := strconv.ParseUint(id, 10, 64)
+func (a *UserAdapter) FindUserByID(ctx context.Context, id string) (*auth.UserData, error) {
+	userID, err := resolveUserID(a.conn(ctx), id)
 	if err != nil {
 		logger.Debug("ID de usuário inválido", "user_id", id, "error", err)
 		return nil, auth.ErrInvalidCredentials
 	}
 
 	var user models.User
-	if err := a.db.First(&user, userID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	if err := a.conn(ctx).First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, auth.ErrInvalidCredentials
 		}
 		logger.Error("Erro ao buscar usuário por ID", "error", err, "user_id", id)
@@ -56,22 +93,37 @@ func (a *UserAdapter) FindUserByID(id string) (*auth.UserData, error) {
 	return a.toUserData(&user), nil
 }
 
-// ValidateCredentials validates username/email and password
-func (a *UserAdapter) ValidateCredentials(identifier, password string) (*auth.UserData, error) {
+// ValidateCredentials validates username/email and password, within the
+// tenant of ctx
+func (a *UserAdapter) ValidateCredentials(ctx context.Context, identifier, password string) (*auth.UserData, error) {
 	var user models.User
-	err := a.db.Where("username = ? OR email = ?", identifier, identifier).First(&user).Error
+	err := a.conn(ctx).Scopes(tenant.Scope(ctx)).Where("username = ? OR email = ?", identifier, identifier).First(&user).Error
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		return nil, auth.ErrInvalidCredentials
 	}
 
 	// Compare password hash
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+	if !a.hasher.Verify(user.PasswordHash, password) {
 		return nil, auth.ErrInvalidCredentials
 	}
 
+	// Upgrade hashes made with an older algorithm or cost parameters, while
+	// the plaintext password is at hand
+	if a.hasher.NeedsRehash(user.PasswordHash) {
+		if rehashed, err := a.hasher.Hash(password); err != nil {
+			logger.Error("Erro ao atualizar hash da senha", "error", err, "user_id", user.ID)
+		} else {
+			user.PasswordHash = rehashed
+			logger.Info("Hash da senha atualizado para os parâmetros atuais", "user_id", user.ID)
+		}
+	}
+
 	// Update last login time
-	user.LastLogin = time.Now()
-	if err := a.db.Save(&user).Error; err != nil {
+	user.LastLogin = a.clock.Now()
+	if err := a.conn(ctx).Save(&user).Error; err != nil {
 		logger.Error("Erro ao atualizar último login", "error", err, "user_id", user.ID)
 		// Não retornar erro, apenas logar
 	}
@@ -79,25 +131,27 @@ func (a *UserAdapter) ValidateCredentials(identifier, password string) (*auth.Us
 	return a.toUserData(&user), nil
 }
 
-// CreateUser creates a new user
-func (a *UserAdapter) CreateUser(data auth.CreateUserInput) (*auth.UserData, error) {
+// CreateUser creates a new user in the tenant of ctx
+func (a *UserAdapter) CreateUser(ctx context.Context, data auth.CreateUserInput) (*auth.UserData, error) {
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(data.Password), bcrypt.DefaultCost)
+	hashedPassword, err := a.hasher.Hash(data.Password)
 	if err != nil {
 		logger.Error("Erro ao gerar hash da senha", "error", err, "identifier", data.Identifier)
 		return nil, err
 	}
 
 	user := &models.User{
+		TenantID:     tenant.FromContext(ctx),
 		Username:     data.Identifier,
 		Email:        data.Email,
 		DisplayName:  data.DisplayName,
-		PasswordHash: string(hashedPassword),
+		Locale:       data.Locale,
+		PasswordHash: hashedPassword,
 		Active:       true,
 		Role:         "user",
 	}
 
-	if err := a.db.Create(user).Error; err != nil {
+	if err := a.conn(ctx).Create(user).Error; err != nil {
 		logger.Error("Erro ao criar usuário no banco de dados", "error", err, "identifier", data.Identifier, "email", data.Email)
 		return nil, err
 	}
@@ -106,65 +160,210 @@ func (a *UserAdapter) CreateUser(data auth.CreateUserInput) (*auth.UserData, err
 }
 
 // UpdatePassword updates the user's password
-func (a *UserAdapter) UpdatePassword(userID string, newPassword string) error {
-	id, err := strconv.ParseUint(userID, 10, 64)
+func (a *UserAdapter) UpdatePassword(ctx context.Context, userID string, newPassword string) error {
+	id, err := resolveUserID(a.conn(ctx), userID)
 	if err != nil {
 		return err
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	hashedPassword, err := a.hasher.Hash(newPassword)
 	if err != nil {
 		return err
 	}
 
-	return a.db.Model(&models.User{}).Where("id = ?", id).Update("password_hash", string(hashedPassword)).Error
+	return a.conn(ctx).Model(&models.User{}).Where("id = ?", id).Updates(map[string]any{
+		"password_hash":        hashedPassword,
+		"password_version":     gorm.Expr("password_version + 1"),
+		"must_change_password": false,
+	}).Error
+}
+
+// MaxPasswordBytes returns the longest password the hasher accepts, or 0 for
+// no limit
+func (a *UserAdapter) MaxPasswordBytes() int {
+	return auth.MaxPasswordBytes(a.hasher)
+}
+
+// VerifyPassword reports whether password is the user's current password
+func (a *UserAdapter) VerifyPassword(ctx context.Context, userID string, password string) (bool, error) {
+	user, err := a.GetUserModel(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return a.hasher.Verify(user.PasswordHash, password), nil
+}
+
+// FindByResetToken finds the user holding a hashed password reset token
+func (a *UserAdapter) FindByResetToken(ctx context.Context, hashedToken string) (*models.User, error) {
+	var user models.User
+	if err := a.conn(ctx).Where("reset_token = ? AND reset_token <> ''", hashedToken).First(&user).Error; err != nil {
+		return nil, notFound(err, auth.ErrUserNotFound)
+	}
+	return &user, nil
+}
+
+// ConsumeResetToken clears a hashed password reset token, reporting whether
+// it was still set: of concurrent calls with the same token only one wins
+func (a *UserAdapter) ConsumeResetToken(ctx context.Context, hashedToken string) (bool, error) {
+	result := a.conn(ctx).Model(&models.User{}).Where("reset_token = ? AND reset_token <> ''", hashedToken).Updates(map[string]any{
+		"reset_token":        "",
+		"reset_token_expiry": time.Time{},
+	})
+	return result.RowsAffected == 1, result.Error
+}
+
+// ClearResetToken clears the reset token after use
+func (a *UserAdapter) ClearResetToken(ctx context.Context, userID string) error {
+	id, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return err
+	}
+	return a.conn(ctx).Model(&models.User{}).Where("id = ?", id).Updates(map[string]any{
+		"reset_token":        "",
+		"reset_token_expiry": time.Time{},
+	}).Error
+}
+
+// ConsumePasswordVersion invalidates the reset links signed for the user's
+// passwordVersion by moving to the next version, and clears any stored reset
+// token. It reports false if the version already moved on. The UPDATE locks
+// the row until the transaction ends, so of concurrent resets with the same
+// signed link only the first gets true.
+func (a *UserAdapter) ConsumePasswordVersion(ctx context.Context, userID string, passwordVersion uint) (bool, error) {
+	id, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return false, err
+	}
+	result := a.conn(ctx).Model(&models.User{}).Where("id = ? AND password_version = ?", id, passwordVersion).Updates(map[string]any{
+		"password_version":   gorm.Expr("password_version + 1"),
+		"reset_token":        "",
+		"reset_token_expiry": time.Time{},
+	})
+	return result.RowsAffected == 1, result.Error
+}
+
+// ScheduleDeletion marks the account for deletion at deleteAt, storing the
+// hash of the restore token that cancels it
+func (a *UserAdapter) ScheduleDeletion(ctx context.Context, userID, hashedToken string, deleteAt time.Time) error {
+	id, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return err
+	}
+	return a.conn(ctx).Model(&models.User{}).Where("id = ?", id).Updates(map[string]any{
+		"scheduled_deletion_at": deleteAt,
+		"restore_token":         hashedToken,
+	}).Error
+}
+
+// FindByRestoreToken finds the user scheduled for deletion holding the
+// (hashed) restore token
+func (a *UserAdapter) FindByRestoreToken(ctx context.Context, hashedToken string) (*models.User, error) {
+	var user models.User
+	if err := a.conn(ctx).Where("restore_token = ? AND restore_token <> '' AND scheduled_deletion_at IS NOT NULL", hashedToken).First(&user).Error; err != nil {
+		return nil, notFound(err, auth.ErrUserNotFound)
+	}
+	return &user, nil
+}
+
+// CancelDeletion clears a scheduled deletion together with its restore token
+func (a *UserAdapter) CancelDeletion(ctx context.Context, userID string) error {
+	id, err := resolveUserID(a.conn(ctx), userID)
+	if err != nil {
+		return err
+	}
+	return a.conn(ctx).Model(&models.User{}).Where("id = ?", id).Updates(map[string]any{
+		"scheduled_deletion_at": nil,
+		"restore_token":         "",
+	}).Error
 }
 
 // GetUserModel returns the underlying GORM user model (for advanced queries)
-func (a *UserAdapter) GetUserModel(userID string) (*models.User, error) {
-	id, err := strconv.ParseUint(userID, 10, 64)
+func (a *UserAdapter) GetUserModel(ctx context.Context, userID string) (*models.User, error) {
+	id, err := resolveUserID(a.conn(ctx), userID)
 	if err != nil {
 		return nil, err
 	}
 
 	var user models.User
-	if err := a.db.First(&user, id).Error; err != nil {
-		return nil, err
+	if err := a.conn(ctx).First(&user, id).Error; err != nil {
+		return nil, notFound(err, auth.ErrUserNotFound)
 	}
 	return &user, nil
 }
 
-// FindByEmail finds user by email (for password reset)
-func (a *UserAdapter) FindByEmail(email string) (*models.User, error) {
+// FindByEmail finds user by email (for password reset), within the tenant of
+// ctx
+func (a *UserAdapter) FindByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	if err := a.db.Where("email = ?", email).First(&user).Error; err != nil {
-		return nil, err
+	if err := a.conn(ctx).Scopes(tenant.Scope(ctx)).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, notFound(err, auth.ErrUserNotFound)
 	}
 	return &user, nil
 }
 
 // UpdateUser saves changes to user model
-func (a *UserAdapter) UpdateUser(user *models.User) error {
-	if err := a.db.Save(user).Error; err != nil {
+func (a *UserAdapter) UpdateUser(ctx context.Context, user *models.User) error {
+	if err := a.conn(ctx).Save(user).Error; err != nil {
 		logger.Error("Erro ao atualizar usuário no banco de dados", "error", err, "user_id", user.ID)
 		return err
 	}
 	return nil
 }
 
+// Transaction runs fn inside a database transaction, passing an adapter bound
+// to it. The transaction is committed if fn returns nil and rolled back otherwise.
+func (a *UserAdapter) Transaction(ctx context.Context, fn func(tx *UserAdapter) error) error {
+	return a.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&UserAdapter{db: tx, hasher: a.hasher, clock: a.clock, inTx: true})
+	})
+}
+
+// conn returns the handle queries run on: the request transaction of ctx, if
+// any (see database.Conn), unless a is already bound to a transaction
+func (a *UserAdapter) conn(ctx context.Context) *gorm.DB {
+	if a.inTx {
+		return a.db.WithContext(ctx)
+	}
+	return database.Conn(ctx, a.db)
+}
+
+// DB returns the underlying database handle (the transaction, inside Transaction)
+func (a *UserAdapter) DB() *gorm.DB {
+	return a.db
+}
+
 func (a *UserAdapter) toUserData(user *models.User) *auth.UserData {
-	return &auth.UserData{
-		ID:          strconv.FormatUint(uint64(user.ID), 10),
+	data := &auth.UserData{
+		ID:          user.PublicID(),
 		Identifier:  user.Username,
 		Email:       user.Email,
 		DisplayName: user.DisplayName,
 		Role:        user.Role,
 		Active:      user.Active,
 		Attributes: map[string]any{
-			"first_name":     user.FirstName,
-			"last_name":      user.LastName,
-			"email_verified": user.EmailVerified,
-			"last_login":     user.LastLogin,
+			"first_name": user.FirstName,
+			"last_name":  user.LastName,
+			"last_login": user.LastLogin,
+			"updated_at": user.UpdatedAt,
+
+			auth.AttrEmailVerified:      user.EmailVerified,
+			auth.AttrMustChangePassword: user.MustChangePassword,
 		},
 	}
+	if user.TenantID != "" {
+		data.Attributes[auth.AttrTenantID] = user.TenantID
+	}
+	if user.Locale != "" {
+		data.Attributes[auth.AttrLocale] = user.Locale
+	}
+	if user.ScheduledDeletionAt != nil {
+		data.Attributes[auth.AttrDeletionScheduledAt] = *user.ScheduledDeletionAt
+	}
+	if user.AvatarKey != "" {
+		data.Attributes[auth.AttrAvatarKey] = user.AvatarKey
+	}
+	if user.TwoFactorMethod != "" {
+		data.Attributes[auth.AttrTwoFactorMethod] = user.TwoFactorMethod
+	}
+	return data
 }
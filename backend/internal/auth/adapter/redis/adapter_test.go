@@ -0,0 +1,219 @@
+// Package redis tests
+package redis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/auth"
+
+	"github.com/alicebob/miniredis/v2"
+	redisclient "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestAdapter(t *testing.T) (*SessionAdapter, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redisclient.NewClient(&redisclient.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewSessionAdapter(client), server
+}
+
+func TestSessionAdapter_Sessions(t *testing.T) {
+	adapter, _ := setupTestAdapter(t)
+	ctx := context.Background()
+
+	_, err := adapter.GetSession(ctx, "missing")
+	assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	metadata := auth.SessionMetadata{UserAgent: "test-agent", IP: "203.0.113.7", ImpersonatorID: "1", ImpersonatorSessionID: "admin-session"}
+	created, err := adapter.CreateSession(ctx, "42", expiresAt, metadata)
+	require.NoError(t, err)
+
+	session, err := adapter.GetSession(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "42", session.UserID)
+	assert.True(t, expiresAt.Equal(session.ExpiresAt))
+	assert.Equal(t, "test-agent", session.UserAgent)
+	assert.Equal(t, "203.0.113.7", session.IP)
+	assert.Equal(t, "1", session.ImpersonatorID)
+	assert.Equal(t, "admin-session", session.ImpersonatorSessionID)
+
+	newExpiresAt := expiresAt.Add(time.Hour)
+	lastUsedAt := time.Now().Add(time.Minute).Truncate(time.Second)
+	require.NoError(t, adapter.UpdateSessionExpiry(ctx, created.ID, newExpiresAt))
+	require.NoError(t, adapter.UpdateSessionLastUsed(ctx, created.ID, lastUsedAt))
+	session, err = adapter.GetSession(ctx, created.ID)
+	require.NoError(t, err)
+	assert.True(t, newExpiresAt.Equal(session.ExpiresAt))
+	assert.True(t, lastUsedAt.Equal(session.LastUsedAt))
+
+	require.NoError(t, adapter.DeleteSession(ctx, created.ID))
+	_, err = adapter.GetSession(ctx, created.ID)
+	assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+	assert.NoError(t, adapter.DeleteSession(ctx, created.ID), "deleting twice is not an error")
+
+	// Updates never bring a deleted session back
+	require.NoError(t, adapter.UpdateSessionLastUsed(ctx, created.ID, time.Now()))
+	_, err = adapter.GetSession(ctx, created.ID)
+	assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+}
+
+func TestSessionAdapter_Expiry(t *testing.T) {
+	adapter, server := setupTestAdapter(t)
+	ctx := context.Background()
+
+	created, err := adapter.CreateSession(ctx, "42", time.Now().Add(100*time.Millisecond), auth.SessionMetadata{})
+	require.NoError(t, err)
+	kept, err := adapter.CreateSession(ctx, "42", time.Now().Add(time.Hour), auth.SessionMetadata{})
+	require.NoError(t, err)
+
+	server.FastForward(150 * time.Millisecond)
+	_, err = adapter.GetSession(ctx, created.ID)
+	assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+
+	// Listing drops the expired session from the user's set
+	sessions, err := adapter.ListUserSessions(ctx, "42")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, kept.ID, sessions[0].ID)
+	members, err := adapter.userSessionIDs(ctx, "42")
+	require.NoError(t, err)
+	assert.Equal(t, []string{kept.ID}, members)
+
+	deleted, err := adapter.DeleteExpiredSessions(ctx, 100)
+	assert.NoError(t, err)
+	assert.Zero(t, deleted)
+	assert.Equal(t, []string{"auth:session:" + kept.ID, "auth:user_sessions:42"}, server.Keys())
+}
+
+func TestSessionAdapter_UserSessions(t *testing.T) {
+	adapter, _ := setupTestAdapter(t)
+	ctx := context.Background()
+
+	sessions, err := adapter.ListUserSessions(ctx, "42")
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	var ids []string
+	for range 3 {
+		session, err := adapter.CreateSession(ctx, "42", time.Now().Add(time.Hour), auth.SessionMetadata{})
+		require.NoError(t, err)
+		ids = append(ids, session.ID)
+		time.Sleep(time.Millisecond)
+	}
+	other, err := adapter.CreateSession(ctx, "7", time.Now().Add(time.Hour), auth.SessionMetadata{})
+	require.NoError(t, err)
+
+	sessions, err = adapter.ListUserSessions(ctx, "42")
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+	for i, session := range sessions {
+		assert.Equal(t, ids[2-i], session.ID, "newest first")
+	}
+
+	require.NoError(t, adapter.DeleteUserSessions(ctx, "42"))
+	for _, id := range ids {
+		_, err = adapter.GetSession(ctx, id)
+		assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+	}
+	_, err = adapter.GetSession(ctx, other.ID)
+	assert.NoError(t, err, "other users keep their sessions")
+}
+
+func TestSessionAdapter_TwoFactorChallenge(t *testing.T) {
+	adapter, _ := setupTestAdapter(t)
+	ctx := context.Background()
+
+	_, err := adapter.GetTwoFactorChallenge(ctx, "missing")
+	assert.ErrorIs(t, err, auth.ErrInvalidTwoFactorChallenge)
+	require.NoError(t, adapter.RecordTwoFactorAttempt(ctx, "missing"))
+
+	expiresAt := time.Now().Add(5 * time.Minute).Truncate(time.Second)
+	metadata := auth.SessionMetadata{UserAgent: "test-agent", IP: "203.0.113.7"}
+	require.NoError(t, adapter.CreateTwoFactorChallenge(ctx, "hash", auth.TwoFactorChallenge{UserID: "42", ExpiresAt: expiresAt, Metadata: metadata}))
+	require.NoError(t, adapter.RecordTwoFactorAttempt(ctx, "hash"))
+	require.NoError(t, adapter.RecordTwoFactorAttempt(ctx, "hash"))
+
+	challenge, err := adapter.GetTwoFactorChallenge(ctx, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, "42", challenge.UserID)
+	assert.True(t, expiresAt.Equal(challenge.ExpiresAt))
+	assert.Equal(t, 2, challenge.Attempts)
+	assert.Equal(t, metadata, challenge.Metadata)
+
+	deleted, err := adapter.DeleteTwoFactorChallenge(ctx, "hash")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = adapter.DeleteTwoFactorChallenge(ctx, "hash")
+	require.NoError(t, err)
+	assert.False(t, deleted, "a challenge is completed only once")
+}
+
+func TestSessionAdapter_RefreshTokens(t *testing.T) {
+	adapter, _ := setupTestAdapter(t)
+	ctx := context.Background()
+
+	_, err := adapter.GetRefreshToken(ctx, "missing")
+	assert.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+	marked, err := adapter.MarkRefreshTokenUsed(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, marked)
+
+	// Two sessions of one family, and one of another
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	var sessions []*auth.Session
+	for i, family := range []string{"family-1", "family-1", "family-2"} {
+		session, err := adapter.CreateSession(ctx, "42", expiresAt, auth.SessionMetadata{})
+		require.NoError(t, err)
+		sessions = append(sessions, session)
+		token := auth.RefreshToken{FamilyID: family, SessionID: session.ID, UserID: "42", ExpiresAt: expiresAt}
+		require.NoError(t, adapter.CreateRefreshToken(ctx, fmt.Sprintf("hash-%d", i), token))
+	}
+
+	token, err := adapter.GetRefreshToken(ctx, "hash-0")
+	require.NoError(t, err)
+	assert.Equal(t, auth.RefreshToken{FamilyID: "family-1", SessionID: sessions[0].ID, UserID: "42", ExpiresAt: token.ExpiresAt}, *token)
+	assert.True(t, expiresAt.Equal(token.ExpiresAt))
+
+	marked, err = adapter.MarkRefreshTokenUsed(ctx, "hash-0")
+	require.NoError(t, err)
+	assert.True(t, marked)
+	marked, err = adapter.MarkRefreshTokenUsed(ctx, "hash-0")
+	require.NoError(t, err)
+	assert.False(t, marked, "a token is exchanged only once")
+	token, err = adapter.GetRefreshToken(ctx, "hash-0")
+	require.NoError(t, err)
+	assert.True(t, token.Used)
+
+	require.NoError(t, adapter.RevokeRefreshTokenFamily(ctx, "family-1"))
+	for _, hash := range []string{"hash-0", "hash-1"} {
+		_, err = adapter.GetRefreshToken(ctx, hash)
+		assert.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+	}
+	for _, session := range sessions[:2] {
+		_, err = adapter.GetSession(ctx, session.ID)
+		assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+	}
+	_, err = adapter.GetSession(ctx, sessions[2].ID)
+	assert.NoError(t, err, "other families are kept")
+	_, err = adapter.GetRefreshToken(ctx, "hash-2")
+	assert.NoError(t, err)
+}
+
+func TestSessionAdapter_OptionalInterfaces(t *testing.T) {
+	adapter, _ := setupTestAdapter(t)
+
+	// The optional interfaces AuthManager looks for
+	var sessions auth.SessionAdapter = adapter
+	_, ok := sessions.(auth.SessionListAdapter)
+	assert.True(t, ok)
+	_, ok = sessions.(auth.TwoFactorChallengeAdapter)
+	assert.True(t, ok)
+	_, ok = sessions.(auth.RefreshTokenAdapter)
+	assert.True(t, ok)
+}
@@ -0,0 +1,134 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gosveltekit/internal/auth"
+
+	redisclient "github.com/redis/go-redis/v9"
+)
+
+// storedRefreshToken is the JSON value of a refresh token key. Being used is
+// a key of its own, set with NX so only one refresh wins.
+type storedRefreshToken struct {
+	FamilyID  string    `json:"family_id"`
+	SessionID string    `json:"session_id"`
+	UserID    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (a *SessionAdapter) refreshTokenKey(hashedToken string) string {
+	return a.prefix + "refresh:" + hashedToken
+}
+
+func (a *SessionAdapter) refreshTokenUsedKey(hashedToken string) string {
+	return a.prefix + "refresh_used:" + hashedToken
+}
+
+// refreshFamilyKey names the set of a family's token hashes. It expires with
+// the newest token, which is issued last.
+func (a *SessionAdapter) refreshFamilyKey(familyID string) string {
+	return a.prefix + "refresh_family:" + familyID
+}
+
+// CreateRefreshToken stores a refresh token
+func (a *SessionAdapter) CreateRefreshToken(ctx context.Context, hashedToken string, token auth.RefreshToken) error {
+	value, err := json.Marshal(storedRefreshToken{
+		FamilyID:  token.FamilyID,
+		SessionID: token.SessionID,
+		UserID:    token.UserID,
+		ExpiresAt: token.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	expiration := ttl(token.ExpiresAt)
+	_, err = a.client.Pipelined(ctx, func(pipe redisclient.Pipeliner) error {
+		pipe.Set(ctx, a.refreshTokenKey(hashedToken), value, expiration)
+		pipe.SAdd(ctx, a.refreshFamilyKey(token.FamilyID), hashedToken)
+		pipe.PExpire(ctx, a.refreshFamilyKey(token.FamilyID), expiration)
+		return nil
+	})
+	return err
+}
+
+// GetRefreshToken returns a refresh token by hash
+func (a *SessionAdapter) GetRefreshToken(ctx context.Context, hashedToken string) (*auth.RefreshToken, error) {
+	pipe := a.client.Pipeline()
+	get := pipe.Get(ctx, a.refreshTokenKey(hashedToken))
+	used := pipe.Get(ctx, a.refreshTokenUsedKey(hashedToken))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redisclient.Nil) {
+		return nil, err
+	}
+	stored, err := decodeRefreshToken(get.Result())
+	if err != nil {
+		return nil, err
+	}
+	if err := used.Err(); err != nil && !errors.Is(err, redisclient.Nil) {
+		return nil, err
+	}
+
+	return &auth.RefreshToken{
+		FamilyID:  stored.FamilyID,
+		SessionID: stored.SessionID,
+		UserID:    stored.UserID,
+		ExpiresAt: stored.ExpiresAt,
+		Used:      used.Err() == nil,
+	}, nil
+}
+
+// MarkRefreshTokenUsed marks an unused refresh token as used, reporting
+// whether this call did it
+func (a *SessionAdapter) MarkRefreshTokenUsed(ctx context.Context, hashedToken string) (bool, error) {
+	stored, err := decodeRefreshToken(a.client.Get(ctx, a.refreshTokenKey(hashedToken)).Result())
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Kept as long as the token, so reuse is detected until it expires
+	return a.client.SetNX(ctx, a.refreshTokenUsedKey(hashedToken), "1", ttl(stored.ExpiresAt)).Result()
+}
+
+// RevokeRefreshTokenFamily deletes the refresh tokens of a family and every
+// session issued with them
+func (a *SessionAdapter) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	members, err := a.client.SMembers(ctx, a.refreshFamilyKey(familyID)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{a.refreshFamilyKey(familyID)}
+	for _, hashedToken := range members {
+		stored, err := decodeRefreshToken(a.client.Get(ctx, a.refreshTokenKey(hashedToken)).Result())
+		switch {
+		case err == nil:
+			if err := a.DeleteSession(ctx, stored.SessionID); err != nil {
+				return err
+			}
+		case !errors.Is(err, auth.ErrInvalidRefreshToken):
+			return err
+		}
+		keys = append(keys, a.refreshTokenKey(hashedToken), a.refreshTokenUsedKey(hashedToken))
+	}
+	return a.client.Del(ctx, keys...).Err()
+}
+
+func decodeRefreshToken(value string, err error) (*storedRefreshToken, error) {
+	if errors.Is(err, redisclient.Nil) {
+		return nil, auth.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	var token storedRefreshToken
+	if err := json.Unmarshal([]byte(value), &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
@@ -0,0 +1,312 @@
+// Package redis stores sessions in Redis, expiring them with key TTLs
+// instead of database cleanups. Two-factor challenges and refresh tokens are
+// kept alongside, so every session feature keeps working.
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/tokens"
+
+	redisclient "github.com/redis/go-redis/v9"
+)
+
+// DefaultKeyPrefix is prepended to every key of a SessionAdapter
+const DefaultKeyPrefix = "auth:"
+
+// SessionAdapter implements auth.SessionAdapter using Redis. User IDs are
+// stored as given, so callers must use the IDs of the user adapter
+// (auth.UserData.ID) as AuthManager does.
+type SessionAdapter struct {
+	client     *redisclient.Client
+	prefix     string
+	tokens     tokens.Generator
+	tokenBytes int // random bytes of session IDs, 0 for auth.DefaultTokenBytes
+}
+
+// SessionAdapterOption configures a SessionAdapter
+type SessionAdapterOption func(*SessionAdapter)
+
+// WithTokenGenerator sets the random source of session IDs. Defaults to
+// tokens.Secure.
+func WithTokenGenerator(g tokens.Generator) SessionAdapterOption {
+	return func(a *SessionAdapter) {
+		a.tokens = g
+	}
+}
+
+// WithTokenBytes sets how many random bytes session IDs carry, see
+// auth.ValidateTokenBytes. Defaults to auth.DefaultTokenBytes.
+func WithTokenBytes(n int) SessionAdapterOption {
+	return func(a *SessionAdapter) {
+		a.tokenBytes = n
+	}
+}
+
+// WithKeyPrefix sets the prefix of every key. Defaults to DefaultKeyPrefix.
+func WithKeyPrefix(prefix string) SessionAdapterOption {
+	return func(a *SessionAdapter) {
+		a.prefix = prefix
+	}
+}
+
+// NewSessionAdapter creates a new Redis-based session adapter
+func NewSessionAdapter(client *redisclient.Client, opts ...SessionAdapterOption) *SessionAdapter {
+	a := &SessionAdapter{client: client, prefix: DefaultKeyPrefix, tokens: tokens.Secure{}}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// storedSession is the JSON value of a session key
+type storedSession struct {
+	UserID                string    `json:"user_id"`
+	ExpiresAt             time.Time `json:"expires_at"`
+	CreatedAt             time.Time `json:"created_at"`
+	LastUsedAt            time.Time `json:"last_used_at"`
+	ImpersonatorID        string    `json:"impersonator_id,omitempty"`
+	ImpersonatorSessionID string    `json:"impersonator_session_id,omitempty"`
+	UserAgent             string    `json:"user_agent,omitempty"`
+	IP                    string    `json:"ip,omitempty"`
+}
+
+func (a *SessionAdapter) sessionKey(sessionID string) string {
+	return a.prefix + "session:" + sessionID
+}
+
+// userSessionsKey names the set of a user's session IDs. It has no TTL:
+// members whose session expired are dropped when the set is listed.
+func (a *SessionAdapter) userSessionsKey(userID string) string {
+	return a.prefix + "user_sessions:" + userID
+}
+
+// ttl returns the expiration of a key that expires at expiresAt, at least 1ms
+func ttl(expiresAt time.Time) time.Duration {
+	return max(time.Until(expiresAt), time.Millisecond)
+}
+
+// CreateSession creates a new session for a user
+func (a *SessionAdapter) CreateSession(ctx context.Context, userID string, expiresAt time.Time, metadata auth.SessionMetadata) (*auth.Session, error) {
+	sessionID, err := auth.NewSessionID(a.tokens, a.tokenBytes)
+	if err != nil {
+		logger.Error("Erro ao gerar ID de sessão", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	now := time.Now()
+	session := storedSession{
+		UserID:                userID,
+		ExpiresAt:             expiresAt,
+		CreatedAt:             now,
+		LastUsedAt:            now,
+		ImpersonatorID:        metadata.ImpersonatorID,
+		ImpersonatorSessionID: metadata.ImpersonatorSessionID,
+		UserAgent:             metadata.UserAgent,
+		IP:                    metadata.IP,
+	}
+	value, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = a.client.Pipelined(ctx, func(pipe redisclient.Pipeliner) error {
+		pipe.Set(ctx, a.sessionKey(sessionID), value, ttl(expiresAt))
+		pipe.SAdd(ctx, a.userSessionsKey(userID), sessionID)
+		return nil
+	})
+	if err != nil {
+		logger.Error("Erro ao criar sessão no Redis", "error", err, "user_id", userID, "session_id", sessionID)
+		return nil, err
+	}
+
+	return toAuthSession(sessionID, &session), nil
+}
+
+// GetSession retrieves a session by ID
+func (a *SessionAdapter) GetSession(ctx context.Context, sessionID string) (*auth.Session, error) {
+	session, err := a.getSession(ctx, sessionID)
+	if err != nil {
+		if !errors.Is(err, auth.ErrSessionNotFound) {
+			logger.Error("Erro ao buscar sessão no Redis", "error", err, "session_id", sessionID)
+		}
+		return nil, err
+	}
+	return toAuthSession(sessionID, session), nil
+}
+
+// UpdateSessionExpiry updates the expiration time of a session, and the TTL
+// of its key
+func (a *SessionAdapter) UpdateSessionExpiry(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	err := a.updateSession(ctx, sessionID, func(session *storedSession) {
+		session.ExpiresAt = expiresAt
+	})
+	if err != nil {
+		logger.Error("Erro ao atualizar expiração da sessão", "error", err, "session_id", sessionID)
+	}
+	return err
+}
+
+// UpdateSessionLastUsed records the last time a session was used
+func (a *SessionAdapter) UpdateSessionLastUsed(ctx context.Context, sessionID string, lastUsedAt time.Time) error {
+	err := a.updateSession(ctx, sessionID, func(session *storedSession) {
+		session.LastUsedAt = lastUsedAt
+	})
+	if err != nil {
+		logger.Error("Erro ao atualizar último uso da sessão", "error", err, "session_id", sessionID)
+	}
+	return err
+}
+
+// DeleteSession removes a session
+func (a *SessionAdapter) DeleteSession(ctx context.Context, sessionID string) error {
+	session, err := a.getSession(ctx, sessionID)
+	if errors.Is(err, auth.ErrSessionNotFound) {
+		return nil
+	}
+	if err == nil {
+		_, err = a.client.Pipelined(ctx, func(pipe redisclient.Pipeliner) error {
+			pipe.Del(ctx, a.sessionKey(sessionID))
+			pipe.SRem(ctx, a.userSessionsKey(session.UserID), sessionID)
+			return nil
+		})
+	}
+	if err != nil {
+		logger.Error("Erro ao deletar sessão", "error", err, "session_id", sessionID)
+		return err
+	}
+	return nil
+}
+
+// DeleteUserSessions removes all sessions for a user
+func (a *SessionAdapter) DeleteUserSessions(ctx context.Context, userID string) error {
+	ids, err := a.userSessionIDs(ctx, userID)
+	if err == nil {
+		keys := []string{a.userSessionsKey(userID)}
+		for _, id := range ids {
+			keys = append(keys, a.sessionKey(id))
+		}
+		err = a.client.Del(ctx, keys...).Err()
+	}
+	if err != nil {
+		logger.Error("Erro ao deletar sessões do usuário", "error", err, "user_id", userID)
+		return err
+	}
+	return nil
+}
+
+// ListUserSessions returns all sessions of a user, newest first
+func (a *SessionAdapter) ListUserSessions(ctx context.Context, userID string) ([]*auth.Session, error) {
+	ids, err := a.userSessionIDs(ctx, userID)
+	if err != nil {
+		logger.Error("Erro ao listar sessões do usuário", "error", err, "user_id", userID)
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	pipe := a.client.Pipeline()
+	gets := make([]*redisclient.StringCmd, len(ids))
+	for i, id := range ids {
+		gets[i] = pipe.Get(ctx, a.sessionKey(id))
+	}
+	// Expired sessions are nil replies, checked one by one below
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redisclient.Nil) {
+		logger.Error("Erro ao listar sessões do usuário", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	sessions := make([]*auth.Session, 0, len(ids))
+	var expired []any
+	for i, get := range gets {
+		if errors.Is(get.Err(), redisclient.Nil) {
+			expired = append(expired, ids[i])
+			continue
+		}
+		session, err := decodeSession(get.Result())
+		if err != nil {
+			logger.Error("Erro ao listar sessões do usuário", "error", err, "user_id", userID)
+			return nil, err
+		}
+		sessions = append(sessions, toAuthSession(ids[i], session))
+	}
+	if len(expired) > 0 {
+		if err := a.client.SRem(ctx, a.userSessionsKey(userID), expired...).Err(); err != nil {
+			logger.Warn("Erro ao remover sessões expiradas da lista do usuário", "error", err, "user_id", userID)
+		}
+	}
+
+	slices.SortFunc(sessions, func(x, y *auth.Session) int {
+		return y.CreatedAt.Compare(x.CreatedAt)
+	})
+	return sessions, nil
+}
+
+// DeleteExpiredSessions is a no-op: Redis expires session keys by itself
+func (a *SessionAdapter) DeleteExpiredSessions(ctx context.Context, limit int) (int64, error) {
+	return 0, nil
+}
+
+func (a *SessionAdapter) getSession(ctx context.Context, sessionID string) (*storedSession, error) {
+	value, err := a.client.Get(ctx, a.sessionKey(sessionID)).Result()
+	if errors.Is(err, redisclient.Nil) {
+		return nil, auth.ErrSessionNotFound
+	}
+	return decodeSession(value, err)
+}
+
+// updateSession rewrites a session with update applied. SET XX never
+// recreates a session deleted in the meantime; missing sessions are ignored,
+// as for the GORM adapter.
+func (a *SessionAdapter) updateSession(ctx context.Context, sessionID string, update func(*storedSession)) error {
+	session, err := a.getSession(ctx, sessionID)
+	if errors.Is(err, auth.ErrSessionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	update(session)
+
+	value, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return a.client.SetXX(ctx, a.sessionKey(sessionID), value, ttl(session.ExpiresAt)).Err()
+}
+
+func (a *SessionAdapter) userSessionIDs(ctx context.Context, userID string) ([]string, error) {
+	return a.client.SMembers(ctx, a.userSessionsKey(userID)).Result()
+}
+
+func decodeSession(value string, err error) (*storedSession, error) {
+	if err != nil {
+		return nil, err
+	}
+	var session storedSession
+	if err := json.Unmarshal([]byte(value), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func toAuthSession(sessionID string, session *storedSession) *auth.Session {
+	return &auth.Session{
+		ID:                    sessionID,
+		UserID:                session.UserID,
+		ExpiresAt:             session.ExpiresAt,
+		CreatedAt:             session.CreatedAt,
+		LastUsedAt:            session.LastUsedAt,
+		ImpersonatorID:        session.ImpersonatorID,
+		ImpersonatorSessionID: session.ImpersonatorSessionID,
+		UserAgent:             session.UserAgent,
+		IP:                    session.IP,
+	}
+}
@@ -0,0 +1,113 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gosveltekit/internal/auth"
+
+	redisclient "github.com/redis/go-redis/v9"
+)
+
+// storedChallenge is the JSON value of a two-factor challenge key. Attempts
+// are counted in a key of their own, so concurrent wrong codes all count.
+type storedChallenge struct {
+	UserID    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+}
+
+func (a *SessionAdapter) challengeKey(hashedToken string) string {
+	return a.prefix + "2fa:" + hashedToken
+}
+
+func (a *SessionAdapter) challengeAttemptsKey(hashedToken string) string {
+	return a.prefix + "2fa_attempts:" + hashedToken
+}
+
+// CreateTwoFactorChallenge stores a pending two-factor login
+func (a *SessionAdapter) CreateTwoFactorChallenge(ctx context.Context, hashedToken string, challenge auth.TwoFactorChallenge) error {
+	value, err := json.Marshal(storedChallenge{
+		UserID:    challenge.UserID,
+		ExpiresAt: challenge.ExpiresAt,
+		UserAgent: challenge.Metadata.UserAgent,
+		IP:        challenge.Metadata.IP,
+	})
+	if err != nil {
+		return err
+	}
+	return a.client.Set(ctx, a.challengeKey(hashedToken), value, ttl(challenge.ExpiresAt)).Err()
+}
+
+// GetTwoFactorChallenge returns a pending two-factor login by token hash
+func (a *SessionAdapter) GetTwoFactorChallenge(ctx context.Context, hashedToken string) (*auth.TwoFactorChallenge, error) {
+	pipe := a.client.Pipeline()
+	get := pipe.Get(ctx, a.challengeKey(hashedToken))
+	getAttempts := pipe.Get(ctx, a.challengeAttemptsKey(hashedToken))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redisclient.Nil) {
+		return nil, err
+	}
+	value, err := get.Bytes()
+	if errors.Is(err, redisclient.Nil) {
+		return nil, auth.ErrInvalidTwoFactorChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored storedChallenge
+	if err := json.Unmarshal(value, &stored); err != nil {
+		return nil, err
+	}
+	attempts, err := getAttempts.Int64()
+	if err != nil && !errors.Is(err, redisclient.Nil) {
+		return nil, err
+	}
+
+	return &auth.TwoFactorChallenge{
+		UserID:    stored.UserID,
+		ExpiresAt: stored.ExpiresAt,
+		Attempts:  int(attempts),
+		Metadata: auth.SessionMetadata{
+			UserAgent: stored.UserAgent,
+			IP:        stored.IP,
+		},
+	}, nil
+}
+
+// RecordTwoFactorAttempt increments the wrong codes of a challenge. The
+// counter expires with the challenge.
+func (a *SessionAdapter) RecordTwoFactorAttempt(ctx context.Context, hashedToken string) error {
+	remaining, err := a.client.PTTL(ctx, a.challengeKey(hashedToken)).Result()
+	if err != nil {
+		return err
+	}
+	if remaining <= 0 {
+		// Expired or gone: nothing left to count against
+		return nil
+	}
+
+	key := a.challengeAttemptsKey(hashedToken)
+	_, err = a.client.Pipelined(ctx, func(pipe redisclient.Pipeliner) error {
+		pipe.Incr(ctx, key)
+		pipe.PExpire(ctx, key, remaining)
+		return nil
+	})
+	return err
+}
+
+// DeleteTwoFactorChallenge removes a challenge, reporting whether it existed
+func (a *SessionAdapter) DeleteTwoFactorChallenge(ctx context.Context, hashedToken string) (bool, error) {
+	var deleted *redisclient.IntCmd
+	_, err := a.client.Pipelined(ctx, func(pipe redisclient.Pipeliner) error {
+		deleted = pipe.Del(ctx, a.challengeKey(hashedToken))
+		pipe.Del(ctx, a.challengeAttemptsKey(hashedToken))
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return deleted.Val() > 0, nil
+}
@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/tokens"
+)
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to spot
+const APIKeyPrefix = "ak_"
+
+// Random bytes in the public ID and in the secret of an API key
+const (
+	apiKeyIDBytes     = 8
+	apiKeySecretBytes = 32
+)
+
+// Limits of CreateAPIKey
+const (
+	MaxAPIKeyScopes   = 20
+	MaxAPIKeysPerUser = 20
+)
+
+// Errors of CreateAPIKey
+var (
+	// ErrInvalidScope: a scope isn't a permission of the form "resource:action"
+	ErrInvalidScope        = errors.New("invalid api key scope")
+	ErrInvalidAPIKeyExpiry = errors.New("api key expiry must be in the future")
+	ErrTooManyAPIKeys      = errors.New("too many api keys")
+)
+
+// Errors of CreateAPIKeyFor
+var (
+	// ErrAPIKeyForbidden: the actor may not issue keys for the target
+	ErrAPIKeyForbidden = errors.New("api key issuance not allowed")
+	// ErrScopeNotGranted: a scope isn't granted by the target's role
+	ErrScopeNotGranted = errors.New("api key scope not granted by the role")
+)
+
+var scopePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*:[a-z][a-z0-9_-]*$`)
+
+// Allows reports whether the key was granted every one of permissions
+func (k *APIKey) Allows(permissions ...string) bool {
+	for _, permission := range permissions {
+		if !slices.Contains(k.Scopes, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateAPIKey issues an API key for the user, limited to scopes and valid
+// until expiresAt (nil for no expiry). The key is returned once; only the
+// hash of its secret is stored. Scopes only narrow what the owner's role
+// allows, they never grant more.
+func (m *AuthManager) CreateAPIKey(ctx context.Context, userID, name string, scopes []string, expiresAt *time.Time) (string, *APIKey, error) {
+	adapter, ok := m.userAdapter.(APIKeyAdapter)
+	if !ok {
+		return "", nil, ErrAPIKeysUnsupported
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	now := m.config.Clock.Now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return "", nil, ErrInvalidAPIKeyExpiry
+	}
+	existing, err := adapter.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(existing) >= MaxAPIKeysPerUser {
+		return "", nil, ErrTooManyAPIKeys
+	}
+
+	id, err := tokens.Hex(m.config.Tokens, apiKeyIDBytes)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := tokens.Hex(m.config.Tokens, apiKeySecretBytes)
+	if err != nil {
+		return "", nil, err
+	}
+	key := APIKey{
+		ID:        id,
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+	if err := adapter.CreateAPIKey(ctx, key, hashToken(secret)); err != nil {
+		logger.Error("Erro ao criar chave de API", "error", err, "user_id", userID)
+		return "", nil, err
+	}
+	return APIKeyPrefix + id + "_" + secret, &key, nil
+}
+
+// CreateAPIKeyFor issues an API key for targetUserID on behalf of actorID, as
+// CreateAPIKey. A key acts as its owner, so the rules of Impersonate apply:
+// ErrAPIKeyForbidden if the actor's role lacks PermissionImpersonate or the
+// target is the actor, an admin or may impersonate too. Every scope must be
+// granted by the target's role, ErrScopeNotGranted otherwise.
+func (m *AuthManager) CreateAPIKeyFor(ctx context.Context, actorID, targetUserID, name string, scopes []string, expiresAt *time.Time) (string, *APIKey, error) {
+	actor, err := m.userAdapter.FindUserByID(ctx, actorID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !m.config.Permissions.Allows(actor.Role, PermissionImpersonate) {
+		return "", nil, ErrAPIKeyForbidden
+	}
+	target, err := m.userAdapter.FindUserByID(ctx, targetUserID)
+	if err != nil {
+		return "", nil, err
+	}
+	if target.ID == actor.ID || target.Role == AdminRole || m.config.Permissions.Allows(target.Role, PermissionImpersonate) {
+		return "", nil, ErrAPIKeyForbidden
+	}
+	scopes, err = normalizeScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	if !m.config.Permissions.Allows(target.Role, scopes...) {
+		return "", nil, ErrScopeNotGranted
+	}
+	return m.CreateAPIKey(ctx, target.ID, name, scopes, expiresAt)
+}
+
+// ValidateAPIKey authenticates a request made with key, returning the key
+// and its owner. Unknown, malformed and expired keys give ErrInvalidAPIKey;
+// keys of users who can't log in give ErrUserNotActive or
+// ErrAccountPendingDeletion. Uses are recorded at most once per
+// SessionActivityInterval.
+func (m *AuthManager) ValidateAPIKey(ctx context.Context, key string) (*APIKey, *UserData, error) {
+	adapter, ok := m.userAdapter.(APIKeyAdapter)
+	if !ok {
+		return nil, nil, ErrAPIKeysUnsupported
+	}
+	id, secret, ok := parseAPIKey(key)
+	if !ok {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	stored, hashedSecret, err := adapter.GetAPIKey(ctx, id)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(hashedSecret)) != 1 {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	now := m.config.Clock.Now()
+	if stored.ExpiresAt != nil && now.After(*stored.ExpiresAt) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	user, err := m.userAdapter.FindUserByID(ctx, stored.UserID)
+	if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrInvalidCredentials) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.Active {
+		return nil, nil, ErrUserNotActive
+	}
+	if user.DeletionScheduled() {
+		return nil, nil, ErrAccountPendingDeletion
+	}
+
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) >= m.config.SessionActivityInterval {
+		if err := adapter.UpdateAPIKeyLastUsed(ctx, id, now); err == nil {
+			stored.LastUsedAt = &now
+		} else {
+			logger.Warn("Erro ao registrar uso da chave de API", "error", err, "api_key_id", id)
+		}
+	}
+	return stored, user, nil
+}
+
+// ListAPIKeys returns the API keys of the user, newest first
+func (m *AuthManager) ListAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	adapter, ok := m.userAdapter.(APIKeyAdapter)
+	if !ok {
+		return nil, ErrAPIKeysUnsupported
+	}
+	return adapter.ListAPIKeys(ctx, userID)
+}
+
+// RevokeAPIKey deletes the API key id of the user. Returns ErrAPIKeyNotFound
+// if the user has no such key.
+func (m *AuthManager) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	adapter, ok := m.userAdapter.(APIKeyAdapter)
+	if !ok {
+		return ErrAPIKeysUnsupported
+	}
+	if err := adapter.DeleteAPIKey(ctx, userID, id); err != nil {
+		return err
+	}
+	logger.Info("Chave de API revogada", "user_id", userID, "api_key_id", id)
+	return nil
+}
+
+// parseAPIKey splits "ak_<id>_<secret>"
+func parseAPIKey(key string) (id, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(key, APIKeyPrefix)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok = strings.Cut(rest, "_")
+	if !ok || len(id) != 2*apiKeyIDBytes || len(secret) != 2*apiKeySecretBytes {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// normalizeScopes validates scopes and returns them sorted without
+// duplicates
+func normalizeScopes(scopes []string) ([]string, error) {
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !scopePattern.MatchString(scope) {
+			return nil, ErrInvalidScope
+		}
+		normalized = append(normalized, scope)
+	}
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+	if len(normalized) == 0 || len(normalized) > MaxAPIKeyScopes {
+		return nil, ErrInvalidScope
+	}
+	return normalized, nil
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPIKeyUserAdapter adds API keys to fakeUserAdapter
+type fakeAPIKeyUserAdapter struct {
+	*fakeUserAdapter
+	keys   map[string]*APIKey
+	hashes map[string]string
+}
+
+func (f *fakeAPIKeyUserAdapter) CreateAPIKey(ctx context.Context, key APIKey, hashedSecret string) error {
+	f.keys[key.ID] = &key
+	f.hashes[key.ID] = hashedSecret
+	return nil
+}
+
+func (f *fakeAPIKeyUserAdapter) GetAPIKey(ctx context.Context, id string) (*APIKey, string, error) {
+	key, ok := f.keys[id]
+	if !ok {
+		return nil, "", ErrAPIKeyNotFound
+	}
+	copied := *key
+	return &copied, f.hashes[id], nil
+}
+
+func (f *fakeAPIKeyUserAdapter) ListAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	var keys []*APIKey
+	for _, key := range f.keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeAPIKeyUserAdapter) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	key, ok := f.keys[id]
+	if !ok || key.UserID != userID {
+		return ErrAPIKeyNotFound
+	}
+	delete(f.keys, id)
+	return nil
+}
+
+func (f *fakeAPIKeyUserAdapter) UpdateAPIKeyLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error {
+	f.keys[id].LastUsedAt = &lastUsedAt
+	return nil
+}
+
+func newAPIKeyTestManager(t *testing.T) (*AuthManager, *fakeAPIKeyUserAdapter, *FakeClock) {
+	t.Helper()
+	clock := NewFakeClock(time.Date(2024, 2, 11, 12, 0, 0, 0, time.UTC))
+	config := DefaultAuthConfig()
+	config.Clock = clock
+	_, users, sessions := newTestAuthManager(config)
+	adapter := &fakeAPIKeyUserAdapter{fakeUserAdapter: users, keys: make(map[string]*APIKey), hashes: make(map[string]string)}
+	return NewAuthManager(adapter, sessions, config), adapter, clock
+}
+
+func TestAuthManager_APIKeys(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("validates and lists keys", func(t *testing.T) {
+		m, adapter, clock := newAPIKeyTestManager(t)
+		secret, key, err := m.CreateAPIKey(ctx, "1", "cron", []string{"users:read", " audit:read", "users:read"}, nil)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(secret, APIKeyPrefix))
+		assert.Equal(t, []string{"audit:read", "users:read"}, key.Scopes)
+		assert.NotContains(t, adapter.hashes[key.ID], strings.TrimPrefix(secret, APIKeyPrefix+key.ID+"_"), "only the hash is stored")
+
+		validated, user, err := m.ValidateAPIKey(ctx, secret)
+		require.NoError(t, err)
+		assert.Equal(t, "1", user.ID)
+		assert.Equal(t, key.ID, validated.ID)
+		assert.True(t, validated.Allows("users:read"))
+		assert.False(t, validated.Allows("users:read", "users:write"))
+		require.NotNil(t, adapter.keys[key.ID].LastUsedAt)
+		assert.Equal(t, clock.Now(), *adapter.keys[key.ID].LastUsedAt)
+
+		keys, err := m.ListAPIKeys(ctx, "1")
+		require.NoError(t, err)
+		assert.Len(t, keys, 1)
+	})
+
+	t.Run("rejects bad keys", func(t *testing.T) {
+		m, _, clock := newAPIKeyTestManager(t)
+		expiresAt := clock.Now().Add(time.Hour)
+		secret, key, err := m.CreateAPIKey(ctx, "1", "cron", []string{"users:read"}, &expiresAt)
+		require.NoError(t, err)
+
+		tampered := secret[:len(secret)-1] + "0"
+		if tampered == secret {
+			tampered = secret[:len(secret)-1] + "1"
+		}
+		for _, bad := range []string{"", "ak_", "ak_" + key.ID, tampered, strings.Replace(secret, key.ID, "0123456789abcdef", 1)} {
+			_, _, err := m.ValidateAPIKey(ctx, bad)
+			assert.ErrorIs(t, err, ErrInvalidAPIKey, "key %q", bad)
+		}
+
+		clock.Advance(2 * time.Hour)
+		_, _, err = m.ValidateAPIKey(ctx, secret)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey, "expired")
+	})
+
+	t.Run("rejects keys of inactive users", func(t *testing.T) {
+		m, adapter, _ := newAPIKeyTestManager(t)
+		secret, _, err := m.CreateAPIKey(ctx, "1", "cron", []string{"users:read"}, nil)
+		require.NoError(t, err)
+
+		adapter.user.Active = false
+		_, _, err = m.ValidateAPIKey(ctx, secret)
+		assert.ErrorIs(t, err, ErrUserNotActive)
+	})
+
+	t.Run("rejects invalid scopes and expiry", func(t *testing.T) {
+		m, _, clock := newAPIKeyTestManager(t)
+		for _, scopes := range [][]string{nil, {"users"}, {"Users:read"}, {"users:read write"}} {
+			_, _, err := m.CreateAPIKey(ctx, "1", "cron", scopes, nil)
+			assert.ErrorIs(t, err, ErrInvalidScope, "scopes %q", scopes)
+		}
+		past := clock.Now().Add(-time.Hour)
+		_, _, err := m.CreateAPIKey(ctx, "1", "cron", []string{"users:read"}, &past)
+		assert.ErrorIs(t, err, ErrInvalidAPIKeyExpiry)
+	})
+
+	t.Run("revokes keys of the user only", func(t *testing.T) {
+		m, _, _ := newAPIKeyTestManager(t)
+		secret, key, err := m.CreateAPIKey(ctx, "1", "cron", []string{"users:read"}, nil)
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, m.RevokeAPIKey(ctx, "2", key.ID), ErrAPIKeyNotFound)
+		require.NoError(t, m.RevokeAPIKey(ctx, "1", key.ID))
+		_, _, err = m.ValidateAPIKey(ctx, secret)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	})
+
+	t.Run("unsupported adapter", func(t *testing.T) {
+		m, _, _ := newTestAuthManager(DefaultAuthConfig())
+		_, _, err := m.CreateAPIKey(ctx, "1", "cron", []string{"users:read"}, nil)
+		assert.ErrorIs(t, err, ErrAPIKeysUnsupported)
+		_, _, err = m.ValidateAPIKey(ctx, "ak_x")
+		assert.ErrorIs(t, err, ErrAPIKeysUnsupported)
+	})
+}
+
+func TestAuthManager_CreateAPIKeyFor(t *testing.T) {
+	ctx := context.Background()
+	m, adapter, _ := newAPIKeyTestManager(t)
+	m.config.Permissions = NewPolicy([]Role{
+		{Name: AdminRole, Permissions: []string{"users:read", "users:write", PermissionImpersonate}},
+		{Name: "support", Permissions: []string{PermissionImpersonate}},
+		{Name: "user", Permissions: []string{"users:read"}},
+	})
+	adapter.user.Role = AdminRole
+	adapter.others = map[string]UserData{
+		"2": {ID: "2", Identifier: "target", Role: "user", Active: true},
+		"3": {ID: "3", Identifier: "other-admin", Role: AdminRole, Active: true},
+		"4": {ID: "4", Identifier: "support", Role: "support", Active: true},
+	}
+
+	_, key, err := m.CreateAPIKeyFor(ctx, "1", "2", "cron", []string{"users:read"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "2", key.UserID)
+
+	for _, target := range []string{"1", "3", "4"} {
+		_, _, err := m.CreateAPIKeyFor(ctx, "1", target, "cron", []string{"users:read"}, nil)
+		assert.ErrorIs(t, err, ErrAPIKeyForbidden, "target %s", target)
+	}
+
+	_, _, err = m.CreateAPIKeyFor(ctx, "1", "2", "cron", []string{"users:read", "users:write"}, nil)
+	assert.ErrorIs(t, err, ErrScopeNotGranted)
+
+	_, _, err = m.CreateAPIKeyFor(ctx, "1", "2", "cron", []string{"users"}, nil)
+	assert.ErrorIs(t, err, ErrInvalidScope)
+
+	_, _, err = m.CreateAPIKeyFor(ctx, "1", "999", "cron", []string{"users:read"}, nil)
+	assert.Error(t, err, "unknown target")
+
+	// An actor whose role lacks the permission
+	m.config.Permissions = NewPolicy([]Role{{Name: AdminRole, Permissions: []string{"users:write"}}})
+	_, _, err = m.CreateAPIKeyFor(ctx, "1", "2", "cron", []string{"users:read"}, nil)
+	assert.ErrorIs(t, err, ErrAPIKeyForbidden, "actor without the permission")
+}
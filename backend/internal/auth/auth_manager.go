@@ -1,12 +1,16 @@
 package auth
 
 import (
-	"crypto/rand"
-	"encoding/base64"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
 	"gosveltekit/internal/logger"
+	"gosveltekit/internal/tokens"
 )
 
 // AuthConfig holds configuration for the auth manager
@@ -15,6 +19,96 @@ type AuthConfig struct {
 	RefreshThreshold  time.Duration // Refresh if less than this remaining (default: 15 days)
 	MaxFailedAttempts int           // Max failed login attempts before lockout
 	LockoutDuration   time.Duration // How long to lock account after max attempts
+
+	// MaxFailedAttemptsPerIP blocks a client IP after this many failed logins
+	// across any accounts, catching password spraying that stays under the
+	// per-account limit. Failures older than LockoutDuration are forgotten, so
+	// users sharing a NAT don't add up over time. Zero disables it.
+	MaxFailedAttemptsPerIP int // Default: 20
+
+	// Tarpit for repeated failed logins: each failure for the same identifier
+	// doubles the artificial delay, starting at FailedLoginBackoff and capped at
+	// MaxFailedLoginBackoff. Zero disables the delay.
+	FailedLoginBackoff    time.Duration
+	MaxFailedLoginBackoff time.Duration
+
+	// LoginAttemptsInMemory keeps failed login counters in this process only,
+	// even if the UserAdapter implements LoginAttemptAdapter: faster, but
+	// lockouts are lost on restart and not shared between instances
+	LoginAttemptsInMemory bool
+
+	// ClockSkewLeeway tolerates small clock differences between instances when
+	// checking expiry: a session is still accepted up to this long after ExpiresAt
+	ClockSkewLeeway time.Duration // Default: 30 seconds
+
+	// SessionIdleTimeout expires sessions unused for this long, even if their
+	// absolute expiry hasn't passed. Zero disables it.
+	SessionIdleTimeout time.Duration
+	// RequireVerifiedEmail refuses to log in users who haven't verified their
+	// email address (ErrEmailNotVerified)
+	RequireVerifiedEmail bool
+
+	// SessionActivityInterval throttles LastUsedAt writes: it is only updated
+	// when older than this, so hot paths don't write on every request
+	SessionActivityInterval time.Duration // Default: 1 minute
+
+	// PasswordHistoryDepth rejects new passwords matching any of the last N
+	// passwords (including the current one). Zero disables the check.
+	PasswordHistoryDepth int
+
+	// ImpersonationDuration is the lifetime of an impersonation session.
+	// Impersonation sessions are never refreshed.
+	ImpersonationDuration time.Duration // Default: 15 minutes
+
+	// Permissions is the policy of the roles, checked by Impersonate for
+	// PermissionImpersonate. Default: nil, where only AdminRole may impersonate.
+	Permissions *Policy
+
+	// SMS codes (phone verification and SMS 2FA): lifetime, failed attempts
+	// allowed per code, and per-user sending limits
+	SMSCodeTTL         time.Duration // Default: 5 minutes
+	SMSCodeMaxAttempts int           // Default: 5
+	SMSCodeInterval    time.Duration // Minimum time between codes (default: 1 minute)
+	SMSCodesPerHour    int           // Default: 5
+
+	// Magic links (passwordless login by email): lifetime and per-user
+	// sending limits
+	MagicLinkTTL      time.Duration // Default: 15 minutes
+	MagicLinkInterval time.Duration // Minimum time between links (default: 1 minute)
+	MagicLinksPerHour int           // Default: 5
+
+	// Two-factor login (TOTP): lifetime of the challenge Login returns instead
+	// of a session, and wrong codes accepted per challenge
+	TwoFactorChallengeTTL time.Duration // Default: 5 minutes
+	TwoFactorMaxAttempts  int           // Default: 5
+
+	// TOTPIssuer names the service in authenticator apps
+	TOTPIssuer string // Default: "GoSvelteKit"
+
+	// RefreshTokenDuration issues a refresh token with every login session,
+	// valid this long and while that session lasts. Refresh exchanges it once
+	// for a new session and refresh token. Zero disables refresh tokens.
+	RefreshTokenDuration time.Duration
+
+	// MaxSessionsPerUser caps the concurrent sessions of a user; at the limit
+	// Login evicts the least recently used session or, with OnSessionLimit set
+	// to SessionLimitReject, refuses the login. Zero disables the limit.
+	MaxSessionsPerUser int
+	OnSessionLimit     string // SessionLimitEvict or SessionLimitReject (default: evict)
+
+	// UsernamePolicy is checked on registration and username changes
+	UsernamePolicy UsernamePolicy
+
+	// Clock is the time source for every expiry check. Default: SystemClock.
+	Clock Clock
+
+	// Tokens generates recovery codes and SMS codes. Default: tokens.Secure.
+	Tokens tokens.Generator
+
+	// TokenBytes is how many random bytes refresh tokens and two-factor
+	// challenge tokens carry, see ValidateTokenBytes. Session IDs are made by
+	// the SessionAdapter. Zero means DefaultTokenBytes.
+	TokenBytes int
 }
 
 // DefaultAuthConfig returns sensible defaults
@@ -24,6 +118,30 @@ func DefaultAuthConfig() *AuthConfig {
 		RefreshThreshold:  15 * 24 * time.Hour, // 15 days
 		MaxFailedAttempts: 5,
 		LockoutDuration:   30 * time.Minute,
+		ClockSkewLeeway:   30 * time.Second,
+
+		MaxFailedAttemptsPerIP: 20,
+
+		SessionActivityInterval: time.Minute,
+		ImpersonationDuration:   15 * time.Minute,
+
+		SMSCodeTTL:         5 * time.Minute,
+		SMSCodeMaxAttempts: 5,
+		SMSCodeInterval:    time.Minute,
+		SMSCodesPerHour:    5,
+
+		MagicLinkTTL:      15 * time.Minute,
+		MagicLinkInterval: time.Minute,
+		MagicLinksPerHour: 5,
+
+		TwoFactorChallengeTTL: 5 * time.Minute,
+		TwoFactorMaxAttempts:  5,
+		TOTPIssuer:            "GoSvelteKit",
+
+		UsernamePolicy: DefaultUsernamePolicy(),
+
+		Clock:  SystemClock{},
+		Tokens: tokens.Secure{},
 	}
 }
 
@@ -33,16 +151,10 @@ type AuthManager struct {
 	sessionAdapter SessionAdapter
 	config         *AuthConfig
 
-	// Rate limiting for failed attempts
-	failedAttempts      map[string]failedAttemptInfo
-	failedAttemptsMutex sync.RWMutex
-}
-
-type failedAttemptInfo struct {
-	count    int
-	lastTry  time.Time
-	lockedAt time.Time
-	isLocked bool
+	// Failed login counters, per identifier and per client IP. The mutex
+	// serializes their updates.
+	loginAttempts       LoginAttemptAdapter
+	failedAttemptsMutex sync.Mutex
 }
 
 // NewAuthManager creates a new AuthManager instance
@@ -50,25 +162,58 @@ func NewAuthManager(userAdapter UserAdapter, sessionAdapter SessionAdapter, conf
 	if config == nil {
 		config = DefaultAuthConfig()
 	}
+	if config.Clock == nil {
+		config.Clock = SystemClock{}
+	}
+	if config.Tokens == nil {
+		config.Tokens = tokens.Secure{}
+	}
+	loginAttempts, ok := userAdapter.(LoginAttemptAdapter)
+	if !ok || config.LoginAttemptsInMemory {
+		loginAttempts = newMemoryLoginAttempts()
+	}
 	return &AuthManager{
 		userAdapter:    userAdapter,
 		sessionAdapter: sessionAdapter,
 		config:         config,
-		failedAttempts: make(map[string]failedAttemptInfo),
+		loginAttempts:  loginAttempts,
 	}
 }
 
-// Login authenticates a user and creates a session
-func (m *AuthManager) Login(identifier, password string, metadata SessionMetadata) (*Session, *UserData, error) {
+// Login authenticates a user and creates a session.
+//
+// Failed attempts count against both the identifier and metadata.IP, and
+// whichever reaches its limit first blocks the login (ErrAccountLocked or
+// ErrTooManyAttempts). They are answered after a progressive delay (see
+// AuthConfig.FailedLoginBackoff). The delay is cancelled together with ctx.
+//
+// The error of the failed attempt that locks the account also matches
+// ErrLockoutStarted, exactly once per lockout.
+//
+// Users with a second factor (TOTP or SMS) get no session yet: the error is a
+// *TwoFactorRequiredError, and CompleteTwoFactorLogin creates the session.
+func (m *AuthManager) Login(ctx context.Context, identifier, password string, metadata SessionMetadata) (*Session, *UserData, error) {
 	// Check if account is locked
-	if m.isAccountLocked(identifier) {
+	if m.isAccountLocked(ctx, identifier) {
 		return nil, nil, ErrAccountLocked
 	}
+	if m.isIPLocked(ctx, metadata.IP) {
+		return nil, nil, ErrTooManyAttempts
+	}
 
 	// Validate credentials
-	user, err := m.userAdapter.ValidateCredentials(identifier, password)
+	user, err := m.userAdapter.ValidateCredentials(ctx, identifier, password)
 	if err != nil {
-		m.recordFailedAttempt(identifier)
+		failures, lockedNow := m.recordFailedAttempt(ctx, identifier)
+		failures = max(failures, m.recordFailedAttemptFromIP(ctx, metadata.IP))
+		// Sleep only after the credentials lookup has finished, so the delay
+		// never holds a database connection
+		if sleepErr := sleepContext(ctx, m.failedLoginDelay(failures)); sleepErr != nil {
+			err = sleepErr
+		}
+		if lockedNow {
+			err = errors.Join(err, ErrLockoutStarted)
+		}
 		return nil, nil, err
 	}
 
@@ -76,38 +221,113 @@ func (m *AuthManager) Login(identifier, password string, metadata SessionMetadat
 	if !user.Active {
 		return nil, nil, ErrUserNotActive
 	}
+	// Accounts pending deletion stay locked until restored
+	if user.DeletionScheduled() {
+		return nil, nil, ErrAccountPendingDeletion
+	}
+	if m.config.RequireVerifiedEmail && !user.EmailVerified() {
+		return nil, nil, ErrEmailNotVerified
+	}
+
+	// Clear failed attempts on successful login. The IP counter is kept: a
+	// sprayer holding one valid account must not be able to reset it.
+	m.clearFailedAttempts(ctx, identifier)
+
+	if err := m.requireSecondFactor(ctx, user, metadata); err != nil {
+		return nil, nil, err
+	}
 
-	// Clear failed attempts on successful login
-	m.clearFailedAttempts(identifier)
+	if err := m.enforceSessionLimit(ctx, user.ID); err != nil {
+		return nil, nil, err
+	}
 
 	// Create session
-	expiresAt := time.Now().Add(m.config.SessionDuration)
-	session, err := m.sessionAdapter.CreateSession(user.ID, expiresAt, metadata)
+	expiresAt := m.config.Clock.Now().Add(m.config.SessionDuration)
+	session, err := m.sessionAdapter.CreateSession(ctx, user.ID, expiresAt, metadata)
 	if err != nil {
 		logger.Error("Erro ao criar sessão após login", "error", err, "user_id", user.ID)
 		return nil, nil, err
 	}
+	if err := m.issueRefreshToken(ctx, session, ""); err != nil {
+		return nil, nil, err
+	}
+
+	session.Fresh = true
+	return session, user, nil
+}
+
+// LoginExternal creates a session for a user authenticated by an external
+// identity provider. The caller vouches for the identity, so no credentials
+// are checked, but the account must still be allowed to log in and pass the
+// two-factor challenge, as with Login.
+func (m *AuthManager) LoginExternal(ctx context.Context, userID string, metadata SessionMetadata) (*Session, *UserData, error) {
+	user, err := m.userAdapter.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.Active {
+		return nil, nil, ErrUserNotActive
+	}
+	if user.DeletionScheduled() {
+		return nil, nil, ErrAccountPendingDeletion
+	}
+	if err := m.requireSecondFactor(ctx, user, metadata); err != nil {
+		return nil, nil, err
+	}
+
+	if err := m.enforceSessionLimit(ctx, user.ID); err != nil {
+		return nil, nil, err
+	}
+
+	expiresAt := m.config.Clock.Now().Add(m.config.SessionDuration)
+	session, err := m.sessionAdapter.CreateSession(ctx, user.ID, expiresAt, metadata)
+	if err != nil {
+		logger.Error("Erro ao criar sessão após login externo", "error", err, "user_id", user.ID)
+		return nil, nil, err
+	}
+	if err := m.issueRefreshToken(ctx, session, ""); err != nil {
+		return nil, nil, err
+	}
 
 	session.Fresh = true
 	return session, user, nil
 }
 
 // ValidateSession validates a session and returns user data
-func (m *AuthManager) ValidateSession(sessionID string) (*Session, *UserData, error) {
-	session, err := m.sessionAdapter.GetSession(sessionID)
+func (m *AuthManager) ValidateSession(ctx context.Context, sessionID string) (*Session, *UserData, error) {
+	session, err := m.sessionAdapter.GetSession(ctx, sessionID)
 	if err != nil {
-		return nil, nil, ErrSessionNotFound
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, nil, ctxErr
+		}
+		if errors.Is(err, ErrSessionNotFound) {
+			return nil, nil, ErrSessionNotFound
+		}
+		// Storage failures are passed through, see middleware.WithReadFallback
+		return nil, nil, err
 	}
 
-	// Check if expired
-	if time.Now().After(session.ExpiresAt) {
+	// Check if expired (tolerating the configured clock skew)
+	now := m.config.Clock.Now()
+	if now.After(session.ExpiresAt.Add(m.config.ClockSkewLeeway)) {
 		// Clean up expired session
-		_ = m.sessionAdapter.DeleteSession(sessionID)
+		_ = m.sessionAdapter.DeleteSession(ctx, sessionID)
+		return nil, nil, ErrSessionExpired
+	}
+
+	// Check inactivity. Sessions created before LastUsedAt existed fall back to CreatedAt.
+	lastUsedAt := session.LastUsedAt
+	if lastUsedAt.IsZero() {
+		lastUsedAt = session.CreatedAt
+	}
+	if m.config.SessionIdleTimeout > 0 && now.After(lastUsedAt.Add(m.config.SessionIdleTimeout+m.config.ClockSkewLeeway)) {
+		_ = m.sessionAdapter.DeleteSession(ctx, sessionID)
+		logger.Debug("Sessão expirada por inatividade", "session_id", sessionID, "last_used_at", lastUsedAt)
 		return nil, nil, ErrSessionExpired
 	}
 
 	// Get user data
-	user, err := m.userAdapter.FindUserByID(session.UserID)
+	user, err := m.userAdapter.FindUserByID(ctx, session.UserID)
 	if err != nil {
 		logger.Error("Erro ao buscar usuário durante validação de sessão", "error", err, "session_id", sessionID, "user_id", session.UserID)
 		return nil, nil, err
@@ -118,12 +338,21 @@ func (m *AuthManager) ValidateSession(sessionID string) (*Session, *UserData, er
 		return nil, nil, ErrUserNotActive
 	}
 
-	// Refresh session if needed
+	// Record activity, at most once per SessionActivityInterval
+	if now.Sub(lastUsedAt) >= m.config.SessionActivityInterval {
+		if err := m.sessionAdapter.UpdateSessionLastUsed(ctx, sessionID, now); err == nil {
+			session.LastUsedAt = now
+		} else {
+			logger.Warn("Erro ao registrar uso da sessão", "error", err, "session_id", sessionID)
+		}
+	}
+
+	// Refresh session if needed (impersonation sessions keep their short lifetime)
 	session.Fresh = false
-	timeRemaining := time.Until(session.ExpiresAt)
-	if timeRemaining < m.config.RefreshThreshold {
-		newExpiresAt := time.Now().Add(m.config.SessionDuration)
-		if err := m.sessionAdapter.UpdateSessionExpiry(sessionID, newExpiresAt); err == nil {
+	timeRemaining := session.ExpiresAt.Sub(now)
+	if timeRemaining < m.config.RefreshThreshold && !session.IsImpersonation() {
+		newExpiresAt := now.Add(m.config.SessionDuration)
+		if err := m.sessionAdapter.UpdateSessionExpiry(ctx, sessionID, newExpiresAt); err == nil {
 			session.ExpiresAt = newExpiresAt
 			session.Fresh = true
 			logger.Debug("Sessão renovada", "session_id", sessionID, "user_id", user.ID)
@@ -135,9 +364,34 @@ func (m *AuthManager) ValidateSession(sessionID string) (*Session, *UserData, er
 	return session, user, nil
 }
 
+// CheckSession reports whether sessionID is still valid, with the errors of
+// ValidateSession, without recording activity or refreshing it. Long-lived
+// connections use it to notice revoked sessions: ValidateSession would keep
+// an idle session alive.
+func (m *AuthManager) CheckSession(ctx context.Context, sessionID string) (*Session, error) {
+	session, err := m.sessionAdapter.GetSession(ctx, sessionID)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	if m.sessionDead(session, m.config.Clock.Now()) {
+		return nil, ErrSessionExpired
+	}
+	user, err := m.userAdapter.FindUserByID(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		return nil, ErrUserNotActive
+	}
+	return session, nil
+}
+
 // Logout invalidates a session
-func (m *AuthManager) Logout(sessionID string) error {
-	if err := m.sessionAdapter.DeleteSession(sessionID); err != nil {
+func (m *AuthManager) Logout(ctx context.Context, sessionID string) error {
+	if err := m.sessionAdapter.DeleteSession(ctx, sessionID); err != nil {
 		logger.Error("Erro ao fazer logout", "error", err, "session_id", sessionID)
 		return err
 	}
@@ -145,8 +399,8 @@ func (m *AuthManager) Logout(sessionID string) error {
 }
 
 // LogoutAll invalidates all sessions for a user
-func (m *AuthManager) LogoutAll(userID string) error {
-	if err := m.sessionAdapter.DeleteUserSessions(userID); err != nil {
+func (m *AuthManager) LogoutAll(ctx context.Context, userID string) error {
+	if err := m.sessionAdapter.DeleteUserSessions(ctx, userID); err != nil {
 		logger.Error("Erro ao fazer logout de todas as sessões", "error", err, "user_id", userID)
 		return err
 	}
@@ -154,78 +408,225 @@ func (m *AuthManager) LogoutAll(userID string) error {
 	return nil
 }
 
+// SessionRevocation is the outcome of invalidating one session in
+// LogoutOthers; Err is nil when the session was deleted
+type SessionRevocation struct {
+	SessionID string
+	Err       error
+}
+
+// LogoutOthers invalidates all sessions of a user except keepSessionID and
+// reports each of them. A session that can't be deleted doesn't stop the
+// others; the error is only for failing to list them. Without session
+// listing support every session is invalidated and none is reported.
+func (m *AuthManager) LogoutOthers(ctx context.Context, userID, keepSessionID string) ([]SessionRevocation, error) {
+	sessions, err := m.ListSessions(ctx, userID)
+	if err == ErrSessionListUnsupported {
+		return nil, m.LogoutAll(ctx, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	revocations := make([]SessionRevocation, 0, len(sessions))
+	for _, session := range sessions {
+		if session.ID == keepSessionID {
+			continue
+		}
+		err := m.sessionAdapter.DeleteSession(ctx, session.ID)
+		if err != nil {
+			logger.Error("Erro ao invalidar sessão", "error", err, "session_id", session.ID, "user_id", userID)
+		}
+		revocations = append(revocations, SessionRevocation{SessionID: session.ID, Err: err})
+	}
+	logger.Info("Demais sessões do usuário foram invalidadas", "user_id", userID, "sessions", len(revocations))
+	return revocations, nil
+}
+
+// UpdatePassword changes a user's password, rejecting it with ErrPasswordReused
+// if it matches one of the last PasswordHistoryDepth passwords
+func (m *AuthManager) UpdatePassword(ctx context.Context, userID, newPassword string) error {
+	depth := m.config.PasswordHistoryDepth
+	history, ok := m.userAdapter.(PasswordHistoryAdapter)
+	if depth <= 0 || !ok {
+		return m.userAdapter.UpdatePassword(ctx, userID, newPassword)
+	}
+
+	reused, err := history.IsRecentPassword(ctx, userID, newPassword, depth)
+	if err != nil {
+		logger.Error("Erro ao verificar histórico de senhas", "error", err, "user_id", userID)
+		return err
+	}
+	if reused {
+		return ErrPasswordReused
+	}
+
+	// The current password counts towards the depth, so keep depth-1 previous ones
+	if err := history.UpdatePasswordWithHistory(ctx, userID, newPassword, depth-1); err != nil {
+		logger.Error("Erro ao atualizar senha", "error", err, "user_id", userID)
+		return err
+	}
+	return nil
+}
+
+// ListSessions returns all sessions of a user, newest first
+func (m *AuthManager) ListSessions(ctx context.Context, userID string) ([]*Session, error) {
+	adapter, ok := m.sessionAdapter.(SessionListAdapter)
+	if !ok {
+		return nil, ErrSessionListUnsupported
+	}
+	return adapter.ListUserSessions(ctx, userID)
+}
+
+// ListAllSessions returns a page of the unexpired sessions of every user
+// matching filter, newest first, and how many match in total. Returns
+// ErrSessionListUnsupported when the session adapter can't list them.
+func (m *AuthManager) ListAllSessions(ctx context.Context, filter SessionFilter, offset, limit int) ([]*Session, int64, error) {
+	adapter, ok := m.sessionAdapter.(SessionListAllAdapter)
+	if !ok {
+		return nil, 0, ErrSessionListUnsupported
+	}
+	filter.ActiveAt = m.config.Clock.Now()
+	return adapter.ListAll(ctx, filter, offset, limit)
+}
+
 // GetUserAdapter returns the user adapter (useful for registration, etc)
 func (m *AuthManager) GetUserAdapter() UserAdapter {
 	return m.userAdapter
 }
 
+// Clock returns the time source used for expiry checks
+func (m *AuthManager) Clock() Clock {
+	return m.config.Clock
+}
+
+// Tokens returns the random source of the manager, for the components that
+// must share it
+func (m *AuthManager) Tokens() tokens.Generator {
+	return m.config.Tokens
+}
+
+// UsernamePolicy returns the rules usernames must follow
+func (m *AuthManager) UsernamePolicy() UsernamePolicy {
+	return m.config.UsernamePolicy
+}
+
+// PasswordHistoryDepth returns how many recent passwords can't be reused
+func (m *AuthManager) PasswordHistoryDepth() int {
+	return m.config.PasswordHistoryDepth
+}
+
 // GetSessionAdapter returns the session adapter
 func (m *AuthManager) GetSessionAdapter() SessionAdapter {
 	return m.sessionAdapter
 }
 
-// GenerateSessionID generates a cryptographically secure session ID
+// Random bytes in a session ID. The ceiling keeps the encoded ID within the
+// 64 characters of the sessions table's key.
+const (
+	DefaultTokenBytes = 32
+	MinTokenBytes     = 16
+	MaxTokenBytes     = 48
+)
+
+// ErrInvalidTokenBytes is returned by ValidateTokenBytes for sizes out of range
+var ErrInvalidTokenBytes = errors.New("tamanho de token inválido")
+
+// ValidateTokenBytes checks a number of random bytes for session IDs and
+// tokens. Zero means DefaultTokenBytes; sizes below MinTokenBytes or above
+// MaxTokenBytes are rejected.
+func ValidateTokenBytes(n int) error {
+	if n != 0 && (n < MinTokenBytes || n > MaxTokenBytes) {
+		return fmt.Errorf("%w: %d bytes (use entre %d e %d)", ErrInvalidTokenBytes, n, MinTokenBytes, MaxTokenBytes)
+	}
+	return nil
+}
+
+// GenerateSessionID generates a cryptographically secure session ID:
+// DefaultTokenBytes random bytes from crypto/rand, base64url-encoded without
+// padding
 func GenerateSessionID() (string, error) {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
+	return NewSessionID(tokens.Secure{}, 0)
+}
+
+// NewSessionID generates a session ID like GenerateSessionID, reading n
+// random bytes from g. Zero means DefaultTokenBytes.
+func NewSessionID(g tokens.Generator, n int) (string, error) {
+	if n == 0 {
+		n = DefaultTokenBytes
 	}
-	return base64.URLEncoding.EncodeToString(bytes), nil
+	return tokens.Text(g, n)
+}
+
+// newToken generates a refresh or challenge token of TokenBytes random bytes
+func (m *AuthManager) newToken() (string, error) {
+	return NewSessionID(m.config.Tokens, m.config.TokenBytes)
+}
+
+// hashToken hashes a random token (two-factor challenge, refresh token) for
+// storage. Tokens are random, so a fast hash is sufficient.
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
 }
 
 // GenerateRandomBytes fills a byte slice with cryptographically secure random bytes
 func GenerateRandomBytes(b []byte) (int, error) {
-	return rand.Read(b)
+	return tokens.Secure{}.Read(b)
 }
 
 // --- Rate limiting helpers ---
 
-func (m *AuthManager) isAccountLocked(identifier string) bool {
-	m.failedAttemptsMutex.RLock()
-	defer m.failedAttemptsMutex.RUnlock()
-
-	info, exists := m.failedAttempts[identifier]
-	if !exists {
-		return false
+// failedLoginDelay returns the tarpit delay after the given number of
+// consecutive failures: FailedLoginBackoff * 2^(failures-1), capped.
+func (m *AuthManager) failedLoginDelay(failures int) time.Duration {
+	base := m.config.FailedLoginBackoff
+	if base <= 0 || failures <= 0 {
+		return 0
 	}
 
-	if !info.isLocked {
-		return false
+	limit := m.config.MaxFailedLoginBackoff
+	if limit <= 0 {
+		limit = base
 	}
 
-	// Check if lockout has expired
-	if time.Since(info.lockedAt) > m.config.LockoutDuration {
-		return false
+	delay := base
+	for i := 1; i < failures && delay < limit; i++ {
+		delay *= 2
 	}
-
-	return true
+	if delay > limit {
+		delay = limit
+	}
+	return delay
 }
 
-func (m *AuthManager) recordFailedAttempt(identifier string) {
-	m.failedAttemptsMutex.Lock()
-	defer m.failedAttemptsMutex.Unlock()
-
-	info := m.failedAttempts[identifier]
-	info.count++
-	info.lastTry = time.Now()
-
-	if info.count >= m.config.MaxFailedAttempts {
-		info.isLocked = true
-		info.lockedAt = time.Now()
+// sleepContext waits for d or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
 	}
 
-	m.failedAttempts[identifier] = info
-}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
-func (m *AuthManager) clearFailedAttempts(identifier string) {
-	m.failedAttemptsMutex.Lock()
-	defer m.failedAttemptsMutex.Unlock()
-	delete(m.failedAttempts, identifier)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
 // ErrAccountLocked is returned when an account is temporarily locked
 var ErrAccountLocked = errorString("account temporarily locked")
 
+// ErrLockoutStarted is joined to the error of the failed login that locked
+// the account, so callers can tell the lockout began with that attempt
+var ErrLockoutStarted = errorString("account locked after too many failed logins")
+
+// ErrTooManyAttempts is returned when the client IP is temporarily blocked
+// after too many failed logins across accounts
+var ErrTooManyAttempts = errorString("too many failed login attempts from this address")
+
 type errorString string
 
 func (e errorString) Error() string { return string(e) }
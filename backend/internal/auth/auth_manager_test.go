@@ -0,0 +1,680 @@
+// Package auth tests
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/tokens"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUserAdapter is an in-memory UserAdapter with a single user
+type fakeUserAdapter struct {
+	user     UserData
+	password string
+
+	// others are additional users, only looked up by ID
+	others map[string]UserData
+}
+
+func (f *fakeUserAdapter) FindUserByIdentifier(ctx context.Context, identifier string) (*UserData, error) {
+	if identifier != f.user.Identifier {
+		return nil, ErrInvalidCredentials
+	}
+	user := f.user
+	return &user, nil
+}
+
+func (f *fakeUserAdapter) FindUserByID(ctx context.Context, id string) (*UserData, error) {
+	if other, ok := f.others[id]; ok {
+		return &other, nil
+	}
+	if id != f.user.ID {
+		return nil, ErrInvalidCredentials
+	}
+	user := f.user
+	return &user, nil
+}
+
+func (f *fakeUserAdapter) ValidateCredentials(ctx context.Context, identifier, password string) (*UserData, error) {
+	if identifier != f.user.Identifier || password != f.password {
+		return nil, ErrInvalidCredentials
+	}
+	user := f.user
+	return &user, nil
+}
+
+func (f *fakeUserAdapter) CreateUser(ctx context.Context, data CreateUserInput) (*UserData, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeUserAdapter) UpdatePassword(ctx context.Context, userID string, newPassword string) error {
+	f.password = newPassword
+	return nil
+}
+
+// fakeSessionAdapter is an in-memory SessionAdapter
+type fakeSessionAdapter struct {
+	sessions map[string]*Session
+
+	lastUsedUpdates int
+}
+
+func newFakeSessionAdapter() *fakeSessionAdapter {
+	return &fakeSessionAdapter{sessions: make(map[string]*Session)}
+}
+
+func (f *fakeSessionAdapter) CreateSession(ctx context.Context, userID string, expiresAt time.Time, metadata SessionMetadata) (*Session, error) {
+	id, err := GenerateSessionID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := &Session{
+		ID:         id,
+		UserID:     userID,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		UserAgent:  metadata.UserAgent,
+		IP:         metadata.IP,
+
+		ImpersonatorID:        metadata.ImpersonatorID,
+		ImpersonatorSessionID: metadata.ImpersonatorSessionID,
+	}
+	f.sessions[id] = session
+	copied := *session
+	return &copied, nil
+}
+
+func (f *fakeSessionAdapter) GetSession(ctx context.Context, sessionID string) (*Session, error) {
+	session, ok := f.sessions[sessionID]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (f *fakeSessionAdapter) UpdateSessionExpiry(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	if session, ok := f.sessions[sessionID]; ok {
+		session.ExpiresAt = expiresAt
+	}
+	return nil
+}
+
+func (f *fakeSessionAdapter) UpdateSessionLastUsed(ctx context.Context, sessionID string, lastUsedAt time.Time) error {
+	if session, ok := f.sessions[sessionID]; ok {
+		session.LastUsedAt = lastUsedAt
+		f.lastUsedUpdates++
+	}
+	return nil
+}
+
+func (f *fakeSessionAdapter) DeleteSession(ctx context.Context, sessionID string) error {
+	delete(f.sessions, sessionID)
+	return nil
+}
+
+func (f *fakeSessionAdapter) DeleteUserSessions(ctx context.Context, userID string) error {
+	for id, session := range f.sessions {
+		if session.UserID == userID {
+			delete(f.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (f *fakeSessionAdapter) DeleteExpiredSessions(ctx context.Context, limit int) (int64, error) {
+	var deleted int64
+	for id, session := range f.sessions {
+		if deleted < int64(limit) && time.Now().After(session.ExpiresAt) {
+			delete(f.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (f *fakeSessionAdapter) ListUserSessions(ctx context.Context, userID string) ([]*Session, error) {
+	var result []*Session
+	for _, session := range f.sessions {
+		if session.UserID == userID {
+			copied := *session
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func newTestAuthManager(config *AuthConfig) (*AuthManager, *fakeUserAdapter, *fakeSessionAdapter) {
+	users := &fakeUserAdapter{
+		user: UserData{
+			ID:         "1",
+			Identifier: "testuser",
+			Email:      "test@example.com",
+			Role:       "user",
+			Active:     true,
+		},
+		password: "Password123!",
+	}
+	sessions := newFakeSessionAdapter()
+	return NewAuthManager(users, sessions, config), users, sessions
+}
+
+func TestAuthManager_FailedLoginDelay(t *testing.T) {
+	config := DefaultAuthConfig()
+	config.FailedLoginBackoff = 100 * time.Millisecond
+	config.MaxFailedLoginBackoff = time.Second
+	m, _, _ := newTestAuthManager(config)
+
+	assert.Equal(t, time.Duration(0), m.failedLoginDelay(0))
+	assert.Equal(t, 100*time.Millisecond, m.failedLoginDelay(1))
+	assert.Equal(t, 200*time.Millisecond, m.failedLoginDelay(2))
+	assert.Equal(t, 400*time.Millisecond, m.failedLoginDelay(3))
+	assert.Equal(t, 800*time.Millisecond, m.failedLoginDelay(4))
+	assert.Equal(t, time.Second, m.failedLoginDelay(5))
+	assert.Equal(t, time.Second, m.failedLoginDelay(50))
+
+	config.FailedLoginBackoff = 0
+	assert.Equal(t, time.Duration(0), m.failedLoginDelay(3))
+}
+
+func TestAuthManager_Login_BackoffGrowsWithFailures(t *testing.T) {
+	config := DefaultAuthConfig()
+	config.MaxFailedAttempts = 100
+	config.FailedLoginBackoff = 20 * time.Millisecond
+	config.MaxFailedLoginBackoff = time.Second
+	m, _, _ := newTestAuthManager(config)
+	ctx := context.Background()
+
+	var previous time.Duration
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		_, _, err := m.Login(ctx, "testuser", "wrong", SessionMetadata{})
+		elapsed := time.Since(start)
+
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		assert.Greater(t, elapsed, previous, "delay should grow with each failure")
+		previous = elapsed
+	}
+
+	// A successful login is never delayed
+	start := time.Now()
+	_, _, err := m.Login(ctx, "testuser", "Password123!", SessionMetadata{})
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), config.FailedLoginBackoff)
+}
+
+func TestAuthManager_Login_BackoffCancelledWithContext(t *testing.T) {
+	config := DefaultAuthConfig()
+	config.FailedLoginBackoff = time.Minute
+	config.MaxFailedLoginBackoff = time.Minute
+	m, _, _ := newTestAuthManager(config)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := m.Login(ctx, "testuser", "wrong", SessionMetadata{})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestAuthManager_ValidateSession_ClockSkewLeeway(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiredBy time.Duration
+		wantErr   error
+	}{
+		{name: "just past expiry within leeway", expiredBy: 10 * time.Second},
+		{name: "past expiry beyond leeway", expiredBy: time.Minute, wantErr: ErrSessionExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultAuthConfig()
+			config.ClockSkewLeeway = 30 * time.Second
+			m, _, sessions := newTestAuthManager(config)
+
+			session, err := sessions.CreateSession(context.Background(), "1", time.Now().Add(-tt.expiredBy), SessionMetadata{})
+			require.NoError(t, err)
+
+			_, user, err := m.ValidateSession(context.Background(), session.ID)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "1", user.ID)
+		})
+	}
+}
+
+func TestAuthManager_ValidateSession_IdleTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		idleFor time.Duration
+		wantErr error
+	}{
+		{name: "recently used", idleFor: 5 * time.Minute},
+		{name: "idle beyond timeout", idleFor: 11 * time.Minute, wantErr: ErrSessionExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultAuthConfig()
+			config.SessionIdleTimeout = 10 * time.Minute
+			config.ClockSkewLeeway = 0
+			m, _, sessions := newTestAuthManager(config)
+
+			// Absolute expiry is far away
+			session, err := sessions.CreateSession(context.Background(), "1", time.Now().Add(24*time.Hour), SessionMetadata{})
+			require.NoError(t, err)
+			sessions.sessions[session.ID].LastUsedAt = time.Now().Add(-tt.idleFor)
+
+			_, _, err = m.ValidateSession(context.Background(), session.ID)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.NotContains(t, sessions.sessions, session.ID, "idle session should be deleted")
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestAuthManager_ValidateSession_RefreshesLastUsedAt(t *testing.T) {
+	config := DefaultAuthConfig()
+	config.SessionIdleTimeout = 10 * time.Minute
+	config.SessionActivityInterval = time.Minute
+	m, _, sessions := newTestAuthManager(config)
+
+	session, err := sessions.CreateSession(context.Background(), "1", time.Now().Add(24*time.Hour), SessionMetadata{})
+	require.NoError(t, err)
+
+	// Just created: repeated requests within the interval don't write
+	for i := 0; i < 3; i++ {
+		_, _, err = m.ValidateSession(context.Background(), session.ID)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 0, sessions.lastUsedUpdates)
+
+	// Once the interval has passed, activity moves LastUsedAt forward
+	stale := time.Now().Add(-2 * time.Minute)
+	sessions.sessions[session.ID].LastUsedAt = stale
+
+	validated, _, err := m.ValidateSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sessions.lastUsedUpdates)
+	assert.True(t, validated.LastUsedAt.After(stale))
+	assert.WithinDuration(t, time.Now(), sessions.sessions[session.ID].LastUsedAt, time.Second)
+}
+
+func TestAuthManager_CheckSession(t *testing.T) {
+	config := DefaultAuthConfig()
+	config.SessionIdleTimeout = 10 * time.Minute
+	config.SessionActivityInterval = 0
+	config.ClockSkewLeeway = 0
+	m, _, sessions := newTestAuthManager(config)
+	ctx := context.Background()
+
+	session, err := sessions.CreateSession(ctx, "1", time.Now().Add(time.Minute), SessionMetadata{})
+	require.NoError(t, err)
+	stale := time.Now().Add(-5 * time.Minute)
+	sessions.sessions[session.ID].LastUsedAt = stale
+
+	// Checking is not activity, nor does it refresh the session
+	checked, err := m.CheckSession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, checked.ID)
+	assert.Equal(t, 0, sessions.lastUsedUpdates)
+	assert.Equal(t, stale, sessions.sessions[session.ID].LastUsedAt)
+	assert.Equal(t, session.ExpiresAt, sessions.sessions[session.ID].ExpiresAt)
+
+	sessions.sessions[session.ID].LastUsedAt = time.Now().Add(-11 * time.Minute)
+	_, err = m.CheckSession(ctx, session.ID)
+	assert.ErrorIs(t, err, ErrSessionExpired)
+
+	_, err = m.CheckSession(ctx, "missing")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestAuthManager_FakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	config := DefaultAuthConfig()
+	config.Clock = clock
+	config.SessionDuration = time.Hour
+	config.RefreshThreshold = 0
+	config.ClockSkewLeeway = 0
+	config.MaxFailedAttempts = 2
+	config.LockoutDuration = 30 * time.Minute
+	m, _, _ := newTestAuthManager(config)
+	ctx := context.Background()
+
+	// Sessions expire once the clock passes SessionDuration
+	session, _, err := m.Login(ctx, "testuser", "Password123!", SessionMetadata{})
+	require.NoError(t, err)
+	assert.Equal(t, clock.Now().Add(time.Hour), session.ExpiresAt)
+
+	clock.Advance(59 * time.Minute)
+	_, _, err = m.ValidateSession(ctx, session.ID)
+	require.NoError(t, err)
+
+	clock.Advance(2 * time.Minute)
+	_, _, err = m.ValidateSession(ctx, session.ID)
+	assert.ErrorIs(t, err, ErrSessionExpired)
+
+	// Lockouts lift once the clock passes LockoutDuration
+	for i := 0; i < config.MaxFailedAttempts; i++ {
+		_, _, err = m.Login(ctx, "testuser", "wrong", SessionMetadata{})
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	_, _, err = m.Login(ctx, "testuser", "Password123!", SessionMetadata{})
+	assert.ErrorIs(t, err, ErrAccountLocked)
+
+	clock.Advance(31 * time.Minute)
+	_, _, err = m.Login(ctx, "testuser", "Password123!", SessionMetadata{})
+	assert.NoError(t, err)
+}
+
+func TestAuthManager_LockoutStarted(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	config := DefaultAuthConfig()
+	config.Clock = clock
+	config.MaxFailedAttempts = 3
+	m, _, _ := newTestAuthManager(config)
+	ctx := context.Background()
+
+	// Only the failure that locks the account reports it
+	for i := 1; i <= config.MaxFailedAttempts; i++ {
+		_, _, err := m.Login(ctx, "testuser", "wrong", SessionMetadata{})
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		assert.Equal(t, i == config.MaxFailedAttempts, errors.Is(err, ErrLockoutStarted), "attempt %d", i)
+	}
+	assert.Equal(t, clock.Now().Add(config.LockoutDuration), m.LockedUntil(ctx, "testuser"))
+
+	_, _, err := m.Login(ctx, "testuser", "wrong", SessionMetadata{})
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	assert.NotErrorIs(t, err, ErrLockoutStarted)
+
+	// A failure after the lockout lifts starts a new one
+	clock.Advance(config.LockoutDuration + time.Minute)
+	assert.True(t, m.LockedUntil(ctx, "testuser").IsZero())
+	_, _, err = m.Login(ctx, "testuser", "wrong", SessionMetadata{})
+	assert.ErrorIs(t, err, ErrLockoutStarted)
+}
+
+func TestAuthManager_LoginThrottle(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	config := DefaultAuthConfig()
+	config.Clock = clock
+	config.MaxFailedAttempts = 3
+	config.MaxFailedAttemptsPerIP = 4
+	config.LockoutDuration = 30 * time.Minute
+	ctx := context.Background()
+	sprayer := SessionMetadata{IP: "203.0.113.7"}
+	neighbor := SessionMetadata{IP: "198.51.100.1"}
+
+	t.Run("per-IP limit trips before any per-username limit", func(t *testing.T) {
+		m, _, _ := newTestAuthManager(config)
+
+		// One guess per account stays under the per-username limit
+		for i := 0; i < config.MaxFailedAttemptsPerIP; i++ {
+			_, _, err := m.Login(ctx, fmt.Sprintf("victim%d", i), "guess", sprayer)
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		}
+		_, _, err := m.Login(ctx, "testuser", "Password123!", sprayer)
+		assert.ErrorIs(t, err, ErrTooManyAttempts, "the IP is blocked even with valid credentials")
+
+		// Neither the accounts nor other addresses are affected
+		_, _, err = m.Login(ctx, "testuser", "Password123!", neighbor)
+		assert.NoError(t, err)
+		_, _, err = m.Login(ctx, "victim0", "guess", neighbor)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+		// The block lifts with the lockout, and old failures are forgotten
+		clock.Advance(31 * time.Minute)
+		_, _, err = m.Login(ctx, "victim9", "guess", sprayer)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		_, _, err = m.Login(ctx, "testuser", "Password123!", sprayer)
+		assert.NoError(t, err)
+	})
+
+	t.Run("per-username limit trips across IPs", func(t *testing.T) {
+		m, _, _ := newTestAuthManager(config)
+
+		for i := 0; i < config.MaxFailedAttempts; i++ {
+			_, _, err := m.Login(ctx, "testuser", "wrong", SessionMetadata{IP: fmt.Sprintf("192.0.2.%d", i)})
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		}
+		_, _, err := m.Login(ctx, "testuser", "Password123!", neighbor)
+		assert.ErrorIs(t, err, ErrAccountLocked)
+	})
+
+	t.Run("successful logins don't reset the IP counter", func(t *testing.T) {
+		m, _, _ := newTestAuthManager(config)
+
+		for i := 0; i < config.MaxFailedAttemptsPerIP-1; i++ {
+			_, _, err := m.Login(ctx, fmt.Sprintf("victim%d", i), "guess", sprayer)
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+			_, _, err = m.Login(ctx, "testuser", "Password123!", sprayer)
+			assert.NoError(t, err)
+		}
+		_, _, err := m.Login(ctx, "victim9", "guess", sprayer)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		_, _, err = m.Login(ctx, "testuser", "Password123!", sprayer)
+		assert.ErrorIs(t, err, ErrTooManyAttempts)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		config := *config
+		config.MaxFailedAttemptsPerIP = 0
+		m, _, _ := newTestAuthManager(&config)
+
+		for i := 0; i < 10; i++ {
+			_, _, err := m.Login(ctx, fmt.Sprintf("victim%d", i), "guess", sprayer)
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		}
+		_, _, err := m.Login(ctx, "testuser", "Password123!", sprayer)
+		assert.NoError(t, err)
+	})
+}
+
+// storingUserAdapter keeps failed login counters outside the AuthManager,
+// like a database would
+type storingUserAdapter struct {
+	*fakeUserAdapter
+	*memoryLoginAttempts
+}
+
+func TestAuthManager_LoginAttemptsStored(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	config := DefaultAuthConfig()
+	config.Clock = clock
+	config.MaxFailedAttempts = 3
+	config.MaxFailedAttemptsPerIP = 5
+	_, fake, sessions := newTestAuthManager(config)
+	users := &storingUserAdapter{fakeUserAdapter: fake, memoryLoginAttempts: newMemoryLoginAttempts()}
+	ctx := context.Background()
+
+	m := NewAuthManager(users, sessions, config)
+	for range config.MaxFailedAttempts {
+		_, _, err := m.Login(ctx, "testuser", "wrong", SessionMetadata{IP: "192.0.2.1"})
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	for range config.MaxFailedAttemptsPerIP - config.MaxFailedAttempts {
+		_, _, err := m.Login(ctx, "other", "wrong", SessionMetadata{IP: "192.0.2.1"})
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+
+	// A restarted instance sees the lockouts of the store
+	restarted := NewAuthManager(users, sessions, config)
+	_, _, err := restarted.Login(ctx, "testuser", "Password123!", SessionMetadata{IP: "198.51.100.1"})
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	_, _, err = restarted.Login(ctx, "other", "wrong", SessionMetadata{IP: "192.0.2.1"})
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+
+	lockouts, err := restarted.Lockouts(ctx)
+	require.NoError(t, err)
+	require.Len(t, lockouts, 2)
+	byKind := map[string]Lockout{}
+	for _, lockout := range lockouts {
+		byKind[lockout.Kind] = lockout
+	}
+	assert.Equal(t, "testuser", byKind[LoginAttemptsAccount].Key)
+	assert.Equal(t, config.MaxFailedAttempts, byKind[LoginAttemptsAccount].Failures)
+	assert.Equal(t, clock.Now().Add(config.LockoutDuration), byKind[LoginAttemptsAccount].LockedUntil)
+	assert.Equal(t, "192.0.2.1", byKind[LoginAttemptsIP].Key)
+
+	// Unlocking lifts the lockouts for every instance
+	require.NoError(t, restarted.UnlockUser(ctx, "1"))
+	require.NoError(t, restarted.Unlock(ctx, LoginAttemptsIP, "192.0.2.1"))
+	_, _, err = m.Login(ctx, "testuser", "Password123!", SessionMetadata{IP: "192.0.2.1"})
+	assert.NoError(t, err)
+	lockouts, err = m.Lockouts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, lockouts)
+
+	// In memory, counters stay in the instance
+	config.LoginAttemptsInMemory = true
+	inMemory := NewAuthManager(users, sessions, config)
+	for range config.MaxFailedAttempts {
+		_, _, _ = inMemory.Login(ctx, "testuser", "wrong", SessionMetadata{})
+	}
+	_, _, err = inMemory.Login(ctx, "testuser", "Password123!", SessionMetadata{})
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	_, _, err = NewAuthManager(users, sessions, config).Login(ctx, "testuser", "Password123!", SessionMetadata{})
+	assert.NoError(t, err)
+	_, _, err = m.Login(ctx, "testuser", "Password123!", SessionMetadata{})
+	assert.NoError(t, err)
+}
+
+func TestNewSessionID_TokenBytes(t *testing.T) {
+	for _, n := range []int{0, MinTokenBytes, 40, MaxTokenBytes} {
+		require.NoError(t, ValidateTokenBytes(n))
+		want := n
+		if n == 0 {
+			want = DefaultTokenBytes
+		}
+
+		id, err := NewSessionID(tokens.Secure{}, n)
+		require.NoError(t, err)
+		assert.Regexp(t, `^[A-Za-z0-9_-]+$`, id, "URL-safe, without padding")
+		assert.LessOrEqual(t, len(id), 64, "fits the sessions table key")
+		decoded, err := base64.RawURLEncoding.DecodeString(id)
+		require.NoError(t, err)
+		assert.Len(t, decoded, want)
+	}
+
+	assert.ErrorIs(t, ValidateTokenBytes(MinTokenBytes-1), ErrInvalidTokenBytes)
+	assert.ErrorIs(t, ValidateTokenBytes(MaxTokenBytes+1), ErrInvalidTokenBytes)
+}
+
+func TestAuthManager_SessionLimit(t *testing.T) {
+	ctx := context.Background()
+	login := func(m *AuthManager) (*Session, error) {
+		session, _, err := m.Login(ctx, "testuser", "Password123!", SessionMetadata{})
+		return session, err
+	}
+
+	t.Run("evict ends the least recently used session", func(t *testing.T) {
+		config := DefaultAuthConfig()
+		config.MaxSessionsPerUser = 2
+		m, _, sessions := newTestAuthManager(config)
+
+		first, err := login(m)
+		require.NoError(t, err)
+		second, err := login(m)
+		require.NoError(t, err)
+		// The older session was used more recently
+		sessions.sessions[first.ID].LastUsedAt = time.Now().Add(time.Minute)
+
+		third, err := login(m)
+		require.NoError(t, err)
+		assert.Len(t, sessions.sessions, 2)
+		assert.Contains(t, sessions.sessions, first.ID)
+		assert.NotContains(t, sessions.sessions, second.ID)
+		assert.Contains(t, sessions.sessions, third.ID)
+	})
+
+	t.Run("reject refuses the login at the limit", func(t *testing.T) {
+		config := DefaultAuthConfig()
+		config.MaxSessionsPerUser = 2
+		config.OnSessionLimit = SessionLimitReject
+		m, _, sessions := newTestAuthManager(config)
+
+		for i := 0; i < 2; i++ {
+			_, err := login(m)
+			require.NoError(t, err)
+		}
+		_, err := login(m)
+		assert.ErrorIs(t, err, ErrTooManySessions)
+		assert.Len(t, sessions.sessions, 2)
+
+		// Logging out of one session makes room again
+		for id := range sessions.sessions {
+			require.NoError(t, m.Logout(ctx, id))
+			break
+		}
+		_, err = login(m)
+		assert.NoError(t, err)
+	})
+
+	t.Run("expired sessions are cleaned up and don't count", func(t *testing.T) {
+		config := DefaultAuthConfig()
+		config.MaxSessionsPerUser = 1
+		config.OnSessionLimit = SessionLimitReject
+		m, _, sessions := newTestAuthManager(config)
+
+		stale, err := login(m)
+		require.NoError(t, err)
+		sessions.sessions[stale.ID].ExpiresAt = time.Now().Add(-time.Hour)
+
+		_, err = login(m)
+		require.NoError(t, err)
+		assert.NotContains(t, sessions.sessions, stale.ID)
+		assert.Len(t, sessions.sessions, 1)
+	})
+}
+
+func TestAuthManager_UserSessions(t *testing.T) {
+	ctx := context.Background()
+	m, _, sessions := newTestAuthManager(DefaultAuthConfig())
+
+	current, _, err := m.Login(ctx, "testuser", "Password123!", SessionMetadata{UserAgent: "current"})
+	require.NoError(t, err)
+	other, _, err := m.Login(ctx, "testuser", "Password123!", SessionMetadata{UserAgent: "other"})
+	require.NoError(t, err)
+	stale, _, err := m.Login(ctx, "testuser", "Password123!", SessionMetadata{})
+	require.NoError(t, err)
+	sessions.sessions[stale.ID].ExpiresAt = time.Now().Add(-time.Hour)
+	sessions.sessions["impersonated"] = &Session{ID: "impersonated", UserID: "1", ExpiresAt: time.Now().Add(time.Hour), ImpersonatorID: "2"}
+
+	active, err := m.ActiveSessions(ctx, "1")
+	require.NoError(t, err)
+	ids := make([]string, len(active))
+	for i, session := range active {
+		ids[i] = session.ID
+	}
+	assert.ElementsMatch(t, []string{current.ID, other.ID}, ids)
+
+	handle := SessionHandle(other.ID)
+	assert.Len(t, handle, 32)
+	assert.NotContains(t, handle, other.ID)
+
+	assert.ErrorIs(t, m.RevokeSessionByHandle(ctx, "2", handle), ErrSessionNotFound, "only the owner can revoke it")
+	assert.ErrorIs(t, m.RevokeSessionByHandle(ctx, "1", SessionHandle("impersonated")), ErrSessionNotFound)
+	require.NoError(t, m.RevokeSessionByHandle(ctx, "1", handle))
+	assert.NotContains(t, sessions.sessions, other.ID)
+	assert.Contains(t, sessions.sessions, current.ID)
+	assert.ErrorIs(t, m.RevokeSessionByHandle(ctx, "1", handle), ErrSessionNotFound)
+}
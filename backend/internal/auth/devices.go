@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strings"
+)
+
+// Subnet sizes grouped into one device location, so a device keeps its
+// fingerprint when its address changes within the same network
+const (
+	deviceIPv4PrefixBits = 24
+	deviceIPv6PrefixBits = 48
+)
+
+// DeviceFingerprint identifies the device behind a session: its user agent
+// and the subnet of its IP address
+func DeviceFingerprint(userAgent, ip string) string {
+	sum := sha256.Sum256([]byte(userAgent + "\x00" + deviceSubnet(ip)))
+	return hex.EncodeToString(sum[:])
+}
+
+// deviceSubnet masks ip to its /24 (IPv4) or /48 (IPv6) network. Unparsable
+// addresses are used as they are.
+func deviceSubnet(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(deviceIPv4PrefixBits, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(deviceIPv6PrefixBits, 128)).String()
+}
+
+// IsNewDevice reports whether the device in metadata differs from the devices
+// of every known session. Impersonation sessions belong to an admin's device
+// and are ignored. Without any known session there is nothing to compare with
+// (first login, or every session expired), so the device isn't considered new.
+func IsNewDevice(known []*Session, metadata SessionMetadata) bool {
+	fingerprint := DeviceFingerprint(metadata.UserAgent, metadata.IP)
+	compared := false
+	for _, session := range known {
+		if session.IsImpersonation() {
+			continue
+		}
+		if DeviceFingerprint(session.UserAgent, session.IP) == fingerprint {
+			return false
+		}
+		compared = true
+	}
+	return compared
+}
+
+// IsNewDeviceSession reports whether session, usually just created by Login,
+// comes from a device none of the user's other sessions came from, see
+// IsNewDevice. It requires a SessionListAdapter.
+func (m *AuthManager) IsNewDeviceSession(ctx context.Context, session *Session) (bool, error) {
+	sessions, err := m.ListSessions(ctx, session.UserID)
+	if err != nil {
+		return false, err
+	}
+	known := make([]*Session, 0, len(sessions))
+	for _, s := range sessions {
+		if s.ID != session.ID {
+			known = append(known, s)
+		}
+	}
+	return IsNewDevice(known, SessionMetadata{UserAgent: session.UserAgent, IP: session.IP}), nil
+}
+
+// Browsers and systems recognized by DeviceName, in match order: Edge and
+// Opera also claim to be Chrome, Chrome claims to be Safari, and so on
+var (
+	deviceBrowsers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	}
+	deviceSystems = []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// DeviceName describes the device of a user agent for session lists, e.g.
+// "Firefox (Linux)". Unrecognized user agents give an empty string.
+func DeviceName(userAgent string) string {
+	var browser, system string
+	for _, b := range deviceBrowsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, s := range deviceSystems {
+		if strings.Contains(userAgent, s.token) {
+			system = s.name
+			break
+		}
+	}
+	switch {
+	case browser != "" && system != "":
+		return browser + " (" + system + ")"
+	case browser != "":
+		return browser
+	default:
+		return system
+	}
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsNewDevice(t *testing.T) {
+	const firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	const chrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36"
+	known := []*Session{
+		{ID: "home", UserAgent: firefox, IP: "203.0.113.10"},
+		{ID: "office", UserAgent: firefox, IP: "2001:db8:abcd:12::1"},
+		{ID: "impersonated", UserAgent: chrome, IP: "198.51.100.7", ImpersonatorID: "1"},
+	}
+
+	tests := []struct {
+		name     string
+		known    []*Session
+		metadata SessionMetadata
+		want     bool
+	}{
+		{name: "same device", known: known, metadata: SessionMetadata{UserAgent: firefox, IP: "203.0.113.10"}, want: false},
+		{name: "same IPv4 subnet", known: known, metadata: SessionMetadata{UserAgent: firefox, IP: "203.0.113.250"}, want: false},
+		{name: "same IPv6 subnet", known: known, metadata: SessionMetadata{UserAgent: firefox, IP: "2001:db8:abcd:ff::2"}, want: false},
+		{name: "other subnet", known: known, metadata: SessionMetadata{UserAgent: firefox, IP: "203.0.114.10"}, want: true},
+		{name: "other browser", known: known, metadata: SessionMetadata{UserAgent: chrome, IP: "203.0.113.10"}, want: true},
+		{name: "impersonation sessions are not the user's devices", known: known, metadata: SessionMetadata{UserAgent: chrome, IP: "198.51.100.7"}, want: true},
+		{name: "no known sessions", metadata: SessionMetadata{UserAgent: chrome, IP: "198.51.100.7"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsNewDevice(tt.known, tt.metadata))
+		})
+	}
+}
+
+func TestDeviceName(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0":                                                                  "Firefox (Linux)",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36":                             "Chrome (Windows)",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36 Edg/126.0":                   "Edge (Windows)",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1": "Safari (iOS)",
+		"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Mobile Safari/537.36":                                "Chrome (Android)",
+		"curl/8.5.0": "",
+		"":           "",
+	}
+	for userAgent, want := range tests {
+		assert.Equal(t, want, DeviceName(userAgent), userAgent)
+	}
+}
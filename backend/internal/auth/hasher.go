@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported password hashing algorithms
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+// BcryptMaxPasswordBytes is the longest input bcrypt uses; the rest of a
+// longer password would be ignored
+const BcryptMaxPasswordBytes = 72
+
+// How bcrypt handles passwords longer than BcryptMaxPasswordBytes
+const (
+	LongPasswordsReject  = "reject"  // Hash returns ErrPasswordTooLong
+	LongPasswordsPrehash = "prehash" // hash a SHA-256 digest of the password instead
+)
+
+var (
+	ErrUnknownHashAlgorithm = errors.New("algoritmo de hash de senha desconhecido")
+	ErrInvalidHash          = errors.New("hash de senha em formato inválido")
+	ErrUnknownLongPasswords = errors.New("tratamento de senhas longas desconhecido")
+	ErrPasswordTooLong      = fmt.Errorf("senha deve ter no máximo %d bytes", BcryptMaxPasswordBytes)
+)
+
+// PasswordHasher hashes and verifies passwords.
+//
+// Verify accepts hashes produced by any supported algorithm, so switching the
+// configured algorithm doesn't lock existing users out. NeedsRehash reports
+// whether a stored hash was produced with a different algorithm or different
+// parameters than the configured ones; adapters rehash such passwords after a
+// successful login.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) bool
+	NeedsRehash(hash string) bool
+}
+
+// MaxPasswordBytes returns the longest password h accepts, or 0 when it
+// accepts any length
+func MaxPasswordBytes(h PasswordHasher) int {
+	if bcryptHasher, ok := h.(*BcryptHasher); ok && !bcryptHasher.PrehashLongPasswords {
+		return BcryptMaxPasswordBytes
+	}
+	return 0
+}
+
+// NewPasswordHasher returns the hasher for algorithm. An empty algorithm
+// selects bcrypt, and an empty bcryptLongPasswords LongPasswordsReject.
+func NewPasswordHasher(algorithm string, bcryptCost int, bcryptLongPasswords string, argon2Params Argon2Params) (PasswordHasher, error) {
+	switch algorithm {
+	case "", HashBcrypt:
+		hasher := NewBcryptHasher(bcryptCost)
+		switch bcryptLongPasswords {
+		case "", LongPasswordsReject:
+		case LongPasswordsPrehash:
+			hasher.PrehashLongPasswords = true
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnknownLongPasswords, bcryptLongPasswords)
+		}
+		return hasher, nil
+	case HashArgon2id:
+		return NewArgon2idHasher(argon2Params), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownHashAlgorithm, algorithm)
+	}
+}
+
+// BcryptHasher hashes passwords with bcrypt.
+//
+// Passwords longer than BcryptMaxPasswordBytes are rejected with
+// ErrPasswordTooLong instead of being silently truncated, unless
+// PrehashLongPasswords is set.
+type BcryptHasher struct {
+	Cost int
+
+	// PrehashLongPasswords hashes a SHA-256 digest of long passwords, so
+	// every byte counts. Verify always accepts such hashes.
+	PrehashLongPasswords bool
+}
+
+// NewBcryptHasher creates a BcryptHasher. A cost outside bcrypt's range uses
+// bcrypt.DefaultCost.
+func NewBcryptHasher(cost int) *BcryptHasher {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &BcryptHasher{Cost: cost}
+}
+
+// Hash implements PasswordHasher
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	if len(password) > BcryptMaxPasswordBytes && !h.PrehashLongPasswords {
+		return "", ErrPasswordTooLong
+	}
+	hash, err := bcrypt.GenerateFromPassword(bcryptInput(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify implements PasswordHasher
+func (h *BcryptHasher) Verify(hash, password string) bool {
+	return verifyPassword(hash, password)
+}
+
+// NeedsRehash implements PasswordHasher
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2Params are the argon2id cost parameters
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params returns the OWASP-recommended argon2id parameters
+// (19 MiB, 2 iterations, 1 thread)
+func DefaultArgon2Params() Argon2Params {
+	return Argon2Params{
+		Memory:      19 * 1024,
+		Iterations:  2,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// Argon2idHasher hashes passwords with argon2id, encoded in the PHC string
+// format: $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idHasher struct {
+	Params Argon2Params
+}
+
+// NewArgon2idHasher creates an Argon2idHasher. Zero parameters fall back to
+// DefaultArgon2Params.
+func NewArgon2idHasher(params Argon2Params) *Argon2idHasher {
+	defaults := DefaultArgon2Params()
+	if params.Memory == 0 {
+		params.Memory = defaults.Memory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = defaults.Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = defaults.Parallelism
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = defaults.SaltLength
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = defaults.KeyLength
+	}
+	return &Argon2idHasher{Params: params}
+}
+
+// Hash implements PasswordHasher
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.Params.SaltLength)
+	if _, err := GenerateRandomBytes(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+	return encodeArgon2id(h.Params, salt, key), nil
+}
+
+// Verify implements PasswordHasher
+func (h *Argon2idHasher) Verify(hash, password string) bool {
+	return verifyPassword(hash, password)
+}
+
+// NeedsRehash implements PasswordHasher
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.Params.Memory ||
+		params.Iterations != h.Params.Iterations ||
+		params.Parallelism != h.Params.Parallelism ||
+		uint32(len(salt)) != h.Params.SaltLength ||
+		uint32(len(key)) != h.Params.KeyLength
+}
+
+// verifyPassword checks password against a hash of any supported algorithm
+func verifyPassword(hash, password string) bool {
+	if strings.HasPrefix(hash, "$"+HashArgon2id+"$") {
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(computed, key) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), bcryptInput(password)) == nil
+}
+
+// bcryptInput returns the bytes bcrypt hashes for password: the password
+// itself, or for passwords beyond bcrypt's limit the base64 of its SHA-256
+// digest (base64 because bcrypt stops at NUL bytes)
+func bcryptInput(password string) []byte {
+	if len(password) <= BcryptMaxPasswordBytes {
+		return []byte(password)
+	}
+	digest := sha256.Sum256([]byte(password))
+	return []byte(base64.StdEncoding.EncodeToString(digest[:]))
+}
+
+func encodeArgon2id(params Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		HashArgon2id, argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// decodeArgon2id parses a PHC-encoded argon2id hash
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != HashArgon2id {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
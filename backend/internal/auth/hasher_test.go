@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Small parameters keep the tests fast
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher := NewArgon2idHasher(testArgon2Params)
+
+	hash, err := hasher.Hash("Password123!")
+	require.NoError(t, err)
+	assert.Regexp(t, `^\$argon2id\$v=19\$m=1024,t=1,p=1\$`, hash)
+
+	assert.True(t, hasher.Verify(hash, "Password123!"))
+	assert.False(t, hasher.Verify(hash, "wrong"))
+	assert.False(t, hasher.NeedsRehash(hash))
+
+	// Salted: the same password never hashes the same way twice
+	again, err := hasher.Hash("Password123!")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, again)
+
+	assert.False(t, hasher.Verify("$argon2id$v=19$m=1024,t=1,p=1$bad", "Password123!"))
+}
+
+func TestArgon2idHasher_NeedsRehash(t *testing.T) {
+	current := NewArgon2idHasher(testArgon2Params)
+
+	tests := []struct {
+		name   string
+		change func(p *Argon2Params)
+	}{
+		{name: "memory", change: func(p *Argon2Params) { p.Memory = 512 }},
+		{name: "iterations", change: func(p *Argon2Params) { p.Iterations = 2 }},
+		{name: "parallelism", change: func(p *Argon2Params) { p.Parallelism = 2 }},
+		{name: "key length", change: func(p *Argon2Params) { p.KeyLength = 16 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testArgon2Params
+			tt.change(&params)
+			hash, err := NewArgon2idHasher(params).Hash("Password123!")
+			require.NoError(t, err)
+
+			// Old parameters still verify, but are flagged for an upgrade
+			assert.True(t, current.Verify(hash, "Password123!"))
+			assert.True(t, current.NeedsRehash(hash))
+		})
+	}
+
+	assert.True(t, current.NeedsRehash("not-a-hash"))
+}
+
+func TestPasswordHasher_CrossAlgorithm(t *testing.T) {
+	bcryptHasher := NewBcryptHasher(bcrypt.MinCost)
+	argonHasher := NewArgon2idHasher(testArgon2Params)
+
+	bcryptHash, err := bcryptHasher.Hash("Password123!")
+	require.NoError(t, err)
+	argonHash, err := argonHasher.Hash("Password123!")
+	require.NoError(t, err)
+
+	// Either hasher verifies both formats and flags the other one
+	assert.True(t, argonHasher.Verify(bcryptHash, "Password123!"))
+	assert.True(t, argonHasher.NeedsRehash(bcryptHash))
+	assert.True(t, bcryptHasher.Verify(argonHash, "Password123!"))
+	assert.True(t, bcryptHasher.NeedsRehash(argonHash))
+
+	// Bcrypt cost changes are detected too
+	assert.False(t, bcryptHasher.NeedsRehash(bcryptHash))
+	assert.True(t, NewBcryptHasher(bcrypt.MinCost+1).NeedsRehash(bcryptHash))
+}
+
+func TestNewPasswordHasher(t *testing.T) {
+	hasher, err := NewPasswordHasher("", 0, "", Argon2Params{})
+	require.NoError(t, err)
+	assert.IsType(t, &BcryptHasher{}, hasher)
+	assert.Equal(t, bcrypt.DefaultCost, hasher.(*BcryptHasher).Cost)
+
+	hasher, err = NewPasswordHasher(HashArgon2id, 0, "", Argon2Params{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultArgon2Params(), hasher.(*Argon2idHasher).Params)
+
+	_, err = NewPasswordHasher("md5", 0, "", Argon2Params{})
+	assert.ErrorIs(t, err, ErrUnknownHashAlgorithm)
+}
+
+func TestBcryptHasher_LongPasswords(t *testing.T) {
+	long := strings.Repeat("a", 99) + "!" // 100 bytes
+	sameFirst72 := strings.Repeat("a", 99) + "?"
+
+	// Rejected by default instead of silently truncated
+	rejecting, err := NewPasswordHasher(HashBcrypt, bcrypt.MinCost, "", Argon2Params{})
+	require.NoError(t, err)
+	_, err = rejecting.Hash(long)
+	assert.ErrorIs(t, err, ErrPasswordTooLong)
+	_, err = rejecting.Hash(long[:BcryptMaxPasswordBytes])
+	assert.NoError(t, err, "exactly 72 bytes is fine")
+
+	// Pre-hashed: every byte counts
+	prehashing, err := NewPasswordHasher(HashBcrypt, bcrypt.MinCost, LongPasswordsPrehash, Argon2Params{})
+	require.NoError(t, err)
+	hash, err := prehashing.Hash(long)
+	require.NoError(t, err)
+	assert.True(t, prehashing.Verify(hash, long))
+	assert.False(t, prehashing.Verify(hash, sameFirst72), "bytes past 72 must matter")
+	assert.True(t, rejecting.Verify(hash, long), "switching back to reject keeps existing users working")
+
+	_, err = NewPasswordHasher(HashBcrypt, 0, "truncate", Argon2Params{})
+	assert.ErrorIs(t, err, ErrUnknownLongPasswords)
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+
+	"gosveltekit/internal/logger"
+)
+
+// AdminRole is the role granted every permission when no roles are
+// configured, see Policy.Allows
+const AdminRole = "admin"
+
+// Impersonate lets the admin owning adminSessionID act as targetUserID.
+//
+// It returns a short-lived session for the target user that also carries the
+// admin's identity (Session.ImpersonatorID). The admin session stays valid and
+// is restored by EndImpersonation. Returns ErrImpersonationForbidden if the
+// caller's role lacks PermissionImpersonate in AuthConfig.Permissions, the
+// caller is already impersonating, or the target may impersonate too (or is
+// an admin).
+func (m *AuthManager) Impersonate(ctx context.Context, adminSessionID, targetUserID string, metadata SessionMetadata) (*Session, *UserData, error) {
+	adminSession, admin, err := m.ValidateSession(ctx, adminSessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if adminSession.IsImpersonation() || !m.config.Permissions.Allows(admin.Role, PermissionImpersonate) {
+		return nil, nil, ErrImpersonationForbidden
+	}
+	target, err := m.userAdapter.FindUserByID(ctx, targetUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Compared after the lookup: targetUserID may be in another ID form
+	if target.ID == admin.ID || target.Role == AdminRole || m.config.Permissions.Allows(target.Role, PermissionImpersonate) {
+		return nil, nil, ErrImpersonationForbidden
+	}
+	if !target.Active {
+		return nil, nil, ErrUserNotActive
+	}
+
+	metadata.ImpersonatorID = admin.ID
+	metadata.ImpersonatorSessionID = adminSessionID
+	expiresAt := m.config.Clock.Now().Add(m.config.ImpersonationDuration)
+	// Never outlive the admin session it came from
+	if adminSession.ExpiresAt.Before(expiresAt) {
+		expiresAt = adminSession.ExpiresAt
+	}
+
+	session, err := m.sessionAdapter.CreateSession(ctx, target.ID, expiresAt, metadata)
+	if err != nil {
+		logger.Error("Erro ao criar sessão de impersonação", "error", err, "admin_id", admin.ID, "target_user_id", target.ID)
+		return nil, nil, err
+	}
+
+	session.Fresh = true
+	// Report the admin with the same ID form as UserData
+	session.ImpersonatorID = admin.ID
+	return session, target, nil
+}
+
+// EndImpersonation deletes an impersonation session and returns the admin
+// session it was started from. Returns ErrNotImpersonating for regular sessions.
+func (m *AuthManager) EndImpersonation(ctx context.Context, sessionID string) (*Session, *UserData, error) {
+	session, err := m.sessionAdapter.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, nil, ErrSessionNotFound
+	}
+	if !session.IsImpersonation() {
+		return nil, nil, ErrNotImpersonating
+	}
+
+	if err := m.sessionAdapter.DeleteSession(ctx, sessionID); err != nil {
+		logger.Error("Erro ao encerrar sessão de impersonação", "error", err, "session_id", sessionID)
+		return nil, nil, err
+	}
+
+	return m.ValidateSession(ctx, session.ImpersonatorSessionID)
+}
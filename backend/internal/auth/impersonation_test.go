@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newImpersonationTestManager returns a manager where "testuser" (ID 1) is an
+// admin and users 2 (regular), 3 (admin) and 4 (inactive) can be impersonated
+func newImpersonationTestManager(t *testing.T) (*AuthManager, *fakeSessionAdapter, *Session) {
+	t.Helper()
+
+	config := DefaultAuthConfig()
+	config.ImpersonationDuration = 10 * time.Minute
+	m, users, sessions := newTestAuthManager(config)
+	users.user.Role = AdminRole
+	users.others = map[string]UserData{
+		"2": {ID: "2", Identifier: "target", Role: "user", Active: true},
+		"3": {ID: "3", Identifier: "other-admin", Role: AdminRole, Active: true},
+		"4": {ID: "4", Identifier: "inactive", Role: "user", Active: false},
+	}
+
+	adminSession, _, err := m.Login(context.Background(), "testuser", "Password123!", SessionMetadata{})
+	require.NoError(t, err)
+	return m, sessions, adminSession
+}
+
+func TestAuthManager_Impersonate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Carries both identities", func(t *testing.T) {
+		m, _, adminSession := newImpersonationTestManager(t)
+
+		session, user, err := m.Impersonate(ctx, adminSession.ID, "2", SessionMetadata{IP: "127.0.0.1"})
+		require.NoError(t, err)
+		assert.Equal(t, "2", session.UserID)
+		assert.Equal(t, "2", user.ID)
+		assert.Equal(t, "1", session.ImpersonatorID)
+		assert.True(t, session.IsImpersonation())
+		assert.WithinDuration(t, time.Now().Add(10*time.Minute), session.ExpiresAt, time.Second)
+
+		// The session validates as the target user
+		validated, validatedUser, err := m.ValidateSession(ctx, session.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "2", validatedUser.ID)
+		assert.Equal(t, "1", validated.ImpersonatorID)
+	})
+
+	t.Run("Admin only", func(t *testing.T) {
+		m, sessions, _ := newImpersonationTestManager(t)
+
+		userSession, err := sessions.CreateSession(ctx, "2", time.Now().Add(time.Hour), SessionMetadata{})
+		require.NoError(t, err)
+
+		_, _, err = m.Impersonate(ctx, userSession.ID, "4", SessionMetadata{})
+		assert.ErrorIs(t, err, ErrImpersonationForbidden)
+	})
+
+	t.Run("Forbidden targets", func(t *testing.T) {
+		m, _, adminSession := newImpersonationTestManager(t)
+
+		_, _, err := m.Impersonate(ctx, adminSession.ID, "1", SessionMetadata{})
+		assert.ErrorIs(t, err, ErrImpersonationForbidden, "self")
+
+		_, _, err = m.Impersonate(ctx, adminSession.ID, "3", SessionMetadata{})
+		assert.ErrorIs(t, err, ErrImpersonationForbidden, "another admin")
+
+		_, _, err = m.Impersonate(ctx, adminSession.ID, "4", SessionMetadata{})
+		assert.ErrorIs(t, err, ErrUserNotActive)
+	})
+
+	t.Run("No nested impersonation", func(t *testing.T) {
+		m, _, adminSession := newImpersonationTestManager(t)
+
+		session, _, err := m.Impersonate(ctx, adminSession.ID, "2", SessionMetadata{})
+		require.NoError(t, err)
+
+		_, _, err = m.Impersonate(ctx, session.ID, "4", SessionMetadata{})
+		assert.ErrorIs(t, err, ErrImpersonationForbidden)
+	})
+
+	t.Run("Never outlives the admin session", func(t *testing.T) {
+		m, sessions, adminSession := newImpersonationTestManager(t)
+		m.config.RefreshThreshold = 0
+		adminExpiry := time.Now().Add(2 * time.Minute)
+		sessions.sessions[adminSession.ID].ExpiresAt = adminExpiry
+
+		session, _, err := m.Impersonate(ctx, adminSession.ID, "2", SessionMetadata{})
+		require.NoError(t, err)
+		assert.False(t, session.ExpiresAt.After(adminExpiry))
+	})
+
+	t.Run("Not refreshed", func(t *testing.T) {
+		m, sessions, adminSession := newImpersonationTestManager(t)
+
+		session, _, err := m.Impersonate(ctx, adminSession.ID, "2", SessionMetadata{})
+		require.NoError(t, err)
+
+		// Inside the refresh window a regular session would be extended
+		nearExpiry := time.Now().Add(time.Minute)
+		sessions.sessions[session.ID].ExpiresAt = nearExpiry
+
+		validated, _, err := m.ValidateSession(ctx, session.ID)
+		require.NoError(t, err)
+		assert.False(t, validated.Fresh)
+		assert.Equal(t, nearExpiry, sessions.sessions[session.ID].ExpiresAt)
+	})
+}
+
+func TestAuthManager_Impersonate_Policy(t *testing.T) {
+	ctx := context.Background()
+	m, _, adminSession := newImpersonationTestManager(t)
+
+	// A configured role gets impersonation from its permissions, not its name
+	m.config.Permissions = NewPolicy([]Role{
+		{Name: AdminRole, Permissions: []string{"users:read"}},
+		{Name: "support", Permissions: []string{PermissionImpersonate}},
+	})
+	_, _, err := m.Impersonate(ctx, adminSession.ID, "2", SessionMetadata{})
+	assert.ErrorIs(t, err, ErrImpersonationForbidden, "admin without the permission")
+
+	users := m.userAdapter.(*fakeUserAdapter)
+	users.user.Role = "support"
+	users.others["5"] = UserData{ID: "5", Identifier: "other-support", Role: "support", Active: true}
+	session, user, err := m.Impersonate(ctx, adminSession.ID, "2", SessionMetadata{})
+	require.NoError(t, err)
+	assert.Equal(t, "2", user.ID)
+	assert.Equal(t, "1", session.ImpersonatorID)
+
+	_, _, err = m.Impersonate(ctx, adminSession.ID, "5", SessionMetadata{})
+	assert.ErrorIs(t, err, ErrImpersonationForbidden, "targets who may impersonate too")
+}
+
+func TestAuthManager_EndImpersonation(t *testing.T) {
+	ctx := context.Background()
+	m, sessions, adminSession := newImpersonationTestManager(t)
+
+	session, _, err := m.Impersonate(ctx, adminSession.ID, "2", SessionMetadata{})
+	require.NoError(t, err)
+
+	restored, admin, err := m.EndImpersonation(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, adminSession.ID, restored.ID)
+	assert.Equal(t, "1", admin.ID)
+	assert.NotContains(t, sessions.sessions, session.ID)
+
+	// The admin's own session is not an impersonation
+	_, _, err = m.EndImpersonation(ctx, adminSession.ID)
+	assert.ErrorIs(t, err, ErrNotImpersonating)
+}
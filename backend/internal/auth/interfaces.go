@@ -8,6 +8,7 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"time"
 )
@@ -16,8 +17,41 @@ import (
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserNotActive      = errors.New("user not active")
+	ErrUserNotFound       = errors.New("user not found")
 	ErrSessionNotFound    = errors.New("session not found")
 	ErrSessionExpired     = errors.New("session expired")
+
+	ErrInvalidRecoveryCode      = errors.New("invalid recovery code")
+	ErrRecoveryCodesUnsupported = errors.New("user adapter does not support recovery codes")
+	ErrSessionListUnsupported   = errors.New("session adapter does not support listing sessions")
+	ErrPasswordReused           = errors.New("password was used recently")
+	ErrImpersonationForbidden   = errors.New("impersonation not allowed")
+	ErrNotImpersonating         = errors.New("session is not an impersonation session")
+	ErrInvalidSMSCode           = errors.New("invalid or expired sms code")
+	ErrSMSCodeRateLimited       = errors.New("too many sms codes requested")
+	ErrSMSCodesUnsupported      = errors.New("user adapter does not support sms codes")
+	ErrAccountPendingDeletion   = errors.New("account is scheduled for deletion")
+	ErrEmailNotVerified         = errors.New("email address not verified")
+
+	ErrTwoFactorRequired              = errors.New("two-factor authentication required")
+	ErrInvalidTwoFactorCode           = errors.New("invalid two-factor code")
+	ErrInvalidTwoFactorChallenge      = errors.New("invalid or expired two-factor challenge")
+	ErrTOTPNotEnrolled                = errors.New("totp not enrolled")
+	ErrTOTPAlreadyEnabled             = errors.New("totp already enabled")
+	ErrTOTPUnsupported                = errors.New("user adapter does not support totp")
+	ErrTwoFactorChallengesUnsupported = errors.New("session adapter does not support two-factor challenges")
+
+	ErrInvalidRefreshToken      = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused       = errors.New("refresh token reused")
+	ErrRefreshTokensUnsupported = errors.New("session adapter does not support refresh tokens")
+
+	ErrInvalidAPIKey      = errors.New("invalid or expired api key")
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+	ErrAPIKeysUnsupported = errors.New("user adapter does not support api keys")
+
+	ErrInvalidMagicLink      = errors.New("invalid or expired magic link")
+	ErrMagicLinkRateLimited  = errors.New("too many magic links requested")
+	ErrMagicLinksUnsupported = errors.New("user adapter does not support magic links")
 )
 
 // UserData represents generic user data (database-agnostic)
@@ -31,21 +65,115 @@ type UserData struct {
 	Attributes  map[string]any `json:"attributes,omitempty"` // extra fields
 }
 
+// AttrMustChangePassword is the UserData attribute set to true when the user
+// has to change their password before using the rest of the API
+const AttrMustChangePassword = "must_change_password"
+
+// AttrDeletionScheduledAt is the UserData attribute holding when the account
+// will be purged, absent unless the user asked to delete it
+const AttrDeletionScheduledAt = "scheduled_deletion_at"
+
+// AttrEmailVerified is the UserData attribute set to false until the user
+// verifies their email address
+const AttrEmailVerified = "email_verified"
+
+// AttrTenantID is the UserData attribute holding the user's tenant, absent
+// for the default tenant
+const AttrTenantID = "tenant_id"
+
+// AttrLocale is the UserData attribute holding the user's preferred email
+// language, absent when they have none
+const AttrLocale = "locale"
+
+// AttrTwoFactorMethod is the UserData attribute holding the second factor
+// the user chose, absent when they have none
+const AttrTwoFactorMethod = "two_factor_method"
+
+// AttrAvatarKey is the UserData attribute holding the storage key of the
+// user's avatar, absent when they have none
+const AttrAvatarKey = "avatar_key"
+
+// AvatarKey returns the storage key of the user's avatar, "" without one
+func (u *UserData) AvatarKey() string {
+	key, _ := u.Attributes[AttrAvatarKey].(string)
+	return key
+}
+
+// TwoFactorMethod returns the second factor the user chose
+// (TwoFactorMethodTOTP or TwoFactorMethodSMS), "" when they have none
+func (u *UserData) TwoFactorMethod() string {
+	method, _ := u.Attributes[AttrTwoFactorMethod].(string)
+	return method
+}
+
+// Locale returns the preferred email language of the user, "" for the default
+func (u *UserData) Locale() string {
+	locale, _ := u.Attributes[AttrLocale].(string)
+	return locale
+}
+
+// TenantID returns the tenant of the user, "" for the default tenant
+func (u *UserData) TenantID() string {
+	id, _ := u.Attributes[AttrTenantID].(string)
+	return id
+}
+
+// MustChangePassword reports whether the user has to change their password
+func (u *UserData) MustChangePassword() bool {
+	required, _ := u.Attributes[AttrMustChangePassword].(bool)
+	return required
+}
+
+// EmailVerified reports whether the user verified their email address.
+// Adapters that don't track verification leave the attribute out, and their
+// users count as verified.
+func (u *UserData) EmailVerified() bool {
+	verified, ok := u.Attributes[AttrEmailVerified].(bool)
+	return verified || !ok
+}
+
+// DeletionScheduled reports whether the user asked to delete their account.
+// Such users can't log in until the deletion is cancelled.
+func (u *UserData) DeletionScheduled() bool {
+	_, scheduled := u.Attributes[AttrDeletionScheduledAt].(time.Time)
+	return scheduled
+}
+
 // Session represents an authentication session
 type Session struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	ExpiresAt time.Time `json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
-	UserAgent string    `json:"user_agent,omitempty"`
-	IP        string    `json:"ip,omitempty"`
-	Fresh     bool      `json:"fresh"` // true if just created or refreshed
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	// ImpersonatorID is set when an admin acts as UserID: it is the real
+	// identity behind the session
+	ImpersonatorID string `json:"impersonator_id,omitempty"`
+	// ImpersonatorSessionID is the admin session restored when impersonation ends
+	ImpersonatorSessionID string `json:"-"`
+	UserAgent             string `json:"user_agent,omitempty"`
+	IP                    string `json:"ip,omitempty"`
+	Fresh                 bool   `json:"fresh"` // true if just created or refreshed
+
+	// RefreshToken is set, in plaintext, only on the session it was issued
+	// with (see AuthConfig.RefreshTokenDuration)
+	RefreshToken          string    `json:"-"`
+	RefreshTokenExpiresAt time.Time `json:"-"`
+}
+
+// IsImpersonation reports whether an admin is acting as the session's user
+func (s *Session) IsImpersonation() bool {
+	return s.ImpersonatorID != ""
 }
 
 // SessionMetadata contains metadata for session creation
 type SessionMetadata struct {
 	UserAgent string
 	IP        string
+
+	// Set only for impersonation sessions
+	ImpersonatorID        string
+	ImpersonatorSessionID string
 }
 
 // CreateUserInput contains data for creating a new user
@@ -54,56 +182,316 @@ type CreateUserInput struct {
 	Email       string
 	Password    string
 	DisplayName string
+	Locale      string // preferred email language, empty for the default
 	Attributes  map[string]any
 }
 
-// UserAdapter is the interface that any user database must implement
+// UserAdapter is the interface that any user database must implement.
+//
+// Every method receives the request context so implementations can abort
+// database work when the client goes away.
 type UserAdapter interface {
 	// FindUserByIdentifier looks up user by identifier (username, email, etc)
-	FindUserByIdentifier(identifier string) (*UserData, error)
+	FindUserByIdentifier(ctx context.Context, identifier string) (*UserData, error)
 
 	// FindUserByID looks up user by ID
-	FindUserByID(id string) (*UserData, error)
+	FindUserByID(ctx context.Context, id string) (*UserData, error)
 
 	// ValidateCredentials validates credentials and returns user if valid
-	ValidateCredentials(identifier, password string) (*UserData, error)
+	ValidateCredentials(ctx context.Context, identifier, password string) (*UserData, error)
 
 	// CreateUser creates a new user (optional for legacy systems)
-	CreateUser(data CreateUserInput) (*UserData, error)
+	CreateUser(ctx context.Context, data CreateUserInput) (*UserData, error)
 
 	// UpdatePassword updates the password (optional for legacy systems)
-	UpdatePassword(userID string, newPassword string) error
+	UpdatePassword(ctx context.Context, userID string, newPassword string) error
 }
 
 // SessionAdapter manages authentication sessions
 type SessionAdapter interface {
 	// CreateSession creates a new session for the user
-	CreateSession(userID string, expiresAt time.Time, metadata SessionMetadata) (*Session, error)
+	CreateSession(ctx context.Context, userID string, expiresAt time.Time, metadata SessionMetadata) (*Session, error)
 
 	// GetSession retrieves a session by ID
-	GetSession(sessionID string) (*Session, error)
+	GetSession(ctx context.Context, sessionID string) (*Session, error)
 
 	// UpdateSessionExpiry updates session expiration time
-	UpdateSessionExpiry(sessionID string, expiresAt time.Time) error
+	UpdateSessionExpiry(ctx context.Context, sessionID string, expiresAt time.Time) error
+
+	// UpdateSessionLastUsed records activity on a session (idle timeout)
+	UpdateSessionLastUsed(ctx context.Context, sessionID string, lastUsedAt time.Time) error
 
 	// DeleteSession removes a session (logout)
-	DeleteSession(sessionID string) error
+	DeleteSession(ctx context.Context, sessionID string) error
 
 	// DeleteUserSessions removes all sessions for a user
-	DeleteUserSessions(userID string) error
+	DeleteUserSessions(ctx context.Context, userID string) error
 
-	// DeleteExpiredSessions cleans up expired sessions
-	DeleteExpiredSessions() error
+	// DeleteExpiredSessions removes up to limit expired sessions and returns
+	// how many, so a large backlog is cleaned up in batches: callers repeat
+	// until fewer than limit are removed
+	DeleteExpiredSessions(ctx context.Context, limit int) (int64, error)
 }
 
 // PasswordResetAdapter optional interface for password reset functionality
 type PasswordResetAdapter interface {
 	// SetResetToken stores a password reset token for a user
-	SetResetToken(userID string, hashedToken string, expiresAt time.Time) error
+	SetResetToken(ctx context.Context, userID string, hashedToken string, expiresAt time.Time) error
 
 	// GetUserByResetToken finds user by reset token hash
-	GetUserByResetToken(hashedToken string) (*UserData, error)
+	GetUserByResetToken(ctx context.Context, hashedToken string) (*UserData, error)
 
 	// ClearResetToken clears the reset token after use
-	ClearResetToken(userID string) error
+	ClearResetToken(ctx context.Context, userID string) error
+}
+
+// RecoveryCodeAdapter optional interface for 2FA recovery codes.
+// A UserAdapter that also implements it enables AuthManager's recovery code methods.
+type RecoveryCodeAdapter interface {
+	// ReplaceRecoveryCodes stores a new set of hashed codes, invalidating the previous ones
+	ReplaceRecoveryCodes(ctx context.Context, userID string, hashedCodes []string) error
+
+	// ConsumeRecoveryCode marks an unused code as used. Returns false if no unused code matches.
+	ConsumeRecoveryCode(ctx context.Context, userID string, hashedCode string) (bool, error)
+
+	// CountRecoveryCodes returns how many unused codes the user has left
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+}
+
+// SMSCodeAdapter optional interface for short-lived codes sent by SMS.
+// A UserAdapter that also implements it enables AuthManager's SMS code methods.
+type SMSCodeAdapter interface {
+	// CreateSMSCode stores a hashed code, invalidating the user's pending codes
+	// for the same purpose
+	CreateSMSCode(ctx context.Context, userID, purpose, hashedCode string, expiresAt time.Time) error
+
+	// SMSCodesSentSince returns when codes (of any purpose) were issued to the
+	// user after since, newest first
+	SMSCodesSentSince(ctx context.Context, userID string, since time.Time) ([]time.Time, error)
+
+	// ConsumeSMSCode checks hashedCode against the user's pending, unexpired code
+	// for purpose and marks it as used on a match. A mismatch counts as an
+	// attempt; codes with maxAttempts failed attempts are no longer accepted.
+	ConsumeSMSCode(ctx context.Context, userID, purpose, hashedCode string, maxAttempts int) (bool, error)
+}
+
+// TOTPSecret is a user's authenticator app secret
+type TOTPSecret struct {
+	Secret    string // base32, without padding
+	Confirmed bool
+	// LastUsedStep is the time step of the last accepted code
+	LastUsedStep int64
+}
+
+// TOTPAdapter optional interface for TOTP (authenticator app) 2FA.
+// A UserAdapter that also implements it enables AuthManager's TOTP methods;
+// users with a confirmed secret then log in through a two-factor challenge.
+type TOTPAdapter interface {
+	// SaveTOTPSecret stores an unconfirmed secret, replacing the user's previous one
+	SaveTOTPSecret(ctx context.Context, userID, secret string) error
+
+	// GetTOTPSecret returns the user's secret, or ErrTOTPNotEnrolled
+	GetTOTPSecret(ctx context.Context, userID string) (*TOTPSecret, error)
+
+	// ConfirmTOTPSecret marks the user's secret as confirmed
+	ConfirmTOTPSecret(ctx context.Context, userID string) error
+
+	// UseTOTPStep records step as the last one used, unless it isn't newer than
+	// the current one. Returns false for a code already used.
+	UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error)
+
+	// DeleteTOTPSecret removes the user's secret
+	DeleteTOTPSecret(ctx context.Context, userID string) error
+}
+
+// PasswordHistoryAdapter optional interface for preventing password reuse.
+// A UserAdapter that also implements it enables AuthConfig.PasswordHistoryDepth.
+type PasswordHistoryAdapter interface {
+	// IsRecentPassword reports whether password matches the current password
+	// or one of the previous depth-1 ones
+	IsRecentPassword(ctx context.Context, userID string, password string, depth int) (bool, error)
+
+	// UpdatePasswordWithHistory changes the password, keeping the replaced hash
+	// in the history and pruning it to the newest keep entries
+	UpdatePasswordWithHistory(ctx context.Context, userID string, newPassword string, keep int) error
+}
+
+// SessionListAdapter optional interface for listing a user's sessions.
+// A SessionAdapter that also implements it enables AuthManager.ListSessions.
+type SessionListAdapter interface {
+	// ListUserSessions returns all sessions of a user, newest first
+	ListUserSessions(ctx context.Context, userID string) ([]*Session, error)
+}
+
+// TwoFactorChallenge is a login waiting for the second factor
+type TwoFactorChallenge struct {
+	UserID    string
+	ExpiresAt time.Time
+	Attempts  int             // wrong codes so far
+	Metadata  SessionMetadata // of the login, given to the session
+}
+
+// TwoFactorChallengeAdapter optional interface for pending two-factor logins.
+// A SessionAdapter that also implements it enables the two-factor challenge
+// of AuthManager.Login; challenges are looked up by the hash of their token.
+type TwoFactorChallengeAdapter interface {
+	// CreateTwoFactorChallenge stores a challenge
+	CreateTwoFactorChallenge(ctx context.Context, hashedToken string, challenge TwoFactorChallenge) error
+
+	// GetTwoFactorChallenge returns a challenge, or ErrInvalidTwoFactorChallenge
+	GetTwoFactorChallenge(ctx context.Context, hashedToken string) (*TwoFactorChallenge, error)
+
+	// RecordTwoFactorAttempt counts a wrong code against the challenge
+	RecordTwoFactorAttempt(ctx context.Context, hashedToken string) error
+
+	// DeleteTwoFactorChallenge removes a challenge. Returns false if it was
+	// already gone, so concurrent completions succeed only once.
+	DeleteTwoFactorChallenge(ctx context.Context, hashedToken string) (bool, error)
+}
+
+// RefreshToken is a stored refresh token. Tokens rotated from the same login
+// share FamilyID.
+type RefreshToken struct {
+	FamilyID  string
+	SessionID string // the session issued with the token
+	UserID    string
+	ExpiresAt time.Time
+	Used      bool // already exchanged by AuthManager.Refresh
+}
+
+// RefreshTokenAdapter optional interface for rotating refresh tokens. A
+// SessionAdapter that also implements it enables AuthManager.Refresh; tokens
+// are looked up by the hash of their value.
+type RefreshTokenAdapter interface {
+	// CreateRefreshToken stores a refresh token
+	CreateRefreshToken(ctx context.Context, hashedToken string, token RefreshToken) error
+
+	// GetRefreshToken returns a refresh token, used or not, or
+	// ErrInvalidRefreshToken
+	GetRefreshToken(ctx context.Context, hashedToken string) (*RefreshToken, error)
+
+	// MarkRefreshTokenUsed marks a token as used. Returns false if it
+	// already was, so concurrent refreshes succeed only once.
+	MarkRefreshTokenUsed(ctx context.Context, hashedToken string) (bool, error)
+
+	// RevokeRefreshTokenFamily deletes every token of a family and the
+	// sessions issued with them
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+}
+
+// APIKey is a stored API key, without its secret
+type APIKey struct {
+	ID         string
+	UserID     string
+	Name       string
+	Scopes     []string
+	ExpiresAt  *time.Time // nil never expires
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// APIKeyAdapter optional interface for API keys. A UserAdapter that also
+// implements it enables AuthManager's API key methods; secrets are compared
+// by their hash.
+type APIKeyAdapter interface {
+	// CreateAPIKey stores key for its UserID
+	CreateAPIKey(ctx context.Context, key APIKey, hashedSecret string) error
+
+	// GetAPIKey returns a key with the hash of its secret, or
+	// ErrAPIKeyNotFound
+	GetAPIKey(ctx context.Context, id string) (*APIKey, string, error)
+
+	// ListAPIKeys returns the keys of the user, newest first
+	ListAPIKeys(ctx context.Context, userID string) ([]*APIKey, error)
+
+	// DeleteAPIKey removes a key of the user, or returns ErrAPIKeyNotFound
+	DeleteAPIKey(ctx context.Context, userID, id string) error
+
+	// UpdateAPIKeyLastUsed records a use of the key
+	UpdateAPIKeyLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error
+}
+
+// MagicLink is a pending passwordless login link, without its token
+type MagicLink struct {
+	UserID    string
+	Email     string // address the link was sent to
+	ExpiresAt time.Time
+}
+
+// MagicLinkAdapter optional interface for passwordless login links. A
+// UserAdapter that also implements it enables AuthManager's magic link
+// methods; tokens are looked up by their hash.
+type MagicLinkAdapter interface {
+	// CreateMagicLink stores a link, invalidating the pending links of its user
+	CreateMagicLink(ctx context.Context, hashedToken string, link MagicLink) error
+
+	// MagicLinksSentSince returns when links were issued to the user after
+	// since, newest first
+	MagicLinksSentSince(ctx context.Context, userID string, since time.Time) ([]time.Time, error)
+
+	// ConsumeMagicLink marks a pending, unexpired link as used and returns it,
+	// or ErrInvalidMagicLink. Concurrent use of a link succeeds only once.
+	ConsumeMagicLink(ctx context.Context, hashedToken string) (*MagicLink, error)
+}
+
+// SessionFilter narrows AuthManager.ListAllSessions. Zero fields match
+// everything.
+type SessionFilter struct {
+	UserID        string
+	IP            string
+	CreatedAfter  time.Time // inclusive
+	CreatedBefore time.Time // exclusive
+
+	// ActiveAt keeps only sessions not expired at this time
+	ActiveAt time.Time
+}
+
+// SessionListAllAdapter optional interface for listing the sessions of all
+// users. A SessionAdapter that also implements it enables
+// AuthManager.ListAllSessions.
+type SessionListAllAdapter interface {
+	// ListAll returns a page of the sessions matching filter, newest first,
+	// and how many match in total
+	ListAll(ctx context.Context, filter SessionFilter, offset, limit int) ([]*Session, int64, error)
+}
+
+// Kinds of failed login counters
+const (
+	LoginAttemptsAccount = "account" // keyed by the identifier logged in with
+	LoginAttemptsIP      = "ip"      // keyed by the client IP
+)
+
+// LoginAttempts is a failed login counter
+type LoginAttempts struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedAt      time.Time // zero until the limit is reached
+	ExpiresAt     time.Time // the counter may be pruned after this
+}
+
+// Lockout is a counter locked by too many failed logins
+type Lockout struct {
+	Kind        string // LoginAttemptsAccount or LoginAttemptsIP
+	Key         string
+	Failures    int
+	LockedAt    time.Time
+	LockedUntil time.Time // set by AuthManager.Lockouts
+}
+
+// LoginAttemptAdapter optional interface for storing failed login counters,
+// so lockouts survive restarts and hold across instances. A UserAdapter that
+// also implements it is used unless AuthConfig.LoginAttemptsInMemory is set.
+type LoginAttemptAdapter interface {
+	// GetLoginAttempts returns a counter, zero if there is none
+	GetLoginAttempts(ctx context.Context, kind, key string) (LoginAttempts, error)
+
+	// SaveLoginAttempts creates or replaces a counter
+	SaveLoginAttempts(ctx context.Context, kind, key string, attempts LoginAttempts) error
+
+	// DeleteLoginAttempts removes a counter. Missing counters are not an error.
+	DeleteLoginAttempts(ctx context.Context, kind, key string) error
+
+	// ListLoginLockouts returns the counters locked at or after since, most
+	// recent first
+	ListLoginLockouts(ctx context.Context, since time.Time) ([]Lockout, error)
 }
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gosveltekit/internal/logger"
+)
+
+// Lockouts returns the identifiers and client IPs currently locked out by
+// failed logins, most recent first
+func (m *AuthManager) Lockouts(ctx context.Context) ([]Lockout, error) {
+	since := m.config.Clock.Now().Add(-m.config.LockoutDuration)
+	lockouts, err := m.loginAttempts.ListLoginLockouts(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lockouts {
+		lockouts[i].LockedUntil = lockouts[i].LockedAt.Add(m.config.LockoutDuration)
+	}
+	return lockouts, nil
+}
+
+// Unlock forgets the failed logins of an identifier or client IP, lifting its
+// lockout. kind is LoginAttemptsAccount or LoginAttemptsIP.
+func (m *AuthManager) Unlock(ctx context.Context, kind, key string) error {
+	m.failedAttemptsMutex.Lock()
+	defer m.failedAttemptsMutex.Unlock()
+	return m.loginAttempts.DeleteLoginAttempts(ctx, kind, key)
+}
+
+// UnlockUser lifts every lockout of a user: logins with their username or
+// email, and their two-factor codes
+func (m *AuthManager) UnlockUser(ctx context.Context, userID string) error {
+	user, err := m.userAdapter.FindUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{user.Identifier, twoFactorLockKey(user.ID)}
+	if user.Email != "" {
+		keys = append(keys, user.Email)
+	}
+	for _, key := range keys {
+		if err := m.Unlock(ctx, LoginAttemptsAccount, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LockedUntil returns when the lockout of identifier ends, or the zero time
+// if it isn't locked
+func (m *AuthManager) LockedUntil(ctx context.Context, identifier string) time.Time {
+	attempts := m.getLoginAttempts(ctx, LoginAttemptsAccount, identifier)
+	if !m.lockActive(attempts) {
+		return time.Time{}
+	}
+	return attempts.LockedAt.Add(m.config.LockoutDuration)
+}
+
+func (m *AuthManager) isAccountLocked(ctx context.Context, identifier string) bool {
+	return m.lockActive(m.getLoginAttempts(ctx, LoginAttemptsAccount, identifier))
+}
+
+func (m *AuthManager) isIPLocked(ctx context.Context, ip string) bool {
+	if ip == "" || m.config.MaxFailedAttemptsPerIP <= 0 {
+		return false
+	}
+	return m.lockActive(m.getLoginAttempts(ctx, LoginAttemptsIP, ip))
+}
+
+// lockActive reports whether attempts are locked and the lockout hasn't expired
+func (m *AuthManager) lockActive(attempts LoginAttempts) bool {
+	if attempts.LockedAt.IsZero() {
+		return false
+	}
+	return m.config.Clock.Now().Sub(attempts.LockedAt) <= m.config.LockoutDuration
+}
+
+// recordFailedAttempt counts a failure against identifier and returns its
+// failures so far, and whether this one locked the account
+func (m *AuthManager) recordFailedAttempt(ctx context.Context, identifier string) (int, bool) {
+	m.failedAttemptsMutex.Lock()
+	defer m.failedAttemptsMutex.Unlock()
+
+	prev := m.getLoginAttempts(ctx, LoginAttemptsAccount, identifier)
+	attempts := m.countFailure(prev, m.config.MaxFailedAttempts)
+	m.saveLoginAttempts(ctx, LoginAttemptsAccount, identifier, attempts)
+	return attempts.Failures, !attempts.LockedAt.IsZero() && !m.lockActive(prev)
+}
+
+// recordFailedAttemptFromIP counts a failure against ip and returns the
+// failures it has within the lockout window. Without an IP, or with the
+// per-IP limit disabled, nothing is counted.
+func (m *AuthManager) recordFailedAttemptFromIP(ctx context.Context, ip string) int {
+	if ip == "" || m.config.MaxFailedAttemptsPerIP <= 0 {
+		return 0
+	}
+
+	m.failedAttemptsMutex.Lock()
+	defer m.failedAttemptsMutex.Unlock()
+
+	attempts := m.getLoginAttempts(ctx, LoginAttemptsIP, ip)
+	if !attempts.LastFailureAt.IsZero() && m.config.Clock.Now().Sub(attempts.LastFailureAt) > m.config.LockoutDuration {
+		attempts = LoginAttempts{}
+	}
+	attempts = m.countFailure(attempts, m.config.MaxFailedAttemptsPerIP)
+	m.saveLoginAttempts(ctx, LoginAttemptsIP, ip, attempts)
+	return attempts.Failures
+}
+
+// countFailure adds a failure to attempts, locking them once limit is reached
+func (m *AuthManager) countFailure(attempts LoginAttempts, limit int) LoginAttempts {
+	attempts.Failures++
+	attempts.LastFailureAt = m.config.Clock.Now()
+	attempts.ExpiresAt = attempts.LastFailureAt.Add(m.config.LockoutDuration)
+
+	if attempts.Failures >= limit {
+		attempts.LockedAt = attempts.LastFailureAt
+	}
+	return attempts
+}
+
+func (m *AuthManager) clearFailedAttempts(ctx context.Context, identifier string) {
+	m.failedAttemptsMutex.Lock()
+	defer m.failedAttemptsMutex.Unlock()
+
+	if err := m.loginAttempts.DeleteLoginAttempts(ctx, LoginAttemptsAccount, identifier); err != nil {
+		logger.Error("Erro ao limpar tentativas de login", "error", err, "kind", LoginAttemptsAccount)
+	}
+}
+
+// getLoginAttempts reads a counter. A failing store counts as no failures:
+// logins keep working while it is down.
+func (m *AuthManager) getLoginAttempts(ctx context.Context, kind, key string) LoginAttempts {
+	attempts, err := m.loginAttempts.GetLoginAttempts(ctx, kind, key)
+	if err != nil {
+		logger.Error("Erro ao ler tentativas de login", "error", err, "kind", kind)
+		return LoginAttempts{}
+	}
+	return attempts
+}
+
+func (m *AuthManager) saveLoginAttempts(ctx context.Context, kind, key string, attempts LoginAttempts) {
+	if err := m.loginAttempts.SaveLoginAttempts(ctx, kind, key, attempts); err != nil {
+		logger.Error("Erro ao registrar tentativa de login", "error", err, "kind", kind)
+	}
+}
+
+// memoryLoginAttempts keeps failed login counters in the process, when the
+// UserAdapter doesn't store them or AuthConfig.LoginAttemptsInMemory is set
+type memoryLoginAttempts struct {
+	mu       sync.RWMutex
+	attempts map[string]map[string]LoginAttempts // by kind, then key
+}
+
+func newMemoryLoginAttempts() *memoryLoginAttempts {
+	return &memoryLoginAttempts{attempts: make(map[string]map[string]LoginAttempts)}
+}
+
+func (s *memoryLoginAttempts) GetLoginAttempts(ctx context.Context, kind, key string) (LoginAttempts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.attempts[kind][key], nil
+}
+
+func (s *memoryLoginAttempts) SaveLoginAttempts(ctx context.Context, kind, key string, attempts LoginAttempts) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.attempts[kind] == nil {
+		s.attempts[kind] = make(map[string]LoginAttempts)
+	}
+	s.attempts[kind][key] = attempts
+	return nil
+}
+
+func (s *memoryLoginAttempts) DeleteLoginAttempts(ctx context.Context, kind, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts[kind], key)
+	return nil
+}
+
+func (s *memoryLoginAttempts) ListLoginLockouts(ctx context.Context, since time.Time) ([]Lockout, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var lockouts []Lockout
+	for kind, byKey := range s.attempts {
+		for key, attempts := range byKey {
+			if attempts.LockedAt.IsZero() || attempts.LockedAt.Before(since) {
+				continue
+			}
+			lockouts = append(lockouts, Lockout{Kind: kind, Key: key, Failures: attempts.Failures, LockedAt: attempts.LockedAt})
+		}
+	}
+	sort.Slice(lockouts, func(i, j int) bool { return lockouts[i].LockedAt.After(lockouts[j].LockedAt) })
+	return lockouts, nil
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/tokens"
+)
+
+// magicLinkTokenBytes is the entropy of a magic link token
+const magicLinkTokenBytes = 32
+
+// IssueMagicLink generates a passwordless login token for the user, sent to
+// email, replacing any pending one. The plaintext token is returned for the
+// caller to send, with its expiry; only its hash is stored.
+//
+// Sending is rate limited per user: at most MagicLinksPerHour links per hour
+// and one every MagicLinkInterval, otherwise ErrMagicLinkRateLimited is
+// returned.
+func (m *AuthManager) IssueMagicLink(ctx context.Context, userID, email string) (string, time.Time, error) {
+	adapter, ok := m.userAdapter.(MagicLinkAdapter)
+	if !ok {
+		return "", time.Time{}, ErrMagicLinksUnsupported
+	}
+
+	now := m.config.Clock.Now()
+	sent, err := adapter.MagicLinksSentSince(ctx, userID, now.Add(-time.Hour))
+	if err != nil {
+		logger.Error("Erro ao consultar links de acesso enviados", "error", err, "user_id", userID)
+		return "", time.Time{}, err
+	}
+	if m.config.MagicLinksPerHour > 0 && len(sent) >= m.config.MagicLinksPerHour {
+		logger.Warn("Limite de links de acesso por hora atingido", "user_id", userID)
+		return "", time.Time{}, ErrMagicLinkRateLimited
+	}
+	if len(sent) > 0 && now.Sub(sent[0]) < m.config.MagicLinkInterval {
+		return "", time.Time{}, ErrMagicLinkRateLimited
+	}
+
+	token, err := tokens.Hex(m.config.Tokens, magicLinkTokenBytes)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	link := MagicLink{
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: now.Add(m.config.MagicLinkTTL),
+	}
+	if err := adapter.CreateMagicLink(ctx, hashToken(token), link); err != nil {
+		logger.Error("Erro ao salvar link de acesso", "error", err, "user_id", userID)
+		return "", time.Time{}, err
+	}
+
+	return token, link.ExpiresAt, nil
+}
+
+// ConsumeMagicLink checks a token issued by IssueMagicLink and invalidates it,
+// so each link logs in only once. Unknown, used, replaced and expired tokens
+// give ErrInvalidMagicLink. The caller creates the session, see LoginExternal.
+func (m *AuthManager) ConsumeMagicLink(ctx context.Context, token string) (*MagicLink, error) {
+	adapter, ok := m.userAdapter.(MagicLinkAdapter)
+	if !ok {
+		return nil, ErrMagicLinksUnsupported
+	}
+
+	link, err := adapter.ConsumeMagicLink(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Link de acesso utilizado", "user_id", link.UserID)
+	return link, nil
+}
@@ -0,0 +1,133 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+// GitHubAPIURL is the base URL of the GitHub REST API
+const GitHubAPIURL = "https://api.github.com"
+
+// GitHubProvider logs users in with GitHub. The email comes from the user's
+// email list, since the profile only shows the one they chose to make public.
+type GitHubProvider struct {
+	name   string
+	config oauth2.Config
+	apiURL string
+	client *http.Client
+}
+
+// NewGitHub returns a provider for GitHub accounts
+func NewGitHub(name, clientID, clientSecret, redirectURL string) *GitHubProvider {
+	return &GitHubProvider{
+		name: name,
+		config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     github.Endpoint,
+			Scopes:       []string{"read:user", "user:email"},
+		},
+		apiURL: GitHubAPIURL,
+		client: &http.Client{Timeout: httpTimeout, Transport: otelhttp.NewTransport(nil)},
+	}
+}
+
+// Name implements Provider
+func (p *GitHubProvider) Name() string {
+	return p.name
+}
+
+// AuthCodeURL implements Provider
+func (p *GitHubProvider) AuthCodeURL(ctx context.Context, state, verifier string) (string, error) {
+	return p.config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// githubUser is the part of GET /user used here
+type githubUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+// githubEmail is an entry of GET /user/emails
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// Exchange implements Provider. The identity carries the primary email if it
+// is verified, or else the first verified one.
+func (p *GitHubProvider) Exchange(ctx context.Context, code, verifier string) (*Identity, error) {
+	ctx = withClient(ctx, p.client)
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao trocar o código com %s: %w", p.name, err)
+	}
+	client := p.config.Client(ctx, token)
+
+	var user githubUser
+	if err := p.get(ctx, client, "/user", &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, fmt.Errorf("%s não informou o ID do usuário", p.name)
+	}
+	var emails []githubEmail
+	if err := p.get(ctx, client, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Provider: p.name,
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+	}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, email := range emails {
+		if !email.Verified {
+			continue
+		}
+		if identity.Email == "" || email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = true
+		}
+		if email.Primary {
+			break
+		}
+	}
+	return identity, nil
+}
+
+// get decodes the JSON answer of an API path
+func (p *GitHubProvider) get(ctx context.Context, client *http.Client, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro ao contatar a API do %s: %w", p.name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API do %s respondeu %s com status %d", p.name, path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("resposta inválida da API do %s: %w", p.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Package oauth implements social login with the OAuth 2.0 authorization code
+// flow and PKCE (RFC 7636).
+//
+// Google and generic issuers are served by OIDCProvider, which verifies the ID
+// token against the issuer's published keys. GitHub has no OpenID Connect
+// support, so GitHubProvider reads the identity from its REST API instead.
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"gosveltekit/internal/tokens"
+
+	"golang.org/x/oauth2"
+)
+
+// httpTimeout bounds every call made to a provider
+const httpTimeout = 10 * time.Second
+
+// Identity is the user as reported by a provider
+type Identity struct {
+	Provider string
+	// Subject is the provider's stable ID for the user; emails may change
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider runs the authorization code flow with one identity provider
+type Provider interface {
+	// Name identifies the provider in routes and linked identities
+	Name() string
+	// AuthCodeURL returns the provider page the browser is sent to. state
+	// must come back in the callback; verifier is the PKCE code verifier.
+	AuthCodeURL(ctx context.Context, state, verifier string) (string, error)
+	// Exchange trades the code received in the callback for the user's identity
+	Exchange(ctx context.Context, code, verifier string) (*Identity, error)
+}
+
+// NewState returns a random value from g for the state parameter
+func NewState(g tokens.Generator) (string, error) {
+	return tokens.Text(g, 32)
+}
+
+// NewVerifier returns a random PKCE code verifier from g: 32 bytes,
+// base64url-encoded, as oauth2.GenerateVerifier makes them
+func NewVerifier(g tokens.Generator) (string, error) {
+	return tokens.Text(g, 32)
+}
+
+// withClient makes the oauth2 and oidc packages use client for ctx
+func withClient(ctx context.Context, client *http.Client) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, client)
+}
@@ -0,0 +1,195 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/tokens"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// newVerifier returns a fresh PKCE code verifier
+func newVerifier(t *testing.T) string {
+	t.Helper()
+	verifier, err := NewVerifier(tokens.Secure{})
+	require.NoError(t, err)
+	return verifier
+}
+
+func TestNewState(t *testing.T) {
+	state, err := NewState(tokens.NewDeterministic("oauth"))
+	require.NoError(t, err)
+	again, err := NewState(tokens.NewDeterministic("oauth"))
+	require.NoError(t, err)
+	assert.Equal(t, state, again, "drawn from the generator")
+
+	verifier, err := NewVerifier(tokens.NewDeterministic("oauth"))
+	require.NoError(t, err)
+	assert.Len(t, verifier, len(oauth2.GenerateVerifier()))
+}
+
+// tokenEndpoint answers the code exchange, checking the PKCE verifier
+func tokenEndpoint(t *testing.T, verifier string, extra map[string]any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("code") != "good-code" || r.PostForm.Get("code_verifier") != verifier {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		body := map[string]any{"access_token": "access-token", "token_type": "Bearer", "expires_in": 3600}
+		for k, v := range extra {
+			body[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+func TestGitHubProvider(t *testing.T) {
+	verifier := newVerifier(t)
+	emails := `[{"email":"old@example.com","primary":false,"verified":true},{"email":"octo@example.com","primary":true,"verified":true}]`
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", tokenEndpoint(t, verifier, nil))
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer access-token", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"id":42,"login":"octocat","name":""}`))
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(emails))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p := NewGitHub("github", "client-id", "client-secret", "https://app.example.com/auth/oauth/github/callback")
+	p.config.Endpoint = oauth2.Endpoint{AuthURL: server.URL + "/login/oauth/authorize", TokenURL: server.URL + "/login/oauth/access_token"}
+	p.apiURL = server.URL
+
+	link, err := p.AuthCodeURL(context.Background(), "the-state", verifier)
+	require.NoError(t, err)
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, "the-state", u.Query().Get("state"))
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	assert.Equal(t, oauth2.S256ChallengeFromVerifier(verifier), u.Query().Get("code_challenge"))
+
+	identity, err := p.Exchange(context.Background(), "good-code", verifier)
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Provider: "github", Subject: "42", Email: "octo@example.com", EmailVerified: true, Name: "octocat"}, identity)
+
+	// Unverified emails are never reported as the user's
+	emails = `[{"email":"octo@example.com","primary":true,"verified":false}]`
+	identity, err = p.Exchange(context.Background(), "good-code", verifier)
+	require.NoError(t, err)
+	assert.Empty(t, identity.Email)
+	assert.False(t, identity.EmailVerified)
+
+	_, err = p.Exchange(context.Background(), "good-code", newVerifier(t))
+	assert.Error(t, err, "the code is bound to the verifier")
+}
+
+// oidcIssuer is a minimal OpenID Connect issuer signing ID tokens with key
+type oidcIssuer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]any
+}
+
+func newOIDCIssuer(t *testing.T, verifier string) *oidcIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	issuer := &oidcIssuer{key: key}
+
+	mux := http.NewServeMux()
+	issuer.Server = httptest.NewServer(mux)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                issuer.URL,
+			"authorization_endpoint":                issuer.URL + "/authorize",
+			"token_endpoint":                        issuer.URL + "/token",
+			"jwks_uri":                              issuer.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "k1", Algorithm: "RS256", Use: "sig"}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenEndpoint(t, verifier, map[string]any{"id_token": issuer.sign(t)})(w, r)
+	})
+	return issuer
+}
+
+func (i *oidcIssuer) sign(t *testing.T) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: i.key}, (&jose.SignerOptions{}).WithHeader("kid", "k1"))
+	require.NoError(t, err)
+	payload, err := json.Marshal(i.claims)
+	require.NoError(t, err)
+	signed, err := signer.Sign(payload)
+	require.NoError(t, err)
+	token, err := signed.CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestOIDCProvider(t *testing.T) {
+	verifier := newVerifier(t)
+	issuer := newOIDCIssuer(t, verifier)
+	defer issuer.Close()
+	now := time.Now()
+	issuer.claims = map[string]any{
+		"iss":            issuer.URL,
+		"aud":            "client-id",
+		"sub":            "user-123",
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+	}
+
+	p := NewOIDC("oidc", issuer.URL, "client-id", "client-secret", "https://app.example.com/auth/oauth/oidc/callback", nil)
+
+	link, err := p.AuthCodeURL(context.Background(), "the-state", verifier)
+	require.NoError(t, err)
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, issuer.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path, "the endpoint is discovered")
+	assert.Equal(t, "openid email profile", u.Query().Get("scope"))
+	assert.Equal(t, oauth2.S256ChallengeFromVerifier(verifier), u.Query().Get("code_challenge"))
+
+	identity, err := p.Exchange(context.Background(), "good-code", verifier)
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Provider: "oidc", Subject: "user-123", Email: "jane@example.com", EmailVerified: true, Name: "Jane Doe"}, identity)
+
+	// Tokens issued for another client are rejected
+	issuer.claims["aud"] = "someone-else"
+	_, err = p.Exchange(context.Background(), "good-code", verifier)
+	assert.Error(t, err)
+}
+
+func TestOIDCProvider_DiscoveryRetried(t *testing.T) {
+	p := NewOIDC("oidc", "http://127.0.0.1:1", "client-id", "client-secret", "https://app.example.com/callback", nil)
+	_, err := p.AuthCodeURL(context.Background(), "state", newVerifier(t))
+	require.Error(t, err)
+
+	verifier := newVerifier(t)
+	issuer := newOIDCIssuer(t, verifier)
+	defer issuer.Close()
+	p.issuerURL = issuer.URL
+	_, err = p.AuthCodeURL(context.Background(), "state", verifier)
+	assert.NoError(t, err, "a failed discovery is not cached")
+}
@@ -0,0 +1,131 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/oauth2"
+)
+
+// GoogleIssuer is the OpenID Connect issuer of Google accounts
+const GoogleIssuer = "https://accounts.google.com"
+
+// OIDCProvider logs users in with an OpenID Connect issuer. The endpoints and
+// signing keys are discovered on first use, so an unreachable issuer doesn't
+// keep the server from starting; a failed discovery is retried by the next
+// login.
+type OIDCProvider struct {
+	name      string
+	issuerURL string
+	client    *http.Client
+
+	mu       sync.Mutex
+	config   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewOIDC returns a provider for the issuer at issuerURL. Empty scopes
+// request openid, email and profile.
+func NewOIDC(name, issuerURL, clientID, clientSecret, redirectURL string, scopes []string) *OIDCProvider {
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	return &OIDCProvider{
+		name:      name,
+		issuerURL: issuerURL,
+		client:    &http.Client{Timeout: httpTimeout, Transport: otelhttp.NewTransport(nil)},
+		config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       scopes,
+		},
+	}
+}
+
+// NewGoogle returns a provider for Google accounts
+func NewGoogle(name, clientID, clientSecret, redirectURL string) *OIDCProvider {
+	return NewOIDC(name, GoogleIssuer, clientID, clientSecret, redirectURL, nil)
+}
+
+// Name implements Provider
+func (p *OIDCProvider) Name() string {
+	return p.name
+}
+
+// AuthCodeURL implements Provider
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, verifier string) (string, error) {
+	config, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange implements Provider. The ID token must be signed by the issuer for
+// this client; PKCE binds the code to the browser that started the login, so
+// no nonce is sent.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier string) (*Identity, error) {
+	config, idTokenVerifier, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = withClient(ctx, p.client)
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao trocar o código com %s: %w", p.name, err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, fmt.Errorf("%s não devolveu um id_token", p.name)
+	}
+	idToken, err := idTokenVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("id_token inválido de %s: %w", p.name, err)
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("claims inválidas no id_token de %s: %w", p.name, err)
+	}
+	if idToken.Subject == "" {
+		return nil, errors.New("id_token sem subject")
+	}
+	return &Identity{
+		Provider:      p.name,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// discover fetches the issuer metadata once and returns a copy of the client
+// config along with the ID token verifier
+func (p *OIDCProvider) discover(ctx context.Context) (oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.verifier == nil {
+		// The key set keeps the discovery context to fetch rotated keys later,
+		// so it must outlive the request
+		discoveryCtx := withClient(context.WithoutCancel(ctx), p.client)
+		provider, err := oidc.NewProvider(discoveryCtx, p.issuerURL)
+		if err != nil {
+			return oauth2.Config{}, nil, fmt.Errorf("erro na descoberta do provedor %s: %w", p.name, err)
+		}
+		p.config.Endpoint = provider.Endpoint()
+		p.verifier = provider.Verifier(&oidc.Config{ClientID: p.config.ClientID})
+	}
+	return p.config, p.verifier, nil
+}
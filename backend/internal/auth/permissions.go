@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"slices"
+	"sort"
+)
+
+// Role is a named set of permissions, e.g. "users:read"
+type Role struct {
+	Name        string
+	Description string
+	Permissions []string
+}
+
+// Policy answers which permissions each role grants. It is immutable: build
+// a new one when roles change.
+type Policy struct {
+	roles  []Role
+	grants map[string]map[string]bool
+}
+
+// NewPolicy builds a Policy from roles. Roles listed twice merge their
+// permissions.
+func NewPolicy(roles []Role) *Policy {
+	p := &Policy{grants: make(map[string]map[string]bool, len(roles))}
+	for _, role := range roles {
+		grants, ok := p.grants[role.Name]
+		if !ok {
+			grants = make(map[string]bool, len(role.Permissions))
+			p.grants[role.Name] = grants
+			p.roles = append(p.roles, Role{Name: role.Name, Description: role.Description})
+		}
+		for _, permission := range role.Permissions {
+			grants[permission] = true
+		}
+	}
+
+	for i := range p.roles {
+		for permission := range p.grants[p.roles[i].Name] {
+			p.roles[i].Permissions = append(p.roles[i].Permissions, permission)
+		}
+		sort.Strings(p.roles[i].Permissions)
+	}
+	sort.Slice(p.roles, func(i, j int) bool { return p.roles[i].Name < p.roles[j].Name })
+	return p
+}
+
+// PermissionImpersonate lets a role act as other users, see Impersonate
+const PermissionImpersonate = "sessions:impersonate"
+
+// Allows reports whether role grants every one of permissions. Unknown roles
+// grant nothing. A nil Policy, without roles configured, grants everything to
+// AdminRole and nothing to other roles.
+func (p *Policy) Allows(role string, permissions ...string) bool {
+	if p == nil {
+		return role == AdminRole
+	}
+	grants := p.grants[role]
+	for _, permission := range permissions {
+		if !grants[permission] {
+			return false
+		}
+	}
+	return true
+}
+
+// Roles returns the roles of the policy sorted by name, with their
+// permissions sorted
+func (p *Policy) Roles() []Role {
+	roles := make([]Role, len(p.roles))
+	for i, role := range p.roles {
+		role.Permissions = slices.Clone(role.Permissions)
+		roles[i] = role
+	}
+	return roles
+}
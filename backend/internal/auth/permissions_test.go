@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	policy := NewPolicy([]Role{
+		{Name: "user", Permissions: []string{"profile:read"}},
+		{Name: "admin", Description: "Administrador", Permissions: []string{"users:read", "profile:read"}},
+		{Name: "user", Permissions: []string{"profile:write"}},
+	})
+
+	assert.True(t, policy.Allows("user", "profile:read", "profile:write"), "duplicated roles merge")
+	assert.False(t, policy.Allows("user", "users:read"))
+	assert.True(t, policy.Allows("admin", "users:read"))
+	assert.False(t, policy.Allows("unknown", "profile:read"))
+	assert.True(t, policy.Allows("unknown"), "no permissions required")
+
+	var none *Policy
+	assert.True(t, none.Allows(AdminRole, "users:write"), "without roles the admin role gets everything")
+	assert.False(t, none.Allows("user", "profile:read"))
+
+	roles := policy.Roles()
+	assert.Equal(t, []Role{
+		{Name: "admin", Description: "Administrador", Permissions: []string{"profile:read", "users:read"}},
+		{Name: "user", Permissions: []string{"profile:read", "profile:write"}},
+	}, roles)
+
+	// Callers can't change the policy
+	roles[0].Permissions[0] = "changed"
+	assert.Equal(t, "profile:read", policy.Roles()[0].Permissions[0])
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/tokens"
+)
+
+// RecoveryCodeCount is the number of recovery codes issued per set
+const RecoveryCodeCount = 10
+
+// GenerateRecoveryCodes issues a fresh set of single-use recovery codes for the
+// user, invalidating any previous set. The plaintext codes are returned once and
+// only their hashes are stored.
+func (m *AuthManager) GenerateRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	adapter, ok := m.userAdapter.(RecoveryCodeAdapter)
+	if !ok {
+		return nil, ErrRecoveryCodesUnsupported
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode(m.config.Tokens)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	if err := adapter.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		logger.Error("Erro ao salvar códigos de recuperação", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// VerifyRecoveryCode checks a recovery code and consumes it on success, so each
+// code can only be used once. Returns ErrInvalidRecoveryCode if it doesn't match
+// an unused code of the user.
+func (m *AuthManager) VerifyRecoveryCode(ctx context.Context, userID, code string) error {
+	adapter, ok := m.userAdapter.(RecoveryCodeAdapter)
+	if !ok {
+		return ErrRecoveryCodesUnsupported
+	}
+
+	consumed, err := adapter.ConsumeRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		logger.Error("Erro ao consumir código de recuperação", "error", err, "user_id", userID)
+		return err
+	}
+	if !consumed {
+		return ErrInvalidRecoveryCode
+	}
+
+	logger.Info("Código de recuperação utilizado", "user_id", userID)
+	return nil
+}
+
+// RemainingRecoveryCodes returns how many unused recovery codes the user has
+func (m *AuthManager) RemainingRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	adapter, ok := m.userAdapter.(RecoveryCodeAdapter)
+	if !ok {
+		return 0, ErrRecoveryCodesUnsupported
+	}
+	return adapter.CountRecoveryCodes(ctx, userID)
+}
+
+// generateRecoveryCode returns a random code formatted as "xxxx-xxxx-xxxx"
+func generateRecoveryCode(g tokens.Generator) (string, error) {
+	raw, err := tokens.Hex(g, 6)
+	if err != nil {
+		return "", err
+	}
+	return raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12], nil
+}
+
+// hashRecoveryCode normalizes (case, separators) and hashes a code.
+// Codes are high-entropy random values, so a fast hash is sufficient.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.TrimSpace(code))
+	normalized = strings.NewReplacer("-", "", " ", "").Replace(normalized)
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/metrics"
+)
+
+// Refresh exchanges a refresh token for a new session and refresh token of the
+// same family, ending the session the token was issued with. metadata
+// describes the client refreshing.
+//
+// Each token is accepted once. A used token coming back means it was copied:
+// the whole family is revoked, logging out both the thief and the user, and
+// ErrRefreshTokenReused returned. Unknown and expired tokens, and tokens whose
+// session expired or ended (logout, password change), give
+// ErrInvalidRefreshToken.
+func (m *AuthManager) Refresh(ctx context.Context, refreshToken string, metadata SessionMetadata) (*Session, *UserData, error) {
+	adapter, ok := m.sessionAdapter.(RefreshTokenAdapter)
+	if !ok {
+		return nil, nil, ErrRefreshTokensUnsupported
+	}
+
+	hashedToken := hashToken(refreshToken)
+	stored, err := adapter.GetRefreshToken(ctx, hashedToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stored.Used {
+		return nil, nil, m.revokeRefreshTokenFamily(ctx, adapter, stored)
+	}
+	if m.config.Clock.Now().After(stored.ExpiresAt) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	// Single use: the new session goes to whoever marks the token
+	marked, err := adapter.MarkRefreshTokenUsed(ctx, hashedToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !marked {
+		return nil, nil, m.revokeRefreshTokenFamily(ctx, adapter, stored)
+	}
+
+	// Tokens die with their session: clients refresh before it expires (see
+	// middleware.WithRefreshRecommendedWithin)
+	previous, err := m.sessionAdapter.GetSession(ctx, stored.SessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if m.config.Clock.Now().After(previous.ExpiresAt.Add(m.config.ClockSkewLeeway)) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	user, err := m.userAdapter.FindUserByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.Active {
+		return nil, nil, ErrUserNotActive
+	}
+	if user.DeletionScheduled() {
+		return nil, nil, ErrAccountPendingDeletion
+	}
+
+	if err := m.sessionAdapter.DeleteSession(ctx, stored.SessionID); err != nil {
+		logger.Error("Erro ao encerrar sessão renovada", "error", err, "session_id", stored.SessionID)
+		return nil, nil, err
+	}
+	expiresAt := m.config.Clock.Now().Add(m.config.SessionDuration)
+	session, err := m.sessionAdapter.CreateSession(ctx, user.ID, expiresAt, metadata)
+	if err != nil {
+		logger.Error("Erro ao criar sessão ao renovar", "error", err, "user_id", user.ID)
+		return nil, nil, err
+	}
+	if err := m.issueRefreshToken(ctx, session, stored.FamilyID); err != nil {
+		return nil, nil, err
+	}
+
+	session.Fresh = true
+	return session, user, nil
+}
+
+// FindRefreshToken returns the stored refresh token, used or not. Returns
+// ErrInvalidRefreshToken for unknown tokens and ErrRefreshTokensUnsupported
+// when the session adapter has no refresh tokens.
+func (m *AuthManager) FindRefreshToken(ctx context.Context, refreshToken string) (*RefreshToken, error) {
+	adapter, ok := m.sessionAdapter.(RefreshTokenAdapter)
+	if !ok {
+		return nil, ErrRefreshTokensUnsupported
+	}
+	return adapter.GetRefreshToken(ctx, hashToken(refreshToken))
+}
+
+// RevokeRefreshTokens deletes every refresh token of a family and the
+// sessions issued with them, e.g. when the client revokes one of its tokens
+func (m *AuthManager) RevokeRefreshTokens(ctx context.Context, familyID string) error {
+	adapter, ok := m.sessionAdapter.(RefreshTokenAdapter)
+	if !ok {
+		return ErrRefreshTokensUnsupported
+	}
+	if err := adapter.RevokeRefreshTokenFamily(ctx, familyID); err != nil {
+		logger.Error("Erro ao revogar família de refresh tokens", "error", err)
+		return err
+	}
+	return nil
+}
+
+// issueRefreshToken gives a new session its refresh token, starting a family
+// when familyID is empty. Does nothing unless AuthConfig.RefreshTokenDuration
+// is set.
+func (m *AuthManager) issueRefreshToken(ctx context.Context, session *Session, familyID string) error {
+	if m.config.RefreshTokenDuration <= 0 {
+		return nil
+	}
+	adapter, ok := m.sessionAdapter.(RefreshTokenAdapter)
+	if !ok {
+		return ErrRefreshTokensUnsupported
+	}
+
+	if familyID == "" {
+		var err error
+		if familyID, err = m.newToken(); err != nil {
+			return err
+		}
+	}
+	token, err := m.newToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := m.config.Clock.Now().Add(m.config.RefreshTokenDuration)
+	stored := RefreshToken{FamilyID: familyID, SessionID: session.ID, UserID: session.UserID, ExpiresAt: expiresAt}
+	if err := adapter.CreateRefreshToken(ctx, hashToken(token), stored); err != nil {
+		logger.Error("Erro ao criar refresh token", "error", err, "user_id", session.UserID)
+		return err
+	}
+
+	session.RefreshToken = token
+	session.RefreshTokenExpiresAt = expiresAt
+	return nil
+}
+
+// revokeRefreshTokenFamily ends every session of the family of a reused
+// token. Returns ErrRefreshTokenReused once revoked.
+func (m *AuthManager) revokeRefreshTokenFamily(ctx context.Context, adapter RefreshTokenAdapter, reused *RefreshToken) error {
+	logger.Warn("Refresh token reutilizado, sessões da família revogadas", "user_id", reused.UserID, "session_id", reused.SessionID)
+	metrics.RefreshTokenReuse.Inc()
+	if err := adapter.RevokeRefreshTokenFamily(ctx, reused.FamilyID); err != nil {
+		logger.Error("Erro ao revogar família de refresh tokens", "error", err, "user_id", reused.UserID)
+		return err
+	}
+	return ErrRefreshTokenReused
+}
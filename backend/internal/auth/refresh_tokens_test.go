@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRefreshSessionAdapter adds refresh tokens to fakeSessionAdapter
+type fakeRefreshSessionAdapter struct {
+	*fakeSessionAdapter
+	tokens map[string]*RefreshToken
+}
+
+func (f *fakeRefreshSessionAdapter) CreateRefreshToken(ctx context.Context, hashedToken string, token RefreshToken) error {
+	f.tokens[hashedToken] = &token
+	return nil
+}
+
+func (f *fakeRefreshSessionAdapter) GetRefreshToken(ctx context.Context, hashedToken string) (*RefreshToken, error) {
+	token, ok := f.tokens[hashedToken]
+	if !ok {
+		return nil, ErrInvalidRefreshToken
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (f *fakeRefreshSessionAdapter) MarkRefreshTokenUsed(ctx context.Context, hashedToken string) (bool, error) {
+	token, ok := f.tokens[hashedToken]
+	if !ok || token.Used {
+		return false, nil
+	}
+	token.Used = true
+	return true, nil
+}
+
+func (f *fakeRefreshSessionAdapter) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	for hash, token := range f.tokens {
+		if token.FamilyID == familyID {
+			delete(f.sessions, token.SessionID)
+			delete(f.tokens, hash)
+		}
+	}
+	return nil
+}
+
+func newRefreshTestManager(t *testing.T) (*AuthManager, *fakeUserAdapter, *fakeRefreshSessionAdapter, *FakeClock) {
+	t.Helper()
+	clock := NewFakeClock(time.Date(2024, 2, 11, 12, 0, 0, 0, time.UTC))
+	config := DefaultAuthConfig()
+	config.Clock = clock
+	config.RefreshTokenDuration = 60 * 24 * time.Hour
+	_, users, sessions := newTestAuthManager(config)
+	refreshSessions := &fakeRefreshSessionAdapter{fakeSessionAdapter: sessions, tokens: make(map[string]*RefreshToken)}
+	return NewAuthManager(users, refreshSessions, config), users, refreshSessions, clock
+}
+
+func TestAuthManager_Refresh(t *testing.T) {
+	ctx := context.Background()
+	metadata := SessionMetadata{UserAgent: "test-agent", IP: "203.0.113.7"}
+
+	t.Run("rotates the session and the token", func(t *testing.T) {
+		m, _, sessions, clock := newRefreshTestManager(t)
+		login, _, err := m.Login(ctx, "testuser", "Password123!", metadata)
+		require.NoError(t, err)
+		require.NotEmpty(t, login.RefreshToken)
+		assert.Equal(t, clock.Now().Add(60*24*time.Hour), login.RefreshTokenExpiresAt)
+
+		session, user, err := m.Refresh(ctx, login.RefreshToken, metadata)
+		require.NoError(t, err)
+		assert.Equal(t, "1", user.ID)
+		assert.True(t, session.Fresh)
+		assert.NotEqual(t, login.ID, session.ID)
+		assert.NotEqual(t, login.RefreshToken, session.RefreshToken)
+		assert.Equal(t, "test-agent", session.UserAgent)
+
+		_, _, err = m.ValidateSession(ctx, login.ID)
+		assert.ErrorIs(t, err, ErrSessionNotFound, "the refreshed session ends")
+		_, _, err = m.ValidateSession(ctx, session.ID)
+		assert.NoError(t, err)
+
+		// The new token continues the family
+		first := sessions.tokens[hashToken(login.RefreshToken)]
+		second := sessions.tokens[hashToken(session.RefreshToken)]
+		assert.Equal(t, first.FamilyID, second.FamilyID)
+		assert.Equal(t, session.ID, second.SessionID)
+	})
+
+	t.Run("reuse revokes the family", func(t *testing.T) {
+		m, _, sessions, _ := newRefreshTestManager(t)
+		login, _, err := m.Login(ctx, "testuser", "Password123!", metadata)
+		require.NoError(t, err)
+		other, _, err := m.Login(ctx, "testuser", "Password123!", metadata)
+		require.NoError(t, err)
+
+		reused := testutil.ToFloat64(metrics.RefreshTokenReuse)
+		refreshed, _, err := m.Refresh(ctx, login.RefreshToken, metadata)
+		require.NoError(t, err)
+		assert.Equal(t, reused, testutil.ToFloat64(metrics.RefreshTokenReuse), "a rotation is no reuse")
+		_, _, err = m.Refresh(ctx, login.RefreshToken, metadata)
+		assert.ErrorIs(t, err, ErrRefreshTokenReused)
+		assert.Equal(t, reused+1, testutil.ToFloat64(metrics.RefreshTokenReuse))
+
+		_, _, err = m.ValidateSession(ctx, refreshed.ID)
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+		_, _, err = m.Refresh(ctx, refreshed.RefreshToken, metadata)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+		// Other logins are separate families
+		_, _, err = m.ValidateSession(ctx, other.ID)
+		assert.NoError(t, err)
+		assert.Contains(t, sessions.tokens, hashToken(other.RefreshToken))
+	})
+
+	t.Run("rejects unknown and expired tokens", func(t *testing.T) {
+		m, _, _, clock := newRefreshTestManager(t)
+		_, _, err := m.Refresh(ctx, "unknown", metadata)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+		login, _, err := m.Login(ctx, "testuser", "Password123!", metadata)
+		require.NoError(t, err)
+		clock.Advance(61 * 24 * time.Hour)
+		_, _, err = m.Refresh(ctx, login.RefreshToken, metadata)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+
+	t.Run("ends with the session", func(t *testing.T) {
+		m, _, _, _ := newRefreshTestManager(t)
+		login, _, err := m.Login(ctx, "testuser", "Password123!", metadata)
+		require.NoError(t, err)
+		require.NoError(t, m.Logout(ctx, login.ID))
+
+		_, _, err = m.Refresh(ctx, login.RefreshToken, metadata)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+
+	t.Run("checks the user", func(t *testing.T) {
+		m, users, _, _ := newRefreshTestManager(t)
+		login, _, err := m.Login(ctx, "testuser", "Password123!", metadata)
+		require.NoError(t, err)
+		users.user.Active = false
+
+		_, _, err = m.Refresh(ctx, login.RefreshToken, metadata)
+		assert.ErrorIs(t, err, ErrUserNotActive)
+	})
+}
+
+func TestAuthManager_RefreshTokensDisabled(t *testing.T) {
+	ctx := context.Background()
+
+	// Off by default
+	config := DefaultAuthConfig()
+	_, users, sessions := newTestAuthManager(config)
+	m := NewAuthManager(users, &fakeRefreshSessionAdapter{fakeSessionAdapter: sessions, tokens: make(map[string]*RefreshToken)}, config)
+	session, _, err := m.Login(ctx, "testuser", "Password123!", SessionMetadata{})
+	require.NoError(t, err)
+	assert.Empty(t, session.RefreshToken)
+
+	// Enabled without adapter support, logins fail rather than hand out
+	// tokens that can't be used
+	config = DefaultAuthConfig()
+	config.RefreshTokenDuration = time.Hour
+	m, _, _ = newTestAuthManager(config)
+	_, _, err = m.Login(ctx, "testuser", "Password123!", SessionMetadata{})
+	assert.ErrorIs(t, err, ErrRefreshTokensUnsupported)
+	_, _, err = m.Refresh(ctx, "token", SessionMetadata{})
+	assert.ErrorIs(t, err, ErrRefreshTokensUnsupported)
+}
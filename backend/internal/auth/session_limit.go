@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"gosveltekit/internal/logger"
+)
+
+// What Login does when a user already has MaxSessionsPerUser sessions
+const (
+	SessionLimitEvict  = "evict"  // end the least recently used session
+	SessionLimitReject = "reject" // refuse the login with ErrTooManySessions
+)
+
+// ErrTooManySessions is returned by Login when the user reached
+// MaxSessionsPerUser and OnSessionLimit is SessionLimitReject
+var ErrTooManySessions = errorString("too many active sessions")
+
+// enforceSessionLimit makes room for a new session of userID. Expired and
+// idle sessions found on the way are deleted first and don't count against
+// the limit; impersonation sessions are never counted nor evicted.
+func (m *AuthManager) enforceSessionLimit(ctx context.Context, userID string) error {
+	if m.config.MaxSessionsPerUser <= 0 {
+		return nil
+	}
+	sessions, err := m.ListSessions(ctx, userID)
+	if err != nil {
+		if err == ErrSessionListUnsupported {
+			return nil
+		}
+		return err
+	}
+
+	now := m.config.Clock.Now()
+	active := make([]*Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.IsImpersonation() {
+			continue
+		}
+		if m.sessionDead(session, now) {
+			if err := m.sessionAdapter.DeleteSession(ctx, session.ID); err != nil {
+				logger.Warn("Erro ao remover sessão expirada", "error", err, "session_id", session.ID)
+			}
+			continue
+		}
+		active = append(active, session)
+	}
+
+	excess := len(active) - m.config.MaxSessionsPerUser + 1
+	if excess <= 0 {
+		return nil
+	}
+	if m.config.OnSessionLimit == SessionLimitReject {
+		return ErrTooManySessions
+	}
+
+	// Least recently used first
+	slices.SortFunc(active, func(a, b *Session) int {
+		return sessionLastUsed(a).Compare(sessionLastUsed(b))
+	})
+	for _, session := range active[:excess] {
+		if err := m.sessionAdapter.DeleteSession(ctx, session.ID); err != nil {
+			return err
+		}
+		logger.Info("Sessão encerrada por limite de sessões simultâneas", "session_id", session.ID, "user_id", userID)
+	}
+	return nil
+}
+
+// sessionDead reports whether ValidateSession would reject session as expired
+func (m *AuthManager) sessionDead(session *Session, now time.Time) bool {
+	if now.After(session.ExpiresAt.Add(m.config.ClockSkewLeeway)) {
+		return true
+	}
+	return m.config.SessionIdleTimeout > 0 && now.After(sessionLastUsed(session).Add(m.config.SessionIdleTimeout+m.config.ClockSkewLeeway))
+}
+
+// sessionLastUsed falls back to CreatedAt for sessions created before
+// LastUsedAt existed
+func sessionLastUsed(session *Session) time.Time {
+	if session.LastUsedAt.IsZero() {
+		return session.CreatedAt
+	}
+	return session.LastUsedAt
+}
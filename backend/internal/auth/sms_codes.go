@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/tokens"
+)
+
+// SMS code purposes. A code issued for one purpose can't be used for another.
+const (
+	SMSPurposePhoneVerification = "phone_verification"
+	SMSPurposeTwoFactor         = "two_factor"
+)
+
+// SMSCodeLength is the number of digits of an SMS code
+const SMSCodeLength = 6
+
+// IssueSMSCode generates a numeric code for the user and purpose, replacing any
+// pending code for the same purpose. The plaintext code is returned for the
+// caller to send; only its hash is stored.
+//
+// Sending is rate limited per user: at most SMSCodesPerHour codes per hour and
+// one every SMSCodeInterval, otherwise ErrSMSCodeRateLimited is returned.
+func (m *AuthManager) IssueSMSCode(ctx context.Context, userID, purpose string) (string, error) {
+	adapter, ok := m.userAdapter.(SMSCodeAdapter)
+	if !ok {
+		return "", ErrSMSCodesUnsupported
+	}
+
+	now := m.config.Clock.Now()
+	sent, err := adapter.SMSCodesSentSince(ctx, userID, now.Add(-time.Hour))
+	if err != nil {
+		logger.Error("Erro ao consultar códigos SMS enviados", "error", err, "user_id", userID)
+		return "", err
+	}
+	if m.config.SMSCodesPerHour > 0 && len(sent) >= m.config.SMSCodesPerHour {
+		logger.Warn("Limite de códigos SMS por hora atingido", "user_id", userID)
+		return "", ErrSMSCodeRateLimited
+	}
+	if len(sent) > 0 && now.Sub(sent[0]) < m.config.SMSCodeInterval {
+		return "", ErrSMSCodeRateLimited
+	}
+
+	code, err := generateSMSCode(m.config.Tokens)
+	if err != nil {
+		return "", err
+	}
+	expiresAt := now.Add(m.config.SMSCodeTTL)
+	if err := adapter.CreateSMSCode(ctx, userID, purpose, hashSMSCode(userID, purpose, code), expiresAt); err != nil {
+		logger.Error("Erro ao salvar código SMS", "error", err, "user_id", userID)
+		return "", err
+	}
+
+	return code, nil
+}
+
+// VerifySMSCode checks a code issued by IssueSMSCode and consumes it on
+// success, so each code can only be used once. Returns ErrInvalidSMSCode if
+// it doesn't match, has expired or has too many failed attempts.
+func (m *AuthManager) VerifySMSCode(ctx context.Context, userID, purpose, code string) error {
+	adapter, ok := m.userAdapter.(SMSCodeAdapter)
+	if !ok {
+		return ErrSMSCodesUnsupported
+	}
+
+	consumed, err := adapter.ConsumeSMSCode(ctx, userID, purpose, hashSMSCode(userID, purpose, code), m.config.SMSCodeMaxAttempts)
+	if err != nil {
+		logger.Error("Erro ao verificar código SMS", "error", err, "user_id", userID)
+		return err
+	}
+	if !consumed {
+		return ErrInvalidSMSCode
+	}
+
+	logger.Info("Código SMS utilizado", "user_id", userID, "purpose", purpose)
+	return nil
+}
+
+// generateSMSCode returns a uniformly random zero-padded numeric code
+func generateSMSCode(g tokens.Generator) (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < SMSCodeLength; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(g, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", SMSCodeLength, n), nil
+}
+
+// hashSMSCode binds the code to the user and purpose before hashing. Codes are
+// short, so what protects them is the TTL and the attempt limit, not the hash.
+func hashSMSCode(userID, purpose, code string) string {
+	normalized := strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	hash := sha256.Sum256([]byte(userID + ":" + purpose + ":" + normalized))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"gosveltekit/internal/logger"
+)
+
+// TOTP parameters (RFC 6238), the defaults of every authenticator app
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30 * time.Second
+
+	// totpSecretBytes is the secret size recommended by RFC 4226
+	totpSecretBytes = 20
+	// totpSkew accepts codes this many steps before or after the current one,
+	// for clock drift and slow typing
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPEnrollment is a new TOTP secret waiting for confirmation
+type TOTPEnrollment struct {
+	Secret string // base32, for manual entry in the app
+	URI    string // otpauth:// provisioning URI, shown as a QR code
+}
+
+// EnrollTOTP generates a TOTP secret for the user, replacing a pending
+// enrollment. It only protects logins after ConfirmTOTP; returns
+// ErrTOTPAlreadyEnabled if the user already has a confirmed secret.
+// accountName labels the entry in the authenticator app.
+func (m *AuthManager) EnrollTOTP(ctx context.Context, userID, accountName string) (*TOTPEnrollment, error) {
+	adapter, ok := m.userAdapter.(TOTPAdapter)
+	if !ok {
+		return nil, ErrTOTPUnsupported
+	}
+
+	current, err := adapter.GetTOTPSecret(ctx, userID)
+	if err != nil && !errors.Is(err, ErrTOTPNotEnrolled) {
+		return nil, err
+	}
+	if err == nil && current.Confirmed {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	raw := make([]byte, totpSecretBytes)
+	if _, err := io.ReadFull(m.config.Tokens, raw); err != nil {
+		return nil, err
+	}
+	secret := totpEncoding.EncodeToString(raw)
+	if err := adapter.SaveTOTPSecret(ctx, userID, secret); err != nil {
+		logger.Error("Erro ao salvar segredo TOTP", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	return &TOTPEnrollment{Secret: secret, URI: totpURI(m.config.TOTPIssuer, accountName, secret)}, nil
+}
+
+// ConfirmTOTP enables TOTP with a first code from the app, proving the user
+// saved the secret, and issues a fresh set of recovery codes (see
+// GenerateRecoveryCodes), returned in plaintext this once. No codes are
+// returned if the adapter doesn't support them.
+func (m *AuthManager) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	adapter, ok := m.userAdapter.(TOTPAdapter)
+	if !ok {
+		return nil, ErrTOTPUnsupported
+	}
+
+	secret, err := adapter.GetTOTPSecret(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if secret.Confirmed {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if err := m.checkTOTP(ctx, adapter, userID, secret, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := m.GenerateRecoveryCodes(ctx, userID)
+	if err != nil && !errors.Is(err, ErrRecoveryCodesUnsupported) {
+		return nil, err
+	}
+	if err := adapter.ConfirmTOTPSecret(ctx, userID); err != nil {
+		logger.Error("Erro ao confirmar segredo TOTP", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	logger.Info("TOTP ativado", "user_id", userID)
+	return codes, nil
+}
+
+// DisableTOTP removes the user's TOTP secret and recovery codes. Callers must
+// have re-authenticated the user, as taking the second factor away is what an
+// attacker holding a session would want.
+func (m *AuthManager) DisableTOTP(ctx context.Context, userID string) error {
+	adapter, ok := m.userAdapter.(TOTPAdapter)
+	if !ok {
+		return ErrTOTPUnsupported
+	}
+
+	if err := adapter.DeleteTOTPSecret(ctx, userID); err != nil {
+		logger.Error("Erro ao remover segredo TOTP", "error", err, "user_id", userID)
+		return err
+	}
+	if codes, ok := m.userAdapter.(RecoveryCodeAdapter); ok {
+		if err := codes.ReplaceRecoveryCodes(ctx, userID, nil); err != nil {
+			logger.Error("Erro ao remover códigos de recuperação", "error", err, "user_id", userID)
+			return err
+		}
+	}
+
+	logger.Info("TOTP desativado", "user_id", userID)
+	return nil
+}
+
+// TOTPEnabled reports whether the user has a confirmed TOTP secret
+func (m *AuthManager) TOTPEnabled(ctx context.Context, userID string) (bool, error) {
+	adapter, ok := m.userAdapter.(TOTPAdapter)
+	if !ok {
+		return false, ErrTOTPUnsupported
+	}
+
+	secret, err := adapter.GetTOTPSecret(ctx, userID)
+	if errors.Is(err, ErrTOTPNotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return secret.Confirmed, nil
+}
+
+// VerifyTOTP checks a code of the user's confirmed secret. Each code is
+// accepted once: returns ErrInvalidTwoFactorCode for a wrong or replayed
+// code and ErrTOTPNotEnrolled if TOTP isn't enabled.
+func (m *AuthManager) VerifyTOTP(ctx context.Context, userID, code string) error {
+	adapter, ok := m.userAdapter.(TOTPAdapter)
+	if !ok {
+		return ErrTOTPUnsupported
+	}
+
+	secret, err := adapter.GetTOTPSecret(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !secret.Confirmed {
+		return ErrTOTPNotEnrolled
+	}
+	return m.checkTOTP(ctx, adapter, userID, secret, code)
+}
+
+// checkTOTP matches code against the steps around now and records the
+// matching step, so the code can't be used again
+func (m *AuthManager) checkTOTP(ctx context.Context, adapter TOTPAdapter, userID string, secret *TOTPSecret, code string) error {
+	key, err := totpEncoding.DecodeString(secret.Secret)
+	if err != nil {
+		return fmt.Errorf("segredo TOTP inválido: %w", err)
+	}
+
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	current := m.config.Clock.Now().Unix() / int64(TOTPPeriod/time.Second)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= secret.LastUsedStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) != 1 {
+			continue
+		}
+		used, err := adapter.UseTOTPStep(ctx, userID, step)
+		if err != nil {
+			logger.Error("Erro ao registrar uso de código TOTP", "error", err, "user_id", userID)
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+	return ErrInvalidTwoFactorCode
+}
+
+// totpCode computes the code of a time step (HOTP of RFC 4226, SHA-1)
+func totpCode(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range TOTPDigits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod)
+}
+
+// totpURI builds the Key Uri Format understood by authenticator apps
+func totpURI(issuer, accountName, secret string) string {
+	label := accountName
+	if issuer != "" {
+		label = issuer + ":" + accountName
+	}
+	query := url.Values{}
+	query.Set("secret", secret)
+	if issuer != "" {
+		query.Set("issuer", issuer)
+	}
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+	return "otpauth://totp/" + url.PathEscape(label) + "?" + query.Encode()
+}
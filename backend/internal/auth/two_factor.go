@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gosveltekit/internal/logger"
+)
+
+// Two-factor methods a user can choose
+const (
+	TwoFactorMethodTOTP = "totp"
+	TwoFactorMethodSMS  = "sms"
+)
+
+// TwoFactorRequiredError is returned by Login for users with a second
+// factor. It matches ErrTwoFactorRequired and carries the challenge token to
+// send back with the code to CompleteTwoFactorLogin.
+type TwoFactorRequiredError struct {
+	Token     string
+	ExpiresAt time.Time
+	UserID    string
+	// Method is the second factor asked for. For TwoFactorMethodSMS the
+	// caller sends the code, see IssueSMSCode with SMSPurposeTwoFactor.
+	Method string
+}
+
+func (e *TwoFactorRequiredError) Error() string { return ErrTwoFactorRequired.Error() }
+
+// Is makes errors.Is(err, ErrTwoFactorRequired) match
+func (e *TwoFactorRequiredError) Is(target error) bool { return target == ErrTwoFactorRequired }
+
+// requireSecondFactor opens a two-factor challenge for users with a
+// two-factor method or TOTP enabled, returned as a *TwoFactorRequiredError.
+// Nil means the user can get a session right away.
+func (m *AuthManager) requireSecondFactor(ctx context.Context, user *UserData, metadata SessionMetadata) error {
+	method := user.TwoFactorMethod()
+	if method == "" {
+		enabled, err := m.TOTPEnabled(ctx, user.ID)
+		if errors.Is(err, ErrTOTPUnsupported) || (err == nil && !enabled) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		method = TwoFactorMethodTOTP
+	}
+
+	// Fail closed: a user who enabled 2FA must never get a session without it
+	challenges, ok := m.sessionAdapter.(TwoFactorChallengeAdapter)
+	if !ok {
+		return ErrTwoFactorChallengesUnsupported
+	}
+	token, err := m.newToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := m.config.Clock.Now().Add(m.config.TwoFactorChallengeTTL)
+	challenge := TwoFactorChallenge{UserID: user.ID, ExpiresAt: expiresAt, Metadata: metadata}
+	if err := challenges.CreateTwoFactorChallenge(ctx, hashToken(token), challenge); err != nil {
+		logger.Error("Erro ao criar desafio de 2FA", "error", err, "user_id", user.ID)
+		return err
+	}
+
+	return &TwoFactorRequiredError{Token: token, ExpiresAt: expiresAt, UserID: user.ID, Method: method}
+}
+
+// CompleteTwoFactorLogin finishes a login interrupted by a two-factor
+// challenge, creating the session once code is a valid TOTP code (SMS code
+// for users with TwoFactorMethodSMS) or an unused recovery code.
+//
+// A wrong code returns ErrInvalidTwoFactorCode; after TwoFactorMaxAttempts
+// of them, or once expired, the challenge gives ErrInvalidTwoFactorChallenge
+// and the user logs in again. Wrong codes also count against the user across
+// challenges, locking the second factor like failed passwords lock Login
+// (ErrAccountLocked).
+func (m *AuthManager) CompleteTwoFactorLogin(ctx context.Context, token, code string) (*Session, *UserData, error) {
+	challenges, ok := m.sessionAdapter.(TwoFactorChallengeAdapter)
+	if !ok {
+		return nil, nil, ErrTwoFactorChallengesUnsupported
+	}
+
+	hashedToken := hashToken(token)
+	challenge, err := challenges.GetTwoFactorChallenge(ctx, hashedToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m.config.Clock.Now().After(challenge.ExpiresAt) ||
+		(m.config.TwoFactorMaxAttempts > 0 && challenge.Attempts >= m.config.TwoFactorMaxAttempts) {
+		if _, err := challenges.DeleteTwoFactorChallenge(ctx, hashedToken); err != nil {
+			logger.Warn("Erro ao remover desafio de 2FA encerrado", "error", err, "user_id", challenge.UserID)
+		}
+		return nil, nil, ErrInvalidTwoFactorChallenge
+	}
+
+	lockKey := twoFactorLockKey(challenge.UserID)
+	if m.isAccountLocked(ctx, lockKey) {
+		return nil, nil, ErrAccountLocked
+	}
+	if err := m.verifySecondFactor(ctx, challenge.UserID, code); err != nil {
+		if !errors.Is(err, ErrInvalidTwoFactorCode) {
+			return nil, nil, err
+		}
+		if err := challenges.RecordTwoFactorAttempt(ctx, hashedToken); err != nil {
+			logger.Error("Erro ao registrar tentativa de 2FA", "error", err, "user_id", challenge.UserID)
+			return nil, nil, err
+		}
+		if _, lockedNow := m.recordFailedAttempt(ctx, lockKey); lockedNow {
+			logger.Warn("2FA bloqueado por excesso de códigos inválidos", "user_id", challenge.UserID)
+		}
+		return nil, nil, ErrInvalidTwoFactorCode
+	}
+	m.clearFailedAttempts(ctx, lockKey)
+
+	// Single use: the session goes to whoever deletes the challenge
+	deleted, err := challenges.DeleteTwoFactorChallenge(ctx, hashedToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !deleted {
+		return nil, nil, ErrInvalidTwoFactorChallenge
+	}
+
+	// The account may have changed while the challenge was pending
+	user, err := m.userAdapter.FindUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.Active {
+		return nil, nil, ErrUserNotActive
+	}
+	if user.DeletionScheduled() {
+		return nil, nil, ErrAccountPendingDeletion
+	}
+
+	if err := m.enforceSessionLimit(ctx, user.ID); err != nil {
+		return nil, nil, err
+	}
+
+	expiresAt := m.config.Clock.Now().Add(m.config.SessionDuration)
+	session, err := m.sessionAdapter.CreateSession(ctx, user.ID, expiresAt, challenge.Metadata)
+	if err != nil {
+		logger.Error("Erro ao criar sessão após 2FA", "error", err, "user_id", user.ID)
+		return nil, nil, err
+	}
+	if err := m.issueRefreshToken(ctx, session, ""); err != nil {
+		return nil, nil, err
+	}
+
+	session.Fresh = true
+	return session, user, nil
+}
+
+// TwoFactorChallengeUser returns the user of a pending challenge, e.g. to
+// send them a new SMS code. Expired or exhausted challenges give
+// ErrInvalidTwoFactorChallenge.
+func (m *AuthManager) TwoFactorChallengeUser(ctx context.Context, token string) (string, error) {
+	challenges, ok := m.sessionAdapter.(TwoFactorChallengeAdapter)
+	if !ok {
+		return "", ErrTwoFactorChallengesUnsupported
+	}
+	challenge, err := challenges.GetTwoFactorChallenge(ctx, hashToken(token))
+	if err != nil {
+		return "", err
+	}
+	if m.config.Clock.Now().After(challenge.ExpiresAt) ||
+		(m.config.TwoFactorMaxAttempts > 0 && challenge.Attempts >= m.config.TwoFactorMaxAttempts) {
+		return "", ErrInvalidTwoFactorChallenge
+	}
+	return challenge.UserID, nil
+}
+
+// verifySecondFactor accepts a code of the user's method, TOTP or SMS, or
+// failing that a recovery code. Returns ErrInvalidTwoFactorCode if code is
+// neither.
+func (m *AuthManager) verifySecondFactor(ctx context.Context, userID, code string) error {
+	user, err := m.userAdapter.FindUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.TwoFactorMethod() == TwoFactorMethodSMS {
+		if isNumericCode(code, SMSCodeLength) {
+			err := m.VerifySMSCode(ctx, userID, SMSPurposeTwoFactor, code)
+			if errors.Is(err, ErrInvalidSMSCode) {
+				return ErrInvalidTwoFactorCode
+			}
+			return err
+		}
+	} else if isNumericCode(code, TOTPDigits) {
+		return m.VerifyTOTP(ctx, userID, code)
+	}
+
+	err = m.VerifyRecoveryCode(ctx, userID, code)
+	if errors.Is(err, ErrInvalidRecoveryCode) || errors.Is(err, ErrRecoveryCodesUnsupported) {
+		return ErrInvalidTwoFactorCode
+	}
+	return err
+}
+
+// isNumericCode tells TOTP and SMS codes (digits only) from recovery codes
+func isNumericCode(code string, digits int) bool {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != digits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// twoFactorLockKey keys the failed code counter of a user, apart from the
+// identifiers counted by Login
+func twoFactorLockKey(userID string) string {
+	return "2fa:" + userID
+}
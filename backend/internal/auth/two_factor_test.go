@@ -0,0 +1,363 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTwoFactorUserAdapter adds TOTP secrets and recovery codes to
+// fakeUserAdapter
+type fakeTwoFactorUserAdapter struct {
+	*fakeUserAdapter
+	totp          *TOTPSecret
+	recoveryCodes map[string]bool // hash -> used
+}
+
+func (f *fakeTwoFactorUserAdapter) SaveTOTPSecret(ctx context.Context, userID, secret string) error {
+	f.totp = &TOTPSecret{Secret: secret}
+	return nil
+}
+
+func (f *fakeTwoFactorUserAdapter) GetTOTPSecret(ctx context.Context, userID string) (*TOTPSecret, error) {
+	if f.totp == nil {
+		return nil, ErrTOTPNotEnrolled
+	}
+	secret := *f.totp
+	return &secret, nil
+}
+
+func (f *fakeTwoFactorUserAdapter) ConfirmTOTPSecret(ctx context.Context, userID string) error {
+	f.totp.Confirmed = true
+	return nil
+}
+
+func (f *fakeTwoFactorUserAdapter) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	if step <= f.totp.LastUsedStep {
+		return false, nil
+	}
+	f.totp.LastUsedStep = step
+	return true, nil
+}
+
+func (f *fakeTwoFactorUserAdapter) DeleteTOTPSecret(ctx context.Context, userID string) error {
+	f.totp = nil
+	return nil
+}
+
+func (f *fakeTwoFactorUserAdapter) ReplaceRecoveryCodes(ctx context.Context, userID string, hashedCodes []string) error {
+	f.recoveryCodes = make(map[string]bool)
+	for _, hash := range hashedCodes {
+		f.recoveryCodes[hash] = false
+	}
+	return nil
+}
+
+func (f *fakeTwoFactorUserAdapter) ConsumeRecoveryCode(ctx context.Context, userID string, hashedCode string) (bool, error) {
+	used, ok := f.recoveryCodes[hashedCode]
+	if !ok || used {
+		return false, nil
+	}
+	f.recoveryCodes[hashedCode] = true
+	return true, nil
+}
+
+func (f *fakeTwoFactorUserAdapter) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	count := 0
+	for _, used := range f.recoveryCodes {
+		if !used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// fakeSMSUserAdapter adds SMS codes to fakeTwoFactorUserAdapter
+type fakeSMSUserAdapter struct {
+	*fakeTwoFactorUserAdapter
+	smsCodes map[string]string // purpose -> hash
+}
+
+func (f *fakeSMSUserAdapter) CreateSMSCode(ctx context.Context, userID, purpose, hashedCode string, expiresAt time.Time) error {
+	f.smsCodes[purpose] = hashedCode
+	return nil
+}
+
+func (f *fakeSMSUserAdapter) SMSCodesSentSince(ctx context.Context, userID string, since time.Time) ([]time.Time, error) {
+	return nil, nil
+}
+
+func (f *fakeSMSUserAdapter) ConsumeSMSCode(ctx context.Context, userID, purpose, hashedCode string, maxAttempts int) (bool, error) {
+	if f.smsCodes[purpose] != hashedCode {
+		return false, nil
+	}
+	delete(f.smsCodes, purpose)
+	return true, nil
+}
+
+// fakeChallengeSessionAdapter adds two-factor challenges to fakeSessionAdapter
+type fakeChallengeSessionAdapter struct {
+	*fakeSessionAdapter
+	challenges map[string]*TwoFactorChallenge
+}
+
+func (f *fakeChallengeSessionAdapter) CreateTwoFactorChallenge(ctx context.Context, hashedToken string, challenge TwoFactorChallenge) error {
+	f.challenges[hashedToken] = &challenge
+	return nil
+}
+
+func (f *fakeChallengeSessionAdapter) GetTwoFactorChallenge(ctx context.Context, hashedToken string) (*TwoFactorChallenge, error) {
+	challenge, ok := f.challenges[hashedToken]
+	if !ok {
+		return nil, ErrInvalidTwoFactorChallenge
+	}
+	copied := *challenge
+	return &copied, nil
+}
+
+func (f *fakeChallengeSessionAdapter) RecordTwoFactorAttempt(ctx context.Context, hashedToken string) error {
+	f.challenges[hashedToken].Attempts++
+	return nil
+}
+
+func (f *fakeChallengeSessionAdapter) DeleteTwoFactorChallenge(ctx context.Context, hashedToken string) (bool, error) {
+	_, ok := f.challenges[hashedToken]
+	delete(f.challenges, hashedToken)
+	return ok, nil
+}
+
+func newTwoFactorTestManager(t *testing.T) (*AuthManager, *fakeTwoFactorUserAdapter, *fakeChallengeSessionAdapter, *FakeClock) {
+	t.Helper()
+	clock := NewFakeClock(time.Date(2024, 2, 11, 12, 0, 0, 0, time.UTC))
+	config := DefaultAuthConfig()
+	config.Clock = clock
+	_, users, sessions := newTestAuthManager(config)
+	twoFactorUsers := &fakeTwoFactorUserAdapter{fakeUserAdapter: users}
+	challengeSessions := &fakeChallengeSessionAdapter{fakeSessionAdapter: sessions, challenges: make(map[string]*TwoFactorChallenge)}
+	return NewAuthManager(twoFactorUsers, challengeSessions, config), twoFactorUsers, challengeSessions, clock
+}
+
+// currentTOTPCode returns the code an authenticator app would show now
+func currentTOTPCode(t *testing.T, m *AuthManager, secret string) string {
+	t.Helper()
+	key, err := totpEncoding.DecodeString(secret)
+	require.NoError(t, err)
+	return totpCode(key, m.config.Clock.Now().Unix()/30)
+}
+
+// enableTOTP enrolls and confirms TOTP for the test user, returning the
+// secret and recovery codes
+func enableTOTP(t *testing.T, m *AuthManager, clock *FakeClock) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	enrollment, err := m.EnrollTOTP(ctx, "1", "testuser")
+	require.NoError(t, err)
+	codes, err := m.ConfirmTOTP(ctx, "1", currentTOTPCode(t, m, enrollment.Secret))
+	require.NoError(t, err)
+	// Move past the step used to confirm
+	clock.Advance(TOTPPeriod)
+	return enrollment.Secret, codes
+}
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	// Test vectors of RFC 6238 (SHA-1), truncated to 6 digits
+	key := []byte("12345678901234567890")
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		assert.Equal(t, want, totpCode(key, unix/30), "time %d", unix)
+	}
+}
+
+func TestAuthManager_TOTPEnrollment(t *testing.T) {
+	m, users, _, _ := newTwoFactorTestManager(t)
+	ctx := context.Background()
+
+	enrollment, err := m.EnrollTOTP(ctx, "1", "test@example.com")
+	require.NoError(t, err)
+	uri, err := url.Parse(enrollment.URI)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "/GoSvelteKit:test@example.com", uri.Path)
+	assert.Equal(t, enrollment.Secret, uri.Query().Get("secret"))
+	assert.Equal(t, "GoSvelteKit", uri.Query().Get("issuer"))
+
+	// Not enabled until confirmed
+	enabled, err := m.TOTPEnabled(ctx, "1")
+	require.NoError(t, err)
+	assert.False(t, enabled)
+	assert.ErrorIs(t, m.VerifyTOTP(ctx, "1", currentTOTPCode(t, m, enrollment.Secret)), ErrTOTPNotEnrolled)
+
+	_, err = m.ConfirmTOTP(ctx, "1", "000000")
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+	code := currentTOTPCode(t, m, enrollment.Secret)
+	recoveryCodes, err := m.ConfirmTOTP(ctx, "1", code)
+	require.NoError(t, err)
+	assert.Len(t, recoveryCodes, RecoveryCodeCount)
+	enabled, err = m.TOTPEnabled(ctx, "1")
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	// The confirmation code can't be replayed
+	assert.ErrorIs(t, m.VerifyTOTP(ctx, "1", code), ErrInvalidTwoFactorCode)
+	_, err = m.EnrollTOTP(ctx, "1", "test@example.com")
+	assert.ErrorIs(t, err, ErrTOTPAlreadyEnabled)
+
+	require.NoError(t, m.DisableTOTP(ctx, "1"))
+	enabled, err = m.TOTPEnabled(ctx, "1")
+	require.NoError(t, err)
+	assert.False(t, enabled)
+	assert.Empty(t, users.recoveryCodes, "disabling TOTP drops the recovery codes")
+}
+
+func TestAuthManager_TOTPSkew(t *testing.T) {
+	m, _, _, clock := newTwoFactorTestManager(t)
+	ctx := context.Background()
+	secret, _ := enableTOTP(t, m, clock)
+
+	code := currentTOTPCode(t, m, secret)
+	clock.Advance(TOTPPeriod)
+	assert.NoError(t, m.VerifyTOTP(ctx, "1", code), "the previous step is still accepted")
+
+	code = currentTOTPCode(t, m, secret)
+	clock.Advance(2 * TOTPPeriod)
+	assert.ErrorIs(t, m.VerifyTOTP(ctx, "1", code), ErrInvalidTwoFactorCode)
+}
+
+func TestAuthManager_TwoFactorLogin(t *testing.T) {
+	ctx := context.Background()
+	metadata := SessionMetadata{UserAgent: "test-agent", IP: "203.0.113.7"}
+	var (
+		m             *AuthManager
+		sessions      *fakeChallengeSessionAdapter
+		clock         *FakeClock
+		secret        string
+		recoveryCodes []string
+	)
+	setup := func(t *testing.T) {
+		m, _, sessions, clock = newTwoFactorTestManager(t)
+		secret, recoveryCodes = enableTOTP(t, m, clock)
+	}
+	login := func(t *testing.T) *TwoFactorRequiredError {
+		t.Helper()
+		session, _, err := m.Login(ctx, "testuser", "Password123!", metadata)
+		require.ErrorIs(t, err, ErrTwoFactorRequired)
+		assert.Nil(t, session)
+		var required *TwoFactorRequiredError
+		require.True(t, errors.As(err, &required))
+		assert.Equal(t, "1", required.UserID)
+		assert.Equal(t, clock.Now().Add(5*time.Minute), required.ExpiresAt)
+		return required
+	}
+
+	t.Run("with a TOTP code", func(t *testing.T) {
+		setup(t)
+		challenge := login(t)
+		assert.Empty(t, sessions.sessions, "no session before the second factor")
+		assert.NotContains(t, sessions.challenges, challenge.Token, "only the hash of the token is stored")
+
+		_, _, err := m.CompleteTwoFactorLogin(ctx, challenge.Token, "000000")
+		assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+
+		session, user, err := m.CompleteTwoFactorLogin(ctx, challenge.Token, currentTOTPCode(t, m, secret))
+		require.NoError(t, err)
+		assert.True(t, session.Fresh)
+		assert.Equal(t, "1", user.ID)
+		assert.Equal(t, metadata.IP, sessions.sessions[session.ID].IP, "the session keeps the metadata of the login")
+
+		_, _, err = m.CompleteTwoFactorLogin(ctx, challenge.Token, currentTOTPCode(t, m, secret))
+		assert.ErrorIs(t, err, ErrInvalidTwoFactorChallenge, "a challenge is used once")
+	})
+
+	t.Run("with a recovery code", func(t *testing.T) {
+		setup(t)
+		challenge := login(t)
+		_, _, err := m.CompleteTwoFactorLogin(ctx, challenge.Token, strings.ToUpper(recoveryCodes[0]))
+		require.NoError(t, err)
+
+		challenge = login(t)
+		_, _, err = m.CompleteTwoFactorLogin(ctx, challenge.Token, recoveryCodes[0])
+		assert.ErrorIs(t, err, ErrInvalidTwoFactorCode, "recovery codes are single use")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		setup(t)
+		challenge := login(t)
+		clock.Advance(6 * time.Minute)
+		_, _, err := m.CompleteTwoFactorLogin(ctx, challenge.Token, currentTOTPCode(t, m, secret))
+		assert.ErrorIs(t, err, ErrInvalidTwoFactorChallenge)
+		assert.Empty(t, sessions.challenges)
+	})
+
+	t.Run("too many wrong codes", func(t *testing.T) {
+		setup(t)
+		challenge := login(t)
+		for range m.config.TwoFactorMaxAttempts - 1 {
+			_, _, err := m.CompleteTwoFactorLogin(ctx, challenge.Token, "000000")
+			assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+		}
+		_, _, err := m.CompleteTwoFactorLogin(ctx, challenge.Token, "000000")
+		assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+
+		// Neither this challenge nor new ones accept the right code now
+		_, _, err = m.CompleteTwoFactorLogin(ctx, challenge.Token, currentTOTPCode(t, m, secret))
+		assert.ErrorIs(t, err, ErrInvalidTwoFactorChallenge)
+		_, _, err = m.CompleteTwoFactorLogin(ctx, login(t).Token, currentTOTPCode(t, m, secret))
+		assert.ErrorIs(t, err, ErrAccountLocked)
+
+		clock.Advance(m.config.LockoutDuration + time.Minute)
+		_, _, err = m.CompleteTwoFactorLogin(ctx, login(t).Token, currentTOTPCode(t, m, secret))
+		assert.NoError(t, err)
+	})
+}
+
+func TestAuthManager_TwoFactorLogin_RequiresChallengeSupport(t *testing.T) {
+	m, users, _, clock := newTwoFactorTestManager(t)
+	enableTOTP(t, m, clock)
+
+	// A session adapter without challenges must not skip the second factor
+	m = NewAuthManager(users, newFakeSessionAdapter(), m.config)
+	_, _, err := m.Login(context.Background(), "testuser", "Password123!", SessionMetadata{})
+	assert.ErrorIs(t, err, ErrTwoFactorChallengesUnsupported)
+}
+
+func TestAuthManager_TwoFactorLogin_SMS(t *testing.T) {
+	ctx := context.Background()
+	m, users, sessions, _ := newTwoFactorTestManager(t)
+	smsUsers := &fakeSMSUserAdapter{fakeTwoFactorUserAdapter: users, smsCodes: make(map[string]string)}
+	m = NewAuthManager(smsUsers, sessions, m.config)
+	users.user.Attributes = map[string]any{AttrTwoFactorMethod: TwoFactorMethodSMS}
+
+	// No TOTP secret: the method alone asks for the second factor, on every login path
+	_, _, err := m.LoginExternal(ctx, "1", SessionMetadata{})
+	assert.ErrorIs(t, err, ErrTwoFactorRequired)
+	session, _, err := m.Login(ctx, "testuser", "Password123!", SessionMetadata{})
+	var required *TwoFactorRequiredError
+	require.True(t, errors.As(err, &required))
+	assert.Nil(t, session)
+	assert.Equal(t, TwoFactorMethodSMS, required.Method)
+	assert.Empty(t, sessions.sessions)
+
+	code, err := m.IssueSMSCode(ctx, "1", SMSPurposeTwoFactor)
+	require.NoError(t, err)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	_, _, err = m.CompleteTwoFactorLogin(ctx, required.Token, wrong)
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+
+	session, user, err := m.CompleteTwoFactorLogin(ctx, required.Token, code)
+	require.NoError(t, err)
+	assert.Equal(t, "1", user.ID)
+	assert.Contains(t, sessions.sessions, session.ID)
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"gosveltekit/internal/logger"
+)
+
+// SessionHandle is the public identifier of a session, safe to show to its
+// user: the session ID itself is a bearer credential and can't be derived
+// from it
+func SessionHandle(sessionID string) string {
+	sum := sha256.Sum256([]byte("session-handle\x00" + sessionID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ActiveSessions returns the live sessions of a user, newest first, as shown
+// to the user: expired, idle and impersonation sessions are left out.
+// Returns ErrSessionListUnsupported without a SessionListAdapter.
+func (m *AuthManager) ActiveSessions(ctx context.Context, userID string) ([]*Session, error) {
+	sessions, err := m.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.config.Clock.Now()
+	active := make([]*Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.IsImpersonation() || m.sessionDead(session, now) {
+			continue
+		}
+		active = append(active, session)
+	}
+	return active, nil
+}
+
+// RevokeSessionByHandle ends the active session of userID with the given
+// SessionHandle. Returns ErrSessionNotFound when the user has no such session.
+func (m *AuthManager) RevokeSessionByHandle(ctx context.Context, userID, handle string) error {
+	sessions, err := m.ActiveSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if SessionHandle(session.ID) != handle {
+			continue
+		}
+		if err := m.sessionAdapter.DeleteSession(ctx, session.ID); err != nil {
+			logger.Error("Erro ao revogar sessão", "error", err, "user_id", userID)
+			return err
+		}
+		return nil
+	}
+	return ErrSessionNotFound
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrInvalidUsername is wrapped by every UsernamePolicy violation
+	ErrInvalidUsername = errors.New("nome de usuário inválido")
+
+	// Rules of UsernamePolicy, wrapped together with ErrInvalidUsername
+	ErrUsernameTooShort   = errors.New("muito curto")
+	ErrUsernameTooLong    = errors.New("muito longo")
+	ErrUsernameCharacters = errors.New("contém caracteres não permitidos")
+	ErrUsernameReserved   = errors.New("nome reservado")
+)
+
+// DefaultUsernamePattern allows letters, digits, dots, hyphens and underscores
+var DefaultUsernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// DefaultReservedUsernames are names users can't register, as they could pass
+// for staff or the system
+var DefaultReservedUsernames = []string{"admin", "administrator", "root", "support", "system"}
+
+// UsernamePolicy holds the rules a username must follow
+type UsernamePolicy struct {
+	MinLength int            // Default: 3
+	MaxLength int            // Default: 50
+	Pattern   *regexp.Regexp // Allowed characters, default DefaultUsernamePattern
+
+	// Reserved names are rejected unless the caller allows them (admins).
+	// Matching is case-insensitive.
+	Reserved []string
+}
+
+// DefaultUsernamePolicy returns the default username rules
+func DefaultUsernamePolicy() UsernamePolicy {
+	return UsernamePolicy{
+		MinLength: 3,
+		MaxLength: 50,
+		Pattern:   DefaultUsernamePattern,
+		Reserved:  DefaultReservedUsernames,
+	}
+}
+
+// Validate checks username against the policy. allowReserved skips the
+// reserved names check, for admins. The error wraps ErrInvalidUsername and
+// the rule violated, e.g. ErrUsernameTooShort.
+func (p UsernamePolicy) Validate(username string, allowReserved bool) error {
+	length := len([]rune(username))
+	switch {
+	case p.MinLength > 0 && length < p.MinLength:
+		return fmt.Errorf("%w: %w (mínimo de %d caracteres)", ErrInvalidUsername, ErrUsernameTooShort, p.MinLength)
+	case p.MaxLength > 0 && length > p.MaxLength:
+		return fmt.Errorf("%w: %w (máximo de %d caracteres)", ErrInvalidUsername, ErrUsernameTooLong, p.MaxLength)
+	}
+
+	if p.Pattern != nil && !p.Pattern.MatchString(username) {
+		return fmt.Errorf("%w: %w", ErrInvalidUsername, ErrUsernameCharacters)
+	}
+
+	if !allowReserved {
+		for _, reserved := range p.Reserved {
+			if strings.EqualFold(username, reserved) {
+				return fmt.Errorf("%w: %w", ErrInvalidUsername, ErrUsernameReserved)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsernamePolicy_Validate(t *testing.T) {
+	policy := DefaultUsernamePolicy()
+
+	tests := []struct {
+		name          string
+		username      string
+		allowReserved bool
+		wantRule      error
+	}{
+		{name: "valid", username: "alice.smith"},
+		{name: "too short", username: "al", wantRule: ErrUsernameTooShort},
+		{name: "too long", username: "a123456789012345678901234567890123456789012345678901", wantRule: ErrUsernameTooLong},
+		{name: "invalid characters", username: "alice smith", wantRule: ErrUsernameCharacters},
+		{name: "reserved", username: "admin", wantRule: ErrUsernameReserved},
+		{name: "reserved in another case", username: "SuPPort", wantRule: ErrUsernameReserved},
+		{name: "reserved allowed for admins", username: "Root", allowReserved: true},
+		{name: "format still applies to admins", username: "root!", allowReserved: true, wantRule: ErrUsernameCharacters},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.username, tt.allowReserved)
+			if tt.wantRule == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidUsername)
+			assert.ErrorIs(t, err, tt.wantRule)
+		})
+	}
+
+	t.Run("custom rules", func(t *testing.T) {
+		custom := UsernamePolicy{MinLength: 5, Pattern: regexp.MustCompile(`^[a-z]+$`), Reserved: []string{"billing"}}
+		assert.NoError(t, custom.Validate("alice", false))
+		assert.ErrorContains(t, custom.Validate("bob", false), "mínimo de 5 caracteres")
+		assert.ErrorIs(t, custom.Validate("Alice", false), ErrUsernameCharacters)
+		assert.ErrorIs(t, custom.Validate("billing", false), ErrUsernameReserved)
+		assert.NoError(t, custom.Validate("admin", false), "only the configured names are reserved")
+	})
+}
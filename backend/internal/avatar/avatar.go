@@ -0,0 +1,114 @@
+// Package avatar turns uploaded pictures into square avatars: it checks the
+// actual format of the file (not the declared content type), crops the
+// center square and scales it down.
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif" // decoder registration
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("formato de imagem não suportado, use JPEG, PNG ou GIF")
+	ErrInvalidImage      = errors.New("imagem inválida ou corrompida")
+	ErrImageTooLarge     = errors.New("imagem com dimensões grandes demais")
+)
+
+// MaxPixels bounds the dimensions of uploads, checked before decoding: a
+// small compressed file can otherwise expand into gigabytes of pixels
+const MaxPixels = 4096 * 4096
+
+// jpegQuality is the quality avatars are encoded with
+const jpegQuality = 85
+
+// Image is an encoded avatar
+type Image struct {
+	Data        []byte
+	ContentType string
+	Ext         string // file extension, with the dot
+}
+
+// Process decodes a JPEG, PNG or GIF picture (the first frame for animated
+// GIFs), crops its center square and scales it to size × size pixels. Smaller
+// pictures are cropped but not enlarged. JPEGs stay JPEGs; PNGs and GIFs
+// become PNGs, keeping their transparency.
+func Process(data []byte, size int) (*Image, error) {
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	avatar := Resize(CropSquare(src), size)
+	var out bytes.Buffer
+	if format == "jpeg" {
+		if err := jpeg.Encode(&out, avatar, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		return &Image{Data: out.Bytes(), ContentType: "image/jpeg", Ext: ".jpg"}, nil
+	}
+	if err := png.Encode(&out, avatar); err != nil {
+		return nil, err
+	}
+	return &Image{Data: out.Bytes(), ContentType: "image/png", Ext: ".png"}, nil
+}
+
+// CropSquare copies the largest centered square of src
+func CropSquare(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), src, origin, draw.Src)
+	return square
+}
+
+// Resize scales a square image down to size × size by averaging the source
+// pixels covered by each destination pixel (a box filter, which doesn't
+// alias when shrinking). Images already that small are returned as is.
+func Resize(src *image.RGBA, size int) *image.RGBA {
+	side := src.Bounds().Dx()
+	if side <= size {
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for dy := 0; dy < size; dy++ {
+		y0, y1 := dy*side/size, (dy+1)*side/size
+		for dx := 0; dx < size; dx++ {
+			x0, x1 := dx*side/size, (dx+1)*side/size
+			var sum [4]int
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride+x0*4 : y*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			offset := dy*dst.Stride + dx*4
+			for i := range sum {
+				dst.Pix[offset+i] = uint8((sum[i] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,106 @@
+package avatar
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// picture is a width × height image, red on the left half and blue on the right
+func picture(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= width/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encode(t *testing.T, img image.Image, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	switch format {
+	case "png":
+		require.NoError(t, png.Encode(&buf, img))
+	case "jpeg":
+		require.NoError(t, jpeg.Encode(&buf, img, nil))
+	case "gif":
+		require.NoError(t, gif.Encode(&buf, img, nil))
+	}
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		format      string
+		contentType string
+		decode      func(*bytes.Reader) (image.Image, error)
+	}{
+		{"png", "image/png", func(r *bytes.Reader) (image.Image, error) { return png.Decode(r) }},
+		{"gif", "image/png", func(r *bytes.Reader) (image.Image, error) { return png.Decode(r) }},
+		{"jpeg", "image/jpeg", func(r *bytes.Reader) (image.Image, error) { return jpeg.Decode(r) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			result, err := Process(encode(t, picture(300, 200), tt.format), 64)
+			require.NoError(t, err)
+			assert.Equal(t, tt.contentType, result.ContentType)
+
+			img, err := tt.decode(bytes.NewReader(result.Data))
+			require.NoError(t, err)
+			assert.Equal(t, image.Rect(0, 0, 64, 64), img.Bounds())
+
+			// The center square keeps both halves
+			r, _, b, _ := img.At(8, 32).RGBA()
+			assert.Greater(t, r, b, "left is red")
+			r, _, b, _ = img.At(56, 32).RGBA()
+			assert.Greater(t, b, r, "right is blue")
+		})
+	}
+}
+
+func TestProcess_SmallPictureIsNotEnlarged(t *testing.T) {
+	result, err := Process(encode(t, picture(20, 30), "png"), 64)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(result.Data))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 20, 20), img.Bounds())
+}
+
+func TestProcess_Rejected(t *testing.T) {
+	_, err := Process([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"), 64)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	truncated := encode(t, picture(50, 50), "png")[:60]
+	_, err = Process(truncated, 64)
+	assert.ErrorIs(t, err, ErrInvalidImage)
+
+	// Only the header is read to reject huge dimensions
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 5000, 5000))))
+	_, err = Process(buf.Bytes(), 64)
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+}
+
+func TestResize_AveragesPixels(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.Set(0, 0, color.RGBA{R: 200, A: 255})
+	src.Set(1, 1, color.RGBA{R: 200, A: 255})
+	src.Set(0, 1, color.RGBA{A: 255})
+	src.Set(1, 0, color.RGBA{A: 255})
+
+	dst := Resize(src, 1)
+	assert.Equal(t, color.RGBA{R: 100, A: 255}, dst.RGBAAt(0, 0))
+}
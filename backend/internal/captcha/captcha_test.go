@@ -0,0 +1,64 @@
+package captcha
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSiteVerifier(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "test-secret", r.PostForm.Get("secret"))
+		assert.Equal(t, "10.0.0.1", r.PostForm.Get("remoteip"))
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostForm.Get("response") {
+		case "good-token":
+			w.Write([]byte(`{"success": true}`))
+		case "broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+		}
+	}))
+	defer server.Close()
+
+	verifier := NewSiteVerifier(server.URL, "test-secret")
+	ctx := context.Background()
+
+	assert.NoError(t, verifier.Verify(ctx, "good-token", "10.0.0.1"))
+
+	err := verifier.Verify(ctx, "bad-token", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrVerificationFailed)
+	assert.ErrorContains(t, err, "invalid-input-response")
+
+	assert.ErrorIs(t, verifier.Verify(ctx, "", "10.0.0.1"), ErrMissingToken)
+
+	// Provider failures are errors, but not a rejected token
+	err = verifier.Verify(ctx, "broken", "10.0.0.1")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrVerificationFailed)
+}
+
+func TestNew(t *testing.T) {
+	verifier, err := New("", "")
+	require.NoError(t, err)
+	assert.Equal(t, NoopVerifier{}, verifier)
+	assert.NoError(t, verifier.Verify(context.Background(), "", ""))
+
+	verifier, err = New(ProviderRecaptcha, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, RecaptchaVerifyURL, verifier.(*SiteVerifier).VerifyURL)
+
+	verifier, err = New(ProviderHCaptcha, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, HCaptchaVerifyURL, verifier.(*SiteVerifier).VerifyURL)
+
+	_, err = New("turnstile", "secret")
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
@@ -0,0 +1,106 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/webhooks"
+
+	"gorm.io/gorm"
+)
+
+// AccountPurgerConfig configures the account purger
+type AccountPurgerConfig struct {
+	Interval time.Duration // Default: 1 hour
+
+	// Events, if set, is notified of every purged account (user.deleted)
+	Events webhooks.Publisher
+}
+
+// AccountPurger permanently deletes the accounts whose scheduled deletion
+// date has passed, together with everything stored about them
+type AccountPurger struct {
+	db     *gorm.DB
+	config AccountPurgerConfig
+}
+
+// NewAccountPurger creates a new AccountPurger
+func NewAccountPurger(db *gorm.DB, config AccountPurgerConfig) *AccountPurger {
+	if config.Interval <= 0 {
+		config.Interval = time.Hour
+	}
+	return &AccountPurger{db: db, config: config}
+}
+
+// Run purges every Interval until ctx is cancelled
+func (p *AccountPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := p.Purge(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logger.Error("Erro ao excluir contas agendadas", "error", err)
+		} else if purged > 0 {
+			logger.Info("Contas excluídas definitivamente", "total", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes every account scheduled for deletion before now and returns
+// how many were deleted. Each account is deleted in its own transaction with
+// its sessions, recovery codes, password history and SMS codes; users are
+// removed for good, not soft-deleted.
+func (p *AccountPurger) Purge(ctx context.Context) (int, error) {
+	now := time.Now()
+	var users []models.User
+	err := p.db.WithContext(ctx).Select("id", "uuid", "username").
+		Where("scheduled_deletion_at IS NOT NULL AND scheduled_deletion_at <= ?", now).
+		Find(&users).Error
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, user := range users {
+		id := user.ID
+		deleted := false
+		err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// Re-checked here: the account may have been restored meanwhile
+			res := tx.Unscoped().Where("scheduled_deletion_at IS NOT NULL AND scheduled_deletion_at <= ?", now).Delete(&models.User{}, id)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			for _, model := range []any{&models.Session{}, &models.RecoveryCode{}, &models.PasswordHistory{}, &models.SMSCode{}, &models.ExternalIdentity{}, &models.TOTPSecret{}, &models.TwoFactorChallenge{}, &models.RefreshToken{}, &models.APIKey{}, &models.MagicLink{}} {
+				if err := tx.Where("user_id = ?", id).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			deleted = true
+			return nil
+		})
+		if err != nil {
+			return purged, err
+		}
+		if deleted {
+			logger.Warn("Conta excluída definitivamente", "user_id", id)
+			purged++
+			if p.config.Events != nil {
+				p.config.Events.Publish(ctx, webhooks.EventUserDeleted, map[string]any{
+					"user_id":  user.PublicID(),
+					"username": user.Username,
+				})
+			}
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,52 @@
+package cleanup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountPurger_Purge(t *testing.T) {
+	db := setupTestDB(t)
+	purger := NewAccountPurger(db, AccountPurgerConfig{})
+	ctx := context.Background()
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+
+	expired := models.User{Username: "expired", Email: "expired@example.com", PasswordHash: "x", ScheduledDeletionAt: &past}
+	pending := models.User{Username: "pending", Email: "pending@example.com", PasswordHash: "x", ScheduledDeletionAt: &future}
+	kept := models.User{Username: "kept", Email: "kept@example.com", PasswordHash: "x"}
+	for _, user := range []*models.User{&expired, &pending, &kept} {
+		require.NoError(t, db.Create(user).Error)
+		require.NoError(t, db.Create(&models.Session{ID: user.Username, UserID: user.ID, ExpiresAt: future}).Error)
+		require.NoError(t, db.Create(&models.RecoveryCode{UserID: user.ID, CodeHash: user.Username}).Error)
+		require.NoError(t, db.Create(&models.PasswordHistory{UserID: user.ID, PasswordHash: "x"}).Error)
+	}
+
+	purged, err := purger.Purge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	// Accounts still within the window, or not scheduled, are untouched
+	var users []models.User
+	require.NoError(t, db.Unscoped().Order("id").Find(&users).Error)
+	require.Len(t, users, 2)
+	assert.Equal(t, "pending", users[0].Username)
+	assert.Equal(t, "kept", users[1].Username)
+
+	for _, model := range []any{&models.Session{}, &models.RecoveryCode{}, &models.PasswordHistory{}} {
+		var n int64
+		require.NoError(t, db.Model(model).Where("user_id = ?", expired.ID).Count(&n).Error)
+		assert.Zero(t, n, "%T of the purged account", model)
+		require.NoError(t, db.Model(model).Where("user_id = ?", pending.ID).Count(&n).Error)
+		assert.Equal(t, int64(1), n, "%T of the pending account", model)
+	}
+
+	purged, err = purger.Purge(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+}
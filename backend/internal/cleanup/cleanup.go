@@ -0,0 +1,343 @@
+// Package cleanup prunes expired tokens: sessions, password reset tokens, SMS
+// codes, two-factor login challenges, refresh tokens and failed login
+// counters.
+//
+// Expired tokens are already rejected when used, so pruning only keeps the
+// tables small. Rows are deleted in batches, so a large backlog doesn't hold
+// long locks on the tables. The Worker prunes periodically, either on its own (Run) or as
+// the scheduled JobPrune job; Prune can also be triggered on demand (e.g. from
+// an admin endpoint) and runs the exact same queries.
+//
+// AccountPurger deletes for good the accounts whose scheduled deletion date
+// has passed.
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	gormadapter "gosveltekit/internal/auth/adapter/gorm"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/metrics"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// JobPrune is the job type of Worker.Job
+const JobPrune = "cleanup.tokens"
+
+// Token types, used as keys of Result and as metric labels
+const (
+	TokenSession       = "session"
+	TokenPasswordReset = "password_reset"
+	TokenSMSCode       = "sms_code"
+	TokenTwoFactor     = "two_factor_challenge"
+	TokenRefresh       = "refresh_token"
+	TokenLoginAttempt  = "login_attempt"
+	TokenMagicLink     = "magic_link"
+)
+
+// TokenTypes lists every token type handled by the pruner
+var TokenTypes = []string{TokenSession, TokenPasswordReset, TokenSMSCode, TokenTwoFactor, TokenRefresh, TokenLoginAttempt, TokenMagicLink}
+
+// Result holds how many tokens of each type were pruned
+type Result map[string]int64
+
+// Total returns the number of tokens pruned across all types
+func (r Result) Total() int64 {
+	var total int64
+	for _, n := range r {
+		total += n
+	}
+	return total
+}
+
+// Counts holds how many tokens of a type are still usable (pending) and how
+// many are expired but not pruned yet
+type Counts struct {
+	Pending int64 `json:"pending"`
+	Expired int64 `json:"expired"`
+}
+
+// SessionStore deletes expired sessions, see auth.SessionAdapter
+type SessionStore interface {
+	DeleteExpiredSessions(ctx context.Context, limit int) (int64, error)
+}
+
+// WorkerConfig configures the cleanup worker
+type WorkerConfig struct {
+	Interval  time.Duration // Default: 1 hour
+	BatchSize int           // Rows deleted per query. Default: 1000
+
+	// Sessions removes expired sessions, so stores that expire them by
+	// themselves (Redis) are skipped. Default: the sessions table of db
+	Sessions SessionStore
+}
+
+// Worker prunes expired tokens periodically
+type Worker struct {
+	db     *gorm.DB
+	config WorkerConfig
+}
+
+// NewWorker creates a new cleanup Worker
+func NewWorker(db *gorm.DB, config WorkerConfig) *Worker {
+	if config.Interval <= 0 {
+		config.Interval = time.Hour
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+	if config.Sessions == nil {
+		config.Sessions = gormadapter.NewSessionAdapter(db)
+	}
+	return &Worker{db: db, config: config}
+}
+
+// Run prunes every Interval until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Job(ctx, nil); err != nil && !errors.Is(err, context.Canceled) {
+			logger.Error("Erro ao remover tokens expirados", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Job prunes once, as a jobs.Handler for JobPrune. The payload is ignored
+func (w *Worker) Job(ctx context.Context, _ []byte) error {
+	result, err := w.Prune(ctx)
+	if err != nil {
+		return err
+	}
+	if total := result.Total(); total > 0 {
+		logger.FromContext(ctx).Info("Tokens expirados removidos", "total", total, "by_type", result)
+	}
+	return nil
+}
+
+// Errors holds the token types whose pruning failed, and why. Prune returns
+// it when only some types failed, so callers can report each type.
+type Errors map[string]error
+
+// Error lists the failed types in the order of TokenTypes
+func (e Errors) Error() string {
+	var msgs []string
+	for _, tokenType := range TokenTypes {
+		if err, ok := e[tokenType]; ok {
+			msgs = append(msgs, tokenType+": "+err.Error())
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Prune removes every expired token and returns how many were removed per
+// type, including the batches removed before an error. A type that fails
+// doesn't stop the others: their errors come back as Errors, unless ctx
+// ends, which stops the run with ctx's error. Password reset tokens live on
+// the user row, so they are cleared instead of deleted. Metrics are
+// refreshed afterwards.
+func (w *Worker) Prune(ctx context.Context) (Result, error) {
+	result := Result{}
+	err := w.prune(ctx, result)
+	for tokenType, n := range result {
+		metrics.TokensPruned.WithLabelValues(tokenType).Add(float64(n))
+	}
+	if err != nil {
+		return result, err
+	}
+	if _, err := w.RefreshMetrics(ctx); err != nil {
+		logger.Warn("Falha ao atualizar métricas de tokens", "error", err)
+	}
+	return result, nil
+}
+
+func (w *Worker) prune(ctx context.Context, result Result) error {
+	db := w.db.WithContext(ctx)
+	batchSize := w.config.BatchSize
+	now := time.Now()
+
+	steps := map[string]func() (int64, error){
+		TokenSession: func() (int64, error) {
+			var deleted int64
+			for {
+				n, err := w.config.Sessions.DeleteExpiredSessions(ctx, batchSize)
+				deleted += n
+				if err != nil || n < int64(batchSize) {
+					return deleted, err
+				}
+			}
+		},
+		TokenPasswordReset: func() (int64, error) {
+			var cleared int64
+			for {
+				var ids []uint
+				err := db.Model(&models.User{}).
+					Where("reset_token <> '' AND reset_token_expiry < ?", now).
+					Limit(batchSize).
+					Pluck("id", &ids).Error
+				if err != nil || len(ids) == 0 {
+					return cleared, err
+				}
+				res := db.Model(&models.User{}).
+					Where("id IN ?", ids).
+					Updates(map[string]any{"reset_token": "", "reset_token_expiry": time.Time{}})
+				cleared += res.RowsAffected
+				if res.Error != nil || len(ids) < batchSize {
+					return cleared, res.Error
+				}
+			}
+		},
+		// Used codes can't be verified again either
+		TokenSMSCode: func() (int64, error) {
+			return deleteInBatches[models.SMSCode](db, batchSize, "expires_at < ? OR used_at IS NOT NULL", now)
+		},
+		TokenTwoFactor: func() (int64, error) {
+			return deleteInBatches[models.TwoFactorChallenge](db, batchSize, "expires_at < ?", now)
+		},
+		// Used refresh tokens are kept until they expire, to detect their reuse
+		TokenRefresh: func() (int64, error) {
+			return deleteInBatches[models.RefreshToken](db, batchSize, "expires_at < ?", now)
+		},
+		TokenLoginAttempt: func() (int64, error) {
+			return deleteInBatches[models.LoginAttempt](db, batchSize, "expires_at < ?", now)
+		},
+		// Links count towards the sending rate limit for an hour, used or not
+		TokenMagicLink: func() (int64, error) {
+			return deleteInBatches[models.MagicLink](db, batchSize, "(expires_at < ? OR used_at IS NOT NULL) AND created_at < ?", now, now.Add(-time.Hour))
+		},
+	}
+
+	failed := Errors{}
+	for _, tokenType := range TokenTypes {
+		n, err := steps[tokenType]()
+		result[tokenType] += n
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			failed[tokenType] = err
+		}
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// deleteInBatches deletes the rows of T matching query, at most batchSize per
+// statement, and returns how many were deleted. Each batch is loaded first
+// and deleted by primary key, which works on every supported database.
+func deleteInBatches[T any](db *gorm.DB, batchSize int, query string, args ...any) (int64, error) {
+	var deleted int64
+	for {
+		var batch []T
+		if err := db.Where(query, args...).Limit(batchSize).Find(&batch).Error; err != nil {
+			return deleted, err
+		}
+		if len(batch) == 0 {
+			return deleted, nil
+		}
+		res := db.Delete(&batch)
+		deleted += res.RowsAffected
+		if res.Error != nil || len(batch) < batchSize {
+			return deleted, res.Error
+		}
+	}
+}
+
+// Count returns the pending and expired tokens of each type
+func (w *Worker) Count(ctx context.Context) (map[string]Counts, error) {
+	db := w.db.WithContext(ctx)
+	now := time.Now()
+	counts := make(map[string]Counts, len(TokenTypes))
+
+	var c Counts
+	if err := db.Model(&models.Session{}).Where("expires_at >= ?", now).Count(&c.Pending).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.Session{}).Where("expires_at < ?", now).Count(&c.Expired).Error; err != nil {
+		return nil, err
+	}
+	counts[TokenSession] = c
+
+	c = Counts{}
+	if err := db.Model(&models.User{}).Where("reset_token <> '' AND reset_token_expiry >= ?", now).Count(&c.Pending).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.User{}).Where("reset_token <> '' AND reset_token_expiry < ?", now).Count(&c.Expired).Error; err != nil {
+		return nil, err
+	}
+	counts[TokenPasswordReset] = c
+
+	c = Counts{}
+	if err := db.Model(&models.SMSCode{}).Where("expires_at >= ? AND used_at IS NULL", now).Count(&c.Pending).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.SMSCode{}).Where("expires_at < ? OR used_at IS NOT NULL", now).Count(&c.Expired).Error; err != nil {
+		return nil, err
+	}
+	counts[TokenSMSCode] = c
+
+	c = Counts{}
+	if err := db.Model(&models.TwoFactorChallenge{}).Where("expires_at >= ?", now).Count(&c.Pending).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.TwoFactorChallenge{}).Where("expires_at < ?", now).Count(&c.Expired).Error; err != nil {
+		return nil, err
+	}
+	counts[TokenTwoFactor] = c
+
+	c = Counts{}
+	if err := db.Model(&models.RefreshToken{}).Where("expires_at >= ?", now).Count(&c.Pending).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.RefreshToken{}).Where("expires_at < ?", now).Count(&c.Expired).Error; err != nil {
+		return nil, err
+	}
+	counts[TokenRefresh] = c
+
+	c = Counts{}
+	if err := db.Model(&models.LoginAttempt{}).Where("expires_at >= ?", now).Count(&c.Pending).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.LoginAttempt{}).Where("expires_at < ?", now).Count(&c.Expired).Error; err != nil {
+		return nil, err
+	}
+	counts[TokenLoginAttempt] = c
+
+	c = Counts{}
+	if err := db.Model(&models.MagicLink{}).Where("expires_at >= ? AND used_at IS NULL", now).Count(&c.Pending).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.MagicLink{}).Where("expires_at < ? OR used_at IS NOT NULL", now).Count(&c.Expired).Error; err != nil {
+		return nil, err
+	}
+	counts[TokenMagicLink] = c
+
+	return counts, nil
+}
+
+// RefreshMetrics sets the token gauges from Count
+func (w *Worker) RefreshMetrics(ctx context.Context) (map[string]Counts, error) {
+	counts, err := w.Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for tokenType, c := range counts {
+		metrics.Tokens.WithLabelValues(tokenType, metrics.TokenStatePending).Set(float64(c.Pending))
+		metrics.Tokens.WithLabelValues(tokenType, metrics.TokenStateExpired).Set(float64(c.Expired))
+	}
+	return counts, nil
+}
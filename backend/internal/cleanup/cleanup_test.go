@@ -0,0 +1,172 @@
+// Package cleanup tests
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/metrics"
+	"gosveltekit/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Session{}, &models.SMSCode{}, &models.RecoveryCode{}, &models.PasswordHistory{}, &models.ExternalIdentity{}, &models.TOTPSecret{}, &models.TwoFactorChallenge{}, &models.RefreshToken{}, &models.LoginAttempt{}, &models.APIKey{}, &models.MagicLink{}))
+	return db
+}
+
+func TestWorker_Prune(t *testing.T) {
+	db := setupTestDB(t)
+	worker := NewWorker(db, WorkerConfig{})
+	ctx := context.Background()
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	expiredReset := models.User{Username: "expired", Email: "expired@example.com", PasswordHash: "x", ResetToken: "old", ResetTokenExpiry: past}
+	validReset := models.User{Username: "valid", Email: "valid@example.com", PasswordHash: "x", ResetToken: "new", ResetTokenExpiry: future}
+	require.NoError(t, db.Create(&expiredReset).Error)
+	require.NoError(t, db.Create(&validReset).Error)
+
+	require.NoError(t, db.Create(&[]models.Session{
+		{ID: "expired-1", UserID: validReset.ID, ExpiresAt: past},
+		{ID: "expired-2", UserID: validReset.ID, ExpiresAt: past},
+		{ID: "valid", UserID: validReset.ID, ExpiresAt: future},
+	}).Error)
+
+	require.NoError(t, db.Create(&[]models.SMSCode{
+		{UserID: validReset.ID, Purpose: "two_factor", CodeHash: "a", ExpiresAt: past},
+		{UserID: validReset.ID, Purpose: "two_factor", CodeHash: "b", ExpiresAt: future, UsedAt: &now},
+		{UserID: validReset.ID, Purpose: "two_factor", CodeHash: "c", ExpiresAt: future},
+	}).Error)
+
+	require.NoError(t, db.Create(&[]models.TwoFactorChallenge{
+		{ID: "expired", UserID: validReset.ID, ExpiresAt: past},
+		{ID: "pending", UserID: validReset.ID, ExpiresAt: future},
+	}).Error)
+
+	require.NoError(t, db.Create(&[]models.RefreshToken{
+		{ID: "expired", FamilyID: "f", SessionID: "valid", UserID: validReset.ID, ExpiresAt: past},
+		{ID: "used", FamilyID: "f", SessionID: "valid", UserID: validReset.ID, ExpiresAt: future, UsedAt: &now},
+	}).Error)
+
+	require.NoError(t, db.Create(&[]models.LoginAttempt{
+		{Kind: "ip", Key: "192.0.2.1", Failures: 3, LastFailureAt: past, ExpiresAt: past},
+		{Kind: "account", Key: "valid", Failures: 1, LastFailureAt: now, ExpiresAt: future},
+	}).Error)
+
+	require.NoError(t, db.Create(&[]models.MagicLink{
+		{ID: "old", UserID: validReset.ID, Email: "valid@example.com", ExpiresAt: past, CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: "recent", UserID: validReset.ID, Email: "valid@example.com", ExpiresAt: future, UsedAt: &now, CreatedAt: now},
+		{ID: "pending", UserID: validReset.ID, Email: "valid@example.com", ExpiresAt: future, CreatedAt: now},
+	}).Error)
+
+	counts, err := worker.Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Counts{Pending: 1, Expired: 2}, counts[TokenSession])
+	assert.Equal(t, Counts{Pending: 1, Expired: 1}, counts[TokenPasswordReset])
+	assert.Equal(t, Counts{Pending: 1, Expired: 2}, counts[TokenSMSCode])
+	assert.Equal(t, Counts{Pending: 1, Expired: 1}, counts[TokenTwoFactor])
+	assert.Equal(t, Counts{Pending: 1, Expired: 1}, counts[TokenRefresh])
+	assert.Equal(t, Counts{Pending: 1, Expired: 1}, counts[TokenLoginAttempt])
+	assert.Equal(t, Counts{Pending: 1, Expired: 2}, counts[TokenMagicLink])
+
+	prunedBefore := testutil.ToFloat64(metrics.TokensPruned.WithLabelValues(TokenSession))
+
+	result, err := worker.Prune(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Result{TokenSession: 2, TokenPasswordReset: 1, TokenSMSCode: 2, TokenTwoFactor: 1, TokenRefresh: 1, TokenLoginAttempt: 1, TokenMagicLink: 1}, result)
+	assert.Equal(t, int64(9), result.Total())
+
+	// Valid tokens remain
+	var sessions []models.Session
+	require.NoError(t, db.Find(&sessions).Error)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "valid", sessions[0].ID)
+
+	var codes []models.SMSCode
+	require.NoError(t, db.Find(&codes).Error)
+	require.Len(t, codes, 1)
+	assert.Equal(t, "c", codes[0].CodeHash)
+
+	require.NoError(t, db.First(&expiredReset, expiredReset.ID).Error)
+	assert.Empty(t, expiredReset.ResetToken)
+	require.NoError(t, db.First(&validReset, validReset.ID).Error)
+	assert.Equal(t, "new", validReset.ResetToken)
+
+	// Metrics reflect the run
+	assert.Equal(t, prunedBefore+2, testutil.ToFloat64(metrics.TokensPruned.WithLabelValues(TokenSession)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.Tokens.WithLabelValues(TokenSession, metrics.TokenStatePending)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.Tokens.WithLabelValues(TokenSession, metrics.TokenStateExpired)))
+
+	// Nothing left to prune
+	result, err = worker.Prune(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, result.Total())
+}
+
+type fakeSessionStore struct{ limits []int }
+
+func (f *fakeSessionStore) DeleteExpiredSessions(ctx context.Context, limit int) (int64, error) {
+	f.limits = append(f.limits, limit)
+	return 0, nil
+}
+
+func TestWorker_PruneInBatches(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+
+	for i := range 5 {
+		name := fmt.Sprintf("user%d", i)
+		user := models.User{Username: name, Email: name + "@example.com", PasswordHash: "x", ResetToken: "old", ResetTokenExpiry: past}
+		require.NoError(t, db.Create(&user).Error)
+		require.NoError(t, db.Create(&models.Session{ID: name, UserID: user.ID, ExpiresAt: past}).Error)
+		require.NoError(t, db.Create(&models.LoginAttempt{Kind: "account", Key: name, Failures: 1, LastFailureAt: past, ExpiresAt: past}).Error)
+	}
+
+	result, err := NewWorker(db, WorkerConfig{BatchSize: 2}).Prune(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), result[TokenSession])
+	assert.Equal(t, int64(5), result[TokenPasswordReset])
+	assert.Equal(t, int64(5), result[TokenLoginAttempt])
+
+	var left int64
+	require.NoError(t, db.Model(&models.LoginAttempt{}).Count(&left).Error)
+	assert.Zero(t, left)
+
+	t.Run("SessionStore", func(t *testing.T) {
+		require.NoError(t, db.Create(&models.Session{ID: "kept", UserID: 1, ExpiresAt: past}).Error)
+		sessions := &fakeSessionStore{}
+
+		result, err := NewWorker(db, WorkerConfig{BatchSize: 2, Sessions: sessions}).Prune(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, result[TokenSession])
+		assert.Equal(t, []int{2}, sessions.limits)
+		require.NoError(t, db.Model(&models.Session{}).Count(&left).Error)
+		assert.Equal(t, int64(1), left, "the store decides what is removed")
+	})
+}
+
+func TestWorker_PrunePartialFailure(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, db.Create(&models.Session{ID: "old", UserID: 1, ExpiresAt: past}).Error)
+	require.NoError(t, db.Migrator().DropTable(&models.SMSCode{}))
+
+	result, err := NewWorker(db, WorkerConfig{}).Prune(ctx)
+	var failed Errors
+	require.ErrorAs(t, err, &failed)
+	assert.Len(t, failed, 1)
+	assert.Contains(t, failed, TokenSMSCode)
+	assert.Equal(t, int64(1), result[TokenSession], "the other types are still pruned")
+}
@@ -0,0 +1,124 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gosveltekit/internal/email"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// VerificationReminderConfig configures the verification reminder
+type VerificationReminderConfig struct {
+	Interval    time.Duration // Default: 1 hour
+	RemindAfter time.Duration // unverified for this long gets the reminder
+	DeleteAfter time.Duration // reminded and unverified for this long is scheduled for deletion; 0 never deletes
+	BatchSize   int           // users reminded per run. Default: 100
+
+	Email email.EmailServiceInterface
+}
+
+// VerificationReminder emails a single reminder to users who haven't verified
+// their email after RemindAfter. Users still unverified after DeleteAfter are
+// scheduled for deletion, which AccountPurger carries out; only reminded users
+// are, so nobody is deleted without warning.
+type VerificationReminder struct {
+	db     *gorm.DB
+	config VerificationReminderConfig
+}
+
+// NewVerificationReminder creates a new VerificationReminder
+func NewVerificationReminder(db *gorm.DB, config VerificationReminderConfig) *VerificationReminder {
+	if config.Interval <= 0 {
+		config.Interval = time.Hour
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	return &VerificationReminder{db: db, config: config}
+}
+
+// Run reminds and schedules stale accounts every Interval until ctx is
+// cancelled
+func (r *VerificationReminder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		reminded, err := r.Remind(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logger.Error("Erro ao enviar lembretes de verificação de email", "error", err)
+		} else if reminded > 0 {
+			logger.Info("Lembretes de verificação de email enviados", "total", reminded)
+		}
+
+		scheduled, err := r.ScheduleStale(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logger.Error("Erro ao agendar exclusão de contas não verificadas", "error", err)
+		} else if scheduled > 0 {
+			logger.Warn("Contas não verificadas agendadas para exclusão", "total", scheduled)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Remind sends the reminder to up to BatchSize unverified users registered
+// before RemindAfter who haven't got it yet, and returns how many were sent.
+// A user is only marked as reminded once the email is sent, so failed sends
+// are retried on the next run.
+func (r *VerificationReminder) Remind(ctx context.Context) (int, error) {
+	var users []models.User
+	err := r.db.WithContext(ctx).
+		Where("email_verified = ? AND verification_reminder_sent_at IS NULL AND scheduled_deletion_at IS NULL AND created_at <= ?", false, time.Now().Add(-r.config.RemindAfter)).
+		Order("id").Limit(r.config.BatchSize).
+		Find(&users).Error
+	if err != nil {
+		return 0, err
+	}
+
+	reminded := 0
+	for _, user := range users {
+		if err := ctx.Err(); err != nil {
+			return reminded, err
+		}
+		displayName := user.DisplayName
+		if displayName == "" {
+			displayName = user.Username
+		}
+		if err := r.config.Email.SendVerificationReminderEmail(email.WithLocale(ctx, user.Locale), user.Email, user.Username, displayName); err != nil {
+			logger.Warn("Falha ao enviar lembrete de verificação, nova tentativa na próxima execução", "error", err, "user_id", user.ID)
+			continue
+		}
+		if err := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", user.ID).
+			Update("verification_reminder_sent_at", time.Now()).Error; err != nil {
+			// The email is out: if this keeps failing the user gets it again
+			logger.Error("Erro ao registrar lembrete de verificação enviado", "error", err, "user_id", user.ID)
+			return reminded, err
+		}
+		reminded++
+	}
+	return reminded, nil
+}
+
+// ScheduleStale schedules the deletion, effective immediately, of the
+// reminded users still unverified DeleteAfter after registering, and returns
+// how many were scheduled. It does nothing when DeleteAfter is 0.
+func (r *VerificationReminder) ScheduleStale(ctx context.Context) (int, error) {
+	if r.config.DeleteAfter <= 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	res := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("email_verified = ? AND verification_reminder_sent_at IS NOT NULL AND scheduled_deletion_at IS NULL AND created_at <= ?", false, now.Add(-r.config.DeleteAfter)).
+		Update("scheduled_deletion_at", now)
+	return int(res.RowsAffected), res.Error
+}
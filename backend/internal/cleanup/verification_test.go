@@ -0,0 +1,86 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/email"
+	"gosveltekit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerificationReminder_Remind(t *testing.T) {
+	db := setupTestDB(t)
+	mockEmail := email.NewMockEmailService()
+	reminder := NewVerificationReminder(db, VerificationReminderConfig{RemindAfter: 24 * time.Hour, Email: mockEmail})
+	ctx := context.Background()
+	old := time.Now().Add(-48 * time.Hour)
+
+	stale := models.User{Username: "stale", Email: "stale@example.com", PasswordHash: "x"}
+	verified := models.User{Username: "verified", Email: "verified@example.com", PasswordHash: "x", EmailVerified: true}
+	recent := models.User{Username: "recent", Email: "recent@example.com", PasswordHash: "x"}
+	for _, user := range []*models.User{&stale, &verified, &recent} {
+		require.NoError(t, db.Create(user).Error)
+	}
+	require.NoError(t, db.Model(&models.User{}).Where("id IN ?", []uint{stale.ID, verified.ID}).Update("created_at", old).Error)
+
+	// A failed send is retried on the next run
+	mockEmail.SetSendEmailError(errors.New("smtp down"))
+	reminded, err := reminder.Remind(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, reminded)
+	mockEmail.SetSendEmailError(nil)
+	mockEmail.ClearSentEmails()
+
+	for range 3 {
+		_, err := reminder.Remind(ctx)
+		require.NoError(t, err)
+	}
+
+	sent := mockEmail.GetSentEmails()
+	require.Len(t, sent, 1, "the stale user gets exactly one reminder")
+	assert.Equal(t, "verification_reminder", sent[0].Kind)
+	assert.Equal(t, "stale@example.com", sent[0].To)
+	assert.Equal(t, "stale", sent[0].DisplayName)
+
+	var user models.User
+	require.NoError(t, db.First(&user, stale.ID).Error)
+	assert.NotNil(t, user.VerificationReminderSentAt)
+}
+
+func TestVerificationReminder_ScheduleStale(t *testing.T) {
+	db := setupTestDB(t)
+	reminder := NewVerificationReminder(db, VerificationReminderConfig{
+		RemindAfter: 24 * time.Hour,
+		DeleteAfter: 7 * 24 * time.Hour,
+		Email:       email.NewMockEmailService(),
+	})
+	ctx := context.Background()
+	old, remindedAt := time.Now().Add(-30*24*time.Hour), time.Now().Add(-20*24*time.Hour)
+
+	stale := models.User{Username: "stale", Email: "stale@example.com", PasswordHash: "x", VerificationReminderSentAt: &remindedAt}
+	unreminded := models.User{Username: "unreminded", Email: "unreminded@example.com", PasswordHash: "x"}
+	verified := models.User{Username: "verified", Email: "verified@example.com", PasswordHash: "x", EmailVerified: true, VerificationReminderSentAt: &remindedAt}
+	for _, user := range []*models.User{&stale, &unreminded, &verified} {
+		require.NoError(t, db.Create(user).Error)
+	}
+	require.NoError(t, db.Model(&models.User{}).Where("1 = 1").Update("created_at", old).Error)
+
+	scheduled, err := reminder.ScheduleStale(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, scheduled)
+
+	var users []models.User
+	require.NoError(t, db.Where("scheduled_deletion_at IS NOT NULL").Find(&users).Error)
+	require.Len(t, users, 1)
+	assert.Equal(t, "stale", users[0].Username)
+
+	// The purger then deletes it
+	purged, err := NewAccountPurger(db, AccountPurgerConfig{}).Purge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+}
@@ -4,6 +4,8 @@ package config
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -11,10 +13,88 @@ import (
 
 type ServerConfig struct {
 	Port int `mapstructure:"port"`
+	// BasePath is the prefix under which all routes are mounted (e.g. "/api")
+	// when the server runs behind a reverse proxy. Empty means root.
+	BasePath string `mapstructure:"base_path"`
+	// PublicURL is the externally visible origin of the API (e.g. "https://example.com"),
+	// used together with BasePath to build absolute links from relative ones.
+	PublicURL string `mapstructure:"public_url"`
+	// ShutdownTimeout bounds the graceful shutdown: in-flight requests and
+	// background workers get this long to finish. Defaults to 10s.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// PublicRoutes lists extra routes reachable without a session, as
+	// "METHOD /path" relative to BasePath. Every other route requires auth.
+	PublicRoutes []string `mapstructure:"public_routes"`
+	// MaxHeaderBytes bounds the size of request headers (431 beyond it) and
+	// MaxURLLength the size of path plus query string (414 beyond it).
+	// Both default to DefaultMaxHeaderBytes and DefaultMaxURLLength.
+	MaxHeaderBytes int `mapstructure:"max_header_bytes"`
+	MaxURLLength   int `mapstructure:"max_url_length"`
+	// VersionMaxAge is how long clients and CDNs may reuse GET /version
+	// without revalidating it; it only changes on a deploy. Zero makes them
+	// revalidate every time. Defaults to 5m.
+	VersionMaxAge time.Duration `mapstructure:"version_max_age"`
+	// CORS decides which other origins may call the API
+	CORS CORSConfig `mapstructure:"cors"`
+	// TLS serves HTTPS directly, without a reverse proxy in front
+	TLS TLSConfig `mapstructure:"tls"`
+}
+
+// Request size defaults
+const (
+	DefaultMaxHeaderBytes = 64 << 10 // 64 KiB
+	DefaultMaxURLLength   = 8 << 10  // 8 KiB
+)
+
+// Validate checks the port; 0 uses 8080
+func (s ServerConfig) Validate() error {
+	if s.Port < 0 || s.Port > 65535 {
+		return fmt.Errorf("server.port inválido %d (use uma porta entre 1 e 65535)", s.Port)
+	}
+	return nil
+}
+
+// NormalizedBasePath returns BasePath always starting with "/" and never
+// ending with one. An empty or "/" path results in "" (root).
+func (s ServerConfig) NormalizedBasePath() string {
+	path := strings.Trim(strings.TrimSpace(s.BasePath), "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// AbsoluteURL turns a relative link (e.g. "/reset-password?token=") into an
+// absolute one using PublicURL and BasePath. Links that already carry a scheme
+// are returned unchanged.
+func (s ServerConfig) AbsoluteURL(link string) string {
+	if strings.Contains(link, "://") || s.PublicURL == "" {
+		return link
+	}
+	if !strings.HasPrefix(link, "/") {
+		link = "/" + link
+	}
+	return strings.TrimRight(s.PublicURL, "/") + s.NormalizedBasePath() + link
 }
 
 type DatabaseConfig struct {
-	DSN string `mapstructure:"dsn"`
+	Driver         string `mapstructure:"driver"` // sqlite, postgres ou mysql
+	DSN            string `mapstructure:"dsn" redact:"dsn"`
+	ReadReplicaDSN string `mapstructure:"read_replica_dsn" redact:"dsn"` // réplica de leitura opcional, usada pelas consultas de listagem
+	IDStrategy     string `mapstructure:"id_strategy"`                   // integer ou uuid: identificador de usuário exposto pela API
+
+	// PRAGMAs aplicados a cada nova conexão sqlite (ex.: journal_mode: wal)
+	SQLitePragmas map[string]string `mapstructure:"sqlite_pragmas"`
+
+	// Pool de conexões; zero mantém o padrão do database/sql
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`     // conexões abertas ao mesmo tempo (0 sem limite)
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`     // conexões ociosas mantidas (0 usa 2)
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`  // recicla conexões após esse tempo (0 nunca)
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"` // fecha conexões ociosas após esse tempo (0 nunca)
+
+	// Aplica as migrações pendentes ao iniciar; desligado, o servidor não
+	// inicia com migrações pendentes (use "server migrate up")
+	MigrateOnStart bool `mapstructure:"migrate_on_start"`
 }
 
 type JWTConfig struct {
@@ -34,40 +114,522 @@ type EmailConfig struct {
 	FromEmail    string `mapstructure:"from_email"`
 	FromName     string `mapstructure:"from_name"`
 	ResetURL     string `mapstructure:"reset_url"`
+	RestoreURL   string `mapstructure:"restore_url"`    // link que cancela uma exclusão de conta agendada
+	VerifyURL    string `mapstructure:"verify_url"`     // página onde o usuário verifica o email, usada no lembrete e no link de verificação (com ?token=)
+	MagicLinkURL string `mapstructure:"magic_link_url"` // página que conclui o login por link enviado por email (com ?token=)
+
+	// Templates: os embutidos podem ser substituídos, arquivo a arquivo, pelos
+	// de mesmo caminho em TemplatesDir (ex.: pt-BR/welcome.tmpl, layout.html)
+	TemplatesDir  string `mapstructure:"templates_dir"`
+	DefaultLocale string `mapstructure:"default_locale"` // idioma usado quando o do usuário não tem templates
+
+	// Email de boas-vindas
+	SendWelcome    bool   `mapstructure:"send_welcome"`
+	WelcomeTrigger string `mapstructure:"welcome_trigger"` // register (no cadastro) ou verification (após verificar o email)
+}
+
+// Validate checks the SMTP port and the welcome email trigger
+func (e EmailConfig) Validate() error {
+	if e.SMTPHost != "" && (e.SMTPPort < 1 || e.SMTPPort > 65535) {
+		return fmt.Errorf("email.smtp_port inválido %d (use uma porta entre 1 e 65535)", e.SMTPPort)
+	}
+	if !e.SendWelcome {
+		return nil
+	}
+	switch e.WelcomeTrigger {
+	case "register", "verification":
+		return nil
+	default:
+		return fmt.Errorf("email.welcome_trigger inválido %q (use register ou verification)", e.WelcomeTrigger)
+	}
+}
+
+// AuthConfig contém configurações do sistema de autenticação
+type AuthConfig struct {
+	// Bloqueio temporário após logins com falha, contados por usuário e por IP;
+	// vale o limite que for atingido primeiro
+	MaxFailedLogins      int           `mapstructure:"max_failed_logins"`        // falhas seguidas para o mesmo usuário antes de bloquear a conta (0 usa 5)
+	MaxFailedLoginsPerIP int           `mapstructure:"max_failed_logins_per_ip"` // falhas do mesmo IP, em qualquer conta, antes de bloquear o IP (0 desativa)
+	LoginLockoutDuration time.Duration `mapstructure:"login_lockout_duration"`   // duração do bloqueio; falhas de um IP mais antigas que isso são esquecidas
+
+	// Atraso progressivo (tarpit) aplicado a logins com falha repetida
+	FailedLoginBackoff    time.Duration `mapstructure:"failed_login_backoff"`     // atraso após a primeira falha, dobra a cada nova falha
+	FailedLoginBackoffMax time.Duration `mapstructure:"failed_login_backoff_max"` // teto do atraso
+
+	// Contadores de falhas ficam no banco, sobrevivendo a reinícios e valendo
+	// para todas as instâncias; em memória são mais rápidos, mas por processo
+	LoginAttemptsInMemory bool `mapstructure:"login_attempts_in_memory"`
+
+	// Sessões ficam no banco ou no Redis (usa a seção redis), que as expira
+	// sozinho e tira as leituras de sessão do banco; trocar encerra as sessões
+	SessionStore string `mapstructure:"session_store"` // database ou redis (vazio usa database)
+
+	ClockSkewLeeway time.Duration `mapstructure:"clock_skew_leeway"` // tolerância de relógio na validação de expiração; 0 desliga, padrão 30s
+
+	RefreshRecommendedWithin time.Duration `mapstructure:"refresh_recommended_within"` // sessões mais perto que isso da expiração recebem X-Token-Refresh-Recommended (0 desativa)
+
+	TokenSources []string `mapstructure:"token_sources"` // onde procurar a sessão, em ordem: header (Authorization/X-Session-ID) e cookie
+	TokenBytes   int      `mapstructure:"token_bytes"`   // bytes aleatórios de cada ID de sessão (entre 16 e 48, 0 usa 32)
+
+	// Como os tokens chegam ao cliente: header devolve a sessão e o refresh
+	// token no corpo (e a sessão também em cookie); cookie os entrega só em
+	// cookies HttpOnly, lê a sessão só do cookie e exige o token CSRF
+	// (X-CSRF-Token) nas requisições que alteram dados
+	TokenTransport string `mapstructure:"token_transport"`  // header ou cookie (vazio usa header)
+	CookieSameSite string `mapstructure:"cookie_same_site"` // SameSite dos cookies de sessão: lax, strict ou none (vazio usa lax; none exige HTTPS)
+
+	NotifyNewDevice bool `mapstructure:"notify_new_device"` // avisa por email logins a partir de dispositivos desconhecidos (user agent + sub-rede do IP)
+	NotifyOnLockout bool `mapstructure:"notify_on_lockout"` // avisa por email o dono da conta bloqueada por falhas de login, uma vez por bloqueio
+
+	// Expiração por inatividade, além da expiração absoluta
+	SessionIdleTimeout      time.Duration `mapstructure:"session_idle_timeout"`      // sessão expira após esse tempo sem uso (0 desativa)
+	SessionActivityInterval time.Duration `mapstructure:"session_activity_interval"` // intervalo mínimo entre atualizações de last_used_at
+
+	// Limite de sessões simultâneas por usuário
+	MaxSessionsPerUser int    `mapstructure:"max_sessions_per_user"` // 0 desativa
+	OnSessionLimit     string `mapstructure:"on_session_limit"`      // evict (encerra a sessão usada há mais tempo) ou reject (recusa o login); vazio usa evict
+
+	PasswordHistoryDepth int `mapstructure:"password_history_depth"` // quantidade de senhas recentes que não podem ser reutilizadas (0 desativa)
+
+	// Sessões após redefinir ou trocar a senha
+	RevokeSessionsOnReset              bool `mapstructure:"revoke_sessions_on_reset"`                // encerra as sessões existentes (padrão true)
+	KeepCurrentSessionOnPasswordChange bool `mapstructure:"keep_current_session_on_password_change"` // na troca de senha, mantém a sessão usada para trocá-la (padrão true)
+
+	RefreshTokenDuration time.Duration `mapstructure:"refresh_token_duration"` // validade dos refresh tokens de uso único que renovam a sessão (0 desativa)
+
+	ImpersonationDuration time.Duration `mapstructure:"impersonation_duration"` // duração máxima de uma sessão de impersonação
+
+	Roles []string `mapstructure:"roles"` // papéis que um admin pode atribuir a usuários
+
+	// Política de nomes de usuário, aplicada no cadastro e na troca de nome
+	UsernameMinLength int      `mapstructure:"username_min_length"` // 0 usa 3
+	UsernameMaxLength int      `mapstructure:"username_max_length"` // 0 usa 50
+	UsernamePattern   string   `mapstructure:"username_pattern"`    // expressão regular dos caracteres permitidos (vazio usa letras, números, . _ -)
+	ReservedUsernames []string `mapstructure:"reserved_usernames"`  // nomes que só administradores podem atribuir (sem diferenciar maiúsculas)
+
+	// Hash de senhas: hashes com algoritmo ou parâmetros antigos são refeitos no próximo login
+	PasswordHashAlgorithm string `mapstructure:"password_hash_algorithm"` // bcrypt ou argon2id
+	BcryptCost            int    `mapstructure:"bcrypt_cost"`
+	BcryptLongPasswords   string `mapstructure:"bcrypt_long_passwords"` // reject ou prehash: senhas acima de 72 bytes são recusadas ou pré-processadas com SHA-256
+	Argon2Memory          uint32 `mapstructure:"argon2_memory"`         // em KiB
+	Argon2Iterations      uint32 `mapstructure:"argon2_iterations"`
+	Argon2Parallelism     uint8  `mapstructure:"argon2_parallelism"`
+
+	// Captcha no cadastro
+	CaptchaProvider string `mapstructure:"captcha_provider"` // none, recaptcha ou hcaptcha
+	CaptchaSecret   string `mapstructure:"captcha_secret"`
+
+	// Códigos SMS (verificação de telefone e 2FA por SMS)
+	SMSCodeTTL         time.Duration `mapstructure:"sms_code_ttl"`          // validade de cada código
+	SMSCodeMaxAttempts int           `mapstructure:"sms_code_max_attempts"` // tentativas erradas antes de invalidar o código
+	SMSCodeInterval    time.Duration `mapstructure:"sms_code_interval"`     // intervalo mínimo entre envios para o mesmo usuário
+	SMSCodesPerHour    int           `mapstructure:"sms_codes_per_hour"`    // máximo de códigos por usuário por hora
+
+	// Login sem senha por link enviado ao email
+	MagicLinkEnabled  bool          `mapstructure:"magic_link_enabled"`   // ativa POST /auth/magic-link e /auth/magic-link/verify
+	MagicLinkTTL      time.Duration `mapstructure:"magic_link_ttl"`       // validade de cada link (0 usa 15m)
+	MagicLinkInterval time.Duration `mapstructure:"magic_link_interval"`  // intervalo mínimo entre links para o mesmo usuário (0 usa 1m)
+	MagicLinksPerHour int           `mapstructure:"magic_links_per_hour"` // máximo de links por usuário por hora (0 usa 5)
+
+	// 2FA por aplicativo autenticador (TOTP)
+	TwoFactorChallengeTTL time.Duration `mapstructure:"two_factor_challenge_ttl"` // prazo para informar o código após a senha (0 usa 5m)
+	TwoFactorMaxAttempts  int           `mapstructure:"two_factor_max_attempts"`  // códigos errados antes de invalidar o login pendente (0 usa 5)
+	TOTPIssuer            string        `mapstructure:"totp_issuer"`              // nome exibido no aplicativo autenticador (vazio usa GoSvelteKit)
+
+	TokenCleanupInterval  time.Duration `mapstructure:"token_cleanup_interval"`   // intervalo entre remoções de sessões, tokens e códigos expirados
+	TokenCleanupBatchSize int           `mapstructure:"token_cleanup_batch_size"` // linhas removidas por comando na limpeza (0 usa 1000)
+
+	// Exclusão de conta pelo próprio usuário: a conta fica bloqueada durante
+	// o prazo e pode ser restaurada, depois é apagada definitivamente
+	AccountDeletionGracePeriod time.Duration `mapstructure:"account_deletion_grace_period"` // prazo para restaurar a conta (0 usa 30 dias)
+	AccountPurgeInterval       time.Duration `mapstructure:"account_purge_interval"`        // intervalo entre remoções de contas com prazo vencido
+
+	// Lembrete de verificação de email: contas sem email verificado recebem um
+	// único lembrete e, se continuarem sem verificação, são agendadas para
+	// exclusão (e apagadas pela remoção de contas acima)
+	VerificationReminderAfter    time.Duration `mapstructure:"verification_reminder_after"`     // tempo sem verificação até o lembrete (0 desliga)
+	UnverifiedAccountDeleteAfter time.Duration `mapstructure:"unverified_account_delete_after"` // tempo sem verificação até a exclusão, maior que o do lembrete (0 nunca exclui)
+	VerificationReminderInterval time.Duration `mapstructure:"verification_reminder_interval"`  // intervalo entre execuções
+
+	// Links de recuperação de senha: stored grava o hash do token no usuário;
+	// signed assina o link (HMAC) sem gravar nada, e trocar a senha invalida os
+	// links já enviados
+	ResetTokenMode   string `mapstructure:"reset_token_mode"`   // stored ou signed (vazio usa stored)
+	ResetTokenSecret string `mapstructure:"reset_token_secret"` // chave dos links assinados, com pelo menos 32 bytes
+
+	// Verificação de email: com email_verification_secret, o cadastro envia um
+	// link assinado (HMAC) confirmado em POST /auth/verify-email, e
+	// POST /auth/resend-verification envia um novo. Enquanto o email não é
+	// verificado, unverified_login decide o acesso:
+	//   - allow: login normal
+	//   - restricted: login permitido, mas só GET /api/me e o logout respondem
+	//   - deny: login recusado
+	EmailVerificationSecret string        `mapstructure:"email_verification_secret"` // chave dos links, com pelo menos 32 bytes (vazio desliga a verificação)
+	EmailVerificationTTL    time.Duration `mapstructure:"email_verification_ttl"`    // validade de cada link (0 usa 24h)
+	UnverifiedLogin         string        `mapstructure:"unverified_login"`          // allow, restricted ou deny (vazio usa allow)
+
+	// Resposta do cadastro:
+	//   - require_email_verification ligado: nenhuma sessão é criada e a resposta
+	//     pede que o usuário verifique o email, mesmo com auto_login_after_register
+	//   - só auto_login_after_register ligado: o usuário já sai logado (cookie e
+	//     sessão na resposta, como no login)
+	//   - ambos desligados: a resposta traz apenas o usuário criado, que faz login
+	//     em seguida
+	AutoLoginAfterRegister   bool `mapstructure:"auto_login_after_register"`  // cria a sessão logo após o cadastro
+	RequireEmailVerification bool `mapstructure:"require_email_verification"` // cadastro não cria sessão até o email ser verificado
+
+	// StrictRegistrationEmail desfaz o cadastro quando o email de boas-vindas
+	// (email.welcome_trigger register) ou o de verificação não pode ser enviado:
+	// os emails são enviados durante a requisição, fora da fila de jobs.
+	// Desligado, a conta é criada sem verificação, a falha é registrada no log e
+	// a resposta do cadastro traz verification_email_sent false
+	StrictRegistrationEmail bool `mapstructure:"strict_registration_email"`
+
+	// RevealEmailAvailability faz POST /auth/check-email informar se o email já
+	// está cadastrado. Permite descobrir quem tem conta: ligue apenas em
+	// ferramentas internas
+	RevealEmailAvailability bool `mapstructure:"reveal_email_availability"`
+}
+
+// Token sources accepted in auth.token_sources
+const (
+	TokenSourceHeader = "header"
+	TokenSourceCookie = "cookie"
+)
+
+// DefaultTokenSources is the lookup order used when auth.token_sources is empty
+var DefaultTokenSources = []string{TokenSourceHeader, TokenSourceCookie}
+
+// Token transports accepted in auth.token_transport
+const (
+	TokenTransportHeader = "header"
+	TokenTransportCookie = "cookie"
+)
+
+// SameSite modes accepted in auth.cookie_same_site
+const (
+	CookieSameSiteLax    = "lax"
+	CookieSameSiteStrict = "strict"
+	CookieSameSiteNone   = "none"
+)
+
+// Password reset token modes accepted in auth.reset_token_mode
+const (
+	ResetTokenStored = "stored"
+	ResetTokenSigned = "signed"
+)
+
+// Session stores accepted in auth.session_store
+const (
+	SessionStoreDatabase = "database"
+	SessionStoreRedis    = "redis"
+)
+
+// Behaviours accepted in auth.on_session_limit
+const (
+	SessionLimitEvict  = "evict"
+	SessionLimitReject = "reject"
+)
+
+// Access of unverified users accepted in auth.unverified_login
+const (
+	UnverifiedLoginAllow      = "allow"
+	UnverifiedLoginRestricted = "restricted"
+	UnverifiedLoginDeny       = "deny"
+)
+
+// MinResetTokenSecretLength is the shortest auth.reset_token_secret accepted
+const MinResetTokenSecretLength = 32
+
+// MinEmailVerificationSecretLength is the shortest
+// auth.email_verification_secret accepted
+const MinEmailVerificationSecretLength = 32
+
+// Validate checks the token sources and transport, the username pattern, the
+// reset token mode, the session store, the session limit behaviour, email
+// verification and the unverified account thresholds
+func (a AuthConfig) Validate() error {
+	if a.UsernamePattern != "" {
+		if _, err := regexp.Compile(a.UsernamePattern); err != nil {
+			return fmt.Errorf("auth.username_pattern inválido: %w", err)
+		}
+	}
+	seen := make(map[string]bool, len(a.TokenSources))
+	for _, source := range a.TokenSources {
+		if source != TokenSourceHeader && source != TokenSourceCookie {
+			return fmt.Errorf("auth.token_sources inválido %q (use %s ou %s)", source, TokenSourceHeader, TokenSourceCookie)
+		}
+		if seen[source] {
+			return fmt.Errorf("auth.token_sources repete %q", source)
+		}
+		seen[source] = true
+	}
+	if a.ClockSkewLeeway < 0 {
+		return fmt.Errorf("auth.clock_skew_leeway não pode ser negativo")
+	}
+	switch a.TokenTransport {
+	case "", TokenTransportHeader, TokenTransportCookie:
+	default:
+		return fmt.Errorf("auth.token_transport inválido %q (use %s ou %s)", a.TokenTransport, TokenTransportHeader, TokenTransportCookie)
+	}
+	switch a.CookieSameSite {
+	case "", CookieSameSiteLax, CookieSameSiteStrict, CookieSameSiteNone:
+	default:
+		return fmt.Errorf("auth.cookie_same_site inválido %q (use %s, %s ou %s)", a.CookieSameSite, CookieSameSiteLax, CookieSameSiteStrict, CookieSameSiteNone)
+	}
+	switch a.ResetTokenMode {
+	case "", ResetTokenStored:
+	case ResetTokenSigned:
+		if len(a.ResetTokenSecret) < MinResetTokenSecretLength {
+			return fmt.Errorf("auth.reset_token_secret deve ter pelo menos %d bytes no modo %s", MinResetTokenSecretLength, ResetTokenSigned)
+		}
+	default:
+		return fmt.Errorf("auth.reset_token_mode inválido %q (use %s ou %s)", a.ResetTokenMode, ResetTokenStored, ResetTokenSigned)
+	}
+	switch a.SessionStore {
+	case "", SessionStoreDatabase, SessionStoreRedis:
+	default:
+		return fmt.Errorf("auth.session_store inválido %q (use %s ou %s)", a.SessionStore, SessionStoreDatabase, SessionStoreRedis)
+	}
+	switch a.OnSessionLimit {
+	case "", SessionLimitEvict, SessionLimitReject:
+	default:
+		return fmt.Errorf("auth.on_session_limit inválido %q (use %s ou %s)", a.OnSessionLimit, SessionLimitEvict, SessionLimitReject)
+	}
+	if a.EmailVerificationSecret != "" && len(a.EmailVerificationSecret) < MinEmailVerificationSecretLength {
+		return fmt.Errorf("auth.email_verification_secret deve ter pelo menos %d bytes", MinEmailVerificationSecretLength)
+	}
+	switch a.UnverifiedLogin {
+	case "", UnverifiedLoginAllow:
+	case UnverifiedLoginRestricted, UnverifiedLoginDeny:
+		if a.EmailVerificationSecret == "" {
+			return fmt.Errorf("auth.unverified_login %s exige auth.email_verification_secret, para que os usuários possam verificar o email", a.UnverifiedLogin)
+		}
+	default:
+		return fmt.Errorf("auth.unverified_login inválido %q (use %s, %s ou %s)", a.UnverifiedLogin, UnverifiedLoginAllow, UnverifiedLoginRestricted, UnverifiedLoginDeny)
+	}
+	if a.UnverifiedAccountDeleteAfter > 0 && a.UnverifiedAccountDeleteAfter <= a.VerificationReminderAfter {
+		return fmt.Errorf("auth.unverified_account_delete_after (%s) deve ser maior que auth.verification_reminder_after (%s)", a.UnverifiedAccountDeleteAfter, a.VerificationReminderAfter)
+	}
+	if a.UnverifiedAccountDeleteAfter > 0 && a.VerificationReminderAfter <= 0 {
+		return fmt.Errorf("auth.unverified_account_delete_after exige auth.verification_reminder_after, para que ninguém seja excluído sem aviso")
+	}
+	return nil
+}
+
+// RoleConfig define um papel e suas permissões, sincronizados com o banco a cada inicialização
+type RoleConfig struct {
+	Name        string   `mapstructure:"name"`
+	Description string   `mapstructure:"description"`
+	Permissions []string `mapstructure:"permissions"` // ex: users:read; permissões removidas daqui são revogadas
+}
+
+// InitialUserConfig define um usuário criado na inicialização, se ainda não
+// existir. Usuários já existentes não são alterados
+type InitialUserConfig struct {
+	Username    string `mapstructure:"username"`
+	Email       string `mapstructure:"email"`
+	DisplayName string `mapstructure:"display_name"` // vazio usa o username
+	Role        string `mapstructure:"role"`         // vazio usa user
+	Password    string `mapstructure:"password"`     // obrigatória e dentro da política de senhas
+}
+
+// SMSConfig contém configurações para envio de SMS
+type SMSConfig struct {
+	Provider         string `mapstructure:"provider"` // none (apenas registra no log) ou twilio
+	TwilioAccountSID string `mapstructure:"twilio_account_sid"`
+	TwilioAuthToken  string `mapstructure:"twilio_auth_token"`
+	TwilioFrom       string `mapstructure:"twilio_from"` // número remetente em formato E.164
+}
+
+// PaginationConfig contém os limites de paginação aplicados a todos os endpoints de listagem
+type PaginationConfig struct {
+	DefaultPageSize int `mapstructure:"default_page_size"`
+	MaxPageSize     int `mapstructure:"max_page_size"`
+}
+
+// TenancyConfig contém configurações de multi-tenancy. Desativado, há um único tenant
+// e emails e usernames são únicos em toda a aplicação.
+type TenancyConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	Header     string `mapstructure:"header"`      // cabeçalho com o ID do tenant, tem prioridade sobre o subdomínio
+	BaseDomain string `mapstructure:"base_domain"` // com app.example.com, acme.app.example.com é o tenant acme (vazio desativa)
+}
+
+// MetricsConfig contém a exposição de métricas Prometheus
+type MetricsConfig struct {
+	Enabled bool `mapstructure:"enabled"` // expõe GET /metrics e mede requisições HTTP e consultas ao banco (padrão true)
+}
+
+// DebugConfig contém ferramentas de diagnóstico, desativadas por padrão
+type DebugConfig struct {
+	Pprof bool `mapstructure:"pprof"` // expõe /debug/pprof (apenas administradores)
+
+	// Inclui a mensagem interna e o stack trace nas respostas 500; sem valor,
+	// vale para todo ambiente exceto produção, onde nunca é incluído
+	VerboseErrors *bool `mapstructure:"verbose_errors"`
+}
+
+// VerboseErrors reports whether 500 responses include the internal error and
+// stack trace: debug.verbose_errors if set, otherwise outside production.
+// Production always gets generic messages.
+func (c *Config) VerboseErrors() bool {
+	if c.Environment.IsProduction() {
+		return false
+	}
+	if c.Debug.VerboseErrors != nil {
+		return *c.Debug.VerboseErrors
+	}
+	return true
+}
+
+// SettingsConfig contém os valores padrão das configurações que admins podem
+// alterar em tempo de execução (tabela settings); notify_new_device vem de auth
+type SettingsConfig struct {
+	RefreshInterval     time.Duration `mapstructure:"refresh_interval"`     // intervalo para cada instância reler as alterações feitas por admins
+	MaintenanceMode     bool          `mapstructure:"maintenance_mode"`     // apenas administradores acessam a API
+	RegistrationEnabled bool          `mapstructure:"registration_enabled"` // permite o cadastro de novos usuários
+}
+
+// WebhooksConfig contém os endpoints notificados de eventos de autenticação
+type WebhooksConfig struct {
+	Endpoints    []WebhookEndpointConfig `mapstructure:"endpoints"`
+	MaxAttempts  int                     `mapstructure:"max_attempts"`  // tentativas de cada entrega antes de descartar o evento
+	RetryBackoff time.Duration           `mapstructure:"retry_backoff"` // espera antes da primeira nova tentativa, dobra a cada falha
+	Timeout      time.Duration           `mapstructure:"timeout"`       // tempo máximo de cada requisição
+}
+
+// WebhookEndpointConfig é uma URL que recebe os eventos que assina
+type WebhookEndpointConfig struct {
+	URL    string   `mapstructure:"url"`
+	Secret string   `mapstructure:"secret"` // chave HMAC do header X-Webhook-Signature
+	Events []string `mapstructure:"events"` // user.registered, user.deleted, login.failed e/ou session.revoked
+}
+
+// ResilienceConfig controla o comportamento durante falhas passageiras do banco
+type ResilienceConfig struct {
+	// Leituras autenticadas (GET/HEAD) usam a última sessão validada com
+	// sucesso quando o banco falha; escritas sempre exigem o banco
+	ReadCacheFallback bool          `mapstructure:"read_cache_fallback"`
+	ReadCacheTTL      time.Duration `mapstructure:"read_cache_ttl"` // por quanto tempo uma sessão validada pode ser reaproveitada
 }
 
 // LogConfig contém configurações de logging
 type LogConfig struct {
-	Level  string `mapstructure:"level"`  // debug, info, warn, error
-	Format string `mapstructure:"format"` // json, text
+	Level   string `mapstructure:"level"`   // debug, info, warn, error
+	Format  string `mapstructure:"format"`  // json, text
+	Service string `mapstructure:"service"` // nome do serviço incluído em cada linha de log
 }
 
 type Config struct {
+	Environment Environment `mapstructure:"environment"` // development, staging, production
+
 	Server   ServerConfig   `mapstructure:"server"`
 	Database DatabaseConfig `mapstructure:"database"`
 	JWT      JWTConfig      `mapstructure:"jwt"`
 	Email    EmailConfig    `mapstructure:"email"`
-	Log      LogConfig      `mapstructure:"log"`
+	SMS      SMSConfig      `mapstructure:"sms"`
+	Auth     AuthConfig     `mapstructure:"auth"`
+	OAuth    OAuthConfig    `mapstructure:"oauth"`
+	Roles    []RoleConfig   `mapstructure:"roles"`
+
+	InitialUsers []InitialUserConfig `mapstructure:"initial_users"`
+	// DisableDefaultAdmin desativa, na inicialização, o usuário inicial admin
+	// assim que outro administrador ativo já tiver feito login
+	DisableDefaultAdmin bool `mapstructure:"disable_default_admin"`
+
+	Pagination PaginationConfig `mapstructure:"pagination"`
+	Tenancy    TenancyConfig    `mapstructure:"tenancy"`
+	Settings   SettingsConfig   `mapstructure:"settings"`
+	Webhooks   WebhooksConfig   `mapstructure:"webhooks"`
+	Jobs       JobsConfig       `mapstructure:"jobs"`
+	Storage    StorageConfig    `mapstructure:"storage"`
+	Realtime   RealtimeConfig   `mapstructure:"realtime"`
+	Resilience ResilienceConfig `mapstructure:"resilience"`
+	RateLimit  RateLimitConfig  `mapstructure:"rate_limit"`
+	HTTPCache  HTTPCacheConfig  `mapstructure:"http_cache"`
+	Redis      RedisConfig      `mapstructure:"redis"`
+	Metrics    MetricsConfig    `mapstructure:"metrics"`
+	Telemetry  TelemetryConfig  `mapstructure:"telemetry"`
+	Debug      DebugConfig      `mapstructure:"debug"`
+	Log        LogConfig        `mapstructure:"log"`
 }
 
 var cfg *Config
 
+// LoadConfig loads configs/app.yml, overridden by the environment (see
+// EnvPrefix), and validates it, reporting every problem found at once in a
+// *ValidationError
 func LoadConfig() (*Config, error) {
-	viper.SetConfigName("app")
-	viper.SetConfigType("yml")
-	viper.AddConfigPath("./configs")
+	return LoadConfigFile("")
+}
+
+// LoadConfigFile is LoadConfig reading path instead of configs/app.yml
+func LoadConfigFile(path string) (*Config, error) {
+	if path != "" {
+		viper.SetConfigFile(path)
+	} else {
+		viper.SetConfigName("app")
+		viper.SetConfigType("yml")
+		viper.AddConfigPath("./configs")
+	}
+	viper.SetDefault("environment", string(EnvDevelopment))
+	viper.SetDefault("email.welcome_trigger", "register")
+	viper.SetDefault("email.default_locale", "pt-BR")
+	viper.SetDefault("database.driver", DriverSQLite)
+	viper.SetDefault("database.dsn", "gosveltekit.db")
+	viper.SetDefault("database.migrate_on_start", true)
+	viper.SetDefault("server.max_header_bytes", DefaultMaxHeaderBytes)
+	viper.SetDefault("server.max_url_length", DefaultMaxURLLength)
+	viper.SetDefault("server.version_max_age", 5*time.Minute)
+	viper.SetDefault("server.cors.allow_localhost", true)
+	viper.SetDefault("server.cors.allow_credentials", true)
+	viper.SetDefault("server.cors.max_age", 12*time.Hour)
+	viper.SetDefault("tenancy.header", "X-Tenant-ID")
+	viper.SetDefault("settings.registration_enabled", true)
+	viper.SetDefault("auth.max_failed_logins_per_ip", 20)
+	viper.SetDefault("auth.clock_skew_leeway", 30*time.Second)
+	viper.SetDefault("resilience.read_cache_ttl", time.Minute)
+	viper.SetDefault("auth.revoke_sessions_on_reset", true)
+	viper.SetDefault("auth.keep_current_session_on_password_change", true)
+	viper.SetDefault("metrics.enabled", true)
+	viper.SetDefault("telemetry.service_name", "gosveltekit")
+	viper.SetDefault("telemetry.sample_ratio", 1.0)
+	viper.SetDefault("storage.driver", StorageDriverLocal)
+	viper.SetDefault("storage.signed_url_ttl", time.Hour)
+	viper.SetDefault("storage.local.dir", "uploads")
+	viper.SetDefault("storage.s3.region", "us-east-1")
+	viper.SetDefault("storage.avatar.max_bytes", 5<<20)
+	viper.SetDefault("storage.avatar.size", 256)
+	viper.SetDefault("realtime.enabled", true)
+	viper.SetDefault("http_cache.etags", true)
+
+	envProblems := bindEnv(viper.GetViper())
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("falha ao ler o arquivo de configuração: %w", err)
 	}
 
-	cfg = &Config{}
-	if err := viper.Unmarshal(cfg); err != nil {
+	loaded := &Config{}
+	if err := viper.Unmarshal(loaded); err != nil {
 		return nil, fmt.Errorf("falha ao carregar as configurações: %w", err)
 	}
 
-	return cfg, nil
+	err := loaded.Validate()
+	if len(envProblems) > 0 {
+		problems := envProblems
+		if validation, ok := err.(*ValidationError); ok {
+			problems = append(problems, validation.Problems...)
+		}
+		err = &ValidationError{Problems: problems}
+	}
+	if err != nil {
+		cfg = nil
+		return nil, err
+	}
 
+	cfg = loaded
+	return cfg, nil
 }
 
 func GetConfig() *Config {
@@ -3,7 +3,11 @@
 package config
 
 import (
+	"errors"
+	"maps"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -90,3 +94,333 @@ func TestGetConfigBeforeLoad(t *testing.T) {
 	config := GetConfig()
 	assert.Nil(t, config)
 }
+
+func TestServerConfigAbsoluteURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   ServerConfig
+		link     string
+		expected string
+	}{
+		{
+			name:     "absolute link is kept",
+			server:   ServerConfig{PublicURL: "https://example.com", BasePath: "/api"},
+			link:     "http://localhost:5173/reset?token=abc",
+			expected: "http://localhost:5173/reset?token=abc",
+		},
+		{
+			name:     "relative link uses public url and base path",
+			server:   ServerConfig{PublicURL: "https://example.com/", BasePath: "api/"},
+			link:     "/reset?token=abc",
+			expected: "https://example.com/api/reset?token=abc",
+		},
+		{
+			name:     "relative link without base path",
+			server:   ServerConfig{PublicURL: "https://example.com"},
+			link:     "reset?token=abc",
+			expected: "https://example.com/reset?token=abc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.server.AbsoluteURL(tt.link))
+		})
+	}
+}
+
+func TestLoadConfigEnvironment(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Environment
+		wantErr bool
+	}{
+		{name: "defaults to development", content: "server:\n  port: 8080\n", want: EnvDevelopment},
+		{name: "production", content: "environment: production\n", want: EnvProduction},
+		{name: "unknown environment", content: "environment: prod\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleanup := setupTestConfig(t)
+			defer cleanup()
+			assert.NoError(t, os.WriteFile("./configs/app.yml", []byte(tt.content), 0644))
+
+			config, err := LoadConfig()
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, config)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, config.Environment)
+			assert.Equal(t, tt.want.IsProduction(), config.Environment.IsProduction())
+		})
+	}
+}
+
+func TestAuthConfigValidate(t *testing.T) {
+	assert.NoError(t, AuthConfig{}.Validate())
+	assert.NoError(t, AuthConfig{TokenSources: []string{TokenSourceCookie, TokenSourceHeader}}.Validate())
+	assert.Error(t, AuthConfig{TokenSources: []string{"query"}}.Validate())
+	assert.Error(t, AuthConfig{TokenSources: []string{TokenSourceHeader, TokenSourceHeader}}.Validate())
+	assert.NoError(t, AuthConfig{TokenTransport: TokenTransportCookie, CookieSameSite: CookieSameSiteStrict}.Validate())
+	assert.Error(t, AuthConfig{TokenTransport: "query"}.Validate())
+	assert.Error(t, AuthConfig{CookieSameSite: "always"}.Validate())
+	assert.NoError(t, AuthConfig{ResetTokenMode: ResetTokenSigned, ResetTokenSecret: strings.Repeat("k", MinResetTokenSecretLength)}.Validate())
+	assert.Error(t, AuthConfig{ResetTokenMode: ResetTokenSigned, ResetTokenSecret: "short"}.Validate())
+	assert.Error(t, AuthConfig{ResetTokenMode: "jwt"}.Validate())
+	assert.NoError(t, AuthConfig{OnSessionLimit: SessionLimitReject}.Validate())
+	assert.Error(t, AuthConfig{OnSessionLimit: "queue"}.Validate())
+	assert.NoError(t, AuthConfig{SessionStore: SessionStoreRedis}.Validate())
+	assert.Error(t, AuthConfig{SessionStore: "memcached"}.Validate())
+	assert.NoError(t, AuthConfig{VerificationReminderAfter: 24 * time.Hour, UnverifiedAccountDeleteAfter: 720 * time.Hour}.Validate())
+	assert.Error(t, AuthConfig{VerificationReminderAfter: 24 * time.Hour, UnverifiedAccountDeleteAfter: time.Hour}.Validate())
+	assert.Error(t, AuthConfig{UnverifiedAccountDeleteAfter: 720 * time.Hour}.Validate(), "deleting requires the reminder")
+	secret := strings.Repeat("v", MinEmailVerificationSecretLength)
+	assert.NoError(t, AuthConfig{EmailVerificationSecret: secret, UnverifiedLogin: UnverifiedLoginDeny}.Validate())
+	assert.NoError(t, AuthConfig{EmailVerificationSecret: secret, UnverifiedLogin: UnverifiedLoginRestricted}.Validate())
+	assert.Error(t, AuthConfig{EmailVerificationSecret: "short"}.Validate())
+	assert.Error(t, AuthConfig{UnverifiedLogin: UnverifiedLoginDeny}.Validate(), "users need a way to verify")
+	assert.Error(t, AuthConfig{EmailVerificationSecret: secret, UnverifiedLogin: "block"}.Validate())
+}
+
+func TestOAuthConfigValidate(t *testing.T) {
+	server := ServerConfig{PublicURL: "https://api.example.com"}
+	github := OAuthProviderConfig{ClientID: "id", ClientSecret: "secret"}
+
+	assert.NoError(t, OAuthConfig{}.Validate(ServerConfig{}), "disabled by default")
+	assert.NoError(t, OAuthConfig{GitHub: github}.Validate(server))
+	assert.Error(t, OAuthConfig{GitHub: github}.Validate(ServerConfig{}), "callback URLs need the public URL")
+	assert.Error(t, OAuthConfig{Google: OAuthProviderConfig{ClientID: "id"}}.Validate(server))
+	assert.Error(t, OAuthConfig{OIDC: github}.Validate(server), "oidc needs an issuer")
+	assert.NoError(t, OAuthConfig{OIDC: OAuthProviderConfig{ClientID: "id", ClientSecret: "secret", IssuerURL: "https://sso.example.com/realms/app"}}.Validate(server))
+
+	assert.Equal(t, []string{OAuthGitHub}, slices.Collect(maps.Keys(OAuthConfig{GitHub: github, Google: OAuthProviderConfig{ClientSecret: "secret"}}.Providers())))
+	assert.Equal(t, RedactedValue, Config{OAuth: OAuthConfig{GitHub: github}}.Redacted().OAuth.GitHub.ClientSecret)
+}
+
+func TestRateLimitConfigValidate(t *testing.T) {
+	rule := RateLimitRuleConfig{Requests: 5, Window: time.Minute}
+
+	assert.NoError(t, RateLimitConfig{}.Validate(RedisConfig{}))
+	assert.NoError(t, RateLimitConfig{Key: RateLimitKeyUser, Auth: rule, Routes: map[string]RateLimitRuleConfig{"POST /auth/login": rule}}.Validate(RedisConfig{}))
+	assert.Error(t, RateLimitConfig{Store: RateLimitStoreRedis}.Validate(RedisConfig{}), "redis needs an address")
+	assert.NoError(t, RateLimitConfig{Store: RateLimitStoreRedis}.Validate(RedisConfig{Addr: "localhost:6379"}))
+	assert.Error(t, RateLimitConfig{Store: "memcached"}.Validate(RedisConfig{}))
+	assert.Error(t, RateLimitConfig{Key: "session"}.Validate(RedisConfig{}))
+	assert.Error(t, RateLimitConfig{API: RateLimitRuleConfig{Requests: 5}}.Validate(RedisConfig{}), "requests without window")
+	assert.Error(t, RateLimitConfig{Routes: map[string]RateLimitRuleConfig{"/auth/login": rule}}.Validate(RedisConfig{}), "route without method")
+	assert.Error(t, RateLimitConfig{Routes: map[string]RateLimitRuleConfig{"POST /auth/login": {Requests: 1, Window: time.Second, Key: "email"}}}.Validate(RedisConfig{}))
+}
+
+func TestHTTPCacheConfigValidate(t *testing.T) {
+	assert.NoError(t, HTTPCacheConfig{}.Validate(RedisConfig{}))
+	assert.NoError(t, HTTPCacheConfig{ETags: true, Routes: map[string]time.Duration{"GET /api/me": 30 * time.Second, "get /api/settings": time.Minute}}.Validate(RedisConfig{}))
+	assert.NoError(t, HTTPCacheConfig{Store: HTTPCacheStoreRedis}.Validate(RedisConfig{Addr: "localhost:6379"}))
+	assert.Error(t, HTTPCacheConfig{Store: HTTPCacheStoreRedis}.Validate(RedisConfig{}), "redis needs redis.addr")
+	assert.Error(t, HTTPCacheConfig{Store: "memcached"}.Validate(RedisConfig{}))
+	assert.Error(t, HTTPCacheConfig{MaxEntries: -1}.Validate(RedisConfig{}))
+	assert.Error(t, HTTPCacheConfig{Routes: map[string]time.Duration{"POST /api/me": time.Minute}}.Validate(RedisConfig{}), "only GET is cached")
+	assert.Error(t, HTTPCacheConfig{Routes: map[string]time.Duration{"/api/me": time.Minute}}.Validate(RedisConfig{}), "route without method")
+	assert.Error(t, HTTPCacheConfig{Routes: map[string]time.Duration{"GET /api/me": 0}}.Validate(RedisConfig{}))
+
+	assert.Equal(t, map[string]time.Duration{"GET /api/settings": time.Minute}, HTTPCacheConfig{Routes: map[string]time.Duration{"get /api/settings": time.Minute}}.RouteTTLs())
+}
+
+func TestTelemetryConfigValidate(t *testing.T) {
+	assert.NoError(t, TelemetryConfig{}.Validate(), "disabled needs nothing")
+	assert.NoError(t, TelemetryConfig{Enabled: true, Endpoint: "http://localhost:4318", SampleRatio: 0.25}.Validate())
+	assert.Error(t, TelemetryConfig{Enabled: true}.Validate(), "enabled needs an endpoint")
+	assert.Error(t, TelemetryConfig{Enabled: true, Endpoint: "localhost:4318"}.Validate(), "endpoint needs a scheme")
+	assert.Error(t, TelemetryConfig{Enabled: true, Endpoint: "http://localhost:4318", SampleRatio: 1.5}.Validate())
+}
+
+func TestCORSConfigValidate(t *testing.T) {
+	assert.NoError(t, CORSConfig{}.Validate())
+	assert.NoError(t, CORSConfig{AllowedOrigins: []string{"https://app.example.com", "http://localhost:5173", "https://*.example.com"}, AllowCredentials: true}.Validate())
+	assert.NoError(t, CORSConfig{AllowedOrigins: []string{"*"}}.Validate())
+	assert.Error(t, CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}.Validate(), "browsers refuse credentials with *")
+	assert.Error(t, CORSConfig{AllowedOrigins: []string{"*", "https://app.example.com"}}.Validate())
+	assert.Error(t, CORSConfig{AllowedOrigins: []string{"app.example.com"}}.Validate(), "origin needs a scheme")
+	assert.Error(t, CORSConfig{AllowedOrigins: []string{"https://app.example.com/login"}}.Validate(), "origin has no path")
+	assert.Error(t, CORSConfig{MaxAge: -time.Second}.Validate())
+}
+
+func TestCORSConfigAllowsOrigin(t *testing.T) {
+	cfg := CORSConfig{AllowedOrigins: []string{"https://app.example.com/", "https://*.example.org"}, AllowLocalhost: true}
+	for _, origin := range []string{"https://app.example.com", "https://admin.example.org", "http://localhost:5173", "http://127.0.0.1"} {
+		assert.True(t, cfg.AllowsOrigin(origin), origin)
+	}
+	for _, origin := range []string{"https://evil.com", "http://app.example.com", "https://example.org", "https://admin.example.org.evil.com", "http://localhost.evil.com"} {
+		assert.False(t, cfg.AllowsOrigin(origin), origin)
+	}
+	assert.True(t, CORSConfig{AllowedOrigins: []string{"*"}}.AllowsOrigin("https://anything.example"))
+	assert.False(t, CORSConfig{}.AllowsOrigin("http://localhost:5173"))
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	assert.NoError(t, TLSConfig{}.Validate(8080), "plain HTTP")
+	assert.NoError(t, TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", HTTPPort: 80}.Validate(443))
+	assert.NoError(t, TLSConfig{AutocertDomains: []string{"api.example.com"}, HTTPPort: 80}.Validate(443))
+	assert.Error(t, TLSConfig{CertFile: "cert.pem"}.Validate(443), "needs the key")
+	assert.Error(t, TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", AutocertDomains: []string{"api.example.com"}, HTTPPort: 80}.Validate(443))
+	assert.Error(t, TLSConfig{AutocertDomains: []string{"api.example.com"}}.Validate(443), "autocert needs the challenge port")
+	assert.Error(t, TLSConfig{AutocertDomains: []string{"https://api.example.com"}, HTTPPort: 80}.Validate(443))
+	assert.Error(t, TLSConfig{AutocertDomains: []string{"api.example.com"}, HTTPPort: 80, AutocertDirectoryURL: "http://acme.local"}.Validate(443))
+	assert.Error(t, TLSConfig{HTTPPort: 80}.Validate(8080), "nothing to redirect to")
+	assert.Error(t, TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", HTTPPort: 443}.Validate(443))
+}
+
+func TestJobsConfigValidate(t *testing.T) {
+	assert.NoError(t, JobsConfig{}.Validate(RedisConfig{}), "database by default")
+	assert.NoError(t, JobsConfig{Store: JobsStoreRedis}.Validate(RedisConfig{Addr: "localhost:6379"}))
+	assert.Error(t, JobsConfig{Store: JobsStoreRedis}.Validate(RedisConfig{}), "redis needs redis.addr")
+	assert.Error(t, JobsConfig{Store: "memory"}.Validate(RedisConfig{}))
+	assert.Error(t, JobsConfig{Workers: -1}.Validate(RedisConfig{}))
+}
+
+func TestRealtimeConfigValidate(t *testing.T) {
+	assert.NoError(t, RealtimeConfig{}.Validate())
+	assert.NoError(t, RealtimeConfig{Enabled: true, PingInterval: 30 * time.Second, PongTimeout: 10 * time.Second, MaxConnectionsPerUser: 10}.Validate())
+	assert.Error(t, RealtimeConfig{PingInterval: -time.Second}.Validate())
+	assert.Error(t, RealtimeConfig{PongTimeout: -time.Second}.Validate())
+	assert.Error(t, RealtimeConfig{MaxConnectionsPerUser: -1}.Validate())
+}
+
+func TestStorageConfigValidate(t *testing.T) {
+	valid := StorageConfig{Driver: StorageDriverLocal, SignedURLTTL: time.Hour, Local: LocalStorageConfig{Dir: "uploads"}, Avatar: AvatarConfig{MaxBytes: 5 << 20, Size: 256}}
+	assert.NoError(t, valid.Validate())
+
+	s3 := valid
+	s3.Driver = StorageDriverS3
+	s3.S3 = S3StorageConfig{Endpoint: "http://localhost:9000", Bucket: "avatars", AccessKeyID: "minio", SecretAccessKey: "minio-secret", PathStyle: true}
+	assert.NoError(t, s3.Validate())
+
+	invalid := map[string]func(*StorageConfig){
+		"unknown driver":     func(c *StorageConfig) { c.Driver = "ftp" },
+		"short url secret":   func(c *StorageConfig) { c.Local.URLSecret = "short" },
+		"no ttl":             func(c *StorageConfig) { c.SignedURLTTL = 0 },
+		"ttl beyond 7 days":  func(c *StorageConfig) { c.SignedURLTTL = 8 * 24 * time.Hour },
+		"no avatar limit":    func(c *StorageConfig) { c.Avatar.MaxBytes = 0 },
+		"tiny avatar":        func(c *StorageConfig) { c.Avatar.Size = 8 },
+		"s3 without bucket":  func(c *StorageConfig) { *c = s3; c.S3.Bucket = "" },
+		"s3 without scheme":  func(c *StorageConfig) { *c = s3; c.S3.Endpoint = "localhost:9000" },
+		"s3 with a path":     func(c *StorageConfig) { *c = s3; c.S3.Endpoint = "http://localhost:9000/s3" },
+		"s3 without secrets": func(c *StorageConfig) { *c = s3; c.S3.SecretAccessKey = "" },
+	}
+	for name, change := range invalid {
+		c := valid
+		change(&c)
+		assert.Error(t, c.Validate(), name)
+	}
+}
+
+func TestLoadConfig_RateLimitRoutes(t *testing.T) {
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+	f, err := os.OpenFile("./configs/app.yml", os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`
+rate_limit:
+  routes:
+    POST /auth/login:
+      requests: 10
+      window: 1m
+      key: user
+`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	config, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]RateLimitRuleConfig{
+		"POST /auth/login": {Requests: 10, Window: time.Minute, Key: RateLimitKeyUser},
+	}, config.RateLimit.RouteRules())
+}
+
+func TestConfig_VerboseErrors(t *testing.T) {
+	on, off := true, false
+	assert.True(t, (&Config{Environment: EnvDevelopment}).VerboseErrors())
+	assert.True(t, (&Config{Environment: EnvStaging}).VerboseErrors())
+	assert.False(t, (&Config{Environment: EnvProduction}).VerboseErrors())
+	assert.False(t, (&Config{Environment: EnvDevelopment, Debug: DebugConfig{VerboseErrors: &off}}).VerboseErrors())
+	assert.False(t, (&Config{Environment: EnvProduction, Debug: DebugConfig{VerboseErrors: &on}}).VerboseErrors(), "never in production")
+}
+
+func TestLoadConfig_Env(t *testing.T) {
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+	secret := t.TempDir() + "/smtp_password"
+	assert.NoError(t, os.WriteFile(secret, []byte("s3cret\n"), 0600))
+
+	t.Setenv("APP_SERVER_PORT", "9090")
+	t.Setenv("APP_AUTH_SESSION_IDLE_TIMEOUT", "2h")
+	t.Setenv("APP_SERVER_CORS_ALLOWED_ORIGINS", "https://a.example.com,https://b.example.com")
+	t.Setenv("APP_JWT_SECRET_KEY", "from-env")
+	t.Setenv("APP_EMAIL_SMTP_PASSWORD_FILE", secret)
+
+	config, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, 9090, config.Server.Port)
+	assert.Equal(t, 2*time.Hour, config.Auth.SessionIdleTimeout)
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, config.Server.CORS.AllowedOrigins)
+	assert.Equal(t, "from-env", config.JWT.SecretKey)
+	assert.Equal(t, "s3cret", config.Email.SMTPPassword)
+	assert.Equal(t, "test.db", config.Database.DSN, "keys without a variable keep the file's value")
+}
+
+func TestLoadConfig_AggregatesProblems(t *testing.T) {
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+	assert.NoError(t, os.WriteFile("./configs/app.yml", []byte("environment: prod\nemail:\n  smtp_host: smtp.example.com\n  smtp_port: 70000\n"), 0644))
+	t.Setenv("APP_AUTH_RESET_TOKEN_SECRET_FILE", t.TempDir()+"/missing")
+
+	config, err := LoadConfig()
+	assert.Nil(t, config)
+	var validation *ValidationError
+	if assert.ErrorAs(t, err, &validation) {
+		assert.Len(t, validation.Problems, 3)
+	}
+	for _, want := range []string{"APP_AUTH_RESET_TOKEN_SECRET_FILE", "ambiente desconhecido", "email.smtp_port"} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	defer func() {
+		viper.Reset()
+		cfg = nil
+	}()
+	path := t.TempDir() + "/custom.yml"
+	assert.NoError(t, os.WriteFile(path, []byte("server:\n  port: 7070\n"), 0644))
+
+	config, err := LoadConfigFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 7070, config.Server.Port)
+	assert.Equal(t, 30*time.Second, config.Auth.ClockSkewLeeway, "default when unset")
+}
+
+func TestLoadConfigFile_ZeroClockSkewLeeway(t *testing.T) {
+	defer func() {
+		viper.Reset()
+		cfg = nil
+	}()
+	path := t.TempDir() + "/custom.yml"
+	assert.NoError(t, os.WriteFile(path, []byte("auth:\n  clock_skew_leeway: 0s\n"), 0644))
+
+	config, err := LoadConfigFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), config.Auth.ClockSkewLeeway, "0 turns the leeway off")
+
+	assert.Error(t, AuthConfig{ClockSkewLeeway: -time.Second}.Validate())
+}
+
+func TestValidationError(t *testing.T) {
+	single := &ValidationError{Problems: []error{errors.New("server.port inválido")}}
+	assert.Equal(t, "configuração inválida: server.port inválido", single.Error())
+
+	multiple := &ValidationError{Problems: []error{errors.New("a"), errors.New("b")}}
+	assert.Equal(t, "configuração inválida (2 problemas):\n  - a\n  - b", multiple.Error())
+}
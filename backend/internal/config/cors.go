@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CORSConfig contém o CORS, para frontends servidos de outra origem (como o
+// servidor de desenvolvimento do SvelteKit). Sem origens permitidas nem
+// allow_localhost, requisições de outras origens são recusadas pelo navegador
+type CORSConfig struct {
+	AllowedOrigins   []string      `mapstructure:"allowed_origins"`   // origens exatas (https://app.example.com), com subdomínio curinga (https://*.example.com) ou "*" para qualquer uma (sem credenciais)
+	AllowLocalhost   bool          `mapstructure:"allow_localhost"`   // aceita http://localhost e http://127.0.0.1 em qualquer porta
+	AllowedMethods   []string      `mapstructure:"allowed_methods"`   // vazio usa GET, POST, PUT, PATCH, DELETE e OPTIONS
+	AllowedHeaders   []string      `mapstructure:"allowed_headers"`   // cabeçalhos aceitos nas requisições; vazio usa os da API (Authorization, X-CSRF-Token, X-Tenant-ID...)
+	ExposedHeaders   []string      `mapstructure:"exposed_headers"`   // cabeçalhos legíveis pelo frontend; vazio usa os da API (ETag, X-Request-ID, X-RateLimit-*...)
+	AllowCredentials bool          `mapstructure:"allow_credentials"` // envia cookies nas requisições de outra origem
+	MaxAge           time.Duration `mapstructure:"max_age"`           // por quanto tempo o navegador guarda a resposta do preflight (0 usa 12h)
+}
+
+// Enabled reports whether any origin is allowed
+func (c CORSConfig) Enabled() bool {
+	return len(c.AllowedOrigins) > 0 || c.AllowLocalhost
+}
+
+// AllowsAllOrigins reports whether allowed_origins is "*"
+func (c CORSConfig) AllowsAllOrigins() bool {
+	return len(c.AllowedOrigins) == 1 && c.AllowedOrigins[0] == "*"
+}
+
+// Validate checks the origins: "*" alone and without credentials, or
+// http(s) origins with no path
+func (c CORSConfig) Validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if len(c.AllowedOrigins) > 1 {
+				return fmt.Errorf("server.cors.allowed_origins: \"*\" já aceita qualquer origem, não o combine com outras")
+			}
+			if c.AllowCredentials {
+				return fmt.Errorf("server.cors.allow_credentials não pode ser usado com allowed_origins \"*\" (liste as origens)")
+			}
+			continue
+		}
+		u, err := url.Parse(strings.Replace(origin, "*.", "", 1))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return fmt.Errorf("server.cors.allowed_origins inválido: %q (use http(s)://host[:porta])", origin)
+		}
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("server.cors.max_age não pode ser negativo")
+	}
+	return nil
+}
+
+// AllowsOrigin reports whether a browser on origin may call the API, as the
+// CORS middleware decides, for checks it doesn't cover (WebSocket handshakes)
+func (c CORSConfig) AllowsOrigin(origin string) bool {
+	if c.AllowLocalhost && IsLocalhostOrigin(origin) {
+		return true
+	}
+	for _, allowed := range c.AllowedOrigins {
+		allowed = strings.TrimSuffix(allowed, "/")
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if prefix, suffix, wildcard := strings.Cut(allowed, "*"); wildcard &&
+			len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLocalhostOrigin reports whether origin is http://localhost or
+// http://127.0.0.1, on any port
+func IsLocalhostOrigin(origin string) bool {
+	return strings.HasPrefix(origin, "http://localhost:") || strings.HasPrefix(origin, "http://127.0.0.1:") ||
+		origin == "http://localhost" || origin == "http://127.0.0.1"
+}
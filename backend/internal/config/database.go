@@ -0,0 +1,325 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Supported database drivers
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
+
+var (
+	// ErrInvalidDSN is wrapped by every DSN validation error
+	ErrInvalidDSN = errors.New("database.dsn inválido")
+	// ErrInvalidPragma is wrapped by every database.sqlite_pragmas validation error
+	ErrInvalidPragma = errors.New("database.sqlite_pragmas inválido")
+)
+
+// SQLitePragmas are the PRAGMAs accepted in database.sqlite_pragmas. They are
+// the ones the sqlite driver can apply to every new connection.
+var SQLitePragmas = []string{
+	"auto_vacuum",
+	"busy_timeout",
+	"cache_size",
+	"case_sensitive_like",
+	"defer_foreign_keys",
+	"foreign_keys",
+	"journal_mode",
+	"locking_mode",
+	"recursive_triggers",
+	"secure_delete",
+	"synchronous",
+}
+
+// dangerousPragmas can corrupt the database or silently skip integrity checks
+var dangerousPragmas = map[string]bool{
+	"writable_schema":          true,
+	"ignore_check_constraints": true,
+}
+
+var pragmaValuePattern = regexp.MustCompile(`^-?[A-Za-z0-9_]+$`)
+
+var postgresSSLModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// Normalize validates the DSN (and read replica DSN, if set) for the
+// configured driver and fills in defaults, so connection problems surface at
+// startup as config errors instead of cryptic driver errors. An empty driver
+// means sqlite.
+//
+// Postgres DSNs without sslmode get "disable" for local hosts and "require"
+// otherwise. MySQL DSNs always get parseTime=true, which GORM needs to read
+// timestamps. SQLite DSNs must be ":memory:" or point to a writable
+// directory, and SQLite PRAGMAs must be one of SQLitePragmas.
+func (d *DatabaseConfig) Normalize() error {
+	if d.Driver == "" {
+		d.Driver = DriverSQLite
+	}
+	if d.Driver != DriverSQLite && d.Driver != DriverPostgres && d.Driver != DriverMySQL {
+		return fmt.Errorf("database.driver inválido %q (use %s, %s ou %s)", d.Driver, DriverSQLite, DriverPostgres, DriverMySQL)
+	}
+	if d.MaxOpenConns < 0 || d.MaxIdleConns < 0 || d.ConnMaxLifetime < 0 || d.ConnMaxIdleTime < 0 {
+		return errors.New("database: limites do pool de conexões não podem ser negativos")
+	}
+
+	dsn, err := normalizeDSN(d.Driver, d.DSN)
+	if err != nil {
+		return err
+	}
+	d.DSN = dsn
+
+	if d.ReadReplicaDSN != "" {
+		replica, err := normalizeDSN(d.Driver, d.ReadReplicaDSN)
+		if err != nil {
+			return fmt.Errorf("database.read_replica_dsn: %w", err)
+		}
+		d.ReadReplicaDSN = replica
+	}
+	return d.normalizePragmas()
+}
+
+// normalizePragmas lowercases the PRAGMA names and rejects unknown or
+// dangerous ones
+func (d *DatabaseConfig) normalizePragmas() error {
+	if len(d.SQLitePragmas) == 0 {
+		return nil
+	}
+	if d.Driver != DriverSQLite {
+		return fmt.Errorf("%w: só se aplica ao driver %s", ErrInvalidPragma, DriverSQLite)
+	}
+
+	pragmas := make(map[string]string, len(d.SQLitePragmas))
+	for name, value := range d.SQLitePragmas {
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		switch {
+		case dangerousPragmas[name]:
+			return fmt.Errorf("%w: %s não é permitido", ErrInvalidPragma, name)
+		case !slices.Contains(SQLitePragmas, name):
+			return fmt.Errorf("%w: %s desconhecido (use %s)", ErrInvalidPragma, name, strings.Join(SQLitePragmas, ", "))
+		case !pragmaValuePattern.MatchString(value):
+			return fmt.Errorf("%w: valor %q inválido para %s", ErrInvalidPragma, value, name)
+		}
+		pragmas[name] = value
+	}
+	d.SQLitePragmas = pragmas
+	return nil
+}
+
+func normalizeDSN(driver, dsn string) (string, error) {
+	if strings.TrimSpace(dsn) == "" {
+		return "", fmt.Errorf("%w: valor vazio", ErrInvalidDSN)
+	}
+	switch driver {
+	case DriverPostgres:
+		return normalizePostgresDSN(dsn)
+	case DriverMySQL:
+		return normalizeMySQLDSN(dsn)
+	}
+	return dsn, validateSQLiteDSN(dsn)
+}
+
+// normalizeMySQLDSN validates a go-sql-driver DSN
+// ("usuario:senha@tcp(host:3306)/banco") and enables parseTime
+func normalizeMySQLDSN(dsn string) (string, error) {
+	if strings.Contains(dsn, "://") {
+		return "", fmt.Errorf("%w: use o formato usuario:senha@tcp(host:3306)/banco, não uma URL", ErrInvalidDSN)
+	}
+	// ParseDSN errors may quote the DSN, which holds the password
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("%w: DSN mysql malformado (use usuario:senha@tcp(host:3306)/banco)", ErrInvalidDSN)
+	}
+	if cfg.DBName == "" {
+		return "", fmt.Errorf("%w: nome do banco ausente no DSN mysql", ErrInvalidDSN)
+	}
+	if cfg.ParseTime {
+		return dsn, nil
+	}
+	cfg.ParseTime = true
+	return cfg.FormatDSN(), nil
+}
+
+// validateSQLiteDSN checks that the database file can be created or opened
+func validateSQLiteDSN(dsn string) error {
+	path := strings.TrimPrefix(dsn, "file:")
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	if path == ":memory:" || strings.Contains(dsn, "mode=memory") {
+		return nil
+	}
+	if path == "" {
+		return fmt.Errorf("%w: caminho do arquivo sqlite ausente em %q", ErrInvalidDSN, dsn)
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return fmt.Errorf("%w: %q é um diretório, informe o caminho do arquivo sqlite", ErrInvalidDSN, path)
+	}
+
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%w: diretório %q do arquivo sqlite não existe", ErrInvalidDSN, dir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %q não é um diretório", ErrInvalidDSN, dir)
+	}
+
+	// Permission bits don't tell the whole story (ACLs, read-only mounts), so try it
+	probe, err := os.CreateTemp(dir, ".dsn-check-*")
+	if err != nil {
+		return fmt.Errorf("%w: sem permissão de escrita no diretório %q do arquivo sqlite", ErrInvalidDSN, dir)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
+
+// normalizePostgresDSN validates a URL ("postgres://...") or key/value
+// ("host=... dbname=...") DSN and adds sslmode when missing
+func normalizePostgresDSN(dsn string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return normalizePostgresURL(dsn)
+	}
+	if strings.Contains(dsn, "://") {
+		return "", fmt.Errorf("%w: esquema de URL não suportado, use postgres://", ErrInvalidDSN)
+	}
+	return normalizePostgresKeyValue(dsn)
+}
+
+func normalizePostgresURL(dsn string) (string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		// url errors echo the whole DSN, which may contain the password
+		return "", fmt.Errorf("%w: URL postgres malformada", ErrInvalidDSN)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("%w: host ausente na URL postgres", ErrInvalidDSN)
+	}
+	if err := validatePort(u.Port()); err != nil {
+		return "", err
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		return "", fmt.Errorf("%w: nome do banco ausente na URL postgres (postgres://host/banco)", ErrInvalidDSN)
+	}
+
+	query := u.Query()
+	sslmode := query.Get("sslmode")
+	if sslmode == "" {
+		query.Set("sslmode", defaultSSLMode(u.Hostname()))
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+	if !postgresSSLModes[sslmode] {
+		return "", fmt.Errorf("%w: sslmode %q desconhecido", ErrInvalidDSN, sslmode)
+	}
+	return dsn, nil
+}
+
+func normalizePostgresKeyValue(dsn string) (string, error) {
+	params, err := parseKeyValueDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+
+	host := params["host"]
+	if host == "" {
+		return "", fmt.Errorf("%w: parâmetro host ausente (ex.: host=localhost dbname=app)", ErrInvalidDSN)
+	}
+	if params["dbname"] == "" {
+		return "", fmt.Errorf("%w: parâmetro dbname ausente", ErrInvalidDSN)
+	}
+	if err := validatePort(params["port"]); err != nil {
+		return "", err
+	}
+
+	sslmode, ok := params["sslmode"]
+	if !ok {
+		return strings.TrimSpace(dsn) + " sslmode=" + defaultSSLMode(host), nil
+	}
+	if !postgresSSLModes[sslmode] {
+		return "", fmt.Errorf("%w: sslmode %q desconhecido", ErrInvalidDSN, sslmode)
+	}
+	return dsn, nil
+}
+
+// parseKeyValueDSN parses libpq key/value pairs, with optional single quotes
+// around values
+func parseKeyValueDSN(dsn string) (map[string]string, error) {
+	params := make(map[string]string)
+	rest := strings.TrimSpace(dsn)
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq <= 0 || strings.ContainsAny(rest[:eq], " \t") {
+			return nil, fmt.Errorf("%w: esperado chave=valor perto de %q", ErrInvalidDSN, firstField(rest))
+		}
+		key := rest[:eq]
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, "'") {
+			end := strings.Index(rest[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("%w: aspas não fechadas no valor de %s", ErrInvalidDSN, key)
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else {
+			field := firstField(rest)
+			value = field
+			rest = rest[len(field):]
+		}
+		params[key] = value
+		rest = strings.TrimLeft(rest, " \t")
+	}
+	return params, nil
+}
+
+func firstField(s string) string {
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func validatePort(port string) error {
+	if port == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%w: porta %q inválida", ErrInvalidDSN, port)
+	}
+	return nil
+}
+
+// defaultSSLMode disables TLS only for connections that never leave the machine
+func defaultSSLMode(host string) string {
+	if host == "localhost" || strings.HasPrefix(host, "/") {
+		return "disable"
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return "disable"
+	}
+	return "require"
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix starts the environment variables overriding the config file:
+// APP_ followed by the key in upper case with dots and dashes as
+// underscores, e.g. APP_DATABASE_DSN for database.dsn. Lists take
+// comma-separated values (APP_SERVER_CORS_ALLOWED_ORIGINS=a,b) and durations
+// Go syntax (APP_AUTH_SESSION_IDLE_TIMEOUT=30m).
+const EnvPrefix = "APP"
+
+// FileEnvSuffix makes a variable name a file holding the value, as Docker
+// and Kubernetes mount secrets: APP_EMAIL_SMTP_PASSWORD_FILE=/run/secrets/smtp.
+// Trailing newlines of the file are dropped.
+const FileEnvSuffix = "_FILE"
+
+// EnvVar returns the environment variable overriding key
+func EnvVar(key string) string {
+	return EnvPrefix + "_" + strings.NewReplacer(".", "_", "-", "_").Replace(strings.ToUpper(key))
+}
+
+// bindEnv makes every key of Config overridable from the environment, see
+// EnvPrefix and FileEnvSuffix. It returns the variables that couldn't be
+// used: unreadable files, or a key set both directly and from a file.
+func bindEnv(v *viper.Viper) []error {
+	var problems []error
+	for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+		name := EnvVar(key)
+		path, fromFile := os.LookupEnv(name + FileEnvSuffix)
+		if !fromFile {
+			_ = v.BindEnv(key, name)
+			continue
+		}
+		if _, ok := os.LookupEnv(name); ok {
+			problems = append(problems, fmt.Errorf("%s e %s%s definidas ao mesmo tempo; use apenas uma", name, name, FileEnvSuffix))
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s%s: falha ao ler %s: %w", name, FileEnvSuffix, path, err))
+			continue
+		}
+		v.Set(key, strings.TrimRight(string(content), "\r\n"))
+	}
+	return problems
+}
+
+// configKeys lists the keys of the leaf fields of t, a config struct. Lists
+// and maps are leaves: their elements can't be set one by one.
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + name
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+			keys = append(keys, configKeys(field.Type, key+".")...)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
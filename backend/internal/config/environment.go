@@ -0,0 +1,34 @@
+// backend/internal/config/environment.go
+
+package config
+
+import "fmt"
+
+// Environment is the deployment environment the application runs in
+type Environment string
+
+const (
+	EnvDevelopment Environment = "development"
+	EnvStaging     Environment = "staging"
+	EnvProduction  Environment = "production"
+)
+
+// IsProduction reports whether the application runs in production
+func (e Environment) IsProduction() bool {
+	return e == EnvProduction
+}
+
+// IsDevelopment reports whether the application runs in development
+func (e Environment) IsDevelopment() bool {
+	return e == EnvDevelopment
+}
+
+// Validate returns an error if e is not a known environment
+func (e Environment) Validate() error {
+	switch e {
+	case EnvDevelopment, EnvStaging, EnvProduction:
+		return nil
+	default:
+		return fmt.Errorf("ambiente desconhecido %q (use development, staging ou production)", string(e))
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTP cache stores
+const (
+	HTTPCacheStoreMemory = "memory"
+	HTTPCacheStoreRedis  = "redis"
+)
+
+// HTTPCacheConfig contém o cache HTTP: ETags com respostas 304 para
+// requisições condicionais e, nas rotas listadas, um cache de respostas
+// separado por sessão (ou chave de API)
+type HTTPCacheConfig struct {
+	ETags      bool   `mapstructure:"etags"`       // calcula o ETag das respostas GET e responde 304 a If-None-Match e If-Modified-Since
+	Store      string `mapstructure:"store"`       // memory (por instância) ou redis (compartilhado, usa a seção redis)
+	MaxEntries int    `mapstructure:"max_entries"` // respostas guardadas por instância com store memory (0 usa 1000)
+
+	// Rotas GET guardadas em cache e por quanto tempo, como
+	// "GET /api/me": 30s (relativas ao base_path). Vazio desliga o cache
+	Routes map[string]time.Duration `mapstructure:"routes"`
+}
+
+// Validate checks the store and the routes
+func (h HTTPCacheConfig) Validate(redis RedisConfig) error {
+	switch h.Store {
+	case "", HTTPCacheStoreMemory:
+	case HTTPCacheStoreRedis:
+		if err := redis.Require("http_cache.store"); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("http_cache.store inválido: %q (use memory ou redis)", h.Store)
+	}
+	if h.MaxEntries < 0 {
+		return fmt.Errorf("http_cache.max_entries não pode ser negativo")
+	}
+	for route, ttl := range h.Routes {
+		method, path, ok := strings.Cut(route, " ")
+		if !ok || !strings.EqualFold(method, http.MethodGet) || !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("http_cache.routes: rota inválida %q (use \"GET /caminho\")", route)
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("http_cache.routes.%s: a duração deve ser positiva", route)
+		}
+	}
+	return nil
+}
+
+// RouteTTLs returns Routes keyed by "GET /path", see RateLimitConfig.RouteRules
+func (h HTTPCacheConfig) RouteTTLs() map[string]time.Duration {
+	ttls := make(map[string]time.Duration, len(h.Routes))
+	for route, ttl := range h.Routes {
+		method, path, _ := strings.Cut(route, " ")
+		ttls[strings.ToUpper(method)+" "+path] = ttl
+	}
+	return ttls
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Armazenamentos da fila de jobs
+const (
+	JobsStoreDatabase = "database"
+	JobsStoreRedis    = "redis"
+)
+
+// JobsConfig contém a fila de jobs em segundo plano (emails, limpezas
+// agendadas). Os valores zerados usam os padrões do pacote jobs
+type JobsConfig struct {
+	Store        string        `mapstructure:"store"`         // database (tabela jobs, enfileira na transação da operação) ou redis (usa a seção redis)
+	Workers      int           `mapstructure:"workers"`       // jobs executados ao mesmo tempo por instância (padrão 4)
+	PollInterval time.Duration `mapstructure:"poll_interval"` // intervalo de busca por jobs pendentes (padrão 1s)
+	MaxAttempts  int           `mapstructure:"max_attempts"`  // tentativas antes de marcar o job como falho (padrão 5)
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"` // espera antes da primeira nova tentativa, dobra a cada falha até 1h (padrão 30s)
+	StaleAfter   time.Duration `mapstructure:"stale_after"`   // jobs em execução há mais tempo voltam para a fila (padrão 15m)
+	Retention    time.Duration `mapstructure:"retention"`     // por quanto tempo jobs finalizados ficam visíveis (padrão 7 dias)
+}
+
+// Validate checks the store
+func (j JobsConfig) Validate(redis RedisConfig) error {
+	switch j.Store {
+	case "", JobsStoreDatabase:
+	case JobsStoreRedis:
+		if err := redis.Require("jobs.store"); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("jobs.store inválido: %q (use database ou redis)", j.Store)
+	}
+	if j.Workers < 0 || j.MaxAttempts < 0 {
+		return fmt.Errorf("jobs.workers e jobs.max_attempts não podem ser negativos")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// OAuth provider names, used in the login routes and in linked identities
+const (
+	OAuthGoogle = "google"
+	OAuthGitHub = "github"
+	OAuthOIDC   = "oidc"
+)
+
+// OAuthConfig contém os provedores de login social. Um provedor fica ativo
+// quando client_id é preenchido; a URL de retorno a cadastrar nele é
+// <server.public_url><base_path>/auth/oauth/<provedor>/callback
+type OAuthConfig struct {
+	RedirectURL string `mapstructure:"redirect_url"` // página do frontend aberta após o login; falhas chegam em ?error=<código>. Vazio responde em JSON
+
+	Google OAuthProviderConfig `mapstructure:"google"`
+	GitHub OAuthProviderConfig `mapstructure:"github"`
+	OIDC   OAuthProviderConfig `mapstructure:"oidc"` // provedor OpenID Connect genérico (Keycloak, Auth0, Entra ID...)
+}
+
+// OAuthProviderConfig contém as credenciais de um provedor
+type OAuthProviderConfig struct {
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	IssuerURL    string   `mapstructure:"issuer_url"` // só oidc: emissor usado na descoberta (/.well-known/openid-configuration)
+	Scopes       []string `mapstructure:"scopes"`     // só oidc; vazio usa openid, email e profile
+}
+
+// Enabled reports whether the provider has credentials
+func (p OAuthProviderConfig) Enabled() bool {
+	return p.ClientID != ""
+}
+
+// Providers returns the enabled providers by name
+func (o OAuthConfig) Providers() map[string]OAuthProviderConfig {
+	providers := make(map[string]OAuthProviderConfig)
+	for name, p := range map[string]OAuthProviderConfig{OAuthGoogle: o.Google, OAuthGitHub: o.GitHub, OAuthOIDC: o.OIDC} {
+		if p.Enabled() {
+			providers[name] = p
+		}
+	}
+	return providers
+}
+
+// Validate checks that every enabled provider is complete and that the
+// callback URLs can be built from server.public_url
+func (o OAuthConfig) Validate(server ServerConfig) error {
+	providers := o.Providers()
+	if len(providers) == 0 {
+		return nil
+	}
+	if server.PublicURL == "" {
+		return fmt.Errorf("oauth requer server.public_url para montar as URLs de retorno")
+	}
+	for name, p := range providers {
+		if p.ClientSecret == "" {
+			return fmt.Errorf("oauth.%s.client_secret é obrigatório", name)
+		}
+	}
+	if o.OIDC.Enabled() {
+		if u, err := url.Parse(o.OIDC.IssuerURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("oauth.oidc.issuer_url inválido %q", o.OIDC.IssuerURL)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rate limit stores and keys
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+
+	RateLimitKeyIP     = "ip"
+	RateLimitKeyUser   = "user"
+	RateLimitKeyAPIKey = "api_key"
+)
+
+// RateLimitConfig contém os limites de requisições. Cada regra permite
+// requests requisições por window para cada chave (IP, usuário ou API key)
+type RateLimitConfig struct {
+	Store string `mapstructure:"store"` // memory (por instância) ou redis (compartilhado, usa a seção redis)
+	Key   string `mapstructure:"key"`   // chave padrão das regras: ip, user (IP para anônimos) ou api_key (chave de API da requisição, IP sem ela)
+
+	Auth RateLimitRuleConfig `mapstructure:"auth"` // rotas /auth (login, cadastro, recuperação de senha...)
+	API  RateLimitRuleConfig `mapstructure:"api"`  // rotas /api
+
+	// Regras por rota, no lugar da regra do grupo, como "POST /auth/login"
+	// (relativas ao base_path)
+	Routes map[string]RateLimitRuleConfig `mapstructure:"routes"`
+}
+
+// RateLimitRuleConfig é uma regra de rate limit
+type RateLimitRuleConfig struct {
+	Requests int           `mapstructure:"requests"`
+	Window   time.Duration `mapstructure:"window"`
+	Key      string        `mapstructure:"key"` // vazio usa rate_limit.key
+}
+
+// RedisConfig contém a conexão com o Redis
+type RedisConfig struct {
+	Addr     string `mapstructure:"addr"` // host:porta
+	Password string `mapstructure:"password"`
+	DB       int    `mapstructure:"db"`
+}
+
+// Require checks the connection is configured for setting, which stores in
+// Redis
+func (r RedisConfig) Require(setting string) error {
+	if r.Addr == "" {
+		return fmt.Errorf("%s redis exige redis.addr", setting)
+	}
+	return nil
+}
+
+// Validate checks the store, keys and rules
+func (r RateLimitConfig) Validate(redis RedisConfig) error {
+	switch r.Store {
+	case "", RateLimitStoreMemory:
+	case RateLimitStoreRedis:
+		if err := redis.Require("rate_limit.store"); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("rate_limit.store inválido: %q (use memory ou redis)", r.Store)
+	}
+	if err := validateRateLimitKey("rate_limit.key", r.Key); err != nil {
+		return err
+	}
+
+	rules := map[string]RateLimitRuleConfig{"rate_limit.auth": r.Auth, "rate_limit.api": r.API}
+	for route, rule := range r.Routes {
+		method, path, ok := strings.Cut(route, " ")
+		if !ok || method == "" || !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("rate_limit.routes: rota inválida %q (use \"MÉTODO /caminho\")", route)
+		}
+		rules["rate_limit.routes."+route] = rule
+	}
+	for name, rule := range rules {
+		if rule.Requests < 0 || rule.Window < 0 || (rule.Requests > 0) != (rule.Window > 0) {
+			return fmt.Errorf("%s: requests e window devem ser ambos positivos", name)
+		}
+		if err := validateRateLimitKey(name+".key", rule.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateRateLimitKey(name, key string) error {
+	switch key {
+	case "", RateLimitKeyIP, RateLimitKeyUser, RateLimitKeyAPIKey:
+		return nil
+	default:
+		return fmt.Errorf("%s inválido: %q (use ip, user ou api_key)", name, key)
+	}
+}
+
+// RouteRules returns Routes keyed by "METHOD /path" with the method upper
+// cased: viper lower cases map keys when loading (every route path of the
+// app is lower case already)
+func (r RateLimitConfig) RouteRules() map[string]RateLimitRuleConfig {
+	rules := make(map[string]RateLimitRuleConfig, len(r.Routes))
+	for route, rule := range r.Routes {
+		method, path, _ := strings.Cut(route, " ")
+		rules[strings.ToUpper(method)+" "+path] = rule
+	}
+	return rules
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// RealtimeConfig contém o WebSocket de notificações em tempo real (GET /ws).
+// Os valores zerados usam os padrões do pacote realtime
+type RealtimeConfig struct {
+	Enabled               bool          `mapstructure:"enabled"`                  // expõe GET /ws
+	PingInterval          time.Duration `mapstructure:"ping_interval"`            // intervalo dos pings de keepalive (padrão 30s)
+	PongTimeout           time.Duration `mapstructure:"pong_timeout"`             // espera além do intervalo antes de derrubar um cliente mudo (padrão 10s)
+	MaxConnectionsPerUser int           `mapstructure:"max_connections_per_user"` // abas e dispositivos conectados ao mesmo tempo (padrão 10)
+}
+
+// Validate checks the durations and the connection limit
+func (r RealtimeConfig) Validate() error {
+	if r.PingInterval < 0 || r.PongTimeout < 0 {
+		return fmt.Errorf("realtime.ping_interval e realtime.pong_timeout não podem ser negativos")
+	}
+	if r.MaxConnectionsPerUser < 0 {
+		return fmt.Errorf("realtime.max_connections_per_user não pode ser negativo")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// RedactedValue replaces secrets in Redacted
+const RedactedValue = "****"
+
+// secretFieldNames are substrings of field names treated as secrets. Matching
+// by name (instead of listing fields) keeps secrets added later redacted too.
+var secretFieldNames = []string{"password", "secret", "token", "apikey", "api_key", "privatekey"}
+
+// keyValuePassword matches password=... in libpq key/value DSNs
+var keyValuePassword = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)`)
+
+// Redacted returns a copy of the config that is safe to log or serialize.
+//
+// String fields whose name looks like a secret (password, secret, token, ...)
+// are replaced by RedactedValue, at any nesting depth. Fields tagged
+// `redact:"dsn"` keep everything but the password of the connection string.
+// Empty values stay empty, so a missing secret is still visible.
+func (c Config) Redacted() Config {
+	redacted := c
+	redactValue(reflect.ValueOf(&redacted).Elem())
+	return redacted
+}
+
+func redactValue(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		info := t.Field(i)
+		if !info.IsExported() {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.Struct:
+			redactValue(field)
+		case reflect.String:
+			if field.String() == "" {
+				continue
+			}
+			if info.Tag.Get("redact") == "dsn" {
+				field.SetString(redactDSN(field.String()))
+			} else if isSecretField(info) {
+				field.SetString(RedactedValue)
+			}
+		case reflect.Slice:
+			// Don't share the backing array with the original config
+			if field.IsNil() {
+				continue
+			}
+			clone := reflect.MakeSlice(field.Type(), field.Len(), field.Len())
+			reflect.Copy(clone, field)
+			switch {
+			case clone.Type().Elem().Kind() == reflect.String && isSecretField(info):
+				for j := 0; j < clone.Len(); j++ {
+					clone.Index(j).SetString(RedactedValue)
+				}
+			case clone.Type().Elem().Kind() == reflect.Struct:
+				for j := 0; j < clone.Len(); j++ {
+					redactValue(clone.Index(j))
+				}
+			}
+			field.Set(clone)
+		}
+	}
+}
+
+func isSecretField(field reflect.StructField) bool {
+	if field.Tag.Get("redact") == "true" {
+		return true
+	}
+	names := []string{strings.ToLower(field.Name), strings.ToLower(field.Tag.Get("mapstructure"))}
+	for _, name := range names {
+		for _, secret := range secretFieldNames {
+			if strings.Contains(name, secret) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// redactDSN hides the password of a URL, key/value or MySQL connection string
+func redactDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			// Can't tell where the password is, hide everything
+			return RedactedValue
+		}
+		// URL.Redacted masks the password as "xxxxx"
+		return strings.Replace(u.Redacted(), ":xxxxx@", ":"+RedactedValue+"@", 1)
+	}
+	if keyValuePassword.MatchString(dsn) {
+		return keyValuePassword.ReplaceAllString(dsn, "${1}"+RedactedValue)
+	}
+	// user:password@tcp(host)/db
+	if cfg, err := mysql.ParseDSN(dsn); err == nil && cfg.Passwd != "" {
+		return strings.Replace(dsn, ":"+cfg.Passwd+"@", ":"+RedactedValue+"@", 1)
+	}
+	return dsn
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Drivers de armazenamento de arquivos
+const (
+	StorageDriverLocal = "local"
+	StorageDriverS3    = "s3"
+)
+
+// MinStorageURLSecretLength is the shortest storage.local.url_secret accepted
+const MinStorageURLSecretLength = 32
+
+// StorageConfig contém onde os arquivos enviados (avatares) são guardados.
+// Os arquivos são privados: o download é feito por links assinados que expiram
+type StorageConfig struct {
+	Driver       string             `mapstructure:"driver"`         // local (disco) ou s3 (AWS S3, MinIO, R2...)
+	SignedURLTTL time.Duration      `mapstructure:"signed_url_ttl"` // validade dos links de download (padrão 1h)
+	Local        LocalStorageConfig `mapstructure:"local"`
+	S3           S3StorageConfig    `mapstructure:"s3"`
+	Avatar       AvatarConfig       `mapstructure:"avatar"`
+}
+
+// LocalStorageConfig contém o armazenamento em disco, servido em GET /files
+type LocalStorageConfig struct {
+	Dir       string `mapstructure:"dir"`        // diretório dos arquivos (padrão uploads)
+	URLSecret string `mapstructure:"url_secret"` // chave dos links, com pelo menos 32 bytes; vazio gera uma nova a cada inicialização, invalidando os links anteriores
+}
+
+// S3StorageConfig contém um bucket S3 ou compatível
+type S3StorageConfig struct {
+	Endpoint        string `mapstructure:"endpoint"` // ex.: https://s3.us-east-1.amazonaws.com ou http://localhost:9000
+	Region          string `mapstructure:"region"`   // padrão us-east-1
+	Bucket          string `mapstructure:"bucket"`
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	PathStyle       bool   `mapstructure:"path_style"` // endpoint/bucket/chave em vez de bucket.endpoint/chave (MinIO)
+}
+
+// AvatarConfig contém os limites do upload de avatar
+type AvatarConfig struct {
+	MaxBytes int64 `mapstructure:"max_bytes"` // tamanho máximo do arquivo enviado (padrão 5 MiB)
+	Size     int   `mapstructure:"size"`      // lado, em pixels, do avatar quadrado gravado (padrão 256)
+}
+
+// Validate checks the driver and the settings it needs
+func (s StorageConfig) Validate() error {
+	switch s.Driver {
+	case StorageDriverLocal:
+		if s.Local.Dir == "" {
+			return fmt.Errorf("storage.local.dir é obrigatório com o driver %s", StorageDriverLocal)
+		}
+		if s.Local.URLSecret != "" && len(s.Local.URLSecret) < MinStorageURLSecretLength {
+			return fmt.Errorf("storage.local.url_secret deve ter pelo menos %d bytes", MinStorageURLSecretLength)
+		}
+	case StorageDriverS3:
+		endpoint, err := url.Parse(s.S3.Endpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" ||
+			strings.Trim(endpoint.Path, "/") != "" {
+			return fmt.Errorf("storage.s3.endpoint inválido: %q (use http:// ou https:// e apenas o host)", s.S3.Endpoint)
+		}
+		if s.S3.Bucket == "" || s.S3.AccessKeyID == "" || s.S3.SecretAccessKey == "" {
+			return fmt.Errorf("storage.s3.bucket, storage.s3.access_key_id e storage.s3.secret_access_key são obrigatórios com o driver %s", StorageDriverS3)
+		}
+	default:
+		return fmt.Errorf("storage.driver inválido: %q (use %s ou %s)", s.Driver, StorageDriverLocal, StorageDriverS3)
+	}
+	if s.SignedURLTTL <= 0 || s.SignedURLTTL > 7*24*time.Hour {
+		return fmt.Errorf("storage.signed_url_ttl deve estar entre 1s e 7 dias")
+	}
+	if s.Avatar.MaxBytes <= 0 {
+		return fmt.Errorf("storage.avatar.max_bytes deve ser positivo")
+	}
+	if s.Avatar.Size < 16 || s.Avatar.Size > 2048 {
+		return fmt.Errorf("storage.avatar.size deve estar entre 16 e 2048")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// TelemetryConfig contém o tracing com OpenTelemetry. Os spans são
+// exportados via OTLP/HTTP para um coletor (ex.: OpenTelemetry Collector,
+// Jaeger, Tempo)
+type TelemetryConfig struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	Endpoint    string  `mapstructure:"endpoint"`     // URL base do coletor OTLP/HTTP, ex.: http://localhost:4318 (os spans vão para /v1/traces)
+	ServiceName string  `mapstructure:"service_name"` // service.name dos spans (padrão gosveltekit)
+	SampleRatio float64 `mapstructure:"sample_ratio"` // fração dos traces iniciados aqui que são exportados, de 0 a 1 (padrão 1)
+	// Cabeçalhos enviados ao coletor, como "authorization=Bearer xyz,x-tenant=a"
+	Headers string `mapstructure:"headers" redact:"true"`
+}
+
+// Validate checks the endpoint and sample ratio when telemetry is enabled
+func (t TelemetryConfig) Validate() error {
+	if !t.Enabled {
+		return nil
+	}
+	u, err := url.Parse(t.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("telemetry.endpoint inválido: %q (use http(s)://host:porta)", t.Endpoint)
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		return fmt.Errorf("telemetry.sample_ratio deve estar entre 0 e 1, recebido %v", t.SampleRatio)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// TLSConfig contém o HTTPS nativo, com certificado em arquivo ou obtido
+// automaticamente do Let's Encrypt (desafio HTTP-01). Sem certificado, o
+// servidor atende em HTTP puro, como atrás de um proxy reverso que termina o TLS
+type TLSConfig struct {
+	CertFile string `mapstructure:"cert_file"` // certificado PEM (com a cadeia intermediária); exige key_file
+	KeyFile  string `mapstructure:"key_file"`  // chave privada PEM do certificado
+
+	AutocertDomains      []string `mapstructure:"autocert_domains"`       // domínios com certificado automático do Let's Encrypt; exige http_port
+	AutocertEmail        string   `mapstructure:"autocert_email"`         // contato para avisos de expiração do Let's Encrypt
+	AutocertCacheDir     string   `mapstructure:"autocert_cache_dir"`     // onde os certificados obtidos ficam guardados entre reinícios (padrão certs)
+	AutocertDirectoryURL string   `mapstructure:"autocert_directory_url"` // servidor ACME (vazio usa o de produção do Let's Encrypt; use o de staging em testes)
+
+	// Porta HTTP que responde ao desafio HTTP-01 e redireciona o resto para
+	// HTTPS (0 desativa). O desafio chega na porta 80 do domínio
+	HTTPPort int `mapstructure:"http_port"`
+}
+
+// Autocert reports whether certificates are obtained automatically
+func (t TLSConfig) Autocert() bool {
+	return len(t.AutocertDomains) > 0
+}
+
+// Enabled reports whether the server serves HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.Autocert()
+}
+
+// Validate checks that the certificate comes from either the files or
+// autocert, and that autocert can answer its challenge
+func (t TLSConfig) Validate(port int) error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("server.tls.cert_file e server.tls.key_file devem ser informados juntos")
+	}
+	if t.CertFile != "" && t.Autocert() {
+		return fmt.Errorf("use server.tls.cert_file ou server.tls.autocert_domains, não os dois")
+	}
+	for _, domain := range t.AutocertDomains {
+		if domain == "" || strings.ContainsAny(domain, "/:* ") {
+			return fmt.Errorf("server.tls.autocert_domains inválido: %q (use só o nome do host, ex: api.example.com)", domain)
+		}
+	}
+	if t.Autocert() && t.HTTPPort == 0 {
+		return fmt.Errorf("server.tls.autocert_domains exige server.tls.http_port, que responde ao desafio HTTP-01")
+	}
+	if t.AutocertDirectoryURL != "" {
+		if u, err := url.Parse(t.AutocertDirectoryURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("server.tls.autocert_directory_url inválido: %q", t.AutocertDirectoryURL)
+		}
+	}
+	if t.HTTPPort != 0 && !t.Enabled() {
+		return fmt.Errorf("server.tls.http_port só redireciona para HTTPS com um certificado configurado")
+	}
+	if t.HTTPPort != 0 && t.HTTPPort == port {
+		return fmt.Errorf("server.tls.http_port deve ser diferente de server.port")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError lists every problem found in a configuration, so they can
+// all be fixed at once instead of one per restart
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return "configuração inválida: " + e.Problems[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "configuração inválida (%d problemas):", len(e.Problems))
+	for _, problem := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(problem.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the problems, for errors.Is and errors.As
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// Validate checks every section of the configuration and normalizes the
+// database settings. It returns a *ValidationError with all the problems
+// found, or nil.
+func (c *Config) Validate() error {
+	var problems []error
+	check := func(err error) {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	check(c.Environment.Validate())
+	check(c.Server.Validate())
+	check(c.Auth.Validate())
+	check(c.Server.CORS.Validate())
+	check(c.Server.TLS.Validate(c.Server.Port))
+	if c.Auth.SessionStore == SessionStoreRedis {
+		check(c.Redis.Require("auth.session_store"))
+	}
+	check(c.Email.Validate())
+	check(c.OAuth.Validate(c.Server))
+	check(c.RateLimit.Validate(c.Redis))
+	check(c.HTTPCache.Validate(c.Redis))
+	check(c.Telemetry.Validate())
+	check(c.Jobs.Validate(c.Redis))
+	check(c.Storage.Validate())
+	check(c.Realtime.Validate())
+	check(c.Database.Normalize())
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
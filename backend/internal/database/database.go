@@ -0,0 +1,149 @@
+// Package database opens the application's GORM connection from config.
+//
+// When a read replica is configured it is registered through GORM's
+// dbresolver under ReplicaResolver. Queries only go to the replica when they
+// opt in with ReadReplica(): auth reads right after a write (login, then
+// validating the new session) must not race replication lag, so everything
+// else stays on the primary.
+//
+// SQLite PRAGMAs from database.sqlite_pragmas are passed to the driver in the
+// DSN, so it applies them to every connection of the pool, not only the first.
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/logger"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ReplicaResolver is the dbresolver name of the read replica
+const ReplicaResolver = "replica"
+
+// ErrUnsupportedDriver is returned for drivers other than sqlite, postgres
+// and mysql
+var ErrUnsupportedDriver = errors.New("driver de banco de dados não suportado")
+
+// Open connects to the primary database and, if configured, the read replica.
+// cfg is expected to be normalized by config.LoadConfig.
+func Open(cfg config.DatabaseConfig, gormConfig *gorm.Config) (*gorm.DB, error) {
+	if gormConfig == nil {
+		gormConfig = &gorm.Config{}
+	}
+
+	warnUnsafePragmas(cfg.SQLitePragmas)
+	dialector, err := dialectorFor(cfg.Driver, cfg.DSN, cfg.SQLitePragmas)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := configurePool(db, cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.ReadReplicaDSN == "" {
+		return db, nil
+	}
+
+	replica, err := dialectorFor(cfg.Driver, cfg.ReadReplicaDSN, cfg.SQLitePragmas)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{replica},
+	}, ReplicaResolver)); err != nil {
+		return nil, fmt.Errorf("falha ao configurar réplica de leitura: %w", err)
+	}
+	return db, nil
+}
+
+// ReadReplica routes a read query to the replica. Without a replica it is a
+// no-op and the query runs on the primary.
+//
+//	db.Clauses(database.ReadReplica()).Find(&users)
+func ReadReplica() clause.Expression {
+	return dbresolver.Use(ReplicaResolver)
+}
+
+func dialectorFor(driver, dsn string, pragmas map[string]string) (gorm.Dialector, error) {
+	switch driver {
+	case "", config.DriverSQLite:
+		return sqlite.Open(withPragmas(dsn, pragmas)), nil
+	case config.DriverPostgres:
+		return postgres.Open(dsn), nil
+	case config.DriverMySQL:
+		return mysql.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDriver, driver)
+	}
+}
+
+// configurePool applies the configured pool limits to the primary; zero
+// values keep the database/sql defaults
+func configurePool(db *gorm.DB, cfg config.DatabaseConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+	return nil
+}
+
+// withPragmas appends the PRAGMAs to a sqlite DSN as the driver's "_name"
+// connection parameters
+func withPragmas(dsn string, pragmas map[string]string) string {
+	if len(pragmas) == 0 {
+		return dsn
+	}
+	names := make([]string, 0, len(pragmas))
+	for name := range pragmas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]string, len(names))
+	for i, name := range names {
+		params[i] = "_" + name + "=" + url.QueryEscape(pragmas[name])
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + strings.Join(params, "&")
+}
+
+// warnUnsafePragmas logs PRAGMA values that trade durability for speed: a
+// crash or power loss may lose recent commits or corrupt the database
+func warnUnsafePragmas(pragmas map[string]string) {
+	if strings.EqualFold(pragmas["synchronous"], "off") || pragmas["synchronous"] == "0" {
+		logger.Warn("PRAGMA synchronous=off: commits recentes podem ser perdidos em caso de queda")
+	}
+	if mode := strings.ToLower(pragmas["journal_mode"]); mode == "off" || mode == "memory" {
+		logger.Warn("PRAGMA journal_mode sem journal em disco: o banco pode corromper em caso de queda", "journal_mode", mode)
+	}
+}
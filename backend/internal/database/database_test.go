@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func usernames(t *testing.T, db *gorm.DB) []string {
+	t.Helper()
+	var names []string
+	require.NoError(t, db.Model(&models.User{}).Order("username").Pluck("username", &names).Error)
+	return names
+}
+
+func TestOpen_ReadReplica(t *testing.T) {
+	dir := t.TempDir()
+	primaryDSN := filepath.Join(dir, "primary.db")
+	replicaDSN := filepath.Join(dir, "replica.db")
+
+	// Give the replica a row the primary doesn't have, to tell them apart
+	replicaDB, err := gorm.Open(sqlite.Open(replicaDSN), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, replicaDB.AutoMigrate(&models.User{}))
+	require.NoError(t, replicaDB.Create(&models.User{Username: "on-replica", Email: "replica@example.com"}).Error)
+
+	db, err := Open(config.DatabaseConfig{Driver: config.DriverSQLite, DSN: primaryDSN, ReadReplicaDSN: replicaDSN}, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}))
+
+	// Writes go to the primary
+	require.NoError(t, db.Create(&models.User{Username: "on-primary", Email: "primary@example.com"}).Error)
+
+	primaryDB, err := gorm.Open(sqlite.Open(primaryDSN), &gorm.Config{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"on-primary"}, usernames(t, primaryDB))
+	assert.Equal(t, []string{"on-replica"}, usernames(t, replicaDB))
+
+	// Hinted reads go to the replica, everything else stays on the primary
+	assert.Equal(t, []string{"on-replica"}, usernames(t, db.Clauses(ReadReplica())))
+	assert.Equal(t, []string{"on-primary"}, usernames(t, db))
+}
+
+func TestOpen_WithoutReplica(t *testing.T) {
+	db, err := Open(config.DatabaseConfig{DSN: ":memory:"}, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}))
+	require.NoError(t, db.Create(&models.User{Username: "alice", Email: "alice@example.com"}).Error)
+
+	// The hint falls back to the primary
+	assert.Equal(t, []string{"alice"}, usernames(t, db.Clauses(ReadReplica())))
+}
+
+func TestOpen_UnsupportedDriver(t *testing.T) {
+	_, err := Open(config.DatabaseConfig{Driver: "oracle", DSN: "oracle://localhost/app"}, nil)
+	assert.ErrorIs(t, err, ErrUnsupportedDriver)
+}
+
+func TestOpen_PoolSettings(t *testing.T) {
+	db, err := Open(config.DatabaseConfig{DSN: ":memory:", MaxOpenConns: 3, ConnMaxIdleTime: time.Minute}, nil)
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	assert.Equal(t, 3, sqlDB.Stats().MaxOpenConnections)
+}
+
+func TestOpen_SQLitePragmas(t *testing.T) {
+	cfg := config.DatabaseConfig{
+		Driver: config.DriverSQLite,
+		DSN:    filepath.Join(t.TempDir(), "app.db"),
+		SQLitePragmas: map[string]string{
+			"journal_mode": "wal",
+			"busy_timeout": "5000",
+			"foreign_keys": "on",
+		},
+	}
+	require.NoError(t, cfg.Normalize())
+	db, err := Open(cfg, nil)
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(2)
+
+	// Every connection of the pool gets the PRAGMAs, not only the first one
+	conns := make([]*sql.Conn, 2)
+	for i := range conns {
+		conn, err := sqlDB.Conn(context.Background())
+		require.NoError(t, err)
+		defer conn.Close()
+		conns[i] = conn
+	}
+	for _, conn := range conns {
+		var journalMode string
+		var busyTimeout, foreignKeys int
+		require.NoError(t, conn.QueryRowContext(context.Background(), "PRAGMA journal_mode").Scan(&journalMode))
+		require.NoError(t, conn.QueryRowContext(context.Background(), "PRAGMA busy_timeout").Scan(&busyTimeout))
+		require.NoError(t, conn.QueryRowContext(context.Background(), "PRAGMA foreign_keys").Scan(&foreignKeys))
+		assert.Equal(t, "wal", journalMode)
+		assert.Equal(t, 5000, busyTimeout)
+		assert.Equal(t, 1, foreignKeys)
+	}
+}
@@ -0,0 +1,58 @@
+package dto
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"gosveltekit/internal/models"
+)
+
+// AuditLogResponse is an audit log entry as shown to admins
+type AuditLogResponse struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	ActorID   string          `json:"actor_id,omitempty"`
+	TargetID  string          `json:"target_id,omitempty"`
+	IP        string          `json:"ip,omitempty"`
+	UserAgent string          `json:"user_agent,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	CreatedAt Timestamp       `json:"created_at"`
+}
+
+// AuditLogCSVHeader is the header row of an audit log CSV export, naming the
+// columns of AuditLogResponse.CSVRecord
+var AuditLogCSVHeader = []string{"id", "created_at", "event", "actor_id", "target_id", "ip", "user_agent", "request_id", "data"}
+
+// CSVRecord returns the entry as a row of an audit log CSV export
+func (r AuditLogResponse) CSVRecord() []string {
+	return []string{
+		r.ID,
+		r.CreatedAt.Time().UTC().Format(TimeFormat),
+		r.Event,
+		r.ActorID,
+		r.TargetID,
+		r.IP,
+		r.UserAgent,
+		r.RequestID,
+		string(r.Data),
+	}
+}
+
+// NewAuditLogResponse builds an AuditLogResponse
+func NewAuditLogResponse(entry *models.AuditLog) AuditLogResponse {
+	response := AuditLogResponse{
+		ID:        strconv.FormatUint(uint64(entry.ID), 10),
+		Event:     entry.Event,
+		ActorID:   entry.ActorID,
+		TargetID:  entry.TargetID,
+		IP:        entry.IP,
+		UserAgent: entry.UserAgent,
+		RequestID: entry.RequestID,
+		CreatedAt: NewTimestamp(entry.CreatedAt),
+	}
+	if entry.Data != "" {
+		response.Data = json.RawMessage(entry.Data)
+	}
+	return response
+}
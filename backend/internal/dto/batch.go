@@ -0,0 +1,44 @@
+package dto
+
+// Statuses of an item in a BatchResult
+const (
+	BatchStatusOK     = "ok"
+	BatchStatusFailed = "failed"
+)
+
+// BatchItemResult is the outcome of one item of a bulk operation. Index is
+// the item's position in the request; ID identifies what it acted on, when
+// known.
+type BatchItemResult struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchResult is the envelope of every bulk endpoint, so clients parse all
+// of them the same way. Build it with NewBatchResult, Succeed and Fail.
+type BatchResult struct {
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BatchItemResult `json:"results"`
+}
+
+// NewBatchResult returns an empty BatchResult, serialized with an empty
+// results array
+func NewBatchResult() *BatchResult {
+	return &BatchResult{Results: []BatchItemResult{}}
+}
+
+// Succeed records that the item at index succeeded
+func (b *BatchResult) Succeed(index int, id string) {
+	b.Succeeded++
+	b.Results = append(b.Results, BatchItemResult{Index: index, ID: id, Status: BatchStatusOK})
+}
+
+// Fail records that the item at index failed with err, whose message is
+// returned to the client
+func (b *BatchResult) Fail(index int, id string, err error) {
+	b.Failed++
+	b.Results = append(b.Results, BatchItemResult{Index: index, ID: id, Status: BatchStatusFailed, Error: err.Error()})
+}
@@ -0,0 +1,101 @@
+// Package dto defines the JSON shapes returned by the API.
+//
+// Formatting rules live here instead of being scattered across struct tags:
+// timestamps are always RFC3339 in UTC and IDs are always strings, so large
+// integer IDs don't lose precision in JavaScript clients.
+package dto
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// TimeFormat is the layout used for every timestamp in API responses
+var TimeFormat = time.RFC3339
+
+// Timestamp is a time.Time serialized as TimeFormat in UTC. The zero time is
+// serialized as null.
+type Timestamp time.Time
+
+// NewTimestamp converts a time.Time into a Timestamp
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp(t)
+}
+
+// Time returns the underlying time.Time
+func (t Timestamp) Time() time.Time {
+	return time.Time(t)
+}
+
+// MarshalJSON implements json.Marshaler
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	tt := time.Time(t)
+	if tt.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(tt.UTC().Format(TimeFormat))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*t = Timestamp{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(TimeFormat, s)
+	if err != nil {
+		return err
+	}
+	*t = Timestamp(parsed)
+	return nil
+}
+
+// ID is a numeric identifier serialized as a JSON string
+type ID uint64
+
+// MarshalJSON implements json.Marshaler
+func (id ID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatUint(uint64(id), 10))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting both strings and numbers
+func (id *ID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		// Fall back to a plain number
+		var n uint64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return err
+		}
+		*id = ID(n)
+		return nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*id = ID(n)
+	return nil
+}
+
+// normalizeAttributes converts time values found in user attributes into
+// Timestamps so they follow the same format as top-level fields
+func normalizeAttributes(attributes map[string]any) map[string]any {
+	if attributes == nil {
+		return nil
+	}
+	normalized := make(map[string]any, len(attributes))
+	for k, v := range attributes {
+		if t, ok := v.(time.Time); ok {
+			normalized[k] = NewTimestamp(t)
+			continue
+		}
+		normalized[k] = v
+	}
+	return normalized
+}
@@ -0,0 +1,164 @@
+// Package dto tests
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestamp_MarshalJSON(t *testing.T) {
+	loc := time.FixedZone("BRT", -3*60*60)
+	ts := NewTimestamp(time.Date(2024, 2, 11, 9, 30, 15, 123456789, loc))
+
+	data, err := json.Marshal(ts)
+	require.NoError(t, err)
+	assert.Equal(t, `"2024-02-11T12:30:15Z"`, string(data))
+
+	data, err = json.Marshal(Timestamp{})
+	require.NoError(t, err)
+	assert.Equal(t, "null", string(data))
+
+	var parsed Timestamp
+	require.NoError(t, json.Unmarshal([]byte(`"2024-02-11T12:30:15Z"`), &parsed))
+	assert.True(t, parsed.Time().Equal(time.Date(2024, 2, 11, 12, 30, 15, 0, time.UTC)))
+}
+
+func TestID_JSON(t *testing.T) {
+	data, err := json.Marshal(ID(9007199254740993)) // beyond JavaScript's safe integer
+	require.NoError(t, err)
+	assert.Equal(t, `"9007199254740993"`, string(data))
+
+	var id ID
+	require.NoError(t, json.Unmarshal([]byte(`"42"`), &id))
+	assert.Equal(t, ID(42), id)
+	require.NoError(t, json.Unmarshal([]byte(`43`), &id))
+	assert.Equal(t, ID(43), id)
+}
+
+func TestNewUserResponse_Serialization(t *testing.T) {
+	user := &models.User{
+		Username:     "testuser",
+		Email:        "test@example.com",
+		DisplayName:  "Test User",
+		PasswordHash: "secret-hash",
+		ResetToken:   "secret-token",
+		Role:         "user",
+		Active:       true,
+	}
+	user.ID = 7
+	user.CreatedAt = time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("X", 3600))
+
+	data, err := json.Marshal(NewUserResponse(user))
+	require.NoError(t, err)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(data, &body))
+	assert.Equal(t, "7", body["id"])
+	assert.Equal(t, "2024-01-02T02:04:05Z", body["created_at"])
+	assert.Nil(t, body["last_login"])
+	assert.NotContains(t, string(data), "secret-hash")
+	assert.NotContains(t, string(data), "secret-token")
+}
+
+func TestNewLoginResponse_Serialization(t *testing.T) {
+	expiresAt := time.Date(2024, 2, 11, 12, 0, 0, 500, time.FixedZone("X", -7200))
+	lastLogin := time.Date(2024, 2, 10, 8, 0, 0, 0, time.UTC)
+	user := &auth.UserData{
+		ID:         "1",
+		Identifier: "admin",
+		Attributes: map[string]any{"last_login": lastLogin, "email_verified": true},
+	}
+
+	data, err := json.Marshal(NewLoginResponse("abc", expiresAt, user))
+	require.NoError(t, err)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(data, &body))
+	assert.Equal(t, "2024-02-11T14:00:00Z", body["expires_at"])
+
+	userBody := body["user"].(map[string]any)
+	assert.Equal(t, "1", userBody["id"])
+	attributes := userBody["attributes"].(map[string]any)
+	assert.Equal(t, "2024-02-10T08:00:00Z", attributes["last_login"])
+	assert.Equal(t, true, attributes["email_verified"])
+}
+
+func TestBatchResult_Serialization(t *testing.T) {
+	data, err := json.Marshal(NewBatchResult())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"succeeded":0,"failed":0,"results":[]}`, string(data))
+
+	batch := NewBatchResult()
+	batch.Succeed(0, "11")
+	batch.Fail(1, "", errors.New("usuário não encontrado"))
+	batch.Succeed(2, "13")
+
+	data, err = json.Marshal(batch)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"succeeded": 2,
+		"failed": 1,
+		"results": [
+			{"index": 0, "id": "11", "status": "ok"},
+			{"index": 1, "status": "failed", "error": "usuário não encontrado"},
+			{"index": 2, "id": "13", "status": "ok"}
+		]
+	}`, string(data))
+}
+
+func TestJSONNamingViolations(t *testing.T) {
+	for _, v := range []any{
+		UserResponse{},
+		AuthUserResponse{},
+		LoginResponse{},
+		TwoFactorChallengeResponse{},
+		TOTPEnrollmentResponse{},
+		RecoveryCodesResponse{},
+		SessionResponse{},
+		AdminSessionResponse{},
+		SessionListResponse{},
+		RoleResponse{},
+		RoleListResponse{},
+		LockoutResponse{},
+		LockoutListResponse{},
+		JobResponse{},
+		JobStatsResponse{},
+		AuditLogResponse{},
+		AccountExportResponse{},
+		ListResponse[UserResponse]{},
+		BatchResult{},
+	} {
+		assert.Empty(t, JSONNamingViolations(v), "%T", v)
+	}
+
+	type nested struct {
+		CamelCase string `json:"camelCase"`
+	}
+	type embedded struct {
+		Promoted string
+	}
+	type bad struct {
+		embedded
+		Good      string    `json:"good_name,omitempty"`
+		Skipped   string    `json:"-"`
+		Timestamp Timestamp `json:"timestamp"`
+		Missing   string
+		OnlyOpts  string   `json:",omitempty"`
+		Items     []nested `json:"items"`
+		internal  string
+	}
+	assert.ElementsMatch(t, []string{
+		"embedded.Promoted: sem tag json",
+		"bad.Missing: sem tag json",
+		"bad.OnlyOpts: sem tag json",
+		`nested.CamelCase: "camelCase" não está em snake_case`,
+	}, JSONNamingViolations(bad{}))
+}
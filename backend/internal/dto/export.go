@@ -0,0 +1,121 @@
+package dto
+
+import (
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+)
+
+// SessionResponse is the public representation of a session. The session ID
+// is a bearer credential, so it is never included: ID is its
+// auth.SessionHandle.
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device,omitempty"`
+	CreatedAt  Timestamp `json:"created_at"`
+	ExpiresAt  Timestamp `json:"expires_at"`
+	LastUsedAt Timestamp `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	Current    bool      `json:"current"`
+}
+
+// NewSessionResponse builds a SessionResponse. current marks the session the
+// request was made with.
+func NewSessionResponse(session *auth.Session, current bool) SessionResponse {
+	return SessionResponse{
+		ID:         auth.SessionHandle(session.ID),
+		Device:     auth.DeviceName(session.UserAgent),
+		CreatedAt:  NewTimestamp(session.CreatedAt),
+		ExpiresAt:  NewTimestamp(session.ExpiresAt),
+		LastUsedAt: NewTimestamp(session.LastUsedAt),
+		UserAgent:  session.UserAgent,
+		IP:         session.IP,
+		Current:    current,
+	}
+}
+
+// AdminSessionResponse is a session as listed to admins: the public
+// representation plus whose session it is
+type AdminSessionResponse struct {
+	SessionResponse
+	UserID         string `json:"user_id"`
+	ImpersonatorID string `json:"impersonator_id,omitempty"`
+}
+
+// NewAdminSessionResponse builds an AdminSessionResponse. current marks the
+// session the request was made with.
+func NewAdminSessionResponse(session *auth.Session, current bool) AdminSessionResponse {
+	return AdminSessionResponse{
+		SessionResponse: NewSessionResponse(session, current),
+		UserID:          session.UserID,
+		ImpersonatorID:  session.ImpersonatorID,
+	}
+}
+
+// SessionListResponse lists the sessions of the current user
+type SessionListResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+}
+
+// NewSessionListResponse builds a SessionListResponse. currentSessionID is
+// used only to flag the current session.
+func NewSessionListResponse(sessions []*auth.Session, currentSessionID string) SessionListResponse {
+	items := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		items[i] = NewSessionResponse(session, session.ID == currentSessionID)
+	}
+	return SessionListResponse{Sessions: items}
+}
+
+// LinkedAccountResponse is a social login linked to the account. The
+// provider's subject identifies the user there, so it is left out.
+type LinkedAccountResponse struct {
+	Provider string    `json:"provider"`
+	Email    string    `json:"email,omitempty"`
+	LinkedAt Timestamp `json:"linked_at"`
+}
+
+// AccountExportResponse is the document returned by the account export
+type AccountExportResponse struct {
+	ExportedAt      Timestamp               `json:"exported_at"`
+	User            UserResponse            `json:"user"`
+	PhoneNumber     string                  `json:"phone_number,omitempty"`
+	PhoneVerified   bool                    `json:"phone_verified"`
+	TwoFactorMethod string                  `json:"two_factor_method,omitempty"`
+	Sessions        []SessionResponse       `json:"sessions"`
+	LinkedAccounts  []LinkedAccountResponse `json:"linked_accounts"`
+	APIKeys         []APIKeyResponse        `json:"api_keys"`
+}
+
+// NewAccountExportResponse builds an AccountExportResponse.
+// currentSessionID is used only to flag the current session.
+func NewAccountExportResponse(user *models.User, sessions []*auth.Session, linked []models.ExternalIdentity, apiKeys []*auth.APIKey, currentSessionID string, exportedAt time.Time) AccountExportResponse {
+	items := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		items[i] = NewSessionResponse(session, session.ID == currentSessionID)
+	}
+	accounts := make([]LinkedAccountResponse, len(linked))
+	for i, identity := range linked {
+		accounts[i] = LinkedAccountResponse{
+			Provider: identity.Provider,
+			Email:    identity.Email,
+			LinkedAt: NewTimestamp(identity.CreatedAt),
+		}
+	}
+	keys := make([]APIKeyResponse, len(apiKeys))
+	for i, key := range apiKeys {
+		keys[i] = NewAPIKeyResponse(key)
+	}
+	return AccountExportResponse{
+		ExportedAt:      NewTimestamp(exportedAt),
+		User:            NewUserResponse(user),
+		PhoneNumber:     user.PhoneNumber,
+		PhoneVerified:   user.PhoneVerified,
+		TwoFactorMethod: user.TwoFactorMethod,
+		Sessions:        items,
+		LinkedAccounts:  accounts,
+		APIKeys:         keys,
+	}
+}
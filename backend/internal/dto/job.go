@@ -0,0 +1,53 @@
+package dto
+
+import (
+	"strconv"
+
+	"gosveltekit/internal/models"
+)
+
+// JobResponse is a background job as shown to admins. Payloads may hold
+// tokens and addresses, so they are never returned.
+type JobResponse struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	RunAt       Timestamp `json:"run_at"`
+	StartedAt   Timestamp `json:"started_at"`
+	FinishedAt  Timestamp `json:"finished_at"`
+	RequestID   string    `json:"request_id,omitempty"`
+	CreatedAt   Timestamp `json:"created_at"`
+	UpdatedAt   Timestamp `json:"updated_at"`
+}
+
+// NewJobResponse builds a JobResponse
+func NewJobResponse(job *models.Job) JobResponse {
+	response := JobResponse{
+		ID:          strconv.FormatUint(uint64(job.ID), 10),
+		Type:        job.Type,
+		Status:      job.Status,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		LastError:   job.LastError,
+		RunAt:       NewTimestamp(job.RunAt),
+		RequestID:   job.RequestID,
+		CreatedAt:   NewTimestamp(job.CreatedAt),
+		UpdatedAt:   NewTimestamp(job.UpdatedAt),
+	}
+	if job.StartedAt != nil {
+		response.StartedAt = NewTimestamp(*job.StartedAt)
+	}
+	if job.FinishedAt != nil {
+		response.FinishedAt = NewTimestamp(*job.FinishedAt)
+	}
+	return response
+}
+
+// JobStatsResponse counts the jobs in each status
+type JobStatsResponse struct {
+	Counts map[string]int64 `json:"counts"`
+	Types  []string         `json:"types"` // job types with a registered handler
+}
@@ -0,0 +1,18 @@
+package dto
+
+import "gosveltekit/internal/pagination"
+
+// ListResponse is the envelope of every list endpoint
+type ListResponse[T any] struct {
+	Data []T             `json:"data"`
+	Meta pagination.Meta `json:"meta"`
+}
+
+// NewListResponse builds a ListResponse. A nil data slice is serialized as an
+// empty array.
+func NewListResponse[T any](data []T, meta pagination.Meta) ListResponse[T] {
+	if data == nil {
+		data = []T{}
+	}
+	return ListResponse[T]{Data: data, Meta: meta}
+}
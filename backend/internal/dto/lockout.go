@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"time"
+
+	"gosveltekit/internal/auth"
+)
+
+// LockoutResponse is an identifier or client IP locked out by failed logins
+type LockoutResponse struct {
+	Kind        string    `json:"kind"`
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LockedAt    time.Time `json:"locked_at"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// NewLockoutResponse builds a LockoutResponse
+func NewLockoutResponse(lockout auth.Lockout) LockoutResponse {
+	return LockoutResponse{
+		Kind:        lockout.Kind,
+		Key:         lockout.Key,
+		Failures:    lockout.Failures,
+		LockedAt:    lockout.LockedAt,
+		LockedUntil: lockout.LockedUntil,
+	}
+}
+
+// LockoutListResponse lists the current lockouts, most recent first
+type LockoutListResponse struct {
+	Lockouts []LockoutResponse `json:"lockouts"`
+}
@@ -0,0 +1,69 @@
+package dto
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// snakeCase is the naming policy for every JSON field of the API
+var snakeCase = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// JSONNamingViolations checks the struct types of API requests and responses
+// against the naming policy: every exported field needs a json tag with a
+// snake_case name (or "-"). Nested structs, pointers, slices and maps are
+// checked too, as are untagged embedded structs, whose fields are promoted.
+// Types with their own MarshalJSON or MarshalText are skipped.
+//
+// It returns one message per offending field, empty when v complies. Tests
+// call it on the types they own to keep new fields from drifting.
+func JSONNamingViolations(v any) []string {
+	var violations []string
+	checkJSONNaming(reflect.TypeOf(v), make(map[reflect.Type]bool), &violations)
+	return violations
+}
+
+func checkJSONNaming(t reflect.Type, seen map[reflect.Type]bool, violations *[]string) {
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || seen[t] || customMarshaler(t) {
+		return
+	}
+	seen[t] = true
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		// encoding/json promotes the fields of unexported embedded structs too
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		tag, hasTag := field.Tag.Lookup("json")
+		name, _, _ := strings.Cut(tag, ",")
+		switch {
+		case name == "-" && tag == "-":
+			continue
+		case field.Anonymous && name == "":
+			// Promoted fields are checked as if declared here
+		case !hasTag || name == "":
+			*violations = append(*violations, fmt.Sprintf("%s.%s: sem tag json", t.Name(), field.Name))
+		case !snakeCase.MatchString(name):
+			*violations = append(*violations, fmt.Sprintf("%s.%s: %q não está em snake_case", t.Name(), field.Name, name))
+		}
+		checkJSONNaming(field.Type, seen, violations)
+	}
+}
+
+func customMarshaler(t reflect.Type) bool {
+	ptr := reflect.PointerTo(t)
+	return t.Implements(jsonMarshalerType) || ptr.Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || ptr.Implements(textMarshalerType)
+}
@@ -0,0 +1,28 @@
+package dto
+
+import "gosveltekit/internal/auth"
+
+// RoleResponse is a role users can be given and the permissions it grants
+type RoleResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// NewRoleResponse builds a RoleResponse
+func NewRoleResponse(role auth.Role) RoleResponse {
+	permissions := role.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+	return RoleResponse{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: permissions,
+	}
+}
+
+// RoleListResponse lists the roles of the permission policy
+type RoleListResponse struct {
+	Roles []RoleResponse `json:"roles"`
+}
@@ -0,0 +1,35 @@
+package dto
+
+import "time"
+
+// TwoFactorChallengeResponse is returned by login instead of LoginResponse
+// when the user must also enter a code of their authenticator app, or the
+// one sent by SMS (method "sms")
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         Timestamp `json:"expires_at"`
+	Method            string    `json:"method"`
+}
+
+// NewTwoFactorChallengeResponse builds a TwoFactorChallengeResponse
+func NewTwoFactorChallengeResponse(token string, expiresAt time.Time, method string) TwoFactorChallengeResponse {
+	return TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         NewTimestamp(expiresAt),
+		Method:            method,
+	}
+}
+
+// TOTPEnrollmentResponse carries a new authenticator app secret. Clients
+// render ProvisioningURI as a QR code and show Secret for manual entry.
+type TOTPEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// RecoveryCodesResponse carries plaintext recovery codes, shown only once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,119 @@
+package dto
+
+import (
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+)
+
+// UserResponse is the public representation of a models.User
+type UserResponse struct {
+	ID            string    `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	DisplayName   string    `json:"display_name"`
+	FirstName     string    `json:"first_name,omitempty"`
+	LastName      string    `json:"last_name,omitempty"`
+	Active        bool      `json:"active"`
+	EmailVerified bool      `json:"email_verified"`
+	Role          string    `json:"role"`
+	Locale        string    `json:"locale,omitempty"`
+	LastLogin     Timestamp `json:"last_login"`
+	CreatedAt     Timestamp `json:"created_at"`
+	UpdatedAt     Timestamp `json:"updated_at"`
+
+	// EmailVerifiedAt is when the email was verified, null if it wasn't or
+	// was verified before it was recorded
+	EmailVerifiedAt Timestamp `json:"email_verified_at"`
+
+	// ScheduledDeletionAt is when the account will be purged, null unless the
+	// user asked to delete it
+	ScheduledDeletionAt Timestamp `json:"scheduled_deletion_at"`
+}
+
+// NewUserResponse builds a UserResponse, leaving out sensitive fields
+// (password hash, reset token)
+func NewUserResponse(user *models.User) UserResponse {
+	response := UserResponse{
+		ID:            user.PublicID(),
+		Username:      user.Username,
+		Email:         user.Email,
+		DisplayName:   user.DisplayName,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Active:        user.Active,
+		EmailVerified: user.EmailVerified,
+		Role:          user.Role,
+		Locale:        user.Locale,
+		LastLogin:     NewTimestamp(user.LastLogin),
+		CreatedAt:     NewTimestamp(user.CreatedAt),
+		UpdatedAt:     NewTimestamp(user.UpdatedAt),
+	}
+	if user.EmailVerifiedAt != nil {
+		response.EmailVerifiedAt = NewTimestamp(*user.EmailVerifiedAt)
+	}
+	if user.ScheduledDeletionAt != nil {
+		response.ScheduledDeletionAt = NewTimestamp(*user.ScheduledDeletionAt)
+	}
+	return response
+}
+
+// AuthUserResponse is the representation of the authenticated user (auth.UserData)
+type AuthUserResponse struct {
+	ID          string         `json:"id"`
+	Identifier  string         `json:"identifier"`
+	DisplayName string         `json:"display_name"`
+	Email       string         `json:"email"`
+	Role        string         `json:"role"`
+	Active      bool           `json:"active"`
+	Attributes  map[string]any `json:"attributes,omitempty"`
+
+	// AvatarURL is a signed link to the avatar, which expires after
+	// storage.signed_url_ttl; empty without an avatar
+	AvatarURL string `json:"avatar_url,omitempty"`
+}
+
+// NewAuthUserResponse builds an AuthUserResponse from auth.UserData
+func NewAuthUserResponse(user *auth.UserData) AuthUserResponse {
+	return AuthUserResponse{
+		ID:          user.ID,
+		Identifier:  user.Identifier,
+		DisplayName: user.DisplayName,
+		Email:       user.Email,
+		Role:        user.Role,
+		Active:      user.Active,
+		Attributes:  normalizeAttributes(user.Attributes),
+	}
+}
+
+// LoginResponse is returned after a successful login
+type LoginResponse struct {
+	// SessionID is left out with the cookie token transport, where the
+	// session is only in the HttpOnly cookie
+	SessionID string           `json:"session_id,omitempty"`
+	ExpiresAt Timestamp        `json:"expires_at"`
+	User      AuthUserResponse `json:"user"`
+
+	// ImpersonatorID is the admin acting as User, for impersonation sessions
+	ImpersonatorID string `json:"impersonator_id,omitempty"`
+
+	// PasswordChangeRequired means the session can only be used to change the
+	// password (POST /api/me/password) until the user does so
+	PasswordChangeRequired bool `json:"password_change_required,omitempty"`
+
+	// RefreshToken renews the session at POST /auth/refresh, when refresh
+	// tokens are enabled (in a cookie with the cookie token transport)
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// NewLoginResponse builds a LoginResponse
+func NewLoginResponse(sessionID string, expiresAt time.Time, user *auth.UserData) LoginResponse {
+	return LoginResponse{
+		SessionID: sessionID,
+		ExpiresAt: NewTimestamp(expiresAt),
+		User:      NewAuthUserResponse(user),
+
+		PasswordChangeRequired: user.MustChangePassword(),
+	}
+}
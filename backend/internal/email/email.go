@@ -5,128 +5,219 @@
 // Este pacote implementa um serviço de email para enviar mensagens transacionais como
 // recuperação de senha, confirmação de cadastro, etc.
 //
-// O serviço usa a biblioteca net/smtp padrão do Go e suporta autenticação SMTP.
+// As mensagens são geradas a partir de templates embutidos (veja templates.go), no
+// idioma do destinatário, e enviadas como multipart/alternative com versões em texto
+// e HTML. O serviço usa a biblioteca net/smtp padrão do Go e suporta autenticação SMTP.
 
 package email
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"gosveltekit/internal/config"
 	"gosveltekit/internal/logger"
-	"html/template"
+	"gosveltekit/internal/metrics"
+	"gosveltekit/internal/telemetry"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
 	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// appName é o nome da aplicação usado nos emails
+const appName = "GoSvelteKit"
+
+// Tipos de email, usados nas métricas de envio
+const (
+	KindPasswordReset        = "password_reset"
+	KindWelcome              = "welcome"
+	KindNewDevice            = "new_device"
+	KindAccountDeletion      = "account_deletion"
+	KindVerificationReminder = "verification_reminder"
+	KindEmailVerification    = "email_verification"
+	KindAccountLocked        = "account_locked"
+	KindMagicLink            = "magic_link"
 )
 
 // EmailServiceInterface defines the interface for email services
 type EmailServiceInterface interface {
-	SendPasswordResetEmail(to, token, username, displayName string) error
+	SendPasswordResetEmail(ctx context.Context, to, token, username, displayName string) error
+	SendWelcomeEmail(ctx context.Context, to, username, displayName string) error
+	SendNewDeviceEmail(ctx context.Context, to, username, displayName, userAgent, ip string) error
+	SendAccountDeletionEmail(ctx context.Context, to, token, username, displayName string, deleteAt time.Time) error
+	SendVerificationReminderEmail(ctx context.Context, to, username, displayName string) error
+	SendVerificationEmail(ctx context.Context, to, token, username, displayName string) error
+	SendAccountLockedEmail(ctx context.Context, to, username, displayName string, lockedUntil time.Time) error
+	SendMagicLinkEmail(ctx context.Context, to, token, username, displayName string) error
 }
 
 // EmailService é o serviço responsável pelo envio de emails
 type EmailService struct {
-	config *config.EmailConfig
+	config       *config.EmailConfig
+	server       *config.ServerConfig
+	templates    *templateSet
+	templatesErr error
 }
 
-// NewEmailService cria uma nova instância do serviço de email
+// NewEmailService cria uma nova instância do serviço de email, carregando os
+// templates embutidos e os de email.templates_dir. Um erro ao carregá-los é
+// retornado por CheckTemplates e por todo envio.
 func NewEmailService(cfg *config.Config) *EmailService {
+	templates, err := loadTemplates(cfg.Email.TemplatesDir, cfg.Email.DefaultLocale)
 	return &EmailService{
-		config: &cfg.Email,
+		config:       &cfg.Email,
+		server:       &cfg.Server,
+		templates:    templates,
+		templatesErr: err,
 	}
 }
 
-// EmailData contém dados dinâmicos para templates de email
+// CheckTemplates retorna o erro de carregamento dos templates, para que um
+// email.templates_dir inválido seja detectado na inicialização
+func (s *EmailService) CheckTemplates() error {
+	return s.templatesErr
+}
+
+// EmailData contém dados dinâmicos para templates de email. Datas são
+// formatadas nos templates com {{date .Campo "layout"}}.
 type EmailData struct {
+	Locale       string // idioma dos templates usados
 	Username     string
 	ResetLink    string
 	DisplayName  string
 	AppName      string
 	SupportEmail string
+	UserAgent    string
+	IP           string
+	RestoreLink  string
+	DeletionDate time.Time
+	VerifyLink   string
+	LockedUntil  time.Time
+	LoginLink    string
+}
+
+// SendPasswordResetEmail envia um email de recuperação de senha com um link contendo o token.
+// Os logs incluem o ID da requisição de origem presente em ctx.
+func (s *EmailService) SendPasswordResetEmail(ctx context.Context, to, token, username, displayName string) error {
+	return s.send(ctx, KindPasswordReset, to, EmailData{
+		Username:    username,
+		DisplayName: displayName,
+		ResetLink:   s.server.AbsoluteURL(s.config.ResetURL + token),
+	})
+}
+
+// SendWelcomeEmail envia o email de boas-vindas a um usuário recém-cadastrado
+func (s *EmailService) SendWelcomeEmail(ctx context.Context, to, username, displayName string) error {
+	return s.send(ctx, KindWelcome, to, EmailData{
+		Username:    username,
+		DisplayName: displayName,
+	})
 }
 
-// SendPasswordResetEmail envia um email de recuperação de senha com um link contendo o token
-func (s *EmailService) SendPasswordResetEmail(to, token, username, displayName string) error {
-	subject := "Recuperação de Senha"
-	resetLink := s.config.ResetURL + token
+// SendNewDeviceEmail avisa o usuário de um login feito a partir de um dispositivo desconhecido
+func (s *EmailService) SendNewDeviceEmail(ctx context.Context, to, username, displayName, userAgent, ip string) error {
+	return s.send(ctx, KindNewDevice, to, EmailData{
+		Username:    username,
+		DisplayName: displayName,
+		UserAgent:   userAgent,
+		IP:          ip,
+	})
+}
 
-	// Dados para o template de email
-	data := EmailData{
+// SendAccountDeletionEmail avisa que a exclusão da conta foi agendada para deleteAt,
+// com um link para restaurá-la até lá
+func (s *EmailService) SendAccountDeletionEmail(ctx context.Context, to, token, username, displayName string, deleteAt time.Time) error {
+	return s.send(ctx, KindAccountDeletion, to, EmailData{
 		Username:     username,
-		ResetLink:    resetLink,
 		DisplayName:  displayName,
-		AppName:      "GoSvelteKit",
-		SupportEmail: s.config.FromEmail,
+		RestoreLink:  s.server.AbsoluteURL(s.config.RestoreURL + token),
+		DeletionDate: deleteAt,
+	})
+}
+
+// SendVerificationReminderEmail lembra o usuário de verificar o email da conta
+func (s *EmailService) SendVerificationReminderEmail(ctx context.Context, to, username, displayName string) error {
+	return s.send(ctx, KindVerificationReminder, to, EmailData{
+		Username:    username,
+		DisplayName: displayName,
+		VerifyLink:  s.server.AbsoluteURL(s.config.VerifyURL),
+	})
+}
+
+// SendVerificationEmail envia o link de verificação do email, com o token
+// adicionado à verify_url como parâmetro token
+func (s *EmailService) SendVerificationEmail(ctx context.Context, to, token, username, displayName string) error {
+	separator := "?"
+	if strings.Contains(s.config.VerifyURL, "?") {
+		separator = "&"
 	}
+	return s.send(ctx, KindEmailVerification, to, EmailData{
+		Username:    username,
+		DisplayName: displayName,
+		VerifyLink:  s.server.AbsoluteURL(s.config.VerifyURL + separator + "token=" + url.QueryEscape(token)),
+	})
+}
 
-	// HTML para o corpo do email
-	htmlBody := `
-	<!DOCTYPE html>
-	<html>
-	<head>
-		<meta charset="UTF-8">
-		<title>Recuperação de Senha</title>
-		<style>
-			body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; margin: 0; padding: 0; background-color: #f9f9f9; color: #333; }
-			.container { max-width: 600px; margin: 0 auto; padding: 20px; }
-			.header { background-color: #1e293b; color: white; padding: 20px; text-align: center; border-radius: 5px 5px 0 0; }
-			.content { background-color: white; padding: 20px; border-radius: 0 0 5px 5px; box-shadow: 0 2px 5px rgba(0,0,0,0.1); }
-			.button { display: inline-block; background-color: #1e293b; color: white; text-decoration: none; padding: 10px 20px; border-radius: 5px; margin: 20px 0; }
-			.footer { margin-top: 20px; text-align: center; font-size: 12px; color: #666; }
-		</style>
-	</head>
-	<body>
-		<div class="container">
-			<div class="header">
-				<h1>Recuperação de Senha</h1>
-			</div>
-			<div class="content">
-				<p>Olá {{.DisplayName}},</p>
-				<p>Recebemos uma solicitação para redefinir a senha da sua conta.</p>
-				<p>Se você não solicitou uma nova senha, ignore este email.</p>
-				<p>Para redefinir sua senha, clique no botão abaixo:</p>
-				<p style="text-align: center;">
-					<a href="{{.ResetLink}}" class="button">Redefinir Senha</a>
-				</p>
-				<p>Ou copie e cole o seguinte link no seu navegador:</p>
-				<p>{{.ResetLink}}</p>
-				<p>Este link expirará em 1 hora por motivos de segurança.</p>
-				<p>Atenciosamente,<br>Equipe {{.AppName}}</p>
-			</div>
-			<div class="footer">
-				<p>Este é um email automático, por favor não responda.<br>
-				Em caso de dúvidas, entre em contato com {{.SupportEmail}}</p>
-			</div>
-		</div>
-	</body>
-	</html>
-	`
-
-	// Criamos um template a partir do HTML
-	t, err := template.New("reset_email").Parse(htmlBody)
-	if err != nil {
-		logger.Error("Erro ao analisar template de email", "error", err, "email", to)
-		return fmt.Errorf("erro ao analisar template: %w", err)
+// SendAccountLockedEmail avisa o usuário que a conta foi bloqueada até lockedUntil
+// por excesso de tentativas de login com senha errada
+func (s *EmailService) SendAccountLockedEmail(ctx context.Context, to, username, displayName string, lockedUntil time.Time) error {
+	return s.send(ctx, KindAccountLocked, to, EmailData{
+		Username:    username,
+		DisplayName: displayName,
+		LockedUntil: lockedUntil,
+	})
+}
+
+// SendMagicLinkEmail envia um link de acesso sem senha, com o token
+// adicionado à magic_link_url como parâmetro token
+func (s *EmailService) SendMagicLinkEmail(ctx context.Context, to, token, username, displayName string) error {
+	separator := "?"
+	if strings.Contains(s.config.MagicLinkURL, "?") {
+		separator = "&"
 	}
+	return s.send(ctx, KindMagicLink, to, EmailData{
+		Username:    username,
+		DisplayName: displayName,
+		LoginLink:   s.server.AbsoluteURL(s.config.MagicLinkURL + separator + "token=" + url.QueryEscape(token)),
+	})
+}
 
-	// Aplicamos os dados ao template
-	var body bytes.Buffer
-	if err := t.Execute(&body, data); err != nil {
-		logger.Error("Erro ao executar template de email", "error", err, "email", to)
+// send gera o email do tipo kind no idioma de ctx (veja WithLocale) e o envia
+func (s *EmailService) send(ctx context.Context, kind, to string, data EmailData) error {
+	log := logger.FromContext(ctx)
+	if s.templatesErr != nil {
+		log.Error("Templates de email não carregados", "error", s.templatesErr, "email", to, "kind", kind)
+		return fmt.Errorf("erro ao carregar templates: %w", s.templatesErr)
+	}
+
+	data.AppName = appName
+	data.SupportEmail = s.config.FromEmail
+	subject, htmlBody, textBody, err := s.templates.render(ctx, kind, data)
+	if err != nil {
+		log.Error("Erro ao executar template de email", "error", err, "email", to, "kind", kind)
 		return fmt.Errorf("erro ao executar template: %w", err)
 	}
 
-	// Enviamos o email usando a função auxiliar
-	if err := s.sendEmail(to, subject, body.String()); err != nil {
-		logger.Error("Erro ao enviar email via SMTP", "error", err, "email", to, "smtp_host", s.config.SMTPHost)
+	if err := s.sendEmail(ctx, kind, to, subject, htmlBody, textBody); err != nil {
 		return err
 	}
 
-	logger.Debug("Email de recuperação de senha enviado com sucesso", "email", to)
+	log.Debug("Email enviado com sucesso", "email", to, "kind", kind)
 	return nil
 }
 
-// sendEmail é uma função auxiliar que envia um email usando SMTP
-func (s *EmailService) sendEmail(to, subject, htmlBody string) error {
+// sendEmail é uma função auxiliar que envia um email usando SMTP, contando o
+// resultado por tipo de email (kind) nas métricas
+func (s *EmailService) sendEmail(ctx context.Context, kind, to, subject, htmlBody, textBody string) error {
 	// Configurações de SMTP
 	host := s.config.SMTPHost
 	port := s.config.SMTPPort
@@ -135,21 +226,22 @@ func (s *EmailService) sendEmail(to, subject, htmlBody string) error {
 	fromEmail := s.config.FromEmail
 	fromName := s.config.FromName
 
-	// Construir o cabeçalho do email
-	headers := make(map[string]string)
-	headers["From"] = fmt.Sprintf("%s <%s>", fromName, fromEmail)
-	headers["To"] = to
-	headers["Subject"] = subject
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = "text/html; charset=UTF-8"
+	ctx, span := telemetry.Tracer().Start(ctx, "email "+kind,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("email.kind", kind),
+			attribute.String("server.address", host),
+		),
+	)
+	defer span.End()
 
-	// Construir a mensagem com cabeçalhos e corpo
-	var message bytes.Buffer
-	for k, v := range headers {
-		message.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
+	from := (&mail.Address{Name: fromName, Address: fromEmail}).String()
+	message, err := buildMessage(from, to, subject, htmlBody, textBody, time.Now())
+	if err != nil {
+		telemetry.RecordError(span, err)
+		metrics.EmailsSent.WithLabelValues(kind, metrics.EmailResultFailed).Inc()
+		return err
 	}
-	message.WriteString("\r\n")
-	message.WriteString(htmlBody)
 
 	// Autenticação SMTP
 	auth := smtp.PlainAuth("", username, password, host)
@@ -163,10 +255,56 @@ func (s *EmailService) sendEmail(to, subject, htmlBody string) error {
 		auth,
 		fromEmail,
 		[]string{to},
-		message.Bytes(),
+		message,
 	); err != nil {
-		logger.Error("Erro ao enviar email via SMTP", "error", err, "to", to, "addr", addr)
+		logger.FromContext(ctx).Error("Erro ao enviar email via SMTP", "error", err, "to", to, "addr", addr)
+		telemetry.RecordError(span, err)
+		metrics.EmailsSent.WithLabelValues(kind, metrics.EmailResultFailed).Inc()
 		return err
 	}
+	metrics.EmailsSent.WithLabelValues(kind, metrics.EmailResultSent).Inc()
 	return nil
 }
+
+// buildMessage monta a mensagem multipart/alternative com a versão em texto e
+// a versão em HTML, nessa ordem, codificadas em quoted-printable. O assunto é
+// codificado conforme a RFC 2047 quando tem caracteres não ASCII.
+func buildMessage(from, to, subject, htmlBody, textBody string, date time.Time) ([]byte, error) {
+	var message bytes.Buffer
+	writer := multipart.NewWriter(&message)
+
+	headers := []string{
+		"From: " + from,
+		"To: " + to,
+		"Subject: " + mime.QEncoding.Encode("UTF-8", subject),
+		"Date: " + date.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + writer.Boundary(),
+	}
+	message.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	parts := []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", textBody},
+		{"text/html; charset=UTF-8", htmlBody},
+	}
+	for _, part := range parts {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return message.Bytes(), nil
+}
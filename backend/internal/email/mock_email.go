@@ -3,7 +3,11 @@
 package email
 
 import (
+	"context"
 	"sync"
+	"time"
+
+	"gosveltekit/internal/logger"
 )
 
 // MockEmailService is a mock implementation of the email service for testing
@@ -15,10 +19,17 @@ type MockEmailService struct {
 
 // MockEmail represents a sent email for testing
 type MockEmail struct {
+	Kind        string // "password_reset", "welcome", "new_device", "account_deletion", "verification_reminder", "email_verification", "account_locked" or "magic_link"
 	To          string
 	Token       string
 	Username    string
 	DisplayName string
+	UserAgent   string
+	IP          string
+	DeleteAt    time.Time
+	LockedUntil time.Time
+	RequestID   string // request ID carried by the context, if any
+	Locale      string // locale carried by the context, if any (see WithLocale)
 }
 
 // NewMockEmailService creates a new mock email service
@@ -29,21 +40,150 @@ func NewMockEmailService() *MockEmailService {
 }
 
 // SendPasswordResetEmail records the email that would be sent
-func (m *MockEmailService) SendPasswordResetEmail(to, token, username, displayName string) error {
+func (m *MockEmailService) SendPasswordResetEmail(ctx context.Context, to, token, username, displayName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sentEmails = append(m.sentEmails, MockEmail{
+		Kind:        "password_reset",
+		To:          to,
+		Token:       token,
+		Username:    username,
+		DisplayName: displayName,
+		RequestID:   logger.RequestIDFromContext(ctx),
+		Locale:      LocaleFromContext(ctx),
+	})
+
+	return m.sendEmailError
+}
+
+// SendWelcomeEmail records the welcome email that would be sent
+func (m *MockEmailService) SendWelcomeEmail(ctx context.Context, to, username, displayName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sentEmails = append(m.sentEmails, MockEmail{
+		Kind:        "welcome",
+		To:          to,
+		Username:    username,
+		DisplayName: displayName,
+		RequestID:   logger.RequestIDFromContext(ctx),
+		Locale:      LocaleFromContext(ctx),
+	})
+
+	return m.sendEmailError
+}
+
+// SendNewDeviceEmail records the new device notification that would be sent
+func (m *MockEmailService) SendNewDeviceEmail(ctx context.Context, to, username, displayName, userAgent, ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sentEmails = append(m.sentEmails, MockEmail{
+		Kind:        "new_device",
+		To:          to,
+		Username:    username,
+		DisplayName: displayName,
+		UserAgent:   userAgent,
+		IP:          ip,
+		RequestID:   logger.RequestIDFromContext(ctx),
+		Locale:      LocaleFromContext(ctx),
+	})
+
+	return m.sendEmailError
+}
+
+// SendAccountDeletionEmail records the deletion confirmation that would be sent
+func (m *MockEmailService) SendAccountDeletionEmail(ctx context.Context, to, token, username, displayName string, deleteAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sentEmails = append(m.sentEmails, MockEmail{
+		Kind:        "account_deletion",
+		To:          to,
+		Token:       token,
+		Username:    username,
+		DisplayName: displayName,
+		DeleteAt:    deleteAt,
+		RequestID:   logger.RequestIDFromContext(ctx),
+		Locale:      LocaleFromContext(ctx),
+	})
+
+	return m.sendEmailError
+}
+
+// SendVerificationReminderEmail records the verification reminder that would be sent
+func (m *MockEmailService) SendVerificationReminderEmail(ctx context.Context, to, username, displayName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sentEmails = append(m.sentEmails, MockEmail{
+		Kind:        "verification_reminder",
+		To:          to,
+		Username:    username,
+		DisplayName: displayName,
+		RequestID:   logger.RequestIDFromContext(ctx),
+		Locale:      LocaleFromContext(ctx),
+	})
+
+	return m.sendEmailError
+}
+
+// SendVerificationEmail records the verification link that would be sent
+func (m *MockEmailService) SendVerificationEmail(ctx context.Context, to, token, username, displayName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sentEmails = append(m.sentEmails, MockEmail{
+		Kind:        "email_verification",
+		To:          to,
+		Token:       token,
+		Username:    username,
+		DisplayName: displayName,
+		RequestID:   logger.RequestIDFromContext(ctx),
+		Locale:      LocaleFromContext(ctx),
+	})
+
+	return m.sendEmailError
+}
+
+// SendAccountLockedEmail records the lockout notification that would be sent
+func (m *MockEmailService) SendAccountLockedEmail(ctx context.Context, to, username, displayName string, lockedUntil time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sentEmails = append(m.sentEmails, MockEmail{
+		Kind:        "account_locked",
+		To:          to,
+		Username:    username,
+		DisplayName: displayName,
+		LockedUntil: lockedUntil,
+		RequestID:   logger.RequestIDFromContext(ctx),
+		Locale:      LocaleFromContext(ctx),
+	})
+
+	return m.sendEmailError
+}
+
+// SendMagicLinkEmail records the passwordless login link that would be sent
+func (m *MockEmailService) SendMagicLinkEmail(ctx context.Context, to, token, username, displayName string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.sentEmails = append(m.sentEmails, MockEmail{
+		Kind:        "magic_link",
 		To:          to,
 		Token:       token,
 		Username:    username,
 		DisplayName: displayName,
+		RequestID:   logger.RequestIDFromContext(ctx),
+		Locale:      LocaleFromContext(ctx),
 	})
 
 	return m.sendEmailError
 }
 
-// SetSendEmailError sets an error to be returned by SendPasswordResetEmail
+// SetSendEmailError sets an error to be returned by the Send methods
 func (m *MockEmailService) SetSendEmailError(err error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -0,0 +1,284 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Templates embutidos: layout.html e layout.txt envolvem todos os emails, e
+// cada diretório de idioma tem common.tmpl (rodapé) e um <tipo>.tmpl por tipo
+// de email, definindo os blocos subject, title, content (HTML) e text
+//
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// DefaultLocale is the locale used when email.default_locale is empty
+const DefaultLocale = "pt-BR"
+
+const (
+	htmlLayout     = "layout.html"
+	textLayout     = "layout.txt"
+	commonTemplate = "common.tmpl"
+)
+
+// requiredBlocks are the blocks every email template must define
+var requiredBlocks = []string{"subject", "title", "content", "text"}
+
+// templateFuncs are available to every template
+var templateFuncs = map[string]any{
+	// date formats a time in UTC, e.g. {{date .LockedUntil "02/01/2006 15:04 MST"}}
+	"date": func(t time.Time, layout string) string {
+		return t.UTC().Format(layout)
+	},
+}
+
+type localeKey struct{}
+
+// WithLocale returns a copy of ctx whose emails are rendered in locale, a
+// tag such as "pt-BR" or "en". Without templates for it, the same language
+// in another region is used, then the default locale.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	if locale == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFromContext returns the locale set with WithLocale, or ""
+func LocaleFromContext(ctx context.Context) string {
+	locale, _ := ctx.Value(localeKey{}).(string)
+	return locale
+}
+
+// emailTemplate is one kind of email in one locale
+type emailTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// templateSet holds the parsed templates by locale and kind
+type templateSet struct {
+	defaultLocale string
+	locales       []string
+	templates     map[string]map[string]*emailTemplate
+}
+
+// templateSource reads template files from dir, when set, before the embedded ones
+type templateSource struct {
+	dir      fs.FS
+	embedded fs.FS
+}
+
+func (s templateSource) readFile(name string) ([]byte, error) {
+	if s.dir != nil {
+		data, err := fs.ReadFile(s.dir, name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return data, err
+		}
+	}
+	return fs.ReadFile(s.embedded, name)
+}
+
+// names lists the directories (or the files, without dirs) in name across
+// both sources, sorted and without duplicates
+func (s templateSource) names(name string, dirs bool) ([]string, error) {
+	seen := map[string]bool{}
+	for _, fsys := range []fs.FS{s.dir, s.embedded} {
+		if fsys == nil {
+			continue
+		}
+		entries, err := fs.ReadDir(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() == dirs {
+				seen[entry.Name()] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// loadTemplates parses the embedded templates, each replaced by the file with
+// the same path in dir, if any. dir may also add locales and kinds; a locale
+// without common.tmpl uses the default locale's.
+func loadTemplates(dir, defaultLocale string) (*templateSet, error) {
+	if defaultLocale == "" {
+		defaultLocale = DefaultLocale
+	}
+	embedded, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	source := templateSource{embedded: embedded}
+	if dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("email.templates_dir %q não é um diretório", dir)
+		}
+		source.dir = os.DirFS(dir)
+	}
+
+	layoutHTML, err := source.readFile(htmlLayout)
+	if err != nil {
+		return nil, err
+	}
+	layoutText, err := source.readFile(textLayout)
+	if err != nil {
+		return nil, err
+	}
+	locales, err := source.names(".", true)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &templateSet{locales: locales, templates: make(map[string]map[string]*emailTemplate, len(locales))}
+	for _, locale := range locales {
+		if strings.EqualFold(locale, defaultLocale) {
+			set.defaultLocale = locale
+		}
+	}
+	if set.defaultLocale == "" {
+		return nil, fmt.Errorf("email.default_locale %q sem templates (disponíveis: %s)", defaultLocale, strings.Join(locales, ", "))
+	}
+	defaultCommon, err := source.readFile(path.Join(set.defaultLocale, commonTemplate))
+	if err != nil {
+		return nil, fmt.Errorf("templates de email do idioma padrão: %w", err)
+	}
+
+	for _, locale := range locales {
+		common, err := source.readFile(path.Join(locale, commonTemplate))
+		if errors.Is(err, fs.ErrNotExist) {
+			common, err = defaultCommon, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		files, err := source.names(locale, false)
+		if err != nil {
+			return nil, err
+		}
+		set.templates[locale] = map[string]*emailTemplate{}
+		for _, file := range files {
+			kind, ok := strings.CutSuffix(file, ".tmpl")
+			if !ok || file == commonTemplate {
+				continue
+			}
+			body, err := source.readFile(path.Join(locale, file))
+			if err != nil {
+				return nil, err
+			}
+			t, err := parseTemplate(layoutHTML, layoutText, common, body)
+			if err != nil {
+				return nil, fmt.Errorf("template de email %s/%s: %w", locale, file, err)
+			}
+			set.templates[locale][kind] = t
+		}
+	}
+	return set, nil
+}
+
+// parseTemplate parses an email kind into both layouts: the HTML one with
+// contextual escaping, and the plain text one, which also renders the subject
+func parseTemplate(layoutHTML, layoutText, common, body []byte) (*emailTemplate, error) {
+	html, err := htmltemplate.New(htmlLayout).Funcs(htmltemplate.FuncMap(templateFuncs)).Parse(string(layoutHTML))
+	if err != nil {
+		return nil, err
+	}
+	text, err := texttemplate.New(textLayout).Funcs(texttemplate.FuncMap(templateFuncs)).Parse(string(layoutText))
+	if err != nil {
+		return nil, err
+	}
+	// The kind is parsed last, so it may redefine the common blocks
+	for _, src := range []string{string(common), string(body)} {
+		if _, err := html.Parse(src); err != nil {
+			return nil, err
+		}
+		if _, err := text.Parse(src); err != nil {
+			return nil, err
+		}
+	}
+	for _, block := range requiredBlocks {
+		if text.Lookup(block) == nil {
+			return nil, fmt.Errorf("bloco %q não definido", block)
+		}
+	}
+	return &emailTemplate{html: html, text: text}, nil
+}
+
+// lookup returns the template of kind in the closest locale to requested
+func (s *templateSet) lookup(requested, kind string) (*emailTemplate, string) {
+	for _, locale := range s.candidates(requested) {
+		if t, ok := s.templates[locale][kind]; ok {
+			return t, locale
+		}
+	}
+	return nil, ""
+}
+
+// candidates lists the locales to try for requested: the exact match, the
+// same language in any region, then the default
+func (s *templateSet) candidates(requested string) []string {
+	var candidates []string
+	if requested = strings.ReplaceAll(requested, "_", "-"); requested != "" {
+		language, _, _ := strings.Cut(requested, "-")
+		for _, locale := range s.locales {
+			if strings.EqualFold(locale, requested) {
+				candidates = append(candidates, locale)
+			}
+		}
+		for _, locale := range s.locales {
+			if prefix, _, _ := strings.Cut(locale, "-"); strings.EqualFold(prefix, language) {
+				candidates = append(candidates, locale)
+			}
+		}
+	}
+	return append(candidates, s.defaultLocale)
+}
+
+// render executes the kind email in the locale of ctx, returning its subject
+// and its HTML and plain text bodies
+func (s *templateSet) render(ctx context.Context, kind string, data EmailData) (subject, html, text string, err error) {
+	t, locale := s.lookup(LocaleFromContext(ctx), kind)
+	if t == nil {
+		return "", "", "", fmt.Errorf("template de email %q não encontrado", kind)
+	}
+	data.Locale = locale
+
+	var buf bytes.Buffer
+	if err := t.text.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", "", err
+	}
+	subject = strings.Join(strings.Fields(buf.String()), " ")
+
+	buf.Reset()
+	if err := t.html.ExecuteTemplate(&buf, htmlLayout, data); err != nil {
+		return "", "", "", err
+	}
+	html = buf.String()
+
+	buf.Reset()
+	if err := t.text.ExecuteTemplate(&buf, textLayout, data); err != nil {
+		return "", "", "", err
+	}
+	return subject, html, strings.TrimSpace(buf.String()) + "\n", nil
+}
@@ -0,0 +1,171 @@
+package email
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var allKinds = []string{
+	KindPasswordReset,
+	KindWelcome,
+	KindNewDevice,
+	KindAccountDeletion,
+	KindVerificationReminder,
+	KindEmailVerification,
+	KindAccountLocked,
+	KindMagicLink,
+}
+
+func TestLoadTemplates_RendersEveryKind(t *testing.T) {
+	set, err := loadTemplates("", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"en", "pt-BR"}, set.locales)
+
+	data := EmailData{
+		Username:     "alice",
+		DisplayName:  "Alice <b>",
+		AppName:      "GoSvelteKit",
+		SupportEmail: "support@example.com",
+		ResetLink:    "https://example.com/reset?token=abc",
+		RestoreLink:  "https://example.com/restore?token=abc",
+		VerifyLink:   "https://example.com/verify?token=abc",
+		UserAgent:    "Firefox",
+		IP:           "192.0.2.1",
+		DeletionDate: time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC),
+		LockedUntil:  time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC),
+		LoginLink:    "https://example.com/magic-link?token=abc",
+	}
+	for _, locale := range set.locales {
+		for _, kind := range allKinds {
+			t.Run(locale+"/"+kind, func(t *testing.T) {
+				subject, html, text, err := set.render(WithLocale(context.Background(), locale), kind, data)
+				require.NoError(t, err)
+				assert.NotEmpty(t, subject)
+				assert.NotContains(t, subject, "\n")
+				assert.Contains(t, html, `<html lang="`+locale+`">`)
+				assert.Contains(t, html, "Alice &lt;b&gt;", "HTML is escaped")
+				assert.Contains(t, text, "Alice <b>", "plain text is not")
+				assert.Contains(t, text, "support@example.com", "footer")
+				assert.NotContains(t, text, "<p>")
+			})
+		}
+	}
+
+	_, html, text, err := set.render(context.Background(), KindAccountLocked, data)
+	require.NoError(t, err)
+	assert.Contains(t, html, "01/03/2026 12:30 UTC")
+	assert.Contains(t, text, "01/03/2026 12:30 UTC")
+
+	_, _, _, err = set.render(context.Background(), "unknown", data)
+	assert.Error(t, err)
+}
+
+func TestTemplateSet_LocaleFallback(t *testing.T) {
+	set, err := loadTemplates("", "pt-br")
+	require.NoError(t, err)
+	assert.Equal(t, "pt-BR", set.defaultLocale)
+
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"", "pt-BR"},
+		{"en", "en"},
+		{"EN", "en"},
+		{"en-US", "en"},
+		{"pt_BR", "pt-BR"},
+		{"pt-PT", "pt-BR"},
+		{"fr-FR", "pt-BR"},
+	}
+	for _, tt := range tests {
+		_, locale := set.lookup(tt.requested, KindWelcome)
+		assert.Equal(t, tt.want, locale, tt.requested)
+	}
+
+	_, err = loadTemplates("", "fr")
+	assert.ErrorContains(t, err, "default_locale")
+}
+
+func TestLoadTemplates_OverrideDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	// Replace one kind, and add a locale with only one kind and no common.tmpl
+	write("en/welcome.tmpl", `{{define "subject"}}Hi from {{.AppName}}{{end}}{{define "title"}}Hi{{end}}{{define "content"}}<p>Custom {{.Username}}</p>{{end}}{{define "text"}}Custom {{.Username}}{{end}}`)
+	write("es/welcome.tmpl", `{{define "subject"}}Bienvenido{{end}}{{define "title"}}Hola{{end}}{{define "content"}}<p>Hola</p>{{end}}{{define "text"}}Hola{{end}}`)
+
+	set, err := loadTemplates(dir, "")
+	require.NoError(t, err)
+	data := EmailData{Username: "alice", AppName: "GoSvelteKit", SupportEmail: "support@example.com"}
+
+	subject, html, _, err := set.render(WithLocale(context.Background(), "en"), KindWelcome, data)
+	require.NoError(t, err)
+	assert.Equal(t, "Hi from GoSvelteKit", subject)
+	assert.Contains(t, html, "Custom alice")
+	assert.Contains(t, html, "class=\"footer\"", "embedded layout kept")
+
+	subject, _, text, err := set.render(WithLocale(context.Background(), "es"), KindWelcome, data)
+	require.NoError(t, err)
+	assert.Equal(t, "Bienvenido", subject)
+	assert.Contains(t, text, "Em caso de dúvidas", "default locale footer")
+
+	// Kinds missing in the new locale use the default one
+	_, locale := set.lookup("es", KindPasswordReset)
+	assert.Equal(t, "pt-BR", locale)
+
+	// Broken templates fail at load time
+	write("en/welcome.tmpl", `{{define "subject"}}Hi{{end}}`)
+	_, err = loadTemplates(dir, "")
+	assert.ErrorContains(t, err, "en/welcome.tmpl")
+
+	_, err = loadTemplates(filepath.Join(dir, "missing"), "")
+	assert.ErrorContains(t, err, "templates_dir")
+}
+
+func TestBuildMessage(t *testing.T) {
+	from := (&mail.Address{Name: "GoSvelteKit", Address: "no-reply@example.com"}).String()
+	raw, err := buildMessage(from, "user@example.com", "Recuperação de Senha", "<p>Olá</p>", "Olá\n", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Recuperação de Senha", subject)
+	assert.Equal(t, "user@example.com", msg.Header.Get("To"))
+	assert.NotEmpty(t, msg.Header.Get("Date"))
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	var types, bodies []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(part) // quoted-printable is decoded by the reader
+		require.NoError(t, err)
+		types = append(types, part.Header.Get("Content-Type"))
+		bodies = append(bodies, string(body))
+	}
+	assert.Equal(t, []string{"text/plain; charset=UTF-8", "text/html; charset=UTF-8"}, types)
+	assert.Equal(t, []string{"Olá\r\n", "<p>Olá</p>"}, bodies, "line breaks are sent as CRLF")
+}
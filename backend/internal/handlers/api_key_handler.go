@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/audit"
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyManager issues and revokes API keys, see auth.AuthManager
+type APIKeyManager interface {
+	CreateAPIKey(ctx context.Context, userID, name string, scopes []string, expiresAt *time.Time) (string, *auth.APIKey, error)
+	CreateAPIKeyFor(ctx context.Context, actorID, targetUserID, name string, scopes []string, expiresAt *time.Time) (string, *auth.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]*auth.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id string) error
+}
+
+// APIKeyHandler handles the API keys of the current user and, for admins,
+// of any user
+type APIKeyHandler struct {
+	manager APIKeyManager
+	auditor
+}
+
+// CreateAPIKeyRequest represents the API key creation request body
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" binding:"required,notblank,max=100"`
+	// Scopes are the permissions the key is limited to, e.g. "users:read"
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+	// ExpiresAt is when the key stops working; omitted, it never expires
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// NewAPIKeyHandler creates a new APIKeyHandler instance
+func NewAPIKeyHandler(manager APIKeyManager, opts ...AdminOption) *APIKeyHandler {
+	h := &APIKeyHandler{manager: manager}
+	for _, opt := range opts {
+		opt(&h.auditor)
+	}
+	return h
+}
+
+// List returns the API keys of the current user, newest first
+func (h *APIKeyHandler) List(c *gin.Context) {
+	h.list(c, c.GetString("userID"))
+}
+
+// Create issues an API key for the current user. The key is only in this
+// response.
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	h.create(c, c.GetString("userID"), func(ctx context.Context, userID string, req CreateAPIKeyRequest) (string, *auth.APIKey, error) {
+		return h.manager.CreateAPIKey(ctx, userID, req.Name, req.Scopes, req.ExpiresAt)
+	})
+}
+
+// Revoke deletes the API key in the :id path parameter of the current user
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	h.revoke(c, c.GetString("userID"), c.Param("id"))
+}
+
+// AdminList returns the API keys of the user in the :id path parameter
+func (h *APIKeyHandler) AdminList(c *gin.Context) {
+	h.list(c, c.Param("id"))
+}
+
+// AdminCreate issues an API key for the user in the :id path parameter,
+// e.g. a service account used by an external integration. Only for users the
+// admin could impersonate and with scopes their role grants, see
+// auth.AuthManager.CreateAPIKeyFor.
+func (h *APIKeyHandler) AdminCreate(c *gin.Context) {
+	actorID := c.GetString("userID")
+	h.create(c, c.Param("id"), func(ctx context.Context, userID string, req CreateAPIKeyRequest) (string, *auth.APIKey, error) {
+		return h.manager.CreateAPIKeyFor(ctx, actorID, userID, req.Name, req.Scopes, req.ExpiresAt)
+	})
+}
+
+// AdminRevoke deletes the API key in the :key_id path parameter of the user
+// in the :id path parameter
+func (h *APIKeyHandler) AdminRevoke(c *gin.Context) {
+	h.revoke(c, c.Param("id"), c.Param("key_id"))
+}
+
+func (h *APIKeyHandler) list(c *gin.Context, userID string) {
+	keys, err := h.manager.ListAPIKeys(requestContext(c), userID)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, auth.ErrUserNotFound):
+			apierror.Respond(c, apierror.NotFound("usuário não encontrado"))
+		default:
+			internalError(c, err, "falha ao listar chaves de API", "user_id", userID)
+		}
+		return
+	}
+
+	items := make([]dto.APIKeyResponse, len(keys))
+	for i, key := range keys {
+		items[i] = dto.NewAPIKeyResponse(key)
+	}
+	c.JSON(http.StatusOK, dto.APIKeyListResponse{APIKeys: items})
+}
+
+// createFunc issues the key of req for userID
+type createFunc func(ctx context.Context, userID string, req CreateAPIKeyRequest) (string, *auth.APIKey, error)
+
+func (h *APIKeyHandler) create(c *gin.Context, userID string, issue createFunc) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	secret, key, err := issue(requestContext(c), userID, req)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, auth.ErrInvalidScope):
+			apierror.Respond(c, apierror.BadRequest("escopo inválido: use permissões como users:read"))
+		case errors.Is(err, auth.ErrScopeNotGranted):
+			apierror.Respond(c, apierror.BadRequest("escopo não concedido ao papel do usuário"))
+		case errors.Is(err, auth.ErrAPIKeyForbidden):
+			apierror.Respond(c, apierror.Forbidden("não é permitido criar chaves de API para este usuário"))
+		case errors.Is(err, auth.ErrInvalidAPIKeyExpiry):
+			apierror.Respond(c, apierror.BadRequest("expires_at deve estar no futuro"))
+		case errors.Is(err, auth.ErrTooManyAPIKeys):
+			apierror.Respond(c, apierror.Conflict("limite de chaves de API atingido"))
+		case errors.Is(err, auth.ErrUserNotFound):
+			apierror.Respond(c, apierror.NotFound("usuário não encontrado"))
+		default:
+			internalError(c, err, "falha ao criar chave de API", "user_id", userID)
+		}
+		return
+	}
+
+	logger.FromContext(requestContext(c)).Info("Chave de API criada", "actor_id", c.GetString("userID"), "user_id", userID, "api_key_id", key.ID, "scopes", key.Scopes, "ip", getClientIP(c))
+	h.record(c, audit.Event{Type: audit.EventAPIKeyCreated, TargetID: userID, Data: map[string]any{"api_key_id": key.ID, "name": key.Name, "scopes": key.Scopes}})
+	c.JSON(http.StatusCreated, dto.CreatedAPIKeyResponse{Key: secret, APIKey: dto.NewAPIKeyResponse(key)})
+}
+
+func (h *APIKeyHandler) revoke(c *gin.Context, userID, id string) {
+	if err := h.manager.RevokeAPIKey(requestContext(c), userID, id); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, auth.ErrAPIKeyNotFound), errors.Is(err, auth.ErrUserNotFound):
+			apierror.Respond(c, apierror.NotFound("chave de API não encontrada"))
+		default:
+			internalError(c, err, "falha ao revogar chave de API", "user_id", userID)
+		}
+		return
+	}
+
+	h.record(c, audit.Event{Type: audit.EventAPIKeyRevoked, TargetID: userID, Data: map[string]any{"api_key_id": id}})
+	c.JSON(http.StatusOK, gin.H{"message": "chave de API revogada com sucesso"})
+}
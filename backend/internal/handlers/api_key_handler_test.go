@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeAPIKeyManager struct {
+	keys map[string]*auth.APIKey
+}
+
+func (f *fakeAPIKeyManager) CreateAPIKey(ctx context.Context, userID, name string, scopes []string, expiresAt *time.Time) (string, *auth.APIKey, error) {
+	if userID != "1" {
+		return "", nil, auth.ErrUserNotFound
+	}
+	if len(scopes) == 1 && scopes[0] == "bad" {
+		return "", nil, auth.ErrInvalidScope
+	}
+	key := &auth.APIKey{ID: "0123456789abcdef", UserID: userID, Name: name, Scopes: scopes, ExpiresAt: expiresAt}
+	f.keys[key.ID] = key
+	return auth.APIKeyPrefix + key.ID + "_secret", key, nil
+}
+
+// CreateAPIKeyFor refuses user 3 as an admin and users:write as a scope the
+// target's role doesn't grant
+func (f *fakeAPIKeyManager) CreateAPIKeyFor(ctx context.Context, actorID, targetUserID, name string, scopes []string, expiresAt *time.Time) (string, *auth.APIKey, error) {
+	if targetUserID == "3" {
+		return "", nil, auth.ErrAPIKeyForbidden
+	}
+	for _, scope := range scopes {
+		if scope == "users:write" {
+			return "", nil, auth.ErrScopeNotGranted
+		}
+	}
+	return f.CreateAPIKey(ctx, targetUserID, name, scopes, expiresAt)
+}
+
+func (f *fakeAPIKeyManager) ListAPIKeys(ctx context.Context, userID string) ([]*auth.APIKey, error) {
+	var keys []*auth.APIKey
+	for _, key := range f.keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeAPIKeyManager) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	key, ok := f.keys[id]
+	if !ok || key.UserID != userID {
+		return auth.ErrAPIKeyNotFound
+	}
+	delete(f.keys, id)
+	return nil
+}
+
+func TestAPIKeyHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewAPIKeyHandler(&fakeAPIKeyManager{keys: map[string]*auth.APIKey{}})
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("userID", "1") })
+	router.GET("/me/api-keys", handler.List)
+	router.POST("/me/api-keys", handler.Create)
+	router.DELETE("/me/api-keys/:id", handler.Revoke)
+	router.POST("/admin/users/:id/api-keys", handler.AdminCreate)
+	router.DELETE("/admin/users/:id/api-keys/:key_id", handler.AdminRevoke)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/me/api-keys", strings.NewReader(`{"name":"cron","scopes":["users:read"]}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var created dto.CreatedAPIKeyResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if created.Key != "ak_0123456789abcdef_secret" || created.APIKey.Name != "cron" {
+		t.Errorf("unexpected response %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me/api-keys", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("listed keys must not include the secret: %s", w.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"missing name", http.MethodPost, "/me/api-keys", `{"scopes":["users:read"]}`, http.StatusBadRequest},
+		{"missing scopes", http.MethodPost, "/me/api-keys", `{"name":"cron"}`, http.StatusBadRequest},
+		{"invalid scope", http.MethodPost, "/me/api-keys", `{"name":"cron","scopes":["bad"]}`, http.StatusBadRequest},
+		{"unknown user", http.MethodPost, "/admin/users/999/api-keys", `{"name":"cron","scopes":["users:read"]}`, http.StatusNotFound},
+		{"admin target", http.MethodPost, "/admin/users/3/api-keys", `{"name":"cron","scopes":["users:read"]}`, http.StatusForbidden},
+		{"scope not granted", http.MethodPost, "/admin/users/1/api-keys", `{"name":"cron","scopes":["users:write"]}`, http.StatusBadRequest},
+		{"other user's key", http.MethodDelete, "/admin/users/2/api-keys/0123456789abcdef", "", http.StatusNotFound},
+		{"revoke", http.MethodDelete, "/me/api-keys/0123456789abcdef", "", http.StatusOK},
+		{"revoked", http.MethodDelete, "/me/api-keys/0123456789abcdef", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"gosveltekit/internal/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminOption configures the handlers of admin actions (users, lockouts,
+// settings)
+type AdminOption func(*auditor)
+
+// WithAuditRecorder makes the handler record the admin actions it performs
+// in recorder
+func WithAuditRecorder(recorder audit.Recorder) AdminOption {
+	return func(a *auditor) {
+		a.recorder = recorder
+	}
+}
+
+// auditor records the admin actions of a handler; without a recorder it does
+// nothing
+type auditor struct {
+	recorder audit.Recorder
+}
+
+// record records event, performed by the user of the request from its client
+func (a auditor) record(c *gin.Context, event audit.Event) {
+	if a.recorder == nil {
+		return
+	}
+	if event.ActorID == "" {
+		event.ActorID = c.GetString("userID")
+	}
+	if event.IP == "" {
+		event.IP = getClientIP(c)
+	}
+	if event.UserAgent == "" && c.Request != nil {
+		event.UserAgent = c.Request.UserAgent()
+	}
+	a.recorder.Record(requestContext(c), event)
+}
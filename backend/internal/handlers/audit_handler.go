@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/audit"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditLog lists recorded audit events, see audit.Store
+type AuditLog interface {
+	List(ctx context.Context, filter audit.Filter, offset, limit int) ([]*models.AuditLog, int64, error)
+	Each(ctx context.Context, filter audit.Filter, fn func(*models.AuditLog) error) error
+}
+
+// AuditHandler handles admin audit log HTTP requests
+type AuditHandler struct {
+	log AuditLog
+}
+
+// NewAuditHandler creates a new AuditHandler instance
+func NewAuditHandler(log AuditLog) *AuditHandler {
+	return &AuditHandler{log: log}
+}
+
+// List returns a page of the audit log, most recent first. Accepts page and
+// page_size plus the filters event, actor_id, target_id, since and until
+// (RFC 3339 timestamps).
+func (h *AuditHandler) List(c *gin.Context) {
+	params, err := pagination.Parse(c)
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	entries, total, err := h.log.List(requestContext(c), filter, params.Offset(), params.Limit())
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao listar registros de auditoria")
+		return
+	}
+
+	items := make([]dto.AuditLogResponse, len(entries))
+	for i, entry := range entries {
+		items[i] = dto.NewAuditLogResponse(entry)
+	}
+	c.JSON(http.StatusOK, dto.NewListResponse(items, pagination.NewMeta(params, total)))
+}
+
+// Export streams the entries matching the filters of List, most recent
+// first, as a download in the format query parameter: csv (default), with a
+// header row, or json, an array of the entries of List.
+func (h *AuditHandler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		apierror.Respond(c, apierror.BadRequest("parâmetro format deve ser csv ou json"))
+		return
+	}
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	ctx := requestContext(c)
+	filename := fmt.Sprintf("audit-export-%s.%s", time.Now().UTC().Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	var started bool
+	if format == "csv" {
+		started, err = streamCSV(c, dto.AuditLogCSVHeader, func(emit func([]string) error) error {
+			return h.log.Each(ctx, filter, func(entry *models.AuditLog) error {
+				return emit(dto.NewAuditLogResponse(entry).CSVRecord())
+			})
+		})
+	} else {
+		started, err = streamJSONArray(c, func(emit func(any) error) error {
+			return h.log.Each(ctx, filter, func(entry *models.AuditLog) error {
+				return emit(dto.NewAuditLogResponse(entry))
+			})
+		})
+	}
+	if err == nil {
+		return
+	}
+	if started {
+		logger.FromContext(c.Request.Context()).Error("Exportação de auditoria interrompida", "error", err, "ip", getClientIP(c))
+		return
+	}
+	c.Header("Content-Disposition", "")
+	if abortIfCanceled(c, err) {
+		return
+	}
+	internalError(c, err, "falha ao exportar registros de auditoria")
+}
+
+// parseAuditFilter reads the filters event, actor_id, target_id, since and
+// until (RFC 3339 timestamps) of the query
+func parseAuditFilter(c *gin.Context) (audit.Filter, error) {
+	filter := audit.Filter{Event: c.Query("event"), ActorID: c.Query("actor_id"), TargetID: c.Query("target_id")}
+	for name, dest := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		value, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return audit.Filter{}, fmt.Errorf("parâmetro %s deve ser uma data RFC 3339 (2006-01-02T15:04:05Z)", name)
+		}
+		*dest = value
+	}
+	return filter, nil
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/audit"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mockAuditLog struct {
+	entries []*models.AuditLog
+
+	filter        audit.Filter
+	offset, limit int
+	eachErr       error
+}
+
+func (m *mockAuditLog) List(ctx context.Context, filter audit.Filter, offset, limit int) ([]*models.AuditLog, int64, error) {
+	m.filter, m.offset, m.limit = filter, offset, limit
+	return m.entries, int64(len(m.entries)), nil
+}
+
+func (m *mockAuditLog) Each(ctx context.Context, filter audit.Filter, fn func(*models.AuditLog) error) error {
+	m.filter = filter
+	if m.eachErr != nil {
+		return m.eachErr
+	}
+	for _, entry := range m.entries {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mockRecorder keeps the recorded events
+type mockRecorder struct {
+	events []audit.Event
+}
+
+func (m *mockRecorder) Record(ctx context.Context, event audit.Event) {
+	m.events = append(m.events, event)
+}
+
+func TestAuditHandler_List(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	log := &mockAuditLog{entries: []*models.AuditLog{
+		{ID: 9, Event: audit.EventUserRoleChanged, ActorID: "1", TargetID: "2", IP: "203.0.113.7", Data: `{"role":"admin"}`, CreatedAt: time.Now()},
+	}}
+	h := NewAuditHandler(log)
+	router := gin.New()
+	router.GET("/admin/audit-logs", h.List)
+
+	t.Run("filters", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/audit-logs?event=user.role_changed&actor_id=1&target_id=2&since=2026-01-01T00:00:00Z&page=2&page_size=5", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		if log.filter.Event != audit.EventUserRoleChanged || log.filter.ActorID != "1" || log.filter.TargetID != "2" || !log.filter.Since.Equal(since) || !log.filter.Until.IsZero() {
+			t.Errorf("unexpected filter %+v", log.filter)
+		}
+		if log.offset != 5 || log.limit != 5 {
+			t.Errorf("expected offset 5 and limit 5, got %d and %d", log.offset, log.limit)
+		}
+
+		var resp dto.ListResponse[dto.AuditLogResponse]
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(resp.Data) != 1 || resp.Data[0].ID != "9" || string(resp.Data[0].Data) != `{"role":"admin"}` {
+			t.Errorf("unexpected response: %s", w.Body.String())
+		}
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/audit-logs?until=yesterday", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestAuditHandler_Export(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	createdAt := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	log := &mockAuditLog{entries: []*models.AuditLog{
+		{ID: 9, Event: audit.EventUserRoleChanged, ActorID: "1", TargetID: "2", IP: "203.0.113.7", UserAgent: "=cmd()", Data: `{"role":"admin"}`, CreatedAt: createdAt},
+		{ID: 8, Event: audit.EventLoginFailed, CreatedAt: createdAt},
+	}}
+	router := gin.New()
+	router.GET("/admin/audit/export", NewAuditHandler(log).Export)
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/audit/export"+query, nil))
+		return w
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		w := get("?actor_id=1&since=2026-01-01T00:00:00Z&until=2026-04-01T00:00:00Z")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+			t.Errorf("unexpected content type %q", got)
+		}
+		if got := w.Header().Get("Content-Disposition"); !strings.HasPrefix(got, `attachment; filename="audit-export-`) || !strings.HasSuffix(got, `.csv"`) {
+			t.Errorf("unexpected content disposition %q", got)
+		}
+		if log.filter.ActorID != "1" || !log.filter.Since.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) || !log.filter.Until.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected filter %+v", log.filter)
+		}
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("failed to parse CSV: %v", err)
+		}
+		if len(records) != 3 {
+			t.Fatalf("expected a header and 2 rows, got %v", records)
+		}
+		if got := strings.Join(records[0], ","); got != "id,created_at,event,actor_id,target_id,ip,user_agent,request_id,data" {
+			t.Errorf("unexpected header row %q", got)
+		}
+		want := []string{"9", "2026-03-04T05:06:07Z", audit.EventUserRoleChanged, "1", "2", "203.0.113.7", "'=cmd()", "", `{"role":"admin"}`}
+		if strings.Join(records[1], "|") != strings.Join(want, "|") {
+			t.Errorf("unexpected row %q", records[1])
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		w := get("?format=json")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var entries []dto.AuditLogResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(entries) != 2 || entries[0].ID != "9" || entries[1].ID != "8" {
+			t.Errorf("unexpected response: %s", w.Body.String())
+		}
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"?format=xml", "?since=yesterday"} {
+			if w := get(query); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+
+	t.Run("failure before the first row", func(t *testing.T) {
+		log.eachErr = errors.New("db down")
+		defer func() { log.eachErr = nil }()
+		w := get("")
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+		if got := w.Header().Get("Content-Disposition"); got != "" {
+			t.Errorf("expected no content disposition, got %q", got)
+		}
+	})
+}
+
+func TestUserHandler_RecordsAdminActions(t *testing.T) {
+	recorder := &mockRecorder{}
+	handler := NewUserHandler(&MockUserService{
+		SetActiveFunc: func(ctx context.Context, actorID, userID string, active bool) (*models.User, error) {
+			user := &models.User{Username: "bob", Active: active}
+			user.ID = 7
+			return user, nil
+		},
+	}, WithAuditRecorder(recorder))
+
+	c, w := setupTestRouter()
+	c.Request, _ = http.NewRequest(http.MethodPost, "/api/admin/users/7/disable", nil)
+	c.Request.Header.Set("User-Agent", "admin-cli")
+	c.Params = gin.Params{{Key: "id", Value: "7"}}
+	c.Set("userID", "1")
+	handler.Disable(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if len(recorder.events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(recorder.events))
+	}
+	event := recorder.events[0]
+	if event.Type != audit.EventUserDisabled || event.ActorID != "1" || event.TargetID != "7" || event.UserAgent != "admin-cli" {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
@@ -2,12 +2,22 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"gosveltekit/internal/apierror"
 	"gosveltekit/internal/auth"
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/email"
 	"gosveltekit/internal/logger"
 	"gosveltekit/internal/middleware"
 	"gosveltekit/internal/service"
+	"gosveltekit/internal/storage"
 	"gosveltekit/internal/validation"
 
 	"github.com/gin-gonic/gin"
@@ -16,11 +26,93 @@ import (
 // AuthHandler handles authentication-related HTTP requests
 type AuthHandler struct {
 	authService service.AuthServiceInterface
+
+	autoLoginAfterRegister   bool
+	requireEmailVerification bool
+
+	// revealEmailAvailability makes CheckEmail answer whether the email is
+	// taken instead of a generic message
+	revealEmailAvailability bool
+
+	// avatars stores the pictures of UploadAvatar; nil disables avatars
+	avatars      storage.Storage
+	avatarConfig config.AvatarConfig
+	avatarURLTTL time.Duration
+}
+
+// AuthHandlerOption configures an AuthHandler
+type AuthHandlerOption func(*AuthHandler)
+
+// WithRegistrationResponse sets what Register answers. With
+// requireEmailVerification no session is created and the response asks the
+// user to verify their email, whatever autoLogin says; otherwise autoLogin
+// logs the new user in, answering like Login. With neither only the created
+// user is returned.
+func WithRegistrationResponse(autoLogin, requireEmailVerification bool) AuthHandlerOption {
+	return func(h *AuthHandler) {
+		h.autoLoginAfterRegister = autoLogin
+		h.requireEmailVerification = requireEmailVerification
+	}
+}
+
+// WithEmailAvailability makes CheckEmail tell whether the email is already
+// registered. Anyone can then enumerate accounts by email, so only enable it
+// for internal tools.
+func WithEmailAvailability(reveal bool) AuthHandlerOption {
+	return func(h *AuthHandler) {
+		h.revealEmailAvailability = reveal
+	}
 }
 
 // NewAuthHandler creates a new AuthHandler instance
-func NewAuthHandler(authService service.AuthServiceInterface) *AuthHandler {
-	return &AuthHandler{authService: authService}
+func NewAuthHandler(authService service.AuthServiceInterface, opts ...AuthHandlerOption) *AuthHandler {
+	h := &AuthHandler{authService: authService}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// ChangePasswordRequest represents the password change request body
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+	ConfirmPassword string `json:"confirm_password" binding:"required"`
+}
+
+// CheckEmailRequest represents the email availability check request body
+type CheckEmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// DeleteAccountRequest represents the account deletion request body
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// RefreshRequest represents the session refresh request body
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RestoreAccountRequest represents the account restore request body
+type RestoreAccountRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// VerifyEmailRequest represents the email verification request body
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationRequest represents the verification resend request body
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// RevokeTokenRequest represents the session token revocation request body
+type RevokeTokenRequest struct {
+	Token string `json:"token" binding:"required"`
 }
 
 // LoginRequest represents the login request body
@@ -35,6 +127,18 @@ type RegistrationRequest struct {
 	Email       string `json:"email" binding:"required"`
 	Password    string `json:"password" binding:"required"`
 	DisplayName string `json:"display_name" binding:"required"`
+
+	// CaptchaToken is the token returned by the CAPTCHA widget, when enabled
+	CaptchaToken string `json:"captcha_token"`
+
+	// Locale is the language emails are sent in, e.g. "pt-BR". Defaults to the
+	// preferred language of the Accept-Language header.
+	Locale string `json:"locale" binding:"omitempty,locale"`
+}
+
+// UpdateLocaleRequest represents the email language change request body
+type UpdateLocaleRequest struct {
+	Locale string `json:"locale" binding:"omitempty,locale"` // empty goes back to the default
 }
 
 // PasswordResetRequest represents the password reset request body
@@ -48,15 +152,15 @@ type PasswordResetRequest struct {
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		logger.Debug("Requisição de login com JSON inválido", "error", err, "ip", getClientIP(c))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.FromContext(requestContext(c)).Debug("Requisição de login com JSON inválido", "error", err, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.FromBinding(err))
 		return
 	}
 
 	// Validate input data before attempting login
 	if err := validation.ValidateLoginRequest(req.Username, req.Password); err != nil {
-		logger.Debug("Requisição de login com validação falhada", "error", err, "username", req.Username, "ip", getClientIP(c))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.FromContext(requestContext(c)).Debug("Requisição de login com validação falhada", "error", err, "username", req.Username, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.Validation(err.Error()))
 		return
 	}
 
@@ -67,8 +171,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		userAgent = c.Request.UserAgent()
 	}
 
-	response, err := h.authService.Login(req.Username, req.Password, ip, userAgent)
+	response, err := h.authService.Login(requestContext(c), req.Username, req.Password, ip, userAgent)
 	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
 		status := http.StatusUnauthorized
 		message := "credenciais inválidas"
 
@@ -77,24 +184,28 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			message = "usuário inativo"
 		case err.Error() == "conta temporariamente bloqueada, tente novamente mais tarde":
 			message = err.Error()
+		case errors.Is(err, service.ErrLoginThrottled):
+			status = http.StatusTooManyRequests
+			message = err.Error()
+		case errors.Is(err, service.ErrAccountPendingDeletion), errors.Is(err, service.ErrEmailNotVerified):
+			status = http.StatusForbidden
+			message = err.Error()
+		case errors.Is(err, service.ErrTooManySessions):
+			status = http.StatusConflict
+			message = err.Error()
 		}
 
-		c.JSON(status, gin.H{"error": message})
+		apierror.Respond(c, apierror.New(status, message))
 		return
 	}
 
-	// Set session cookie
-	c.SetCookie(
-		middleware.SessionCookieName,
-		response.SessionID,
-		30*24*60*60, // 30 days
-		"/",
-		"",
-		true, // secure
-		true, // httpOnly
-	)
+	// Users with TOTP get a challenge instead of a session
+	if challenge := response.TwoFactor; challenge != nil {
+		c.JSON(http.StatusAccepted, dto.NewTwoFactorChallengeResponse(challenge.Token, challenge.ExpiresAt, challenge.Method))
+		return
+	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, sessionResponse(c, response))
 }
 
 // Logout handles user logout
@@ -102,21 +213,22 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	sessionID, exists := c.Get("sessionID")
 	if !exists {
 		ip := getClientIP(c)
-		logger.Debug("Tentativa de logout sem sessão", "ip", ip)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "não autenticado"})
+		logger.FromContext(requestContext(c)).Debug("Tentativa de logout sem sessão", "ip", ip)
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
 		return
 	}
 
 	sessionIDStr := sessionID.(string)
-	if err := h.authService.Logout(sessionIDStr); err != nil {
-		ip := getClientIP(c)
-		logger.Error("Erro ao fazer logout", "error", err, "session_id", sessionIDStr, "ip", ip)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "falha ao fazer logout"})
+	if err := h.authService.Logout(requestContext(c), sessionIDStr); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao fazer logout", "session_id", sessionIDStr, "ip", getClientIP(c))
 		return
 	}
 
 	ip := getClientIP(c)
-	logger.Info("Logout realizado com sucesso", "session_id", sessionIDStr, "ip", ip)
+	logger.FromContext(requestContext(c)).Info("Logout realizado com sucesso", "session_id", sessionIDStr, "ip", ip)
 
 	// Clear session cookie
 	middleware.ClearSessionCookie(c)
@@ -124,12 +236,90 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "logout realizado com sucesso"})
 }
 
+// Refresh exchanges the refresh token of a login for a new session and
+// refresh token. Public: the session it renews may be about to expire. With
+// the cookie token transport the refresh token comes from its cookie.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if middleware.CookieOptionsFrom(c).TokensInCookies {
+		req.RefreshToken = middleware.RefreshTokenFromCookie(c)
+		if req.RefreshToken == "" {
+			apierror.Respond(c, apierror.Unauthorized(service.ErrInvalidRefreshToken.Error()))
+			return
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	ip := getClientIP(c)
+	userAgent := ""
+	if c.Request != nil {
+		userAgent = c.Request.UserAgent()
+	}
+
+	response, err := h.authService.Refresh(requestContext(c), req.RefreshToken, ip, userAgent)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidRefreshToken):
+			apierror.Respond(c, apierror.Unauthorized(err.Error()))
+		case errors.Is(err, service.ErrUserNotActive):
+			apierror.Respond(c, apierror.Unauthorized("usuário inativo"))
+		case errors.Is(err, service.ErrAccountPendingDeletion):
+			apierror.Respond(c, apierror.Forbidden(err.Error()))
+		default:
+			internalError(c, err, "falha ao renovar sessão", "ip", ip)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, sessionResponse(c, response))
+}
+
+// RevokeToken invalidates one session or refresh token of the authenticated
+// user, e.g. a token the client is about to discard, leaving the other
+// sessions alone. Revoking an unknown or already revoked token succeeds.
+func (h *AuthHandler) RevokeToken(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	var req RevokeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	if err := h.authService.RevokeToken(requestContext(c), userID, req.Token); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			apierror.Respond(c, apierror.Forbidden(err.Error()))
+		default:
+			internalError(c, err, "falha ao revogar sessão", "user_id", userID, "ip", getClientIP(c))
+		}
+		return
+	}
+
+	if req.Token == c.GetString("sessionID") {
+		middleware.ClearSessionCookie(c)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "sessão revogada"})
+}
+
 // Register handles new user registration with comprehensive validation
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req RegistrationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		logger.Debug("Requisição de registro com JSON inválido", "error", err, "ip", getClientIP(c))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.FromContext(requestContext(c)).Debug("Requisição de registro com JSON inválido", "error", err, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.FromBinding(err))
 		return
 	}
 
@@ -140,22 +330,63 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		req.Password,
 		req.DisplayName,
 	); err != nil {
-		logger.Debug("Requisição de registro com validação falhada", "error", err, "username", req.Username, "email", req.Email, "ip", getClientIP(c))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.FromContext(requestContext(c)).Debug("Requisição de registro com validação falhada", "error", err, "username", req.Username, "email", req.Email, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.Validation(err.Error()))
 		return
 	}
+	ctx := email.WithLocale(requestContext(c), registrationLocale(c, req.Locale))
 
 	// Forward to service layer
-	user, err := h.authService.Register(req.Username, req.Email, req.Password, req.DisplayName)
+	user, verificationSent, err := h.authService.Register(ctx, req.Username, req.Email, req.Password, req.DisplayName, req.CaptchaToken, getClientIP(c))
 	if err != nil {
-		logger.Debug("Erro ao registrar usuário", "error", err, "username", req.Username, "email", req.Email, "ip", getClientIP(c))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if abortIfCanceled(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrRegistrationClosed) {
+			apierror.Respond(c, apierror.Forbidden(err.Error()))
+			return
+		}
+		if errors.Is(err, service.ErrRegistrationEmail) {
+			apierror.Respond(c, apierror.New(http.StatusServiceUnavailable, err.Error()))
+			return
+		}
+		logger.FromContext(requestContext(c)).Debug("Erro ao registrar usuário", "error", err, "username", req.Username, "email", req.Email, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
 		return
 	}
 
-	// Strip sensitive data
-	user.PasswordHash = ""
-	c.JSON(http.StatusOK, user)
+	switch {
+	case h.requireEmailVerification:
+		message := "cadastro realizado, verifique seu email para entrar"
+		if !verificationSent {
+			message = "cadastro realizado, mas o email de verificação não pôde ser enviado: peça um novo link"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":                 message,
+			"verification_required":   true,
+			"verification_email_sent": verificationSent,
+			"user":                    dto.NewUserResponse(user),
+		})
+		return
+	case h.autoLoginAfterRegister:
+		userAgent := ""
+		if c.Request != nil {
+			userAgent = c.Request.UserAgent()
+		}
+		response, err := h.authService.Login(requestContext(c), req.Username, req.Password, getClientIP(c), userAgent)
+		if err == nil {
+			c.JSON(http.StatusOK, sessionResponse(c, response))
+			return
+		}
+		if abortIfCanceled(c, err) {
+			return
+		}
+		// The account exists anyway: the user can log in by hand
+		logger.FromContext(requestContext(c)).Error("Erro ao criar sessão após o cadastro", "error", err, "user_id", user.ID)
+	}
+
+	// DTO leaves out sensitive data
+	c.JSON(http.StatusOK, dto.NewUserResponse(user))
 }
 
 // RequestPasswordReset handles password reset requests
@@ -165,21 +396,24 @@ func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		logger.Debug("Requisição de reset de senha com JSON inválido", "error", err, "ip", getClientIP(c))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.FromContext(requestContext(c)).Debug("Requisição de reset de senha com JSON inválido", "error", err, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.FromBinding(err))
 		return
 	}
 
 	// Validate email
 	if err := validation.ValidateEmail(req.Email); err != nil {
-		logger.Debug("Requisição de reset de senha com email inválido", "error", err, "email", req.Email, "ip", getClientIP(c))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.FromContext(requestContext(c)).Debug("Requisição de reset de senha com email inválido", "error", err, "email", req.Email, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.Validation(err.Error()))
 		return
 	}
 
-	if err := h.authService.RequestPasswordReset(req.Email); err != nil {
+	if err := h.authService.RequestPasswordReset(requestContext(c), req.Email); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
 		if err.Error() == "invalid email format" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Respond(c, apierror.BadRequest(err.Error()))
 			return
 		}
 		// Don't reveal if email exists for security reasons
@@ -190,23 +424,80 @@ func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "se o email existir, um link de recuperação será enviado"})
 }
 
+// AdminRequestPasswordReset emails a password reset link to the user in the
+// :id path parameter, for users who can't ask for one themselves. Admin only.
+func (h *AuthHandler) AdminRequestPasswordReset(c *gin.Context) {
+	actorID := c.GetString("userID")
+	if err := h.authService.AdminRequestPasswordReset(requestContext(c), actorID, c.Param("id")); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+		default:
+			internalError(c, err, "falha ao enviar link de recuperação de senha")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "link de recuperação de senha enviado ao usuário"})
+}
+
+// CheckEmail lets the registration form check an email before submitting.
+// By default it always answers the same generic message, so it can't be used
+// to find out who has an account; with WithEmailAvailability it answers
+// {"available": bool} instead.
+func (h *AuthHandler) CheckEmail(c *gin.Context) {
+	var req CheckEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.FromContext(requestContext(c)).Debug("Verificação de email com JSON inválido", "error", err, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	if err := validation.ValidateEmail(req.Email); err != nil {
+		logger.FromContext(requestContext(c)).Debug("Verificação de email com email inválido", "error", err, "email", req.Email, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.Validation(err.Error()))
+		return
+	}
+
+	if !h.revealEmailAvailability {
+		c.JSON(http.StatusOK, gin.H{"message": "email válido, a disponibilidade será confirmada no cadastro"})
+		return
+	}
+
+	available, err := h.authService.EmailAvailable(requestContext(c), req.Email)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao verificar email", "ip", getClientIP(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"available": available})
+}
+
 // ResetPassword handles password reset with token validation
 func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	var req PasswordResetRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		logger.Debug("Requisição de reset de senha com JSON inválido", "error", err, "ip", getClientIP(c))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.FromContext(requestContext(c)).Debug("Requisição de reset de senha com JSON inválido", "error", err, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.FromBinding(err))
 		return
 	}
 
 	// Validate password reset request
 	if err := validation.ValidatePasswordReset(req.Token, req.NewPassword, req.ConfirmPassword); err != nil {
-		logger.Debug("Requisição de reset de senha com validação falhada", "error", err, "ip", getClientIP(c))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.FromContext(requestContext(c)).Debug("Requisição de reset de senha com validação falhada", "error", err, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.Validation(err.Error()))
 		return
 	}
 
-	if err := h.authService.ResetPassword(req.Token, req.NewPassword); err != nil {
+	if err := h.authService.ResetPassword(requestContext(c), req.Token, req.NewPassword); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
 		status := http.StatusBadRequest
 		message := "falha ao redefinir senha"
 		ip := getClientIP(c)
@@ -214,30 +505,489 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 		switch {
 		case err == service.ErrInvalidToken:
 			message = "token inválido"
-			logger.Warn("Tentativa de reset de senha com token inválido", "ip", ip)
+			logger.FromContext(requestContext(c)).Warn("Tentativa de reset de senha com token inválido", "ip", ip)
 		case err == service.ErrExpiredToken:
 			message = "token expirado"
-			logger.Warn("Tentativa de reset de senha com token expirado", "ip", ip)
+			logger.FromContext(requestContext(c)).Warn("Tentativa de reset de senha com token expirado", "ip", ip)
+		case err == service.ErrPasswordReused:
+			message = err.Error()
+			logger.FromContext(requestContext(c)).Debug("Tentativa de reset de senha reutilizando senha recente", "ip", ip)
+		case errors.Is(err, service.ErrPasswordTooLong):
+			message = err.Error()
+			logger.FromContext(requestContext(c)).Debug("Tentativa de reset de senha com senha longa demais", "ip", ip)
 		default:
-			logger.Error("Erro ao resetar senha", "error", err, "ip", ip)
+			logger.FromContext(requestContext(c)).Error("Erro ao resetar senha", "error", err, "ip", ip)
 		}
 
-		c.JSON(status, gin.H{"error": message})
+		apierror.Respond(c, apierror.New(status, message))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "senha redefinida com sucesso"})
 }
 
-// GetCurrentUser returns the currently authenticated user
+// ChangePassword changes the password of the authenticated user. It is the
+// only route besides GET /api/me and logout available to sessions of users
+// that must change their password.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+	userData := user.(*auth.UserData)
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+	if req.NewPassword != req.ConfirmPassword {
+		apierror.Respond(c, apierror.BadRequest("as senhas não coincidem"))
+		return
+	}
+	if err := validation.ValidatePassword(req.NewPassword, userData.Identifier); err != nil {
+		apierror.Respond(c, apierror.Validation(err.Error()))
+		return
+	}
+
+	sessionID, _ := c.Get("sessionID")
+	currentSession, _ := sessionID.(string)
+	if err := h.authService.ChangePassword(requestContext(c), userData.ID, currentSession, req.CurrentPassword, req.NewPassword); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrWrongPassword),
+			errors.Is(err, service.ErrPasswordReused),
+			errors.Is(err, service.ErrPasswordTooLong):
+			apierror.Respond(c, apierror.BadRequest(err.Error()))
+		default:
+			internalError(c, err, "falha ao alterar senha")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "senha alterada com sucesso"})
+}
+
+// ValidateResetToken reports whether the token query parameter is a valid,
+// unexpired reset token, so the frontend only shows the reset form when the
+// submit can succeed. The token is not consumed.
+func (h *AuthHandler) ValidateResetToken(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		apierror.Respond(c, apierror.BadRequest("token é obrigatório"))
+		return
+	}
+
+	err := h.authService.ValidateResetToken(requestContext(c), token)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		if !errors.Is(err, service.ErrInvalidToken) && !errors.Is(err, service.ErrExpiredToken) {
+			internalError(c, err, "falha ao validar token", "ip", getClientIP(c))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"valid": err == nil})
+}
+
+// PasswordPolicy returns the password rules in effect, so the frontend can
+// show them before submission. Public: the rules aren't sensitive.
+func (h *AuthHandler) PasswordPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, h.authService.PasswordPolicy())
+}
+
+// GetCurrentUser returns the currently authenticated user. The response
+// carries an ETag derived from the user's updated_at and is answered with
+// 304 Not Modified when If-None-Match matches it.
 func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "não autenticado"})
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	userData := user.(*auth.UserData)
+	response := dto.NewAuthUserResponse(userData)
+	response.AvatarURL = h.avatarURL(c, userData.AvatarKey())
+	// Polling clients revalidate with If-None-Match; the profile only changes
+	// when the user row does, or when the avatar URL is signed again
+	if updatedAt, ok := userData.Attributes["updated_at"].(time.Time); ok {
+		if middleware.NotModified(c, middleware.VersionETag(userData.ID, updatedAt.UTC().Format(time.RFC3339Nano), response.AvatarURL)) {
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateLocale sets the language the authenticated user's emails are sent in
+func (h *AuthHandler) UpdateLocale(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	var req UpdateLocaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	if err := h.authService.SetLocale(requestContext(c), userID.(string), req.Locale); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao alterar idioma")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"locale": req.Locale})
+}
+
+// ExportAccount returns all data stored about the authenticated user as a
+// downloadable JSON document. Secrets (password hash, reset token, session IDs)
+// are left out by the DTO.
+func (h *AuthHandler) ExportAccount(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	export, err := h.authService.ExportAccount(requestContext(c), userID.(string))
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao exportar dados da conta", "user_id", userID, "ip", getClientIP(c))
+		return
+	}
+
+	currentSessionID := c.GetString("sessionID")
+	now := time.Now()
+	filename := fmt.Sprintf("account-export-%s-%s.json", userID, now.UTC().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.JSON(http.StatusOK, dto.NewAccountExportResponse(export.User, export.Sessions, export.LinkedAccounts, export.APIKeys, currentSessionID, now))
+}
+
+// ListSessions lists the active sessions of the authenticated user, each with
+// the id accepted by RevokeSession and the one in use flagged current
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(requestContext(c), userID.(string))
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao listar sessões", "user_id", userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewSessionListResponse(sessions, c.GetString("sessionID")))
+}
+
+// RevokeSession ends one session of the authenticated user, given the id
+// returned by ListSessions. Revoking the current session logs the user out.
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	handle := c.Param("id")
+	if err := h.authService.RevokeSession(requestContext(c), userID.(string), handle); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSessionNotFound) {
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+			return
+		}
+		internalError(c, err, "falha ao revogar sessão", "user_id", userID, "ip", getClientIP(c))
+		return
+	}
+
+	if handle == auth.SessionHandle(c.GetString("sessionID")) {
+		middleware.ClearSessionCookie(c)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "sessão revogada"})
+}
+
+// RevokeOtherSessions ends every session of the authenticated user except the
+// one making the request
+func (h *AuthHandler) RevokeOtherSessions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	revocations, err := h.authService.RevokeOtherSessions(requestContext(c), userID.(string), c.GetString("sessionID"))
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao revogar sessões", "user_id", userID, "ip", getClientIP(c))
+		return
+	}
+
+	// Sessions are identified by their public handle, as in ListSessions
+	batch := dto.NewBatchResult()
+	for i, revocation := range revocations {
+		handle := auth.SessionHandle(revocation.SessionID)
+		if revocation.Err != nil {
+			batch.Fail(i, handle, errors.New("falha ao revogar sessão"))
+			continue
+		}
+		batch.Succeed(i, handle)
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// DeleteAccount schedules the deletion of the authenticated user's account,
+// confirmed with their password. The user is logged out everywhere and can
+// restore the account with the emailed link until the deletion date.
+func (h *AuthHandler) DeleteAccount(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	deleteAt, err := h.authService.DeleteAccount(requestContext(c), userID.(string), req.Password)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrWrongPassword) {
+			apierror.Respond(c, apierror.BadRequest(err.Error()))
+			return
+		}
+		internalError(c, err, "falha ao excluir conta")
+		return
+	}
+
+	middleware.ClearSessionCookie(c)
+	c.JSON(http.StatusOK, gin.H{
+		"message":               "exclusão da conta agendada",
+		"scheduled_deletion_at": dto.NewTimestamp(deleteAt),
+	})
+}
+
+// RestoreAccount cancels a scheduled account deletion with the token from
+// the deletion email. Public: the user has no session until restored.
+func (h *AuthHandler) RestoreAccount(c *gin.Context) {
+	var req RestoreAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	if err := h.authService.RestoreAccount(requestContext(c), req.Token); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidToken):
+			apierror.Respond(c, apierror.BadRequest("token inválido"))
+		case errors.Is(err, service.ErrExpiredToken):
+			apierror.Respond(c, apierror.BadRequest("prazo para restaurar a conta encerrado"))
+		default:
+			internalError(c, err, "falha ao restaurar conta", "ip", getClientIP(c))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "conta restaurada com sucesso"})
+}
+
+// VerifyEmail marks the address a verification link was sent to as verified
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	if err := h.authService.VerifyEmail(requestContext(c), req.Token); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidToken):
+			apierror.Respond(c, apierror.BadRequest("token inválido"))
+		case errors.Is(err, service.ErrExpiredToken):
+			apierror.Respond(c, apierror.BadRequest("link de verificação expirado, solicite um novo"))
+		case errors.Is(err, service.ErrEmailVerificationDisabled):
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+		default:
+			internalError(c, err, "falha ao verificar email", "ip", getClientIP(c))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verificado com sucesso"})
+}
+
+// ResendVerification emails a new verification link. The response is the
+// same whether or not the address belongs to an unverified account.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.FromContext(requestContext(c)).Debug("Requisição de reenvio de verificação com JSON inválido", "error", err, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.FromBinding(err))
 		return
 	}
 
-	c.JSON(http.StatusOK, user.(*auth.UserData))
+	if err := h.authService.ResendVerificationEmail(requestContext(c), req.Email); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrEmailVerificationDisabled) {
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+			return
+		}
+		internalError(c, err, "falha ao reenviar verificação", "ip", getClientIP(c))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "se o email precisar de verificação, um novo link será enviado"})
+}
+
+// Impersonate starts an impersonation session as the user in the :user_id
+// path parameter. Admin only; the new session replaces the session cookie.
+func (h *AuthHandler) Impersonate(c *gin.Context) {
+	sessionID := c.GetString("sessionID")
+	if sessionID == "" {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	ip := getClientIP(c)
+	userAgent := ""
+	if c.Request != nil {
+		userAgent = c.Request.UserAgent()
+	}
+
+	response, err := h.authService.Impersonate(requestContext(c), sessionID, c.Param("user_id"), ip, userAgent)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrForbidden):
+			apierror.Respond(c, apierror.Forbidden(err.Error()))
+		case errors.Is(err, service.ErrUserNotFound):
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+		case errors.Is(err, service.ErrUserNotActive):
+			apierror.Respond(c, apierror.BadRequest("usuário inativo"))
+		case errors.Is(err, service.ErrInvalidToken):
+			apierror.Respond(c, apierror.Unauthorized("sessão inválida"))
+		default:
+			internalError(c, err, "falha ao iniciar impersonação")
+		}
+		return
+	}
+
+	body := sessionResponse(c, response)
+	body.ImpersonatorID = response.ImpersonatorID
+	c.JSON(http.StatusOK, body)
+}
+
+// EndImpersonation ends the current impersonation session and restores the
+// admin session it was started from
+func (h *AuthHandler) EndImpersonation(c *gin.Context) {
+	sessionID := c.GetString("sessionID")
+	if sessionID == "" {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	response, err := h.authService.EndImpersonation(requestContext(c), sessionID)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrNotImpersonating):
+			apierror.Respond(c, apierror.BadRequest(err.Error()))
+		case errors.Is(err, service.ErrInvalidToken):
+			// The admin session is gone, so the client must log in again
+			middleware.ClearSessionCookie(c)
+			apierror.Respond(c, apierror.Unauthorized("sessão do administrador expirada"))
+		default:
+			internalError(c, err, "falha ao encerrar impersonação")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, sessionResponse(c, response))
+}
+
+// CSRFToken returns the CSRF token to send in the X-CSRF-Token header, for
+// clients on another origin that can't read the csrf_token cookie. Only
+// registered with the cookie token transport.
+func (h *AuthHandler) CSRFToken(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"csrf_token": middleware.CSRFToken(c)})
+}
+
+// sessionResponse sets the cookies of a new session and builds the response
+// body. With the cookie token transport the session and refresh tokens are
+// only in HttpOnly cookies, out of reach of scripts, and left out of the body.
+func sessionResponse(c *gin.Context, response *service.LoginResponse) dto.LoginResponse {
+	middleware.SetSessionCookie(c, response.SessionID, response.ExpiresAt)
+	middleware.SetRefreshTokenCookie(c, response.RefreshToken)
+
+	body := dto.NewLoginResponse(response.SessionID, response.ExpiresAt, &response.User)
+	body.RefreshToken = response.RefreshToken
+	if middleware.CookieOptionsFrom(c).TokensInCookies {
+		body.SessionID, body.RefreshToken = "", ""
+	}
+	return body
+}
+
+// registrationLocale returns the email language of a new user: the one in the
+// request body if given, otherwise the preferred valid language of the
+// Accept-Language header, or "" for the default
+func registrationLocale(c *gin.Context, requested string) string {
+	if requested != "" || c.Request == nil {
+		return requested
+	}
+
+	best, bestQ := "", 0.0
+	for _, entry := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > bestQ && tag != "" && validation.ValidateLocale(tag) == nil {
+			best, bestQ = tag, q
+		}
+	}
+	return best
 }
 
 // getClientIP safely gets the client IP from the context
@@ -3,58 +3,217 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"gosveltekit/internal/auth"
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/email"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/middleware"
 	"gosveltekit/internal/models"
 	"gosveltekit/internal/service"
+	"gosveltekit/internal/validation"
 
 	"github.com/gin-gonic/gin"
 )
 
 // MockAuthService implements the service.AuthServiceInterface interface
 type MockAuthService struct {
-	LoginFunc                func(username, password, ip, userAgent string) (*service.LoginResponse, error)
-	ValidateSessionFunc      func(sessionID string) (*auth.Session, *auth.UserData, error)
-	LogoutFunc               func(sessionID string) error
-	LogoutAllFunc            func(userID string) error
-	RegisterFunc             func(username, email, password, displayName string) (*models.User, error)
-	RequestPasswordResetFunc func(email string) error
-	ResetPasswordFunc        func(token, newPassword string) error
+	LoginFunc                     func(ctx context.Context, username, password, ip, userAgent string) (*service.LoginResponse, error)
+	ValidateSessionFunc           func(ctx context.Context, sessionID string) (*auth.Session, *auth.UserData, error)
+	LogoutFunc                    func(ctx context.Context, sessionID string) error
+	LogoutAllFunc                 func(ctx context.Context, userID string) error
+	RevokeTokenFunc               func(ctx context.Context, userID, token string) error
+	RegisterFunc                  func(ctx context.Context, username, email, password, displayName, captchaToken, ip string) (*models.User, bool, error)
+	EmailAvailableFunc            func(ctx context.Context, email string) (bool, error)
+	RequestPasswordResetFunc      func(ctx context.Context, email string) error
+	AdminRequestPasswordResetFunc func(ctx context.Context, actorID, userID string) error
+	VerifyEmailFunc               func(ctx context.Context, token string) error
+	ResendVerificationEmailFunc   func(ctx context.Context, email string) error
+	ResetPasswordFunc             func(ctx context.Context, token, newPassword string) error
+	ValidateResetTokenFunc        func(ctx context.Context, token string) error
+	ChangePasswordFunc            func(ctx context.Context, userID, sessionID, currentPassword, newPassword string) error
+	SetLocaleFunc                 func(ctx context.Context, userID, locale string) error
+	SetAvatarFunc                 func(ctx context.Context, userID, key string) (string, error)
+	ExportAccountFunc             func(ctx context.Context, userID string) (*service.AccountExport, error)
+	ImpersonateFunc               func(ctx context.Context, adminSessionID, targetUserID, ip, userAgent string) (*service.LoginResponse, error)
+	EndImpersonationFunc          func(ctx context.Context, sessionID string) (*service.LoginResponse, error)
+	PasswordPolicyFunc            func() validation.PasswordPolicy
+	DeleteAccountFunc             func(ctx context.Context, userID, password string) (time.Time, error)
+	RestoreAccountFunc            func(ctx context.Context, token string) error
+	CompleteTwoFactorLoginFunc    func(ctx context.Context, challengeToken, code, ip string) (*service.LoginResponse, error)
+	EnrollTOTPFunc                func(ctx context.Context, userID string) (*auth.TOTPEnrollment, error)
+	ConfirmTOTPFunc               func(ctx context.Context, userID, code string) ([]string, error)
+	DisableTOTPFunc               func(ctx context.Context, userID, password string) error
+	SetPhoneNumberFunc            func(ctx context.Context, userID, phoneNumber string) error
+	VerifyPhoneNumberFunc         func(ctx context.Context, userID, code string) error
+	SetTwoFactorMethodFunc        func(ctx context.Context, userID, method string) error
+	DisableSMSTwoFactorFunc       func(ctx context.Context, userID, password string) error
+	ResendTwoFactorCodeFunc       func(ctx context.Context, challengeToken string) error
+	RefreshFunc                   func(ctx context.Context, refreshToken, ip, userAgent string) (*service.LoginResponse, error)
+	ListSessionsFunc              func(ctx context.Context, userID string) ([]*auth.Session, error)
+	RevokeSessionFunc             func(ctx context.Context, userID, handle string) error
+	RevokeOtherSessionsFunc       func(ctx context.Context, userID, currentSessionID string) ([]auth.SessionRevocation, error)
+	RequestMagicLinkFunc          func(ctx context.Context, email, ip string) error
+	LoginWithMagicLinkFunc        func(ctx context.Context, token, ip, userAgent string) (*service.LoginResponse, error)
 }
 
-func (m *MockAuthService) Login(username, password, ip, userAgent string) (*service.LoginResponse, error) {
-	return m.LoginFunc(username, password, ip, userAgent)
+func (m *MockAuthService) Login(ctx context.Context, username, password, ip, userAgent string) (*service.LoginResponse, error) {
+	return m.LoginFunc(ctx, username, password, ip, userAgent)
 }
 
-func (m *MockAuthService) ValidateSession(sessionID string) (*auth.Session, *auth.UserData, error) {
-	return m.ValidateSessionFunc(sessionID)
+func (m *MockAuthService) ValidateSession(ctx context.Context, sessionID string) (*auth.Session, *auth.UserData, error) {
+	return m.ValidateSessionFunc(ctx, sessionID)
 }
 
-func (m *MockAuthService) Logout(sessionID string) error {
-	return m.LogoutFunc(sessionID)
+func (m *MockAuthService) Logout(ctx context.Context, sessionID string) error {
+	return m.LogoutFunc(ctx, sessionID)
 }
 
-func (m *MockAuthService) LogoutAll(userID string) error {
-	return m.LogoutAllFunc(userID)
+func (m *MockAuthService) LogoutAll(ctx context.Context, userID string) error {
+	return m.LogoutAllFunc(ctx, userID)
 }
 
-func (m *MockAuthService) Register(username, email, password, displayName string) (*models.User, error) {
-	return m.RegisterFunc(username, email, password, displayName)
+func (m *MockAuthService) RevokeToken(ctx context.Context, userID, token string) error {
+	return m.RevokeTokenFunc(ctx, userID, token)
 }
 
-func (m *MockAuthService) RequestPasswordReset(email string) error {
-	return m.RequestPasswordResetFunc(email)
+func (m *MockAuthService) Register(ctx context.Context, username, email, password, displayName, captchaToken, ip string) (*models.User, bool, error) {
+	return m.RegisterFunc(ctx, username, email, password, displayName, captchaToken, ip)
 }
 
-func (m *MockAuthService) ResetPassword(token, newPassword string) error {
-	return m.ResetPasswordFunc(token, newPassword)
+func (m *MockAuthService) EmailAvailable(ctx context.Context, email string) (bool, error) {
+	return m.EmailAvailableFunc(ctx, email)
+}
+
+func (m *MockAuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	return m.RequestPasswordResetFunc(ctx, email)
+}
+
+func (m *MockAuthService) AdminRequestPasswordReset(ctx context.Context, actorID, userID string) error {
+	return m.AdminRequestPasswordResetFunc(ctx, actorID, userID)
+}
+
+func (m *MockAuthService) VerifyEmail(ctx context.Context, token string) error {
+	return m.VerifyEmailFunc(ctx, token)
+}
+
+func (m *MockAuthService) ResendVerificationEmail(ctx context.Context, email string) error {
+	return m.ResendVerificationEmailFunc(ctx, email)
+}
+
+func (m *MockAuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	return m.ResetPasswordFunc(ctx, token, newPassword)
+}
+
+func (m *MockAuthService) ValidateResetToken(ctx context.Context, token string) error {
+	return m.ValidateResetTokenFunc(ctx, token)
+}
+
+func (m *MockAuthService) ChangePassword(ctx context.Context, userID, sessionID, currentPassword, newPassword string) error {
+	return m.ChangePasswordFunc(ctx, userID, sessionID, currentPassword, newPassword)
+}
+
+func (m *MockAuthService) SetLocale(ctx context.Context, userID, locale string) error {
+	return m.SetLocaleFunc(ctx, userID, locale)
+}
+
+func (m *MockAuthService) SetAvatar(ctx context.Context, userID, key string) (string, error) {
+	return m.SetAvatarFunc(ctx, userID, key)
+}
+
+func (m *MockAuthService) ExportAccount(ctx context.Context, userID string) (*service.AccountExport, error) {
+	return m.ExportAccountFunc(ctx, userID)
+}
+
+func (m *MockAuthService) ListSessions(ctx context.Context, userID string) ([]*auth.Session, error) {
+	return m.ListSessionsFunc(ctx, userID)
+}
+
+func (m *MockAuthService) RevokeSession(ctx context.Context, userID, handle string) error {
+	return m.RevokeSessionFunc(ctx, userID, handle)
+}
+
+func (m *MockAuthService) RevokeOtherSessions(ctx context.Context, userID, currentSessionID string) ([]auth.SessionRevocation, error) {
+	return m.RevokeOtherSessionsFunc(ctx, userID, currentSessionID)
+}
+
+func (m *MockAuthService) Impersonate(ctx context.Context, adminSessionID, targetUserID, ip, userAgent string) (*service.LoginResponse, error) {
+	return m.ImpersonateFunc(ctx, adminSessionID, targetUserID, ip, userAgent)
+}
+
+func (m *MockAuthService) EndImpersonation(ctx context.Context, sessionID string) (*service.LoginResponse, error) {
+	return m.EndImpersonationFunc(ctx, sessionID)
+}
+
+func (m *MockAuthService) PasswordPolicy() validation.PasswordPolicy {
+	return m.PasswordPolicyFunc()
+}
+
+func (m *MockAuthService) DeleteAccount(ctx context.Context, userID, password string) (time.Time, error) {
+	return m.DeleteAccountFunc(ctx, userID, password)
+}
+
+func (m *MockAuthService) RestoreAccount(ctx context.Context, token string) error {
+	return m.RestoreAccountFunc(ctx, token)
+}
+
+func (m *MockAuthService) CompleteTwoFactorLogin(ctx context.Context, challengeToken, code, ip string) (*service.LoginResponse, error) {
+	return m.CompleteTwoFactorLoginFunc(ctx, challengeToken, code, ip)
+}
+
+func (m *MockAuthService) Refresh(ctx context.Context, refreshToken, ip, userAgent string) (*service.LoginResponse, error) {
+	return m.RefreshFunc(ctx, refreshToken, ip, userAgent)
+}
+
+func (m *MockAuthService) RequestMagicLink(ctx context.Context, email, ip string) error {
+	return m.RequestMagicLinkFunc(ctx, email, ip)
+}
+
+func (m *MockAuthService) LoginWithMagicLink(ctx context.Context, token, ip, userAgent string) (*service.LoginResponse, error) {
+	return m.LoginWithMagicLinkFunc(ctx, token, ip, userAgent)
+}
+
+func (m *MockAuthService) EnrollTOTP(ctx context.Context, userID string) (*auth.TOTPEnrollment, error) {
+	return m.EnrollTOTPFunc(ctx, userID)
+}
+
+func (m *MockAuthService) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	return m.ConfirmTOTPFunc(ctx, userID, code)
+}
+
+func (m *MockAuthService) DisableTOTP(ctx context.Context, userID, password string) error {
+	return m.DisableTOTPFunc(ctx, userID, password)
+}
+
+func (m *MockAuthService) SetPhoneNumber(ctx context.Context, userID, phoneNumber string) error {
+	return m.SetPhoneNumberFunc(ctx, userID, phoneNumber)
+}
+
+func (m *MockAuthService) VerifyPhoneNumber(ctx context.Context, userID, code string) error {
+	return m.VerifyPhoneNumberFunc(ctx, userID, code)
+}
+
+func (m *MockAuthService) SetTwoFactorMethod(ctx context.Context, userID, method string) error {
+	return m.SetTwoFactorMethodFunc(ctx, userID, method)
+}
+
+func (m *MockAuthService) DisableSMSTwoFactor(ctx context.Context, userID, password string) error {
+	return m.DisableSMSTwoFactorFunc(ctx, userID, password)
+}
+
+func (m *MockAuthService) ResendTwoFactorCode(ctx context.Context, challengeToken string) error {
+	return m.ResendTwoFactorCodeFunc(ctx, challengeToken)
 }
 
 func setupTestRouter() (*gin.Context, *httptest.ResponseRecorder) {
@@ -96,7 +255,7 @@ func TestAuthHandler_Login(t *testing.T) {
 				Password: "password123",
 			},
 			setupMock: func(m *MockAuthService) {
-				m.LoginFunc = func(username, password, ip, userAgent string) (*service.LoginResponse, error) {
+				m.LoginFunc = func(ctx context.Context, username, password, ip, userAgent string) (*service.LoginResponse, error) {
 					return &service.LoginResponse{
 						SessionID: "test-session-id",
 						ExpiresAt: time.Now().Add(time.Hour),
@@ -119,7 +278,7 @@ func TestAuthHandler_Login(t *testing.T) {
 				Password: "wrongpass",
 			},
 			setupMock: func(m *MockAuthService) {
-				m.LoginFunc = func(username, password, ip, userAgent string) (*service.LoginResponse, error) {
+				m.LoginFunc = func(ctx context.Context, username, password, ip, userAgent string) (*service.LoginResponse, error) {
 					return nil, service.ErrInvalidCredentials
 				}
 			},
@@ -135,7 +294,7 @@ func TestAuthHandler_Login(t *testing.T) {
 				Password: "password123",
 			},
 			setupMock: func(m *MockAuthService) {
-				m.LoginFunc = func(username, password, ip, userAgent string) (*service.LoginResponse, error) {
+				m.LoginFunc = func(ctx context.Context, username, password, ip, userAgent string) (*service.LoginResponse, error) {
 					return nil, service.ErrUserNotActive
 				}
 			},
@@ -151,7 +310,7 @@ func TestAuthHandler_Login(t *testing.T) {
 				Password: "password123",
 			},
 			setupMock: func(m *MockAuthService) {
-				m.LoginFunc = func(username, password, ip, userAgent string) (*service.LoginResponse, error) {
+				m.LoginFunc = func(ctx context.Context, username, password, ip, userAgent string) (*service.LoginResponse, error) {
 					return nil, errors.New("conta temporariamente bloqueada, tente novamente mais tarde")
 				}
 			},
@@ -160,6 +319,57 @@ func TestAuthHandler_Login(t *testing.T) {
 				"error": "conta temporariamente bloqueada, tente novamente mais tarde",
 			},
 		},
+		{
+			name: "Account pending deletion",
+			request: LoginRequest{
+				Username: "leaving",
+				Password: "password123",
+			},
+			setupMock: func(m *MockAuthService) {
+				m.LoginFunc = func(ctx context.Context, username, password, ip, userAgent string) (*service.LoginResponse, error) {
+					return nil, service.ErrAccountPendingDeletion
+				}
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody: map[string]interface{}{
+				"error": service.ErrAccountPendingDeletion.Error(),
+			},
+		},
+		{
+			name: "Too many sessions",
+			request: LoginRequest{
+				Username: "busy",
+				Password: "password123",
+			},
+			setupMock: func(m *MockAuthService) {
+				m.LoginFunc = func(ctx context.Context, username, password, ip, userAgent string) (*service.LoginResponse, error) {
+					return nil, service.ErrTooManySessions
+				}
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody: map[string]interface{}{
+				"error": service.ErrTooManySessions.Error(),
+			},
+		},
+		{
+			name: "Two-factor challenge",
+			request: LoginRequest{
+				Username: "totpuser",
+				Password: "password123",
+			},
+			setupMock: func(m *MockAuthService) {
+				m.LoginFunc = func(ctx context.Context, username, password, ip, userAgent string) (*service.LoginResponse, error) {
+					return &service.LoginResponse{
+						TwoFactor: &service.TwoFactorChallenge{Token: "challenge-token", ExpiresAt: time.Now().Add(5 * time.Minute)},
+					}, nil
+				}
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedBody: map[string]interface{}{
+				"two_factor_required": true,
+				"challenge_token":     "challenge-token",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -203,6 +413,43 @@ func TestAuthHandler_Login(t *testing.T) {
 	}
 }
 
+func TestAuthHandler_Login_ClientCanceled(t *testing.T) {
+	var logs bytes.Buffer
+	logger.SetLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer logger.Init("info", "text")
+
+	c, w := setupTestRouter()
+	mockService := &MockAuthService{
+		LoginFunc: func(ctx context.Context, username, password, ip, userAgent string) (*service.LoginResponse, error) {
+			// The DB call would fail with the request's context error
+			return nil, ctx.Err()
+		},
+	}
+	handler := NewAuthHandler(mockService)
+
+	jsonData, _ := json.Marshal(map[string]string{"username": "testuser", "password": "Password123!"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/auth/login", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+
+	handler.Login(c)
+
+	if c.Writer.Written() || w.Body.Len() != 0 {
+		t.Errorf("expected no response to be written, got %q", w.Body.String())
+	}
+	if !c.IsAborted() {
+		t.Error("expected context to be aborted")
+	}
+	if !strings.Contains(logs.String(), "level=DEBUG") {
+		t.Errorf("expected a debug log entry, got %q", logs.String())
+	}
+	if strings.Contains(logs.String(), "level=ERROR") {
+		t.Errorf("expected no error log entry, got %q", logs.String())
+	}
+}
+
 func TestAuthHandler_Logout(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -218,7 +465,7 @@ func TestAuthHandler_Logout(t *testing.T) {
 				c.Set("sessionID", "valid-session")
 			},
 			setupMock: func(m *MockAuthService) {
-				m.LogoutFunc = func(sessionID string) error {
+				m.LogoutFunc = func(ctx context.Context, sessionID string) error {
 					return nil
 				}
 			},
@@ -233,7 +480,7 @@ func TestAuthHandler_Logout(t *testing.T) {
 				// Don't set sessionID
 			},
 			setupMock: func(m *MockAuthService) {
-				m.LogoutFunc = func(sessionID string) error {
+				m.LogoutFunc = func(ctx context.Context, sessionID string) error {
 					return nil
 				}
 			},
@@ -285,9 +532,11 @@ func TestAuthHandler_Register(t *testing.T) {
 	tests := []struct {
 		name           string
 		request        RegistrationRequest
+		opts           []AuthHandlerOption
 		setupMock      func(*MockAuthService)
 		expectedStatus int
 		expectedBody   map[string]interface{}
+		expectSession  bool
 	}{
 		{
 			name: "Successful registration",
@@ -298,12 +547,12 @@ func TestAuthHandler_Register(t *testing.T) {
 				DisplayName: "New User",
 			},
 			setupMock: func(m *MockAuthService) {
-				m.RegisterFunc = func(username, email, password, displayName string) (*models.User, error) {
+				m.RegisterFunc = func(ctx context.Context, username, email, password, displayName, captchaToken, ip string) (*models.User, bool, error) {
 					return &models.User{
 						Username:    username,
 						Email:       email,
 						DisplayName: displayName,
-					}, nil
+					}, false, nil
 				}
 			},
 			expectedStatus: http.StatusOK,
@@ -312,6 +561,80 @@ func TestAuthHandler_Register(t *testing.T) {
 				"email":    "new@example.com",
 			},
 		},
+		{
+			name: "Auto-login after registration",
+			request: RegistrationRequest{
+				Username:    "newuser",
+				Email:       "new@example.com",
+				Password:    "Padasdasdasdd123!",
+				DisplayName: "New User",
+			},
+			opts: []AuthHandlerOption{WithRegistrationResponse(true, false)},
+			setupMock: func(m *MockAuthService) {
+				m.RegisterFunc = func(ctx context.Context, username, email, password, displayName, captchaToken, ip string) (*models.User, bool, error) {
+					return &models.User{Username: username, Email: email, DisplayName: displayName}, true, nil
+				}
+				m.LoginFunc = func(ctx context.Context, username, password, ip, userAgent string) (*service.LoginResponse, error) {
+					if username != "newuser" || password != "Padasdasdasdd123!" {
+						return nil, errors.New("credenciais inválidas")
+					}
+					return &service.LoginResponse{
+						SessionID: "new-session",
+						ExpiresAt: time.Now().Add(time.Hour),
+						User:      auth.UserData{ID: "1", Identifier: username},
+					}, nil
+				}
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				"session_id": "new-session",
+			},
+			expectSession: true,
+		},
+		{
+			name: "Verification required creates no session",
+			request: RegistrationRequest{
+				Username:    "newuser",
+				Email:       "new@example.com",
+				Password:    "Padasdasdasdd123!",
+				DisplayName: "New User",
+			},
+			opts: []AuthHandlerOption{WithRegistrationResponse(true, true)},
+			setupMock: func(m *MockAuthService) {
+				m.RegisterFunc = func(ctx context.Context, username, email, password, displayName, captchaToken, ip string) (*models.User, bool, error) {
+					return &models.User{Username: username, Email: email, DisplayName: displayName}, true, nil
+				}
+				m.LoginFunc = func(ctx context.Context, username, password, ip, userAgent string) (*service.LoginResponse, error) {
+					t.Error("Login must not be called when verification is required")
+					return nil, errors.New("unexpected login")
+				}
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				"verification_required":   true,
+				"verification_email_sent": true,
+			},
+		},
+		{
+			name: "Verification email not sent",
+			request: RegistrationRequest{
+				Username:    "newuser",
+				Email:       "new@example.com",
+				Password:    "Padasdasdasdd123!",
+				DisplayName: "New User",
+			},
+			opts: []AuthHandlerOption{WithRegistrationResponse(false, true)},
+			setupMock: func(m *MockAuthService) {
+				m.RegisterFunc = func(ctx context.Context, username, email, password, displayName, captchaToken, ip string) (*models.User, bool, error) {
+					return &models.User{Username: username, Email: email, DisplayName: displayName}, false, nil
+				}
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				"verification_required":   true,
+				"verification_email_sent": false,
+			},
+		},
 		{
 			name: "Username already exists",
 			request: RegistrationRequest{
@@ -321,8 +644,8 @@ func TestAuthHandler_Register(t *testing.T) {
 				DisplayName: "New User",
 			},
 			setupMock: func(m *MockAuthService) {
-				m.RegisterFunc = func(username, email, password, displayName string) (*models.User, error) {
-					return nil, errors.New("username already exists")
+				m.RegisterFunc = func(ctx context.Context, username, email, password, displayName, captchaToken, ip string) (*models.User, bool, error) {
+					return nil, false, errors.New("username already exists")
 				}
 			},
 			expectedStatus: http.StatusBadRequest,
@@ -330,6 +653,24 @@ func TestAuthHandler_Register(t *testing.T) {
 				"error": "username already exists",
 			},
 		},
+		{
+			name: "Registration email unavailable",
+			request: RegistrationRequest{
+				Username:    "newuser",
+				Email:       "new@example.com",
+				Password:    "Padasdasdasdd123!",
+				DisplayName: "New User",
+			},
+			setupMock: func(m *MockAuthService) {
+				m.RegisterFunc = func(ctx context.Context, username, email, password, displayName, captchaToken, ip string) (*models.User, bool, error) {
+					return nil, false, service.ErrRegistrationEmail
+				}
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody: map[string]interface{}{
+				"error": service.ErrRegistrationEmail.Error(),
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -339,7 +680,7 @@ func TestAuthHandler_Register(t *testing.T) {
 			tt.setupMock(mockService)
 
 			var authService service.AuthServiceInterface = mockService
-			handler := NewAuthHandler(authService)
+			handler := NewAuthHandler(authService, tt.opts...)
 
 			// Setup request
 			jsonData, _ := json.Marshal(tt.request)
@@ -368,6 +709,57 @@ func TestAuthHandler_Register(t *testing.T) {
 					t.Errorf("expected %s to be %v, got %v", key, expectedValue, actualValue)
 				}
 			}
+
+			hasCookie := strings.Contains(w.Header().Get("Set-Cookie"), "new-session")
+			if hasCookie != tt.expectSession {
+				t.Errorf("expected session cookie %v, got Set-Cookie %q", tt.expectSession, w.Header().Get("Set-Cookie"))
+			}
+		})
+	}
+}
+
+func TestAuthHandler_CheckEmail(t *testing.T) {
+	const generic = `{"message":"email válido, a disponibilidade será confirmada no cadastro"}`
+
+	tests := []struct {
+		name           string
+		body           string
+		reveal         bool
+		available      bool
+		serviceErr     error
+		expectedStatus int
+		expectedBody   string
+	}{
+		// EmailAvailableFunc is left nil without reveal: calling it would panic
+		{name: "Generic Answer", body: `{"email":"taken@example.com"}`, expectedStatus: http.StatusOK, expectedBody: generic},
+		{name: "Invalid Email", body: `{"email":"not-an-email"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Available", body: `{"email":"free@example.com"}`, reveal: true, available: true, expectedStatus: http.StatusOK, expectedBody: `{"available":true}`},
+		{name: "Taken", body: `{"email":"taken@example.com"}`, reveal: true, expectedStatus: http.StatusOK, expectedBody: `{"available":false}`},
+		{name: "Service Error", body: `{"email":"free@example.com"}`, reveal: true, serviceErr: errors.New("database down"), expectedStatus: http.StatusInternalServerError, expectedBody: `{"error":"falha ao verificar email"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			mockService := &MockAuthService{}
+			if tt.reveal {
+				mockService.EmailAvailableFunc = func(ctx context.Context, email string) (bool, error) {
+					return tt.available, tt.serviceErr
+				}
+			}
+			handler := NewAuthHandler(mockService, WithEmailAvailability(tt.reveal))
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/auth/check-email", bytes.NewBufferString(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handler.CheckEmail(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedBody != "" && !jsonContains(t, w.Body.Bytes(), tt.expectedBody) {
+				t.Errorf("expected body %s, got %s", tt.expectedBody, w.Body.String())
+			}
 		})
 	}
 }
@@ -387,7 +779,7 @@ func TestAuthHandler_RequestPasswordReset(t *testing.T) {
 				"email": "test@example.com",
 			},
 			setupMock: func(m *MockAuthService) {
-				m.RequestPasswordResetFunc = func(email string) error {
+				m.RequestPasswordResetFunc = func(ctx context.Context, email string) error {
 					return nil
 				}
 			},
@@ -402,7 +794,7 @@ func TestAuthHandler_RequestPasswordReset(t *testing.T) {
 				"email": "invalid-email",
 			},
 			setupMock: func(m *MockAuthService) {
-				m.RequestPasswordResetFunc = func(email string) error {
+				m.RequestPasswordResetFunc = func(ctx context.Context, email string) error {
 					return errors.New("should not be called")
 				}
 			},
@@ -462,11 +854,114 @@ func TestAuthHandler_RequestPasswordReset(t *testing.T) {
 	}
 }
 
+func TestAuthHandler_RevokeToken(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         string
+		body           string
+		serviceErr     error
+		expectedStatus int
+		expectCleared  bool
+	}{
+		{name: "other session", userID: "1", body: `{"token":"other-session"}`, expectedStatus: http.StatusOK},
+		{name: "current session clears the cookie", userID: "1", body: `{"token":"current-session"}`, expectedStatus: http.StatusOK, expectCleared: true},
+		{name: "not the owner", userID: "1", body: `{"token":"other-session"}`, serviceErr: service.ErrForbidden, expectedStatus: http.StatusForbidden},
+		{name: "missing token", userID: "1", body: `{}`, expectedStatus: http.StatusBadRequest},
+		{name: "anonymous", body: `{"token":"other-session"}`, expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			mockService := &MockAuthService{
+				RevokeTokenFunc: func(ctx context.Context, userID, token string) error {
+					if userID != tt.userID {
+						t.Errorf("expected user %q, got %q", tt.userID, userID)
+					}
+					return tt.serviceErr
+				},
+			}
+			handler := NewAuthHandler(mockService)
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/auth/revoke", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			if tt.userID != "" {
+				c.Set("userID", tt.userID)
+				c.Set("sessionID", "current-session")
+			}
+			handler.RevokeToken(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			cleared := strings.Contains(w.Header().Get("Set-Cookie"), "Max-Age=0")
+			if cleared != tt.expectCleared {
+				t.Errorf("expected cookie cleared %v, got Set-Cookie %q", tt.expectCleared, w.Header().Get("Set-Cookie"))
+			}
+		})
+	}
+}
+
+func TestAuthHandler_AdminRequestPasswordReset(t *testing.T) {
+	tests := []struct {
+		name           string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{name: "success", expectedStatus: http.StatusOK},
+		{name: "unknown user", serviceErr: service.ErrUserNotFound, expectedStatus: http.StatusNotFound},
+		{name: "service failure", serviceErr: errors.New("db down"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			mockService := &MockAuthService{
+				AdminRequestPasswordResetFunc: func(ctx context.Context, actorID, userID string) error {
+					if actorID != "1" || userID != "7" {
+						t.Errorf("expected actor 1 and user 7, got %q and %q", actorID, userID)
+					}
+					return tt.serviceErr
+				},
+			}
+			handler := NewAuthHandler(mockService)
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/api/admin/users/7/reset-password", nil)
+			c.Params = gin.Params{{Key: "id", Value: "7"}}
+			c.Set("userID", "1")
+			handler.AdminRequestPasswordReset(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
 // Helper function to check if a string contains another string
 func contains(s, substr string) bool {
 	return s != "" && strings.Contains(strings.ToLower(s), strings.ToLower(substr))
 }
 
+// jsonContains reports whether the JSON object body has every member of the
+// JSON object expected, e.g. the "error" of a problem response
+func jsonContains(t *testing.T, body []byte, expected string) bool {
+	t.Helper()
+	var got, want map[string]interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		return false
+	}
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatalf("invalid expected body %s: %v", expected, err)
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(got[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
 func TestAuthHandler_ResetPassword(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -483,7 +978,7 @@ func TestAuthHandler_ResetPassword(t *testing.T) {
 				ConfirmPassword: "NewPgdfgdfgd123!",
 			},
 			setupMock: func(m *MockAuthService) {
-				m.ResetPasswordFunc = func(token, newPassword string) error {
+				m.ResetPasswordFunc = func(ctx context.Context, token, newPassword string) error {
 					return nil
 				}
 			},
@@ -500,7 +995,7 @@ func TestAuthHandler_ResetPassword(t *testing.T) {
 				ConfirmPassword: "NewPgdfgdfgd123!",
 			},
 			setupMock: func(m *MockAuthService) {
-				m.ResetPasswordFunc = func(token, newPassword string) error {
+				m.ResetPasswordFunc = func(ctx context.Context, token, newPassword string) error {
 					return service.ErrInvalidToken
 				}
 			},
@@ -517,7 +1012,7 @@ func TestAuthHandler_ResetPassword(t *testing.T) {
 				ConfirmPassword: "NewPgdfgdfgd123!",
 			},
 			setupMock: func(m *MockAuthService) {
-				m.ResetPasswordFunc = func(token, newPassword string) error {
+				m.ResetPasswordFunc = func(ctx context.Context, token, newPassword string) error {
 					return service.ErrExpiredToken
 				}
 			},
@@ -567,3 +1062,905 @@ func TestAuthHandler_ResetPassword(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthHandler_ExportAccount(t *testing.T) {
+	c, w := setupTestRouter()
+	mockService := &MockAuthService{
+		ExportAccountFunc: func(ctx context.Context, userID string) (*service.AccountExport, error) {
+			user := &models.User{
+				Username:     "testuser",
+				Email:        "test@example.com",
+				DisplayName:  "Test User",
+				PasswordHash: "$2a$10$secret-hash",
+				ResetToken:   "secret-reset-token",
+				Role:         "user",
+				Active:       true,
+			}
+			user.ID = 1
+			return &service.AccountExport{
+				User: user,
+				Sessions: []*auth.Session{
+					{ID: "current-session-id", UserID: userID, IP: "10.0.0.1", UserAgent: "browser", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)},
+					{ID: "other-session-id", UserID: userID, IP: "10.0.0.2", UserAgent: "phone", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)},
+				},
+				LinkedAccounts: []models.ExternalIdentity{
+					{Provider: "github", Subject: "secret-subject", Email: "test@example.com", CreatedAt: time.Now()},
+				},
+				APIKeys: []*auth.APIKey{
+					{ID: "key-1", UserID: userID, Name: "ci", Scopes: []string{"users:read"}, CreatedAt: time.Now()},
+				},
+			}, nil
+		},
+	}
+	handler := NewAuthHandler(mockService)
+
+	c.Request, _ = http.NewRequest(http.MethodGet, "/api/me/export", nil)
+	c.Set("userID", "1")
+	c.Set("sessionID", "current-session-id")
+
+	handler.ExportAccount(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, `attachment; filename="account-export-1-`) {
+		t.Errorf("expected attachment Content-Disposition, got %q", disposition)
+	}
+
+	body := w.Body.String()
+	for _, secret := range []string{"secret-hash", "secret-reset-token", "current-session-id", "other-session-id", "password", "reset_token", "secret-subject"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("expected export not to contain %q, got %s", secret, body)
+		}
+	}
+
+	var response struct {
+		User struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"user"`
+		Sessions []struct {
+			IP      string `json:"ip"`
+			Current bool   `json:"current"`
+		} `json:"sessions"`
+		LinkedAccounts []struct {
+			Provider string `json:"provider"`
+		} `json:"linked_accounts"`
+		APIKeys []struct {
+			Name string `json:"name"`
+		} `json:"api_keys"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.User.ID != "1" || response.User.Username != "testuser" {
+		t.Errorf("unexpected user in export: %+v", response.User)
+	}
+	if len(response.LinkedAccounts) != 1 || response.LinkedAccounts[0].Provider != "github" {
+		t.Errorf("unexpected linked accounts in export: %+v", response.LinkedAccounts)
+	}
+	if len(response.APIKeys) != 1 || response.APIKeys[0].Name != "ci" {
+		t.Errorf("unexpected API keys in export: %+v", response.APIKeys)
+	}
+	if len(response.Sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(response.Sessions))
+	}
+	if !response.Sessions[0].Current || response.Sessions[1].Current {
+		t.Errorf("expected only the first session to be flagged current: %+v", response.Sessions)
+	}
+}
+
+func TestAuthHandler_ListSessions(t *testing.T) {
+	c, w := setupTestRouter()
+	handler := NewAuthHandler(&MockAuthService{
+		ListSessionsFunc: func(ctx context.Context, userID string) ([]*auth.Session, error) {
+			return []*auth.Session{
+				{ID: "current-session-id", UserID: userID, IP: "10.0.0.1", UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)},
+				{ID: "other-session-id", UserID: userID, IP: "10.0.0.2", UserAgent: "phone", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)},
+			}, nil
+		},
+	})
+
+	c.Request, _ = http.NewRequest(http.MethodGet, "/api/me/sessions", nil)
+	c.Set("userID", "1")
+	c.Set("sessionID", "current-session-id")
+
+	handler.ListSessions(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	body := w.Body.String()
+	for _, secret := range []string{"current-session-id", "other-session-id"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("expected sessions not to contain %q, got %s", secret, body)
+		}
+	}
+
+	var response struct {
+		Sessions []struct {
+			ID      string `json:"id"`
+			Device  string `json:"device"`
+			IP      string `json:"ip"`
+			Current bool   `json:"current"`
+		} `json:"sessions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(response.Sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(response.Sessions))
+	}
+	if response.Sessions[0].ID != auth.SessionHandle("current-session-id") {
+		t.Errorf("expected the session handle as id, got %q", response.Sessions[0].ID)
+	}
+	if response.Sessions[0].Device != "Firefox (Linux)" {
+		t.Errorf("expected device Firefox (Linux), got %q", response.Sessions[0].Device)
+	}
+	if !response.Sessions[0].Current || response.Sessions[1].Current {
+		t.Errorf("expected only the first session to be flagged current: %+v", response.Sessions)
+	}
+}
+
+func TestAuthHandler_RevokeSession(t *testing.T) {
+	tests := []struct {
+		name           string
+		handle         string
+		serviceErr     error
+		expectedStatus int
+		clearsCookie   bool
+	}{
+		{name: "Other Session", handle: auth.SessionHandle("other-session-id"), expectedStatus: http.StatusOK},
+		{name: "Current Session", handle: auth.SessionHandle("current-session-id"), expectedStatus: http.StatusOK, clearsCookie: true},
+		{name: "Unknown Session", handle: "unknown", serviceErr: service.ErrSessionNotFound, expectedStatus: http.StatusNotFound},
+		{name: "Service Error", handle: "any", serviceErr: errors.New("database down"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			var revoked string
+			handler := NewAuthHandler(&MockAuthService{
+				RevokeSessionFunc: func(ctx context.Context, userID, handle string) error {
+					revoked = handle
+					return tt.serviceErr
+				},
+			})
+
+			c.Request, _ = http.NewRequest(http.MethodDelete, "/api/me/sessions/"+tt.handle, nil)
+			c.Params = gin.Params{{Key: "id", Value: tt.handle}}
+			c.Set("userID", "1")
+			c.Set("sessionID", "current-session-id")
+
+			handler.RevokeSession(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if revoked != tt.handle {
+				t.Errorf("expected handle %q to be revoked, got %q", tt.handle, revoked)
+			}
+			if cleared := strings.Contains(w.Header().Get("Set-Cookie"), "Max-Age=0"); cleared != tt.clearsCookie {
+				t.Errorf("expected cookie cleared %v, got %v", tt.clearsCookie, cleared)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_RevokeOtherSessions(t *testing.T) {
+	c, w := setupTestRouter()
+	var kept string
+	handler := NewAuthHandler(&MockAuthService{
+		RevokeOtherSessionsFunc: func(ctx context.Context, userID, currentSessionID string) ([]auth.SessionRevocation, error) {
+			kept = currentSessionID
+			return []auth.SessionRevocation{
+				{SessionID: "phone"},
+				{SessionID: "tablet", Err: errors.New("redis: connection refused")},
+				{SessionID: "laptop"},
+			}, nil
+		},
+	})
+
+	c.Request, _ = http.NewRequest(http.MethodPost, "/api/me/sessions/revoke-others", nil)
+	c.Set("userID", "1")
+	c.Set("sessionID", "current-session-id")
+
+	handler.RevokeOtherSessions(c)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if kept != "current-session-id" {
+		t.Errorf("expected the current session to be kept, got %q", kept)
+	}
+	var batch dto.BatchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &batch); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	expected := []dto.BatchItemResult{
+		{Index: 0, ID: auth.SessionHandle("phone"), Status: dto.BatchStatusOK},
+		{Index: 1, ID: auth.SessionHandle("tablet"), Status: dto.BatchStatusFailed, Error: "falha ao revogar sessão"},
+		{Index: 2, ID: auth.SessionHandle("laptop"), Status: dto.BatchStatusOK},
+	}
+	if batch.Succeeded != 2 || batch.Failed != 1 || !reflect.DeepEqual(batch.Results, expected) {
+		t.Errorf("unexpected batch %+v", batch)
+	}
+}
+
+func TestAuthHandler_Impersonate(t *testing.T) {
+	tests := []struct {
+		name           string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{name: "Success", expectedStatus: http.StatusOK},
+		{name: "Not Admin", serviceErr: service.ErrForbidden, expectedStatus: http.StatusForbidden},
+		{name: "Unknown User", serviceErr: service.ErrUserNotFound, expectedStatus: http.StatusNotFound},
+		{name: "Inactive User", serviceErr: service.ErrUserNotActive, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			mockService := &MockAuthService{
+				ImpersonateFunc: func(ctx context.Context, adminSessionID, targetUserID, ip, userAgent string) (*service.LoginResponse, error) {
+					if adminSessionID != "admin-session" || targetUserID != "2" {
+						t.Errorf("unexpected arguments %q, %q", adminSessionID, targetUserID)
+					}
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &service.LoginResponse{
+						SessionID:      "impersonation-session",
+						ExpiresAt:      time.Now().Add(15 * time.Minute),
+						User:           auth.UserData{ID: "2", Identifier: "target"},
+						ImpersonatorID: "1",
+					}, nil
+				},
+			}
+			handler := NewAuthHandler(mockService)
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/api/admin/impersonate/2", nil)
+			c.Params = gin.Params{{Key: "user_id", Value: "2"}}
+			c.Set("sessionID", "admin-session")
+
+			handler.Impersonate(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.serviceErr != nil {
+				return
+			}
+			if !strings.Contains(w.Header().Get("Set-Cookie"), "impersonation-session") {
+				t.Errorf("expected impersonation session cookie, got %q", w.Header().Get("Set-Cookie"))
+			}
+			if !strings.Contains(w.Body.String(), `"impersonator_id":"1"`) {
+				t.Errorf("expected impersonator_id in response, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthHandler_EndImpersonation(t *testing.T) {
+	t.Run("Restores Admin Session", func(t *testing.T) {
+		c, w := setupTestRouter()
+		mockService := &MockAuthService{
+			EndImpersonationFunc: func(ctx context.Context, sessionID string) (*service.LoginResponse, error) {
+				return &service.LoginResponse{
+					SessionID: "admin-session",
+					ExpiresAt: time.Now().Add(time.Hour),
+					User:      auth.UserData{ID: "1", Identifier: "admin"},
+				}, nil
+			},
+		}
+		handler := NewAuthHandler(mockService)
+
+		c.Request, _ = http.NewRequest(http.MethodPost, "/api/impersonation/end", nil)
+		c.Set("sessionID", "impersonation-session")
+
+		handler.EndImpersonation(c)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if !strings.Contains(w.Header().Get("Set-Cookie"), "admin-session") {
+			t.Errorf("expected admin session cookie, got %q", w.Header().Get("Set-Cookie"))
+		}
+	})
+
+	t.Run("Not Impersonating", func(t *testing.T) {
+		c, w := setupTestRouter()
+		mockService := &MockAuthService{
+			EndImpersonationFunc: func(ctx context.Context, sessionID string) (*service.LoginResponse, error) {
+				return nil, service.ErrNotImpersonating
+			},
+		}
+		handler := NewAuthHandler(mockService)
+
+		c.Request, _ = http.NewRequest(http.MethodPost, "/api/impersonation/end", nil)
+		c.Set("sessionID", "regular-session")
+
+		handler.EndImpersonation(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestAuthHandler_ValidateResetToken(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		serviceErr     error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Valid Token", query: "?token=valid", expectedStatus: http.StatusOK, expectedBody: `{"valid":true}`},
+		{name: "Expired Token", query: "?token=expired", serviceErr: service.ErrExpiredToken, expectedStatus: http.StatusOK, expectedBody: `{"valid":false}`},
+		{name: "Unknown Token", query: "?token=unknown", serviceErr: service.ErrInvalidToken, expectedStatus: http.StatusOK, expectedBody: `{"valid":false}`},
+		{name: "Missing Token", query: "", expectedStatus: http.StatusBadRequest, expectedBody: `{"error":"token é obrigatório"}`},
+		{name: "Service Error", query: "?token=valid", serviceErr: errors.New("database down"), expectedStatus: http.StatusInternalServerError, expectedBody: `{"error":"falha ao validar token"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			mockService := &MockAuthService{
+				ValidateResetTokenFunc: func(ctx context.Context, token string) error {
+					return tt.serviceErr
+				},
+				ResetPasswordFunc: func(ctx context.Context, token, newPassword string) error {
+					t.Error("validation must not reset the password")
+					return nil
+				},
+			}
+			handler := NewAuthHandler(mockService)
+
+			c.Request, _ = http.NewRequest(http.MethodGet, "/auth/password-reset/validate"+tt.query, nil)
+
+			handler.ValidateResetToken(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if !jsonContains(t, w.Body.Bytes(), tt.expectedBody) {
+				t.Errorf("expected body %s, got %s", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthHandler_GetCurrentUser_ETag(t *testing.T) {
+	handler := NewAuthHandler(&MockAuthService{})
+	updatedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	get := func(ifNoneMatch string, updatedAt time.Time) *httptest.ResponseRecorder {
+		c, w := setupTestRouter()
+		c.Request, _ = http.NewRequest(http.MethodGet, "/api/me", nil)
+		if ifNoneMatch != "" {
+			c.Request.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		c.Set("user", &auth.UserData{
+			ID:         "1",
+			Identifier: "testuser",
+			Attributes: map[string]any{"updated_at": updatedAt},
+		})
+		handler.GetCurrentUser(c)
+		return w
+	}
+
+	// First request: full response with an ETag
+	w := get("", updatedAt)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	etag := w.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("expected a weak ETag, got %q", etag)
+	}
+	if cacheControl := w.Header().Get("Cache-Control"); !strings.Contains(cacheControl, "private") {
+		t.Errorf("expected private Cache-Control, got %q", cacheControl)
+	}
+
+	// Unchanged user: 304 without a body
+	w = get(etag, updatedAt)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("expected status %d, got %d", http.StatusNotModified, w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected empty body on 304, got %s", w.Body.String())
+	}
+	if w.Header().Get("ETag") != etag {
+		t.Errorf("expected 304 to repeat the ETag %q, got %q", etag, w.Header().Get("ETag"))
+	}
+
+	// Matched within a list of candidates
+	if w := get(`"other", `+etag, updatedAt); w.Code != http.StatusNotModified {
+		t.Errorf("expected status %d for a matching candidate, got %d", http.StatusNotModified, w.Code)
+	}
+
+	// Updated user: new ETag and full response
+	w = get(etag, updatedAt.Add(time.Second))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d after update, got %d", http.StatusOK, w.Code)
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Errorf("expected the ETag to change after an update")
+	}
+}
+
+func TestAuthHandler_PasswordPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy := validation.DefaultPasswordPolicy()
+	policy.MaxLength = 72
+	policy.HistoryDepth = 5
+	handler := NewAuthHandler(&MockAuthService{
+		PasswordPolicyFunc: func() validation.PasswordPolicy { return policy },
+	})
+	router := gin.New()
+	router.GET("/auth/password-policy", handler.PasswordPolicy)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/password-policy", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body["min_length"] != float64(validation.PasswordMinLength) || body["max_length"] != float64(72) || body["history_depth"] != float64(5) {
+		t.Errorf("unexpected policy: %v", body)
+	}
+	if classes, _ := body["required_classes"].([]any); len(classes) != 4 {
+		t.Errorf("expected 4 required classes, got %v", body["required_classes"])
+	}
+}
+
+func TestAuthHandler_RestoreAccount(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Restored", body: `{"token":"valid"}`, expectedStatus: http.StatusOK, expectedBody: `{"message":"conta restaurada com sucesso"}`},
+		{name: "Unknown Token", body: `{"token":"unknown"}`, serviceErr: service.ErrInvalidToken, expectedStatus: http.StatusBadRequest, expectedBody: `{"error":"token inválido"}`},
+		{name: "Window Over", body: `{"token":"expired"}`, serviceErr: service.ErrExpiredToken, expectedStatus: http.StatusBadRequest, expectedBody: `{"error":"prazo para restaurar a conta encerrado"}`},
+		{name: "Service Error", body: `{"token":"valid"}`, serviceErr: errors.New("database down"), expectedStatus: http.StatusInternalServerError, expectedBody: `{"error":"falha ao restaurar conta"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			handler := NewAuthHandler(&MockAuthService{
+				RestoreAccountFunc: func(ctx context.Context, token string) error {
+					return tt.serviceErr
+				},
+			})
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/auth/account/restore", bytes.NewBufferString(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handler.RestoreAccount(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if !jsonContains(t, w.Body.Bytes(), tt.expectedBody) {
+				t.Errorf("expected body %s, got %s", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthHandler_VerifyEmail(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Verified", body: `{"token":"valid"}`, expectedStatus: http.StatusOK, expectedBody: `{"message":"email verificado com sucesso"}`},
+		{name: "Missing Token", body: `{}`, expectedStatus: http.StatusBadRequest},
+		{name: "Invalid Token", body: `{"token":"forged"}`, serviceErr: service.ErrInvalidToken, expectedStatus: http.StatusBadRequest, expectedBody: `{"error":"token inválido"}`},
+		{name: "Expired Token", body: `{"token":"expired"}`, serviceErr: service.ErrExpiredToken, expectedStatus: http.StatusBadRequest, expectedBody: `{"error":"link de verificação expirado, solicite um novo"}`},
+		{name: "Disabled", body: `{"token":"valid"}`, serviceErr: service.ErrEmailVerificationDisabled, expectedStatus: http.StatusNotFound, expectedBody: `{"error":"verificação de email desativada"}`},
+		{name: "Service Error", body: `{"token":"valid"}`, serviceErr: errors.New("database down"), expectedStatus: http.StatusInternalServerError, expectedBody: `{"error":"falha ao verificar email"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			handler := NewAuthHandler(&MockAuthService{
+				VerifyEmailFunc: func(ctx context.Context, token string) error {
+					return tt.serviceErr
+				},
+			})
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/auth/verify-email", bytes.NewBufferString(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handler.VerifyEmail(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedBody != "" && !jsonContains(t, w.Body.Bytes(), tt.expectedBody) {
+				t.Errorf("expected body %s, got %s", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthHandler_ResendVerification(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{name: "Sent", body: `{"email":"alice@example.com"}`, expectedStatus: http.StatusOK},
+		{name: "Invalid Email", body: `{"email":"alice"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Disabled", body: `{"email":"alice@example.com"}`, serviceErr: service.ErrEmailVerificationDisabled, expectedStatus: http.StatusNotFound},
+		{name: "Service Error", body: `{"email":"alice@example.com"}`, serviceErr: errors.New("database down"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			var requested string
+			handler := NewAuthHandler(&MockAuthService{
+				ResendVerificationEmailFunc: func(ctx context.Context, email string) error {
+					requested = email
+					return tt.serviceErr
+				},
+			})
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/auth/resend-verification", bytes.NewBufferString(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handler.ResendVerification(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusOK && requested != "alice@example.com" {
+				t.Errorf("expected a resend to alice@example.com, got %q", requested)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_CompleteTwoFactorLogin(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Completed", body: `{"challenge_token":"token","code":"123456"}`, expectedStatus: http.StatusOK},
+		{name: "Missing Code", body: `{"challenge_token":"token"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Wrong Code", body: `{"challenge_token":"token","code":"000000"}`, serviceErr: service.ErrInvalidTwoFactorCode, expectedStatus: http.StatusUnauthorized, expectedError: service.ErrInvalidTwoFactorCode.Error()},
+		{name: "Expired Challenge", body: `{"challenge_token":"old","code":"123456"}`, serviceErr: service.ErrInvalidTwoFactorChallenge, expectedStatus: http.StatusUnauthorized, expectedError: service.ErrInvalidTwoFactorChallenge.Error()},
+		{name: "Locked", body: `{"challenge_token":"token","code":"000000"}`, serviceErr: service.ErrTwoFactorLocked, expectedStatus: http.StatusTooManyRequests, expectedError: service.ErrTwoFactorLocked.Error()},
+		{name: "Service Error", body: `{"challenge_token":"token","code":"123456"}`, serviceErr: errors.New("database down"), expectedStatus: http.StatusInternalServerError, expectedError: "falha ao concluir login"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			handler := NewAuthHandler(&MockAuthService{
+				CompleteTwoFactorLoginFunc: func(ctx context.Context, challengeToken, code, ip string) (*service.LoginResponse, error) {
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &service.LoginResponse{
+						SessionID: "test-session-id",
+						ExpiresAt: time.Now().Add(time.Hour),
+						User:      auth.UserData{ID: "1", Identifier: "totpuser"},
+					}, nil
+				},
+			})
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/auth/login/2fa", bytes.NewBufferString(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handler.CompleteTwoFactorLogin(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			var response map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if tt.expectedError != "" && response["error"] != tt.expectedError {
+				t.Errorf("expected error %q, got %v", tt.expectedError, response["error"])
+			}
+			if tt.expectedStatus == http.StatusOK {
+				if response["session_id"] != "test-session-id" {
+					t.Errorf("expected session_id test-session-id, got %v", response["session_id"])
+				}
+				if w.Header().Get("Set-Cookie") == "" {
+					t.Error("expected the session cookie to be set")
+				}
+			}
+		})
+	}
+}
+
+func TestAuthHandler_RequestMagicLink(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{name: "Sent", body: `{"email":"user@example.com"}`, expectedStatus: http.StatusOK},
+		{name: "Invalid Email", body: `{"email":"not-an-email"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Disabled", body: `{"email":"user@example.com"}`, serviceErr: service.ErrMagicLinkDisabled, expectedStatus: http.StatusNotFound},
+		{name: "Service Error", body: `{"email":"user@example.com"}`, serviceErr: errors.New("database down"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			handler := NewAuthHandler(&MockAuthService{
+				RequestMagicLinkFunc: func(ctx context.Context, email, ip string) error {
+					return tt.serviceErr
+				},
+			})
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/auth/magic-link", bytes.NewBufferString(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handler.RequestMagicLink(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthHandler_VerifyMagicLink(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		response       *service.LoginResponse
+		serviceErr     error
+		expectedStatus int
+	}{
+		{name: "Logged In", body: `{"token":"token"}`, response: &service.LoginResponse{SessionID: "test-session-id", ExpiresAt: time.Now().Add(time.Hour), User: auth.UserData{ID: "1"}}, expectedStatus: http.StatusOK},
+		{name: "Two Factor", body: `{"token":"token"}`, response: &service.LoginResponse{TwoFactor: &service.TwoFactorChallenge{Token: "challenge", ExpiresAt: time.Now().Add(time.Minute)}}, expectedStatus: http.StatusAccepted},
+		{name: "Missing Token", body: `{}`, expectedStatus: http.StatusBadRequest},
+		{name: "Invalid Link", body: `{"token":"used"}`, serviceErr: service.ErrInvalidMagicLink, expectedStatus: http.StatusUnauthorized},
+		{name: "Disabled", body: `{"token":"token"}`, serviceErr: service.ErrMagicLinkDisabled, expectedStatus: http.StatusNotFound},
+		{name: "Pending Deletion", body: `{"token":"token"}`, serviceErr: service.ErrAccountPendingDeletion, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			handler := NewAuthHandler(&MockAuthService{
+				LoginWithMagicLinkFunc: func(ctx context.Context, token, ip, userAgent string) (*service.LoginResponse, error) {
+					return tt.response, tt.serviceErr
+				},
+			})
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/auth/magic-link/verify", bytes.NewBufferString(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handler.VerifyMagicLink(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK && w.Header().Get("Set-Cookie") == "" {
+				t.Error("expected the session cookie to be set")
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Refresh(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Refreshed", body: `{"refresh_token":"token"}`, expectedStatus: http.StatusOK},
+		{name: "Missing Token", body: `{}`, expectedStatus: http.StatusBadRequest},
+		{name: "Invalid Token", body: `{"refresh_token":"old"}`, serviceErr: service.ErrInvalidRefreshToken, expectedStatus: http.StatusUnauthorized, expectedError: service.ErrInvalidRefreshToken.Error()},
+		{name: "Inactive User", body: `{"refresh_token":"token"}`, serviceErr: service.ErrUserNotActive, expectedStatus: http.StatusUnauthorized, expectedError: "usuário inativo"},
+		{name: "Service Error", body: `{"refresh_token":"token"}`, serviceErr: errors.New("database down"), expectedStatus: http.StatusInternalServerError, expectedError: "falha ao renovar sessão"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			handler := NewAuthHandler(&MockAuthService{
+				RefreshFunc: func(ctx context.Context, refreshToken, ip, userAgent string) (*service.LoginResponse, error) {
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &service.LoginResponse{
+						SessionID:    "new-session-id",
+						ExpiresAt:    time.Now().Add(time.Hour),
+						User:         auth.UserData{ID: "1", Identifier: "testuser"},
+						RefreshToken: "new-refresh-token",
+					}, nil
+				},
+			})
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBufferString(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handler.Refresh(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			var response map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if tt.expectedError != "" && response["error"] != tt.expectedError {
+				t.Errorf("expected error %q, got %v", tt.expectedError, response["error"])
+			}
+			if tt.expectedStatus == http.StatusOK {
+				if response["session_id"] != "new-session-id" {
+					t.Errorf("expected session_id new-session-id, got %v", response["session_id"])
+				}
+				if response["refresh_token"] != "new-refresh-token" {
+					t.Errorf("expected refresh_token new-refresh-token, got %v", response["refresh_token"])
+				}
+				if w.Header().Get("Set-Cookie") == "" {
+					t.Error("expected the session cookie to be set")
+				}
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Refresh_CookieTransport(t *testing.T) {
+	withCookies := middleware.Cookies(middleware.NewCookieOptions(config.AuthConfig{TokenTransport: config.TokenTransportCookie, RefreshTokenDuration: time.Hour}, true))
+
+	var received string
+	handler := NewAuthHandler(&MockAuthService{
+		RefreshFunc: func(ctx context.Context, refreshToken, ip, userAgent string) (*service.LoginResponse, error) {
+			received = refreshToken
+			return &service.LoginResponse{
+				SessionID:    "new-session-id",
+				ExpiresAt:    time.Now().Add(time.Hour),
+				User:         auth.UserData{ID: "1", Identifier: "testuser"},
+				RefreshToken: "new-refresh-token",
+			}, nil
+		},
+	})
+
+	// Without the cookie there is nothing to refresh
+	c, w := setupTestRouter()
+	c.Request, _ = http.NewRequest(http.MethodPost, "/auth/refresh", nil)
+	withCookies(c)
+	handler.Refresh(c)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	c, w = setupTestRouter()
+	c.Request, _ = http.NewRequest(http.MethodPost, "/auth/refresh", nil)
+	c.Request.AddCookie(&http.Cookie{Name: middleware.RefreshCookieName, Value: "old-refresh-token"})
+	withCookies(c)
+	handler.Refresh(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if received != "old-refresh-token" {
+		t.Errorf("expected the refresh token from the cookie, got %q", received)
+	}
+	if body := w.Body.String(); strings.Contains(body, "new-session-id") || strings.Contains(body, "new-refresh-token") {
+		t.Errorf("expected the tokens only in cookies, got %s", body)
+	}
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	for name, value := range map[string]string{middleware.SessionCookieName: "new-session-id", middleware.RefreshCookieName: "new-refresh-token"} {
+		if cookie := cookies[name]; cookie == nil || cookie.Value != value || !cookie.HttpOnly {
+			t.Errorf("expected HttpOnly cookie %s=%s, got %+v", name, value, cookie)
+		}
+	}
+}
+
+func TestAuthHandler_RegisterLocale(t *testing.T) {
+	tests := []struct {
+		name           string
+		locale         string
+		acceptLanguage string
+		expectedStatus int
+		expectedLocale string
+	}{
+		{"From the body", "en", "pt-BR", http.StatusOK, "en"},
+		{"From Accept-Language", "", "fr;q=0.5, en-US, *;q=0.1", http.StatusOK, "en-US"},
+		{"Invalid header entries are skipped", "", "<x>, es;q=0.8", http.StatusOK, "es"},
+		{"Default", "", "", http.StatusOK, ""},
+		{"Invalid", "not a locale", "", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			var gotLocale string
+			handler := NewAuthHandler(&MockAuthService{
+				RegisterFunc: func(ctx context.Context, username, emailAddr, password, displayName, captchaToken, ip string) (*models.User, bool, error) {
+					gotLocale = email.LocaleFromContext(ctx)
+					return &models.User{Username: username, Email: emailAddr, DisplayName: displayName}, false, nil
+				},
+			})
+
+			body, _ := json.Marshal(RegistrationRequest{
+				Username:    "newuser",
+				Email:       "new@example.com",
+				Password:    "Padasdasdasdd123!",
+				DisplayName: "New User",
+				Locale:      tt.locale,
+			})
+			c.Request, _ = http.NewRequest(http.MethodPost, "/auth/register", bytes.NewBuffer(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			if tt.acceptLanguage != "" {
+				c.Request.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+
+			handler.Register(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if gotLocale != tt.expectedLocale {
+				t.Errorf("expected locale %q, got %q", tt.expectedLocale, gotLocale)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_UpdateLocale(t *testing.T) {
+	var gotUserID, gotLocale string
+	handler := NewAuthHandler(&MockAuthService{
+		SetLocaleFunc: func(ctx context.Context, userID, locale string) error {
+			gotUserID, gotLocale = userID, locale
+			return nil
+		},
+	})
+
+	c, w := setupTestRouter()
+	c.Request, _ = http.NewRequest(http.MethodPut, "/api/me/locale", strings.NewReader(`{"locale":"en"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("userID", "1")
+	handler.UpdateLocale(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if gotUserID != "1" || gotLocale != "en" {
+		t.Errorf("expected SetLocale(1, en), got SetLocale(%s, %s)", gotUserID, gotLocale)
+	}
+
+	c, w = setupTestRouter()
+	c.Request, _ = http.NewRequest(http.MethodPut, "/api/me/locale", strings.NewReader(`{"locale":"<script>"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("userID", "1")
+	handler.UpdateLocale(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/avatar"
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// avatarFormOverhead is the room left for the multipart boundaries and
+// headers on top of storage.avatar.max_bytes
+const avatarFormOverhead = 16 << 10
+
+var (
+	errAvatarMissing  = errors.New("envie a imagem no campo avatar de um formulário multipart/form-data")
+	errAvatarTooLarge = errors.New("imagem grande demais")
+)
+
+// WithAvatarStorage enables avatar uploads, stored in store and resized as
+// cfg.Avatar says. GetCurrentUser then returns an avatar_url signed for
+// cfg.SignedURLTTL.
+func WithAvatarStorage(store storage.Storage, cfg config.StorageConfig) AuthHandlerOption {
+	return func(h *AuthHandler) {
+		h.avatars = store
+		h.avatarConfig = cfg.Avatar
+		h.avatarURLTTL = cfg.SignedURLTTL
+	}
+}
+
+// AvatarsEnabled reports whether WithAvatarStorage was set
+func (h *AuthHandler) AvatarsEnabled() bool {
+	return h.avatars != nil
+}
+
+// avatarURL returns a signed URL of the avatar stored under key, "" without
+// one or when it can't be signed
+func (h *AuthHandler) avatarURL(c *gin.Context, key string) string {
+	if key == "" || h.avatars == nil {
+		return ""
+	}
+	url, err := h.avatars.SignedURL(requestContext(c), key, h.avatarURLTTL)
+	if err != nil {
+		logger.FromContext(requestContext(c)).Warn("Erro ao assinar link do avatar", "error", err, "key", key)
+		return ""
+	}
+	return url
+}
+
+// UploadAvatar replaces the authenticated user's avatar with the picture sent
+// in the "avatar" field of a multipart/form-data body. JPEG, PNG and GIF are
+// accepted, whatever the declared content type says; the picture is cropped
+// to a square and resized to storage.avatar.size.
+func (h *AuthHandler) UploadAvatar(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.avatarConfig.MaxBytes+avatarFormOverhead)
+	data, err := readAvatarPart(c.Request, h.avatarConfig.MaxBytes)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errAvatarTooLarge) || errors.As(err, &maxBytesErr):
+		apierror.Respond(c, apierror.New(http.StatusRequestEntityTooLarge, fmt.Sprintf("a imagem deve ter no máximo %d bytes", h.avatarConfig.MaxBytes)))
+		return
+	case err != nil:
+		if abortIfCanceled(c, err) {
+			return
+		}
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	picture, err := avatar.Process(data, h.avatarConfig.Size)
+	switch {
+	case errors.Is(err, avatar.ErrUnsupportedFormat):
+		apierror.Respond(c, apierror.New(http.StatusUnsupportedMediaType, err.Error()))
+		return
+	case errors.Is(err, avatar.ErrInvalidImage) || errors.Is(err, avatar.ErrImageTooLarge):
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
+		return
+	case err != nil:
+		internalError(c, err, "falha ao processar imagem")
+		return
+	}
+
+	// A new name on every upload, so caches never serve the previous picture
+	ctx := requestContext(c)
+	key := fmt.Sprintf("avatars/%s/%s%s", userID, randomName(), picture.Ext)
+	if err := h.avatars.Put(ctx, key, bytes.NewReader(picture.Data), int64(len(picture.Data)), picture.ContentType); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao salvar avatar", "user_id", userID)
+		return
+	}
+	previous, err := h.authService.SetAvatar(ctx, userID.(string), key)
+	if err != nil {
+		h.deleteAvatarFile(c, key)
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao salvar avatar", "user_id", userID)
+		return
+	}
+	h.deleteAvatarFile(c, previous)
+
+	c.JSON(http.StatusOK, gin.H{"avatar_url": h.avatarURL(c, key)})
+}
+
+// DeleteAvatar removes the authenticated user's avatar
+func (h *AuthHandler) DeleteAvatar(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	previous, err := h.authService.SetAvatar(requestContext(c), userID.(string), "")
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao remover avatar", "user_id", userID)
+		return
+	}
+	h.deleteAvatarFile(c, previous)
+
+	c.JSON(http.StatusOK, gin.H{"message": "avatar removido"})
+}
+
+// deleteAvatarFile removes a replaced avatar. A leftover file only wastes
+// space, so failures are logged and not returned.
+func (h *AuthHandler) deleteAvatarFile(c *gin.Context, key string) {
+	if key == "" {
+		return
+	}
+	if err := h.avatars.Delete(requestContext(c), key); err != nil {
+		logger.FromContext(requestContext(c)).Warn("Erro ao apagar avatar antigo", "error", err, "key", key)
+	}
+}
+
+// readAvatarPart streams the multipart body up to the "avatar" field and
+// reads it, failing with errAvatarTooLarge beyond maxBytes
+func readAvatarPart(r *http.Request, maxBytes int64) ([]byte, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, errAvatarMissing
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, errAvatarMissing
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() != "avatar" {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(part, maxBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > maxBytes {
+			return nil, errAvatarTooLarge
+		}
+		return data, nil
+	}
+}
+
+// randomName returns 16 random hex characters
+func randomName() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartBody builds a multipart/form-data body with data in field
+func multipartBody(t *testing.T, field string, data []byte) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile(field, "picture.png")
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	part.Write(data)
+	writer.Close()
+	return &body, writer.FormDataContentType()
+}
+
+func pngPicture(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("failed to encode picture: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestAuthHandler_UploadAvatar(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	store := storage.NewLocal(dir, "/files", []byte("0123456789abcdef0123456789abcdef"))
+	cfg := config.StorageConfig{SignedURLTTL: time.Hour, Avatar: config.AvatarConfig{MaxBytes: 4096, Size: 32}}
+
+	// The previous avatar is deleted once replaced
+	if err := store.Put(context.Background(), "avatars/1/old.png", strings.NewReader("old"), 3, "image/png"); err != nil {
+		t.Fatalf("failed to store the previous avatar: %v", err)
+	}
+	var savedKey string
+	handler := NewAuthHandler(&MockAuthService{
+		SetAvatarFunc: func(ctx context.Context, userID, key string) (string, error) {
+			savedKey = key
+			return "avatars/1/old.png", nil
+		},
+	}, WithAvatarStorage(store, cfg))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("userID", "1") })
+	router.PUT("/api/me/avatar", handler.UploadAvatar)
+
+	upload := func(field string, data []byte) *httptest.ResponseRecorder {
+		body, contentType := multipartBody(t, field, data)
+		req := httptest.NewRequest(http.MethodPut, "/api/me/avatar", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := upload("avatar", pngPicture(t, 80, 60))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !strings.HasPrefix(savedKey, "avatars/1/") || !strings.HasSuffix(savedKey, ".png") {
+		t.Fatalf("unexpected key %q", savedKey)
+	}
+	file, err := os.Open(filepath.Join(dir, filepath.FromSlash(savedKey)))
+	if err != nil {
+		t.Fatalf("avatar not stored: %v", err)
+	}
+	defer file.Close()
+	stored, _, err := image.DecodeConfig(file)
+	if err != nil || stored.Width != 32 || stored.Height != 32 {
+		t.Errorf("expected a 32x32 avatar, got %+v (%v)", stored, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "avatars", "1", "old.png")); !os.IsNotExist(err) {
+		t.Errorf("expected the previous avatar to be deleted, got %v", err)
+	}
+	var resp map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !strings.HasPrefix(resp["avatar_url"], "/files/"+savedKey+"?") {
+		t.Errorf("unexpected avatar_url %q", resp["avatar_url"])
+	}
+
+	for name, tt := range map[string]struct {
+		field  string
+		data   []byte
+		status int
+	}{
+		"too large":     {"avatar", pngPicture(t, 1000, 1000), http.StatusRequestEntityTooLarge},
+		"not an image":  {"avatar", []byte("<script>alert(1)</script>"), http.StatusUnsupportedMediaType},
+		"corrupted":     {"avatar", pngPicture(t, 10, 10)[:40], http.StatusBadRequest},
+		"missing field": {"picture", pngPicture(t, 10, 10), http.StatusBadRequest},
+	} {
+		if w := upload(tt.field, tt.data); w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", name, tt.status, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestAuthHandler_GetCurrentUser_AvatarURL(t *testing.T) {
+	store := storage.NewLocal(t.TempDir(), "/files", []byte("0123456789abcdef0123456789abcdef"))
+	handler := NewAuthHandler(&MockAuthService{}, WithAvatarStorage(store, config.StorageConfig{SignedURLTTL: time.Hour}))
+
+	c, w := setupTestRouter()
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	c.Set("user", &auth.UserData{ID: "1", Attributes: map[string]any{auth.AttrAvatarKey: "avatars/1/a.png"}})
+	handler.GetCurrentUser(c)
+
+	var resp struct {
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !strings.HasPrefix(resp.AvatarURL, "/files/avatars/1/a.png?expires=") {
+		t.Errorf("unexpected avatar_url %q", resp.AvatarURL)
+	}
+}
+
+func TestFileHandler_Serve(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewLocal(t.TempDir(), "/files", []byte("0123456789abcdef0123456789abcdef"))
+	if err := store.Put(context.Background(), "avatars/1/a.png", strings.NewReader("png data"), 8, "image/png"); err != nil {
+		t.Fatalf("failed to store file: %v", err)
+	}
+	router := gin.New()
+	router.GET("/files/*key", NewFileHandler(store).Serve)
+
+	signed, err := store.SignedURL(context.Background(), "avatars/1/a.png", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to sign URL: %v", err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, signed, nil))
+	if w.Code != http.StatusOK || w.Body.String() != "png data" {
+		t.Fatalf("expected the file, got %d: %s", w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "image/png" {
+		t.Errorf("expected image/png, got %q", contentType)
+	}
+
+	// Another file with the same signature
+	u, _ := url.Parse(signed)
+	u.Path = "/files/avatars/2/a.png"
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, u.String(), nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	// Signed but deleted
+	if err := store.Delete(context.Background(), "avatars/1/a.png"); err != nil {
+		t.Fatalf("failed to delete file: %v", err)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, signed, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
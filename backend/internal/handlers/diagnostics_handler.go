@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recentGCPauses is how many of the latest GC pauses Diagnostics reports
+const recentGCPauses = 10
+
+// DBStatser reports connection pool statistics, see sql.DB.Stats
+type DBStatser interface {
+	Stats() sql.DBStats
+}
+
+// DiagnosticsHandler reports runtime statistics for quick debugging, without
+// the overhead of profiling
+type DiagnosticsHandler struct {
+	db        DBStatser
+	startedAt time.Time
+}
+
+// DiagnosticsResponse is returned by Diagnostics. Byte counts are in bytes
+// and durations in nanoseconds.
+type DiagnosticsResponse struct {
+	StartedAt     time.Time          `json:"started_at"`
+	UptimeSeconds float64            `json:"uptime_seconds"`
+	Runtime       RuntimeStats       `json:"runtime"`
+	Memory        MemoryStats        `json:"memory"`
+	GC            GCStats            `json:"gc"`
+	Database      *DatabasePoolStats `json:"database,omitempty"`
+}
+
+// RuntimeStats describes the Go runtime
+type RuntimeStats struct {
+	GoVersion  string `json:"go_version"`
+	Goroutines int    `json:"goroutines"`
+	NumCPU     int    `json:"num_cpu"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+}
+
+// MemoryStats is a subset of runtime.MemStats
+type MemoryStats struct {
+	Alloc        uint64 `json:"alloc"`
+	TotalAlloc   uint64 `json:"total_alloc"`
+	Sys          uint64 `json:"sys"`
+	HeapAlloc    uint64 `json:"heap_alloc"`
+	HeapInuse    uint64 `json:"heap_inuse"`
+	HeapIdle     uint64 `json:"heap_idle"`
+	HeapReleased uint64 `json:"heap_released"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	StackInuse   uint64 `json:"stack_inuse"`
+}
+
+// GCStats describes garbage collection activity
+type GCStats struct {
+	NumGC        uint32     `json:"num_gc"`
+	PauseTotalNs uint64     `json:"pause_total_ns"`
+	RecentPauses []uint64   `json:"recent_pauses_ns"` // newest first
+	LastGC       *time.Time `json:"last_gc,omitempty"`
+	NextGC       uint64     `json:"next_gc"` // heap size target of the next cycle
+}
+
+// DatabasePoolStats is sql.DBStats with JSON names
+type DatabasePoolStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationNs     int64 `json:"wait_duration_ns"`
+	MaxIdleClosed      int64 `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64 `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+}
+
+// NewDiagnosticsHandler creates a DiagnosticsHandler. startedAt is when the
+// process started; db may be nil to leave the pool stats out.
+func NewDiagnosticsHandler(db DBStatser, startedAt time.Time) *DiagnosticsHandler {
+	return &DiagnosticsHandler{db: db, startedAt: startedAt}
+}
+
+// Diagnostics returns Go runtime, memory, GC and connection pool statistics
+// and the process uptime. Admin only.
+func (h *DiagnosticsHandler) Diagnostics(c *gin.Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	resp := DiagnosticsResponse{
+		StartedAt:     h.startedAt.UTC(),
+		UptimeSeconds: time.Since(h.startedAt).Seconds(),
+		Runtime: RuntimeStats{
+			GoVersion:  runtime.Version(),
+			Goroutines: runtime.NumGoroutine(),
+			NumCPU:     runtime.NumCPU(),
+			GOMAXPROCS: runtime.GOMAXPROCS(0),
+		},
+		Memory: MemoryStats{
+			Alloc:        mem.Alloc,
+			TotalAlloc:   mem.TotalAlloc,
+			Sys:          mem.Sys,
+			HeapAlloc:    mem.HeapAlloc,
+			HeapInuse:    mem.HeapInuse,
+			HeapIdle:     mem.HeapIdle,
+			HeapReleased: mem.HeapReleased,
+			HeapObjects:  mem.HeapObjects,
+			StackInuse:   mem.StackInuse,
+		},
+		GC: GCStats{
+			NumGC:        mem.NumGC,
+			PauseTotalNs: mem.PauseTotalNs,
+			RecentPauses: recentPauses(&mem),
+			NextGC:       mem.NextGC,
+		},
+	}
+	if mem.LastGC > 0 {
+		lastGC := time.Unix(0, int64(mem.LastGC)).UTC()
+		resp.GC.LastGC = &lastGC
+	}
+
+	if h.db != nil {
+		stats := h.db.Stats()
+		resp.Database = &DatabasePoolStats{
+			MaxOpenConnections: stats.MaxOpenConnections,
+			OpenConnections:    stats.OpenConnections,
+			InUse:              stats.InUse,
+			Idle:               stats.Idle,
+			WaitCount:          stats.WaitCount,
+			WaitDurationNs:     int64(stats.WaitDuration),
+			MaxIdleClosed:      stats.MaxIdleClosed,
+			MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+			MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// recentPauses returns the latest GC pauses, newest first. PauseNs is a
+// circular buffer whose most recent entry is at (NumGC+255)%256.
+func recentPauses(mem *runtime.MemStats) []uint64 {
+	n := min(int(mem.NumGC), recentGCPauses, len(mem.PauseNs))
+	pauses := make([]uint64, n)
+	for i := range n {
+		pauses[i] = mem.PauseNs[(int(mem.NumGC)-1-i+len(mem.PauseNs))%len(mem.PauseNs)]
+	}
+	return pauses
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mockDBStatser struct {
+	stats sql.DBStats
+}
+
+func (m mockDBStatser) Stats() sql.DBStats {
+	return m.stats
+}
+
+func TestDiagnosticsHandler_Diagnostics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	runtime.GC() // make sure there is at least one pause to report
+
+	startedAt := time.Now().Add(-time.Minute)
+	handler := NewDiagnosticsHandler(mockDBStatser{stats: sql.DBStats{MaxOpenConnections: 10, OpenConnections: 3, InUse: 1, Idle: 2, WaitCount: 4}}, startedAt)
+	router := gin.New()
+	router.GET("/admin/diagnostics", handler.Diagnostics)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/diagnostics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp DiagnosticsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.UptimeSeconds < 60 {
+		t.Errorf("expected at least a minute of uptime, got %v", resp.UptimeSeconds)
+	}
+	if resp.Runtime.Goroutines < 1 || resp.Runtime.GoVersion == "" || resp.Runtime.NumCPU < 1 {
+		t.Errorf("unexpected runtime stats: %+v", resp.Runtime)
+	}
+	if resp.Memory.HeapAlloc == 0 || resp.Memory.Sys == 0 {
+		t.Errorf("unexpected memory stats: %+v", resp.Memory)
+	}
+	if resp.GC.NumGC == 0 || len(resp.GC.RecentPauses) == 0 || resp.GC.LastGC == nil {
+		t.Errorf("unexpected gc stats: %+v", resp.GC)
+	}
+	if resp.Database == nil || resp.Database.MaxOpenConnections != 10 || resp.Database.OpenConnections != 3 || resp.Database.WaitCount != 4 {
+		t.Errorf("unexpected database stats: %+v", resp.Database)
+	}
+
+	// The pool stats are optional
+	router = gin.New()
+	router.GET("/admin/diagnostics", NewDiagnosticsHandler(nil, startedAt).Diagnostics)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/diagnostics", nil))
+	var raw map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if _, ok := raw["database"]; ok {
+		t.Errorf("expected no database stats without a pool, got %v", raw["database"])
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestContext returns the context of the request, which is cancelled when
+// the client disconnects. Falls back to context.Background() if the request
+// is not available (e.g., in tests).
+func requestContext(c *gin.Context) context.Context {
+	if c.Request == nil {
+		return context.Background()
+	}
+	return c.Request.Context()
+}
+
+// abortIfCanceled handles errors caused by the client going away. Nobody is
+// left to read a response, so it logs at debug level, aborts without writing
+// a body and returns true. Any other error returns false.
+func abortIfCanceled(c *gin.Context, err error) bool {
+	if !errors.Is(err, context.Canceled) {
+		return false
+	}
+	path := ""
+	if c.Request != nil {
+		path = c.Request.URL.Path
+	}
+	logger.FromContext(requestContext(c)).Debug("Requisição cancelada pelo cliente", "path", path, "ip", getClientIP(c))
+	c.Abort()
+	return true
+}
+
+// internalError logs err with its stack trace and the given attributes, then
+// answers 500 with message. Behind middleware.ErrorDetails the problem also
+// carries the error ("details") and the stack ("stack").
+func internalError(c *gin.Context, err error, message string, args ...any) {
+	stack := string(debug.Stack())
+	path := ""
+	if c.Request != nil {
+		path = c.Request.URL.Path
+	}
+	logger.FromContext(requestContext(c)).Error("Erro interno ao processar requisição", append([]any{"error", err, "response", message, "path", path, "stack", stack}, args...)...)
+
+	problem := apierror.Internal(message)
+	if middleware.ErrorDetailsAllowed(c) {
+		problem.With("details", err.Error()).With("stack", stack)
+	}
+	apierror.Respond(c, problem)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestInternalError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer logger.Init("info", "text")
+
+	tests := []struct {
+		name    string
+		verbose bool
+	}{
+		{name: "development exposes details", verbose: true},
+		{name: "production hides details", verbose: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/admin/users", nil)
+			if tt.verbose {
+				middleware.ErrorDetails()(c)
+			}
+			internalError(c, errors.New("database is locked"), "falha ao listar usuários", "user_id", "7")
+
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if body["error"] != "falha ao listar usuários" {
+				t.Errorf("expected generic message, got %v", body["error"])
+			}
+			_, hasStack := body["stack"]
+			if tt.verbose {
+				if body["details"] != "database is locked" || !hasStack {
+					t.Errorf("expected details and stack, got %s", w.Body.String())
+				}
+			} else if _, hasDetails := body["details"]; hasDetails || hasStack {
+				t.Errorf("expected no internal details, got %s", w.Body.String())
+			}
+
+			// The full detail is always logged
+			for _, want := range []string{"level=ERROR", "database is locked", "user_id=7", "stack="} {
+				if !strings.Contains(logs.String(), want) {
+					t.Errorf("expected log to contain %q, got %q", want, logs.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FileHandler serves the signed URLs of storage.Local. Files in S3 are
+// downloaded from the bucket directly.
+type FileHandler struct {
+	store *storage.Local
+}
+
+// NewFileHandler creates a FileHandler
+func NewFileHandler(store *storage.Local) *FileHandler {
+	return &FileHandler{store: store}
+}
+
+// Serve answers GET /files/*key. The signature in the query string is the
+// only authorization, so the route is public.
+func (h *FileHandler) Serve(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	expires := c.Query("expires")
+	if err := h.store.Verify(key, expires, c.Query("signature")); err != nil {
+		apierror.Respond(c, apierror.Forbidden(err.Error()))
+		return
+	}
+
+	object, err := h.store.Get(requestContext(c), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		apierror.Respond(c, apierror.NotFound(err.Error()))
+		return
+	}
+	if err != nil {
+		internalError(c, err, "falha ao ler arquivo", "key", key)
+		return
+	}
+	defer object.Body.Close()
+
+	// Cacheable by the browser until the link expires
+	maxAge := int64(0)
+	if unix, err := strconv.ParseInt(expires, 10, 64); err == nil {
+		maxAge = max(unix-time.Now().Unix(), 0)
+	}
+	c.DataFromReader(http.StatusOK, object.Size, object.ContentType, object.Body, map[string]string{
+		"Cache-Control":          fmt.Sprintf("private, max-age=%d", maxAge),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gosveltekit/internal/healthcheck"
+	"gosveltekit/internal/middleware"
+	"gosveltekit/internal/version"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler handles health-related HTTP requests
+type HealthHandler struct {
+	aggregator    *healthcheck.Aggregator
+	versionMaxAge time.Duration
+}
+
+// HealthHandlerOption configures a HealthHandler
+type HealthHandlerOption func(*HealthHandler)
+
+// WithVersionMaxAge lets clients and CDNs reuse the Version response for
+// maxAge without revalidating it. Zero, the default, makes them revalidate
+// every time.
+func WithVersionMaxAge(maxAge time.Duration) HealthHandlerOption {
+	return func(h *HealthHandler) {
+		h.versionMaxAge = maxAge
+	}
+}
+
+// NewHealthHandler creates a new HealthHandler instance
+func NewHealthHandler(aggregator *healthcheck.Aggregator, opts ...HealthHandlerOption) *HealthHandler {
+	if aggregator == nil {
+		aggregator = healthcheck.NewAggregator(0)
+	}
+	h := &HealthHandler{aggregator: aggregator}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Liveness reports that the process is up and serving HTTP. It checks no
+// dependency, so a database outage doesn't get the process restarted.
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": healthcheck.StatusOK})
+}
+
+// Readiness reports the health of each component.
+//
+// It responds 200 while the application can serve requests (ok or degraded)
+// and 503 when a critical component is down.
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	report := h.aggregator.Check(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status == healthcheck.StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, report)
+}
+
+// Version reports the running build. It only changes on a deploy, so the
+// response is public and answers If-None-Match with 304 Not Modified.
+func (h *HealthHandler) Version(c *gin.Context) {
+	info := version.Get()
+	etag := middleware.VersionETag(info.Version, info.Commit, info.BuildTime, info.GoVersion, strconv.FormatBool(info.Modified))
+	c.Header("ETag", etag)
+	if h.versionMaxAge > 0 {
+		c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(h.versionMaxAge.Seconds())))
+	} else {
+		c.Header("Cache-Control", "public, no-cache")
+	}
+
+	if middleware.ETagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/healthcheck"
+	"gosveltekit/internal/middleware"
+	"gosveltekit/internal/version"
+)
+
+func TestHealthHandler_Readiness(t *testing.T) {
+	tests := []struct {
+		name           string
+		databaseErr    error
+		expectedStatus int
+		expectedHealth healthcheck.Status
+	}{
+		{name: "Healthy", expectedStatus: http.StatusOK, expectedHealth: healthcheck.StatusOK},
+		{name: "Database down", databaseErr: errors.New("down"), expectedStatus: http.StatusServiceUnavailable, expectedHealth: healthcheck.StatusDown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			c.Request, _ = http.NewRequest(http.MethodGet, "/readyz", nil)
+
+			checker := healthcheck.CheckerFunc(func(ctx context.Context) error { return tt.databaseErr })
+			handler := NewHealthHandler(healthcheck.NewAggregator(time.Second,
+				healthcheck.Component{Name: "database", Checker: checker, Critical: true},
+			))
+
+			handler.Readiness(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			var report healthcheck.Report
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if report.Status != tt.expectedHealth {
+				t.Errorf("expected health %s, got %s", tt.expectedHealth, report.Status)
+			}
+			if report.Components["database"] != tt.expectedHealth {
+				t.Errorf("expected database component %s, got %s", tt.expectedHealth, report.Components["database"])
+			}
+		})
+	}
+}
+
+func TestHealthHandler_Liveness(t *testing.T) {
+	c, w := setupTestRouter()
+	c.Request, _ = http.NewRequest(http.MethodGet, "/healthz", nil)
+
+	// Liveness checks no component, even a failing one
+	checker := healthcheck.CheckerFunc(func(ctx context.Context) error { return errors.New("down") })
+	handler := NewHealthHandler(healthcheck.NewAggregator(time.Second,
+		healthcheck.Component{Name: "database", Checker: checker, Critical: true},
+	))
+	handler.Liveness(c)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if body := w.Body.String(); body != `{"status":"ok"}` {
+		t.Errorf("unexpected body %s", body)
+	}
+}
+
+func TestHealthHandler_Version(t *testing.T) {
+	c, w := setupTestRouter()
+	c.Request, _ = http.NewRequest(http.MethodGet, "/version", nil)
+
+	NewHealthHandler(nil).Version(c)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var info version.Info
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if info.Version == "" || info.GoVersion == "" {
+		t.Errorf("expected version and Go version, got %+v", info)
+	}
+}
+
+func TestHealthHandler_VersionCaching(t *testing.T) {
+	handler := NewHealthHandler(nil, WithVersionMaxAge(5*time.Minute))
+
+	c, w := setupTestRouter()
+	c.Request, _ = http.NewRequest(http.MethodGet, "/version", nil)
+	handler.Version(c)
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=300" {
+		t.Errorf("expected Cache-Control %q, got %q", "public, max-age=300", got)
+	}
+	info := version.Get()
+	etag := middleware.VersionETag(info.Version, info.Commit, info.BuildTime, info.GoVersion, strconv.FormatBool(info.Modified))
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("expected ETag %q, got %q", etag, got)
+	}
+
+	c, w = setupTestRouter()
+	c.Request, _ = http.NewRequest(http.MethodGet, "/version", nil)
+	c.Request.Header.Set("If-None-Match", etag)
+	handler.Version(c)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected an empty %d, got %d %s", http.StatusNotModified, w.Code, w.Body.String())
+	}
+
+	// Without a max-age clients revalidate every time
+	c, w = setupTestRouter()
+	c.Request, _ = http.NewRequest(http.MethodGet, "/version", nil)
+	NewHealthHandler(nil).Version(c)
+	if got := w.Header().Get("Cache-Control"); got != "public, no-cache" {
+		t.Errorf("expected Cache-Control %q, got %q", "public, no-cache", got)
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("expected ETag %q, got %q", etag, got)
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/jobs"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobQueue exposes the state of the background jobs, see jobs.Queue
+type JobQueue interface {
+	List(ctx context.Context, filter jobs.Filter, offset, limit int) ([]*models.Job, int64, error)
+	Get(ctx context.Context, id uint) (*models.Job, error)
+	Counts(ctx context.Context) (map[string]int64, error)
+	Types() []string
+}
+
+var jobStatuses = []string{models.JobStatusPending, models.JobStatusRunning, models.JobStatusSucceeded, models.JobStatusFailed}
+
+// JobHandler handles admin job HTTP requests
+type JobHandler struct {
+	queue JobQueue
+}
+
+// NewJobHandler creates a new JobHandler instance
+func NewJobHandler(queue JobQueue) *JobHandler {
+	return &JobHandler{queue: queue}
+}
+
+// List returns a page of the jobs, most recent first. Accepts page and
+// page_size plus the filters status and type. Finished jobs are only kept for
+// the configured retention.
+func (h *JobHandler) List(c *gin.Context) {
+	params, err := pagination.Parse(c)
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	filter := jobs.Filter{Status: c.Query("status"), Type: c.Query("type")}
+	if filter.Status != "" && !slices.Contains(jobStatuses, filter.Status) {
+		apierror.Respond(c, apierror.BadRequest("status deve ser pending, running, succeeded ou failed"))
+		return
+	}
+
+	list, total, err := h.queue.List(requestContext(c), filter, params.Offset(), params.Limit())
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao listar jobs")
+		return
+	}
+
+	items := make([]dto.JobResponse, len(list))
+	for i, job := range list {
+		items[i] = dto.NewJobResponse(job)
+	}
+	c.JSON(http.StatusOK, dto.NewListResponse(items, pagination.NewMeta(params, total)))
+}
+
+// Get returns the job in the :id path parameter
+func (h *JobHandler) Get(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		apierror.Respond(c, apierror.NotFound(jobs.ErrNotFound.Error()))
+		return
+	}
+
+	job, err := h.queue.Get(requestContext(c), uint(id))
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		if errors.Is(err, jobs.ErrNotFound) {
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+			return
+		}
+		internalError(c, err, "falha ao buscar job")
+		return
+	}
+	c.JSON(http.StatusOK, dto.NewJobResponse(job))
+}
+
+// Stats returns how many jobs are in each status and the job types this
+// instance runs
+func (h *JobHandler) Stats(c *gin.Context) {
+	counts, err := h.queue.Counts(requestContext(c))
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao contar jobs")
+		return
+	}
+	c.JSON(http.StatusOK, dto.JobStatsResponse{Counts: counts, Types: h.queue.Types()})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/jobs"
+	"gosveltekit/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mockJobQueue struct {
+	jobs []*models.Job
+
+	filter        jobs.Filter
+	offset, limit int
+}
+
+func (m *mockJobQueue) List(ctx context.Context, filter jobs.Filter, offset, limit int) ([]*models.Job, int64, error) {
+	m.filter, m.offset, m.limit = filter, offset, limit
+	return m.jobs, int64(len(m.jobs)), nil
+}
+
+func (m *mockJobQueue) Get(ctx context.Context, id uint) (*models.Job, error) {
+	for _, job := range m.jobs {
+		if job.ID == id {
+			return job, nil
+		}
+	}
+	return nil, jobs.ErrNotFound
+}
+
+func (m *mockJobQueue) Counts(ctx context.Context) (map[string]int64, error) {
+	return map[string]int64{models.JobStatusPending: 1, models.JobStatusFailed: 2}, nil
+}
+
+func (m *mockJobQueue) Types() []string {
+	return []string{"email.welcome"}
+}
+
+func TestJobHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	queue := &mockJobQueue{jobs: []*models.Job{
+		{ID: 3, Type: "email.welcome", Status: models.JobStatusFailed, Attempts: 5, MaxAttempts: 5, LastError: "smtp down", Payload: `{"recipient":"secret@example.com"}`, RunAt: time.Now()},
+	}}
+	h := NewJobHandler(queue)
+	router := gin.New()
+	router.GET("/admin/jobs", h.List)
+	router.GET("/admin/jobs/stats", h.Stats)
+	router.GET("/admin/jobs/:id", h.Get)
+
+	t.Run("list with filters", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/jobs?status=failed&type=email.welcome&page=2&page_size=10", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if queue.filter != (jobs.Filter{Status: models.JobStatusFailed, Type: "email.welcome"}) {
+			t.Errorf("unexpected filter %+v", queue.filter)
+		}
+		if queue.offset != 10 || queue.limit != 10 {
+			t.Errorf("expected offset 10 and limit 10, got %d and %d", queue.offset, queue.limit)
+		}
+
+		var resp dto.ListResponse[dto.JobResponse]
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(resp.Data) != 1 || resp.Data[0].ID != "3" || resp.Data[0].LastError != "smtp down" {
+			t.Errorf("unexpected response: %s", w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "secret@example.com") {
+			t.Errorf("payload leaked: %s", w.Body.String())
+		}
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/jobs?status=stuck", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		for path, status := range map[string]int{
+			"/admin/jobs/3":    http.StatusOK,
+			"/admin/jobs/4":    http.StatusNotFound,
+			"/admin/jobs/spam": http.StatusNotFound,
+		} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != status {
+				t.Errorf("%s: expected status %d, got %d", path, status, w.Code)
+			}
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/jobs/stats", nil))
+		var resp dto.JobStatsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Counts[models.JobStatusFailed] != 2 || len(resp.Types) != 1 {
+			t.Errorf("unexpected response: %s", w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/audit"
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LockoutManager lists and lifts failed login lockouts, see auth.AuthManager
+type LockoutManager interface {
+	Lockouts(ctx context.Context) ([]auth.Lockout, error)
+	Unlock(ctx context.Context, kind, key string) error
+	UnlockUser(ctx context.Context, userID string) error
+}
+
+// LockoutHandler handles admin lockout HTTP requests
+type LockoutHandler struct {
+	manager LockoutManager
+	auditor
+}
+
+// NewLockoutHandler creates a new LockoutHandler instance
+func NewLockoutHandler(manager LockoutManager, opts ...AdminOption) *LockoutHandler {
+	h := &LockoutHandler{manager: manager}
+	for _, opt := range opts {
+		opt(&h.auditor)
+	}
+	return h
+}
+
+// List returns the identifiers and client IPs locked out by failed logins,
+// most recent first
+func (h *LockoutHandler) List(c *gin.Context) {
+	lockouts, err := h.manager.Lockouts(requestContext(c))
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao listar bloqueios de login")
+		return
+	}
+
+	items := make([]dto.LockoutResponse, len(lockouts))
+	for i, lockout := range lockouts {
+		items[i] = dto.NewLockoutResponse(lockout)
+	}
+	c.JSON(http.StatusOK, dto.LockoutListResponse{Lockouts: items})
+}
+
+// Unlock forgets the failed logins of the :kind ("account" or "ip") and :key
+// path parameters, as listed by List
+func (h *LockoutHandler) Unlock(c *gin.Context) {
+	kind, key := c.Param("kind"), c.Param("key")
+	if kind != auth.LoginAttemptsAccount && kind != auth.LoginAttemptsIP {
+		apierror.Respond(c, apierror.BadRequest("tipo de bloqueio deve ser account ou ip"))
+		return
+	}
+
+	if err := h.manager.Unlock(requestContext(c), kind, key); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao remover bloqueio de login")
+		return
+	}
+
+	logger.FromContext(requestContext(c)).Info("Bloqueio de login removido por administrador", "admin_id", c.GetString("userID"), "kind", kind, "ip", getClientIP(c))
+	h.record(c, audit.Event{Type: audit.EventLockoutRemoved, Data: map[string]any{"kind": kind, "key": key}})
+	c.JSON(http.StatusOK, gin.H{"message": "bloqueio removido com sucesso"})
+}
+
+// UnlockUser lifts every lockout of the user in the :id path parameter:
+// logins with their username or email, and their two-factor codes
+func (h *LockoutHandler) UnlockUser(c *gin.Context) {
+	userID := c.Param("id")
+	if err := h.manager.UnlockUser(requestContext(c), userID); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, auth.ErrUserNotFound):
+			apierror.Respond(c, apierror.NotFound("usuário não encontrado"))
+		default:
+			internalError(c, err, "falha ao desbloquear usuário")
+		}
+		return
+	}
+
+	logger.FromContext(requestContext(c)).Info("Usuário desbloqueado por administrador", "admin_id", c.GetString("userID"), "user_id", userID, "ip", getClientIP(c))
+	h.record(c, audit.Event{Type: audit.EventUserUnlocked, TargetID: userID})
+	c.JSON(http.StatusOK, gin.H{"message": "usuário desbloqueado com sucesso"})
+}
@@ -0,0 +1,88 @@
+// Package handlers tests
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeLockoutManager struct {
+	lockouts []auth.Lockout
+	unlocked []string
+}
+
+func (f *fakeLockoutManager) Lockouts(ctx context.Context) ([]auth.Lockout, error) {
+	return f.lockouts, nil
+}
+
+func (f *fakeLockoutManager) Unlock(ctx context.Context, kind, key string) error {
+	f.unlocked = append(f.unlocked, kind+" "+key)
+	return nil
+}
+
+func (f *fakeLockoutManager) UnlockUser(ctx context.Context, userID string) error {
+	if userID != "1" {
+		return auth.ErrUserNotFound
+	}
+	f.unlocked = append(f.unlocked, "user "+userID)
+	return nil
+}
+
+func TestLockoutHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	lockedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	manager := &fakeLockoutManager{lockouts: []auth.Lockout{
+		{Kind: auth.LoginAttemptsIP, Key: "192.0.2.1", Failures: 20, LockedAt: lockedAt, LockedUntil: lockedAt.Add(30 * time.Minute)},
+	}}
+	handler := NewLockoutHandler(manager)
+	router := gin.New()
+	router.GET("/admin/lockouts", handler.List)
+	router.DELETE("/admin/lockouts/:kind/:key", handler.Unlock)
+	router.POST("/admin/users/:id/unlock", handler.UnlockUser)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/lockouts", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	want := `{"lockouts":[{"kind":"ip","key":"192.0.2.1","failures":20,"locked_at":"2026-01-02T03:04:05Z","locked_until":"2026-01-02T03:34:05Z"}]}`
+	if w.Body.String() != want {
+		t.Errorf("expected body %s, got %s", want, w.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{"unlock ip", http.MethodDelete, "/admin/lockouts/ip/192.0.2.1", http.StatusOK},
+		{"unlock account", http.MethodDelete, "/admin/lockouts/account/test@example.com", http.StatusOK},
+		{"unknown kind", http.MethodDelete, "/admin/lockouts/user/1", http.StatusBadRequest},
+		{"unlock user", http.MethodPost, "/admin/users/1/unlock", http.StatusOK},
+		{"unknown user", http.MethodPost, "/admin/users/999/unlock", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	want = "[ip 192.0.2.1 account test@example.com user 1]"
+	if got := fmt.Sprint(manager.unlocked); got != want {
+		t.Errorf("expected unlocks %s, got %s", want, got)
+	}
+}
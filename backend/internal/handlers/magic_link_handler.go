@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MagicLinkRequest asks for a passwordless login link
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// MagicLinkLoginRequest carries the token of a login link
+type MagicLinkLoginRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RequestMagicLink emails a single-use login link. The response is the same
+// whether or not the address belongs to an account.
+func (h *AuthHandler) RequestMagicLink(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.FromContext(requestContext(c)).Debug("Requisição de link de acesso com JSON inválido", "error", err, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	ip := getClientIP(c)
+	if err := h.authService.RequestMagicLink(requestContext(c), req.Email, ip); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrMagicLinkDisabled) {
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+			return
+		}
+		internalError(c, err, "falha ao enviar link de acesso", "ip", ip)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "se o email estiver cadastrado, um link de acesso será enviado"})
+}
+
+// VerifyMagicLink exchanges the token of a login link for a session, answering
+// like Login: users with TOTP get a challenge instead
+func (h *AuthHandler) VerifyMagicLink(c *gin.Context) {
+	var req MagicLinkLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	ip := getClientIP(c)
+	userAgent := ""
+	if c.Request != nil {
+		userAgent = c.Request.UserAgent()
+	}
+
+	response, err := h.authService.LoginWithMagicLink(requestContext(c), req.Token, ip, userAgent)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidMagicLink):
+			apierror.Respond(c, apierror.Unauthorized(err.Error()))
+		case errors.Is(err, service.ErrMagicLinkDisabled):
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+		case errors.Is(err, service.ErrUserNotActive):
+			apierror.Respond(c, apierror.Unauthorized("usuário inativo"))
+		case errors.Is(err, service.ErrAccountPendingDeletion):
+			apierror.Respond(c, apierror.Forbidden(err.Error()))
+		case errors.Is(err, service.ErrTooManySessions):
+			apierror.Respond(c, apierror.Conflict(err.Error()))
+		default:
+			internalError(c, err, "falha ao entrar com link de acesso", "ip", ip)
+		}
+		return
+	}
+
+	if challenge := response.TwoFactor; challenge != nil {
+		c.JSON(http.StatusAccepted, dto.NewTwoFactorChallengeResponse(challenge.Token, challenge.ExpiresAt, challenge.Method))
+		return
+	}
+
+	c.JSON(http.StatusOK, sessionResponse(c, response))
+}
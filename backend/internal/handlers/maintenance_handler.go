@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gosveltekit/internal/cleanup"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenPruner removes expired tokens, see cleanup.Worker
+type TokenPruner interface {
+	Prune(ctx context.Context) (cleanup.Result, error)
+}
+
+// MaintenanceHandler handles admin maintenance HTTP requests
+type MaintenanceHandler struct {
+	pruner TokenPruner
+}
+
+// CleanupTokensResponse reports CleanupTokens as a batch with one item per
+// token type, in the order of cleanup.TokenTypes, plus the tokens removed
+type CleanupTokensResponse struct {
+	dto.BatchResult
+	Deleted cleanup.Result `json:"deleted"`
+	Total   int64          `json:"total"`
+}
+
+// NewMaintenanceHandler creates a new MaintenanceHandler instance
+func NewMaintenanceHandler(pruner TokenPruner) *MaintenanceHandler {
+	return &MaintenanceHandler{pruner: pruner}
+}
+
+// CleanupTokens prunes expired tokens right away, with the same queries as
+// the background cleanup, and returns how many were removed per type. A type
+// that fails is reported as a failed item without stopping the others.
+func (h *MaintenanceHandler) CleanupTokens(c *gin.Context) {
+	result, err := h.pruner.Prune(requestContext(c))
+	var failed cleanup.Errors
+	if err != nil && !errors.As(err, &failed) {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao remover tokens expirados")
+		return
+	}
+
+	log := logger.FromContext(requestContext(c))
+	batch := dto.NewBatchResult()
+	for i, tokenType := range cleanup.TokenTypes {
+		if err, ok := failed[tokenType]; ok {
+			log.Error("Erro ao remover tokens expirados", "error", err, "type", tokenType)
+			batch.Fail(i, tokenType, errors.New("falha ao remover tokens expirados deste tipo"))
+			continue
+		}
+		batch.Succeed(i, tokenType)
+	}
+
+	log.Info("Limpeza de tokens executada manualmente", "admin_id", c.GetString("userID"), "total", result.Total(), "by_type", result, "failed", batch.Failed)
+	c.JSON(http.StatusOK, CleanupTokensResponse{BatchResult: *batch, Deleted: result, Total: result.Total()})
+}
@@ -0,0 +1,90 @@
+// Package handlers tests
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gosveltekit/internal/cleanup"
+	"gosveltekit/internal/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mockTokenPruner struct {
+	result cleanup.Result
+	err    error
+	calls  int
+}
+
+func (m *mockTokenPruner) Prune(ctx context.Context) (cleanup.Result, error) {
+	m.calls++
+	return m.result, m.err
+}
+
+func TestMaintenanceHandler_CleanupTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	pruner := &mockTokenPruner{result: cleanup.Result{cleanup.TokenSession: 3, cleanup.TokenSMSCode: 1}}
+	handler := NewMaintenanceHandler(pruner)
+	router := gin.New()
+	router.POST("/admin/cleanup-tokens", handler.CleanupTokens)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/cleanup-tokens", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var resp CleanupTokensResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Total != 4 || resp.Deleted[cleanup.TokenSession] != 3 || resp.Deleted[cleanup.TokenSMSCode] != 1 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.Succeeded != len(cleanup.TokenTypes) || resp.Failed != 0 || len(resp.Results) != len(cleanup.TokenTypes) {
+		t.Errorf("expected one succeeded item per token type, got %+v", resp.BatchResult)
+	}
+	if pruner.calls != 1 {
+		t.Errorf("expected prune to run once, ran %d times", pruner.calls)
+	}
+
+	// A failed type is reported without hiding the others
+	pruner.err = cleanup.Errors{cleanup.TokenSMSCode: errors.New("no such table: sms_codes")}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/cleanup-tokens", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	resp = CleanupTokensResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Succeeded != len(cleanup.TokenTypes)-1 || resp.Failed != 1 {
+		t.Errorf("expected one failed item, got %+v", resp.BatchResult)
+	}
+	for i, item := range resp.Results {
+		if item.Index != i || item.ID != cleanup.TokenTypes[i] {
+			t.Errorf("expected item %d to be %s, got %+v", i, cleanup.TokenTypes[i], item)
+		}
+		failed := item.ID == cleanup.TokenSMSCode
+		if failed != (item.Status == dto.BatchStatusFailed) || failed != (item.Error != "") {
+			t.Errorf("unexpected item %+v", item)
+		}
+		if strings.Contains(item.Error, "sms_codes") {
+			t.Errorf("database error leaked to the client: %q", item.Error)
+		}
+	}
+
+	pruner.err = errors.New("database is down")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/cleanup-tokens", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
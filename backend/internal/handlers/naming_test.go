@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/healthcheck"
+	"gosveltekit/internal/pagination"
+	"gosveltekit/internal/validation"
+)
+
+func TestJSONNaming(t *testing.T) {
+	for _, v := range []any{
+		LoginRequest{},
+		RegistrationRequest{},
+		PasswordResetRequest{},
+		ChangePasswordRequest{},
+		CheckEmailRequest{},
+		DeleteAccountRequest{},
+		RestoreAccountRequest{},
+		RevokeTokenRequest{},
+		RefreshRequest{},
+		TwoFactorLoginRequest{},
+		MagicLinkRequest{},
+		MagicLinkLoginRequest{},
+		TOTPCodeRequest{},
+		DisableTOTPRequest{},
+		UpdateRoleRequest{},
+		ExpirePasswordRequest{},
+		BatchUserActionRequest{},
+		CleanupTokensResponse{},
+		DiagnosticsResponse{},
+		UpdateSettingRequest{},
+		SettingsResponse{},
+		CreateAPIKeyRequest{},
+		dto.ListResponse[dto.AdminSessionResponse]{},
+		dto.CreatedAPIKeyResponse{},
+		dto.APIKeyListResponse{},
+		healthcheck.Report{},
+		pagination.Meta{},
+		validation.PasswordPolicy{},
+	} {
+		for _, violation := range dto.JSONNamingViolations(v) {
+			t.Errorf("%T: %s", v, violation)
+		}
+	}
+}
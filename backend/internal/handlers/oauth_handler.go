@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/auth/oauth"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/middleware"
+	"gosveltekit/internal/service"
+	"gosveltekit/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthStateCookieName is the cookie holding the state and PKCE verifier of a
+// login in progress, scoped to the callback path
+const OAuthStateCookieName = "oauth_state"
+
+// oauthStateMaxAge is how long the user has to finish logging in at the provider
+const oauthStateMaxAge = 10 * time.Minute
+
+// Error codes of the callback, sent to the frontend as ?error=
+const (
+	OAuthErrorAccessDenied     = "access_denied"
+	OAuthErrorInvalidState     = "invalid_state"
+	OAuthErrorProvider         = "provider_error"
+	OAuthErrorEmailNotVerified = "email_not_verified"
+	OAuthErrorAccountConflict  = "account_conflict"
+	OAuthErrorRegistration     = "registration_closed"
+	OAuthErrorLoginRefused     = "login_refused"
+	OAuthErrorServer           = "server_error"
+)
+
+// OAuthAuthenticator logs in users verified by an OAuth provider, see
+// service.AuthService
+type OAuthAuthenticator interface {
+	OAuthLogin(ctx context.Context, identity *oauth.Identity, ip, userAgent string) (*service.LoginResponse, error)
+}
+
+// OAuthHandler handles social login HTTP requests
+type OAuthHandler struct {
+	authenticator OAuthAuthenticator
+	providers     map[string]oauth.Provider
+	redirectURL   string
+	tokens        tokens.Generator
+}
+
+// OAuthHandlerOption configures an OAuthHandler
+type OAuthHandlerOption func(*OAuthHandler)
+
+// WithOAuthTokenGenerator sets the random source of the state and PKCE
+// verifier. Defaults to tokens.Secure.
+func WithOAuthTokenGenerator(g tokens.Generator) OAuthHandlerOption {
+	return func(h *OAuthHandler) {
+		h.tokens = g
+	}
+}
+
+// NewOAuthHandler creates a new OAuthHandler instance. After the callback the
+// browser is sent to redirectURL, with ?error=<code> on failure; an empty
+// redirectURL answers in JSON instead, as POST /auth/login does.
+func NewOAuthHandler(authenticator OAuthAuthenticator, providers []oauth.Provider, redirectURL string, opts ...OAuthHandlerOption) *OAuthHandler {
+	byName := make(map[string]oauth.Provider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+	h := &OAuthHandler{authenticator: authenticator, providers: byName, redirectURL: redirectURL, tokens: tokens.Secure{}}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Login starts the authorization code flow, redirecting the browser to the
+// provider. The state and PKCE verifier wait in a short-lived HttpOnly cookie.
+func (h *OAuthHandler) Login(c *gin.Context) {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		apierror.Respond(c, apierror.NotFound("provedor de login desconhecido"))
+		return
+	}
+
+	state, err := oauth.NewState(h.tokens)
+	if err != nil {
+		internalError(c, err, "falha ao iniciar login social")
+		return
+	}
+	verifier, err := oauth.NewVerifier(h.tokens)
+	if err != nil {
+		internalError(c, err, "falha ao iniciar login social")
+		return
+	}
+	link, err := provider.AuthCodeURL(requestContext(c), state, verifier)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		logger.FromContext(requestContext(c)).Error("Erro ao iniciar login social", "error", err, "provider", provider.Name(), "ip", getClientIP(c))
+		apierror.Respond(c, apierror.New(http.StatusBadGateway, "provedor de login indisponível"))
+		return
+	}
+
+	// The provider comes back with a cross-site top-level GET, which Lax allows
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OAuthStateCookieName, state+"."+verifier, int(oauthStateMaxAge.Seconds()), callbackPath(c), "", middleware.CookieOptionsFrom(c).Secure, true)
+	c.Redirect(http.StatusFound, link)
+}
+
+// Callback finishes the flow: it checks the state, exchanges the code for the
+// user's identity and creates a session, set in the session cookie
+func (h *OAuthHandler) Callback(c *gin.Context) {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		apierror.Respond(c, apierror.NotFound("provedor de login desconhecido"))
+		return
+	}
+	ip := getClientIP(c)
+
+	// Single use: cleared whatever the outcome
+	stored, _ := c.Cookie(OAuthStateCookieName)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OAuthStateCookieName, "", -1, callbackPath(c), "", middleware.CookieOptionsFrom(c).Secure, true)
+
+	if denied := c.Query("error"); denied != "" {
+		logger.FromContext(requestContext(c)).Info("Login social cancelado no provedor", "provider", provider.Name(), "error", denied, "ip", ip)
+		h.fail(c, http.StatusUnauthorized, OAuthErrorAccessDenied, "login cancelado no provedor")
+		return
+	}
+	state, verifier, _ := strings.Cut(stored, ".")
+	if state == "" || verifier == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		logger.FromContext(requestContext(c)).Warn("Callback de login social com state inválido", "provider", provider.Name(), "ip", ip)
+		h.fail(c, http.StatusBadRequest, OAuthErrorInvalidState, "login expirado ou iniciado em outro navegador, tente novamente")
+		return
+	}
+
+	ctx := requestContext(c)
+	identity, err := provider.Exchange(ctx, c.Query("code"), verifier)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		logger.FromContext(requestContext(c)).Warn("Erro ao obter identidade do provedor", "error", err, "provider", provider.Name(), "ip", ip)
+		h.fail(c, http.StatusBadGateway, OAuthErrorProvider, "não foi possível confirmar o login com o provedor")
+		return
+	}
+
+	userAgent := ""
+	if c.Request != nil {
+		userAgent = c.Request.UserAgent()
+	}
+	response, err := h.authenticator.OAuthLogin(ctx, identity, ip, userAgent)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrOAuthEmailNotVerified):
+			h.fail(c, http.StatusUnauthorized, OAuthErrorEmailNotVerified, err.Error())
+		case errors.Is(err, service.ErrOAuthAccountConflict):
+			h.fail(c, http.StatusConflict, OAuthErrorAccountConflict, err.Error())
+		case errors.Is(err, service.ErrRegistrationClosed):
+			h.fail(c, http.StatusForbidden, OAuthErrorRegistration, err.Error())
+		case errors.Is(err, service.ErrUserNotActive):
+			h.fail(c, http.StatusUnauthorized, OAuthErrorLoginRefused, "usuário inativo")
+		case errors.Is(err, service.ErrAccountPendingDeletion):
+			h.fail(c, http.StatusForbidden, OAuthErrorLoginRefused, err.Error())
+		case errors.Is(err, service.ErrTooManySessions):
+			h.fail(c, http.StatusConflict, OAuthErrorLoginRefused, err.Error())
+		default:
+			if h.redirectURL == "" {
+				internalError(c, err, "falha ao fazer login social", "provider", provider.Name(), "ip", ip)
+				return
+			}
+			h.fail(c, http.StatusInternalServerError, OAuthErrorServer, "falha ao fazer login social")
+		}
+		return
+	}
+
+	// TOTP users finish the login on the frontend, with the challenge token
+	if challenge := response.TwoFactor; challenge != nil {
+		if h.redirectURL == "" {
+			c.JSON(http.StatusAccepted, dto.NewTwoFactorChallengeResponse(challenge.Token, challenge.ExpiresAt, challenge.Method))
+			return
+		}
+		h.redirect(c, "two_factor_token", challenge.Token)
+		return
+	}
+
+	body := sessionResponse(c, response)
+	if h.redirectURL == "" {
+		c.JSON(http.StatusOK, body)
+		return
+	}
+	c.Redirect(http.StatusFound, h.redirectURL)
+}
+
+// fail sends the browser back to the frontend with ?error=code, or answers
+// with a problem without a redirect URL
+func (h *OAuthHandler) fail(c *gin.Context, status int, code, message string) {
+	if h.redirectURL == "" {
+		apierror.Respond(c, apierror.New(status, message).WithCode(code))
+		return
+	}
+	h.redirect(c, "error", code)
+}
+
+// redirect sends the browser back to the frontend with one query parameter
+func (h *OAuthHandler) redirect(c *gin.Context, key, value string) {
+	separator := "?"
+	if strings.Contains(h.redirectURL, "?") {
+		separator = "&"
+	}
+	c.Redirect(http.StatusFound, h.redirectURL+separator+key+"="+url.QueryEscape(value))
+}
+
+// callbackPath returns the callback path of the provider named in the
+// request, which is either its login or its callback route
+func callbackPath(c *gin.Context) string {
+	path := strings.TrimSuffix(c.Request.URL.Path, "/callback")
+	return strings.TrimSuffix(path, "/login") + "/callback"
+}
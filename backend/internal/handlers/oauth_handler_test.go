@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/auth/oauth"
+	"gosveltekit/internal/middleware"
+	"gosveltekit/internal/service"
+	"gosveltekit/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mockOAuthProvider struct {
+	verifier string
+}
+
+func (p *mockOAuthProvider) Name() string { return "github" }
+
+func (p *mockOAuthProvider) AuthCodeURL(ctx context.Context, state, verifier string) (string, error) {
+	return "https://provider.example.com/authorize?state=" + url.QueryEscape(state), nil
+}
+
+func (p *mockOAuthProvider) Exchange(ctx context.Context, code, verifier string) (*oauth.Identity, error) {
+	p.verifier = verifier
+	if code != "good-code" {
+		return nil, errors.New("invalid_grant")
+	}
+	return &oauth.Identity{Provider: "github", Subject: "42", Email: "jane@example.com", EmailVerified: true}, nil
+}
+
+type mockOAuthAuthenticator struct {
+	err       error
+	challenge *service.TwoFactorChallenge
+}
+
+func (m *mockOAuthAuthenticator) OAuthLogin(ctx context.Context, identity *oauth.Identity, ip, userAgent string) (*service.LoginResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.challenge != nil {
+		return &service.LoginResponse{TwoFactor: m.challenge}, nil
+	}
+	return &service.LoginResponse{SessionID: "session-1", ExpiresAt: time.Now().Add(time.Hour), User: auth.UserData{ID: "1", Identifier: "jane"}}, nil
+}
+
+// startOAuthLogin calls the login route and returns the state sent to the
+// provider and the state cookie
+func startOAuthLogin(t *testing.T, router *gin.Engine) (string, *http.Cookie) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oauth/github/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusFound, w.Code, w.Body.String())
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || location.Host != "provider.example.com" {
+		t.Fatalf("expected a redirect to the provider, got %q", w.Header().Get("Location"))
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == OAuthStateCookieName {
+			if !cookie.HttpOnly || cookie.Path != "/auth/oauth/github/callback" || cookie.SameSite != http.SameSiteLaxMode {
+				t.Errorf("state cookie not locked down: %+v", cookie)
+			}
+			return location.Query().Get("state"), cookie
+		}
+	}
+	t.Fatal("expected the state cookie")
+	return "", nil
+}
+
+func TestOAuthHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	provider := &mockOAuthProvider{}
+	authenticator := &mockOAuthAuthenticator{}
+	newRouter := func(redirectURL string) *gin.Engine {
+		h := NewOAuthHandler(authenticator, []oauth.Provider{provider}, redirectURL, WithOAuthTokenGenerator(tokens.NewDeterministic("oauth")))
+		router := gin.New()
+		router.Use(middleware.Cookies(middleware.CookieOptions{SameSite: http.SameSiteLaxMode}))
+		router.GET("/auth/oauth/:provider/login", h.Login)
+		router.GET("/auth/oauth/:provider/callback", h.Callback)
+		return router
+	}
+	callback := func(router *gin.Engine, query string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/auth/oauth/github/callback?"+query, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("redirects with a session", func(t *testing.T) {
+		router := newRouter("https://app.example.com/login/done")
+		state, cookie := startOAuthLogin(t, router)
+		_, verifier, _ := strings.Cut(cookie.Value, ".")
+		if expected, _ := oauth.NewState(tokens.NewDeterministic("oauth")); state != expected {
+			t.Errorf("expected the state drawn from the handler's generator, got %q", state)
+		}
+
+		w := callback(router, "code=good-code&state="+url.QueryEscape(state), cookie)
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "https://app.example.com/login/done" {
+			t.Fatalf("expected a redirect to the frontend, got %d %q", w.Code, w.Header().Get("Location"))
+		}
+		if provider.verifier != verifier {
+			t.Errorf("expected the verifier of the cookie to be sent to the provider")
+		}
+		var session, cleared bool
+		for _, c := range w.Result().Cookies() {
+			session = session || (c.Name == middleware.SessionCookieName && c.Value == "session-1")
+			cleared = cleared || (c.Name == OAuthStateCookieName && c.MaxAge < 0)
+		}
+		if !session || !cleared {
+			t.Errorf("expected the session cookie and the state cookie cleared, got %v", w.Result().Cookies())
+		}
+	})
+
+	t.Run("redirects with a two-factor challenge", func(t *testing.T) {
+		authenticator.challenge = &service.TwoFactorChallenge{Token: "challenge-1", ExpiresAt: time.Now().Add(5 * time.Minute)}
+		defer func() { authenticator.challenge = nil }()
+		router := newRouter("https://app.example.com/login/done")
+		state, cookie := startOAuthLogin(t, router)
+
+		w := callback(router, "code=good-code&state="+url.QueryEscape(state), cookie)
+		if w.Header().Get("Location") != "https://app.example.com/login/done?two_factor_token=challenge-1" {
+			t.Fatalf("expected the challenge token in the redirect, got %d %q", w.Code, w.Header().Get("Location"))
+		}
+		for _, c := range w.Result().Cookies() {
+			if c.Name == middleware.SessionCookieName {
+				t.Errorf("expected no session cookie before the second factor, got %+v", c)
+			}
+		}
+	})
+
+	t.Run("rejects a forged state", func(t *testing.T) {
+		router := newRouter("https://app.example.com/login/done?from=oauth")
+		_, cookie := startOAuthLogin(t, router)
+
+		for _, query := range []string{"code=good-code&state=forged", "code=good-code"} {
+			w := callback(router, query, cookie)
+			if w.Header().Get("Location") != "https://app.example.com/login/done?from=oauth&error="+OAuthErrorInvalidState {
+				t.Errorf("%s: expected invalid_state, got %d %q", query, w.Code, w.Header().Get("Location"))
+			}
+		}
+		state, _ := startOAuthLogin(t, router)
+		w := callback(router, "code=good-code&state="+url.QueryEscape(state), nil)
+		if !strings.HasSuffix(w.Header().Get("Location"), "error="+OAuthErrorInvalidState) {
+			t.Errorf("expected invalid_state without the cookie, got %q", w.Header().Get("Location"))
+		}
+	})
+
+	t.Run("JSON without redirect URL", func(t *testing.T) {
+		router := newRouter("")
+		tests := []struct {
+			name       string
+			query      string
+			err        error
+			wantStatus int
+			wantCode   string
+		}{
+			{"success", "code=good-code", nil, http.StatusOK, ""},
+			{"denied at the provider", "error=access_denied", nil, http.StatusUnauthorized, OAuthErrorAccessDenied},
+			{"bad code", "code=bad-code", nil, http.StatusBadGateway, OAuthErrorProvider},
+			{"unverified email", "code=good-code", service.ErrOAuthEmailNotVerified, http.StatusUnauthorized, OAuthErrorEmailNotVerified},
+			{"account conflict", "code=good-code", service.ErrOAuthAccountConflict, http.StatusConflict, OAuthErrorAccountConflict},
+			{"registration closed", "code=good-code", service.ErrRegistrationClosed, http.StatusForbidden, OAuthErrorRegistration},
+			{"inactive user", "code=good-code", service.ErrUserNotActive, http.StatusUnauthorized, OAuthErrorLoginRefused},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				authenticator.err = tt.err
+				defer func() { authenticator.err = nil }()
+				state, cookie := startOAuthLogin(t, router)
+
+				w := callback(router, tt.query+"&state="+url.QueryEscape(state), cookie)
+				if w.Code != tt.wantStatus {
+					t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+				}
+				if tt.wantCode != "" && !strings.Contains(w.Body.String(), `"code":"`+tt.wantCode+`"`) {
+					t.Errorf("expected code %s, got %s", tt.wantCode, w.Body.String())
+				}
+				if tt.wantCode == "" && !strings.Contains(w.Body.String(), "session-1") {
+					t.Errorf("expected the login response, got %s", w.Body.String())
+				}
+			})
+		}
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		router := newRouter("")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oauth/myspace/login", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PhoneNumberRequest sets the user's phone number, in E.164 format
+type PhoneNumberRequest struct {
+	PhoneNumber string `json:"phone_number" binding:"required"`
+}
+
+// SMSCodeRequest carries a code sent by SMS
+type SMSCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// ResendTwoFactorCodeRequest asks for a new SMS code during a login challenge
+type ResendTwoFactorCodeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+// SetPhoneNumber stores the current user's phone number, unverified until
+// VerifyPhoneNumber confirms the code sent to it
+func (h *AuthHandler) SetPhoneNumber(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	var req PhoneNumberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	if err := h.authService.SetPhoneNumber(requestContext(c), userID.(string), req.PhoneNumber); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		respondSMSError(c, err, "falha ao cadastrar telefone")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "código de verificação enviado por SMS"})
+}
+
+// VerifyPhoneNumber confirms the current user's phone number with the code
+// sent by SetPhoneNumber
+func (h *AuthHandler) VerifyPhoneNumber(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	var req SMSCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	if err := h.authService.VerifyPhoneNumber(requestContext(c), userID.(string), req.Code); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		respondSMSError(c, err, "falha ao verificar telefone")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "telefone verificado"})
+}
+
+// EnableSMSTwoFactor makes SMS codes, sent to the verified phone, the current
+// user's second factor
+func (h *AuthHandler) EnableSMSTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	if err := h.authService.SetTwoFactorMethod(requestContext(c), userID.(string), service.TwoFactorSMS); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		respondSMSError(c, err, "falha ao ativar 2FA por SMS")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "2FA por SMS ativado"})
+}
+
+// DisableSMSTwoFactor turns SMS 2FA off for the current user, confirmed with
+// their password
+func (h *AuthHandler) DisableSMSTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	var req DisableTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	if err := h.authService.DisableSMSTwoFactor(requestContext(c), userID.(string), req.Password); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		respondSMSError(c, err, "falha ao desativar 2FA por SMS")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "2FA por SMS desativado"})
+}
+
+// ResendTwoFactorCode sends a new SMS code for a login answered with 202 by
+// Login. Public: the user has no session yet.
+func (h *AuthHandler) ResendTwoFactorCode(c *gin.Context) {
+	var req ResendTwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	if err := h.authService.ResendTwoFactorCode(requestContext(c), req.ChallengeToken); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidTwoFactorChallenge) {
+			apierror.Respond(c, apierror.Unauthorized(err.Error()))
+			return
+		}
+		respondSMSError(c, err, "falha ao reenviar código")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "código enviado por SMS"})
+}
+
+// respondSMSError answers the errors of phone verification and SMS 2FA
+func respondSMSError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidPhoneNumber):
+		apierror.Respond(c, apierror.Validation(err.Error(), apierror.FieldError{Field: "phone_number", Rule: "e164", Message: err.Error()}))
+	case errors.Is(err, service.ErrInvalidSMSCode),
+		errors.Is(err, service.ErrPhoneNotVerified),
+		errors.Is(err, service.ErrTwoFactorSMSNotEnabled),
+		errors.Is(err, service.ErrWrongPassword):
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
+	case errors.Is(err, service.ErrPhoneLockedBy2FA),
+		errors.Is(err, service.ErrTOTPEnabled):
+		apierror.Respond(c, apierror.Conflict(err.Error()))
+	case errors.Is(err, service.ErrSMSRateLimited):
+		apierror.Respond(c, apierror.RateLimited(err.Error()))
+	case errors.Is(err, service.ErrSMSUnavailable):
+		apierror.Respond(c, apierror.New(http.StatusServiceUnavailable, err.Error()))
+	default:
+		internalError(c, err, message)
+	}
+}
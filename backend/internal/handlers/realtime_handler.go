@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/realtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RealtimeHandler opens the WebSocket of real-time notifications
+type RealtimeHandler struct {
+	hub *realtime.Hub
+}
+
+// NewRealtimeHandler creates a RealtimeHandler
+func NewRealtimeHandler(hub *realtime.Hub) *RealtimeHandler {
+	return &RealtimeHandler{hub: hub}
+}
+
+// Connect answers GET /ws for the authenticated user. The connection only
+// carries events from the server (realtime.Event) and lasts at most as long
+// as the session that opened it.
+func (h *RealtimeHandler) Connect(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+	var expiresAt time.Time
+	if session, ok := c.Get("session"); ok {
+		expiresAt = session.(*auth.Session).ExpiresAt
+	}
+
+	err := h.hub.Serve(c.Writer, c.Request, userID.(string), c.GetString("sessionID"), expiresAt)
+	switch {
+	case err == nil:
+	case errors.Is(err, realtime.ErrNotWebSocket):
+		c.Header("Upgrade", "websocket")
+		apierror.Respond(c, apierror.New(http.StatusUpgradeRequired, err.Error()))
+	case errors.Is(err, realtime.ErrBadHandshake):
+		c.Header("Sec-WebSocket-Version", "13")
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
+	case errors.Is(err, realtime.ErrOriginNotAllowed):
+		logger.FromContext(requestContext(c)).Warn("Conexão WebSocket de origem não permitida", "origin", c.GetHeader("Origin"), "user_id", userID, "ip", getClientIP(c))
+		apierror.Respond(c, apierror.Forbidden(err.Error()))
+	case errors.Is(err, realtime.ErrTooManyConnections):
+		apierror.Respond(c, apierror.RateLimited(err.Error()))
+	case errors.Is(err, realtime.ErrClosed):
+		c.Header("Retry-After", "5")
+		apierror.Respond(c, apierror.New(http.StatusServiceUnavailable, err.Error()))
+	default:
+		internalError(c, err, "falha ao abrir conexão WebSocket", "user_id", userID)
+	}
+}
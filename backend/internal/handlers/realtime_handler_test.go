@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/realtime"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRealtimeHandler_Connect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hub := realtime.NewHub(realtime.Config{MaxConnectionsPerUser: 1})
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "1")
+		c.Set("sessionID", "session")
+		c.Set("session", &auth.Session{ID: "session", ExpiresAt: time.Now().Add(time.Hour)})
+	})
+	router.GET("/ws", NewRealtimeHandler(hub).Connect)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	defer hub.Close()
+
+	handshake := func(header map[string]string) *http.Response {
+		t.Helper()
+		conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		if err := req.Write(conn); err != nil {
+			t.Fatalf("failed to send handshake: %v", err)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		return resp
+	}
+	websocket := map[string]string{
+		"Connection":            "Upgrade",
+		"Upgrade":               "websocket",
+		"Sec-WebSocket-Version": "13",
+		"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+	}
+
+	if resp := handshake(nil); resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("expected status %d for a plain GET, got %d", http.StatusUpgradeRequired, resp.StatusCode)
+	}
+
+	crossSite := map[string]string{"Origin": "https://evil.example.com"}
+	for name, value := range websocket {
+		crossSite[name] = value
+	}
+	if resp := handshake(crossSite); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status %d for another origin, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	resp := handshake(websocket)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected Sec-WebSocket-Accept %q", accept)
+	}
+
+	// The only connection allowed is open
+	if resp := handshake(websocket); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleHandler handles admin role HTTP requests
+type RoleHandler struct {
+	policy *auth.Policy
+}
+
+// NewRoleHandler creates a new RoleHandler instance listing the roles of
+// policy
+func NewRoleHandler(policy *auth.Policy) *RoleHandler {
+	return &RoleHandler{policy: policy}
+}
+
+// List returns the roles users can be given (PATCH /admin/users/:id/role)
+// with their permissions, sorted by name
+func (h *RoleHandler) List(c *gin.Context) {
+	roles := h.policy.Roles()
+	items := make([]dto.RoleResponse, len(roles))
+	for i, role := range roles {
+		items[i] = dto.NewRoleResponse(role)
+	}
+	c.JSON(http.StatusOK, dto.RoleListResponse{Roles: items})
+}
@@ -0,0 +1,34 @@
+// Package handlers tests
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gosveltekit/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRoleHandler_List(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewRoleHandler(auth.NewPolicy([]auth.Role{
+		{Name: "user", Description: "Usuário comum", Permissions: []string{"profile:read"}},
+		{Name: "guest"},
+	}))
+	router := gin.New()
+	router.GET("/admin/roles", handler.List)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/roles", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	want := `{"roles":[{"name":"guest","permissions":[]},{"name":"user","description":"Usuário comum","permissions":["profile:read"]}]}`
+	if w.Body.String() != want {
+		t.Errorf("expected body %s, got %s", want, w.Body.String())
+	}
+}
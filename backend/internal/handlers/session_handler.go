@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionLister lists the sessions of all users, see auth.AuthManager
+type SessionLister interface {
+	ListAllSessions(ctx context.Context, filter auth.SessionFilter, offset, limit int) ([]*auth.Session, int64, error)
+}
+
+// SessionHandler handles admin session HTTP requests
+type SessionHandler struct {
+	lister SessionLister
+}
+
+// NewSessionHandler creates a new SessionHandler instance
+func NewSessionHandler(lister SessionLister) *SessionHandler {
+	return &SessionHandler{lister: lister}
+}
+
+// List returns a page of the active sessions of all users. Accepts page and
+// page_size plus the filters user_id, ip, created_after and created_before
+// (RFC 3339). Session IDs are never returned. Every access is logged with the
+// acting admin.
+func (h *SessionHandler) List(c *gin.Context) {
+	params, err := pagination.Parse(c)
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	filter := auth.SessionFilter{UserID: c.Query("user_id"), IP: c.Query("ip")}
+	for _, bound := range []struct {
+		param string
+		dest  *time.Time
+	}{
+		{"created_after", &filter.CreatedAfter},
+		{"created_before", &filter.CreatedBefore},
+	} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			apierror.Respond(c, apierror.BadRequest("parâmetro "+bound.param+" deve ser uma data RFC 3339"))
+			return
+		}
+		*bound.dest = parsed
+	}
+
+	logger.FromContext(requestContext(c)).Info("Sessões listadas por administrador", "admin_id", c.GetString("userID"), "filter_user_id", filter.UserID, "filter_ip", filter.IP,
+		"created_after", filter.CreatedAfter, "created_before", filter.CreatedBefore, "ip", getClientIP(c))
+
+	sessions, total, err := h.lister.ListAllSessions(requestContext(c), filter, params.Offset(), params.Limit())
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		if errors.Is(err, auth.ErrUserNotFound) {
+			// No such user, so no sessions
+			c.JSON(http.StatusOK, dto.NewListResponse([]dto.AdminSessionResponse{}, pagination.NewMeta(params, 0)))
+			return
+		}
+		if errors.Is(err, auth.ErrSessionListUnsupported) {
+			// e.g. sessions stored in Redis
+			apierror.Respond(c, apierror.New(http.StatusNotImplemented, "listagem de sessões não suportada pelo armazenamento de sessões"))
+			return
+		}
+		internalError(c, err, "falha ao listar sessões")
+		return
+	}
+
+	currentSessionID := c.GetString("sessionID")
+	items := make([]dto.AdminSessionResponse, len(sessions))
+	for i, session := range sessions {
+		items[i] = dto.NewAdminSessionResponse(session, session.ID == currentSessionID)
+	}
+	c.JSON(http.StatusOK, dto.NewListResponse(items, pagination.NewMeta(params, total)))
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mockSessionLister struct {
+	sessions []*auth.Session
+	err      error
+
+	filter        auth.SessionFilter
+	offset, limit int
+}
+
+func (m *mockSessionLister) ListAllSessions(ctx context.Context, filter auth.SessionFilter, offset, limit int) ([]*auth.Session, int64, error) {
+	m.filter, m.offset, m.limit = filter, offset, limit
+	return m.sessions, int64(len(m.sessions)), m.err
+}
+
+func TestSessionHandler_List(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	lister := &mockSessionLister{sessions: []*auth.Session{
+		{ID: "secret-session-id", UserID: "7", IP: "10.0.0.1", UserAgent: "curl", CreatedAt: time.Now()},
+	}}
+	router := gin.New()
+	router.GET("/admin/sessions", NewSessionHandler(lister).List)
+
+	t.Run("filters and pagination", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/sessions?user_id=7&ip=10.0.0.1&created_after=2024-03-01T00:00:00Z&created_before=2024-04-01T00:00:00-03:00&page=2&page_size=5", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		want := auth.SessionFilter{
+			UserID:        "7",
+			IP:            "10.0.0.1",
+			CreatedAfter:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			CreatedBefore: time.Date(2024, 4, 1, 3, 0, 0, 0, time.UTC),
+		}
+		if lister.filter.UserID != want.UserID || lister.filter.IP != want.IP ||
+			!lister.filter.CreatedAfter.Equal(want.CreatedAfter) || !lister.filter.CreatedBefore.Equal(want.CreatedBefore) {
+			t.Errorf("expected filter %+v, got %+v", want, lister.filter)
+		}
+		if lister.offset != 5 || lister.limit != 5 {
+			t.Errorf("expected offset 5 and limit 5, got %d and %d", lister.offset, lister.limit)
+		}
+
+		var resp dto.ListResponse[dto.AdminSessionResponse]
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(resp.Data) != 1 || resp.Data[0].UserID != "7" || resp.Data[0].IP != "10.0.0.1" {
+			t.Errorf("unexpected response: %s", w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "secret-session-id") {
+			t.Errorf("session ID leaked: %s", w.Body.String())
+		}
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/sessions?created_after=yesterday", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		lister.err = auth.ErrUserNotFound
+		defer func() { lister.err = nil }()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/sessions?user_id=999", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("listing unsupported", func(t *testing.T) {
+		lister.err = auth.ErrSessionListUnsupported
+		defer func() { lister.err = nil }()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/sessions", nil))
+		if w.Code != http.StatusNotImplemented {
+			t.Errorf("expected status %d, got %d", http.StatusNotImplemented, w.Code)
+		}
+	})
+}
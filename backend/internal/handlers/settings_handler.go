@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/audit"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/settings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SettingsStore reads and overrides runtime settings, see settings.Store
+type SettingsStore interface {
+	All() []settings.Setting
+	Set(ctx context.Context, key string, value bool, updatedBy string) (settings.Setting, error)
+}
+
+// SettingsHandler handles admin runtime settings HTTP requests
+type SettingsHandler struct {
+	store SettingsStore
+	auditor
+}
+
+// UpdateSettingRequest is the body of UpdateSetting
+type UpdateSettingRequest struct {
+	Value *bool `json:"value" binding:"required"`
+}
+
+// SettingsResponse lists the effective value of every runtime setting
+type SettingsResponse struct {
+	Settings []settings.Setting `json:"settings"`
+}
+
+// NewSettingsHandler creates a new SettingsHandler instance
+func NewSettingsHandler(store SettingsStore, opts ...AdminOption) *SettingsHandler {
+	h := &SettingsHandler{store: store}
+	for _, opt := range opts {
+		opt(&h.auditor)
+	}
+	return h
+}
+
+// List returns every runtime setting with its default and override
+func (h *SettingsHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, SettingsResponse{Settings: h.store.All()})
+}
+
+// UpdateSetting overrides the setting in the :key path parameter. Other
+// instances apply it on their next refresh.
+func (h *SettingsHandler) UpdateSetting(c *gin.Context) {
+	var req UpdateSettingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	adminID := c.GetString("userID")
+	setting, err := h.store.Set(requestContext(c), c.Param("key"), *req.Value, adminID)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		if errors.Is(err, settings.ErrUnknownKey) {
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+			return
+		}
+		internalError(c, err, "falha ao alterar configuração", "key", c.Param("key"))
+		return
+	}
+
+	logger.FromContext(requestContext(c)).Info("Configuração alterada", "admin_id", adminID, "key", setting.Key, "value", setting.Value)
+	h.record(c, audit.Event{Type: audit.EventSettingChanged, ActorID: adminID, Data: map[string]any{"key": setting.Key, "value": setting.Value}})
+	c.JSON(http.StatusOK, setting)
+}
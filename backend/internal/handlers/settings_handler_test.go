@@ -0,0 +1,90 @@
+// Package handlers tests
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gosveltekit/internal/settings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mockSettingsStore struct {
+	values map[string]bool
+}
+
+func (m *mockSettingsStore) All() []settings.Setting {
+	var all []settings.Setting
+	for key, value := range m.values {
+		all = append(all, settings.Setting{Key: key, Value: value})
+	}
+	return all
+}
+
+func (m *mockSettingsStore) Set(ctx context.Context, key string, value bool, updatedBy string) (settings.Setting, error) {
+	if _, ok := m.values[key]; !ok {
+		return settings.Setting{}, fmt.Errorf("%w: %s", settings.ErrUnknownKey, key)
+	}
+	m.values[key] = value
+	return settings.Setting{Key: key, Value: value, Overridden: true, UpdatedBy: updatedBy}, nil
+}
+
+func TestSettingsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := &mockSettingsStore{values: map[string]bool{settings.KeyMaintenanceMode: false}}
+	handler := NewSettingsHandler(store)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "1")
+		c.Next()
+	})
+	router.GET("/admin/settings", handler.List)
+	router.PUT("/admin/settings/:key", handler.UpdateSetting)
+
+	put := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/admin/settings/"+key, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := put(settings.KeyMaintenanceMode, `{"value": true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var setting settings.Setting
+	if err := json.Unmarshal(w.Body.Bytes(), &setting); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !setting.Value || setting.UpdatedBy != "1" || !store.values[settings.KeyMaintenanceMode] {
+		t.Errorf("setting not updated: %+v", setting)
+	}
+
+	if w := put(settings.KeyMaintenanceMode, `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing value: expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := put(settings.KeyMaintenanceMode, `{"value": "yes"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("non-boolean value: expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := put("jwt_secret", `{"value": true}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown key: expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/settings", nil))
+	var resp SettingsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Settings) != 1 || !resp.Settings[0].Value {
+		t.Errorf("unexpected settings: %+v", resp.Settings)
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamFlushEvery is how many array elements are written between flushes
+const streamFlushEvery = 100
+
+// streamJSONArray answers 200 with a JSON array whose elements are written as
+// produce emits them, without a Content-Length, so large results never sit in
+// memory. The status is only sent with the first element: if produce fails
+// before that, started is false and the caller answers the error as usual.
+// A later failure can only cut the array short, leaving invalid JSON for the
+// client to notice.
+func streamJSONArray(c *gin.Context, produce func(emit func(any) error) error) (started bool, err error) {
+	count := 0
+	emit := func(item any) error {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if !started {
+			started = true
+			c.Header("Content-Type", "application/json; charset=utf-8")
+			c.Status(http.StatusOK)
+			if _, err := c.Writer.WriteString("["); err != nil {
+				return err
+			}
+		} else if _, err := c.Writer.WriteString(","); err != nil {
+			return err
+		}
+		if _, err := c.Writer.Write(data); err != nil {
+			return err
+		}
+		count++
+		if count%streamFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	}
+
+	if err := produce(emit); err != nil {
+		if started {
+			c.Abort()
+		}
+		return started, err
+	}
+
+	if !started {
+		started = true
+		c.Data(http.StatusOK, "application/json; charset=utf-8", []byte("[]"))
+		return started, nil
+	}
+	_, err = c.Writer.WriteString("]")
+	c.Writer.Flush()
+	return started, err
+}
+
+// streamCSV answers 200 with a CSV document, the header row followed by the
+// records produce emits, written as they come like streamJSONArray. Nothing
+// is sent until the first record or the end of produce, so a failure before
+// then leaves started false for the caller to answer. Cells starting like a
+// spreadsheet formula are prefixed with a quote, so opening an export never
+// runs what a client put in, e.g., its user agent.
+func streamCSV(c *gin.Context, header []string, produce func(emit func([]string) error) error) (started bool, err error) {
+	w := csv.NewWriter(c.Writer)
+	start := func() error {
+		started = true
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		return w.Write(header)
+	}
+	count := 0
+	emit := func(record []string) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		for i, cell := range record {
+			if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+				record[i] = "'" + cell
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+		count++
+		if count%streamFlushEvery == 0 {
+			w.Flush()
+			c.Writer.Flush()
+			return w.Error()
+		}
+		return nil
+	}
+
+	if err := produce(emit); err != nil {
+		if started {
+			w.Flush()
+			c.Abort()
+		}
+		return started, err
+	}
+
+	if !started {
+		if err := start(); err != nil {
+			return started, err
+		}
+	}
+	w.Flush()
+	c.Writer.Flush()
+	return started, w.Error()
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorLoginRequest completes a login challenged for the second factor
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// TOTPCodeRequest carries a code of the user's authenticator app
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTOTPRequest confirms turning TOTP off with the user's password
+type DisableTOTPRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// CompleteTwoFactorLogin creates the session of a login answered with 202 by
+// Login, given the challenge token and a TOTP or recovery code. Public: the
+// user has no session yet.
+func (h *AuthHandler) CompleteTwoFactorLogin(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	ip := getClientIP(c)
+	response, err := h.authService.CompleteTwoFactorLogin(requestContext(c), req.ChallengeToken, req.Code, ip)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidTwoFactorCode),
+			errors.Is(err, service.ErrInvalidTwoFactorChallenge):
+			apierror.Respond(c, apierror.Unauthorized(err.Error()))
+		case errors.Is(err, service.ErrTwoFactorLocked):
+			apierror.Respond(c, apierror.RateLimited(err.Error()))
+		case errors.Is(err, service.ErrUserNotActive):
+			apierror.Respond(c, apierror.Unauthorized("usuário inativo"))
+		case errors.Is(err, service.ErrAccountPendingDeletion):
+			apierror.Respond(c, apierror.Forbidden(err.Error()))
+		case errors.Is(err, service.ErrTooManySessions):
+			apierror.Respond(c, apierror.Conflict(err.Error()))
+		default:
+			internalError(c, err, "falha ao concluir login", "ip", ip)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, sessionResponse(c, response))
+}
+
+// EnrollTOTP starts setting up an authenticator app for the current user.
+// TOTP stays off until VerifyTOTP confirms a first code.
+func (h *AuthHandler) EnrollTOTP(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	enrollment, err := h.authService.EnrollTOTP(requestContext(c), userID.(string))
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrTOTPAlreadyEnabled) {
+			apierror.Respond(c, apierror.Conflict(err.Error()))
+			return
+		}
+		internalError(c, err, "falha ao configurar autenticação em dois fatores")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.TOTPEnrollmentResponse{
+		Secret:          enrollment.Secret,
+		ProvisioningURI: enrollment.URI,
+	})
+}
+
+// VerifyTOTP enables TOTP with a first code of the app and returns the
+// user's new recovery codes, shown only once
+func (h *AuthHandler) VerifyTOTP(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	codes, err := h.authService.ConfirmTOTP(requestContext(c), userID.(string), req.Code)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidTwoFactorCode),
+			errors.Is(err, service.ErrTOTPNotEnabled):
+			apierror.Respond(c, apierror.BadRequest(err.Error()))
+		case errors.Is(err, service.ErrTOTPAlreadyEnabled):
+			apierror.Respond(c, apierror.Conflict(err.Error()))
+		default:
+			internalError(c, err, "falha ao ativar autenticação em dois fatores")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP turns TOTP off for the current user, confirmed with their
+// password
+func (h *AuthHandler) DisableTOTP(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.Unauthorized("não autenticado"))
+		return
+	}
+
+	var req DisableTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	if err := h.authService.DisableTOTP(requestContext(c), userID.(string), req.Password); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrWrongPassword),
+			errors.Is(err, service.ErrTOTPNotEnabled):
+			apierror.Respond(c, apierror.BadRequest(err.Error()))
+		default:
+			internalError(c, err, "falha ao desativar autenticação em dois fatores")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "autenticação em dois fatores desativada"})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/audit"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Actions of BatchUserActionRequest
+const (
+	BatchUserDisable        = "disable"
+	BatchUserEnable         = "enable"
+	BatchUserExpirePassword = "expire_password"
+)
+
+// BatchUserActionRequest applies one action to up to 100 users
+type BatchUserActionRequest struct {
+	Action  string   `json:"action" binding:"required,oneof=disable enable expire_password"`
+	UserIDs []string `json:"user_ids" binding:"required,min=1,max=100,dive,required"`
+}
+
+// Batch applies the action of the request to each user, as Disable, Enable
+// or ExpirePassword would, and answers with a dto.BatchResult in the order
+// of user_ids. A user that fails doesn't stop the others. Admin only.
+func (h *UserHandler) Batch(c *gin.Context) {
+	var req BatchUserActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	ctx := requestContext(c)
+	actorID := c.GetString("userID")
+	batch := dto.NewBatchResult()
+	for i, userID := range req.UserIDs {
+		event, err := h.batchAction(ctx, actorID, req.Action, userID)
+		if err != nil {
+			if abortIfCanceled(c, err) {
+				return
+			}
+			batch.Fail(i, userID, batchUserError(ctx, err, req.Action, userID))
+			continue
+		}
+		h.record(c, event)
+		batch.Succeed(i, userID)
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
+
+// batchAction applies action to userID and returns the audit event to record
+func (h *UserHandler) batchAction(ctx context.Context, actorID, action, userID string) (audit.Event, error) {
+	if action == BatchUserExpirePassword {
+		if err := h.userService.ExpirePassword(ctx, actorID, userID, false); err != nil {
+			return audit.Event{}, err
+		}
+		return audit.Event{Type: audit.EventPasswordExpired, TargetID: userID, Data: map[string]any{"revoke_sessions": false}}, nil
+	}
+
+	active := action == BatchUserEnable
+	user, err := h.userService.SetActive(ctx, actorID, userID, active)
+	if err != nil {
+		return audit.Event{}, err
+	}
+	event := audit.EventUserDisabled
+	if active {
+		event = audit.EventUserEnabled
+	}
+	return audit.Event{Type: event, TargetID: auditID(user)}, nil
+}
+
+// batchUserError returns the error reported for a failed item: the service
+// errors meant for the client as they are, anything else logged and hidden
+func batchUserError(ctx context.Context, err error, action, userID string) error {
+	if errors.Is(err, service.ErrUserNotFound) || errors.Is(err, service.ErrDisableLastAdmin) {
+		return err
+	}
+	logger.FromContext(ctx).Error("Erro ao aplicar ação em lote", "error", err, "action", action, "user_id", userID)
+	return errors.New("erro interno do servidor")
+}
@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/audit"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/pagination"
+	"gosveltekit/internal/repository"
+	"gosveltekit/internal/service"
+	"gosveltekit/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserHandler handles user management HTTP requests
+type UserHandler struct {
+	userService service.UserServiceInterface
+	auditor
+}
+
+// CreateUserRequest represents the admin user creation request body
+type CreateUserRequest struct {
+	Username    string `json:"username" binding:"required"`
+	Email       string `json:"email" binding:"required"`
+	DisplayName string `json:"display_name" binding:"required"`
+	Password    string `json:"password" binding:"required"`
+	// Role defaults to "user"
+	Role string `json:"role"`
+
+	// MustChangePassword makes the user replace the password at first login
+	MustChangePassword bool `json:"must_change_password"`
+	// EmailVerified marks the email as verified
+	EmailVerified bool `json:"email_verified"`
+}
+
+// UpdateUserRequest represents the admin profile change request body
+type UpdateUserRequest struct {
+	DisplayName string `json:"display_name" binding:"required"`
+}
+
+// UpdateRoleRequest represents the role change request body
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+
+	// RevokeSessions logs the user out of all sessions after the change
+	RevokeSessions bool `json:"revoke_sessions"`
+}
+
+// UpdateUsernameRequest represents the username change request body
+type UpdateUsernameRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+// ExpirePasswordRequest represents the optional password expiry request body
+type ExpirePasswordRequest struct {
+	// RevokeSessions logs the user out of all sessions, instead of limiting
+	// them to changing the password
+	RevokeSessions bool `json:"revoke_sessions"`
+}
+
+// NewUserHandler creates a new UserHandler instance
+func NewUserHandler(userService service.UserServiceInterface, opts ...AdminOption) *UserHandler {
+	h := &UserHandler{userService: userService}
+	for _, opt := range opts {
+		opt(&h.auditor)
+	}
+	return h
+}
+
+// List returns a page of users. Accepts page, page_size and sort (one of
+// repository.UserSortFields, "-" prefix for descending), plus the filters q
+// (substring of the username, email or display name), role and active.
+func (h *UserHandler) List(c *gin.Context) {
+	params, err := pagination.Parse(c)
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	active, err := pagination.ParseBool(c, "active")
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	filter := repository.UserFilter{Search: c.Query("q"), Role: c.Query("role"), Active: active}
+
+	sort, err := pagination.ParseSort(c, repository.UserSortFields, repository.DefaultUserSort)
+	if err != nil {
+		logger.FromContext(requestContext(c)).Debug("Listagem de usuários com ordenação inválida", "sort", c.Query("sort"), "ip", getClientIP(c))
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	users, total, err := h.userService.ListUsers(requestContext(c), filter, params, sort)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		internalError(c, err, "falha ao listar usuários")
+		return
+	}
+
+	items := make([]dto.UserResponse, len(users))
+	for i := range users {
+		items[i] = dto.NewUserResponse(&users[i])
+	}
+	c.JSON(http.StatusOK, dto.NewListResponse(items, pagination.NewMeta(params, total)))
+}
+
+// Get returns the user in the :id path parameter. Admin only.
+func (h *UserHandler) Get(c *gin.Context) {
+	user, err := h.userService.GetUser(requestContext(c), c.Param("id"))
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+			return
+		}
+		internalError(c, err, "falha ao buscar usuário")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewUserResponse(user))
+}
+
+// Create creates an active user. Admin only: the registration rules apply,
+// but reserved usernames and any allowed role are accepted, and no welcome or
+// verification email is sent.
+func (h *UserHandler) Create(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+	if err := validation.ValidateEmail(req.Email); err != nil {
+		apierror.Respond(c, apierror.Validation(err.Error()))
+		return
+	}
+	if err := validation.ValidateDisplayName(req.DisplayName); err != nil {
+		apierror.Respond(c, apierror.Validation(err.Error()))
+		return
+	}
+	if err := validation.ValidatePassword(req.Password, req.Username); err != nil {
+		apierror.Respond(c, apierror.Validation(err.Error()))
+		return
+	}
+
+	actorID := c.GetString("userID")
+	user, err := h.userService.CreateUser(requestContext(c), actorID, service.CreateUserInput{
+		Username:           req.Username,
+		Email:              req.Email,
+		DisplayName:        req.DisplayName,
+		Password:           req.Password,
+		Role:               req.Role,
+		MustChangePassword: req.MustChangePassword,
+		EmailVerified:      req.EmailVerified,
+	})
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidRole), errors.Is(err, service.ErrInvalidUsername):
+			apierror.Respond(c, apierror.BadRequest(err.Error()))
+		case errors.Is(err, service.ErrUsernameTaken), errors.Is(err, service.ErrEmailTaken):
+			apierror.Respond(c, apierror.Conflict(err.Error()))
+		default:
+			internalError(c, err, "falha ao criar usuário")
+		}
+		return
+	}
+	h.record(c, audit.Event{Type: audit.EventUserCreated, TargetID: auditID(user), Data: map[string]any{"username": user.Username, "role": user.Role}})
+
+	c.JSON(http.StatusCreated, dto.NewUserResponse(user))
+}
+
+// Update changes the display name of the user in the :id path parameter.
+// Admin only.
+func (h *UserHandler) Update(c *gin.Context) {
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+	if err := validation.ValidateDisplayName(req.DisplayName); err != nil {
+		apierror.Respond(c, apierror.Validation(err.Error()))
+		return
+	}
+
+	actorID := c.GetString("userID")
+	user, err := h.userService.UpdateDisplayName(requestContext(c), actorID, c.Param("id"), req.DisplayName)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+			return
+		}
+		internalError(c, err, "falha ao alterar usuário")
+		return
+	}
+	h.record(c, audit.Event{Type: audit.EventUserUpdated, TargetID: auditID(user), Data: map[string]any{"display_name": user.DisplayName}})
+
+	c.JSON(http.StatusOK, dto.NewUserResponse(user))
+}
+
+// Disable deactivates the account of the user in the :id path parameter, so
+// they can no longer log in. Admin only; the last active admin can't be
+// disabled.
+func (h *UserHandler) Disable(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+// Enable reactivates the account of the user in the :id path parameter.
+// Admin only.
+func (h *UserHandler) Enable(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+func (h *UserHandler) setActive(c *gin.Context, active bool) {
+	actorID := c.GetString("userID")
+	user, err := h.userService.SetActive(requestContext(c), actorID, c.Param("id"), active)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+		case errors.Is(err, service.ErrDisableLastAdmin):
+			apierror.Respond(c, apierror.Conflict(err.Error()))
+		default:
+			internalError(c, err, "falha ao alterar status da conta")
+		}
+		return
+	}
+	event := audit.EventUserDisabled
+	if active {
+		event = audit.EventUserEnabled
+	}
+	h.record(c, audit.Event{Type: event, TargetID: auditID(user)})
+
+	c.JSON(http.StatusOK, dto.NewUserResponse(user))
+}
+
+// Export streams every user as a JSON array download, ordered by sort like
+// List. Admin only.
+func (h *UserHandler) Export(c *gin.Context) {
+	sort, err := pagination.ParseSort(c, repository.UserSortFields, repository.DefaultUserSort)
+	if err != nil {
+		apierror.Respond(c, apierror.BadRequest(err.Error()))
+		return
+	}
+
+	filename := fmt.Sprintf("users-export-%s.json", time.Now().UTC().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	started, err := streamJSONArray(c, func(emit func(any) error) error {
+		return h.userService.ExportUsers(requestContext(c), sort, func(user *models.User) error {
+			return emit(dto.NewUserResponse(user))
+		})
+	})
+	if err == nil {
+		return
+	}
+	if started {
+		logger.FromContext(c.Request.Context()).Error("Exportação de usuários interrompida", "error", err, "ip", getClientIP(c))
+		return
+	}
+	c.Header("Content-Disposition", "")
+	if abortIfCanceled(c, err) {
+		return
+	}
+	internalError(c, err, "falha ao exportar usuários")
+}
+
+// UpdateRole changes the role of the user in the :id path parameter. Admin
+// only; every change is logged with the acting admin.
+func (h *UserHandler) UpdateRole(c *gin.Context) {
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	actorID := c.GetString("userID")
+	user, err := h.userService.UpdateRole(requestContext(c), actorID, c.Param("id"), req.Role, req.RevokeSessions)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidRole):
+			apierror.Respond(c, apierror.BadRequest(err.Error()))
+		case errors.Is(err, service.ErrUserNotFound):
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+		case errors.Is(err, service.ErrLastAdmin):
+			apierror.Respond(c, apierror.Conflict(err.Error()))
+		default:
+			internalError(c, err, "falha ao alterar papel do usuário")
+		}
+		return
+	}
+	h.record(c, audit.Event{Type: audit.EventUserRoleChanged, TargetID: auditID(user), Data: map[string]any{"role": user.Role, "revoke_sessions": req.RevokeSessions}})
+
+	c.JSON(http.StatusOK, dto.NewUserResponse(user))
+}
+
+// UpdateUsername renames the user in the :id path parameter. Admin only:
+// unlike registration, reserved names are accepted.
+func (h *UserHandler) UpdateUsername(c *gin.Context) {
+	var req UpdateUsernameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return
+	}
+
+	actorID := c.GetString("userID")
+	user, err := h.userService.UpdateUsername(requestContext(c), actorID, c.Param("id"), req.Username)
+	if err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrInvalidUsername):
+			apierror.Respond(c, apierror.BadRequest(err.Error()))
+		case errors.Is(err, service.ErrUserNotFound):
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+		case errors.Is(err, service.ErrUsernameTaken):
+			apierror.Respond(c, apierror.Conflict(err.Error()))
+		default:
+			internalError(c, err, "falha ao alterar nome de usuário")
+		}
+		return
+	}
+	h.record(c, audit.Event{Type: audit.EventUserUpdated, TargetID: auditID(user), Data: map[string]any{"username": user.Username}})
+
+	c.JSON(http.StatusOK, dto.NewUserResponse(user))
+}
+
+// ExpirePassword forces the user in the :id path parameter to change their
+// password before using the API again. Admin only; the body is optional.
+func (h *UserHandler) ExpirePassword(c *gin.Context) {
+	var req ExpirePasswordRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, apierror.FromBinding(err))
+			return
+		}
+	}
+
+	actorID := c.GetString("userID")
+	if err := h.userService.ExpirePassword(requestContext(c), actorID, c.Param("id"), req.RevokeSessions); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+		default:
+			internalError(c, err, "falha ao expirar senha do usuário")
+		}
+		return
+	}
+	h.record(c, audit.Event{Type: audit.EventPasswordExpired, TargetID: c.Param("id"), Data: map[string]any{"revoke_sessions": req.RevokeSessions}})
+
+	c.JSON(http.StatusOK, gin.H{"message": "troca de senha obrigatória no próximo acesso"})
+}
+
+// RestoreAccount cancels the scheduled deletion of the user in the :id path
+// parameter. Admin only.
+func (h *UserHandler) RestoreAccount(c *gin.Context) {
+	actorID := c.GetString("userID")
+	if err := h.userService.RestoreAccount(requestContext(c), actorID, c.Param("id")); err != nil {
+		if abortIfCanceled(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			apierror.Respond(c, apierror.NotFound(err.Error()))
+		case errors.Is(err, service.ErrNotScheduled):
+			apierror.Respond(c, apierror.Conflict(err.Error()))
+		default:
+			internalError(c, err, "falha ao restaurar conta")
+		}
+		return
+	}
+	h.record(c, audit.Event{Type: audit.EventAccountRestored, TargetID: c.Param("id")})
+
+	c.JSON(http.StatusOK, gin.H{"message": "conta restaurada com sucesso"})
+}
+
+// auditID is the primary key of user, as recorded in the audit log
+func auditID(user *models.User) string {
+	return strconv.FormatUint(uint64(user.ID), 10)
+}
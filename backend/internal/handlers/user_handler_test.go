@@ -0,0 +1,608 @@
+// Package handlers tests
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/dto"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/pagination"
+	"gosveltekit/internal/repository"
+	"gosveltekit/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MockUserService implements the service.UserServiceInterface interface
+type MockUserService struct {
+	ListUsersFunc         func(ctx context.Context, filter repository.UserFilter, params pagination.Params, sort pagination.Sort) ([]models.User, int64, error)
+	GetUserFunc           func(ctx context.Context, userID string) (*models.User, error)
+	CreateUserFunc        func(ctx context.Context, actorID string, input service.CreateUserInput) (*models.User, error)
+	UpdateDisplayNameFunc func(ctx context.Context, actorID, userID, displayName string) (*models.User, error)
+	SetActiveFunc         func(ctx context.Context, actorID, userID string, active bool) (*models.User, error)
+	UpdateRoleFunc        func(ctx context.Context, actorID, userID, role string, revokeSessions bool) (*models.User, error)
+	ExpirePasswordFunc    func(ctx context.Context, actorID, userID string, revokeSessions bool) error
+	UpdateUsernameFunc    func(ctx context.Context, actorID, userID, username string) (*models.User, error)
+	RestoreAccountFunc    func(ctx context.Context, actorID, userID string) error
+	ExportUsersFunc       func(ctx context.Context, sort pagination.Sort, fn func(*models.User) error) error
+}
+
+func (m *MockUserService) ListUsers(ctx context.Context, filter repository.UserFilter, params pagination.Params, sort pagination.Sort) ([]models.User, int64, error) {
+	return m.ListUsersFunc(ctx, filter, params, sort)
+}
+
+func (m *MockUserService) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	return m.GetUserFunc(ctx, userID)
+}
+
+func (m *MockUserService) CreateUser(ctx context.Context, actorID string, input service.CreateUserInput) (*models.User, error) {
+	return m.CreateUserFunc(ctx, actorID, input)
+}
+
+func (m *MockUserService) UpdateDisplayName(ctx context.Context, actorID, userID, displayName string) (*models.User, error) {
+	return m.UpdateDisplayNameFunc(ctx, actorID, userID, displayName)
+}
+
+func (m *MockUserService) SetActive(ctx context.Context, actorID, userID string, active bool) (*models.User, error) {
+	return m.SetActiveFunc(ctx, actorID, userID, active)
+}
+
+func (m *MockUserService) ExportUsers(ctx context.Context, sort pagination.Sort, fn func(*models.User) error) error {
+	return m.ExportUsersFunc(ctx, sort, fn)
+}
+
+func (m *MockUserService) UpdateRole(ctx context.Context, actorID, userID, role string, revokeSessions bool) (*models.User, error) {
+	return m.UpdateRoleFunc(ctx, actorID, userID, role, revokeSessions)
+}
+
+func (m *MockUserService) ExpirePassword(ctx context.Context, actorID, userID string, revokeSessions bool) error {
+	return m.ExpirePasswordFunc(ctx, actorID, userID, revokeSessions)
+}
+
+func (m *MockUserService) UpdateUsername(ctx context.Context, actorID, userID, username string) (*models.User, error) {
+	return m.UpdateUsernameFunc(ctx, actorID, userID, username)
+}
+
+func (m *MockUserService) RestoreAccount(ctx context.Context, actorID, userID string) error {
+	return m.RestoreAccountFunc(ctx, actorID, userID)
+}
+
+func TestUserHandler_List(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedSort   pagination.Sort
+		expectedFilter repository.UserFilter
+	}{
+		{name: "default sort", query: "", expectedStatus: http.StatusOK, expectedSort: pagination.Sort{Column: "created_at", Desc: true}},
+		{name: "allowed key", query: "sort=-username", expectedStatus: http.StatusOK, expectedSort: pagination.Sort{Column: "username", Desc: true}},
+		{name: "disallowed key", query: "sort=password_hash", expectedStatus: http.StatusBadRequest},
+		{name: "raw SQL", query: "sort=" + url.QueryEscape("id; DROP TABLE users"), expectedStatus: http.StatusBadRequest},
+		{name: "filters", query: "q=ali&role=admin", expectedStatus: http.StatusOK, expectedSort: pagination.Sort{Column: "created_at", Desc: true}, expectedFilter: repository.UserFilter{Search: "ali", Role: "admin"}},
+		{name: "invalid active", query: "active=maybe", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			called := false
+			mockService := &MockUserService{
+				ListUsersFunc: func(ctx context.Context, filter repository.UserFilter, params pagination.Params, sort pagination.Sort) ([]models.User, int64, error) {
+					called = true
+					if sort != tt.expectedSort {
+						t.Errorf("expected sort %+v, got %+v", tt.expectedSort, sort)
+					}
+					if filter != tt.expectedFilter {
+						t.Errorf("expected filter %+v, got %+v", tt.expectedFilter, filter)
+					}
+					return []models.User{{Username: "alice", Email: "alice@example.com"}}, 1, nil
+				},
+			}
+			handler := NewUserHandler(mockService)
+
+			c.Request, _ = http.NewRequest(http.MethodGet, "/api/admin/users?"+tt.query, nil)
+			handler.List(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				if called {
+					t.Error("expected service not to be called for invalid parameters")
+				}
+				return
+			}
+
+			var response struct {
+				Data []map[string]any `json:"data"`
+				Meta pagination.Meta  `json:"meta"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if len(response.Data) != 1 || response.Data[0]["username"] != "alice" {
+				t.Errorf("unexpected data: %v", response.Data)
+			}
+			if response.Meta.Total != 1 {
+				t.Errorf("expected total 1, got %d", response.Meta.Total)
+			}
+		})
+	}
+}
+
+func TestUserHandler_UpdateRole(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{name: "success", body: `{"role":"admin","revoke_sessions":true}`, expectedStatus: http.StatusOK},
+		{name: "missing role", body: `{}`, expectedStatus: http.StatusBadRequest},
+		{name: "invalid role", body: `{"role":"superuser"}`, serviceErr: service.ErrInvalidRole, expectedStatus: http.StatusBadRequest},
+		{name: "last admin", body: `{"role":"user"}`, serviceErr: service.ErrLastAdmin, expectedStatus: http.StatusConflict},
+		{name: "unknown user", body: `{"role":"user"}`, serviceErr: service.ErrUserNotFound, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			mockService := &MockUserService{
+				UpdateRoleFunc: func(ctx context.Context, actorID, userID, role string, revokeSessions bool) (*models.User, error) {
+					if actorID != "1" || userID != "7" {
+						t.Errorf("expected actor 1 and user 7, got %q and %q", actorID, userID)
+					}
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					if role != "admin" || !revokeSessions {
+						t.Errorf("expected role admin with revocation, got %q, %v", role, revokeSessions)
+					}
+					user := &models.User{Username: "bob", Role: role}
+					user.ID = 7
+					return user, nil
+				},
+			}
+			handler := NewUserHandler(mockService)
+
+			c.Request, _ = http.NewRequest(http.MethodPatch, "/api/admin/users/7/role", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = gin.Params{{Key: "id", Value: "7"}}
+			c.Set("userID", "1")
+			handler.UpdateRole(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK && !strings.Contains(w.Body.String(), `"role":"admin"`) {
+				t.Errorf("expected the updated user in the response, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUserHandler_Export(t *testing.T) {
+	const rows = 1000
+
+	t.Run("streams rows as they come", func(t *testing.T) {
+		c, w := setupTestRouter()
+		mockService := &MockUserService{
+			ExportUsersFunc: func(ctx context.Context, sort pagination.Sort, fn func(*models.User) error) error {
+				for i := range rows {
+					// The previous row is already written when the next one is read
+					if i > 0 && !strings.Contains(w.Body.String(), fmt.Sprintf(`"username":"user%d"`, i-1)) {
+						t.Fatalf("row %d was buffered instead of written", i-1)
+					}
+					if err := fn(&models.User{Username: fmt.Sprintf("user%d", i)}); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		}
+		handler := NewUserHandler(mockService)
+
+		c.Request, _ = http.NewRequest(http.MethodGet, "/api/admin/users/export", nil)
+		handler.Export(c)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		if !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment;") {
+			t.Errorf("expected an attachment, got %q", w.Header().Get("Content-Disposition"))
+		}
+		var users []map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil {
+			t.Fatalf("expected a JSON array: %v", err)
+		}
+		if len(users) != rows {
+			t.Errorf("expected %d users, got %d", rows, len(users))
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		c, w := setupTestRouter()
+		handler := NewUserHandler(&MockUserService{
+			ExportUsersFunc: func(ctx context.Context, sort pagination.Sort, fn func(*models.User) error) error {
+				return nil
+			},
+		})
+
+		c.Request, _ = http.NewRequest(http.MethodGet, "/api/admin/users/export", nil)
+		handler.Export(c)
+
+		if w.Code != http.StatusOK || w.Body.String() != "[]" {
+			t.Errorf("expected an empty array, got %d %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("failure before the first row", func(t *testing.T) {
+		c, w := setupTestRouter()
+		handler := NewUserHandler(&MockUserService{
+			ExportUsersFunc: func(ctx context.Context, sort pagination.Sort, fn func(*models.User) error) error {
+				return fmt.Errorf("db down")
+			},
+		})
+
+		c.Request, _ = http.NewRequest(http.MethodGet, "/api/admin/users/export", nil)
+		handler.Export(c)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+		if w.Header().Get("Content-Disposition") != "" {
+			t.Errorf("expected no attachment for an error")
+		}
+	})
+}
+
+func TestUserHandler_ExpirePassword(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedRevoke bool
+		expectedStatus int
+	}{
+		{name: "success", expectedStatus: http.StatusOK},
+		{name: "with revocation", body: `{"revoke_sessions":true}`, expectedRevoke: true, expectedStatus: http.StatusOK},
+		{name: "invalid body", body: `{`, expectedStatus: http.StatusBadRequest},
+		{name: "unknown user", serviceErr: service.ErrUserNotFound, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			mockService := &MockUserService{
+				ExpirePasswordFunc: func(ctx context.Context, actorID, userID string, revokeSessions bool) error {
+					if actorID != "1" || userID != "7" {
+						t.Errorf("expected actor 1 and user 7, got %q and %q", actorID, userID)
+					}
+					if revokeSessions != tt.expectedRevoke {
+						t.Errorf("expected revoke_sessions %v, got %v", tt.expectedRevoke, revokeSessions)
+					}
+					return tt.serviceErr
+				},
+			}
+			handler := NewUserHandler(mockService)
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/api/admin/users/7/expire-password", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = gin.Params{{Key: "id", Value: "7"}}
+			c.Set("userID", "1")
+			handler.ExpirePassword(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUserHandler_UpdateUsername(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{name: "success", body: `{"username":"support"}`, expectedStatus: http.StatusOK},
+		{name: "missing username", body: `{}`, expectedStatus: http.StatusBadRequest},
+		{name: "policy violation", body: `{"username":"a b"}`, serviceErr: fmt.Errorf("%w: %w", service.ErrInvalidUsername, auth.ErrUsernameCharacters), expectedStatus: http.StatusBadRequest},
+		{name: "taken", body: `{"username":"bob"}`, serviceErr: service.ErrUsernameTaken, expectedStatus: http.StatusConflict},
+		{name: "unknown user", body: `{"username":"bob"}`, serviceErr: service.ErrUserNotFound, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			mockService := &MockUserService{
+				UpdateUsernameFunc: func(ctx context.Context, actorID, userID, username string) (*models.User, error) {
+					if actorID != "1" || userID != "7" {
+						t.Errorf("expected actor 1 and user 7, got %q and %q", actorID, userID)
+					}
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &models.User{Username: username, Role: "user"}, nil
+				},
+			}
+			handler := NewUserHandler(mockService)
+
+			c.Request, _ = http.NewRequest(http.MethodPatch, "/api/admin/users/7/username", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = gin.Params{{Key: "id", Value: "7"}}
+			c.Set("userID", "1")
+			handler.UpdateUsername(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK {
+				var resp map[string]any
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("invalid JSON response: %v", err)
+				}
+				if resp["username"] != "support" {
+					t.Errorf("expected username support, got %v", resp["username"])
+				}
+			}
+		})
+	}
+}
+
+func TestUserHandler_ListActiveFilter(t *testing.T) {
+	c, w := setupTestRouter()
+	mockService := &MockUserService{
+		ListUsersFunc: func(ctx context.Context, filter repository.UserFilter, params pagination.Params, sort pagination.Sort) ([]models.User, int64, error) {
+			if filter.Active == nil || *filter.Active {
+				t.Errorf("expected the inactive filter, got %+v", filter.Active)
+			}
+			return nil, 0, nil
+		},
+	}
+	handler := NewUserHandler(mockService)
+
+	c.Request, _ = http.NewRequest(http.MethodGet, "/api/admin/users?active=false", nil)
+	handler.List(c)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestUserHandler_Get(t *testing.T) {
+	for id, status := range map[string]int{"7": http.StatusOK, "8": http.StatusNotFound} {
+		c, w := setupTestRouter()
+		handler := NewUserHandler(&MockUserService{
+			GetUserFunc: func(ctx context.Context, userID string) (*models.User, error) {
+				if userID != "7" {
+					return nil, service.ErrUserNotFound
+				}
+				return &models.User{Username: "bob", Role: "user"}, nil
+			},
+		})
+
+		c.Request, _ = http.NewRequest(http.MethodGet, "/api/admin/users/"+id, nil)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+		handler.Get(c)
+
+		if w.Code != status {
+			t.Errorf("%s: expected status %d, got %d", id, status, w.Code)
+		}
+	}
+}
+
+func TestUserHandler_Create(t *testing.T) {
+	const valid = `{"username":"carol","email":"carol@example.com","display_name":"Carol","password":"Str0ng!Pass","role":"admin","must_change_password":true}`
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{name: "success", body: valid, expectedStatus: http.StatusCreated},
+		{name: "missing fields", body: `{"username":"carol"}`, expectedStatus: http.StatusBadRequest},
+		{name: "invalid email", body: `{"username":"carol","email":"carol","display_name":"Carol","password":"Str0ng!Pass"}`, expectedStatus: http.StatusBadRequest},
+		{name: "weak password", body: `{"username":"carol","email":"carol@example.com","display_name":"Carol","password":"password"}`, expectedStatus: http.StatusBadRequest},
+		{name: "invalid role", body: valid, serviceErr: service.ErrInvalidRole, expectedStatus: http.StatusBadRequest},
+		{name: "username taken", body: valid, serviceErr: service.ErrUsernameTaken, expectedStatus: http.StatusConflict},
+		{name: "email taken", body: valid, serviceErr: service.ErrEmailTaken, expectedStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			mockService := &MockUserService{
+				CreateUserFunc: func(ctx context.Context, actorID string, input service.CreateUserInput) (*models.User, error) {
+					if actorID != "1" {
+						t.Errorf("expected actor 1, got %q", actorID)
+					}
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					if input.Role != "admin" || !input.MustChangePassword || input.Password != "Str0ng!Pass" {
+						t.Errorf("unexpected input %+v", input)
+					}
+					return &models.User{Username: input.Username, Email: input.Email, Role: input.Role}, nil
+				},
+			}
+			handler := NewUserHandler(mockService)
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/api/admin/users", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Set("userID", "1")
+			handler.Create(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusCreated && strings.Contains(w.Body.String(), "Str0ng!Pass") {
+				t.Errorf("password leaked: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUserHandler_Update(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{name: "success", body: `{"display_name":"Robert"}`, expectedStatus: http.StatusOK},
+		{name: "missing display name", body: `{}`, expectedStatus: http.StatusBadRequest},
+		{name: "too long", body: `{"display_name":"` + strings.Repeat("a", 101) + `"}`, expectedStatus: http.StatusBadRequest},
+		{name: "unknown user", body: `{"display_name":"Robert"}`, serviceErr: service.ErrUserNotFound, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			mockService := &MockUserService{
+				UpdateDisplayNameFunc: func(ctx context.Context, actorID, userID, displayName string) (*models.User, error) {
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &models.User{Username: "bob", DisplayName: displayName}, nil
+				},
+			}
+			handler := NewUserHandler(mockService)
+
+			c.Request, _ = http.NewRequest(http.MethodPatch, "/api/admin/users/7", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = gin.Params{{Key: "id", Value: "7"}}
+			handler.Update(c)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusOK && !strings.Contains(w.Body.String(), `"display_name":"Robert"`) {
+				t.Errorf("expected the updated user in the response, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUserHandler_Batch(t *testing.T) {
+	var expired []string
+	mockService := &MockUserService{
+		SetActiveFunc: func(ctx context.Context, actorID, userID string, active bool) (*models.User, error) {
+			switch userID {
+			case "1":
+				return nil, service.ErrDisableLastAdmin
+			case "404":
+				return nil, service.ErrUserNotFound
+			case "500":
+				return nil, fmt.Errorf("database is locked")
+			}
+			return &models.User{Username: "bob", Active: active}, nil
+		},
+		ExpirePasswordFunc: func(ctx context.Context, actorID, userID string, revokeSessions bool) error {
+			expired = append(expired, userID)
+			return nil
+		},
+	}
+	handler := NewUserHandler(mockService)
+	do := func(body string) *httptest.ResponseRecorder {
+		c, w := setupTestRouter()
+		c.Request, _ = http.NewRequest(http.MethodPost, "/api/admin/users/batch", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set("userID", "1")
+		handler.Batch(c)
+		return w
+	}
+
+	w := do(`{"action":"disable","user_ids":["7","1","404","500"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var batch dto.BatchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &batch); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	expected := []dto.BatchItemResult{
+		{Index: 0, ID: "7", Status: dto.BatchStatusOK},
+		{Index: 1, ID: "1", Status: dto.BatchStatusFailed, Error: service.ErrDisableLastAdmin.Error()},
+		{Index: 2, ID: "404", Status: dto.BatchStatusFailed, Error: service.ErrUserNotFound.Error()},
+		{Index: 3, ID: "500", Status: dto.BatchStatusFailed, Error: "erro interno do servidor"},
+	}
+	if batch.Succeeded != 1 || batch.Failed != 3 || !reflect.DeepEqual(batch.Results, expected) {
+		t.Errorf("unexpected batch %+v", batch)
+	}
+
+	w = do(`{"action":"expire_password","user_ids":["7","8"]}`)
+	if w.Code != http.StatusOK || !reflect.DeepEqual(expired, []string{"7", "8"}) {
+		t.Errorf("expected both passwords expired, got %d %v", w.Code, expired)
+	}
+
+	for _, body := range []string{
+		`{"action":"delete","user_ids":["7"]}`,
+		`{"action":"enable","user_ids":[]}`,
+		`{"action":"enable","user_ids":[""]}`,
+		`{"action":"enable","user_ids":[` + strings.Repeat(`"7",`, 100) + `"7"]}`,
+	} {
+		if w := do(body); w.Code != http.StatusBadRequest && w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected %s to be rejected, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestUserHandler_DisableEnable(t *testing.T) {
+	tests := []struct {
+		name           string
+		disable        bool
+		serviceErr     error
+		expectedStatus int
+	}{
+		{name: "disable", disable: true, expectedStatus: http.StatusOK},
+		{name: "enable", expectedStatus: http.StatusOK},
+		{name: "last admin", disable: true, serviceErr: service.ErrDisableLastAdmin, expectedStatus: http.StatusConflict},
+		{name: "unknown user", serviceErr: service.ErrUserNotFound, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupTestRouter()
+			mockService := &MockUserService{
+				SetActiveFunc: func(ctx context.Context, actorID, userID string, active bool) (*models.User, error) {
+					if actorID != "1" || userID != "7" {
+						t.Errorf("expected actor 1 and user 7, got %q and %q", actorID, userID)
+					}
+					if active == tt.disable {
+						t.Errorf("expected active %v, got %v", !tt.disable, active)
+					}
+					if tt.serviceErr != nil {
+						return nil, tt.serviceErr
+					}
+					return &models.User{Username: "bob", Active: active}, nil
+				},
+			}
+			handler := NewUserHandler(mockService)
+
+			c.Request, _ = http.NewRequest(http.MethodPost, "/api/admin/users/7/disable", nil)
+			c.Params = gin.Params{{Key: "id", Value: "7"}}
+			c.Set("userID", "1")
+			if tt.disable {
+				handler.Disable(c)
+			} else {
+				handler.Enable(c)
+			}
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package healthcheck
+
+import (
+	"context"
+	"net"
+
+	"gorm.io/gorm"
+)
+
+// DatabaseChecker checks that the database behind db answers a ping
+func DatabaseChecker(db *gorm.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+// TCPChecker checks that a TCP connection can be opened to addr (host:port),
+// e.g. the SMTP server
+func TCPChecker(addr string) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
@@ -0,0 +1,151 @@
+// Package healthcheck aggregates the health of the application's dependencies.
+//
+// Each dependency (database, SMTP, ...) is registered as a Component wrapping a
+// Checker. The Aggregator runs all checks concurrently with a timeout and
+// reports the overall status as the worst component status.
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gosveltekit/internal/logger"
+)
+
+// Status is the health status of a component or of the whole application
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+// severity orders statuses so the worst one can be picked
+func (s Status) severity() int {
+	switch s {
+	case StatusOK:
+		return 0
+	case StatusDegraded:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// DefaultTimeout is used when the aggregator is created without a timeout
+const DefaultTimeout = 2 * time.Second
+
+// ErrCheckTimeout is reported when a check doesn't finish within the timeout
+var ErrCheckTimeout = errors.New("health check timed out")
+
+// Checker is implemented by anything whose health can be checked.
+// Check returns nil when the dependency is healthy.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to the Checker interface
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f(ctx)
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Component is a named dependency checked by the Aggregator.
+//
+// A failing critical component marks the application as down; a failing
+// non-critical one only degrades it.
+type Component struct {
+	Name     string
+	Checker  Checker
+	Critical bool
+}
+
+// Report is the aggregated health result
+type Report struct {
+	Status     Status            `json:"status"`
+	Components map[string]Status `json:"components"`
+}
+
+// Aggregator runs the registered component checks
+type Aggregator struct {
+	components []Component
+	timeout    time.Duration
+}
+
+// NewAggregator creates an Aggregator for the given components
+func NewAggregator(timeout time.Duration, components ...Component) *Aggregator {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Aggregator{
+		components: components,
+		timeout:    timeout,
+	}
+}
+
+// Register adds a component to the aggregator
+func (a *Aggregator) Register(component Component) {
+	a.components = append(a.components, component)
+}
+
+// Check runs all component checks concurrently and aggregates the results
+func (a *Aggregator) Check(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	report := Report{
+		Status:     StatusOK,
+		Components: make(map[string]Status, len(a.components)),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, component := range a.components {
+		wg.Add(1)
+		go func(component Component) {
+			defer wg.Done()
+
+			status := StatusOK
+			if err := runCheck(ctx, component.Checker); err != nil {
+				status = StatusDegraded
+				if component.Critical {
+					status = StatusDown
+				}
+				logger.Warn("Componente com falha no health check", "component", component.Name, "status", status, "error", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[component.Name] = status
+			if status.severity() > report.Status.severity() {
+				report.Status = status
+			}
+		}(component)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// runCheck runs a single check, giving up when ctx is done even if the
+// checker itself ignores the context
+func runCheck(ctx context.Context, checker Checker) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ErrCheckTimeout
+	}
+}
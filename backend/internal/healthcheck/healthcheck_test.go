@@ -0,0 +1,75 @@
+// Package healthcheck tests
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func healthy() Checker {
+	return CheckerFunc(func(ctx context.Context) error { return nil })
+}
+
+func failing() Checker {
+	return CheckerFunc(func(ctx context.Context) error { return errors.New("boom") })
+}
+
+func TestAggregator_AllHealthy(t *testing.T) {
+	a := NewAggregator(time.Second,
+		Component{Name: "database", Checker: healthy(), Critical: true},
+		Component{Name: "email", Checker: healthy()},
+	)
+
+	report := a.Check(context.Background())
+
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Equal(t, map[string]Status{"database": StatusOK, "email": StatusOK}, report.Components)
+}
+
+func TestAggregator_FailingComponents(t *testing.T) {
+	t.Run("non-critical failure degrades", func(t *testing.T) {
+		a := NewAggregator(time.Second,
+			Component{Name: "database", Checker: healthy(), Critical: true},
+			Component{Name: "email", Checker: failing()},
+		)
+
+		report := a.Check(context.Background())
+
+		assert.Equal(t, StatusDegraded, report.Status)
+		assert.Equal(t, StatusOK, report.Components["database"])
+		assert.Equal(t, StatusDegraded, report.Components["email"])
+	})
+
+	t.Run("critical failure is the worst status", func(t *testing.T) {
+		a := NewAggregator(time.Second,
+			Component{Name: "database", Checker: failing(), Critical: true},
+			Component{Name: "email", Checker: failing()},
+		)
+
+		report := a.Check(context.Background())
+
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, StatusDown, report.Components["database"])
+		assert.Equal(t, StatusDegraded, report.Components["email"])
+	})
+}
+
+func TestAggregator_Timeout(t *testing.T) {
+	blocking := CheckerFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	a := NewAggregator(20*time.Millisecond,
+		Component{Name: "slow", Checker: blocking, Critical: true},
+	)
+
+	start := time.Now()
+	report := a.Check(context.Background())
+
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, StatusDown, report.Status)
+}
@@ -0,0 +1,109 @@
+// Package https serves the API over TLS, with a certificate from files or
+// obtained automatically from Let's Encrypt through the ACME HTTP-01
+// challenge, and redirects plain HTTP to HTTPS.
+package https
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"gosveltekit/internal/config"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// DefaultCacheDir is where autocert keeps certificates without
+// server.tls.autocert_cache_dir
+const DefaultCacheDir = "certs"
+
+// Config returns the hardened TLS settings: TLS 1.2 or later and, for TLS
+// 1.2, only ECDHE key exchange with AEAD ciphers (TLS 1.3 suites are always
+// safe and not configurable)
+func Config() *tls.Config {
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+}
+
+// Setup is how the server serves HTTPS
+type Setup struct {
+	// TLSConfig is set on the HTTPS server, certificates included
+	TLSConfig *tls.Config
+	// HTTPHandler answers on server.tls.http_port: ACME challenges, with
+	// autocert, and redirects to HTTPS. Nil without http_port.
+	HTTPHandler http.Handler
+}
+
+// New prepares HTTPS from cfg, which must be valid (see
+// config.TLSConfig.Validate), for the HTTPS server listening on port. It
+// returns nil when TLS is disabled. Certificate files are read now, so a
+// bad certificate stops the server from starting.
+func New(cfg config.TLSConfig, port int) (*Setup, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	setup := &Setup{TLSConfig: Config()}
+	var redirect http.Handler
+	if cfg.HTTPPort != 0 {
+		redirect = RedirectHandler(port)
+		setup.HTTPHandler = redirect
+	}
+
+	if !cfg.Autocert() {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao carregar o certificado TLS: %w", err)
+		}
+		setup.TLSConfig.Certificates = []tls.Certificate{cert}
+		return setup, nil
+	}
+
+	cacheDir := cfg.AutocertCacheDir
+	if cacheDir == "" {
+		cacheDir = DefaultCacheDir
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      cfg.AutocertEmail,
+	}
+	if cfg.AutocertDirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: cfg.AutocertDirectoryURL}
+	}
+	setup.TLSConfig.GetCertificate = manager.GetCertificate
+	// Also answer the TLS-ALPN-01 challenge on the HTTPS port
+	setup.TLSConfig.NextProtos = append(setup.TLSConfig.NextProtos, acme.ALPNProto)
+	setup.HTTPHandler = manager.HTTPHandler(redirect)
+	return setup, nil
+}
+
+// RedirectHandler permanently redirects every request to the same URL over
+// HTTPS on port (omitted when 443). The method and body are kept (308).
+func RedirectHandler(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != 0 && port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
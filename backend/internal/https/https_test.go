@@ -0,0 +1,122 @@
+package https
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a self-signed certificate for localhost and its
+// key, returning their paths
+func writeCertificate(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestNew_Disabled(t *testing.T) {
+	setup, err := New(config.TLSConfig{}, 8080)
+	require.NoError(t, err)
+	assert.Nil(t, setup)
+}
+
+func TestNew_CertificateFiles(t *testing.T) {
+	certFile, keyFile := writeCertificate(t)
+	setup, err := New(config.TLSConfig{CertFile: certFile, KeyFile: keyFile, HTTPPort: 8080}, 8443)
+	require.NoError(t, err)
+	require.Len(t, setup.TLSConfig.Certificates, 1)
+	assert.Equal(t, uint16(tls.VersionTLS12), setup.TLSConfig.MinVersion)
+	require.NotNil(t, setup.HTTPHandler)
+
+	// Serves HTTPS with the certificate, refusing TLS 1.1
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = setup.TLSConfig
+	srv.StartTLS()
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(setup.TLSConfig.Certificates[0].Leaf)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "localhost"}}}
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	old := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "localhost", MaxVersion: tls.VersionTLS11}}}
+	_, err = old.Get(srv.URL)
+	assert.Error(t, err)
+
+	_, err = New(config.TLSConfig{CertFile: certFile, KeyFile: certFile}, 8443)
+	assert.Error(t, err, "the key doesn't match")
+}
+
+func TestNew_Autocert(t *testing.T) {
+	setup, err := New(config.TLSConfig{AutocertDomains: []string{"api.example.com"}, AutocertCacheDir: t.TempDir(), HTTPPort: 80}, 443)
+	require.NoError(t, err)
+	assert.NotNil(t, setup.TLSConfig.GetCertificate)
+	assert.Contains(t, setup.TLSConfig.NextProtos, "acme-tls/1")
+
+	// Other domains never get a certificate
+	_, err = setup.TLSConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: "evil.example.com"})
+	assert.Error(t, err)
+
+	// Anything but a challenge is redirected
+	w := httptest.NewRecorder()
+	setup.HTTPHandler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://api.example.com/api/me", nil))
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "https://api.example.com/api/me", w.Header().Get("Location"))
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		port     int
+		url      string
+		expected string
+	}{
+		{443, "http://example.com/api/items?page=2", "https://example.com/api/items?page=2"},
+		{443, "http://example.com:8080/", "https://example.com/"},
+		{8443, "http://example.com:8080/auth/login", "https://example.com:8443/auth/login"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		RedirectHandler(tt.port).ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader("{}")))
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code, tt.url)
+		assert.Equal(t, tt.expected, w.Header().Get("Location"), tt.url)
+	}
+}
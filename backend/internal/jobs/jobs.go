@@ -0,0 +1,483 @@
+// Package jobs runs background work from a persistent queue.
+//
+// Jobs are queued with Enqueue and stored in a Store (the jobs table, or
+// Redis), so they survive restarts. Run polls the store and hands due jobs to
+// a pool of workers, which call the Handler registered for the job type.
+// Failed jobs are retried with exponential backoff until MaxAttempts, then
+// marked failed; jobs left running by a dead process are requeued. Schedule
+// queues a job periodically ("@every 1h" or a cron expression), once per
+// occurrence even with several instances running.
+//
+// With the database store, InTx queues jobs within a transaction, so a job is
+// only visible once the operation that triggered it commits (the
+// transactional outbox pattern).
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gosveltekit/internal/database"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/metrics"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownType is returned for jobs without a registered Handler
+	ErrUnknownType = errors.New("tipo de job desconhecido")
+	// ErrDuplicate is returned by Enqueue when a job with the same DedupeKey
+	// was already queued
+	ErrDuplicate = errors.New("job já enfileirado")
+	// ErrNotFound is returned by Get for unknown job IDs
+	ErrNotFound = errors.New("job não encontrado")
+)
+
+// maxBackoff caps the wait between attempts
+const maxBackoff = time.Hour
+
+// maintenanceInterval is how often stale jobs are requeued and finished
+// ones pruned
+const maintenanceInterval = time.Minute
+
+// Handler runs a job given its JSON payload. Returning an error retries the
+// job, unless it is wrapped with Permanent.
+type Handler func(ctx context.Context, payload []byte) error
+
+// permanentError marks an error that retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job fails without further attempts, e.g. for
+// payloads that can't be decoded
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// Config configures a Queue
+type Config struct {
+	Workers      int           // Jobs run at the same time. Default: 4
+	PollInterval time.Duration // Default: 1 second
+	MaxAttempts  int           // Default: 5, then the job is marked failed
+	RetryBackoff time.Duration // Wait after the first failure, doubled on each one up to 1 hour. Default: 30 seconds
+	StaleAfter   time.Duration // Running jobs older than this are requeued. Default: 15 minutes
+	Retention    time.Duration // Finished jobs are deleted after this. Default: 7 days
+}
+
+// Option configures a job queued with Enqueue
+type Option func(*models.Job)
+
+// RunAt delays the job until t
+func RunAt(t time.Time) Option {
+	return func(job *models.Job) {
+		job.RunAt = t
+	}
+}
+
+// MaxAttempts overrides Config.MaxAttempts for the job
+func MaxAttempts(n int) Option {
+	return func(job *models.Job) {
+		if n > 0 {
+			job.MaxAttempts = n
+		}
+	}
+}
+
+// DedupeKey queues the job only if no job with the same key was queued
+// before (and not yet pruned); Enqueue returns ErrDuplicate otherwise
+func DedupeKey(key string) Option {
+	return func(job *models.Job) {
+		job.DedupeKey = &key
+	}
+}
+
+// Enqueuer queues jobs, see Queue.Enqueue
+type Enqueuer interface {
+	Enqueue(ctx context.Context, jobType string, payload any, opts ...Option) (*models.Job, error)
+}
+
+// Queue runs the jobs of a Store with a pool of workers
+type Queue struct {
+	store  Store
+	config Config
+	now    func() time.Time
+
+	mu        sync.RWMutex
+	handlers  map[string]Handler
+	schedules []*schedule
+
+	// wake interrupts the poll wait when a due job is queued or a worker
+	// becomes free
+	wake  chan struct{}
+	slots chan struct{}
+}
+
+// schedule is a job type queued periodically
+type schedule struct {
+	jobType string
+	spec    Schedule
+	next    time.Time
+}
+
+// New creates a Queue over store. Handlers and schedules must be registered
+// before Run.
+func New(store Store, config Config) *Queue {
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 30 * time.Second
+	}
+	if config.StaleAfter <= 0 {
+		config.StaleAfter = 15 * time.Minute
+	}
+	if config.Retention <= 0 {
+		config.Retention = 7 * 24 * time.Hour
+	}
+	return &Queue{
+		store:    store,
+		config:   config,
+		now:      time.Now,
+		handlers: make(map[string]Handler),
+		wake:     make(chan struct{}, 1),
+		slots:    make(chan struct{}, config.Workers),
+	}
+}
+
+// Handle registers the handler of jobType, replacing any previous one
+func (q *Queue) Handle(jobType string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = handler
+}
+
+// Schedule queues a jobType job, with no payload, on every occurrence of
+// spec: "@every <duration>" (aligned to the Unix epoch, so every instance
+// agrees), "@hourly", "@daily", "@weekly", "@monthly" or a five-field cron
+// expression in UTC. Each occurrence is queued once across instances.
+func (q *Queue) Schedule(jobType, spec string) error {
+	parsed, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("agendamento do job %s: %w", jobType, err)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.schedules = append(q.schedules, &schedule{jobType: jobType, spec: parsed})
+	return nil
+}
+
+// Enqueue queues a jobType job. payload is serialized as JSON; nil queues no
+// payload. The request ID and trace context of ctx are stored with the job,
+// so it is logged and traced under the request that queued it.
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload any, opts ...Option) (*models.Job, error) {
+	job := &models.Job{
+		Type:        jobType,
+		Status:      models.JobStatusPending,
+		MaxAttempts: q.config.MaxAttempts,
+		RunAt:       q.now(),
+		RequestID:   logger.RequestIDFromContext(ctx),
+		TraceParent: telemetry.TraceParent(ctx),
+	}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao serializar payload do job %s: %w", jobType, err)
+		}
+		job.Payload = string(data)
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	if err := q.store.Enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+	// Jobs queued in a transaction aren't visible until it commits, so
+	// they wait for the next poll
+	if database.TxFromContext(ctx) == nil && !job.RunAt.After(q.now()) {
+		q.signal()
+	}
+	return job, nil
+}
+
+// InTx returns an Enqueuer queuing jobs in tx, so they are only run if it
+// commits. Only the database store honors it: the Redis store queues right
+// away.
+func (q *Queue) InTx(tx *gorm.DB) Enqueuer {
+	return txEnqueuer{queue: q, tx: tx}
+}
+
+type txEnqueuer struct {
+	queue *Queue
+	tx    *gorm.DB
+}
+
+func (e txEnqueuer) Enqueue(ctx context.Context, jobType string, payload any, opts ...Option) (*models.Job, error) {
+	return e.queue.Enqueue(database.WithTx(ctx, e.tx), jobType, payload, opts...)
+}
+
+// Get returns the job with id
+func (q *Queue) Get(ctx context.Context, id uint) (*models.Job, error) {
+	return q.store.Get(ctx, id)
+}
+
+// List returns a page of the jobs matching filter, most recent first, and
+// how many match in total
+func (q *Queue) List(ctx context.Context, filter Filter, offset, limit int) ([]*models.Job, int64, error) {
+	return q.store.List(ctx, filter, offset, limit)
+}
+
+// Counts returns how many jobs there are by status
+func (q *Queue) Counts(ctx context.Context) (map[string]int64, error) {
+	return q.store.Counts(ctx)
+}
+
+// Types returns the registered job types, sorted
+func (q *Queue) Types() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	types := make([]string, 0, len(q.handlers))
+	for jobType := range q.handlers {
+		types = append(types, jobType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Run queues scheduled jobs and runs due ones until ctx is cancelled, then
+// waits for the running jobs to return
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ticker := time.NewTicker(q.config.PollInterval)
+	defer ticker.Stop()
+
+	var lastMaintenance time.Time
+	for {
+		now := q.now()
+		q.queueScheduled(ctx, now)
+		if now.Sub(lastMaintenance) >= maintenanceInterval {
+			q.maintain(ctx, now)
+			lastMaintenance = now
+		}
+		if err := q.dispatch(ctx, &wg); err != nil && !errors.Is(err, context.Canceled) {
+			logger.Error("Erro ao buscar jobs pendentes", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch claims as many due jobs as there are free workers and starts them
+func (q *Queue) dispatch(ctx context.Context, wg *sync.WaitGroup) error {
+	free := cap(q.slots) - len(q.slots)
+	if free == 0 || ctx.Err() != nil {
+		return nil
+	}
+	claimed, err := q.store.Claim(ctx, q.now(), free)
+	if err != nil {
+		return err
+	}
+	for _, job := range claimed {
+		q.slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				<-q.slots
+				q.signal()
+			}()
+			q.process(ctx, job)
+		}()
+	}
+	return nil
+}
+
+// RunDue runs the due jobs, one at a time, until none is left or ctx is
+// cancelled, and returns how many ran. Meant for tests and one-off tools;
+// servers use Run.
+func (q *Queue) RunDue(ctx context.Context) (int, error) {
+	ran := 0
+	for ctx.Err() == nil {
+		claimed, err := q.store.Claim(ctx, q.now(), 1)
+		if err != nil || len(claimed) == 0 {
+			return ran, err
+		}
+		q.process(ctx, claimed[0])
+		ran++
+	}
+	return ran, ctx.Err()
+}
+
+// queueScheduled queues the scheduled jobs whose occurrence has come. Every
+// instance tries, and the dedupe key keeps one.
+func (q *Queue) queueScheduled(ctx context.Context, now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, s := range q.schedules {
+		if s.next.IsZero() {
+			s.next = s.spec.Next(now)
+			continue
+		}
+		if now.Before(s.next) {
+			continue
+		}
+		key := s.jobType + "@" + s.next.UTC().Format(time.RFC3339)
+		if _, err := q.Enqueue(ctx, s.jobType, nil, RunAt(s.next), DedupeKey(key)); err != nil && !errors.Is(err, ErrDuplicate) && !errors.Is(err, context.Canceled) {
+			logger.Error("Erro ao enfileirar job agendado", "error", err, "type", s.jobType)
+			continue
+		}
+		s.next = s.spec.Next(now)
+	}
+}
+
+// maintain requeues jobs left running by a dead process and deletes old
+// finished ones
+func (q *Queue) maintain(ctx context.Context, now time.Time) {
+	if requeued, err := q.store.RequeueStale(ctx, now.Add(-q.config.StaleAfter)); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			logger.Error("Erro ao recuperar jobs travados", "error", err)
+		}
+	} else if requeued > 0 {
+		logger.Warn("Jobs travados recolocados na fila", "total", requeued)
+	}
+	if pruned, err := q.store.Prune(ctx, now.Add(-q.config.Retention)); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			logger.Error("Erro ao remover jobs finalizados", "error", err)
+		}
+	} else if pruned > 0 {
+		logger.Debug("Jobs finalizados removidos", "total", pruned)
+	}
+}
+
+// process runs a claimed job and records the outcome
+func (q *Queue) process(ctx context.Context, job *models.Job) {
+	q.mu.RLock()
+	handler := q.handlers[job.Type]
+	q.mu.RUnlock()
+
+	// Run and log under the request that queued the job, continuing its trace
+	ctx = logger.WithRequestID(ctx, job.RequestID)
+	ctx = telemetry.ContextWithTraceParent(ctx, job.TraceParent)
+	ctx, span := telemetry.Tracer().Start(ctx, "job "+job.Type,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.Int64("job.id", int64(job.ID)),
+			attribute.Int("job.attempt", job.Attempts),
+		),
+	)
+	defer span.End()
+	log := logger.FromContext(ctx)
+
+	start := time.Now()
+	var err error
+	if handler == nil {
+		err = Permanent(fmt.Errorf("%w: %s", ErrUnknownType, job.Type))
+	} else {
+		err = runHandler(ctx, handler, job.Payload)
+	}
+	metrics.JobDuration.WithLabelValues(job.Type).Observe(time.Since(start).Seconds())
+
+	now := q.now()
+	result := metrics.JobResultSucceeded
+	switch {
+	case err == nil:
+		// The payload may contain secrets (e.g. reset tokens), so drop it
+		job.Status = models.JobStatusSucceeded
+		job.FinishedAt = &now
+		job.Payload = ""
+		job.LastError = ""
+		log.Debug("Job executado", "job_id", job.ID, "type", job.Type)
+	case ctx.Err() != nil && !isPermanent(err):
+		// Interrupted by the shutdown: run it again, without counting it
+		telemetry.RecordError(span, err)
+		result = metrics.JobResultRetried
+		job.Status = models.JobStatusPending
+		job.Attempts--
+		job.RunAt = now
+		job.LastError = err.Error()
+		log.Warn("Job interrompido, será executado novamente", "error", err, "job_id", job.ID, "type", job.Type)
+	case isPermanent(err) || job.Attempts >= job.MaxAttempts:
+		telemetry.RecordError(span, err)
+		result = metrics.JobResultFailed
+		job.Status = models.JobStatusFailed
+		job.FinishedAt = &now
+		job.LastError = err.Error()
+		log.Error("Job falhou definitivamente", "error", err, "job_id", job.ID, "type", job.Type, "attempts", job.Attempts)
+	default:
+		telemetry.RecordError(span, err)
+		result = metrics.JobResultRetried
+		job.Status = models.JobStatusPending
+		job.RunAt = now.Add(q.backoff(job.Attempts))
+		job.LastError = err.Error()
+		log.Warn("Falha ao executar job, nova tentativa agendada", "error", err, "job_id", job.ID, "type", job.Type, "attempts", job.Attempts, "run_at", job.RunAt)
+	}
+	metrics.JobsProcessed.WithLabelValues(job.Type, result).Inc()
+
+	// Record the outcome even if ctx was cancelled meanwhile
+	if err := q.store.Update(context.WithoutCancel(ctx), job); err != nil {
+		log.Error("Erro ao atualizar job", "error", err, "job_id", job.ID)
+	}
+}
+
+// runHandler calls handler, turning a panic into an error so one bad job
+// can't bring the process down
+func runHandler(ctx context.Context, handler Handler, payload string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, []byte(payload))
+}
+
+// backoff returns the wait before the next attempt: RetryBackoff *
+// 2^(attempts-1), capped at one hour
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.config.RetryBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
@@ -0,0 +1,204 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue_RetriesWithBackoffThenFails(t *testing.T) {
+	db := setupTestDB(t)
+	q := New(NewDBStore(db), Config{MaxAttempts: 3, RetryBackoff: time.Minute})
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	var runs int
+	q.Handle("flaky", func(ctx context.Context, payload []byte) error {
+		runs++
+		assert.JSONEq(t, `{"id":7}`, string(payload))
+		return errors.New("still down")
+	})
+	_, err := q.Enqueue(ctx, "flaky", map[string]int{"id": 7})
+	require.NoError(t, err)
+
+	for attempt, backoff := range []time.Duration{time.Minute, 2 * time.Minute} {
+		_, err := q.RunDue(ctx)
+		require.NoError(t, err)
+
+		var job models.Job
+		require.NoError(t, db.First(&job).Error)
+		assert.Equal(t, models.JobStatusPending, job.Status)
+		assert.Equal(t, attempt+1, job.Attempts)
+		assert.Equal(t, "still down", job.LastError)
+		assert.True(t, now.Add(backoff).Equal(job.RunAt), "attempt %d due at %s", attempt+1, job.RunAt)
+
+		// Not due yet
+		ran, err := q.RunDue(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, ran)
+		now = job.RunAt
+	}
+
+	_, err = q.RunDue(ctx)
+	require.NoError(t, err)
+	var job models.Job
+	require.NoError(t, db.First(&job).Error)
+	assert.Equal(t, models.JobStatusFailed, job.Status)
+	assert.Equal(t, 3, job.Attempts)
+	assert.NotNil(t, job.FinishedAt)
+	assert.NotEmpty(t, job.Payload, "kept to inspect the failure")
+	assert.Equal(t, 3, runs)
+}
+
+func TestQueue_PermanentFailures(t *testing.T) {
+	db := setupTestDB(t)
+	q := New(NewDBStore(db), Config{})
+	ctx := context.Background()
+
+	q.Handle("bad-payload", func(ctx context.Context, payload []byte) error {
+		return Permanent(errors.New("undecodable"))
+	})
+	q.Handle("panics", func(ctx context.Context, payload []byte) error {
+		panic("boom")
+	})
+	for _, jobType := range []string{"bad-payload", "unknown", "panics"} {
+		_, err := q.Enqueue(ctx, jobType, nil)
+		require.NoError(t, err)
+	}
+	_, err := q.RunDue(ctx)
+	require.NoError(t, err)
+
+	var jobs []models.Job
+	require.NoError(t, db.Order("id").Find(&jobs).Error)
+	require.Len(t, jobs, 3)
+	assert.Equal(t, models.JobStatusFailed, jobs[0].Status)
+	assert.Equal(t, models.JobStatusFailed, jobs[1].Status)
+	assert.Contains(t, jobs[1].LastError, ErrUnknownType.Error())
+	assert.Equal(t, models.JobStatusPending, jobs[2].Status, "panics are retried")
+	assert.Equal(t, "panic: boom", jobs[2].LastError)
+}
+
+func TestQueue_Run(t *testing.T) {
+	db := setupTestDB(t)
+	q := New(NewDBStore(db), Config{Workers: 2, PollInterval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var running, maxRunning atomic.Int32
+	done := make(chan string, 5)
+	q.Handle("work", func(ctx context.Context, payload []byte) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			current := maxRunning.Load()
+			if n <= current || maxRunning.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		done <- logger.RequestIDFromContext(ctx)
+		return nil
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(stopped)
+	}()
+
+	// Queued jobs wake the queue up, without waiting for the poll interval
+	reqCtx := logger.WithRequestID(context.Background(), "req-1")
+	for range 5 {
+		_, err := q.Enqueue(reqCtx, "work", nil)
+		require.NoError(t, err)
+	}
+	for range 5 {
+		select {
+		case requestID := <-done:
+			assert.Equal(t, "req-1", requestID)
+		case <-time.After(5 * time.Second):
+			t.Fatal("jobs not run")
+		}
+	}
+	cancel()
+	<-stopped
+
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2), "at most Workers jobs at once")
+	counts, err := q.Counts(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), counts[models.JobStatusSucceeded])
+}
+
+func TestQueue_InterruptedJobIsRetried(t *testing.T) {
+	db := setupTestDB(t)
+	q := New(NewDBStore(db), Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	q.Handle("slow", func(ctx context.Context, payload []byte) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	_, err := q.Enqueue(ctx, "slow", nil)
+	require.NoError(t, err)
+	_, err = q.RunDue(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var job models.Job
+	require.NoError(t, db.First(&job).Error)
+	assert.Equal(t, models.JobStatusPending, job.Status)
+	assert.Zero(t, job.Attempts, "the shutdown doesn't count as an attempt")
+}
+
+func TestQueue_Schedule(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Date(2026, 3, 1, 12, 0, 30, 0, time.UTC)
+	newQueue := func() *Queue {
+		q := New(NewDBStore(db), Config{})
+		q.now = func() time.Time { return now }
+		require.NoError(t, q.Schedule("cleanup", "@every 1m"))
+		return q
+	}
+	// Two instances sharing the store
+	first, second := newQueue(), newQueue()
+	ctx := context.Background()
+
+	first.queueScheduled(ctx, now)
+	second.queueScheduled(ctx, now)
+	var count int64
+	require.NoError(t, db.Model(&models.Job{}).Count(&count).Error)
+	assert.Zero(t, count, "nothing before the first occurrence")
+
+	now = now.Add(time.Minute)
+	first.queueScheduled(ctx, now)
+	second.queueScheduled(ctx, now)
+	var jobs []models.Job
+	require.NoError(t, db.Find(&jobs).Error)
+	require.Len(t, jobs, 1, "queued once across instances")
+	assert.Equal(t, "cleanup", jobs[0].Type)
+	assert.True(t, jobs[0].RunAt.Equal(time.Date(2026, 3, 1, 12, 1, 0, 0, time.UTC)))
+
+	now = now.Add(time.Minute)
+	first.queueScheduled(ctx, now)
+	require.NoError(t, db.Model(&models.Job{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+
+	assert.Error(t, first.Schedule("broken", "every hour"))
+}
+
+func TestQueue_Backoff(t *testing.T) {
+	q := New(NewDBStore(setupTestDB(t)), Config{RetryBackoff: 10 * time.Minute})
+	assert.Equal(t, 10*time.Minute, q.backoff(1))
+	assert.Equal(t, 20*time.Minute, q.backoff(2))
+	assert.Equal(t, 40*time.Minute, q.backoff(3))
+	assert.Equal(t, time.Hour, q.backoff(4))
+	assert.Equal(t, time.Hour, q.backoff(50))
+}
@@ -0,0 +1,326 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"gosveltekit/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultKeyPrefix is prepended to every key of a RedisStore
+const DefaultKeyPrefix = "jobs:"
+
+// RedisStore keeps jobs in Redis, for deployments sharing Redis rather than
+// a database between instances. Each job is a JSON string, indexed by one
+// sorted set per status (scored by when it is due, started or finished) and
+// one with every job. Jobs can't be queued in a database transaction.
+//
+// List loads every job matching the status filter, so it suits the admin
+// views of queues pruned by Config.Retention, not arbitrary sizes.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore creates a RedisStore naming its keys prefix + name, or
+// DefaultKeyPrefix + name when prefix is empty
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = DefaultKeyPrefix
+	}
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// storedJob is the JSON of a job in Redis, including the fields the API
+// leaves out
+type storedJob struct {
+	models.Job
+	Payload     string  `json:"payload,omitempty"`
+	TraceParent string  `json:"trace_parent,omitempty"`
+	DedupeKey   *string `json:"dedupe_key,omitempty"`
+}
+
+func (s *RedisStore) jobKey(id string) string        { return s.prefix + "job:" + id }
+func (s *RedisStore) statusKey(status string) string { return s.prefix + status }
+func (s *RedisStore) indexKey() string               { return s.prefix + "index" }
+func (s *RedisStore) dedupeKey(key string) string    { return s.prefix + "key:" + key }
+
+func formatID(id uint) string   { return strconv.FormatUint(uint64(id), 10) }
+func score(t time.Time) float64 { return float64(t.UnixMilli()) }
+
+// before is the score range of the members scored before t, or up to t
+// with inclusive
+func before(t time.Time, inclusive bool) *redis.ZRangeBy {
+	bound := strconv.FormatInt(t.UnixMilli(), 10)
+	if !inclusive {
+		bound = "(" + bound
+	}
+	return &redis.ZRangeBy{Min: "-inf", Max: bound}
+}
+
+// statusScore is the score of job in the sorted set of its status
+func statusScore(job *models.Job) float64 {
+	switch {
+	case job.Status == models.JobStatusRunning && job.StartedAt != nil:
+		return score(*job.StartedAt)
+	case job.FinishedAt != nil:
+		return score(*job.FinishedAt)
+	default:
+		return score(job.RunAt)
+	}
+}
+
+func encodeJob(job *models.Job) (string, error) {
+	data, err := json.Marshal(storedJob{Job: *job, Payload: job.Payload, TraceParent: job.TraceParent, DedupeKey: job.DedupeKey})
+	return string(data), err
+}
+
+func decodeJob(value string) (*models.Job, error) {
+	var stored storedJob
+	if err := json.Unmarshal([]byte(value), &stored); err != nil {
+		return nil, err
+	}
+	job := stored.Job
+	job.Payload, job.TraceParent, job.DedupeKey = stored.Payload, stored.TraceParent, stored.DedupeKey
+	return &job, nil
+}
+
+// Enqueue implements Store
+func (s *RedisStore) Enqueue(ctx context.Context, job *models.Job) error {
+	next, err := s.client.Incr(ctx, s.prefix+"seq").Result()
+	if err != nil {
+		return err
+	}
+	id := strconv.FormatInt(next, 10)
+	if job.DedupeKey != nil {
+		set, err := s.client.SetNX(ctx, s.dedupeKey(*job.DedupeKey), id, 0).Result()
+		if err != nil {
+			return err
+		}
+		if !set {
+			return ErrDuplicate
+		}
+	}
+
+	job.ID = uint(next)
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+	value, err := encodeJob(job)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.jobKey(id), value, 0)
+		pipe.ZAdd(ctx, s.statusKey(job.Status), redis.Z{Score: statusScore(job), Member: id})
+		pipe.ZAdd(ctx, s.indexKey(), redis.Z{Score: float64(next), Member: id})
+		return nil
+	})
+	return err
+}
+
+// Claim implements Store. A job belongs to whoever removes it from the
+// pending set, so concurrent claimers never get the same one.
+func (s *RedisStore) Claim(ctx context.Context, now time.Time, limit int) ([]*models.Job, error) {
+	due := before(now, true)
+	due.Count = int64(limit)
+	ids, err := s.client.ZRangeByScore(ctx, s.statusKey(models.JobStatusPending), due).Result()
+	if err != nil {
+		return nil, err
+	}
+	var claimed []*models.Job
+	for _, id := range ids {
+		removed, err := s.client.ZRem(ctx, s.statusKey(models.JobStatusPending), id).Result()
+		if err != nil {
+			return claimed, err
+		}
+		if removed == 0 {
+			continue
+		}
+		job, err := s.load(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return claimed, err
+		}
+		job.Status = models.JobStatusRunning
+		job.StartedAt = &now
+		job.Attempts++
+		if err := s.save(ctx, job, models.JobStatusPending); err != nil {
+			return claimed, err
+		}
+		claimed = append(claimed, job)
+	}
+	return claimed, nil
+}
+
+// Update implements Store
+func (s *RedisStore) Update(ctx context.Context, job *models.Job) error {
+	return s.save(ctx, job, models.JobStatusRunning)
+}
+
+// save stores job and moves it from the sorted set of status previous to
+// that of its status
+func (s *RedisStore) save(ctx context.Context, job *models.Job, previous string) error {
+	job.UpdatedAt = time.Now()
+	value, err := encodeJob(job)
+	if err != nil {
+		return err
+	}
+	id := formatID(job.ID)
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.jobKey(id), value, 0)
+		pipe.ZRem(ctx, s.statusKey(previous), id)
+		pipe.ZAdd(ctx, s.statusKey(job.Status), redis.Z{Score: statusScore(job), Member: id})
+		return nil
+	})
+	return err
+}
+
+// Get implements Store
+func (s *RedisStore) Get(ctx context.Context, id uint) (*models.Job, error) {
+	return s.load(ctx, formatID(id))
+}
+
+func (s *RedisStore) load(ctx context.Context, id string) (*models.Job, error) {
+	value, err := s.client.Get(ctx, s.jobKey(id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeJob(value)
+}
+
+// List implements Store
+func (s *RedisStore) List(ctx context.Context, filter Filter, offset, limit int) ([]*models.Job, int64, error) {
+	set := s.indexKey()
+	if filter.Status != "" {
+		set = s.statusKey(filter.Status)
+	}
+	ids, err := s.client.ZRange(ctx, set, 0, -1).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	pipe := s.client.Pipeline()
+	gets := make([]*redis.StringCmd, len(ids))
+	for i, id := range ids {
+		gets[i] = pipe.Get(ctx, s.jobKey(id))
+	}
+	if len(gets) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			return nil, 0, err
+		}
+	}
+
+	var matching []*models.Job
+	for _, get := range gets {
+		value, err := get.Result()
+		if errors.Is(err, redis.Nil) {
+			continue // pruned meanwhile
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		job, err := decodeJob(value)
+		if err != nil {
+			return nil, 0, err
+		}
+		if filter.Type == "" || job.Type == filter.Type {
+			matching = append(matching, job)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].ID > matching[j].ID })
+
+	total := int64(len(matching))
+	if offset >= len(matching) {
+		return []*models.Job{}, total, nil
+	}
+	return matching[offset:min(offset+limit, len(matching))], total, nil
+}
+
+// Counts implements Store
+func (s *RedisStore) Counts(ctx context.Context) (map[string]int64, error) {
+	cards := make([]*redis.IntCmd, len(statuses))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, status := range statuses {
+			cards[i] = pipe.ZCard(ctx, s.statusKey(status))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(statuses))
+	for i, status := range statuses {
+		counts[status] = cards[i].Val()
+	}
+	return counts, nil
+}
+
+// RequeueStale implements Store
+func (s *RedisStore) RequeueStale(ctx context.Context, startedBefore time.Time) (int, error) {
+	ids, err := s.client.ZRangeByScore(ctx, s.statusKey(models.JobStatusRunning), before(startedBefore, false)).Result()
+	if err != nil {
+		return 0, err
+	}
+	requeued := 0
+	for _, id := range ids {
+		job, err := s.load(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return requeued, err
+		}
+		now := time.Now()
+		if job.Attempts >= job.MaxAttempts {
+			job.Status = models.JobStatusFailed
+			job.LastError = errStaleExhausted.Error()
+			job.FinishedAt = &now
+		} else {
+			job.Status = models.JobStatusPending
+			job.RunAt = now
+			requeued++
+		}
+		if err := s.save(ctx, job, models.JobStatusRunning); err != nil {
+			return requeued, err
+		}
+	}
+	return requeued, nil
+}
+
+// Prune implements Store
+func (s *RedisStore) Prune(ctx context.Context, finishedBefore time.Time) (int, error) {
+	pruned := 0
+	for _, status := range []string{models.JobStatusSucceeded, models.JobStatusFailed} {
+		ids, err := s.client.ZRangeByScore(ctx, s.statusKey(status), before(finishedBefore, false)).Result()
+		if err != nil {
+			return pruned, err
+		}
+		for _, id := range ids {
+			job, loadErr := s.load(ctx, id)
+			_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Del(ctx, s.jobKey(id))
+				pipe.ZRem(ctx, s.statusKey(status), id)
+				pipe.ZRem(ctx, s.indexKey(), id)
+				if loadErr == nil && job.DedupeKey != nil {
+					pipe.Del(ctx, s.dedupeKey(*job.DedupeKey))
+				}
+				return nil
+			})
+			if err != nil {
+				return pruned, err
+			}
+			pruned++
+		}
+	}
+	return pruned, nil
+}
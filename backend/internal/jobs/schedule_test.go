@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		require.NoError(t, err)
+		return parsed
+	}
+	from := at("2026-03-06T10:17:30Z") // a Friday
+
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"@every 1h", "2026-03-06T11:00:00Z"},
+		{"@every 15m", "2026-03-06T10:30:00Z"},
+		{"@hourly", "2026-03-06T11:00:00Z"},
+		{"@daily", "2026-03-07T00:00:00Z"},
+		{"@weekly", "2026-03-08T00:00:00Z"},
+		{"@monthly", "2026-04-01T00:00:00Z"},
+		{"*/5 * * * *", "2026-03-06T10:20:00Z"},
+		{"30 3 * * *", "2026-03-07T03:30:00Z"},
+		{"0 9 * * 1-5", "2026-03-09T09:00:00Z"},
+		{"0 0 * * 7", "2026-03-08T00:00:00Z"},
+		{"0,45 10 * * *", "2026-03-06T10:45:00Z"},
+		{"0 0 29 2 *", "2028-02-29T00:00:00Z"},
+		// Both day fields restricted: either matches
+		{"0 0 1 * 1", "2026-03-09T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.spec)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, at(tt.want), schedule.Next(from), tt.spec)
+	}
+
+	for _, spec := range []string{"", "@every", "@every 10ms", "@every soon", "* * * *", "60 * * * *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "0 0 30 2 *"} {
+		_, err := ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
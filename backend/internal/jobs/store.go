@@ -0,0 +1,202 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gosveltekit/internal/database"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/pagination"
+	"gosveltekit/internal/query"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Filter restricts the jobs returned by Store.List. Empty fields match any
+// job.
+type Filter struct {
+	Status string
+	Type   string
+}
+
+// Store persists the jobs of a Queue. Implementations must be safe for
+// several processes sharing them: Claim never hands the same job out twice.
+type Store interface {
+	// Enqueue persists a new job and sets its ID. Returns ErrDuplicate when
+	// job has a DedupeKey already in use.
+	Enqueue(ctx context.Context, job *models.Job) error
+	// Claim marks up to limit pending jobs due at now as running, counting
+	// an attempt, and returns them
+	Claim(ctx context.Context, now time.Time, limit int) ([]*models.Job, error)
+	// Update saves the outcome of a claimed job
+	Update(ctx context.Context, job *models.Job) error
+	// Get returns a job, or ErrNotFound
+	Get(ctx context.Context, id uint) (*models.Job, error)
+	// List returns a page of the jobs matching filter, most recent first,
+	// and how many match
+	List(ctx context.Context, filter Filter, offset, limit int) ([]*models.Job, int64, error)
+	// Counts returns how many jobs there are by status
+	Counts(ctx context.Context) (map[string]int64, error)
+	// RequeueStale puts back in the queue the jobs running since before
+	// startedBefore, failing those out of attempts, and returns how many
+	RequeueStale(ctx context.Context, startedBefore time.Time) (int, error)
+	// Prune deletes the jobs finished before finishedBefore and returns how
+	// many
+	Prune(ctx context.Context, finishedBefore time.Time) (int, error)
+}
+
+// errStaleExhausted is recorded on stale jobs without attempts left
+var errStaleExhausted = errors.New("job interrompido sem tentativas restantes")
+
+// statuses are every job status, as reported by Counts
+var statuses = []string{models.JobStatusPending, models.JobStatusRunning, models.JobStatusSucceeded, models.JobStatusFailed}
+
+// DBStore keeps jobs in the jobs table. It runs in the transaction of ctx,
+// if any (see database.WithTx), which is how Queue.InTx works.
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore creates a DBStore
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// Enqueue implements Store
+func (s *DBStore) Enqueue(ctx context.Context, job *models.Job) error {
+	res := database.Conn(ctx, s.db).Clauses(clause.OnConflict{DoNothing: true}).Create(job)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDuplicate
+	}
+	return nil
+}
+
+// Claim implements Store. Jobs are claimed one by one with a conditional
+// update, so concurrent claimers skip the jobs taken by others.
+func (s *DBStore) Claim(ctx context.Context, now time.Time, limit int) ([]*models.Job, error) {
+	db := s.db.WithContext(ctx)
+	var candidates []*models.Job
+	if err := db.Where("status = ? AND run_at <= ?", models.JobStatusPending, now).
+		Order("run_at, id").Limit(limit).
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	claimed := candidates[:0]
+	for _, job := range candidates {
+		res := db.Model(&models.Job{}).
+			Where("id = ? AND status = ?", job.ID, models.JobStatusPending).
+			Updates(map[string]any{
+				"status":     models.JobStatusRunning,
+				"started_at": now,
+				"attempts":   gorm.Expr("attempts + 1"),
+			})
+		if res.Error != nil {
+			return claimed, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		job.Status = models.JobStatusRunning
+		job.StartedAt = &now
+		job.Attempts++
+		claimed = append(claimed, job)
+	}
+	return claimed, nil
+}
+
+// Update implements Store
+func (s *DBStore) Update(ctx context.Context, job *models.Job) error {
+	return s.db.WithContext(ctx).Model(&models.Job{}).Where("id = ?", job.ID).Updates(map[string]any{
+		"status":      job.Status,
+		"payload":     job.Payload,
+		"attempts":    job.Attempts,
+		"last_error":  job.LastError,
+		"run_at":      job.RunAt,
+		"finished_at": job.FinishedAt,
+	}).Error
+}
+
+// Get implements Store
+func (s *DBStore) Get(ctx context.Context, id uint) (*models.Job, error) {
+	var job models.Job
+	if err := s.db.WithContext(ctx).First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// List implements Store
+func (s *DBStore) List(ctx context.Context, filter Filter, offset, limit int) ([]*models.Job, int64, error) {
+	db := s.db.WithContext(ctx).Model(&models.Job{})
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.Type != "" {
+		db = db.Where("type = ?", filter.Type)
+	}
+	return query.Paginate[*models.Job](db, query.Options{
+		Offset: offset,
+		Limit:  limit,
+		Sort:   pagination.Sort{Column: "id", Desc: true},
+	})
+}
+
+// Counts implements Store
+func (s *DBStore) Counts(ctx context.Context) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Total  int64
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Job{}).
+		Select("status, COUNT(*) AS total").Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(statuses))
+	for _, status := range statuses {
+		counts[status] = 0
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Total
+	}
+	return counts, nil
+}
+
+// RequeueStale implements Store
+func (s *DBStore) RequeueStale(ctx context.Context, startedBefore time.Time) (int, error) {
+	db := s.db.WithContext(ctx)
+	now := time.Now()
+	if err := db.Model(&models.Job{}).
+		Where("status = ? AND started_at < ? AND attempts >= max_attempts", models.JobStatusRunning, startedBefore).
+		Updates(map[string]any{
+			"status":      models.JobStatusFailed,
+			"last_error":  errStaleExhausted.Error(),
+			"finished_at": now,
+		}).Error; err != nil {
+		return 0, err
+	}
+	res := db.Model(&models.Job{}).
+		Where("status = ? AND started_at < ?", models.JobStatusRunning, startedBefore).
+		Updates(map[string]any{
+			"status": models.JobStatusPending,
+			"run_at": now,
+		})
+	return int(res.RowsAffected), res.Error
+}
+
+// Prune implements Store
+func (s *DBStore) Prune(ctx context.Context, finishedBefore time.Time) (int, error) {
+	res := s.db.WithContext(ctx).
+		Where("status IN ? AND finished_at < ?", []string{models.JobStatusSucceeded, models.JobStatusFailed}, finishedBefore).
+		Delete(&models.Job{})
+	return int(res.RowsAffected), res.Error
+}
@@ -0,0 +1,146 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	// A single connection, so concurrent workers share one database
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&models.Job{}))
+	return db
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"database": func(t *testing.T) Store {
+			return NewDBStore(setupTestDB(t))
+		},
+		"redis": func(t *testing.T) Store {
+			server := miniredis.RunT(t)
+			return NewRedisStore(redis.NewClient(&redis.Options{Addr: server.Addr()}), "")
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			testStore(t, newStore(t))
+		})
+	}
+}
+
+// testStore checks the behavior every Store must have
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Millisecond)
+
+	newJob := func(jobType string, runAt time.Time) *models.Job {
+		return &models.Job{Type: jobType, Payload: `{"n":1}`, Status: models.JobStatusPending, MaxAttempts: 2, RunAt: runAt, TraceParent: "trace"}
+	}
+	due := newJob("a", now.Add(-time.Minute))
+	later := newJob("b", now.Add(time.Hour))
+	require.NoError(t, store.Enqueue(ctx, due))
+	require.NoError(t, store.Enqueue(ctx, later))
+	assert.NotZero(t, due.ID)
+	assert.NotEqual(t, due.ID, later.ID)
+
+	// Dedupe keys are only queued once
+	key := "a@1"
+	keyed := newJob("a", now.Add(time.Hour))
+	keyed.DedupeKey = &key
+	require.NoError(t, store.Enqueue(ctx, keyed))
+	again := newJob("a", now.Add(time.Hour))
+	again.DedupeKey = &key
+	assert.ErrorIs(t, store.Enqueue(ctx, again), ErrDuplicate)
+
+	// Only due jobs are claimed, once
+	claimed, err := store.Claim(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, due.ID, claimed[0].ID)
+	assert.Equal(t, models.JobStatusRunning, claimed[0].Status)
+	assert.Equal(t, 1, claimed[0].Attempts)
+	assert.Equal(t, `{"n":1}`, claimed[0].Payload)
+	assert.Equal(t, "trace", claimed[0].TraceParent)
+	claimed, err = store.Claim(ctx, now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	got, err := store.Get(ctx, due.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusRunning, got.Status)
+	_, err = store.Get(ctx, 9999)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	counts, err := store.Counts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{models.JobStatusPending: 2, models.JobStatusRunning: 1, models.JobStatusSucceeded: 0, models.JobStatusFailed: 0}, counts)
+
+	// A job left running too long goes back to the queue
+	requeued, err := store.RequeueStale(ctx, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, requeued)
+	claimed, err = store.Claim(ctx, time.Now().Add(time.Second), 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, 2, claimed[0].Attempts)
+
+	// Out of attempts, a stale job fails instead
+	_, err = store.RequeueStale(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	got, err = store.Get(ctx, due.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusFailed, got.Status)
+	assert.NotNil(t, got.FinishedAt)
+
+	// Outcomes are saved
+	claimed, err = store.Claim(ctx, now.Add(2*time.Hour), 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	finishedAt := now
+	claimed[0].Status = models.JobStatusSucceeded
+	claimed[0].FinishedAt = &finishedAt
+	claimed[0].Payload = ""
+	require.NoError(t, store.Update(ctx, claimed[0]))
+	got, err = store.Get(ctx, claimed[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusSucceeded, got.Status)
+	assert.Empty(t, got.Payload)
+
+	// Listing, most recent first
+	list, total, err := store.List(ctx, Filter{}, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	require.Len(t, list, 2)
+	assert.Equal(t, keyed.ID, list[0].ID)
+	list, total, err = store.List(ctx, Filter{Type: "a"}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, list, 1)
+	assert.Equal(t, due.ID, list[0].ID)
+	_, total, err = store.List(ctx, Filter{Status: models.JobStatusFailed}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+
+	// Finished jobs are pruned
+	pruned, err := store.Prune(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 2, pruned)
+	_, total, err = store.List(ctx, Filter{}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+}
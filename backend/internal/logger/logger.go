@@ -2,16 +2,56 @@
 package logger
 
 import (
+	"errors"
+	"io"
 	"log/slog"
 	"os"
+	"syscall"
+	"time"
 )
 
-var defaultLogger *slog.Logger
+var (
+	defaultLogger *slog.Logger
+	// output is where defaultLogger writes, for Sync
+	output io.Writer
+)
 
 // Init initializes the logger with the specified level and format.
 // level: "debug", "info", "warn", "error"
 // format: "json" or "text"
 func Init(level, format string) {
+	InitWithService(level, format, "")
+}
+
+// InitWithService initializes the logger like Init, adding a "service" field
+// to every line so logs can be told apart in aggregated log systems.
+//
+// The JSON format is meant for collectors reading stdout (e.g. in Docker):
+// each line has "timestamp" (RFC3339), "level" (lowercase), "message" and
+// "service". The text format keeps slog's human-friendly defaults.
+func InitWithService(level, format, service string) {
+	output = os.Stdout
+	defaultLogger = newLogger(output, level, format, service)
+	slog.SetDefault(defaultLogger)
+}
+
+// Sync flushes the log output to its file, so the last lines aren't lost when
+// the process exits. Outputs that can't be synced, like pipes and terminals,
+// are already unbuffered and report no error.
+func Sync() error {
+	syncer, ok := output.(interface{ Sync() error })
+	if !ok {
+		return nil
+	}
+	err := syncer.Sync()
+	if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTSUP) {
+		return nil
+	}
+	return err
+}
+
+// newLogger builds a logger writing to w
+func newLogger(w io.Writer, level, format, service string) *slog.Logger {
 	var logLevel slog.Level
 	switch level {
 	case "debug":
@@ -32,13 +72,61 @@ func Init(level, format string) {
 
 	var handler slog.Handler
 	if format == "json" {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
+		opts.ReplaceAttr = jsonReplaceAttr
+		handler = slog.NewJSONHandler(w, opts)
 	} else {
-		handler = slog.NewTextHandler(os.Stdout, opts)
+		handler = slog.NewTextHandler(w, opts)
 	}
 
-	defaultLogger = slog.New(handler)
-	slog.SetDefault(defaultLogger)
+	if service != "" {
+		handler = handler.WithAttrs([]slog.Attr{slog.String("service", service)})
+	}
+
+	return slog.New(handler)
+}
+
+// jsonReplaceAttr renames slog's built-in keys to the names log collectors
+// expect and normalizes their values
+func jsonReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+
+	switch a.Key {
+	case slog.TimeKey:
+		if t, ok := a.Value.Any().(time.Time); ok {
+			return slog.String("timestamp", t.UTC().Format(time.RFC3339))
+		}
+		a.Key = "timestamp"
+	case slog.LevelKey:
+		if l, ok := a.Value.Any().(slog.Level); ok {
+			return slog.String(slog.LevelKey, levelName(l))
+		}
+	case slog.MessageKey:
+		a.Key = "message"
+	}
+	return a
+}
+
+// levelName maps slog levels to the standard lowercase names
+func levelName(l slog.Level) string {
+	switch {
+	case l < slog.LevelInfo:
+		return "debug"
+	case l < slog.LevelWarn:
+		return "info"
+	case l < slog.LevelError:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// SetLogger replaces the default logger instance, e.g. to capture output in tests.
+func SetLogger(l *slog.Logger) {
+	output = nil
+	defaultLogger = l
+	slog.SetDefault(l)
 }
 
 // Get returns the default logger instance.
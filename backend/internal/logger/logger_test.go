@@ -0,0 +1,89 @@
+// Package logger tests
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogger_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(&buf, "debug", "json", "gosveltekit-test")
+
+	l.Warn("Algo aconteceu", "user_id", "1")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+
+	assert.Equal(t, "warn", entry["level"])
+	assert.Equal(t, "Algo aconteceu", entry["message"])
+	assert.Equal(t, "gosveltekit-test", entry["service"])
+	assert.Equal(t, "1", entry["user_id"])
+	assert.NotContains(t, entry, "msg")
+	assert.NotContains(t, entry, "time")
+
+	timestamp, ok := entry["timestamp"].(string)
+	require.True(t, ok, "timestamp should be a string")
+	_, err := time.Parse(time.RFC3339, timestamp)
+	assert.NoError(t, err)
+}
+
+func TestNewLogger_JSONLevels(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(&buf, "debug", "json", "")
+
+	l.Debug("d")
+	l.Info("i")
+	l.Error("e")
+
+	var levels []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		levels = append(levels, entry["level"].(string))
+		assert.NotContains(t, entry, "service")
+	}
+	assert.Equal(t, []string{"debug", "info", "error"}, levels)
+}
+
+func TestNewLogger_Text(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogger(&buf, "info", "text", "gosveltekit-test")
+
+	l.Info("Servidor iniciado", "port", 8080)
+
+	line := buf.String()
+	assert.Contains(t, line, "level=INFO")
+	assert.Contains(t, line, `msg="Servidor iniciado"`)
+	assert.Contains(t, line, "service=gosveltekit-test")
+	assert.Contains(t, line, "port=8080")
+}
+
+func TestSync(t *testing.T) {
+	defer func(w io.Writer) { output = w }(output)
+
+	file, err := os.CreateTemp(t.TempDir(), "app.log")
+	require.NoError(t, err)
+	defer file.Close()
+	output = file
+	assert.NoError(t, Sync())
+
+	// Pipes can't be synced, which is not an error
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+	output = w
+	assert.NoError(t, Sync())
+
+	output = &bytes.Buffer{}
+	assert.NoError(t, Sync())
+}
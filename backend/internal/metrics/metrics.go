@@ -0,0 +1,206 @@
+// Package metrics defines the application's Prometheus metrics.
+//
+// Metrics are registered on a dedicated Registry (instead of the global
+// default one) so tests and the /metrics endpoint only see what the
+// application defines.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gosveltekit"
+
+// Registration conflict fields
+const (
+	FieldUsername = "username"
+	FieldEmail    = "email"
+)
+
+// Login failure reasons
+const (
+	ReasonInvalidCredentials = "invalid_credentials"
+	ReasonAccountLocked      = "account_locked"
+	ReasonUserInactive       = "user_inactive"
+	ReasonIPThrottled        = "ip_throttled"
+	ReasonPendingDeletion    = "pending_deletion"
+	ReasonEmailNotVerified   = "email_not_verified"
+	ReasonSessionLimit       = "session_limit"
+	ReasonInvalidTwoFactor   = "invalid_two_factor"
+)
+
+// Login methods
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodOAuth     = "oauth"
+	LoginMethodTwoFactor = "two_factor"
+	LoginMethodMagicLink = "magic_link"
+)
+
+// Token states
+const (
+	TokenStatePending = "pending"
+	TokenStateExpired = "expired"
+)
+
+// Email send results
+const (
+	EmailResultSent   = "sent"
+	EmailResultFailed = "failed"
+)
+
+// Job results
+const (
+	JobResultSucceeded = "succeeded"
+	JobResultRetried   = "retried"
+	JobResultFailed    = "failed"
+)
+
+// Registry holds every metric exposed by Handler
+var Registry = prometheus.NewRegistry()
+
+var (
+	// RegistrationConflicts counts registrations rejected because the username
+	// or email is already taken, labeled by field
+	RegistrationConflicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "registration_conflicts_total",
+		Help:      "Registrations rejected because the username or email already exists.",
+	}, []string{"field"})
+
+	// LoginFailures counts failed logins, labeled by reason
+	LoginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "login_failures_total",
+		Help:      "Failed login attempts by reason.",
+	}, []string{"reason"})
+
+	// LoginSuccesses counts logins that created a session, labeled by method
+	LoginSuccesses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "login_successes_total",
+		Help:      "Successful logins by method.",
+	}, []string{"method"})
+
+	// RefreshTokenReuse counts refresh tokens presented again after rotation,
+	// each of which revoked its token family
+	RefreshTokenReuse = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "refresh_token_reuse_total",
+		Help:      "Rotated refresh tokens presented again, revoking their family.",
+	})
+
+	// Tokens reports the stored tokens by type and state, as of the last
+	// cleanup run
+	Tokens = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "tokens",
+		Help:      "Stored tokens by type and state (pending or expired), as of the last cleanup run.",
+	}, []string{"type", "state"})
+
+	// TokensPruned counts expired tokens removed by the cleanup, labeled by type
+	TokensPruned = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "tokens_pruned_total",
+		Help:      "Expired tokens removed by the cleanup, by type.",
+	}, []string{"type"})
+
+	// HTTPRequests counts handled requests, labeled by method, route pattern
+	// and status code
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes request latency, labeled by method and
+	// route pattern
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// DBQueryDuration observes database operations, labeled by operation
+	// (create, query, update, delete, row, raw) and table
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Database operation latency by operation and table.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	// EmailsSent counts emails handed to the SMTP server, labeled by kind
+	// (password_reset, welcome...) and result
+	EmailsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "email",
+		Name:      "sent_total",
+		Help:      "Emails sent by kind and result (sent or failed).",
+	}, []string{"kind", "result"})
+
+	// JobsProcessed counts background job runs, labeled by job type and result
+	// (succeeded, retried or failed)
+	JobsProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "processed_total",
+		Help:      "Background job runs by type and result (succeeded, retried or failed).",
+	}, []string{"type", "result"})
+
+	// JobDuration observes how long background jobs take, labeled by job type
+	JobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "duration_seconds",
+		Help:      "Background job run duration by type.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"type"})
+)
+
+func init() {
+	Registry.MustRegister(
+		RegistrationConflicts,
+		LoginFailures,
+		LoginSuccesses,
+		RefreshTokenReuse,
+		Tokens,
+		TokensPruned,
+		HTTPRequests,
+		HTTPRequestDuration,
+		DBQueryDuration,
+		EmailsSent,
+		JobsProcessed,
+		JobDuration,
+		activeSessions,
+	)
+
+	// Initialize known label values so dashboards see zeros instead of gaps
+	for _, field := range []string{FieldUsername, FieldEmail} {
+		RegistrationConflicts.WithLabelValues(field)
+	}
+	for _, reason := range []string{ReasonInvalidCredentials, ReasonAccountLocked, ReasonUserInactive, ReasonIPThrottled, ReasonPendingDeletion, ReasonEmailNotVerified, ReasonSessionLimit, ReasonInvalidTwoFactor} {
+		LoginFailures.WithLabelValues(reason)
+	}
+	for _, method := range []string{LoginMethodPassword, LoginMethodOAuth, LoginMethodTwoFactor, LoginMethodMagicLink} {
+		LoginSuccesses.WithLabelValues(method)
+	}
+}
+
+// Handler returns the HTTP handler serving the metrics in the Prometheus format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
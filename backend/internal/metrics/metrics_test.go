@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/users/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	before := testutil.ToFloat64(HTTPRequests.WithLabelValues(http.MethodGet, "/users/:id", "204"))
+	unmatchedBefore := testutil.ToFloat64(HTTPRequests.WithLabelValues("OTHER", unmatchedRoute, "404"))
+	for _, path := range []string{"/users/1", "/users/2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/coffee", nil))
+
+	// Labeled by route pattern, not path
+	assert.Equal(t, before+2, testutil.ToFloat64(HTTPRequests.WithLabelValues(http.MethodGet, "/users/:id", "204")))
+	assert.Equal(t, unmatchedBefore+1, testutil.ToFloat64(HTTPRequests.WithLabelValues("OTHER", unmatchedRoute, "404")))
+	assert.Positive(t, testutil.CollectAndCount(HTTPRequestDuration, "gosveltekit_http_request_duration_seconds"))
+}
+
+func TestGORMPlugin(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(GORMPlugin{}))
+
+	type widget struct {
+		ID   uint
+		Name string
+	}
+	require.NoError(t, db.AutoMigrate(&widget{}))
+	require.NoError(t, db.Create(&widget{Name: "a"}).Error)
+	var found []widget
+	require.NoError(t, db.Find(&found).Error)
+
+	output := gatherText(t)
+	assert.Contains(t, output, `gosveltekit_db_query_duration_seconds_count{operation="create",table="widgets"}`)
+	assert.Contains(t, output, `gosveltekit_db_query_duration_seconds_count{operation="query",table="widgets"}`)
+}
+
+func TestActiveSessions(t *testing.T) {
+	t.Cleanup(func() { SetActiveSessionsFunc(nil) })
+
+	assert.NotContains(t, gatherText(t), "gosveltekit_auth_active_sessions ", "nothing reported without a counter")
+
+	SetActiveSessionsFunc(func(ctx context.Context) (int64, error) { return 3, nil })
+	assert.Contains(t, gatherText(t), "gosveltekit_auth_active_sessions 3")
+
+	// A failing count is skipped, not a failed scrape
+	SetActiveSessionsFunc(func(ctx context.Context) (int64, error) { return 0, errors.New("database down") })
+	output := gatherText(t)
+	assert.NotContains(t, output, "gosveltekit_auth_active_sessions ")
+	assert.Contains(t, output, "gosveltekit_auth_login_failures_total")
+}
+
+// gatherText scrapes Handler
+func gatherText(t *testing.T) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	return strings.TrimSpace(w.Body.String())
+}
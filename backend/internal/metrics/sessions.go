@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gosveltekit/internal/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// activeSessionsTimeout bounds the count run on each scrape
+const activeSessionsTimeout = 2 * time.Second
+
+// activeSessions reports the unexpired sessions, counted on each scrape by
+// the function set with SetActiveSessionsFunc. Without one it reports
+// nothing.
+var activeSessions = &sessionsCollector{
+	desc: prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "auth", "active_sessions"),
+		"Unexpired sessions, counted on each scrape.",
+		nil, nil,
+	),
+}
+
+type sessionsCollector struct {
+	desc *prometheus.Desc
+
+	mu    sync.RWMutex
+	count func(ctx context.Context) (int64, error)
+}
+
+// SetActiveSessionsFunc sets how the active sessions are counted, e.g. by
+// the session adapter. nil stops reporting them.
+func SetActiveSessionsFunc(count func(ctx context.Context) (int64, error)) {
+	activeSessions.mu.Lock()
+	defer activeSessions.mu.Unlock()
+	activeSessions.count = count
+}
+
+// Describe implements prometheus.Collector
+func (c *sessionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector. A failed count is logged and
+// skipped, so the rest of the scrape still succeeds.
+func (c *sessionsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	count := c.count
+	c.mu.RUnlock()
+	if count == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), activeSessionsTimeout)
+	defer cancel()
+	n, err := count(ctx)
+	if err != nil {
+		logger.Warn("Falha ao contar sessões ativas para métricas", "error", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n))
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"gosveltekit/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLog logs one line per request once it is handled: method, path,
+// route, status, latency, size and client IP, plus the user ID when the
+// request was authenticated. Install it after RequestID so the line carries
+// the request ID. The query string is left out, as it may hold tokens.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		args := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", max(c.Writer.Size(), 0),
+			"ip", c.ClientIP(),
+		}
+		if userID := c.GetString("userID"); userID != "" {
+			args = append(args, "user_id", userID)
+		}
+
+		log := logger.FromContext(c.Request.Context())
+		if status >= http.StatusInternalServerError {
+			log.Warn("Requisição HTTP", args...)
+		} else {
+			log.Info("Requisição HTTP", args...)
+		}
+	}
+}
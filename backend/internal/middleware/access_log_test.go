@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gosveltekit/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	logger.SetLogger(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer logger.Init("info", "text")
+
+	router := gin.New()
+	router.Use(RequestID(), AccessLog())
+	router.GET("/users/:id", func(c *gin.Context) {
+		c.Set("userID", "42")
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/fail", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+	req := httptest.NewRequest(http.MethodGet, "/users/7?token=secret", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &line))
+	assert.Equal(t, "INFO", line["level"])
+	assert.Equal(t, "req-1", line["request_id"])
+	assert.Equal(t, http.MethodGet, line["method"])
+	assert.Equal(t, "/users/7", line["path"], "without the query")
+	assert.Equal(t, "/users/:id", line["route"])
+	assert.Equal(t, float64(http.StatusOK), line["status"])
+	assert.Equal(t, float64(2), line["bytes"])
+	assert.Equal(t, "42", line["user_id"])
+	assert.Contains(t, line, "latency_ms")
+
+	// Server errors stand out, and anonymous requests have no user
+	logs.Reset()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	line = nil
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &line))
+	assert.Equal(t, "WARN", line["level"])
+	assert.NotContains(t, line, "user_id")
+	assert.NotEmpty(t, line["request_id"])
+}
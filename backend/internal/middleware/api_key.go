@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyScheme is the Authorization scheme of API keys:
+// "Authorization: ApiKey {key}"
+const APIKeyScheme = "ApiKey"
+
+// apiKeyContextKey is where AuthMiddleware stores the *auth.APIKey of
+// requests authenticated with one
+const apiKeyContextKey = "apiKey"
+
+// APIKeyFromContext returns the API key that authenticated the request, or
+// nil for session-authenticated requests
+func APIKeyFromContext(c *gin.Context) *auth.APIKey {
+	value, _ := c.Get(apiKeyContextKey)
+	key, _ := value.(*auth.APIKey)
+	return key
+}
+
+// apiKeyToken returns the key of an "ApiKey {key}" Authorization header and
+// whether the header uses that scheme at all. The scheme is
+// case-insensitive; a malformed key comes back empty.
+func apiKeyToken(header string) (string, bool) {
+	scheme, key, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, APIKeyScheme) {
+		return "", false
+	}
+	if strings.ContainsAny(key, " \t") {
+		return "", true
+	}
+	return key, true
+}
+
+// authenticateAPIKey is the part of AuthMiddleware for requests sent with an
+// API key. The request acts as the owner of the key, without a session:
+// "session" and "sessionID" stay unset and "apiKey" holds the key, whose
+// scopes RequirePermission enforces.
+func authenticateAPIKey(c *gin.Context, authManager *auth.AuthManager, key string) {
+	if key == "" {
+		logger.FromContext(c.Request.Context()).Debug("Cabeçalho Authorization malformado", "path", c.Request.URL.Path, "ip", c.ClientIP())
+		abortChallenge(c, APIKeyScheme, BearerInvalidRequest, "malformed Authorization header", "cabeçalho Authorization malformado")
+		return
+	}
+
+	apiKey, user, err := authManager.ValidateAPIKey(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			c.Abort()
+			return
+		}
+		message, description := "chave de API inválida", "invalid api key"
+		switch {
+		case errors.Is(err, auth.ErrInvalidAPIKey):
+			logger.FromContext(c.Request.Context()).Warn("Chave de API inválida", "path", c.Request.URL.Path, "ip", c.ClientIP())
+		case errors.Is(err, auth.ErrUserNotActive), errors.Is(err, auth.ErrAccountPendingDeletion):
+			message, description = "usuário inativo", "user inactive"
+			logger.FromContext(c.Request.Context()).Warn("Chave de API de usuário inativo", "ip", c.ClientIP())
+		default:
+			logger.FromContext(c.Request.Context()).Error("Erro ao validar chave de API", "error", err, "ip", c.ClientIP())
+		}
+		abortChallenge(c, APIKeyScheme, BearerInvalidToken, description, message)
+		return
+	}
+
+	if requestTenant := tenant.FromContext(c.Request.Context()); user.TenantID() != requestTenant {
+		logger.FromContext(c.Request.Context()).Warn("Chave de API usada em outro tenant", "api_key_id", apiKey.ID, "user_id", user.ID, "tenant", requestTenant, "ip", c.ClientIP())
+		abortChallenge(c, APIKeyScheme, BearerInvalidToken, "invalid api key", "chave de API inválida")
+		return
+	}
+
+	c.Set("userID", user.ID)
+	c.Set("role", user.Role)
+	c.Set("user", user)
+	c.Set(apiKeyContextKey, apiKey)
+	c.Next()
+}
+
+// RequireSessionExcept rejects requests authenticated with an API key with
+// 403 and code "api_key_not_allowed", except for the allowed routes (matched
+// like RequireAuthExcept). Keys only reach the routes listed, so new routes
+// need a session unless explicitly opened to them (fail-closed).
+//
+// It expects AuthMiddleware to run first; other requests pass through.
+func RequireSessionExcept(allowed PublicRoutes) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := APIKeyFromContext(c)
+		if key == nil || allowed.Contains(c.Request.Method, c.FullPath()) {
+			c.Next()
+			return
+		}
+		logger.FromContext(c.Request.Context()).Debug("Rota não disponível para chaves de API", "path", c.Request.URL.Path, "api_key_id", key.ID)
+		apierror.Abort(c, apierror.Forbidden("rota não disponível para chaves de API").WithCode("api_key_not_allowed"))
+	}
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"gosveltekit/internal/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditClient stores the client IP and user agent in the request context, so
+// audit events recorded further down name the client without every caller
+// passing them (see audit.WithClient)
+func AuditClient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(audit.WithClient(c.Request.Context(), c.ClientIP(), c.Request.UserAgent()))
+		c.Next()
+	}
+}
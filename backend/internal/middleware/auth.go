@@ -2,11 +2,18 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"gosveltekit/internal/apierror"
 	"gosveltekit/internal/auth"
+	"gosveltekit/internal/config"
 	"gosveltekit/internal/logger"
+	"gosveltekit/internal/tenant"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,47 +21,218 @@ import (
 const (
 	// SessionCookieName is the name of the session cookie
 	SessionCookieName = "session_id"
+	// RefreshCookieName is the name of the refresh token cookie, set with the
+	// cookie token transport
+	RefreshCookieName = "refresh_token"
 	// SessionHeaderName is the name of the session header (for API clients)
 	SessionHeaderName = "X-Session-ID"
+
+	// ExpiresInHeader carries the seconds left until the session expires
+	ExpiresInHeader = "X-Token-Expires-In"
+	// RefreshRecommendedHeader is set to "true" when the session is about to
+	// expire, see WithRefreshRecommendedWithin
+	RefreshRecommendedHeader = "X-Token-Refresh-Recommended"
+)
+
+// Error codes of the WWW-Authenticate challenge (RFC 6750, section 3.1)
+const (
+	BearerInvalidRequest = "invalid_request"
+	BearerInvalidToken   = "invalid_token"
 )
 
+// sessionCookieMaxAge is how long browsers keep the session cookies
+const sessionCookieMaxAge = 30 * 24 * time.Hour
+
+// cookieOptionsKey is where Cookies stores the options in the Gin context
+const cookieOptionsKey = "cookieOptions"
+
+// CookieOptions is how the session, refresh token and CSRF cookies are set
+type CookieOptions struct {
+	// Secure cookies are only sent over HTTPS; turned off for plain-HTTP
+	// development setups
+	Secure   bool
+	SameSite http.SameSite
+	// TokensInCookies is set with config.TokenTransportCookie: the session
+	// and refresh tokens are only set in HttpOnly cookies, and requests
+	// changing data need the CSRF token. Otherwise they stay in the response
+	// body.
+	TokensInCookies bool
+	// RefreshMaxAge is how long browsers keep the refresh token cookie, the
+	// refresh token lifetime
+	RefreshMaxAge time.Duration
+}
+
+// DefaultCookieOptions returns Secure, SameSite Lax cookies with the tokens
+// in the response body
+func DefaultCookieOptions() CookieOptions {
+	return CookieOptions{Secure: true, SameSite: http.SameSiteLaxMode}
+}
+
+// NewCookieOptions returns the cookie options of cfg: the SameSite attribute
+// of cfg.CookieSameSite (config.CookieSameSiteStrict, config.CookieSameSiteNone
+// or, for anything else, Lax) and the token transport of cfg.TokenTransport
+func NewCookieOptions(cfg config.AuthConfig, secure bool) CookieOptions {
+	opts := CookieOptions{
+		Secure:          secure,
+		SameSite:        http.SameSiteLaxMode,
+		TokensInCookies: cfg.TokenTransport == config.TokenTransportCookie,
+		RefreshMaxAge:   cfg.RefreshTokenDuration,
+	}
+	switch cfg.CookieSameSite {
+	case config.CookieSameSiteStrict:
+		opts.SameSite = http.SameSiteStrictMode
+	case config.CookieSameSiteNone:
+		opts.SameSite = http.SameSiteNoneMode
+	}
+	return opts
+}
+
+// Cookies makes opts the cookie options of the requests it handles, read by
+// SetSessionCookie, SetRefreshTokenCookie, ClearSessionCookie and
+// SetCSRFCookie
+func Cookies(opts CookieOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(cookieOptionsKey, opts)
+		c.Next()
+	}
+}
+
+// CookieOptionsFrom returns the cookie options of the request, set by
+// Cookies, or DefaultCookieOptions
+func CookieOptionsFrom(c *gin.Context) CookieOptions {
+	if opts, ok := c.Value(cookieOptionsKey).(CookieOptions); ok {
+		return opts
+	}
+	return DefaultCookieOptions()
+}
+
+// AuthOption configures AuthMiddleware
+type AuthOption func(*authOptions)
+
+type authOptions struct {
+	tokenSources             []string
+	refreshRecommendedWithin time.Duration
+	readFallback             *sessionCache
+}
+
+// WithTokenSources sets the order in which the session ID is looked up:
+// config.TokenSourceHeader (Authorization or X-Session-ID header) and
+// config.TokenSourceCookie. Sources left out are not read at all. An empty
+// list keeps config.DefaultTokenSources.
+func WithTokenSources(sources []string) AuthOption {
+	return func(o *authOptions) {
+		if len(sources) > 0 {
+			o.tokenSources = append([]string(nil), sources...)
+		}
+	}
+}
+
+// WithRefreshRecommendedWithin sets how close to expiry a session must be
+// for authenticated responses to carry RefreshRecommendedHeader. Zero
+// disables it.
+func WithRefreshRecommendedWithin(d time.Duration) AuthOption {
+	return func(o *authOptions) {
+		o.refreshRecommendedWithin = d
+	}
+}
+
 // AuthMiddleware creates a Gin middleware for session-based authentication.
 //
-// It looks for a session ID in either:
+// It looks for a session ID in the sources set by WithTokenSources, by
+// default the headers first and then the cookie, so browsers and API clients
+// share the same middleware:
 // 1. The Authorization header (format: "Bearer {session_id}")
 // 2. The X-Session-ID header
 // 3. A cookie named "session_id"
 //
-// If validation succeeds, it adds user info to the request context.
-func AuthMiddleware(authManager *auth.AuthManager) gin.HandlerFunc {
+// The first source that carries a session ID wins; a request without any
+// gets 401 "autorização necessária".
+//
+// Every 401 carries a WWW-Authenticate Bearer challenge (RFC 6750): without
+// an error code when no session was sent, invalid_request for a malformed
+// Authorization header, and invalid_token for expired, unknown or otherwise
+// rejected sessions.
+//
+// A request with an "Authorization: ApiKey {key}" header is authenticated
+// with the API key instead, as its owner and limited to its scopes (see
+// RequirePermission and RequireSessionExcept); its 401s carry an ApiKey
+// challenge.
+//
+// With WithReadFallback, reads keep working with the last validated copy of
+// the session during brief database outages.
+//
+// If validation succeeds, it adds user info to the request context and
+// reports the session's remaining lifetime in ExpiresInHeader, so clients
+// don't have to track the expiry themselves.
+func AuthMiddleware(authManager *auth.AuthManager, opts ...AuthOption) gin.HandlerFunc {
+	o := &authOptions{tokenSources: config.DefaultTokenSources}
+	for _, opt := range opts {
+		opt(o)
+	}
 	return func(c *gin.Context) {
-		sessionID := extractSessionID(c)
+		if key, ok := apiKeyToken(c.GetHeader("Authorization")); ok {
+			authenticateAPIKey(c, authManager, key)
+			return
+		}
+
+		sessionID := extractSessionID(c, o.tokenSources)
 		if sessionID == "" {
-			logger.Debug("Requisição sem sessão", "path", c.Request.URL.Path, "ip", c.ClientIP())
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "autorização necessária"})
+			if authorizationMalformed(c) {
+				logger.FromContext(c.Request.Context()).Debug("Cabeçalho Authorization malformado", "path", c.Request.URL.Path, "ip", c.ClientIP())
+				abortUnauthorized(c, BearerInvalidRequest, "malformed Authorization header", "cabeçalho Authorization malformado")
+				return
+			}
+			logger.FromContext(c.Request.Context()).Debug("Requisição sem sessão", "path", c.Request.URL.Path, "ip", c.ClientIP())
+			abortUnauthorized(c, "", "", "autorização necessária")
 			return
 		}
 
-		session, user, err := authManager.ValidateSession(sessionID)
+		session, user, err := authManager.ValidateSession(c.Request.Context(), sessionID)
 		if err != nil {
-			status := http.StatusUnauthorized
+			if errors.Is(err, context.Canceled) {
+				// Client went away, nobody will read the response
+				logger.FromContext(c.Request.Context()).Debug("Validação de sessão cancelada pelo cliente", "session_id", sessionID, "ip", c.ClientIP())
+				c.Abort()
+				return
+			}
+
 			message := "sessão inválida"
+			description := "invalid session"
 
 			switch {
 			case err == auth.ErrSessionExpired:
-				message = "sessão expirada"
-				logger.Debug("Sessão expirada", "session_id", sessionID, "ip", c.ClientIP())
+				message, description = "sessão expirada", "session expired"
+				logger.FromContext(c.Request.Context()).Debug("Sessão expirada", "session_id", sessionID, "ip", c.ClientIP())
 			case err == auth.ErrSessionNotFound:
-				message = "sessão não encontrada"
-				logger.Warn("Sessão não encontrada", "session_id", sessionID, "ip", c.ClientIP())
+				message, description = "sessão não encontrada", "session not found"
+				logger.FromContext(c.Request.Context()).Warn("Sessão não encontrada", "session_id", sessionID, "ip", c.ClientIP())
 			case err == auth.ErrUserNotActive:
-				message = "usuário inativo"
-				logger.Warn("Tentativa de acesso com usuário inativo", "session_id", sessionID, "ip", c.ClientIP())
+				message, description = "usuário inativo", "user inactive"
+				logger.FromContext(c.Request.Context()).Warn("Tentativa de acesso com usuário inativo", "session_id", sessionID, "ip", c.ClientIP())
 			default:
-				logger.Error("Erro ao validar sessão", "error", err, "session_id", sessionID, "ip", c.ClientIP())
+				if cache := o.readFallback; cache != nil && readOnly(c.Request) && !errors.Is(err, auth.ErrInvalidCredentials) {
+					if cached, cachedUser, ok := cache.lookup(sessionID); ok {
+						logger.FromContext(c.Request.Context()).Warn("Sessão validada a partir do cache por falha no banco", "error", err, "session_id", sessionID, "ip", c.ClientIP())
+						session, user, err = cached, cachedUser, nil
+						c.Header(StaleDataHeader, "true")
+						break
+					}
+				}
+				logger.FromContext(c.Request.Context()).Error("Erro ao validar sessão", "error", err, "session_id", sessionID, "ip", c.ClientIP())
 			}
 
-			c.AbortWithStatusJSON(status, gin.H{"error": message})
+			if err != nil {
+				abortUnauthorized(c, BearerInvalidToken, description, message)
+				return
+			}
+		} else if cache := o.readFallback; cache != nil {
+			cache.store(session, user)
+		}
+
+		// Sessions are only valid in the tenant the user belongs to
+		if requestTenant := tenant.FromContext(c.Request.Context()); user.TenantID() != requestTenant {
+			logger.FromContext(c.Request.Context()).Warn("Sessão usada em outro tenant", "session_id", sessionID, "user_id", user.ID, "tenant", requestTenant, "ip", c.ClientIP())
+			abortUnauthorized(c, BearerInvalidToken, "invalid session", "sessão inválida")
 			return
 		}
 
@@ -64,16 +242,59 @@ func AuthMiddleware(authManager *auth.AuthManager) gin.HandlerFunc {
 		c.Set("user", user)
 		c.Set("session", session)
 		c.Set("sessionID", sessionID)
+		if session.IsImpersonation() {
+			// Real identity behind the session, see BlockDuringImpersonation
+			c.Set("impersonatorID", session.ImpersonatorID)
+		}
 
 		// If session was refreshed, update the cookie
 		if session.Fresh && c.Request.Method != http.MethodOptions {
-			setSessionCookie(c, sessionID, session.ExpiresAt)
+			SetSessionCookie(c, sessionID, session.ExpiresAt)
 		}
+		setExpiryHeaders(c, session.ExpiresAt.Sub(authManager.Clock().Now()), o.refreshRecommendedWithin)
 
 		c.Next()
 	}
 }
 
+// PublicRoutes is a set of routes reachable without a session, keyed by
+// method and full route pattern, e.g. "GET /auth/password-reset/validate"
+// or "POST /api/webhooks/:provider"
+type PublicRoutes map[string]bool
+
+// NewPublicRoutes builds a PublicRoutes set from "METHOD /path" entries
+func NewPublicRoutes(routes ...string) PublicRoutes {
+	p := make(PublicRoutes, len(routes))
+	for _, route := range routes {
+		p[route] = true
+	}
+	return p
+}
+
+// Contains reports whether the route with method and pattern path is public
+func (p PublicRoutes) Contains(method, path string) bool {
+	return p[method+" "+path]
+}
+
+// RequireAuthExcept applies AuthMiddleware to every route except the public
+// ones. It is meant to be installed once for the whole router, so a new route
+// requires a session unless it is explicitly listed (fail-closed).
+//
+// Routes are matched by their registered pattern (c.FullPath()), not by the
+// request path. Requests that match no route pass through so the 404/405
+// fallbacks answer them. opts configure AuthMiddleware.
+func RequireAuthExcept(authManager *auth.AuthManager, public PublicRoutes, opts ...AuthOption) gin.HandlerFunc {
+	requireAuth := AuthMiddleware(authManager, opts...)
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" || public.Contains(c.Request.Method, route) {
+			c.Next()
+			return
+		}
+		requireAuth(c)
+	}
+}
+
 // RoleMiddleware creates a middleware to verify user roles.
 //
 // It expects the user's role to be set in the context by AuthMiddleware.
@@ -81,7 +302,7 @@ func RoleMiddleware(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("role")
 		if !exists {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "usuário não autenticado"})
+			apierror.Abort(c, apierror.Unauthorized("usuário não autenticado"))
 			return
 		}
 
@@ -92,60 +313,228 @@ func RoleMiddleware(roles ...string) gin.HandlerFunc {
 			}
 		}
 
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "acesso negado"})
+		apierror.Abort(c, apierror.Forbidden("acesso negado"))
+	}
+}
+
+// RequirePermission creates a middleware letting through users whose role
+// grants every one of permissions in policy, so routes declare what they
+// need. A nil policy lets only auth.AdminRole through (see
+// auth.Policy.Allows). Requests authenticated with an API key also need
+// permissions among the scopes of the key.
+//
+// It expects the user's role to be set in the context by AuthMiddleware.
+func RequirePermission(policy *auth.Policy, permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := APIKeyFromContext(c); key != nil && !key.Allows(permissions...) {
+			logger.FromContext(c.Request.Context()).Warn("Acesso negado por falta de escopo na chave de API", "path", c.FullPath(), "user_id", c.GetString("userID"), "api_key_id", key.ID, "permissions", permissions)
+			apierror.Abort(c, apierror.Forbidden("acesso negado"))
+			return
+		}
+		userRole, exists := c.Get("role")
+		if !exists {
+			apierror.Abort(c, apierror.Unauthorized("usuário não autenticado"))
+			return
+		}
+		role, _ := userRole.(string)
+		if !policy.Allows(role, permissions...) {
+			logger.FromContext(c.Request.Context()).Warn("Acesso negado por falta de permissão", "path", c.FullPath(), "user_id", c.GetString("userID"), "role", role, "permissions", permissions)
+			apierror.Abort(c, apierror.Forbidden("acesso negado"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// BlockDuringImpersonation rejects the request with 403 when an admin is
+// impersonating the user, for actions only the real user may take.
+//
+// It expects AuthMiddleware to run first.
+func BlockDuringImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if impersonatorID := c.GetString("impersonatorID"); impersonatorID != "" {
+			logger.FromContext(c.Request.Context()).Warn("Ação bloqueada durante impersonação", "path", c.Request.URL.Path, "admin_id", impersonatorID, "user_id", c.GetString("userID"))
+			apierror.Abort(c, apierror.Forbidden("ação não permitida durante impersonação"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePasswordChangeExcept rejects requests from users who must change
+// their password with 403 and code "password_change_required", except for the
+// allowed routes (matched like RequireAuthExcept), which should cover changing
+// the password and logging out.
+//
+// It expects AuthMiddleware to run first; requests without a user pass through.
+func RequirePasswordChangeExcept(allowed PublicRoutes) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("user")
+		user, ok := value.(*auth.UserData)
+		if !exists || !ok || !user.MustChangePassword() || allowed.Contains(c.Request.Method, c.FullPath()) {
+			c.Next()
+			return
+		}
+		logger.FromContext(c.Request.Context()).Debug("Requisição bloqueada até a troca de senha", "path", c.Request.URL.Path, "user_id", user.ID)
+		apierror.Abort(c, apierror.Forbidden("troca de senha obrigatória").WithCode("password_change_required"))
+	}
+}
+
+// RequireVerifiedEmailExcept rejects requests from users who haven't
+// verified their email with 403 and code "email_verification_required",
+// except for the allowed routes (matched like RequireAuthExcept).
+//
+// It expects AuthMiddleware to run first; requests without a user pass through.
+func RequireVerifiedEmailExcept(allowed PublicRoutes) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("user")
+		user, ok := value.(*auth.UserData)
+		if !exists || !ok || user.EmailVerified() || allowed.Contains(c.Request.Method, c.FullPath()) {
+			c.Next()
+			return
+		}
+		logger.FromContext(c.Request.Context()).Debug("Requisição bloqueada até a verificação do email", "path", c.Request.URL.Path, "user_id", user.ID)
+		apierror.Abort(c, apierror.Forbidden("verificação de email obrigatória").WithCode("email_verification_required"))
 	}
 }
 
-// extractSessionID extracts the session ID from the request.
-// Priority: Authorization header > X-Session-ID header > Cookie
-func extractSessionID(c *gin.Context) string {
-	// Try Authorization header first (for API clients)
-	authHeader := c.GetHeader("Authorization")
-	if authHeader != "" {
-		parts := strings.Split(authHeader, " ")
-		if len(parts) == 2 && parts[0] == "Bearer" {
-			return parts[1]
+// abortUnauthorized answers 401 with message in the body and a Bearer
+// challenge in WWW-Authenticate. Without code the challenge carries no error,
+// as RFC 6750 requires for requests that sent no credentials. description
+// goes in a quoted header value, so it must be plain ASCII.
+func abortUnauthorized(c *gin.Context, code, description, message string) {
+	abortChallenge(c, "Bearer", code, description, message)
+}
+
+// abortChallenge is abortUnauthorized with the challenge of scheme
+func abortChallenge(c *gin.Context, scheme, code, description, message string) {
+	challenge := scheme
+	if code != "" {
+		challenge += ` error="` + code + `"`
+		if description != "" {
+			challenge += `, error_description="` + description + `"`
 		}
 	}
+	c.Header("WWW-Authenticate", challenge)
+	apierror.Abort(c, apierror.Unauthorized(message))
+}
+
+// authorizationMalformed reports whether the request sent an Authorization
+// header that isn't a well-formed "Bearer {session_id}"
+func authorizationMalformed(c *gin.Context) bool {
+	header := c.GetHeader("Authorization")
+	return header != "" && bearerToken(header) == ""
+}
 
-	// Try X-Session-ID header
-	if sessionID := c.GetHeader(SessionHeaderName); sessionID != "" {
-		return sessionID
+// bearerToken returns the token of a "Bearer {token}" Authorization header,
+// or "" if it is malformed. The scheme is case-insensitive (RFC 9110).
+func bearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" || strings.ContainsAny(token, " \t") {
+		return ""
 	}
+	return token
+}
 
-	// Try cookie
-	if cookie, err := c.Cookie(SessionCookieName); err == nil {
-		return cookie
+// setExpiryHeaders reports the session's remaining lifetime, in whole
+// seconds, recommending a refresh below refreshWithin
+func setExpiryHeaders(c *gin.Context, remaining, refreshWithin time.Duration) {
+	if remaining < 0 {
+		remaining = 0
+	}
+	c.Header(ExpiresInHeader, strconv.FormatInt(int64(remaining/time.Second), 10))
+	if refreshWithin > 0 && remaining < refreshWithin {
+		c.Header(RefreshRecommendedHeader, "true")
 	}
+}
 
+// extractSessionID extracts the session ID from the request, trying sources
+// in order
+func extractSessionID(c *gin.Context, sources []string) string {
+	for _, source := range sources {
+		var sessionID string
+		switch source {
+		case config.TokenSourceHeader:
+			sessionID = sessionIDFromHeader(c)
+		case config.TokenSourceCookie:
+			sessionID = sessionIDFromCookie(c)
+		}
+		if sessionID != "" {
+			return sessionID
+		}
+	}
 	return ""
 }
 
-// setSessionCookie sets the session cookie in the response
-func setSessionCookie(c *gin.Context, sessionID string, expiresAt interface{}) {
-	// Calculate max age in seconds
-	maxAge := 30 * 24 * 60 * 60 // 30 days default
+// sessionIDFromHeader reads the Authorization header (for API clients), then
+// the X-Session-ID header
+func sessionIDFromHeader(c *gin.Context) string {
+	if token := bearerToken(c.GetHeader("Authorization")); token != "" {
+		return token
+	}
+	return c.GetHeader(SessionHeaderName)
+}
+
+// sessionIDFromCookie reads the session cookie (for browsers)
+func sessionIDFromCookie(c *gin.Context) string {
+	cookie, err := c.Cookie(SessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie
+}
 
+// SetSessionCookie sets the session cookie in the response, with the
+// options of the request (see Cookies)
+func SetSessionCookie(c *gin.Context, sessionID string, expiresAt interface{}) {
+	opts := CookieOptionsFrom(c)
+	c.SetSameSite(opts.SameSite)
 	c.SetCookie(
 		SessionCookieName,
 		sessionID,
-		maxAge,
+		int(sessionCookieMaxAge.Seconds()),
 		"/",
-		"",   // domain - empty means current domain
-		true, // secure - only send over HTTPS
-		true, // httpOnly - not accessible via JavaScript
+		"",          // domain - empty means current domain
+		opts.Secure, // secure - only send over HTTPS
+		true,        // httpOnly - not accessible via JavaScript
 	)
 }
 
-// ClearSessionCookie removes the session cookie
+// SetRefreshTokenCookie sets the refresh token cookie in the response. It
+// does nothing without a token or outside the cookie token transport.
+func SetRefreshTokenCookie(c *gin.Context, refreshToken string) {
+	opts := CookieOptionsFrom(c)
+	if refreshToken == "" || !opts.TokensInCookies {
+		return
+	}
+	c.SetSameSite(opts.SameSite)
+	c.SetCookie(RefreshCookieName, refreshToken, int(opts.RefreshMaxAge.Seconds()), "/", "", opts.Secure, true)
+}
+
+// RefreshTokenFromCookie reads the refresh token cookie
+func RefreshTokenFromCookie(c *gin.Context) string {
+	cookie, err := c.Cookie(RefreshCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie
+}
+
+// ClearSessionCookie removes the session cookie, and the refresh token cookie
+// with the cookie token transport
 func ClearSessionCookie(c *gin.Context) {
+	opts := CookieOptionsFrom(c)
+	c.SetSameSite(opts.SameSite)
 	c.SetCookie(
 		SessionCookieName,
 		"",
 		-1, // negative max age deletes the cookie
 		"/",
 		"",
-		true,
+		opts.Secure,
 		true,
 	)
+	if opts.TokensInCookies {
+		c.SetCookie(RefreshCookieName, "", -1, "/", "", opts.Secure, true)
+	}
 }
@@ -9,6 +9,7 @@ import (
 
 	"gosveltekit/internal/auth"
 	gormadapter "gosveltekit/internal/auth/adapter/gorm"
+	"gosveltekit/internal/config"
 	"gosveltekit/internal/models"
 
 	"github.com/gin-gonic/gin"
@@ -235,3 +236,241 @@ func TestRoleMiddleware(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "acesso negado")
 	})
 }
+
+func TestRequirePermission(t *testing.T) {
+	policy := auth.NewPolicy([]auth.Role{
+		{Name: "support", Permissions: []string{"users:read"}},
+		{Name: "admin", Permissions: []string{"users:read", "users:write"}},
+	})
+	request := func(policy *auth.Policy, role string, permissions ...string) *httptest.ResponseRecorder {
+		r := gin.New()
+		if role != "" {
+			r.Use(func(c *gin.Context) {
+				c.Set("role", role)
+				c.Next()
+			})
+		}
+		r.GET("/test", RequirePermission(policy, permissions...), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, request(policy, "support", "users:read").Code)
+	assert.Equal(t, http.StatusOK, request(policy, "admin", "users:read", "users:write").Code)
+
+	w := request(policy, "support", "users:read", "users:write")
+	assert.Equal(t, http.StatusForbidden, w.Code, "every permission is required")
+	assert.Contains(t, w.Body.String(), "acesso negado")
+	assert.Equal(t, http.StatusForbidden, request(policy, "unknown", "users:read").Code)
+	assert.Equal(t, http.StatusUnauthorized, request(policy, "", "users:read").Code)
+
+	// Without a policy only the admin role is allowed
+	assert.Equal(t, http.StatusOK, request(nil, "admin", "users:write").Code)
+	assert.Equal(t, http.StatusForbidden, request(nil, "support", "users:write").Code)
+}
+
+func TestBlockDuringImpersonation(t *testing.T) {
+	newRouter := func(impersonatorID string) *gin.Engine {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set("userID", "2")
+			if impersonatorID != "" {
+				c.Set("impersonatorID", impersonatorID)
+			}
+			c.Next()
+		})
+		r.Use(BlockDuringImpersonation())
+		r.GET("/test", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		return r
+	}
+
+	t.Run("Real User", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		newRouter("").ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Impersonating Admin", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		newRouter("1").ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "impersonação")
+	})
+}
+
+func TestAuthMiddleware_ExpiryHeaders(t *testing.T) {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db.AutoMigrate(&models.User{}, &models.Session{})
+	db.Create(&models.User{Username: "testuser", Email: "test@example.com", PasswordHash: "hash", Active: true, Role: "user"})
+
+	clock := auth.NewFakeClock(time.Now())
+	config := auth.DefaultAuthConfig()
+	config.Clock = clock
+	config.RefreshThreshold = 0 // keep the expiry fixed
+	authManager := auth.NewAuthManager(gormadapter.NewUserAdapter(db), gormadapter.NewSessionAdapter(db), config)
+	db.Create(&models.Session{ID: "session-id", UserID: 1, ExpiresAt: clock.Now().Add(time.Hour), CreatedAt: clock.Now(), LastUsedAt: clock.Now()})
+
+	r := gin.New()
+	r.Use(AuthMiddleware(authManager, WithRefreshRecommendedWithin(10*time.Minute)))
+	r.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set(SessionHeaderName, "session-id")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3600", w.Header().Get(ExpiresInHeader))
+	assert.Empty(t, w.Header().Get(RefreshRecommendedHeader))
+
+	// Close to expiry, a refresh is recommended
+	clock.Advance(55 * time.Minute)
+	w = request()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "300", w.Header().Get(ExpiresInHeader))
+	assert.Equal(t, "true", w.Header().Get(RefreshRecommendedHeader))
+}
+
+func TestAuthMiddleware_TokenSources(t *testing.T) {
+	authManager, db := createTestAuthManager()
+	db.Create(&models.User{Username: "testuser", Email: "test@example.com", PasswordHash: "hash", Active: true, Role: "user"})
+	db.Create(&models.User{Username: "other", Email: "other@example.com", PasswordHash: "hash", Active: true, Role: "user"})
+	db.Create(&models.Session{ID: "header-session", UserID: 1, ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now()})
+	db.Create(&models.Session{ID: "cookie-session", UserID: 2, ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now()})
+
+	request := func(sources []string, header, cookie string) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.Use(AuthMiddleware(authManager, WithTokenSources(sources)))
+		r.GET("/test", func(c *gin.Context) {
+			c.String(http.StatusOK, c.GetString("sessionID"))
+		})
+		req := httptest.NewRequest("GET", "/test", nil)
+		if header != "" {
+			req.Header.Set("Authorization", "Bearer "+header)
+		}
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name       string
+		sources    []string
+		header     string
+		cookie     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "header only", header: "header-session", wantStatus: http.StatusOK, wantBody: "header-session"},
+		{name: "cookie only", cookie: "cookie-session", wantStatus: http.StatusOK, wantBody: "cookie-session"},
+		{name: "header wins over cookie", header: "header-session", cookie: "cookie-session", wantStatus: http.StatusOK, wantBody: "header-session"},
+		{name: "neither", wantStatus: http.StatusUnauthorized, wantBody: "autorização necessária"},
+		{name: "cookie first", sources: []string{config.TokenSourceCookie, config.TokenSourceHeader}, header: "header-session", cookie: "cookie-session", wantStatus: http.StatusOK, wantBody: "cookie-session"},
+		{name: "header disabled", sources: []string{config.TokenSourceCookie}, header: "header-session", wantStatus: http.StatusUnauthorized, wantBody: "autorização necessária"},
+		{name: "cookie disabled", sources: []string{config.TokenSourceHeader}, cookie: "cookie-session", wantStatus: http.StatusUnauthorized, wantBody: "autorização necessária"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(tt.sources, tt.header, tt.cookie)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func TestAuthMiddleware_WWWAuthenticate(t *testing.T) {
+	authManager, db := createTestAuthManager()
+	db.Create(&models.User{Username: "testuser", Email: "test@example.com", PasswordHash: "hash", Active: true, Role: "user"})
+	db.Create(&models.Session{ID: "expired-session", UserID: 1, ExpiresAt: time.Now().Add(-time.Hour), CreatedAt: time.Now().Add(-2 * time.Hour)})
+	db.Create(&models.Session{ID: "valid-session", UserID: 1, ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now()})
+
+	r := gin.New()
+	r.Use(AuthMiddleware(authManager))
+	r.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantChallenge string
+	}{
+		{name: "missing", wantStatus: http.StatusUnauthorized, wantChallenge: "Bearer"},
+		{name: "malformed scheme", authorization: "Basic dXNlcjpwYXNz", wantStatus: http.StatusUnauthorized, wantChallenge: `Bearer error="invalid_request", error_description="malformed Authorization header"`},
+		{name: "malformed token", authorization: "Bearer a b", wantStatus: http.StatusUnauthorized, wantChallenge: `Bearer error="invalid_request", error_description="malformed Authorization header"`},
+		{name: "expired", authorization: "Bearer expired-session", wantStatus: http.StatusUnauthorized, wantChallenge: `Bearer error="invalid_token", error_description="session expired"`},
+		{name: "unknown", authorization: "Bearer unknown-session", wantStatus: http.StatusUnauthorized, wantChallenge: `Bearer error="invalid_token", error_description="session not found"`},
+		{name: "valid, scheme is case-insensitive", authorization: "bearer valid-session", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantChallenge, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestCookies(t *testing.T) {
+	setCookies := func(handlers ...gin.HandlerFunc) map[string]*http.Cookie {
+		r := gin.New()
+		r.Use(handlers...)
+		r.GET("/login", func(c *gin.Context) {
+			SetSessionCookie(c, "session-id", nil)
+			SetRefreshTokenCookie(c, "refresh-token")
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+		cookies := map[string]*http.Cookie{}
+		for _, cookie := range w.Result().Cookies() {
+			cookies[cookie.Name] = cookie
+		}
+		return cookies
+	}
+
+	// Without Cookies: secure, Lax, tokens in the body
+	cookies := setCookies()
+	assert.True(t, cookies[SessionCookieName].Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookies[SessionCookieName].SameSite)
+	assert.NotContains(t, cookies, RefreshCookieName)
+
+	opts := NewCookieOptions(config.AuthConfig{
+		CookieSameSite:       config.CookieSameSiteStrict,
+		TokenTransport:       config.TokenTransportCookie,
+		RefreshTokenDuration: time.Hour,
+	}, false)
+	cookies = setCookies(Cookies(opts))
+	assert.False(t, cookies[SessionCookieName].Secure)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[SessionCookieName].SameSite)
+	if assert.Contains(t, cookies, RefreshCookieName) {
+		assert.Equal(t, 3600, cookies[RefreshCookieName].MaxAge)
+		assert.True(t, cookies[RefreshCookieName].HttpOnly)
+	}
+}
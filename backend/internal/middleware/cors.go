@@ -3,33 +3,65 @@
 package middleware
 
 import (
-	"strings"
 	"time"
 
+	"gosveltekit/internal/config"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-// CorsMiddleware configures CORS for the API
-func CorsMiddleware() gin.HandlerFunc {
-	return cors.New(cors.Config{
-		// Explicitly allow common dev origins (localhost and 127.0.0.1 with typical Vite/Bun ports)
-		AllowOrigins: []string{"http://localhost:5173", "http://localhost:4173", "http://127.0.0.1:5173", "http://127.0.0.1:4173"},
-		// Additionally accept origins programmatically if they match localhost or 127.0.0.1 on any port
-		AllowOriginFunc: func(origin string) bool {
-			// simple check to allow http://localhost:<port> and http://127.0.0.1:<port>
-			if origin == "" {
-				return false
-			}
-			if strings.HasPrefix(origin, "http://localhost:") || strings.HasPrefix(origin, "http://127.0.0.1:") {
-				return true
-			}
-			return false
-		},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	})
+// CORS defaults, used for the lists left empty in config.CORSConfig
+var (
+	DefaultCORSMethods        = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	DefaultCORSHeaders        = []string{"Origin", "Content-Type", "Accept", "Authorization", "If-None-Match", "X-Request-ID", "X-Tenant-ID", "X-CSRF-Token"}
+	DefaultCORSExposedHeaders = []string{"Content-Length", "ETag", "X-Request-ID", "X-Token-Expires-In", "X-Token-Refresh-Recommended", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After", "WWW-Authenticate"}
+)
+
+// DefaultCORSMaxAge is how long browsers cache preflight responses by default
+const DefaultCORSMaxAge = 12 * time.Hour
+
+// DefaultCORSConfig is the CORS setup used without a config: the SvelteKit
+// dev server on localhost, with credentials
+func DefaultCORSConfig() config.CORSConfig {
+	return config.CORSConfig{AllowLocalhost: true, AllowCredentials: true}
+}
+
+// CORS configures CORS for the API from cfg, which must be valid (see
+// config.CORSConfig.Validate). It returns nil when no origin is allowed;
+// browsers then refuse cross-origin calls on their own.
+func CORS(cfg config.CORSConfig) gin.HandlerFunc {
+	if !cfg.Enabled() {
+		return nil
+	}
+	corsConfig := cors.Config{
+		AllowMethods:     orDefault(cfg.AllowedMethods, DefaultCORSMethods),
+		AllowHeaders:     orDefault(cfg.AllowedHeaders, DefaultCORSHeaders),
+		ExposeHeaders:    orDefault(cfg.ExposedHeaders, DefaultCORSExposedHeaders),
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+		AllowWildcard:    true,
+	}
+	if corsConfig.MaxAge == 0 {
+		corsConfig.MaxAge = DefaultCORSMaxAge
+	}
+	switch {
+	case cfg.AllowsAllOrigins():
+		corsConfig.AllowAllOrigins = true
+	default:
+		corsConfig.AllowOrigins = cfg.AllowedOrigins
+		if cfg.AllowLocalhost {
+			// http://localhost:<port> and http://127.0.0.1:<port>, whatever
+			// port the dev server picked
+			corsConfig.AllowOriginFunc = config.IsLocalhostOrigin
+		}
+	}
+	return cors.New(corsConfig)
+}
+
+func orDefault(values, defaults []string) []string {
+	if len(values) == 0 {
+		return defaults
+	}
+	return values
 }
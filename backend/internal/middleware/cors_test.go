@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	preflight := func(cfg config.CORSConfig, origin string) *httptest.ResponseRecorder {
+		router := gin.New()
+		if cors := CORS(cfg); cors != nil {
+			router.Use(cors)
+		}
+		router.POST("/auth/login", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		req := httptest.NewRequest(http.MethodOptions, "/auth/login", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "content-type,x-csrf-token")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		w := preflight(DefaultCORSConfig(), "http://localhost:5173")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "http://localhost:5173", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "X-Csrf-Token")
+		assert.Equal(t, "43200", w.Header().Get("Access-Control-Max-Age"))
+
+		assert.Equal(t, http.StatusForbidden, preflight(DefaultCORSConfig(), "https://evil.example").Code)
+	})
+
+	t.Run("configured origins", func(t *testing.T) {
+		cfg := config.CORSConfig{AllowedOrigins: []string{"https://app.example.com", "https://*.preview.example.com"}, AllowedHeaders: []string{"Content-Type", "X-CSRF-Token"}, MaxAge: 10 * time.Minute}
+		require.NoError(t, cfg.Validate())
+		w := preflight(cfg, "https://app.example.com")
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+		assert.Equal(t, "https://pr-7.preview.example.com", preflight(cfg, "https://pr-7.preview.example.com").Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, http.StatusForbidden, preflight(cfg, "http://localhost:5173").Code, "localhost is not allowed unless asked for")
+	})
+
+	t.Run("any origin", func(t *testing.T) {
+		w := preflight(config.CORSConfig{AllowedOrigins: []string{"*"}}, "https://anywhere.example")
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Nil(t, CORS(config.CORSConfig{}))
+	})
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CSRFCookieName is the name of the cookie carrying the CSRF token,
+	// readable by scripts so they can echo it in CSRFHeaderName
+	CSRFCookieName = "csrf_token"
+	// CSRFHeaderName carries the CSRF token in requests changing data
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// csrfTokenKey is where CSRF stores the token of the request in the Gin context
+const csrfTokenKey = "csrfToken"
+
+// CSRF protects cookie-authenticated requests with the double-submit cookie
+// pattern. Every response to a request without the cookie sets a token from
+// g in CSRFCookieName; requests with unsafe methods (anything but GET,
+// HEAD and OPTIONS) must send the same value in CSRFHeaderName, or get 403
+// with code "csrf_token_invalid". Other sites can make the browser send the
+// cookie but can't read it, so they can't forge the header.
+//
+// Clients on another origin, which can't read the cookie, get the token from
+// GET /auth/csrf (see CSRFToken). Requests sent with an API key are exempt:
+// browsers never add that header on their own.
+func CSRF(g tokens.Generator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := apiKeyToken(c.GetHeader("Authorization")); ok {
+			c.Next()
+			return
+		}
+
+		sent, _ := c.Cookie(CSRFCookieName)
+		token := sent
+		if token == "" {
+			var err error
+			if token, err = tokens.Text(g, 32); err != nil {
+				logger.FromContext(c.Request.Context()).Error("Erro ao gerar token CSRF", "error", err)
+				apierror.Abort(c, apierror.Internal("erro interno do servidor"))
+				return
+			}
+			SetCSRFCookie(c, token)
+		}
+		c.Set(csrfTokenKey, token)
+
+		if safeMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if sent == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(c.GetHeader(CSRFHeaderName))) != 1 {
+			logger.FromContext(c.Request.Context()).Info("Requisição sem token CSRF válido", "method", c.Request.Method, "path", c.Request.URL.Path, "ip", c.ClientIP())
+			apierror.Abort(c, apierror.Forbidden("token CSRF ausente ou inválido").WithCode("csrf_token_invalid"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// CSRFToken returns the CSRF token of the request, set by CSRF
+func CSRFToken(c *gin.Context) string {
+	return c.GetString(csrfTokenKey)
+}
+
+// SetCSRFCookie sets the CSRF cookie in the response. Unlike the session
+// cookie it isn't HttpOnly: scripts read it to send it back in the header.
+func SetCSRFCookie(c *gin.Context, token string) {
+	opts := CookieOptionsFrom(c)
+	c.SetSameSite(opts.SameSite)
+	c.SetCookie(CSRFCookieName, token, int(sessionCookieMaxAge.Seconds()), "/", "", opts.Secure, false)
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gosveltekit/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CSRF(tokens.NewDeterministic("csrf")))
+	router.GET("/auth/csrf", func(c *gin.Context) { c.String(http.StatusOK, CSRFToken(c)) })
+	router.POST("/api/items", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	// A request without the cookie gets one
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/csrf", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	cookie := cookies[0]
+	assert.Equal(t, CSRFCookieName, cookie.Name)
+	assert.Equal(t, w.Body.String(), cookie.Value)
+	expected, err := tokens.Text(tokens.NewDeterministic("csrf"), 32)
+	require.NoError(t, err)
+	assert.Equal(t, expected, cookie.Value, "drawn from the generator")
+	assert.False(t, cookie.HttpOnly, "scripts read the cookie")
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	post := func(cookieValue, header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/items", nil)
+		if cookieValue != "" {
+			req.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: cookieValue})
+		}
+		if header != "" {
+			req.Header.Set(CSRFHeaderName, header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, post(cookie.Value, cookie.Value).Code)
+	assert.Equal(t, http.StatusForbidden, post(cookie.Value, "").Code)
+	assert.Equal(t, http.StatusForbidden, post(cookie.Value, "forged").Code)
+	assert.Equal(t, http.StatusForbidden, post("", cookie.Value).Code)
+	w = post("", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "csrf_token_invalid")
+	assert.NotEmpty(t, w.Result().Cookies(), "the rejected client gets a token for its next try")
+
+	// Browsers don't send API keys on their own
+	req := httptest.NewRequest(http.MethodPost, "/api/items", nil)
+	req.Header.Set("Authorization", "ApiKey ak_key")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// A token already in the cookie is kept
+	req = httptest.NewRequest(http.MethodGet, "/auth/csrf", nil)
+	req.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: cookie.Value})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, cookie.Value, w.Body.String())
+	assert.Empty(t, w.Result().Cookies())
+}
@@ -0,0 +1,232 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxETagBody is the largest response ETag buffers; larger ones (exports,
+// downloads) are streamed without an ETag
+const maxETagBody = 1 << 20
+
+// ETag answers conditional GET and HEAD requests: successful responses get a
+// weak ETag hashed from their body, unless the handler set one, and requests
+// whose If-None-Match matches it (or, without If-None-Match, whose
+// If-Modified-Since isn't older than the Last-Modified set by the handler)
+// get 304 Not Modified without the body. Responses without a Cache-Control
+// must be revalidated before reuse and, when authenticated, are private.
+//
+// The response is buffered to hash it. Handlers that flush (streams) or
+// hijack the connection (WebSocket), and bodies over 1 MiB, are passed
+// through untouched.
+func ETag() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		w := &etagWriter{ResponseWriter: original}
+		c.Writer = w
+		// Restored before a panic reaches the recovery middleware too
+		defer func() { c.Writer = original }()
+		c.Next()
+		c.Writer = original
+
+		if w.passthrough {
+			return
+		}
+		status := w.Status()
+		header := original.Header()
+		if status == http.StatusOK {
+			etag := header.Get("ETag")
+			if etag == "" {
+				etag = BodyETag(w.body.Bytes())
+				header.Set("ETag", etag)
+			}
+			if header.Get("Cache-Control") == "" {
+				if c.GetString("userID") != "" {
+					header.Set("Cache-Control", "private, no-cache")
+				} else {
+					header.Set("Cache-Control", "no-cache")
+				}
+			}
+			if notModifiedSince(c.Request, etag, header.Get("Last-Modified")) {
+				header.Del("Content-Type")
+				header.Del("Content-Length")
+				original.WriteHeader(http.StatusNotModified)
+				original.WriteHeaderNow()
+				return
+			}
+		}
+
+		original.WriteHeader(status)
+		if w.body.Len() == 0 {
+			original.WriteHeaderNow()
+			return
+		}
+		_, _ = original.Write(w.body.Bytes())
+	}
+}
+
+// BodyETag returns the weak ETag of a response body
+func BodyETag(body []byte) string {
+	hash := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// VersionETag returns a weak ETag for the values identifying a version of a
+// resource (e.g. its ID and updated_at), for handlers that know it without
+// rendering the body
+func VersionETag(parts ...string) string {
+	return BodyETag([]byte(strings.Join(parts, "\x00")))
+}
+
+// NotModified sets etag on a per-user response and, when the request's
+// If-None-Match matches it, ends the request with 304 Not Modified and
+// returns true. The response is marked private so shared caches never store
+// it, and must be revalidated before reuse.
+func NotModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+
+	if !ETagMatches(c.GetHeader("If-None-Match"), etag) {
+		return false
+	}
+	c.AbortWithStatus(http.StatusNotModified)
+	return true
+}
+
+// ETagMatches reports whether an If-None-Match header matches etag, using the
+// weak comparison required for If-None-Match (RFC 9110, section 13.1.2)
+func ETagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModifiedSince reports whether the client's copy is still current.
+// If-None-Match takes precedence over If-Modified-Since (RFC 9110, section
+// 13.1.3), which HTTP dates compare to the second.
+func notModifiedSince(r *http.Request, etag, lastModified string) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return ETagMatches(ifNoneMatch, etag)
+	}
+	if lastModified == "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// etagWriter buffers a response until ETag decides how to send it, or
+// passes it through once it can't be buffered
+type etagWriter struct {
+	gin.ResponseWriter
+	body        bytes.Buffer
+	status      int
+	passthrough bool
+}
+
+// release sends what was buffered and passes the rest of the response through
+func (w *etagWriter) release() {
+	if w.passthrough {
+		return
+	}
+	w.passthrough = true
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+// WriteHeaderNow is deferred with the body, e.g. for c.AbortWithStatus
+func (w *etagWriter) WriteHeaderNow() {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *etagWriter) Write(data []byte) (int, error) {
+	if !w.passthrough && w.body.Len()+len(data) > maxETagBody {
+		w.release()
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *etagWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *etagWriter) Status() int {
+	if w.passthrough {
+		return w.ResponseWriter.Status()
+	}
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *etagWriter) Size() int {
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	return w.body.Len()
+}
+
+func (w *etagWriter) Written() bool {
+	if w.passthrough {
+		return w.ResponseWriter.Written()
+	}
+	return w.status != 0 || w.body.Len() > 0
+}
+
+func (w *etagWriter) Flush() {
+	w.release()
+	w.ResponseWriter.Flush()
+}
+
+func (w *etagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.passthrough = true
+	return w.ResponseWriter.Hijack()
+}
@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	lastModified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := gin.New()
+	r.Use(ETag())
+	r.GET("/items", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"items": []int{1, 2}}) })
+	r.GET("/private", func(c *gin.Context) {
+		c.Set("userID", "1")
+		c.JSON(http.StatusOK, gin.H{"id": 1})
+	})
+	r.GET("/dated", func(c *gin.Context) {
+		c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	r.GET("/custom", func(c *gin.Context) {
+		c.Header("ETag", `"v1"`)
+		c.Header("Cache-Control", "no-store")
+		c.String(http.StatusOK, "custom")
+	})
+	r.GET("/missing", func(c *gin.Context) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}) })
+	r.GET("/stream", func(c *gin.Context) {
+		c.String(http.StatusOK, "part")
+		c.Writer.Flush()
+		c.String(http.StatusOK, "-rest")
+	})
+	r.GET("/large", func(c *gin.Context) { c.String(http.StatusOK, strings.Repeat("x", maxETagBody+1)) })
+	r.POST("/items", func(c *gin.Context) { c.JSON(http.StatusCreated, gin.H{"id": 3}) })
+
+	do := func(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Hashes Body", func(t *testing.T) {
+		w := do(http.MethodGet, "/items", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, BodyETag(w.Body.Bytes()), w.Header().Get("ETag"))
+		assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+		assert.JSONEq(t, `{"items":[1,2]}`, w.Body.String())
+
+		// Same body, same ETag
+		assert.Equal(t, w.Header().Get("ETag"), do(http.MethodGet, "/items", nil).Header().Get("ETag"))
+	})
+
+	t.Run("If-None-Match", func(t *testing.T) {
+		etag := do(http.MethodGet, "/items", nil).Header().Get("ETag")
+
+		w := do(http.MethodGet, "/items", map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+		assert.Empty(t, w.Header().Get("Content-Type"))
+
+		w = do(http.MethodGet, "/items", map[string]string{"If-None-Match": `"other", ` + strings.TrimPrefix(etag, "W/")})
+		assert.Equal(t, http.StatusNotModified, w.Code, "strong form of the weak ETag matches")
+
+		w = do(http.MethodGet, "/items", map[string]string{"If-None-Match": `"other"`})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, w.Body.String())
+
+		w = do(http.MethodHead, "/items", map[string]string{"If-None-Match": "*"})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+	})
+
+	t.Run("If-Modified-Since", func(t *testing.T) {
+		w := do(http.MethodGet, "/dated", map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+
+		w = do(http.MethodGet, "/dated", map[string]string{"If-Modified-Since": lastModified.Add(-time.Hour).Format(http.TimeFormat)})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// If-None-Match takes precedence
+		w = do(http.MethodGet, "/dated", map[string]string{
+			"If-Modified-Since": lastModified.Format(http.TimeFormat),
+			"If-None-Match":     `"other"`,
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Without Last-Modified there is nothing to compare
+		w = do(http.MethodGet, "/items", map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Authenticated Responses Are Private", func(t *testing.T) {
+		w := do(http.MethodGet, "/private", nil)
+		assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+	})
+
+	t.Run("Keeps Handler Headers", func(t *testing.T) {
+		w := do(http.MethodGet, "/custom", nil)
+		assert.Equal(t, `"v1"`, w.Header().Get("ETag"))
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		w = do(http.MethodGet, "/custom", map[string]string{"If-None-Match": `W/"v1"`})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+	})
+
+	t.Run("Passes Through", func(t *testing.T) {
+		w := do(http.MethodGet, "/missing", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Empty(t, w.Header().Get("ETag"))
+		assert.JSONEq(t, `{"error":"not found"}`, w.Body.String())
+
+		w = do(http.MethodGet, "/stream", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "part-rest", w.Body.String())
+		assert.Empty(t, w.Header().Get("ETag"))
+
+		w = do(http.MethodGet, "/large", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, maxETagBody+1, w.Body.Len())
+		assert.Empty(t, w.Header().Get("ETag"))
+
+		w = do(http.MethodPost, "/items", nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get("ETag"))
+	})
+}
+
+func TestNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	etag := VersionETag("1", "2026-01-02T03:04:05Z")
+	assert.NotEqual(t, etag, VersionETag("1", "2026-01-02T03:04:06Z"))
+	assert.NotEqual(t, VersionETag("1", "2"), VersionETag("12"), "parts stay separate")
+
+	r := gin.New()
+	r.GET("/me", func(c *gin.Context) {
+		if NotModified(c, etag) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": 1})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/me", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+}
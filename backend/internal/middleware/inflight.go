@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"gosveltekit/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// drainPollInterval is how often Drain checks the in-flight count
+const drainPollInterval = 50 * time.Millisecond
+
+// ErrDrainTimeout is returned by Drain when requests are still in flight at
+// the deadline
+var ErrDrainTimeout = errors.New("requisições ainda em andamento no fim do prazo")
+
+// InFlight counts the requests being served, so shutdown can report and wait
+// for them. http.Server.Shutdown already waits for active connections; Drain
+// adds visibility into how many requests are left.
+type InFlight struct {
+	count atomic.Int64
+}
+
+// NewInFlight creates an InFlight counter
+func NewInFlight() *InFlight {
+	return &InFlight{}
+}
+
+// Middleware counts each request while its handlers run. Install it first, so
+// it covers every other middleware.
+func (f *InFlight) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f.count.Add(1)
+		defer f.count.Add(-1)
+		c.Next()
+	}
+}
+
+// Count returns the number of requests in flight
+func (f *InFlight) Count() int64 {
+	return f.count.Load()
+}
+
+// Drain waits until no request is in flight, logging the count whenever it
+// changes. It returns ErrDrainTimeout if ctx is done first.
+func (f *InFlight) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	last := int64(-1)
+	for {
+		n := f.Count()
+		if n == 0 {
+			if last > 0 {
+				logger.Info("Requisições em andamento concluídas")
+			}
+			return nil
+		}
+		if n != last {
+			logger.Info("Aguardando requisições em andamento", "in_flight", n)
+			last = n
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Warn("Prazo de desligamento esgotado com requisições em andamento", "in_flight", f.Count())
+			return ErrDrainTimeout
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInFlight_Drain(t *testing.T) {
+	inFlight := NewInFlight()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	r := gin.New()
+	r.Use(inFlight.Middleware())
+	r.GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusOK)
+	})
+	r.GET("/fast", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/fast")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int64(0), inFlight.Count(), "finished requests aren't counted")
+
+	responses := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(server.URL + "/slow")
+		if err != nil {
+			responses <- 0
+			return
+		}
+		resp.Body.Close()
+		responses <- resp.StatusCode
+	}()
+	<-started
+	assert.Equal(t, int64(1), inFlight.Count())
+
+	// The in-flight request outlives a short deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, inFlight.Drain(ctx), ErrDrainTimeout)
+
+	// Shutdown waits until it completes
+	drained := make(chan error, 1)
+	go func() {
+		drained <- inFlight.Drain(context.Background())
+	}()
+	select {
+	case <-drained:
+		t.Fatal("drain returned with a request in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-drained:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("drain didn't return after the request completed")
+	}
+	assert.Equal(t, http.StatusOK, <-responses)
+	assert.Equal(t, int64(0), inFlight.Count())
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gosveltekit/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceModeExcept answers 503 with code "maintenance" while enabled
+// reports true, except to admins and on the allowed routes (matched like
+// RequireAuthExcept). allowed should let admins log in and turn maintenance
+// off, and keep the health checks reachable.
+//
+// enabled is called on every request, so it must be cheap (e.g. a cached
+// setting). It expects AuthMiddleware to run first.
+func MaintenanceModeExcept(enabled func() bool, allowed PublicRoutes) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" || allowed.Contains(c.Request.Method, route) || c.GetString("role") == "admin" || !enabled() {
+			c.Next()
+			return
+		}
+		apierror.Abort(c, apierror.New(http.StatusServiceUnavailable, "sistema em manutenção, tente novamente mais tarde").WithCode("maintenance"))
+	}
+}
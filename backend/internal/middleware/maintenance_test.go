@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceModeExcept(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	enabled := true
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if role := c.GetHeader("X-Test-Role"); role != "" {
+			c.Set("role", role)
+		}
+		c.Next()
+	})
+	router.Use(MaintenanceModeExcept(func() bool { return enabled }, NewPublicRoutes("POST /auth/login")))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/api/items", ok)
+	router.POST("/auth/login", ok)
+
+	request := func(method, path, role string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if role != "" {
+			req.Header.Set("X-Test-Role", role)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, request(http.MethodGet, "/api/items", "user"))
+	assert.Equal(t, http.StatusServiceUnavailable, request(http.MethodGet, "/api/items", ""))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/api/items", "admin"))
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "/auth/login", ""))
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/missing", ""))
+
+	enabled = false
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/api/items", "user"))
+}
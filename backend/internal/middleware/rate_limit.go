@@ -3,16 +3,55 @@
 package middleware
 
 import (
-	"net/http"
+	"math"
+	"strconv"
 	"sync"
 	"time"
 
+	"gosveltekit/internal/apierror"
 	"gosveltekit/internal/logger"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
 )
 
+// Standard rate limit response headers
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+	RetryAfterHeader         = "Retry-After"
+)
+
+// RateLimitStatus is the state of a client's bucket after a request
+type RateLimitStatus struct {
+	// Limit is the bucket size (burst)
+	Limit int
+	// Remaining is the number of requests allowed right now
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when
+	// the request was allowed
+	RetryAfter time.Duration
+}
+
+// SetRateLimitHeaders reports status in the standard headers. Durations are
+// sent as whole seconds, rounded up so clients never retry too early;
+// Retry-After is only set for rejected requests.
+func SetRateLimitHeaders(c *gin.Context, status RateLimitStatus) {
+	c.Header(RateLimitLimitHeader, strconv.Itoa(status.Limit))
+	c.Header(RateLimitRemainingHeader, strconv.Itoa(status.Remaining))
+	c.Header(RateLimitResetHeader, strconv.FormatInt(ceilSeconds(status.Reset), 10))
+	if status.RetryAfter > 0 {
+		c.Header(RetryAfterHeader, strconv.FormatInt(ceilSeconds(status.RetryAfter), 10))
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
 type IPRateLimiter struct {
 	ips    map[string]*rate.Limiter
 	mu     *sync.RWMutex
@@ -54,17 +93,52 @@ func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
 	return limiter
 }
 
+// Allow takes a token from the bucket of ip and reports whether the request
+// is allowed, along with the bucket state afterwards
+func (i *IPRateLimiter) Allow(ip string) (bool, RateLimitStatus) {
+	return takeToken(i.GetLimiter(ip), time.Now())
+}
+
+// takeToken takes a token from l at now, reporting whether it was available
+// and the bucket state afterwards
+func takeToken(l *rate.Limiter, now time.Time) (bool, RateLimitStatus) {
+	allowed := l.AllowN(now, 1)
+	tokens := l.TokensAt(now)
+
+	status := RateLimitStatus{
+		Limit:     l.Burst(),
+		Remaining: max(int(math.Floor(tokens)), 0),
+	}
+	// Without a finite, positive refill rate the bucket never refills (or is
+	// never empty), so there is no reset time to report
+	if limit := l.Limit(); limit > 0 && limit != rate.Inf {
+		status.Reset = refillTime(float64(l.Burst())-tokens, limit)
+		if !allowed {
+			status.RetryAfter = refillTime(1-tokens, limit)
+		}
+	}
+	return allowed, status
+}
+
+// refillTime is how long the bucket takes to gain tokens at limit
+func refillTime(tokens float64, limit rate.Limit) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / float64(limit) * float64(time.Second))
+}
+
+// RateLimitMiddleware rejects requests over the client IP's limit with 429.
+// Every response carries the rate limit headers, see SetRateLimitHeaders.
 func RateLimitMiddleware(limiter *IPRateLimiter) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ip := c.ClientIP()
-		l := limiter.GetLimiter(ip)
-
-		if !l.Allow() {
-			logger.Warn("Rate limit excedido", "ip", ip, "path", c.Request.URL.Path)
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "limite de requisições excedido",
-			})
-			c.Abort()
+		allowed, status := limiter.Allow(ip)
+		SetRateLimitHeaders(c, status)
+
+		if !allowed {
+			logger.FromContext(c.Request.Context()).Warn("Rate limit excedido", "ip", ip, "path", c.Request.URL.Path)
+			apierror.Abort(c, apierror.RateLimited("limite de requisições excedido"))
 			return
 		}
 
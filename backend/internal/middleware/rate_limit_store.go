@@ -0,0 +1,268 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// APIKeyHeader carries the API key requests are rate limited by, see
+// RateLimitByAPIKey
+const APIKeyHeader = "X-API-Key"
+
+// RateLimitStore counts requests per key. Rejected requests don't count.
+// Implementations must be safe for concurrent use.
+type RateLimitStore interface {
+	// Take counts a request of key against requests per window, reporting
+	// whether it is allowed and the state of the key afterwards
+	Take(ctx context.Context, key string, requests int, window time.Duration) (bool, RateLimitStatus, error)
+}
+
+// RateLimitKeyFunc names who a request is counted against
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// RateLimitByIP counts requests per client IP
+func RateLimitByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// RateLimitByUser counts requests per authenticated user, and anonymous
+// requests per client IP
+func RateLimitByUser(c *gin.Context) string {
+	if userID := c.GetString("userID"); userID != "" {
+		return "user:" + userID
+	}
+	return RateLimitByIP(c)
+}
+
+// RateLimitByAPIKey counts requests per API key: the one AuthMiddleware
+// authenticated, or else the one in APIKeyHeader. Requests without one are
+// counted per client IP. Keys are hashed so stores never hold them.
+func RateLimitByAPIKey(c *gin.Context) string {
+	if key := APIKeyFromContext(c); key != nil {
+		return "api_key:" + key.ID
+	}
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "api_key:" + hex.EncodeToString(sum[:16])
+	}
+	return RateLimitByIP(c)
+}
+
+// RateLimitRule allows Requests per Window for each key
+type RateLimitRule struct {
+	Requests int
+	Window   time.Duration
+	Key      RateLimitKeyFunc // Default: RateLimitByIP
+	// Authenticated marks keys that identify the caller (RateLimitByUser,
+	// RateLimitByAPIKey), which only work after authentication
+	Authenticated bool
+}
+
+// RateLimiter limits requests with a RateLimitStore. Routes can override the
+// rule of their group, e.g. a stricter limit for logins.
+type RateLimiter struct {
+	store  RateLimitStore
+	routes map[string]RateLimitRule
+}
+
+// NewRateLimiter creates a RateLimiter. routes maps "METHOD /full/path"
+// (the route pattern, as in gin's FullPath) to the rule replacing the
+// group's for that route.
+func NewRateLimiter(store RateLimitStore, routes map[string]RateLimitRule) *RateLimiter {
+	return &RateLimiter{store: store, routes: routes}
+}
+
+// Limit rejects requests over rule with 429, counting them under name, or
+// over the route's own rule if it has one. Every response carries the rate
+// limit headers, see SetRateLimitHeaders. When the store fails the request
+// is let through: a store outage must not take the API down.
+func (l *RateLimiter) Limit(name string, rule RateLimitRule) gin.HandlerFunc {
+	return l.limit(name, rule, func(RateLimitRule) bool { return true })
+}
+
+// BeforeAuth is Limit to install ahead of authentication, so requests
+// rejected with 401 use up the limit too. Rules marked Authenticated are left
+// to AfterAuth.
+func (l *RateLimiter) BeforeAuth(name string, rule RateLimitRule) gin.HandlerFunc {
+	return l.limit(name, rule, func(r RateLimitRule) bool { return !r.Authenticated })
+}
+
+// AfterAuth is Limit to install after authentication, for the rules marked
+// Authenticated that BeforeAuth skipped
+func (l *RateLimiter) AfterAuth(name string, rule RateLimitRule) gin.HandlerFunc {
+	return l.limit(name, rule, func(r RateLimitRule) bool { return r.Authenticated })
+}
+
+// limit applies the request's rule, as in Limit, if applies reports true
+func (l *RateLimiter) limit(name string, rule RateLimitRule, applies func(RateLimitRule) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bucket, effective := name, rule
+		route := c.Request.Method + " " + c.FullPath()
+		if override, ok := l.routes[route]; ok {
+			bucket, effective = route, override
+		}
+		if !applies(effective) {
+			c.Next()
+			return
+		}
+		l.take(c, bucket, effective)
+	}
+}
+
+// Extra limits a route with rule on top of its group's limit, e.g. a
+// sensitive admin action. Route overrides don't apply to it.
+func (l *RateLimiter) Extra(name string, rule RateLimitRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l.take(c, name, rule)
+	}
+}
+
+func (l *RateLimiter) take(c *gin.Context, name string, rule RateLimitRule) {
+	keyFunc := rule.Key
+	if keyFunc == nil {
+		keyFunc = RateLimitByIP
+	}
+	key := keyFunc(c)
+
+	allowed, status, err := l.store.Take(c.Request.Context(), name+"|"+key, rule.Requests, rule.Window)
+	if err != nil {
+		logger.Warn("Falha ao consultar rate limit, requisição liberada", "error", err, "limit", name)
+		c.Next()
+		return
+	}
+	SetRateLimitHeaders(c, status)
+
+	if !allowed {
+		logger.Warn("Rate limit excedido", "key", key, "limit", name, "path", c.Request.URL.Path)
+		apierror.Abort(c, apierror.RateLimited("limite de requisições excedido"))
+		return
+	}
+
+	c.Next()
+}
+
+// MemoryRateLimitStore keeps a token bucket per key in the process: each key
+// holds up to requests tokens, refilled evenly over window. Limits are per
+// instance.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	limiter  *rate.Limiter
+	window   time.Duration
+	lastSeen time.Time
+}
+
+// NewMemoryRateLimitStore creates a MemoryRateLimitStore
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*memoryBucket)}
+}
+
+// Take implements RateLimitStore
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, requests int, window time.Duration) (bool, RateLimitStatus, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{limiter: rate.NewLimiter(rate.Limit(float64(requests)/window.Seconds()), requests), window: window}
+		s.buckets[key] = bucket
+	}
+	bucket.lastSeen = now
+	s.sweep(now)
+	s.mu.Unlock()
+
+	allowed, status := takeToken(bucket.limiter, now)
+	return allowed, status, nil
+}
+
+// sweep drops the buckets unused for their window, which are full again
+// anyway. Runs at most once a minute.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.lastSeen) > bucket.window {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// RedisRateLimitStore counts requests in Redis with a sliding window, so
+// limits hold across instances. The count of the previous window is weighed
+// by how much of it still overlaps the sliding one.
+type RedisRateLimitStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisRateLimitStore creates a RedisRateLimitStore, naming its keys
+// prefix + key
+func NewRedisRateLimitStore(client *redis.Client, prefix string) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client, prefix: prefix}
+}
+
+// Take implements RateLimitStore
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, requests int, window time.Duration) (bool, RateLimitStatus, error) {
+	windowMs := window.Milliseconds()
+	if windowMs <= 0 {
+		windowMs = 1
+	}
+	nowMs := time.Now().UnixMilli()
+	index := nowMs / windowMs
+	current := s.prefix + key + ":" + strconv.FormatInt(index, 10)
+	previous := s.prefix + key + ":" + strconv.FormatInt(index-1, 10)
+
+	pipe := s.client.Pipeline()
+	incr := pipe.Incr(ctx, current)
+	pipe.PExpire(ctx, current, 2*time.Duration(windowMs)*time.Millisecond)
+	get := pipe.Get(ctx, previous)
+	// A missing previous window is a nil reply, not a failure
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return false, RateLimitStatus{}, err
+	}
+	count := incr.Val()
+	previousCount, err := get.Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return false, RateLimitStatus{}, err
+	}
+
+	elapsed := nowMs - index*windowMs
+	overlap := 1 - float64(elapsed)/float64(windowMs)
+	used := float64(previousCount)*overlap + float64(count)
+	allowed := used <= float64(requests)
+
+	untilNextWindow := time.Duration(windowMs-elapsed) * time.Millisecond
+	status := RateLimitStatus{
+		Limit:     requests,
+		Remaining: max(int(math.Floor(float64(requests)-used)), 0),
+		Reset:     untilNextWindow,
+	}
+	if !allowed {
+		// Approximate: by the next window at the latest this one's count
+		// starts fading out
+		status.RetryAfter = untilNextWindow
+		if err := s.client.Decr(ctx, current).Err(); err != nil {
+			logger.Warn("Falha ao descontar requisição rejeitada do rate limit", "error", err)
+		}
+	}
+	return allowed, status, nil
+}
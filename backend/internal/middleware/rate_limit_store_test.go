@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/auth"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingRateLimitStore simulates a store outage
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(ctx context.Context, key string, requests int, window time.Duration) (bool, RateLimitStatus, error) {
+	return false, RateLimitStatus{}, errors.New("store down")
+}
+
+func TestRateLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(store RateLimitStore, routes map[string]RateLimitRule) *gin.Engine {
+		limiter := NewRateLimiter(store, routes)
+		r := gin.New()
+		group := r.Group("/auth", limiter.Limit("auth", RateLimitRule{Requests: 2, Window: time.Minute}))
+		group.POST("/login", func(c *gin.Context) { c.Status(http.StatusOK) })
+		group.POST("/register", func(c *gin.Context) { c.Status(http.StatusOK) })
+		group.POST("/sensitive", limiter.Extra("sensitive", RateLimitRule{Requests: 1, Window: time.Minute}), func(c *gin.Context) { c.Status(http.StatusOK) })
+		return r
+	}
+	do := func(r *gin.Engine, path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = "192.168.1.10:1234"
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Before And After Auth", func(t *testing.T) {
+		limiter := NewRateLimiter(NewMemoryRateLimitStore(), nil)
+		r := gin.New()
+		caller := RateLimitRule{Requests: 1, Window: time.Minute, Key: RateLimitByUser, Authenticated: true}
+		r.GET("/ip", limiter.BeforeAuth("ip", RateLimitRule{Requests: 1, Window: time.Minute}), limiter.AfterAuth("ip", RateLimitRule{Requests: 1, Window: time.Minute}), func(c *gin.Context) { c.Status(http.StatusOK) })
+		r.GET("/user", limiter.BeforeAuth("user", caller), func(c *gin.Context) { c.Set("userID", "1") }, limiter.AfterAuth("user", caller), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		// Counted once, by the middleware matching the rule
+		for _, path := range []string{"/ip", "/user"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code, path)
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusTooManyRequests, w.Code, path)
+		}
+	})
+
+	t.Run("Group Rule Applies", func(t *testing.T) {
+		r := newRouter(NewMemoryRateLimitStore(), nil)
+
+		assert.Equal(t, http.StatusOK, do(r, "/auth/register", nil).Code)
+		w := do(r, "/auth/login", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+		w = do(r, "/auth/register", nil)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	})
+
+	t.Run("Route Override Replaces Group Rule", func(t *testing.T) {
+		r := newRouter(NewMemoryRateLimitStore(), map[string]RateLimitRule{
+			"POST /auth/login": {Requests: 1, Window: time.Minute},
+		})
+
+		assert.Equal(t, http.StatusOK, do(r, "/auth/login", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, do(r, "/auth/login", nil).Code)
+
+		// The override is counted apart from the group
+		assert.Equal(t, http.StatusOK, do(r, "/auth/register", nil).Code)
+		assert.Equal(t, http.StatusOK, do(r, "/auth/register", nil).Code)
+	})
+
+	t.Run("Extra Limits On Top Of Group", func(t *testing.T) {
+		r := newRouter(NewMemoryRateLimitStore(), map[string]RateLimitRule{
+			"POST /auth/sensitive": {Requests: 5, Window: time.Minute},
+		})
+
+		assert.Equal(t, http.StatusOK, do(r, "/auth/sensitive", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, do(r, "/auth/sensitive", nil).Code)
+	})
+
+	t.Run("Keys Separate Clients", func(t *testing.T) {
+		store := NewMemoryRateLimitStore()
+		limiter := NewRateLimiter(store, nil)
+		r := gin.New()
+		r.POST("/keyed", limiter.Limit("keyed", RateLimitRule{Requests: 1, Window: time.Minute, Key: RateLimitByAPIKey}), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		assert.Equal(t, http.StatusOK, do(r, "/keyed", map[string]string{APIKeyHeader: "key-a"}).Code)
+		assert.Equal(t, http.StatusTooManyRequests, do(r, "/keyed", map[string]string{APIKeyHeader: "key-a"}).Code)
+		assert.Equal(t, http.StatusOK, do(r, "/keyed", map[string]string{APIKeyHeader: "key-b"}).Code)
+		// Without a key the IP is used
+		assert.Equal(t, http.StatusOK, do(r, "/keyed", nil).Code)
+	})
+
+	t.Run("Store Failure Lets Requests Through", func(t *testing.T) {
+		r := newRouter(failingRateLimitStore{}, nil)
+
+		for range 5 {
+			assert.Equal(t, http.StatusOK, do(r, "/auth/login", nil).Code)
+		}
+	})
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.RemoteAddr = "10.0.0.1:1234"
+
+	assert.Equal(t, "ip:10.0.0.1", RateLimitByIP(c))
+	assert.Equal(t, "ip:10.0.0.1", RateLimitByUser(c))
+	assert.Equal(t, "ip:10.0.0.1", RateLimitByAPIKey(c))
+
+	c.Set("userID", "42")
+	assert.Equal(t, "user:42", RateLimitByUser(c))
+
+	c.Request.Header.Set(APIKeyHeader, "secret-key")
+	key := RateLimitByAPIKey(c)
+	assert.Regexp(t, "^api_key:[0-9a-f]{32}$", key)
+	assert.NotContains(t, key, "secret-key")
+
+	c.Set("apiKey", &auth.APIKey{ID: "0123456789abcdef"})
+	assert.Equal(t, "api_key:0123456789abcdef", RateLimitByAPIKey(c))
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	ctx := context.Background()
+
+	for i := range 3 {
+		allowed, status, err := store.Take(ctx, "k", 3, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		assert.Equal(t, 2-i, status.Remaining)
+	}
+	allowed, status, err := store.Take(ctx, "k", 3, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Positive(t, status.RetryAfter)
+
+	// Other keys have their own bucket
+	allowed, _, err = store.Take(ctx, "other", 3, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func TestRedisRateLimitStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	store := NewRedisRateLimitStore(client, "ratelimit:")
+	ctx := context.Background()
+
+	t.Run("Limits Within Window", func(t *testing.T) {
+		for range 3 {
+			allowed, status, err := store.Take(ctx, "k", 3, time.Minute)
+			require.NoError(t, err)
+			assert.True(t, allowed)
+			assert.Equal(t, 3, status.Limit)
+		}
+		allowed, status, err := store.Take(ctx, "k", 3, time.Minute)
+		require.NoError(t, err)
+		assert.False(t, allowed)
+		assert.Zero(t, status.Remaining)
+		assert.Positive(t, status.RetryAfter)
+
+		// Rejected requests aren't counted, whichever windows the requests fell in
+		var count int64
+		for _, key := range server.Keys() {
+			n, err := client.Get(ctx, key).Int64()
+			require.NoError(t, err)
+			count += n
+		}
+		assert.Equal(t, int64(3), count)
+	})
+
+	t.Run("Window Slides", func(t *testing.T) {
+		window := 200 * time.Millisecond
+		for range 2 {
+			allowed, _, err := store.Take(ctx, "slide", 2, window)
+			require.NoError(t, err)
+			assert.True(t, allowed)
+		}
+
+		// Two windows later the old counts no longer weigh
+		time.Sleep(2 * window)
+		allowed, _, err := store.Take(ctx, "slide", 2, window)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	})
+
+	t.Run("Unreachable Server", func(t *testing.T) {
+		down := NewRedisRateLimitStore(redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond, MaxRetries: -1}), "ratelimit:")
+		_, _, err := down.Take(ctx, "k", 3, time.Minute)
+		assert.Error(t, err)
+	})
+}
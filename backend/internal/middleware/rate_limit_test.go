@@ -5,6 +5,7 @@ package middleware
 import (
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -282,3 +283,45 @@ func TestRateLimitMiddleware(t *testing.T) {
 		assert.Equal(t, http.StatusOK, code3)
 	})
 }
+
+func TestRateLimitMiddleware_Headers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 2 requests of burst, one token every 10 seconds
+	ipLimiter := NewIPRateLimiter(rate.Every(10*time.Second), 2, time.Minute)
+	r := gin.New()
+	r.Use(RateLimitMiddleware(ipLimiter))
+	r.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	makeRequest := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("X-Forwarded-For", "192.168.1.40")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	header := func(w *httptest.ResponseRecorder, name string) int {
+		value, err := strconv.Atoi(w.Header().Get(name))
+		assert.NoError(t, err, name)
+		return value
+	}
+
+	w := makeRequest()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, header(w, RateLimitLimitHeader))
+	assert.Equal(t, 1, header(w, RateLimitRemainingHeader))
+	assert.InDelta(t, 10, header(w, RateLimitResetHeader), 1, "one token to refill")
+	assert.Empty(t, w.Header().Get(RetryAfterHeader))
+
+	makeRequest()
+	w = makeRequest()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, 2, header(w, RateLimitLimitHeader))
+	assert.Equal(t, 0, header(w, RateLimitRemainingHeader))
+	assert.InDelta(t, 20, header(w, RateLimitResetHeader), 1, "two tokens to refill")
+	retryAfter := header(w, RetryAfterHeader)
+	assert.InDelta(t, 10, retryAfter, 1, "one token to refill")
+	assert.LessOrEqual(t, retryAfter, header(w, RateLimitResetHeader))
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"gosveltekit/internal/auth"
+)
+
+// StaleDataHeader is set to "true" on responses authenticated with a cached
+// session because the database was unavailable, see WithReadFallback
+const StaleDataHeader = "X-Stale-Data"
+
+// WithReadFallback makes AuthMiddleware remember every session it validates
+// for ttl. When validating a GET or HEAD request fails with a storage error
+// (not an unknown or expired session), the remembered session and user are
+// used instead and the response carries StaleDataHeader. Other methods
+// always fail, so writes never run without the database. A ttl of 0
+// disables the fallback.
+//
+// A session revoked while the database is down stays usable for reads until
+// its cached copy expires, so keep ttl short.
+func WithReadFallback(ttl time.Duration) AuthOption {
+	return func(o *authOptions) {
+		o.readFallback = nil
+		if ttl > 0 {
+			o.readFallback = &sessionCache{ttl: ttl, entries: make(map[string]cachedSession)}
+		}
+	}
+}
+
+type cachedSession struct {
+	session  auth.Session
+	user     *auth.UserData
+	storedAt time.Time
+}
+
+// sessionCache holds the last validated copy of each session
+type sessionCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]cachedSession
+	lastSweep time.Time
+}
+
+func (s *sessionCache) store(session *auth.Session, user *auth.UserData) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired copies once per ttl so the map doesn't grow forever
+	if now.Sub(s.lastSweep) > s.ttl {
+		for id, entry := range s.entries {
+			if now.Sub(entry.storedAt) > s.ttl {
+				delete(s.entries, id)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.entries[session.ID] = cachedSession{session: *session, user: user, storedAt: now}
+}
+
+// lookup returns the cached copy of sessionID if it is younger than ttl
+func (s *sessionCache) lookup(sessionID string) (*auth.Session, *auth.UserData, bool) {
+	s.mu.Lock()
+	entry, ok := s.entries[sessionID]
+	s.mu.Unlock()
+	if !ok || time.Since(entry.storedAt) > s.ttl {
+		return nil, nil, false
+	}
+	session, user := entry.session, *entry.user
+	session.Fresh = false // nothing was refreshed, don't reissue the cookie
+	return &session, &user, true
+}
+
+// readOnly reports whether the request can be served from cached data
+func readOnly(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
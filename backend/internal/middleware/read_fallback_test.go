@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthMiddleware_ReadFallback(t *testing.T) {
+	authManager, db := createTestAuthManager()
+	require.NoError(t, db.Create(&models.User{Username: "testuser", Email: "test@example.com", PasswordHash: "hash", Active: true, Role: "user"}).Error)
+	for _, id := range []string{"cached-session", "uncached-session"} {
+		require.NoError(t, db.Create(&models.Session{ID: id, UserID: 1, ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now()}).Error)
+	}
+
+	r := gin.New()
+	r.Use(AuthMiddleware(authManager, WithReadFallback(time.Minute)))
+	r.GET("/me", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetString("userID")})
+	})
+	r.POST("/me", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	serve := func(method, sessionID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/me", nil)
+		req.Header.Set(SessionHeaderName, sessionID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodGet, "cached-session")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(StaleDataHeader))
+
+	// The database goes away
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	w = serve(http.MethodGet, "cached-session")
+	assert.Equal(t, http.StatusOK, w.Code, "reads are served from the cached session")
+	assert.Equal(t, "true", w.Header().Get(StaleDataHeader))
+	assert.JSONEq(t, `{"user_id":"1"}`, w.Body.String())
+
+	w = serve(http.MethodPost, "cached-session")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "writes require the database")
+
+	w = serve(http.MethodGet, "uncached-session")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "only validated sessions are cached")
+}
@@ -0,0 +1,49 @@
+// backend/internal/middleware/recovery.go
+
+package middleware
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecoveryMiddleware recovers from panics and answers with a JSON 500.
+//
+// The panic value and stack trace are always logged, but only included in the
+// response when exposeDetails is true, which should never be the case in
+// production.
+func RecoveryMiddleware(exposeDetails bool) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered any) {
+		stack := string(debug.Stack())
+		logger.FromContext(c.Request.Context()).Error("Panic ao processar requisição", "error", recovered, "path", c.Request.URL.Path, "stack", stack)
+
+		problem := apierror.Internal("erro interno do servidor")
+		if exposeDetails {
+			problem.With("details", fmt.Sprint(recovered)).With("stack", stack)
+		}
+		apierror.Abort(c, problem)
+	})
+}
+
+// errorDetailsKey marks, in the Gin context, the requests set by ErrorDetails
+const errorDetailsKey = "errorDetails"
+
+// ErrorDetails lets the handlers of the requests it handles include internal
+// error details in their 500 responses, as RecoveryMiddleware does with
+// exposeDetails. Never install it in production.
+func ErrorDetails() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(errorDetailsKey, true)
+		c.Next()
+	}
+}
+
+// ErrorDetailsAllowed reports whether ErrorDetails handled the request
+func ErrorDetailsAllowed(c *gin.Context) bool {
+	return c.GetBool(errorDetailsKey)
+}
@@ -0,0 +1,55 @@
+// Package middleware tests
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gosveltekit/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		// Available both in the Gin context and the request context
+		assert.Equal(t, c.GetString("requestID"), logger.RequestIDFromContext(c.Request.Context()))
+		c.String(http.StatusOK, c.GetString("requestID"))
+	})
+
+	tests := []struct {
+		name     string
+		header   string
+		wantSame bool
+	}{
+		{name: "generated when missing"},
+		{name: "client ID kept", header: "proxy-abc.123_x", wantSame: true},
+		{name: "unsafe client ID replaced", header: "bad id\nInjected: yes"},
+		{name: "over-long client ID replaced", header: strings.Repeat("a", 65)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			assert.NotEmpty(t, id)
+			assert.Equal(t, id, w.Body.String())
+			if tt.wantSame {
+				assert.Equal(t, tt.header, id)
+			} else {
+				assert.NotEqual(t, tt.header, id)
+				assert.Len(t, id, 32)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"gosveltekit/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxURLLength answers 414 to requests whose path plus query string is longer
+// than limit bytes, before any handler parses them. Header size is bounded
+// separately by http.Server.MaxHeaderBytes, which answers 431.
+func MaxURLLength(limit int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(c.Request.URL.RequestURI()) > limit {
+			apierror.Abort(c, apierror.New(http.StatusRequestURITooLong, "URL muito longa"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireJSON answers 415 to requests whose body isn't declared as JSON
+// (application/json or a "+json" type such as application/merge-patch+json),
+// instead of leaving it to the lenient binding of each handler. Requests
+// without a body pass, so GET, DELETE or a bodyless POST such as logout are
+// not affected.
+func RequireJSON() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength == 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if !isJSONContentType(c.GetHeader("Content-Type")) {
+			apierror.Abort(c, apierror.New(http.StatusUnsupportedMediaType, "Content-Type deve ser application/json"))
+			return
+		}
+		c.Next()
+	}
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || (strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireJSON(t *testing.T) {
+	r := gin.New()
+	r.Use(RequireJSON())
+	r.POST("/test", func(c *gin.Context) {
+		var body struct {
+			Name string `json:"name"`
+		}
+		_ = c.ShouldBindJSON(&body)
+		c.String(http.StatusOK, body.Name)
+	})
+	r.DELETE("/test", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{name: "JSON", method: http.MethodPost, contentType: "application/json", body: `{"name":"alice"}`, wantStatus: http.StatusOK},
+		{name: "JSON with charset", method: http.MethodPost, contentType: "application/json; charset=utf-8", body: `{"name":"alice"}`, wantStatus: http.StatusOK},
+		{name: "JSON suffix", method: http.MethodPost, contentType: "application/merge-patch+json", body: `{"name":"alice"}`, wantStatus: http.StatusOK},
+		{name: "text/plain", method: http.MethodPost, contentType: "text/plain", body: `{"name":"alice"}`, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "form", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "name=alice", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "missing Content-Type", method: http.MethodPost, body: `{"name":"alice"}`, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "empty body", method: http.MethodPost, wantStatus: http.StatusOK},
+		{name: "DELETE without body", method: http.MethodDelete, wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/test", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK && tt.body != "" {
+				assert.Equal(t, "alice", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gosveltekit/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// CacheHeader tells whether a cached route was answered from the cache
+// ("HIT") or by its handler ("MISS")
+const CacheHeader = "X-Cache"
+
+// cachedHeaders are the response headers stored with a cached body
+var cachedHeaders = []string{"Content-Type", "Cache-Control", "ETag", "Last-Modified"}
+
+// CachedResponse is a response stored by ResponseCache
+type CachedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// ResponseCacheStore keeps the responses of ResponseCache. Entries belong to
+// a scope, the user they were answered to; Invalidate bumps the version of a
+// scope, which is part of the keys, so the previous entries are never read
+// again and expire on their own.
+type ResponseCacheStore interface {
+	// Get returns the entry stored under key, or nil without one
+	Get(ctx context.Context, key string) (*CachedResponse, error)
+	Set(ctx context.Context, key string, response *CachedResponse, ttl time.Duration) error
+	Version(ctx context.Context, scope string) (int64, error)
+	Invalidate(ctx context.Context, scope string) error
+}
+
+// ResponseCache answers the GET routes in ttls, keyed "GET /full/path" as in
+// gin's FullPath, from store for their TTL. Entries are kept per credential
+// (session or API key, hashed) and never shared between users, so a cache
+// hit returns what that same credential was already allowed to see.
+// InvalidateResponseCache drops the entries of a user after their writes;
+// changes made by others show up when the TTL ends, so only list routes
+// that may be that stale. Responses marked Cache-Control: no-store are not
+// stored.
+//
+// A hit ends the request, so ResponseCache goes last in the chain of the
+// routes, after the rate limit and their own checks (permissions,
+// impersonation). When the store fails the request is handled normally: the
+// cache must not take the API down.
+func ResponseCache(store ResponseCacheStore, ttls map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ttl, ok := ttls[http.MethodGet+" "+c.FullPath()]
+		if c.Request.Method != http.MethodGet || !ok {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		scope := cacheScope(c)
+
+		version, err := store.Version(ctx, scope.user)
+		if err != nil {
+			logger.Warn("Falha ao consultar cache de respostas, requisição liberada", "error", err)
+			c.Next()
+			return
+		}
+		key := scope.user + "|" + strconv.FormatInt(version, 10) + "|" + scope.credential + "|" + c.Request.URL.RequestURI()
+
+		cached, err := store.Get(ctx, key)
+		if err != nil {
+			logger.Warn("Falha ao consultar cache de respostas, requisição liberada", "error", err)
+		}
+		if cached != nil {
+			for name, values := range cached.Header {
+				c.Writer.Header()[name] = values
+			}
+			c.Header(CacheHeader, "HIT")
+			c.Data(cached.Status, cached.Header.Get("Content-Type"), cached.Body)
+			c.Abort()
+			return
+		}
+
+		w := &cacheWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Header(CacheHeader, "MISS")
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.Status() != http.StatusOK || w.overflow || strings.Contains(w.Header().Get("Cache-Control"), "no-store") {
+			return
+		}
+		response := &CachedResponse{Status: http.StatusOK, Header: http.Header{}, Body: w.body.Bytes()}
+		for _, name := range cachedHeaders {
+			if value := w.Header().Get(name); value != "" {
+				response.Header.Set(name, value)
+			}
+		}
+		if err := store.Set(ctx, key, response, ttl); err != nil {
+			logger.Warn("Falha ao guardar resposta em cache", "error", err)
+		}
+	}
+}
+
+// InvalidateResponseCache makes every successful write (any method but GET,
+// HEAD and OPTIONS) by a user invalidate the ResponseCache entries of all
+// their sessions. It never ends a request, so it can run from anywhere in
+// the chain.
+func InvalidateResponseCache(store ResponseCacheStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		if userID := c.GetString("userID"); userID != "" && c.Writer.Status() < http.StatusBadRequest {
+			if err := store.Invalidate(c.Request.Context(), "user:"+userID); err != nil {
+				logger.Warn("Falha ao invalidar cache de respostas", "error", err, "user_id", userID)
+			}
+		}
+	}
+}
+
+type responseCacheScope struct {
+	user       string // scope of Invalidate
+	credential string
+}
+
+// cacheScope names who the response is for: the user and the credential of
+// the request, or the tenant for anonymous requests
+func cacheScope(c *gin.Context) responseCacheScope {
+	userID := c.GetString("userID")
+	if userID == "" {
+		return responseCacheScope{user: "anonymous:" + c.GetString("tenantID")}
+	}
+	scope := responseCacheScope{user: "user:" + userID}
+	if key := APIKeyFromContext(c); key != nil {
+		scope.credential = "api_key:" + key.ID
+	} else if c.GetString("sessionID") != "" {
+		sum := sha256.Sum256([]byte(c.GetString("sessionID")))
+		scope.credential = "session:" + hex.EncodeToString(sum[:16])
+	}
+	return scope
+}
+
+// maxCachedBody is the largest response ResponseCache stores
+const maxCachedBody = 1 << 20
+
+// cacheWriter copies a response while it is written, up to maxCachedBody
+type cacheWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *cacheWriter) Write(data []byte) (int, error) {
+	w.copy(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *cacheWriter) WriteString(s string) (int, error) {
+	w.copy([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *cacheWriter) copy(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.body.Len()+len(data) > maxCachedBody {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
+
+// MemoryResponseCache keeps responses in the process, so each instance has
+// its own cache. Past maxEntries, expired entries are dropped, then any.
+type MemoryResponseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]memoryCacheEntry
+	versions   map[string]int64
+}
+
+type memoryCacheEntry struct {
+	response  *CachedResponse
+	expiresAt time.Time
+}
+
+// NewMemoryResponseCache creates a MemoryResponseCache holding up to
+// maxEntries responses (1000 when zero)
+func NewMemoryResponseCache(maxEntries int) *MemoryResponseCache {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &MemoryResponseCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]memoryCacheEntry),
+		versions:   make(map[string]int64),
+	}
+}
+
+// Get implements ResponseCacheStore
+func (s *MemoryResponseCache) Get(ctx context.Context, key string) (*CachedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(s.entries, key)
+		return nil, nil
+	}
+	return entry.response, nil
+}
+
+// Set implements ResponseCacheStore
+func (s *MemoryResponseCache) Set(ctx context.Context, key string, response *CachedResponse, ttl time.Duration) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[key]; !ok && len(s.entries) >= s.maxEntries {
+		for k, entry := range s.entries {
+			if now.After(entry.expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+		for k := range s.entries {
+			if len(s.entries) < s.maxEntries {
+				break
+			}
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = memoryCacheEntry{response: response, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// Version implements ResponseCacheStore
+func (s *MemoryResponseCache) Version(ctx context.Context, scope string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.versions[scope], nil
+}
+
+// Invalidate implements ResponseCacheStore
+func (s *MemoryResponseCache) Invalidate(ctx context.Context, scope string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[scope]++
+	return nil
+}
+
+// RedisResponseCache keeps responses in Redis, shared by every instance
+type RedisResponseCache struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisResponseCache creates a RedisResponseCache, naming its keys
+// prefix + key
+func NewRedisResponseCache(client *redis.Client, prefix string) *RedisResponseCache {
+	return &RedisResponseCache{client: client, prefix: prefix}
+}
+
+// Get implements ResponseCacheStore
+func (s *RedisResponseCache) Get(ctx context.Context, key string) (*CachedResponse, error) {
+	value, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var response CachedResponse
+	if err := json.Unmarshal(value, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Set implements ResponseCacheStore
+func (s *RedisResponseCache) Set(ctx context.Context, key string, response *CachedResponse, ttl time.Duration) error {
+	value, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+key, value, max(ttl, time.Millisecond)).Err()
+}
+
+// Version implements ResponseCacheStore
+func (s *RedisResponseCache) Version(ctx context.Context, scope string) (int64, error) {
+	version, err := s.client.Get(ctx, s.prefix+"version:"+scope).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// Invalidate implements ResponseCacheStore
+func (s *RedisResponseCache) Invalidate(ctx context.Context, scope string) error {
+	return s.client.Incr(ctx, s.prefix+"version:"+scope).Err()
+}
@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingResponseCache simulates a store outage
+type failingResponseCache struct{}
+
+func (failingResponseCache) Get(ctx context.Context, key string) (*CachedResponse, error) {
+	return nil, errors.New("store down")
+}
+
+func (failingResponseCache) Set(ctx context.Context, key string, response *CachedResponse, ttl time.Duration) error {
+	return errors.New("store down")
+}
+
+func (failingResponseCache) Version(ctx context.Context, scope string) (int64, error) {
+	return 0, errors.New("store down")
+}
+
+func (failingResponseCache) Invalidate(ctx context.Context, scope string) error {
+	return errors.New("store down")
+}
+
+func TestResponseCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(store ResponseCacheStore) (*gin.Engine, *int) {
+		calls := 0
+		r := gin.New()
+		// Stands in for the authentication middleware
+		r.Use(func(c *gin.Context) {
+			if user := c.GetHeader("X-User"); user != "" {
+				c.Set("userID", user)
+				c.Set("sessionID", c.GetHeader("X-Session"))
+			}
+		}, InvalidateResponseCache(store), ResponseCache(store, map[string]time.Duration{
+			"GET /api/me":        time.Minute,
+			"GET /api/private":   time.Minute,
+			"GET /api/broken":    time.Minute,
+			"GET /api/short/:id": 50 * time.Millisecond,
+		}))
+		r.GET("/api/me", func(c *gin.Context) {
+			calls++
+			c.JSON(http.StatusOK, gin.H{"user": c.GetString("userID"), "calls": calls})
+		})
+		r.GET("/api/private", func(c *gin.Context) {
+			calls++
+			c.Header("Cache-Control", "no-store")
+			c.JSON(http.StatusOK, gin.H{"calls": calls})
+		})
+		r.GET("/api/broken", func(c *gin.Context) {
+			calls++
+			c.JSON(http.StatusInternalServerError, gin.H{"calls": calls})
+		})
+		r.GET("/api/short/:id", func(c *gin.Context) {
+			calls++
+			c.JSON(http.StatusOK, gin.H{"calls": calls})
+		})
+		r.GET("/api/uncached", func(c *gin.Context) {
+			calls++
+			c.JSON(http.StatusOK, gin.H{"calls": calls})
+		})
+		r.PUT("/api/me", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+		r.POST("/api/fail", func(c *gin.Context) { c.Status(http.StatusBadRequest) })
+		return r, &calls
+	}
+	do := func(r *gin.Engine, method, path, user, session string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if user != "" {
+			req.Header.Set("X-User", user)
+			req.Header.Set("X-Session", session)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Hit After Miss", func(t *testing.T) {
+		r, calls := newRouter(NewMemoryResponseCache(0))
+
+		first := do(r, http.MethodGet, "/api/me", "1", "s1")
+		require.Equal(t, http.StatusOK, first.Code)
+		assert.Equal(t, "MISS", first.Header().Get(CacheHeader))
+
+		second := do(r, http.MethodGet, "/api/me", "1", "s1")
+		require.Equal(t, http.StatusOK, second.Code)
+		assert.Equal(t, "HIT", second.Header().Get(CacheHeader))
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, first.Header().Get("Content-Type"), second.Header().Get("Content-Type"))
+		assert.Equal(t, 1, *calls)
+
+		// The query string is part of the key
+		assert.Equal(t, "MISS", do(r, http.MethodGet, "/api/me?x=1", "1", "s1").Header().Get(CacheHeader))
+	})
+
+	t.Run("Separate Per Credential", func(t *testing.T) {
+		r, calls := newRouter(NewMemoryResponseCache(0))
+
+		do(r, http.MethodGet, "/api/me", "1", "s1")
+		other := do(r, http.MethodGet, "/api/me", "2", "s2")
+		assert.Equal(t, "MISS", other.Header().Get(CacheHeader))
+		assert.JSONEq(t, `{"user":"2","calls":2}`, other.Body.String())
+
+		// Another session of the same user doesn't share entries either
+		assert.Equal(t, "MISS", do(r, http.MethodGet, "/api/me", "1", "s3").Header().Get(CacheHeader))
+		assert.Equal(t, "MISS", do(r, http.MethodGet, "/api/me", "", "").Header().Get(CacheHeader))
+		assert.Equal(t, "HIT", do(r, http.MethodGet, "/api/me", "", "").Header().Get(CacheHeader))
+		assert.Equal(t, 4, *calls)
+	})
+
+	t.Run("Writes Invalidate", func(t *testing.T) {
+		r, calls := newRouter(NewMemoryResponseCache(0))
+
+		do(r, http.MethodGet, "/api/me", "1", "s1")
+		do(r, http.MethodGet, "/api/me", "1", "s2")
+		do(r, http.MethodGet, "/api/me", "2", "s3")
+
+		// A failed write changes nothing
+		do(r, http.MethodPost, "/api/fail", "1", "s1")
+		assert.Equal(t, "HIT", do(r, http.MethodGet, "/api/me", "1", "s1").Header().Get(CacheHeader))
+
+		require.Equal(t, http.StatusNoContent, do(r, http.MethodPut, "/api/me", "1", "s1").Code)
+		assert.Equal(t, "MISS", do(r, http.MethodGet, "/api/me", "1", "s1").Header().Get(CacheHeader))
+		assert.Equal(t, "MISS", do(r, http.MethodGet, "/api/me", "1", "s2").Header().Get(CacheHeader), "all sessions of the user")
+		assert.Equal(t, "HIT", do(r, http.MethodGet, "/api/me", "2", "s3").Header().Get(CacheHeader))
+		assert.Equal(t, 5, *calls)
+	})
+
+	t.Run("Not Stored", func(t *testing.T) {
+		r, calls := newRouter(NewMemoryResponseCache(0))
+
+		for _, path := range []string{"/api/private", "/api/broken", "/api/uncached"} {
+			do(r, http.MethodGet, path, "1", "s1")
+			w := do(r, http.MethodGet, path, "1", "s1")
+			assert.NotEqual(t, "HIT", w.Header().Get(CacheHeader), path)
+		}
+		assert.Empty(t, do(r, http.MethodGet, "/api/uncached", "1", "s1").Header().Get(CacheHeader))
+		assert.Equal(t, 7, *calls)
+	})
+
+	t.Run("Expires", func(t *testing.T) {
+		r, _ := newRouter(NewMemoryResponseCache(0))
+
+		do(r, http.MethodGet, "/api/short/1", "1", "s1")
+		assert.Equal(t, "HIT", do(r, http.MethodGet, "/api/short/1", "1", "s1").Header().Get(CacheHeader))
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, "MISS", do(r, http.MethodGet, "/api/short/1", "1", "s1").Header().Get(CacheHeader))
+	})
+
+	t.Run("Store Down", func(t *testing.T) {
+		r, calls := newRouter(failingResponseCache{})
+
+		for range 2 {
+			w := do(r, http.MethodGet, "/api/me", "1", "s1")
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+		assert.Equal(t, http.StatusNoContent, do(r, http.MethodPut, "/api/me", "1", "s1").Code)
+		assert.Equal(t, 2, *calls)
+	})
+}
+
+func TestMemoryResponseCache_MaxEntries(t *testing.T) {
+	store := NewMemoryResponseCache(3)
+	ctx := context.Background()
+
+	for i := range 5 {
+		require.NoError(t, store.Set(ctx, strconv.Itoa(i), &CachedResponse{Status: http.StatusOK}, time.Minute))
+	}
+	assert.Len(t, store.entries, 3)
+
+	cached, err := store.Get(ctx, "4")
+	require.NoError(t, err)
+	assert.NotNil(t, cached, "the last entry is kept")
+}
+
+func TestRedisResponseCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	store := NewRedisResponseCache(client, "httpcache:")
+	ctx := context.Background()
+
+	t.Run("Get And Set", func(t *testing.T) {
+		cached, err := store.Get(ctx, "k")
+		require.NoError(t, err)
+		assert.Nil(t, cached)
+
+		response := &CachedResponse{
+			Status: http.StatusOK,
+			Header: http.Header{"Content-Type": {"application/json"}},
+			Body:   []byte(`{"ok":true}`),
+		}
+		require.NoError(t, store.Set(ctx, "k", response, time.Minute))
+		cached, err = store.Get(ctx, "k")
+		require.NoError(t, err)
+		assert.Equal(t, response, cached)
+		assert.Contains(t, server.Keys(), "httpcache:k")
+	})
+
+	t.Run("Expires", func(t *testing.T) {
+		require.NoError(t, store.Set(ctx, "short", &CachedResponse{Status: http.StatusOK}, 50*time.Millisecond))
+		server.FastForward(100 * time.Millisecond)
+		cached, err := store.Get(ctx, "short")
+		require.NoError(t, err)
+		assert.Nil(t, cached)
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		version, err := store.Version(ctx, "user:1")
+		require.NoError(t, err)
+		assert.Zero(t, version)
+
+		require.NoError(t, store.Invalidate(ctx, "user:1"))
+		require.NoError(t, store.Invalidate(ctx, "user:1"))
+		version, err = store.Version(ctx, "user:1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), version)
+	})
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tenant resolves the tenant of the request from the cfg.Header header or,
+// failing that, the subdomain of cfg.BaseDomain, and stores it in the request
+// context for tenant.FromContext. Requests matching neither belong to the
+// default (empty) tenant; malformed tenant IDs are rejected with 400.
+func Tenant(cfg config.TenancyConfig) gin.HandlerFunc {
+	baseDomain := strings.ToLower(strings.Trim(cfg.BaseDomain, "."))
+	return func(c *gin.Context) {
+		id := ""
+		if cfg.Header != "" {
+			id = strings.ToLower(strings.TrimSpace(c.GetHeader(cfg.Header)))
+		}
+		if id == "" && baseDomain != "" {
+			id = subdomain(c.Request.Host, baseDomain)
+		}
+
+		if id != "" {
+			if err := tenant.Validate(id); err != nil {
+				logger.FromContext(c.Request.Context()).Debug("Tenant inválido", "tenant", id, "ip", c.ClientIP())
+				apierror.Abort(c, apierror.BadRequest(err.Error()))
+				return
+			}
+		}
+		c.Set("tenantID", id)
+		c.Request = c.Request.WithContext(tenant.WithTenant(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// subdomain returns the label right below baseDomain in host, e.g. "acme" for
+// "acme.app.example.com" and base domain "app.example.com"
+func subdomain(host, baseDomain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	prefix, ok := strings.CutSuffix(host, "."+baseDomain)
+	if !ok {
+		return ""
+	}
+	// Only the label right below the base domain names the tenant
+	if i := strings.LastIndex(prefix, "."); i >= 0 {
+		prefix = prefix[i+1:]
+	}
+	return prefix
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenant(t *testing.T) {
+	tenant.SetEnabled(true)
+	defer tenant.SetEnabled(false)
+
+	r := gin.New()
+	r.Use(Tenant(config.TenancyConfig{Enabled: true, Header: "X-Tenant-ID", BaseDomain: "app.example.com"}))
+	r.GET("/test", func(c *gin.Context) {
+		c.String(http.StatusOK, tenant.FromContext(c.Request.Context()))
+	})
+
+	tests := []struct {
+		name           string
+		host           string
+		header         string
+		expectedStatus int
+		expectedTenant string
+	}{
+		{name: "header", host: "app.example.com", header: "Acme", expectedStatus: http.StatusOK, expectedTenant: "acme"},
+		{name: "subdomain", host: "acme.app.example.com:8080", expectedStatus: http.StatusOK, expectedTenant: "acme"},
+		{name: "header wins over subdomain", host: "acme.app.example.com", header: "globex", expectedStatus: http.StatusOK, expectedTenant: "globex"},
+		{name: "nested subdomain", host: "www.acme.app.example.com", expectedStatus: http.StatusOK, expectedTenant: "acme"},
+		{name: "base domain", host: "app.example.com", expectedStatus: http.StatusOK, expectedTenant: ""},
+		{name: "other domain", host: "acme.example.org", expectedStatus: http.StatusOK, expectedTenant: ""},
+		{name: "invalid", host: "app.example.com", header: "../acme", expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Host = tt.host
+			if tt.header != "" {
+				req.Header.Set("X-Tenant-ID", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, tt.expectedTenant, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_TenantMismatch(t *testing.T) {
+	tenant.SetEnabled(true)
+	defer tenant.SetEnabled(false)
+
+	authManager, db := createTestAuthManager()
+	db.Create(&models.User{TenantID: "acme", Username: "testuser", Email: "test@example.com", PasswordHash: "hash", Active: true, Role: "user"})
+	db.Create(&models.Session{ID: "session-id", UserID: 1, ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now(), LastUsedAt: time.Now()})
+
+	r := gin.New()
+	r.Use(Tenant(config.TenancyConfig{Enabled: true, Header: "X-Tenant-ID"}), AuthMiddleware(authManager))
+	r.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	request := func(tenantID string) int {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set(SessionHeaderName, "session-id")
+		req.Header.Set("X-Tenant-ID", tenantID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("acme"))
+	assert.Equal(t, http.StatusUnauthorized, request("globex"), "sessions are only valid in the user's tenant")
+}
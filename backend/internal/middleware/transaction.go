@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/database"
+	"gosveltekit/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Transaction runs the rest of the chain in a database transaction, stored in
+// the request context for database.Conn, so every write of a multi-write
+// handler is applied or discarded together. Opt-in, per route:
+//
+//	admin.POST("/users/import", middleware.Transaction(db), h.Import)
+//
+// It commits when the handlers answer 2xx without recording an error with
+// c.Error, and rolls back otherwise. A panic rolls back too and keeps
+// propagating, so RecoveryMiddleware still answers it.
+//
+// The commit happens once the handler has written its response, so a failed
+// commit can't change the status anymore; it is logged.
+func Transaction(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tx := db.WithContext(c.Request.Context()).Begin()
+		if tx.Error != nil {
+			logger.FromContext(c.Request.Context()).Error("Erro ao iniciar transação da requisição", "error", tx.Error, "path", c.Request.URL.Path)
+			apierror.Abort(c, apierror.Internal("erro interno do servidor"))
+			return
+		}
+		c.Request = c.Request.WithContext(database.WithTx(c.Request.Context(), tx))
+
+		done := false
+		defer func() {
+			// Reached without done on errors and panics alike
+			if done {
+				return
+			}
+			if err := tx.Rollback().Error; err != nil {
+				logger.FromContext(c.Request.Context()).Error("Erro ao desfazer transação da requisição", "error", err, "path", c.Request.URL.Path)
+			}
+		}()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if len(c.Errors) > 0 || status < 200 || status >= 300 {
+			logger.FromContext(c.Request.Context()).Debug("Transação da requisição desfeita", "status", status, "path", c.Request.URL.Path)
+			return
+		}
+		done = true
+		if err := tx.Commit().Error; err != nil {
+			logger.FromContext(c.Request.Context()).Error("Erro ao confirmar transação da requisição", "error", err, "status", status, "path", c.Request.URL.Path)
+		}
+	}
+}
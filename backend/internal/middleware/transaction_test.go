@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gosveltekit/internal/auth"
+	gormadapter "gosveltekit/internal/auth/adapter/gorm"
+	"gosveltekit/internal/database"
+	"gosveltekit/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTransaction(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	// A single connection, so the transaction and the checks share one database
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&models.User{}))
+	users := gormadapter.NewUserAdapter(db)
+
+	// Each handler writes twice: through the adapter and through database.Conn
+	write := func(c *gin.Context) {
+		ctx := c.Request.Context()
+		_, err := users.CreateUser(ctx, auth.CreateUserInput{Identifier: "first", Email: "first@example.com", Password: "Passw0rd!", DisplayName: "First"})
+		require.NoError(t, err)
+		require.NoError(t, database.Conn(ctx, db).Create(&models.User{Username: "second", Email: "second@example.com", PasswordHash: "x"}).Error)
+	}
+
+	r := gin.New()
+	r.Use(RecoveryMiddleware(false))
+	r.POST("/ok", Transaction(db), func(c *gin.Context) {
+		write(c)
+		c.Status(http.StatusCreated)
+	})
+	r.POST("/error", Transaction(db), func(c *gin.Context) {
+		write(c)
+		_ = c.Error(errors.New("third write failed"))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "falha"})
+	})
+	r.POST("/conflict", Transaction(db), func(c *gin.Context) {
+		write(c)
+		c.JSON(http.StatusConflict, gin.H{"error": "conflito"})
+	})
+	r.POST("/panic", Transaction(db), func(c *gin.Context) {
+		write(c)
+		panic("boom")
+	})
+
+	count := func() int64 {
+		var n int64
+		require.NoError(t, db.Model(&models.User{}).Count(&n).Error)
+		return n
+	}
+
+	for _, path := range []string{"/error", "/conflict", "/panic"} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+			assert.GreaterOrEqual(t, w.Code, http.StatusBadRequest)
+			assert.Zero(t, count(), "writes are rolled back")
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ok", nil))
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, int64(2), count(), "writes are committed")
+}
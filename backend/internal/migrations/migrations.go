@@ -0,0 +1,309 @@
+// Package migrations applies the versioned SQL migrations embedded from
+// sql/<driver>/, so every environment goes through the same schema history
+// instead of whatever AutoMigrate infers from the models.
+//
+// Each migration is a pair of files named NNNN_description.up.sql and
+// NNNN_description.down.sql. Versions are applied in order, each in its own
+// transaction, and recorded in the schema_migrations table. MySQL commits DDL
+// implicitly, so a failed migration there may be left half applied.
+//
+// Changing the schema means adding a new version for every driver; applied
+// files must never be edited.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/logger"
+
+	"gorm.io/gorm"
+)
+
+// Table records the applied versions
+const Table = "schema_migrations"
+
+//go:embed sql
+var files embed.FS
+
+var (
+	// ErrUnsupportedDriver is returned for drivers without migrations
+	ErrUnsupportedDriver = errors.New("driver sem migrações")
+	// ErrInvalidMigration is wrapped by every malformed migration file error
+	ErrInvalidMigration = errors.New("migração inválida")
+	// ErrUnknownVersion means the database has a version this build doesn't
+	// know, usually because it was migrated by a newer release
+	ErrUnknownVersion = errors.New("versão de migração desconhecida")
+)
+
+// Migration is one schema version
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration was applied, and when
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// appliedMigration is a row of Table
+type appliedMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (appliedMigration) TableName() string {
+	return Table
+}
+
+// Load returns the embedded migrations of a driver, ordered by version. An
+// empty driver means sqlite.
+func Load(driver string) ([]Migration, error) {
+	if driver == "" {
+		driver = config.DriverSQLite
+	}
+	dir := path.Join("sql", driver)
+	if _, err := fs.Stat(files, dir); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDriver, driver)
+	}
+	return load(files, dir)
+}
+
+// load parses the up/down pairs in dir of fsys
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("%w: %s (use NNNN_descricao.up.sql ou .down.sql)", ErrInvalidMigration, name)
+		}
+		number, description, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version < 1 || description == "" {
+			return nil, fmt.Errorf("%w: %s (use NNNN_descricao.up.sql ou .down.sql)", ErrInvalidMigration, name)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: description}
+			byVersion[version] = m
+		} else if m.Name != description {
+			return nil, fmt.Errorf("%w: versão %d tem nomes diferentes (%s e %s)", ErrInvalidMigration, version, m.Name, description)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("%w: versão %d precisa de .up.sql e .down.sql", ErrInvalidMigration, m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies and rolls back migrations on a database
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New returns a Migrator for the embedded migrations of driver
+func New(db *gorm.DB, driver string) (*Migrator, error) {
+	migrations, err := Load(driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies every pending migration and returns how many were applied.
+// Instances starting at the same time may race: the loser fails on the
+// version's primary key and should be restarted.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, migration.Up); err != nil {
+				return err
+			}
+			return tx.Create(&appliedMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("falha ao aplicar migração %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		logger.Info("Migração aplicada", "version", migration.Version, "name", migration.Name)
+		count++
+	}
+	return count, nil
+}
+
+// Down rolls back the last steps applied migrations, newest first, and
+// returns how many were rolled back
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, migration.Down); err != nil {
+				return err
+			}
+			return tx.Delete(&appliedMigration{Version: migration.Version}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("falha ao reverter migração %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		logger.Info("Migração revertida", "version", migration.Version, "name", migration.Name)
+		count++
+	}
+	return count, nil
+}
+
+// Status lists every known migration in order
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		row, ok := applied[migration.Version]
+		statuses[i] = Status{Migration: migration, Applied: ok, AppliedAt: row.AppliedAt}
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations Up would apply
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// applied creates Table if needed and returns its rows by version. A version
+// this build doesn't know is an error, so an older release never runs
+// against a newer schema.
+func (m *Migrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	db := m.db.WithContext(ctx)
+	if err := db.Exec("CREATE TABLE IF NOT EXISTS " + Table + " (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL)").Error; err != nil {
+		return nil, fmt.Errorf("falha ao criar tabela %s: %w", Table, err)
+	}
+
+	var rows []appliedMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	known := make(map[int]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	applied := make(map[int]appliedMigration, len(rows))
+	for _, row := range rows {
+		if !known[row.Version] {
+			return nil, fmt.Errorf("%w: %04d_%s está aplicada no banco", ErrUnknownVersion, row.Version, row.Name)
+		}
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// execScript runs each statement of a migration file. Statements end with a
+// ";" at the end of a line; lines starting with "--" are comments.
+// addColumn matches "ALTER TABLE t ADD COLUMN c", which SQLite and MySQL
+// can't make conditional
+var addColumn = regexp.MustCompile("(?is)^ALTER\\s+TABLE\\s+[`\"]?(\\w+)[`\"]?\\s+ADD\\s+COLUMN\\s+[`\"]?(\\w+)[`\"]?\\s")
+
+// execScript runs every statement of a script. Adding a column that already
+// exists is skipped, so databases AutoMigrate created from newer models are
+// adopted.
+func execScript(tx *gorm.DB, script string) error {
+	for _, statement := range splitStatements(script) {
+		if m := addColumn.FindStringSubmatch(statement); m != nil && tx.Migrator().HasColumn(m[1], m[2]) {
+			continue
+		}
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
@@ -0,0 +1,246 @@
+package migrations
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// allModels are the tables the migrations must create
+var allModels = []any{&models.User{}, &models.Session{}, &models.RecoveryCode{}, &models.Job{}, &models.PasswordHistory{}, &models.SMSCode{}, &models.Role{}, &models.RolePermission{}, &models.Setting{}, &models.ExternalIdentity{}, &models.TOTPSecret{}, &models.TwoFactorChallenge{}, &models.RefreshToken{}, &models.LoginAttempt{}, &models.AuditLog{}, &models.APIKey{}, &models.MagicLink{}}
+
+func openDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	// Every connection of :memory: is a different database
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+// schema maps each model table to its sorted column and index names
+func schema(t *testing.T, db *gorm.DB) map[string][]string {
+	t.Helper()
+	tables := make(map[string][]string)
+	for _, model := range allModels {
+		stmt := &gorm.Statement{DB: db}
+		require.NoError(t, stmt.Parse(model))
+		table := stmt.Schema.Table
+
+		var names []string
+		columns, err := db.Migrator().ColumnTypes(table)
+		require.NoError(t, err)
+		for _, column := range columns {
+			names = append(names, "column "+column.Name())
+		}
+		indexes, err := db.Migrator().GetIndexes(table)
+		require.NoError(t, err)
+		for _, index := range indexes {
+			names = append(names, "index "+index.Name())
+		}
+		sort.Strings(names)
+		tables[table] = names
+	}
+	return tables
+}
+
+func TestLoad(t *testing.T) {
+	sqliteMigrations, err := Load("")
+	require.NoError(t, err)
+	require.NotEmpty(t, sqliteMigrations)
+
+	// Every driver goes through the same versions
+	for _, driver := range []string{config.DriverPostgres, config.DriverMySQL} {
+		migrations, err := Load(driver)
+		require.NoError(t, err, driver)
+		require.Len(t, migrations, len(sqliteMigrations), driver)
+		for i, m := range migrations {
+			assert.Equal(t, sqliteMigrations[i].Version, m.Version, driver)
+			assert.Equal(t, sqliteMigrations[i].Name, m.Name, driver)
+		}
+	}
+
+	_, err = Load("oracle")
+	assert.ErrorIs(t, err, ErrUnsupportedDriver)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"bad name":     {"m/create_users.up.sql": {Data: []byte("SELECT 1;")}},
+		"bad suffix":   {"m/0001_users.sideways.sql": {Data: []byte("SELECT 1;")}},
+		"missing down": {"m/0001_users.up.sql": {Data: []byte("SELECT 1;")}},
+		"name mismatch": {
+			"m/0001_users.up.sql":    {Data: []byte("SELECT 1;")},
+			"m/0001_people.down.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := load(fsys, "m")
+			assert.ErrorIs(t, err, ErrInvalidMigration)
+		})
+	}
+}
+
+func TestUp_MatchesModels(t *testing.T) {
+	db := openDB(t)
+	m, err := New(db, config.DriverSQLite)
+	require.NoError(t, err)
+
+	applied, err := m.Up(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, len(m.migrations), applied)
+
+	// The SQL creates what the models describe
+	reference := openDB(t)
+	require.NoError(t, reference.AutoMigrate(allModels...))
+	assert.Equal(t, schema(t, reference), schema(t, db))
+
+	applied, err = m.Up(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, applied, "applied migrations don't run again")
+}
+
+func TestUp_AdoptsAutoMigratedDatabase(t *testing.T) {
+	db := openDB(t)
+	require.NoError(t, db.AutoMigrate(allModels...))
+	require.NoError(t, db.Create(&models.User{Username: "alice", Email: "alice@example.com", PasswordHash: "x"}).Error)
+
+	m, err := New(db, config.DriverSQLite)
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+
+	var count int64
+	require.NoError(t, db.Model(&models.User{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count, "existing data is kept")
+}
+
+func TestUp_MovesPendingOutboxEmailsToJobs(t *testing.T) {
+	db := openDB(t)
+	m, err := New(db, config.DriverSQLite)
+	require.NoError(t, err)
+	ctx := context.Background()
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	// Back to the outbox, with an email waiting and one already sent
+	newer := 0
+	for i, migration := range m.migrations {
+		if migration.Name == "jobs" {
+			newer = len(m.migrations) - i
+		}
+	}
+	_, err = m.Down(ctx, newer)
+	require.NoError(t, err)
+	require.NoError(t, db.Exec("INSERT INTO `outbox` (`kind`, `recipient`, `payload`, `request_id`, `status`, `attempts`, `locale`) VALUES (?, ?, ?, ?, ?, ?, ?), (?, ?, ?, ?, ?, ?, ?)",
+		"welcome", "alice@example.com", `{"username":"alice","display_name":"Alice"}`, "req-1", "pending", 1, "en",
+		"welcome", "bob@example.com", "", "", "sent", 1, nil).Error)
+
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable("outbox"))
+	var jobs []models.Job
+	require.NoError(t, db.Find(&jobs).Error)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "email.welcome", jobs[0].Type)
+	assert.Equal(t, models.JobStatusPending, jobs[0].Status)
+	assert.Equal(t, 1, jobs[0].Attempts)
+	assert.Equal(t, "req-1", jobs[0].RequestID)
+	assert.JSONEq(t, `{"recipient":"alice@example.com","locale":"en","data":{"username":"alice","display_name":"Alice"}}`, jobs[0].Payload)
+}
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	db := openDB(t)
+	migrations, err := load(fstest.MapFS{
+		"m/0001_widgets.up.sql":   {Data: []byte("-- widgets\nCREATE TABLE widgets (\n    id INTEGER PRIMARY KEY\n);\n")},
+		"m/0001_widgets.down.sql": {Data: []byte("DROP TABLE widgets;\n")},
+		"m/0002_gadgets.up.sql":   {Data: []byte("CREATE TABLE gadgets (id INTEGER PRIMARY KEY);\nCREATE INDEX idx_gadgets_id ON gadgets(id);\n")},
+		"m/0002_gadgets.down.sql": {Data: []byte("DROP TABLE gadgets;\n")},
+		"m/README.md":             {Data: []byte("ignored")},
+	}, "m")
+	require.NoError(t, err)
+	m := &Migrator{db: db, migrations: migrations}
+	ctx := context.Background()
+
+	pending, err := m.Pending(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, applied)
+	assert.True(t, db.Migrator().HasTable("widgets"))
+	assert.True(t, db.Migrator().HasTable("gadgets"))
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	for _, status := range statuses {
+		assert.True(t, status.Applied, status.Name)
+		assert.False(t, status.AppliedAt.IsZero(), status.Name)
+	}
+
+	// Down goes newest first
+	reverted, err := m.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reverted)
+	assert.True(t, db.Migrator().HasTable("widgets"))
+	assert.False(t, db.Migrator().HasTable("gadgets"))
+
+	pending, err = m.Pending(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 2, pending[0].Version)
+
+	reverted, err = m.Down(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reverted, "only applied migrations are rolled back")
+	assert.False(t, db.Migrator().HasTable("widgets"))
+}
+
+func TestMigrator_FailedMigrationRollsBack(t *testing.T) {
+	db := openDB(t)
+	migrations, err := load(fstest.MapFS{
+		"m/0001_broken.up.sql":   {Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY);\nCREATE TABLE nonsense (;\n")},
+		"m/0001_broken.down.sql": {Data: []byte("DROP TABLE widgets;\n")},
+	}, "m")
+	require.NoError(t, err)
+	m := &Migrator{db: db, migrations: migrations}
+
+	_, err = m.Up(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "0001_broken")
+	assert.False(t, db.Migrator().HasTable("widgets"), "sqlite DDL is rolled back")
+
+	pending, err := m.Pending(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, pending, 1, "the version is not recorded")
+}
+
+func TestMigrator_UnknownVersion(t *testing.T) {
+	db := openDB(t)
+	m, err := New(db, config.DriverSQLite)
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+
+	// Migrated by a newer release
+	require.NoError(t, db.Create(&appliedMigration{Version: 9999, Name: "from_the_future"}).Error)
+
+	_, err = m.Up(context.Background())
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+	_, err = m.Down(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+}
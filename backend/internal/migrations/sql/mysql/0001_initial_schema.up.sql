@@ -0,0 +1,140 @@
+-- Schema previously created by AutoMigrate. IF NOT EXISTS lets databases
+-- created that way adopt the migrations without changes.
+
+CREATE TABLE IF NOT EXISTS `users` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `deleted_at` datetime(3) NULL,
+    `uuid` varchar(36),
+    `tenant_id` varchar(191) NOT NULL DEFAULT '',
+    `username` varchar(191) NOT NULL,
+    `email` varchar(191) NOT NULL,
+    `display_name` longtext NOT NULL,
+    `password_hash` longtext NOT NULL,
+    `first_name` longtext,
+    `last_name` longtext,
+    `active` boolean DEFAULT true,
+    `email_verified` boolean DEFAULT false,
+    `last_login` datetime(3) NULL,
+    `last_active` datetime(3) NULL,
+    `verification_reminder_sent_at` datetime(3) NULL,
+    `phone_number` longtext,
+    `phone_verified` boolean DEFAULT false,
+    `two_factor_method` longtext,
+    `role` varchar(191) DEFAULT 'user',
+    `permissions` text,
+    `reset_token` longtext,
+    `reset_token_expiry` datetime(3) NULL,
+    `password_version` bigint unsigned NOT NULL DEFAULT 0,
+    `must_change_password` boolean DEFAULT false,
+    `scheduled_deletion_at` datetime(3) NULL,
+    `restore_token` longtext,
+    PRIMARY KEY (`id`),
+    INDEX `idx_users_deleted_at` (`deleted_at`),
+    UNIQUE INDEX `idx_users_uuid` (`uuid`),
+    UNIQUE INDEX `idx_users_tenant_username` (`tenant_id`,`username`),
+    UNIQUE INDEX `idx_users_tenant_email` (`tenant_id`,`email`),
+    INDEX `idx_users_username` (`username`),
+    INDEX `idx_users_email` (`email`),
+    INDEX `idx_users_verification_reminder_sent_at` (`verification_reminder_sent_at`),
+    INDEX `idx_users_scheduled_deletion_at` (`scheduled_deletion_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `sessions` (
+    `id` varchar(64),
+    `user_id` bigint unsigned NOT NULL,
+    `expires_at` datetime(3) NOT NULL,
+    `created_at` datetime(3) NULL,
+    `last_used_at` datetime(3) NULL,
+    `impersonator_id` bigint unsigned,
+    `impersonator_session_id` varchar(64),
+    `user_agent` varchar(500),
+    `ip` varchar(45),
+    PRIMARY KEY (`id`),
+    INDEX `idx_sessions_user_id` (`user_id`),
+    INDEX `idx_sessions_expires_at` (`expires_at`),
+    INDEX `idx_sessions_impersonator_id` (`impersonator_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `recovery_codes` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `code_hash` varchar(64) NOT NULL,
+    `used_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_recovery_codes_user_id` (`user_id`),
+    INDEX `idx_recovery_codes_code_hash` (`code_hash`)
+);
+
+CREATE TABLE IF NOT EXISTS `outbox` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `kind` varchar(50) NOT NULL,
+    `recipient` longtext NOT NULL,
+    `payload` text,
+    `request_id` varchar(64),
+    `status` varchar(20) NOT NULL DEFAULT 'pending',
+    `attempts` bigint NOT NULL DEFAULT 0,
+    `last_error` text,
+    `next_attempt_at` datetime(3) NULL,
+    `sent_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_outbox_request_id` (`request_id`),
+    INDEX `idx_outbox_status` (`status`),
+    INDEX `idx_outbox_next_attempt_at` (`next_attempt_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `password_history` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `password_hash` longtext NOT NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_password_history_user_id` (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `sms_codes` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `purpose` varchar(32) NOT NULL,
+    `code_hash` varchar(64) NOT NULL,
+    `attempts` bigint NOT NULL DEFAULT 0,
+    `expires_at` datetime(3) NOT NULL,
+    `used_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_sms_codes_user_id` (`user_id`),
+    INDEX `idx_sms_codes_purpose` (`purpose`),
+    INDEX `idx_sms_codes_created_at` (`created_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `roles` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `name` varchar(50) NOT NULL,
+    `description` varchar(255),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_roles_name` (`name`)
+);
+
+CREATE TABLE IF NOT EXISTS `role_permissions` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `role_id` bigint unsigned NOT NULL,
+    `permission` varchar(100) NOT NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_role_permission` (`role_id`,`permission`),
+    CONSTRAINT `fk_roles_permissions` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `settings` (
+    `key` varchar(64),
+    `value` longtext NOT NULL,
+    `updated_by` longtext,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`key`)
+);
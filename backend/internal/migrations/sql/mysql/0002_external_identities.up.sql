@@ -0,0 +1,13 @@
+-- Accounts at OAuth/OIDC providers linked to users
+
+CREATE TABLE IF NOT EXISTS `external_identities` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `provider` varchar(32) NOT NULL,
+    `subject` varchar(255) NOT NULL,
+    `email` varchar(255),
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_external_identities_user_id` (`user_id`),
+    UNIQUE INDEX `idx_external_identities_subject` (`provider`,`subject`)
+);
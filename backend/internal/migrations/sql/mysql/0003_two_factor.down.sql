@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `two_factor_challenges`;
+DROP TABLE IF EXISTS `totp_secrets`;
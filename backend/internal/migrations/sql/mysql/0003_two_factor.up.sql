@@ -0,0 +1,25 @@
+-- TOTP secrets and pending two-factor logins
+
+CREATE TABLE IF NOT EXISTS `totp_secrets` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL,
+    `secret` varchar(64) NOT NULL,
+    `confirmed_at` datetime(3) NULL,
+    `last_used_step` bigint NOT NULL DEFAULT 0,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_totp_secrets_user_id` (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `two_factor_challenges` (
+    `id` varchar(64),
+    `user_id` bigint unsigned NOT NULL,
+    `attempts` bigint NOT NULL DEFAULT 0,
+    `expires_at` datetime(3) NOT NULL,
+    `user_agent` varchar(500),
+    `ip` varchar(45),
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_two_factor_challenges_user_id` (`user_id`),
+    INDEX `idx_two_factor_challenges_expires_at` (`expires_at`)
+);
@@ -0,0 +1,16 @@
+-- Rotating refresh tokens, grouped in families per login
+
+CREATE TABLE IF NOT EXISTS `refresh_tokens` (
+    `id` varchar(64),
+    `family_id` varchar(64) NOT NULL,
+    `session_id` varchar(64) NOT NULL,
+    `user_id` bigint unsigned NOT NULL,
+    `expires_at` datetime(3) NOT NULL,
+    `used_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_refresh_tokens_family_id` (`family_id`),
+    INDEX `idx_refresh_tokens_session_id` (`session_id`),
+    INDEX `idx_refresh_tokens_user_id` (`user_id`),
+    INDEX `idx_refresh_tokens_expires_at` (`expires_at`)
+);
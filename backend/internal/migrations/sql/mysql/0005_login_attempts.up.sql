@@ -0,0 +1,13 @@
+-- Failed login counters per identifier and per client IP
+
+CREATE TABLE IF NOT EXISTS `login_attempts` (
+    `kind` varchar(16),
+    `key` varchar(255),
+    `failures` bigint NOT NULL DEFAULT 0,
+    `last_failure_at` datetime(3) NOT NULL,
+    `locked_at` datetime(3) NULL,
+    `expires_at` datetime(3) NOT NULL,
+    PRIMARY KEY (`kind`, `key`),
+    INDEX `idx_login_attempts_locked_at` (`locked_at`),
+    INDEX `idx_login_attempts_expires_at` (`expires_at`)
+);
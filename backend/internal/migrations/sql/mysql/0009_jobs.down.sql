@@ -0,0 +1,24 @@
+-- Pending jobs are not moved back: only deploy the previous version once the
+-- queue is empty
+
+CREATE TABLE IF NOT EXISTS `outbox` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `kind` varchar(50) NOT NULL,
+    `recipient` longtext NOT NULL,
+    `payload` text,
+    `request_id` varchar(64),
+    `trace_parent` varchar(55),
+    `status` varchar(20) NOT NULL DEFAULT 'pending',
+    `attempts` bigint NOT NULL DEFAULT 0,
+    `last_error` text,
+    `next_attempt_at` datetime(3) NULL,
+    `sent_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    `locale` varchar(35),
+    PRIMARY KEY (`id`),
+    INDEX `idx_outbox_request_id` (`request_id`),
+    INDEX `idx_outbox_status` (`status`),
+    INDEX `idx_outbox_next_attempt_at` (`next_attempt_at`)
+);
+DROP TABLE IF EXISTS `jobs`;
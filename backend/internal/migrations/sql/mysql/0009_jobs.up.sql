@@ -0,0 +1,31 @@
+-- Background job queue, replacing the email outbox: emails still waiting to
+-- be sent are moved to it as email.<kind> jobs
+
+CREATE TABLE IF NOT EXISTS `jobs` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `type` varchar(100) NOT NULL,
+    `payload` text,
+    `status` varchar(20) NOT NULL DEFAULT 'pending',
+    `attempts` bigint NOT NULL DEFAULT 0,
+    `max_attempts` bigint NOT NULL DEFAULT 0,
+    `last_error` text,
+    `run_at` datetime(3) NULL,
+    `started_at` datetime(3) NULL,
+    `finished_at` datetime(3) NULL,
+    `request_id` varchar(64),
+    `trace_parent` varchar(55),
+    `dedupe_key` varchar(191),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_jobs_type` (`type`),
+    INDEX `idx_jobs_status_run_at` (`status`,`run_at`),
+    INDEX `idx_jobs_finished_at` (`finished_at`),
+    INDEX `idx_jobs_request_id` (`request_id`),
+    UNIQUE INDEX `idx_jobs_dedupe_key` (`dedupe_key`)
+);
+
+INSERT INTO `jobs` (`type`, `payload`, `status`, `attempts`, `max_attempts`, `last_error`, `run_at`, `request_id`, `trace_parent`, `created_at`, `updated_at`)
+SELECT CONCAT('email.', `kind`), JSON_OBJECT('recipient', `recipient`, 'locale', COALESCE(`locale`, ''), 'data', CAST(COALESCE(NULLIF(`payload`, ''), '{}') AS JSON)), 'pending', `attempts`, 5, `last_error`, COALESCE(`next_attempt_at`, `created_at`, CURRENT_TIMESTAMP(3)), `request_id`, `trace_parent`, `created_at`, `updated_at`
+FROM `outbox` WHERE `status` = 'pending';
+DROP TABLE IF EXISTS `outbox`;
@@ -0,0 +1,19 @@
+-- Security-relevant events, see the audit package
+
+CREATE TABLE IF NOT EXISTS `audit_logs` (
+    `id` bigint unsigned AUTO_INCREMENT,
+    `created_at` datetime(3) NULL,
+    `tenant_id` varchar(64) NOT NULL DEFAULT '',
+    `event` varchar(64) NOT NULL,
+    `actor_id` varchar(64),
+    `target_id` varchar(64),
+    `ip` varchar(45),
+    `user_agent` text,
+    `request_id` varchar(64),
+    `data` text,
+    PRIMARY KEY (`id`),
+    INDEX `idx_audit_logs_created_at` (`created_at`),
+    INDEX `idx_audit_logs_event` (`event`),
+    INDEX `idx_audit_logs_actor_id` (`actor_id`),
+    INDEX `idx_audit_logs_target_id` (`target_id`)
+);
@@ -0,0 +1,15 @@
+-- API keys of machine-to-machine clients, acting as their owner within scopes
+
+CREATE TABLE IF NOT EXISTS `api_keys` (
+    `id` varchar(32),
+    `user_id` bigint unsigned NOT NULL,
+    `name` varchar(100) NOT NULL,
+    `secret_hash` varchar(64) NOT NULL,
+    `scopes` varchar(1000) NOT NULL,
+    `expires_at` datetime(3) NULL,
+    `last_used_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_api_keys_user_id` (`user_id`),
+    INDEX `idx_api_keys_expires_at` (`expires_at`)
+);
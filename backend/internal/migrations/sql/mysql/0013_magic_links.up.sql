@@ -0,0 +1,14 @@
+-- Single-use passwordless login links; only the hash of each token is stored
+
+CREATE TABLE IF NOT EXISTS `magic_links` (
+    `id` varchar(64),
+    `user_id` bigint unsigned NOT NULL,
+    `email` varchar(255) NOT NULL,
+    `expires_at` datetime(3) NOT NULL,
+    `used_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_magic_links_user_id` (`user_id`),
+    INDEX `idx_magic_links_expires_at` (`expires_at`),
+    INDEX `idx_magic_links_created_at` (`created_at`)
+);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS "settings";
+DROP TABLE IF EXISTS "role_permissions";
+DROP TABLE IF EXISTS "roles";
+DROP TABLE IF EXISTS "sms_codes";
+DROP TABLE IF EXISTS "password_history";
+DROP TABLE IF EXISTS "outbox";
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "sessions";
+DROP TABLE IF EXISTS "users";
@@ -0,0 +1,25 @@
+-- TOTP secrets and pending two-factor logins
+
+CREATE TABLE IF NOT EXISTS "totp_secrets" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "secret" varchar(64) NOT NULL,
+    "confirmed_at" timestamptz,
+    "last_used_step" bigint NOT NULL DEFAULT 0,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_totp_secrets_user_id" ON "totp_secrets" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "two_factor_challenges" (
+    "id" varchar(64),
+    "user_id" bigint NOT NULL,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "expires_at" timestamptz NOT NULL,
+    "user_agent" varchar(500),
+    "ip" varchar(45),
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_two_factor_challenges_expires_at" ON "two_factor_challenges" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_two_factor_challenges_user_id" ON "two_factor_challenges" ("user_id");
@@ -0,0 +1,16 @@
+-- Rotating refresh tokens, grouped in families per login
+
+CREATE TABLE IF NOT EXISTS "refresh_tokens" (
+    "id" varchar(64),
+    "family_id" varchar(64) NOT NULL,
+    "session_id" varchar(64) NOT NULL,
+    "user_id" bigint NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_expires_at" ON "refresh_tokens" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_user_id" ON "refresh_tokens" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_session_id" ON "refresh_tokens" ("session_id");
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_family_id" ON "refresh_tokens" ("family_id");
@@ -0,0 +1,3 @@
+-- W3C trace context of the request that queued each email
+
+ALTER TABLE "outbox" ADD COLUMN IF NOT EXISTS "trace_parent" varchar(55);
@@ -0,0 +1,3 @@
+-- When each address was verified
+
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "email_verified_at" timestamptz;
@@ -0,0 +1,24 @@
+-- Pending jobs are not moved back: only deploy the previous version once the
+-- queue is empty
+
+CREATE TABLE IF NOT EXISTS "outbox" (
+    "id" bigserial,
+    "kind" varchar(50) NOT NULL,
+    "recipient" text NOT NULL,
+    "payload" text,
+    "request_id" varchar(64),
+    "trace_parent" varchar(55),
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "last_error" text,
+    "next_attempt_at" timestamptz,
+    "sent_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "locale" varchar(35),
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_outbox_next_attempt_at" ON "outbox" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_outbox_status" ON "outbox" ("status");
+CREATE INDEX IF NOT EXISTS "idx_outbox_request_id" ON "outbox" ("request_id");
+DROP TABLE IF EXISTS "jobs";
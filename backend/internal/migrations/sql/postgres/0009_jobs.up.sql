@@ -0,0 +1,31 @@
+-- Background job queue, replacing the email outbox: emails still waiting to
+-- be sent are moved to it as email.<kind> jobs
+
+CREATE TABLE IF NOT EXISTS "jobs" (
+    "id" bigserial,
+    "type" varchar(100) NOT NULL,
+    "payload" text,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "max_attempts" bigint NOT NULL DEFAULT 0,
+    "last_error" text,
+    "run_at" timestamptz,
+    "started_at" timestamptz,
+    "finished_at" timestamptz,
+    "request_id" varchar(64),
+    "trace_parent" varchar(55),
+    "dedupe_key" varchar(191),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_jobs_type" ON "jobs" ("type");
+CREATE INDEX IF NOT EXISTS "idx_jobs_status_run_at" ON "jobs" ("status","run_at");
+CREATE INDEX IF NOT EXISTS "idx_jobs_finished_at" ON "jobs" ("finished_at");
+CREATE INDEX IF NOT EXISTS "idx_jobs_request_id" ON "jobs" ("request_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_jobs_dedupe_key" ON "jobs" ("dedupe_key");
+
+INSERT INTO "jobs" ("type", "payload", "status", "attempts", "max_attempts", "last_error", "run_at", "request_id", "trace_parent", "created_at", "updated_at")
+SELECT 'email.' || "kind", json_build_object('recipient', "recipient", 'locale', COALESCE("locale", ''), 'data', COALESCE(NULLIF("payload", ''), '{}')::json)::text, 'pending', "attempts", 5, "last_error", COALESCE("next_attempt_at", "created_at", now()), "request_id", "trace_parent", "created_at", "updated_at"
+FROM "outbox" WHERE "status" = 'pending';
+DROP TABLE IF EXISTS "outbox";
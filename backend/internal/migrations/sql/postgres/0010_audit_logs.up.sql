@@ -0,0 +1,19 @@
+-- Security-relevant events, see the audit package
+
+CREATE TABLE IF NOT EXISTS "audit_logs" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "tenant_id" varchar(64) NOT NULL DEFAULT '',
+    "event" varchar(64) NOT NULL,
+    "actor_id" varchar(64),
+    "target_id" varchar(64),
+    "ip" varchar(45),
+    "user_agent" text,
+    "request_id" varchar(64),
+    "data" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_event" ON "audit_logs" ("event");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_actor_id" ON "audit_logs" ("actor_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_logs_target_id" ON "audit_logs" ("target_id");
@@ -0,0 +1,15 @@
+-- API keys of machine-to-machine clients, acting as their owner within scopes
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" varchar(32),
+    "user_id" bigint NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "secret_hash" varchar(64) NOT NULL,
+    "scopes" varchar(1000) NOT NULL,
+    "expires_at" timestamptz,
+    "last_used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_api_keys_user_id" ON "api_keys" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_expires_at" ON "api_keys" ("expires_at");
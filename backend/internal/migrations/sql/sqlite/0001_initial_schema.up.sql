@@ -0,0 +1,133 @@
+-- Schema previously created by AutoMigrate. IF NOT EXISTS lets databases
+-- created that way adopt the migrations without changes.
+
+CREATE TABLE IF NOT EXISTS `users` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `uuid` varchar(36),
+    `tenant_id` text NOT NULL DEFAULT '',
+    `username` text NOT NULL,
+    `email` text NOT NULL,
+    `display_name` text NOT NULL,
+    `password_hash` text NOT NULL,
+    `first_name` text,
+    `last_name` text,
+    `active` numeric DEFAULT true,
+    `email_verified` numeric DEFAULT false,
+    `last_login` datetime,
+    `last_active` datetime,
+    `verification_reminder_sent_at` datetime,
+    `phone_number` text,
+    `phone_verified` numeric DEFAULT false,
+    `two_factor_method` text,
+    `role` text DEFAULT 'user',
+    `permissions` text,
+    `reset_token` text,
+    `reset_token_expiry` datetime,
+    `password_version` integer NOT NULL DEFAULT 0,
+    `must_change_password` numeric DEFAULT false,
+    `scheduled_deletion_at` datetime,
+    `restore_token` text
+);
+CREATE INDEX IF NOT EXISTS `idx_users_scheduled_deletion_at` ON `users`(`scheduled_deletion_at`);
+CREATE INDEX IF NOT EXISTS `idx_users_verification_reminder_sent_at` ON `users`(`verification_reminder_sent_at`);
+CREATE INDEX IF NOT EXISTS `idx_users_email` ON `users`(`email`);
+CREATE INDEX IF NOT EXISTS `idx_users_username` ON `users`(`username`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_users_tenant_email` ON `users`(`tenant_id`,`email`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_users_tenant_username` ON `users`(`tenant_id`,`username`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_users_uuid` ON `users`(`uuid`);
+CREATE INDEX IF NOT EXISTS `idx_users_deleted_at` ON `users`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `sessions` (
+    `id` varchar(64),
+    `user_id` integer NOT NULL,
+    `expires_at` datetime NOT NULL,
+    `created_at` datetime,
+    `last_used_at` datetime,
+    `impersonator_id` integer,
+    `impersonator_session_id` varchar(64),
+    `user_agent` varchar(500),
+    `ip` varchar(45),
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_sessions_impersonator_id` ON `sessions`(`impersonator_id`);
+CREATE INDEX IF NOT EXISTS `idx_sessions_expires_at` ON `sessions`(`expires_at`);
+CREATE INDEX IF NOT EXISTS `idx_sessions_user_id` ON `sessions`(`user_id`);
+
+CREATE TABLE IF NOT EXISTS `recovery_codes` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `code_hash` varchar(64) NOT NULL,
+    `used_at` datetime,
+    `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_recovery_codes_code_hash` ON `recovery_codes`(`code_hash`);
+CREATE INDEX IF NOT EXISTS `idx_recovery_codes_user_id` ON `recovery_codes`(`user_id`);
+
+CREATE TABLE IF NOT EXISTS `outbox` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `kind` varchar(50) NOT NULL,
+    `recipient` text NOT NULL,
+    `payload` text,
+    `request_id` varchar(64),
+    `status` varchar(20) NOT NULL DEFAULT 'pending',
+    `attempts` integer NOT NULL DEFAULT 0,
+    `last_error` text,
+    `next_attempt_at` datetime,
+    `sent_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_outbox_next_attempt_at` ON `outbox`(`next_attempt_at`);
+CREATE INDEX IF NOT EXISTS `idx_outbox_status` ON `outbox`(`status`);
+CREATE INDEX IF NOT EXISTS `idx_outbox_request_id` ON `outbox`(`request_id`);
+
+CREATE TABLE IF NOT EXISTS `password_history` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `password_hash` text NOT NULL,
+    `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_password_history_user_id` ON `password_history`(`user_id`);
+
+CREATE TABLE IF NOT EXISTS `sms_codes` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `purpose` varchar(32) NOT NULL,
+    `code_hash` varchar(64) NOT NULL,
+    `attempts` integer NOT NULL DEFAULT 0,
+    `expires_at` datetime NOT NULL,
+    `used_at` datetime,
+    `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_sms_codes_created_at` ON `sms_codes`(`created_at`);
+CREATE INDEX IF NOT EXISTS `idx_sms_codes_purpose` ON `sms_codes`(`purpose`);
+CREATE INDEX IF NOT EXISTS `idx_sms_codes_user_id` ON `sms_codes`(`user_id`);
+
+CREATE TABLE IF NOT EXISTS `roles` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `name` varchar(50) NOT NULL,
+    `description` varchar(255),
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_roles_name` ON `roles`(`name`);
+
+CREATE TABLE IF NOT EXISTS `role_permissions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `role_id` integer NOT NULL,
+    `permission` varchar(100) NOT NULL,
+    `created_at` datetime,
+    CONSTRAINT `fk_roles_permissions` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_role_permission` ON `role_permissions`(`role_id`,`permission`);
+
+CREATE TABLE IF NOT EXISTS `settings` (
+    `key` text,
+    `value` text NOT NULL,
+    `updated_by` text,
+    `updated_at` datetime,
+    PRIMARY KEY (`key`)
+);
@@ -0,0 +1,12 @@
+-- Accounts at OAuth/OIDC providers linked to users
+
+CREATE TABLE IF NOT EXISTS `external_identities` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `provider` varchar(32) NOT NULL,
+    `subject` varchar(255) NOT NULL,
+    `email` varchar(255),
+    `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_external_identities_user_id` ON `external_identities`(`user_id`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_external_identities_subject` ON `external_identities`(`provider`,`subject`);
@@ -0,0 +1,24 @@
+-- TOTP secrets and pending two-factor logins
+
+CREATE TABLE IF NOT EXISTS `totp_secrets` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `secret` varchar(64) NOT NULL,
+    `confirmed_at` datetime,
+    `last_used_step` integer NOT NULL DEFAULT 0,
+    `created_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_totp_secrets_user_id` ON `totp_secrets`(`user_id`);
+
+CREATE TABLE IF NOT EXISTS `two_factor_challenges` (
+    `id` varchar(64),
+    `user_id` integer NOT NULL,
+    `attempts` integer NOT NULL DEFAULT 0,
+    `expires_at` datetime NOT NULL,
+    `user_agent` varchar(500),
+    `ip` varchar(45),
+    `created_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_two_factor_challenges_expires_at` ON `two_factor_challenges`(`expires_at`);
+CREATE INDEX IF NOT EXISTS `idx_two_factor_challenges_user_id` ON `two_factor_challenges`(`user_id`);
@@ -0,0 +1,13 @@
+-- Failed login counters per identifier and per client IP
+
+CREATE TABLE IF NOT EXISTS `login_attempts` (
+    `kind` varchar(16),
+    `key` varchar(255),
+    `failures` integer NOT NULL DEFAULT 0,
+    `last_failure_at` datetime NOT NULL,
+    `locked_at` datetime,
+    `expires_at` datetime NOT NULL,
+    PRIMARY KEY (`kind`, `key`)
+);
+CREATE INDEX IF NOT EXISTS `idx_login_attempts_expires_at` ON `login_attempts`(`expires_at`);
+CREATE INDEX IF NOT EXISTS `idx_login_attempts_locked_at` ON `login_attempts`(`locked_at`);
@@ -0,0 +1,27 @@
+-- W3C trace context of the request that queued each email
+--
+-- SQLite can't add a column only if missing, so the table is rebuilt; this
+-- also adopts databases whose outbox AutoMigrate already created with it.
+
+CREATE TABLE `outbox_new` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `kind` varchar(50) NOT NULL,
+    `recipient` text NOT NULL,
+    `payload` text,
+    `request_id` varchar(64),
+    `trace_parent` varchar(55),
+    `status` varchar(20) NOT NULL DEFAULT 'pending',
+    `attempts` integer NOT NULL DEFAULT 0,
+    `last_error` text,
+    `next_attempt_at` datetime,
+    `sent_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+INSERT INTO `outbox_new` (`id`, `kind`, `recipient`, `payload`, `request_id`, `status`, `attempts`, `last_error`, `next_attempt_at`, `sent_at`, `created_at`, `updated_at`)
+SELECT `id`, `kind`, `recipient`, `payload`, `request_id`, `status`, `attempts`, `last_error`, `next_attempt_at`, `sent_at`, `created_at`, `updated_at` FROM `outbox`;
+DROP TABLE `outbox`;
+ALTER TABLE `outbox_new` RENAME TO `outbox`;
+CREATE INDEX IF NOT EXISTS `idx_outbox_next_attempt_at` ON `outbox`(`next_attempt_at`);
+CREATE INDEX IF NOT EXISTS `idx_outbox_status` ON `outbox`(`status`);
+CREATE INDEX IF NOT EXISTS `idx_outbox_request_id` ON `outbox`(`request_id`);
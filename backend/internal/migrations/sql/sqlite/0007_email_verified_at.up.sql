@@ -0,0 +1,3 @@
+-- When each address was verified
+
+ALTER TABLE `users` ADD COLUMN `email_verified_at` datetime;
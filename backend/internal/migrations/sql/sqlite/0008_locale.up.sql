@@ -0,0 +1,4 @@
+-- Preferred email language of each user, and the language of each queued email
+
+ALTER TABLE `users` ADD COLUMN `locale` varchar(35);
+ALTER TABLE `outbox` ADD COLUMN `locale` varchar(35);
@@ -0,0 +1,3 @@
+-- Storage key of the avatar picture of each user
+
+ALTER TABLE `users` ADD COLUMN `avatar_key` varchar(255);
@@ -0,0 +1,14 @@
+-- Single-use passwordless login links; only the hash of each token is stored
+
+CREATE TABLE IF NOT EXISTS `magic_links` (
+    `id` varchar(64),
+    `user_id` integer NOT NULL,
+    `email` varchar(255) NOT NULL,
+    `expires_at` datetime NOT NULL,
+    `used_at` datetime,
+    `created_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_magic_links_user_id` ON `magic_links`(`user_id`);
+CREATE INDEX IF NOT EXISTS `idx_magic_links_expires_at` ON `magic_links`(`expires_at`);
+CREATE INDEX IF NOT EXISTS `idx_magic_links_created_at` ON `magic_links`(`created_at`);
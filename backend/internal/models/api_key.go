@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// APIKey authenticates a machine client as the user owning it, limited to
+// Scopes (space-separated permissions). ID is the public part of the key
+// handed out; only the hash of its secret is stored.
+type APIKey struct {
+	ID         string     `gorm:"primaryKey;type:varchar(32)" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	SecretHash string     `gorm:"type:varchar(64);not null" json:"-"`
+	Scopes     string     `gorm:"type:varchar(1000);not null" json:"scopes"`
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (APIKey) TableName() string {
+	return "api_keys"
+}
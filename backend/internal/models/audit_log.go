@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditLog is a security-relevant event recorded by the audit package: logins,
+// password and role changes, revoked sessions and admin actions
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	TenantID  string    `gorm:"type:varchar(64);not null;default:''" json:"tenant_id,omitempty"`
+	Event     string    `gorm:"type:varchar(64);not null;index" json:"event"`
+	ActorID   string    `gorm:"type:varchar(64);index" json:"actor_id,omitempty"`  // user performing the action; empty when anonymous
+	TargetID  string    `gorm:"type:varchar(64);index" json:"target_id,omitempty"` // user affected by it
+	IP        string    `gorm:"type:varchar(45)" json:"ip,omitempty"`
+	UserAgent string    `gorm:"type:text" json:"user_agent,omitempty"`
+	RequestID string    `gorm:"type:varchar(64)" json:"request_id,omitempty"`
+	Data      string    `gorm:"type:text" json:"-"` // JSON object with event details
+}
+
+// TableName specifies the table name for GORM
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
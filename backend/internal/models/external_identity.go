@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// ExternalIdentity links a user to an account at an OAuth/OIDC provider, so
+// later social logins find the user by the provider's subject instead of the
+// email, which may change on either side
+type ExternalIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Provider  string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_external_identities_subject,priority:1" json:"provider"`
+	Subject   string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_external_identities_subject,priority:2" json:"-"`
+	Email     string    `gorm:"type:varchar(255)" json:"email"` // as reported by the provider when linked
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (ExternalIdentity) TableName() string {
+	return "external_identities"
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// User ID strategies. Integer primary keys are always used inside the
+// database (foreign keys, joins); with IDStrategyUUID users also get a random
+// UUID, which is what the application exposes, so IDs handed to clients
+// neither leak record counts nor can be enumerated.
+const (
+	IDStrategyInteger = "integer"
+	IDStrategyUUID    = "uuid"
+)
+
+// ErrUnknownIDStrategy is returned by SetIDStrategy for unsupported strategies
+var ErrUnknownIDStrategy = errors.New("estratégia de ID desconhecida")
+
+var uuidStrategy atomic.Bool
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// SetIDStrategy selects the ID strategy for users created from now on. An
+// empty strategy means IDStrategyInteger.
+func SetIDStrategy(strategy string) error {
+	switch strategy {
+	case "", IDStrategyInteger:
+		uuidStrategy.Store(false)
+	case IDStrategyUUID:
+		uuidStrategy.Store(true)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownIDStrategy, strategy)
+	}
+	return nil
+}
+
+// UsesUUIDs reports whether new users get a UUID
+func UsesUUIDs() bool {
+	return uuidStrategy.Load()
+}
+
+// NewUUID returns a random (version 4) UUID
+func NewUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// IsUUID reports whether s is a UUID in canonical textual form
+func IsUUID(s string) bool {
+	return uuidPattern.MatchString(s)
+}
+
+// ResolveUserID returns the primary key of the user identified by id, which
+// may be the integer primary key or the user's UUID. Unknown UUIDs return
+// gorm.ErrRecordNotFound; anything else fails to parse.
+func ResolveUserID(db *gorm.DB, id string) (uint, error) {
+	if IsUUID(id) {
+		var user User
+		if err := db.Select("id").Where("uuid = ?", id).First(&user).Error; err != nil {
+			return 0, err
+		}
+		return user.ID, nil
+	}
+	parsed, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(parsed), nil
+}
+
+// BackfillUserUUIDs assigns a UUID to users created before the UUID strategy
+// was enabled, and returns how many were updated
+func BackfillUserUUIDs(db *gorm.DB) (int, error) {
+	var ids []uint
+	if err := db.Model(&User{}).Where("uuid IS NULL").Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		uuid, err := NewUUID()
+		if err != nil {
+			return 0, err
+		}
+		if err := db.Model(&User{}).Where("id = ? AND uuid IS NULL", id).Update("uuid", uuid).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
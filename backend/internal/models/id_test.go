@@ -0,0 +1,72 @@
+package models
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupIDTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&User{}))
+	return db
+}
+
+func TestUser_UUIDStrategy(t *testing.T) {
+	require.NoError(t, SetIDStrategy(IDStrategyUUID))
+	defer SetIDStrategy(IDStrategyInteger)
+	db := setupIDTestDB(t)
+
+	user := &User{Username: "alice", Email: "alice@example.com", DisplayName: "Alice", PasswordHash: "hash"}
+	require.NoError(t, db.Create(user).Error)
+
+	require.NotNil(t, user.UUID)
+	assert.True(t, IsUUID(*user.UUID), "expected a UUID, got %q", *user.UUID)
+	assert.Equal(t, byte('4'), (*user.UUID)[14], "expected a version 4 UUID")
+	assert.Equal(t, *user.UUID, user.PublicID())
+	assert.NotZero(t, user.ID, "integer primary key is still assigned")
+
+	// Lookups accept both forms
+	id, err := ResolveUserID(db, *user.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, id)
+	id, err = ResolveUserID(db, strconv.FormatUint(uint64(user.ID), 10))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, id)
+
+	_, err = ResolveUserID(db, "00000000-0000-4000-8000-000000000000")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = ResolveUserID(db, "not-an-id")
+	assert.Error(t, err)
+}
+
+func TestUser_IntegerStrategy(t *testing.T) {
+	require.NoError(t, SetIDStrategy(IDStrategyInteger))
+	db := setupIDTestDB(t)
+
+	user := &User{Username: "bob", Email: "bob@example.com", DisplayName: "Bob", PasswordHash: "hash"}
+	require.NoError(t, db.Create(user).Error)
+	assert.Nil(t, user.UUID)
+	assert.Equal(t, strconv.FormatUint(uint64(user.ID), 10), user.PublicID())
+
+	// Enabling UUIDs later backfills existing users
+	require.NoError(t, SetIDStrategy(IDStrategyUUID))
+	defer SetIDStrategy(IDStrategyInteger)
+	count, err := BackfillUserUUIDs(db)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	var reloaded User
+	require.NoError(t, db.First(&reloaded, user.ID).Error)
+	require.NotNil(t, reloaded.UUID)
+	assert.True(t, IsUUID(*reloaded.UUID))
+}
+
+func TestSetIDStrategy_Unknown(t *testing.T) {
+	assert.ErrorIs(t, SetIDStrategy("snowflake"), ErrUnknownIDStrategy)
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// Job statuses
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// Job is a unit of background work run by the jobs queue
+type Job struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Type        string     `gorm:"type:varchar(100);not null;index" json:"type"`
+	Payload     string     `gorm:"type:text" json:"-"` // JSON, cleared once the job succeeds
+	Status      string     `gorm:"type:varchar(20);not null;default:pending;index:idx_jobs_status_run_at,priority:1" json:"status"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"not null;default:0" json:"max_attempts"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	RunAt       time.Time  `gorm:"index:idx_jobs_status_run_at,priority:2" json:"run_at"` // when the job is due, pushed back on retries
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `gorm:"index" json:"finished_at,omitempty"`
+	RequestID   string     `gorm:"type:varchar(64);index" json:"request_id,omitempty"` // request that queued the job
+	TraceParent string     `gorm:"type:varchar(55)" json:"-"`                          // W3C trace context of that request, to continue its trace
+	DedupeKey   *string    `gorm:"type:varchar(191);uniqueIndex" json:"-"`             // jobs with the same key are only queued once
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Job) TableName() string {
+	return "jobs"
+}
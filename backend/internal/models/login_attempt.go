@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginAttempt counts the failed logins of an identifier (Kind "account") or
+// a client IP (Kind "ip"), so lockouts survive restarts and are shared by
+// every instance. Rows are pruned after ExpiresAt.
+type LoginAttempt struct {
+	Kind          string     `gorm:"primaryKey;type:varchar(16)" json:"kind"`
+	Key           string     `gorm:"primaryKey;type:varchar(255)" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `gorm:"not null" json:"last_failure_at"`
+	LockedAt      *time.Time `gorm:"index" json:"locked_at,omitempty"`
+	ExpiresAt     time.Time  `gorm:"not null;index" json:"expires_at"`
+}
+
+// TableName specifies the table name for GORM
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// MagicLink is a short-lived, single-use link that logs its user in without
+// a password. ID is the hash of the token sent to Email.
+type MagicLink struct {
+	ID        string     `gorm:"primaryKey;type:varchar(64)" json:"-"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	Email     string     `gorm:"type:varchar(255);not null" json:"email"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (MagicLink) TableName() string {
+	return "magic_links"
+}
@@ -2,6 +2,7 @@
 package models
 
 import (
+	"strconv"
 	"time"
 
 	"gorm.io/gorm"
@@ -10,9 +11,17 @@ import (
 // User represents a user in the system
 type User struct {
 	gorm.Model
+	// UUID is the public identifier with IDStrategyUUID (nil otherwise, and
+	// for users created before the strategy was enabled)
+	UUID *string `gorm:"uniqueIndex;type:varchar(36)" json:"uuid,omitempty"`
+
+	// TenantID is the tenant the user belongs to; empty without multi-tenancy.
+	// Usernames and emails are unique within a tenant.
+	TenantID string `gorm:"not null;default:'';uniqueIndex:idx_users_tenant_username,priority:1;uniqueIndex:idx_users_tenant_email,priority:1" json:"tenant_id,omitempty"`
+
 	// Identity information
-	Username     string `gorm:"unique;not null;index" json:"username"`
-	Email        string `gorm:"unique;not null;index" json:"email"`
+	Username     string `gorm:"not null;index;uniqueIndex:idx_users_tenant_username,priority:2" json:"username"`
+	Email        string `gorm:"not null;index;uniqueIndex:idx_users_tenant_email,priority:2" json:"email"`
 	DisplayName  string `gorm:"not null" json:"display_name"`
 	PasswordHash string `gorm:"not null" json:"-"`
 
@@ -20,12 +29,33 @@ type User struct {
 	FirstName string `json:"first_name,omitempty"`
 	LastName  string `json:"last_name,omitempty"`
 
+	// Preferred email language, e.g. "pt-BR"; empty uses email.default_locale
+	Locale string `gorm:"type:varchar(35)" json:"locale,omitempty"`
+
+	// Storage key of the avatar picture (see the storage package); empty
+	// without one
+	AvatarKey string `gorm:"type:varchar(255)" json:"-"`
+
 	// Account status
 	Active        bool      `gorm:"default:true" json:"active"`
 	EmailVerified bool      `gorm:"default:false" json:"email_verified"`
 	LastLogin     time.Time `json:"last_login,omitempty"`
 	LastActive    time.Time `json:"last_active,omitempty"`
 
+	// Set when the verification reminder is sent, so it is sent only once
+	VerificationReminderSentAt *time.Time `gorm:"index" json:"-"`
+
+	// When the address was verified; nil for accounts verified before it
+	// was recorded
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+
+	// Phone number (E.164), verified separately by SMS
+	PhoneNumber   string `json:"phone_number,omitempty"`
+	PhoneVerified bool   `gorm:"default:false" json:"phone_verified"`
+
+	// Two-factor method: empty (disabled), "totp" or "sms"
+	TwoFactorMethod string `json:"two_factor_method,omitempty"`
+
 	// Access control
 	Role        string `gorm:"default:user" json:"role"`
 	Permissions string `gorm:"type:text" json:"permissions,omitempty"` // JSON string of permissions
@@ -33,4 +63,40 @@ type User struct {
 	// Password reset (kept separate from session management)
 	ResetToken       string    `json:"-"`
 	ResetTokenExpiry time.Time `json:"-"`
+
+	// Incremented on every password change; signed reset links carry it so
+	// they stop working once the password changes
+	PasswordVersion uint `gorm:"not null;default:0" json:"-"`
+
+	// Set by an admin to force a password change; cleared when the password
+	// is changed
+	MustChangePassword bool `gorm:"default:false" json:"must_change_password"`
+
+	// Account deletion requested by the user: login is blocked and the
+	// account is purged once ScheduledDeletionAt passes, unless restored with
+	// RestoreToken (hashed) or by an admin
+	ScheduledDeletionAt *time.Time `gorm:"index" json:"scheduled_deletion_at,omitempty"`
+	RestoreToken        string     `json:"-"`
+}
+
+// BeforeCreate assigns a UUID to new users with IDStrategyUUID
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	if u.UUID != nil || !UsesUUIDs() {
+		return nil
+	}
+	uuid, err := NewUUID()
+	if err != nil {
+		return err
+	}
+	u.UUID = &uuid
+	return nil
+}
+
+// PublicID is the identifier exposed outside the database: the UUID when the
+// user has one, the integer primary key otherwise
+func (u *User) PublicID() string {
+	if u.UUID != nil {
+		return *u.UUID
+	}
+	return strconv.FormatUint(uint64(u.ID), 10)
 }
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordHistory stores a previous password hash of a user, so recent
+// passwords can't be reused
+type PasswordHistory struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"index;not null" json:"user_id"`
+	PasswordHash string    `gorm:"not null" json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (PasswordHistory) TableName() string {
+	return "password_history"
+}
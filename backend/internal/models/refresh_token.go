@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is a refresh token issued with a session. ID is the hash of
+// the token handed to the client. Tokens are rotated on every refresh and
+// kept, marked used, until they expire: tokens descending from the same login
+// share FamilyID, so a used one coming back revokes the whole family.
+type RefreshToken struct {
+	ID        string     `gorm:"primaryKey;type:varchar(64)" json:"-"`
+	FamilyID  string     `gorm:"type:varchar(64);index;not null" json:"family_id"`
+	SessionID string     `gorm:"type:varchar(64);index;not null" json:"session_id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// Role is a named set of permissions. Roles are declared in config and
+// reconciled at startup by seed.EnsureRoles; User.Role refers to Name.
+type Role struct {
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	Name        string           `gorm:"uniqueIndex;type:varchar(50);not null" json:"name"`
+	Description string           `gorm:"type:varchar(255)" json:"description,omitempty"`
+	Permissions []RolePermission `gorm:"constraint:OnDelete:CASCADE" json:"permissions,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Role) TableName() string {
+	return "roles"
+}
+
+// RolePermission grants a permission (e.g. "users:read") to a role
+type RolePermission struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	RoleID     uint      `gorm:"uniqueIndex:idx_role_permission;not null" json:"role_id"`
+	Permission string    `gorm:"uniqueIndex:idx_role_permission;type:varchar(100);not null" json:"permission"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (RolePermission) TableName() string {
+	return "role_permissions"
+}
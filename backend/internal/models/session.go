@@ -10,8 +10,13 @@ type Session struct {
 	UserID    uint      `gorm:"index;not null" json:"user_id"`
 	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
 	CreatedAt time.Time `json:"created_at"`
-	UserAgent string    `gorm:"type:varchar(500)" json:"user_agent,omitempty"`
-	IP        string    `gorm:"type:varchar(45)" json:"ip,omitempty"` // Supports IPv6
+	// LastUsedAt is refreshed on activity, throttled by AuthConfig.SessionActivityInterval
+	LastUsedAt time.Time `json:"last_used_at"`
+	// Set when an admin impersonates UserID
+	ImpersonatorID        *uint  `gorm:"index" json:"impersonator_id,omitempty"`
+	ImpersonatorSessionID string `gorm:"type:varchar(64)" json:"-"`
+	UserAgent             string `gorm:"type:varchar(500)" json:"user_agent,omitempty"`
+	IP                    string `gorm:"type:varchar(45)" json:"ip,omitempty"` // Supports IPv6
 }
 
 // TableName specifies the table name for GORM
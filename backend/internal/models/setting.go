@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// Setting is a runtime override of a configuration value, changed by admins
+// without a redeploy (see the settings package)
+type Setting struct {
+	Key       string    `gorm:"primaryKey;size:64" json:"key"`
+	Value     string    `gorm:"not null" json:"value"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
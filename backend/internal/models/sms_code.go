@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// SMSCode is a short-lived, single-use code sent by SMS, for phone
+// verification or two-factor login. Only the hash is stored.
+type SMSCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	Purpose   string     `gorm:"type:varchar(32);not null;index" json:"purpose"`
+	CodeHash  string     `gorm:"type:varchar(64);not null" json:"-"`
+	Attempts  int        `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (SMSCode) TableName() string {
+	return "sms_codes"
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// TOTPSecret is the authenticator app secret of a user. It only protects
+// logins once confirmed with a first valid code; LastUsedStep rejects a code
+// replayed within its time window.
+type TOTPSecret struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"uniqueIndex;not null" json:"user_id"`
+	Secret       string     `gorm:"type:varchar(64);not null" json:"-"` // base32
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (TOTPSecret) TableName() string {
+	return "totp_secrets"
+}
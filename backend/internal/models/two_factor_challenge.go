@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// TwoFactorChallenge is a login that passed the password check and waits for
+// the second factor. ID is the hash of the token handed to the client.
+type TwoFactorChallenge struct {
+	ID        string    `gorm:"primaryKey;type:varchar(64)" json:"-"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	Attempts  int       `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	UserAgent string    `gorm:"type:varchar(500)" json:"user_agent,omitempty"`
+	IP        string    `gorm:"type:varchar(45)" json:"ip,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (TwoFactorChallenge) TableName() string {
+	return "two_factor_challenges"
+}
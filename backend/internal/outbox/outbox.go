@@ -0,0 +1,217 @@
+// Package outbox sends emails as background jobs (see package jobs).
+//
+// Instead of talking to the SMTP server while handling a request, the email
+// is queued as an "email.<kind>" job, inside the transaction of the operation
+// that triggered it when there is one (jobs.Queue.InTx), so it is neither
+// lost if the process dies right after committing nor sent for an operation
+// that rolled back. Register installs the handlers that deliver the jobs
+// through the email service; the queue retries failures with backoff.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gosveltekit/internal/email"
+	"gosveltekit/internal/jobs"
+)
+
+// JobTypePrefix starts the job type of every email
+const JobTypePrefix = "email."
+
+// Message kinds
+const (
+	KindPasswordReset     = email.KindPasswordReset
+	KindWelcome           = email.KindWelcome
+	KindAccountDeletion   = email.KindAccountDeletion
+	KindEmailVerification = email.KindEmailVerification
+	KindNewDevice         = email.KindNewDevice
+	KindAccountLocked     = email.KindAccountLocked
+	KindMagicLink         = email.KindMagicLink
+)
+
+// JobType returns the job type of the emails of kind
+func JobType(kind string) string {
+	return JobTypePrefix + kind
+}
+
+// Message is the payload of an email job
+type Message struct {
+	Recipient string          `json:"recipient"`
+	Locale    string          `json:"locale,omitempty"` // language the email is rendered in
+	Data      json.RawMessage `json:"data"`             // payload of the kind
+}
+
+// PasswordResetPayload is the payload of a KindPasswordReset message
+type PasswordResetPayload struct {
+	Token       string `json:"token"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
+
+// WelcomePayload is the payload of a KindWelcome message
+type WelcomePayload struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
+
+// EmailVerificationPayload is the payload of a KindEmailVerification message
+type EmailVerificationPayload struct {
+	Token       string `json:"token"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
+
+// AccountDeletionPayload is the payload of a KindAccountDeletion message
+type AccountDeletionPayload struct {
+	Token       string    `json:"token"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	DeleteAt    time.Time `json:"delete_at"`
+}
+
+// NewDevicePayload is the payload of a KindNewDevice message
+type NewDevicePayload struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	UserAgent   string `json:"user_agent"`
+	IP          string `json:"ip"`
+}
+
+// AccountLockedPayload is the payload of a KindAccountLocked message
+type AccountLockedPayload struct {
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// MagicLinkPayload is the payload of a KindMagicLink message
+type MagicLinkPayload struct {
+	Token       string `json:"token"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
+
+// Enqueue queues a kind email to recipient with q. The email locale of ctx
+// (see email.WithLocale) is stored with it, and the queue keeps its request
+// ID and trace context.
+func Enqueue(ctx context.Context, q jobs.Enqueuer, kind, recipient string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar payload do email: %w", err)
+	}
+	_, err = q.Enqueue(ctx, JobType(kind), Message{
+		Recipient: recipient,
+		Locale:    email.LocaleFromContext(ctx),
+		Data:      data,
+	})
+	return err
+}
+
+// EnqueuePasswordReset queues a password reset email
+func EnqueuePasswordReset(ctx context.Context, q jobs.Enqueuer, to, token, username, displayName string) error {
+	return Enqueue(ctx, q, KindPasswordReset, to, PasswordResetPayload{
+		Token:       token,
+		Username:    username,
+		DisplayName: displayName,
+	})
+}
+
+// EnqueueWelcome queues a welcome email
+func EnqueueWelcome(ctx context.Context, q jobs.Enqueuer, to, username, displayName string) error {
+	return Enqueue(ctx, q, KindWelcome, to, WelcomePayload{
+		Username:    username,
+		DisplayName: displayName,
+	})
+}
+
+// EnqueueEmailVerification queues an email verification link
+func EnqueueEmailVerification(ctx context.Context, q jobs.Enqueuer, to, token, username, displayName string) error {
+	return Enqueue(ctx, q, KindEmailVerification, to, EmailVerificationPayload{
+		Token:       token,
+		Username:    username,
+		DisplayName: displayName,
+	})
+}
+
+// EnqueueAccountDeletion queues an account deletion confirmation email
+func EnqueueAccountDeletion(ctx context.Context, q jobs.Enqueuer, to, token, username, displayName string, deleteAt time.Time) error {
+	return Enqueue(ctx, q, KindAccountDeletion, to, AccountDeletionPayload{
+		Token:       token,
+		Username:    username,
+		DisplayName: displayName,
+		DeleteAt:    deleteAt,
+	})
+}
+
+// EnqueueNewDevice queues a new device login notification
+func EnqueueNewDevice(ctx context.Context, q jobs.Enqueuer, to, username, displayName, userAgent, ip string) error {
+	return Enqueue(ctx, q, KindNewDevice, to, NewDevicePayload{
+		Username:    username,
+		DisplayName: displayName,
+		UserAgent:   userAgent,
+		IP:          ip,
+	})
+}
+
+// EnqueueAccountLocked queues an account locked notification
+func EnqueueAccountLocked(ctx context.Context, q jobs.Enqueuer, to, username, displayName string, lockedUntil time.Time) error {
+	return Enqueue(ctx, q, KindAccountLocked, to, AccountLockedPayload{
+		Username:    username,
+		DisplayName: displayName,
+		LockedUntil: lockedUntil,
+	})
+}
+
+// EnqueueMagicLink queues a passwordless login link
+func EnqueueMagicLink(ctx context.Context, q jobs.Enqueuer, to, token, username, displayName string) error {
+	return Enqueue(ctx, q, KindMagicLink, to, MagicLinkPayload{
+		Token:       token,
+		Username:    username,
+		DisplayName: displayName,
+	})
+}
+
+// Register installs in q the handlers delivering every email kind through
+// emailService
+func Register(q *jobs.Queue, emailService email.EmailServiceInterface) {
+	q.Handle(JobType(KindPasswordReset), handler(func(ctx context.Context, to string, p PasswordResetPayload) error {
+		return emailService.SendPasswordResetEmail(ctx, to, p.Token, p.Username, p.DisplayName)
+	}))
+	q.Handle(JobType(KindWelcome), handler(func(ctx context.Context, to string, p WelcomePayload) error {
+		return emailService.SendWelcomeEmail(ctx, to, p.Username, p.DisplayName)
+	}))
+	q.Handle(JobType(KindEmailVerification), handler(func(ctx context.Context, to string, p EmailVerificationPayload) error {
+		return emailService.SendVerificationEmail(ctx, to, p.Token, p.Username, p.DisplayName)
+	}))
+	q.Handle(JobType(KindAccountDeletion), handler(func(ctx context.Context, to string, p AccountDeletionPayload) error {
+		return emailService.SendAccountDeletionEmail(ctx, to, p.Token, p.Username, p.DisplayName, p.DeleteAt)
+	}))
+	q.Handle(JobType(KindNewDevice), handler(func(ctx context.Context, to string, p NewDevicePayload) error {
+		return emailService.SendNewDeviceEmail(ctx, to, p.Username, p.DisplayName, p.UserAgent, p.IP)
+	}))
+	q.Handle(JobType(KindAccountLocked), handler(func(ctx context.Context, to string, p AccountLockedPayload) error {
+		return emailService.SendAccountLockedEmail(ctx, to, p.Username, p.DisplayName, p.LockedUntil)
+	}))
+	q.Handle(JobType(KindMagicLink), handler(func(ctx context.Context, to string, p MagicLinkPayload) error {
+		return emailService.SendMagicLinkEmail(ctx, to, p.Token, p.Username, p.DisplayName)
+	}))
+}
+
+// handler decodes a Message with a T payload and sends it in its locale.
+// Undecodable payloads fail without retries.
+func handler[T any](send func(ctx context.Context, to string, payload T) error) jobs.Handler {
+	return func(ctx context.Context, raw []byte) error {
+		var msg Message
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			return jobs.Permanent(fmt.Errorf("payload de email inválido: %w", err))
+		}
+		var payload T
+		if err := json.Unmarshal(msg.Data, &payload); err != nil {
+			return jobs.Permanent(fmt.Errorf("payload de email inválido: %w", err))
+		}
+		return send(email.WithLocale(ctx, msg.Locale), msg.Recipient, payload)
+	}
+}
@@ -0,0 +1,148 @@
+// Package outbox tests
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/email"
+	"gosveltekit/internal/jobs"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/telemetry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupQueue(t *testing.T, emailService email.EmailServiceInterface) (*gorm.DB, *jobs.Queue) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Job{}))
+	q := jobs.New(jobs.NewDBStore(db), jobs.Config{MaxAttempts: 2})
+	Register(q, emailService)
+	return db, q
+}
+
+func TestRegister_DeliversEveryKind(t *testing.T) {
+	mockEmailService := email.NewMockEmailService()
+	_, q := setupQueue(t, mockEmailService)
+	ctx := context.Background()
+	lockedUntil := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, EnqueuePasswordReset(ctx, q, "a@example.com", "reset-token", "a", "A"))
+	require.NoError(t, EnqueueWelcome(ctx, q, "b@example.com", "b", "B"))
+	require.NoError(t, EnqueueEmailVerification(ctx, q, "c@example.com", "verify-token", "c", "C"))
+	require.NoError(t, EnqueueAccountDeletion(ctx, q, "d@example.com", "delete-token", "d", "D", lockedUntil))
+	require.NoError(t, EnqueueNewDevice(ctx, q, "e@example.com", "e", "E", "curl", "192.0.2.1"))
+	require.NoError(t, EnqueueAccountLocked(ctx, q, "f@example.com", "f", "F", lockedUntil))
+	require.NoError(t, EnqueueMagicLink(ctx, q, "g@example.com", "login-token", "g", "G"))
+
+	ran, err := q.RunDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 7, ran)
+
+	sent := mockEmailService.GetSentEmails()
+	require.Len(t, sent, 7)
+	assert.Equal(t, email.MockEmail{Kind: "password_reset", To: "a@example.com", Token: "reset-token", Username: "a", DisplayName: "A"}, sent[0])
+	assert.Equal(t, "welcome", sent[1].Kind)
+	assert.Equal(t, "verify-token", sent[2].Token)
+	assert.True(t, lockedUntil.Equal(sent[3].DeleteAt))
+	assert.Equal(t, "192.0.2.1", sent[4].IP)
+	assert.True(t, lockedUntil.Equal(sent[5].LockedUntil))
+	assert.Equal(t, email.MockEmail{Kind: "magic_link", To: "g@example.com", Token: "login-token", Username: "g", DisplayName: "G"}, sent[6])
+
+	counts, err := q.Counts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), counts[models.JobStatusSucceeded])
+}
+
+func TestRegister_RetriesThenFails(t *testing.T) {
+	mockEmailService := email.NewMockEmailService()
+	mockEmailService.SetSendEmailError(errors.New("smtp down"))
+	db, q := setupQueue(t, mockEmailService)
+	ctx := context.Background()
+
+	require.NoError(t, EnqueuePasswordReset(ctx, q, "user@example.com", "token", "user", "User"))
+
+	// First failure schedules a retry
+	_, err := q.RunDue(ctx)
+	require.NoError(t, err)
+
+	var job models.Job
+	require.NoError(t, db.First(&job).Error)
+	assert.Equal(t, models.JobStatusPending, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Equal(t, "smtp down", job.LastError)
+	assert.True(t, job.RunAt.After(time.Now()))
+	assert.NotEmpty(t, job.Payload, "kept for the retry")
+
+	// Once due, the last attempt marks it failed
+	require.NoError(t, db.Model(&job).Update("run_at", time.Now().Add(-time.Second)).Error)
+	_, err = q.RunDue(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, db.First(&job).Error)
+	assert.Equal(t, models.JobStatusFailed, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Len(t, mockEmailService.GetSentEmails(), 2)
+}
+
+func TestRegister_InvalidPayloadFails(t *testing.T) {
+	db, q := setupQueue(t, email.NewMockEmailService())
+	ctx := context.Background()
+
+	_, err := q.Enqueue(ctx, JobType(KindWelcome), "not a message")
+	require.NoError(t, err)
+	_, err = q.RunDue(ctx)
+	require.NoError(t, err)
+
+	var job models.Job
+	require.NoError(t, db.First(&job).Error)
+	assert.Equal(t, models.JobStatusFailed, job.Status)
+	assert.Equal(t, 1, job.Attempts, "not retried")
+}
+
+func TestEnqueue_StoresRequestContext(t *testing.T) {
+	mockEmailService := email.NewMockEmailService()
+	db, q := setupQueue(t, mockEmailService)
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := telemetry.ContextWithTraceParent(logger.WithRequestID(context.Background(), "req-1"), traceParent)
+	ctx = email.WithLocale(ctx, "en")
+
+	require.NoError(t, EnqueueWelcome(ctx, q, "user@example.com", "user", "User"))
+
+	var job models.Job
+	require.NoError(t, db.First(&job).Error)
+	assert.Equal(t, "email.welcome", job.Type)
+	assert.Equal(t, "req-1", job.RequestID)
+	assert.Equal(t, traceParent, job.TraceParent)
+	assert.JSONEq(t, `{"recipient":"user@example.com","locale":"en","data":{"username":"user","display_name":"User"}}`, job.Payload)
+
+	// The email is sent under the request and in the language it was queued with
+	_, err := q.RunDue(context.Background())
+	require.NoError(t, err)
+	sent := mockEmailService.GetSentEmails()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "req-1", sent[0].RequestID)
+	assert.Equal(t, "en", sent[0].Locale)
+}
+
+func TestEnqueue_InTx(t *testing.T) {
+	db, q := setupQueue(t, email.NewMockEmailService())
+	ctx := context.Background()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		require.NoError(t, EnqueueWelcome(ctx, q.InTx(tx), "user@example.com", "user", "User"))
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+
+	var count int64
+	require.NoError(t, db.Model(&models.Job{}).Count(&count).Error)
+	assert.Zero(t, count, "rolled back with the transaction")
+}
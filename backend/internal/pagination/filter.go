@@ -0,0 +1,25 @@
+package pagination
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParseBool reads an optional boolean filter from the query parameter name
+// (e.g. "active=false"). A missing or empty parameter returns nil, meaning the
+// filter is not applied; anything other than a boolean is an error handlers
+// should answer with 400.
+func ParseBool(c *gin.Context, name string) (*bool, error) {
+	raw := strings.TrimSpace(c.Query(name))
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parâmetro %s deve ser true ou false", name)
+	}
+	return &value, nil
+}
@@ -0,0 +1,120 @@
+// Package pagination parses and validates pagination query parameters so every
+// list endpoint behaves the same way.
+package pagination
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default limits, used until SetDefaults is called
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	ErrInvalidPage     = errors.New("parâmetro page deve ser um número inteiro positivo")
+	ErrInvalidPageSize = errors.New("parâmetro page_size deve ser um número inteiro positivo")
+)
+
+var (
+	mu              sync.RWMutex
+	defaultPageSize = DefaultPageSize
+	maxPageSize     = MaxPageSize
+)
+
+// SetDefaults configures the default and maximum page size for all endpoints.
+// Non-positive values keep the built-in defaults.
+func SetDefaults(defaultSize, maxSize int) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	defaultPageSize = DefaultPageSize
+	maxPageSize = MaxPageSize
+	if maxSize > 0 {
+		maxPageSize = maxSize
+	}
+	if defaultSize > 0 {
+		defaultPageSize = defaultSize
+	}
+	if defaultPageSize > maxPageSize {
+		defaultPageSize = maxPageSize
+	}
+}
+
+// Params holds validated pagination parameters
+type Params struct {
+	Page     int `json:"page"`
+	PageSize int `json:"page_size"`
+}
+
+// Offset returns the number of rows to skip
+func (p Params) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// Limit returns the number of rows to fetch
+func (p Params) Limit() int {
+	return p.PageSize
+}
+
+// Parse reads the page and page_size query parameters.
+//
+// Missing values fall back to page 1 and the default page size, and page_size
+// is clamped to the maximum. Non-numeric, zero or negative values return an
+// error that handlers should answer with 400.
+func Parse(c *gin.Context) (Params, error) {
+	mu.RLock()
+	defaultSize, maxSize := defaultPageSize, maxPageSize
+	mu.RUnlock()
+
+	params := Params{Page: 1, PageSize: defaultSize}
+
+	if raw := c.Query("page"); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			return Params{}, ErrInvalidPage
+		}
+		params.Page = page
+	}
+
+	if raw := c.Query("page_size"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 {
+			return Params{}, ErrInvalidPageSize
+		}
+		params.PageSize = size
+	}
+
+	if params.PageSize > maxSize {
+		params.PageSize = maxSize
+	}
+
+	return params, nil
+}
+
+// Meta describes the page returned by a list endpoint
+type Meta struct {
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// NewMeta builds the response metadata for params and the total number of rows
+func NewMeta(params Params, total int64) Meta {
+	totalPages := 0
+	if params.PageSize > 0 {
+		totalPages = int((total + int64(params.PageSize) - 1) / int64(params.PageSize))
+	}
+	return Meta{
+		Page:       params.Page,
+		PageSize:   params.PageSize,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+}
@@ -0,0 +1,138 @@
+// Package pagination tests
+package pagination
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func parseQuery(query string) (Params, error) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest(http.MethodGet, "/items?"+query, nil)
+	return Parse(c)
+}
+
+func TestParse(t *testing.T) {
+	SetDefaults(10, 50)
+	defer SetDefaults(0, 0)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected Params
+		wantErr  error
+	}{
+		{name: "defaults", query: "", expected: Params{Page: 1, PageSize: 10}},
+		{name: "explicit values", query: "page=3&page_size=25", expected: Params{Page: 3, PageSize: 25}},
+		{name: "clamped to max", query: "page_size=500", expected: Params{Page: 1, PageSize: 50}},
+		{name: "negative page", query: "page=-1", wantErr: ErrInvalidPage},
+		{name: "zero page size", query: "page_size=0", wantErr: ErrInvalidPageSize},
+		{name: "non-numeric page", query: "page=abc", wantErr: ErrInvalidPage},
+		{name: "non-numeric page size", query: "page_size=ten", wantErr: ErrInvalidPageSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := parseQuery(tt.query)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, params)
+		})
+	}
+}
+
+func TestSetDefaults_DefaultNeverExceedsMax(t *testing.T) {
+	SetDefaults(200, 50)
+	defer SetDefaults(0, 0)
+
+	params, err := parseQuery("")
+	assert.NoError(t, err)
+	assert.Equal(t, 50, params.PageSize)
+}
+
+func TestParams_OffsetAndMeta(t *testing.T) {
+	params := Params{Page: 3, PageSize: 20}
+	assert.Equal(t, 40, params.Offset())
+	assert.Equal(t, 20, params.Limit())
+
+	meta := NewMeta(params, 41)
+	assert.Equal(t, Meta{Page: 3, PageSize: 20, Total: 41, TotalPages: 3}, meta)
+}
+
+func TestParseSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	fields := SortFields{"created_at": "created_at", "username": "username"}
+	defaultSort := Sort{Column: "created_at", Desc: true}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected Sort
+		wantErr  error
+	}{
+		{name: "default", query: "", expected: defaultSort},
+		{name: "ascending", query: "sort=username", expected: Sort{Column: "username"}},
+		{name: "descending", query: "sort=-username", expected: Sort{Column: "username", Desc: true}},
+		{name: "unknown key", query: "sort=password_hash", wantErr: ErrInvalidSort},
+		{name: "raw SQL", query: "sort=" + url.QueryEscape("username; DROP TABLE users"), wantErr: ErrInvalidSort},
+		{name: "only a dash", query: "sort=-", wantErr: ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request, _ = http.NewRequest(http.MethodGet, "/items?"+tt.query, nil)
+
+			sort, err := ParseSort(c, fields, defaultSort)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, sort)
+		})
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	yes, no := true, false
+	tests := []struct {
+		name     string
+		query    string
+		expected *bool
+		wantErr  bool
+	}{
+		{name: "missing", query: "", expected: nil},
+		{name: "empty", query: "active=", expected: nil},
+		{name: "true", query: "active=true", expected: &yes},
+		{name: "false", query: "active=0", expected: &no},
+		{name: "invalid", query: "active=maybe", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request, _ = http.NewRequest(http.MethodGet, "/items?"+tt.query, nil)
+
+			value, err := ParseBool(c, "active")
+			if tt.wantErr {
+				assert.EqualError(t, err, "parâmetro active deve ser true ou false")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
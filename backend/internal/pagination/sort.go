@@ -0,0 +1,46 @@
+package pagination
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrInvalidSort is returned when the sort parameter names a key that is not
+// in the endpoint's whitelist
+var ErrInvalidSort = errors.New("parâmetro sort inválido")
+
+// SortFields maps the sort keys an endpoint accepts to the column they order
+// by. Only keys in the map are accepted, so user input never reaches the
+// ORDER BY clause directly.
+type SortFields map[string]string
+
+// Sort is a validated sort order
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+// ParseSort reads the sort query parameter: a key from fields, optionally
+// prefixed with "-" for descending order (e.g. "sort=-created_at"). A missing
+// parameter falls back to defaultSort. Unknown keys return ErrInvalidSort,
+// which handlers should answer with 400.
+func ParseSort(c *gin.Context, fields SortFields, defaultSort Sort) (Sort, error) {
+	raw := strings.TrimSpace(c.Query("sort"))
+	if raw == "" {
+		return defaultSort, nil
+	}
+
+	desc := false
+	if strings.HasPrefix(raw, "-") {
+		desc = true
+		raw = raw[1:]
+	}
+
+	column, ok := fields[raw]
+	if !ok {
+		return Sort{}, ErrInvalidSort
+	}
+	return Sort{Column: column, Desc: desc}, nil
+}
@@ -0,0 +1,28 @@
+package query
+
+import (
+	"gorm.io/gorm"
+)
+
+// Each calls fn for every row matched by db, scanning them one at a time from
+// a database cursor instead of loading the whole result in memory. It stops
+// at the first error returned by fn. db must have its model, conditions and
+// order set.
+func Each[T any](db *gorm.DB, fn func(*T) error) error {
+	rows, err := db.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item T
+		if err := db.ScanRows(rows, &item); err != nil {
+			return err
+		}
+		if err := fn(&item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
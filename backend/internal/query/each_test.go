@@ -0,0 +1,47 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"gosveltekit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestEach(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}))
+	for i := range 250 {
+		name := fmt.Sprintf("user%03d", i)
+		require.NoError(t, db.Create(&models.User{Username: name, Email: name + "@example.com", PasswordHash: "x"}).Error)
+	}
+
+	var seen []string
+	err = Each(db.Model(&models.User{}).Order("username DESC"), func(u *models.User) error {
+		seen = append(seen, u.Username)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, seen, 250)
+	assert.Equal(t, "user249", seen[0])
+	assert.Equal(t, "user000", seen[249])
+
+	// An error from fn stops the scan
+	stop := errors.New("stop")
+	calls := 0
+	err = Each(db.Model(&models.User{}), func(u *models.User) error {
+		calls++
+		if calls == 3 {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,53 @@
+// Package query holds GORM query helpers shared by the adapters and
+// repositories.
+package query
+
+import (
+	"errors"
+
+	"gosveltekit/internal/pagination"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidPage is returned for a negative offset or a non-positive limit
+var ErrInvalidPage = errors.New("paginação inválida")
+
+// Options selects one page of a list query
+type Options struct {
+	Offset int
+	Limit  int
+	// Sort must come from pagination.ParseSort or be a constant, never raw
+	// user input. The zero value orders by id only.
+	Sort pagination.Sort
+}
+
+// PageOptions builds the Options for validated pagination and sort params
+func PageOptions(params pagination.Params, sort pagination.Sort) Options {
+	return Options{Offset: params.Offset(), Limit: params.Limit(), Sort: sort}
+}
+
+// Paginate counts the rows matched by db and fetches the page described by
+// opts, ordered by opts.Sort and then id so pages never overlap. db must have
+// its model and conditions set, e.g. db.Model(&models.User{}).Where(...).
+func Paginate[T any](db *gorm.DB, opts Options) ([]T, int64, error) {
+	if opts.Offset < 0 || opts.Limit < 1 {
+		return nil, 0, ErrInvalidPage
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	page := db
+	if opts.Sort.Column != "" {
+		page = page.Order(clause.OrderByColumn{Column: clause.Column{Name: opts.Sort.Column}, Desc: opts.Sort.Desc})
+	}
+	var items []T
+	if err := page.Order("id").Offset(opts.Offset).Limit(opts.Limit).Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
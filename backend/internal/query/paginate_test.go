@@ -0,0 +1,55 @@
+package query
+
+import (
+	"fmt"
+	"testing"
+
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/pagination"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPaginate(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}))
+
+	// Two users share each role, so id breaks the ties
+	for i, role := range []string{"user", "admin", "user", "admin", "user"} {
+		name := fmt.Sprintf("user%d", i+1)
+		require.NoError(t, db.Create(&models.User{Username: name, Email: name + "@example.com", PasswordHash: "x", Role: role}).Error)
+	}
+	usernames := func(users []models.User) []string {
+		names := make([]string, len(users))
+		for i, u := range users {
+			names[i] = u.Username
+		}
+		return names
+	}
+	byRole := pagination.Sort{Column: "role", Desc: true}
+
+	users, total, err := Paginate[models.User](db.Model(&models.User{}), PageOptions(pagination.Params{Page: 1, PageSize: 2}, byRole))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.Equal(t, []string{"user1", "user3"}, usernames(users))
+
+	users, total, err = Paginate[models.User](db.Model(&models.User{}), PageOptions(pagination.Params{Page: 2, PageSize: 2}, byRole))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.Equal(t, []string{"user5", "user2"}, usernames(users))
+
+	// The count honours the conditions, not the page
+	users, total, err = Paginate[models.User](db.Model(&models.User{}).Where("role = ?", "admin"), Options{Offset: 1, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []string{"user4"}, usernames(users))
+
+	_, _, err = Paginate[models.User](db.Model(&models.User{}), Options{Limit: 0})
+	assert.ErrorIs(t, err, ErrInvalidPage)
+	_, _, err = Paginate[models.User](db.Model(&models.User{}), Options{Offset: -1, Limit: 1})
+	assert.ErrorIs(t, err, ErrInvalidPage)
+}
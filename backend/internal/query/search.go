@@ -0,0 +1,30 @@
+package query
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// likeEscaper escapes the LIKE wildcards of a search term. The escape
+// character is !, since a backslash needs escaping itself in MySQL literals.
+var likeEscaper = strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+
+// Search narrows db to the rows where any of columns contains term, ignoring
+// case. Wildcards in term match literally, and an empty term matches every
+// row. columns are trusted names, never user input.
+func Search(db *gorm.DB, term string, columns ...string) *gorm.DB {
+	term = strings.TrimSpace(term)
+	if term == "" || len(columns) == 0 {
+		return db
+	}
+
+	pattern := "%" + likeEscaper.Replace(strings.ToLower(term)) + "%"
+	conditions := make([]string, len(columns))
+	args := make([]any, len(columns))
+	for i, column := range columns {
+		conditions[i] = "LOWER(" + column + ") LIKE ? ESCAPE '!'"
+		args[i] = pattern
+	}
+	return db.Where(strings.Join(conditions, " OR "), args...)
+}
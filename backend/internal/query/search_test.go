@@ -0,0 +1,38 @@
+package query
+
+import (
+	"testing"
+
+	"gosveltekit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSearch(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}))
+	for _, user := range []models.User{
+		{Username: "alice", Email: "alice@example.com", DisplayName: "Alice Liddell"},
+		{Username: "bob", Email: "bob@corp.example", DisplayName: "Bob 100%!"},
+		{Username: "carol_x", Email: "carol@example.com", DisplayName: "Carol"},
+	} {
+		user.PasswordHash = "x"
+		require.NoError(t, db.Create(&user).Error)
+	}
+	search := func(term string) []string {
+		var names []string
+		require.NoError(t, Search(db.Model(&models.User{}), term, "username", "email", "display_name").Order("id").Pluck("username", &names).Error)
+		return names
+	}
+
+	assert.Equal(t, []string{"alice", "bob", "carol_x"}, search(""))
+	assert.Equal(t, []string{"alice"}, search("LIDDELL"), "case-insensitive")
+	assert.Equal(t, []string{"bob"}, search("corp"), "any column")
+	assert.Equal(t, []string{"bob"}, search("100%!"), "wildcards match literally")
+	assert.Equal(t, []string{"carol_x"}, search("l_x"))
+	assert.Empty(t, search("zed"))
+}
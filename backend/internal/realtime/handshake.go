@@ -0,0 +1,58 @@
+package realtime
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Handshake errors returned by Hub.Serve. websocket.Accept answers these
+// itself in plain text; checking first leaves the response to the caller.
+var (
+	ErrNotWebSocket     = errors.New("esta rota aceita apenas conexões WebSocket")
+	ErrBadHandshake     = errors.New("handshake WebSocket inválido")
+	ErrOriginNotAllowed = errors.New("origem não permitida")
+)
+
+// checkHandshake validates the WebSocket handshake in r. Browsers send
+// cookies on cross-site WebSocket handshakes and CORS doesn't apply, so an
+// Origin other than the server's own must pass checkOrigin.
+func checkHandshake(r *http.Request, checkOrigin func(string) bool) error {
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		return ErrNotWebSocket
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return ErrBadHandshake
+	}
+	key := strings.TrimSpace(r.Header.Get("Sec-WebSocket-Key"))
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return ErrBadHandshake
+	}
+	if origin := r.Header.Get("Origin"); origin != "" && !sameOrigin(origin, r) &&
+		(checkOrigin == nil || !checkOrigin(origin)) {
+		return ErrOriginNotAllowed
+	}
+	return nil
+}
+
+// headerContains reports whether the comma-separated header name of h lists
+// token, case-insensitively
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether origin names the host the request was sent to
+func sameOrigin(origin string, r *http.Request) bool {
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,406 @@
+// Package realtime pushes events to the browsers of signed-in users over
+// WebSocket. Services publish through a Publisher; the Hub keeps the
+// connections of this process, so with several instances behind a load
+// balancer an event only reaches the clients connected to the instance that
+// published it.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/logger"
+
+	"github.com/coder/websocket"
+)
+
+// Events pushed to users
+const (
+	// EventSessionRevoked: one of the user's sessions was ended (logout
+	// elsewhere, revoked from the session list...). Data has the reason.
+	EventSessionRevoked = "session.revoked"
+	// EventPasswordChanged: the password was changed or reset
+	EventPasswordChanged = "password.changed"
+)
+
+// Defaults of Config
+const (
+	DefaultPingInterval          = 30 * time.Second
+	DefaultPongTimeout           = 10 * time.Second
+	DefaultSendBuffer            = 16
+	DefaultMaxConnectionsPerUser = 10
+)
+
+// Close codes sent by the hub
+const (
+	CloseNormal         = websocket.StatusNormalClosure
+	CloseGoingAway      = websocket.StatusGoingAway
+	ClosePolicyViolated = websocket.StatusPolicyViolation
+	// CloseSessionEnded tells the client its session expired or was revoked:
+	// it must authenticate again before reconnecting
+	CloseSessionEnded websocket.StatusCode = 4001
+)
+
+// maxMessageSize bounds the messages read from clients, which only send
+// control frames in practice
+const maxMessageSize = 64 << 10
+
+// writeTimeout bounds every message written, so a stuck client can't hold a
+// writer forever
+const writeTimeout = 10 * time.Second
+
+// sessionRecheck is how often the session of a connection is checked again
+// with Config.Sessions, so idle timeouts and deactivated users end it too
+const sessionRecheck = 5 * time.Minute
+
+var (
+	ErrClosed             = errors.New("servidor em desligamento")
+	ErrTooManyConnections = errors.New("conexões simultâneas demais")
+)
+
+// Event is the JSON message sent to the client
+type Event struct {
+	Type string         `json:"type"`
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// Publisher is what services need from the hub
+type Publisher interface {
+	// Publish sends event to every connection of userID, without waiting
+	Publish(ctx context.Context, userID string, event Event)
+	// CheckSessions closes the connections of userID whose session is no
+	// longer valid, after sessions were revoked
+	CheckSessions(ctx context.Context, userID string)
+}
+
+// SessionChecker validates a session without touching it, as
+// auth.AuthManager.CheckSession
+type SessionChecker interface {
+	CheckSession(ctx context.Context, sessionID string) (*auth.Session, error)
+}
+
+// Config tunes a Hub. Zero values use the defaults.
+type Config struct {
+	PingInterval          time.Duration
+	PongTimeout           time.Duration // silence tolerated after a ping
+	SendBuffer            int           // events queued per connection; a client that falls further behind is dropped
+	MaxConnectionsPerUser int
+	// CheckOrigin accepts cross-origin handshakes; same-origin ones are
+	// always accepted and, when nil, only those
+	CheckOrigin func(origin string) bool
+	// Sessions rechecks sessions on CheckSessions and when they reach their
+	// expiry. When nil, CheckSessions closes every connection of the user.
+	Sessions SessionChecker
+}
+
+// Hub holds the WebSocket connections of the process
+type Hub struct {
+	cfg Config
+
+	mu      sync.Mutex
+	clients map[string]map[*client]struct{}
+	closed  bool
+}
+
+// client is one connection of a user
+type client struct {
+	conn      *websocket.Conn
+	userID    string
+	sessionID string
+	send      chan []byte
+
+	stopOnce    sync.Once
+	stopped     chan struct{}
+	closeCode   websocket.StatusCode
+	closeReason string
+}
+
+// NewHub creates a Hub
+func NewHub(cfg Config) *Hub {
+	if cfg.PingInterval <= 0 {
+		cfg.PingInterval = DefaultPingInterval
+	}
+	if cfg.PongTimeout <= 0 {
+		cfg.PongTimeout = DefaultPongTimeout
+	}
+	if cfg.SendBuffer <= 0 {
+		cfg.SendBuffer = DefaultSendBuffer
+	}
+	if cfg.MaxConnectionsPerUser <= 0 {
+		cfg.MaxConnectionsPerUser = DefaultMaxConnectionsPerUser
+	}
+	return &Hub{cfg: cfg, clients: make(map[string]map[*client]struct{})}
+}
+
+// Serve upgrades r to a WebSocket for the user authenticated by sessionID
+// and blocks until the connection ends, which happens at the latest when the
+// session expires. On failure nothing was written to w: ErrNotWebSocket,
+// ErrBadHandshake, ErrOriginNotAllowed, ErrTooManyConnections and ErrClosed
+// are for the caller to answer.
+func (h *Hub) Serve(w http.ResponseWriter, r *http.Request, userID, sessionID string, expiresAt time.Time) error {
+	c := &client{
+		userID:    userID,
+		sessionID: sessionID,
+		send:      make(chan []byte, h.cfg.SendBuffer),
+		stopped:   make(chan struct{}),
+	}
+	// The slot is taken before the handshake so concurrent ones can't exceed
+	// the limit
+	if err := h.register(c); err != nil {
+		return err
+	}
+	defer h.unregister(c)
+
+	if err := checkHandshake(r, h.cfg.CheckOrigin); err != nil {
+		return err
+	}
+	// The server's deadlines no longer apply once hijacked
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+	// The origin was checked above, with CheckOrigin rather than patterns
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		return err
+	}
+	conn.SetReadLimit(maxMessageSize)
+	c.conn = conn
+
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		h.writeLoop(c, expiresAt)
+	}()
+	go h.keepalive(c)
+
+	// Clients have nothing to say: data messages are read and ignored. The
+	// library answers pings and close frames, and closes the connection on
+	// protocol errors and messages over the limit.
+	for {
+		if _, _, err := conn.Read(context.Background()); err != nil {
+			break
+		}
+	}
+	c.stop(CloseNormal, "")
+	<-writerDone
+	_ = conn.CloseNow()
+	return nil
+}
+
+// keepalive pings the client every PingInterval and drops the connection,
+// without a close handshake, when the pong doesn't arrive within PongTimeout
+func (h *Hub) keepalive(c *client) {
+	ping := time.NewTicker(h.cfg.PingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ping.C:
+			ctx, cancel := context.WithTimeout(context.Background(), h.cfg.PongTimeout)
+			err := c.conn.Ping(ctx)
+			cancel()
+			if err != nil {
+				_ = c.conn.CloseNow()
+				return
+			}
+		case <-c.stopped:
+			return
+		}
+	}
+}
+
+// write sends message to c within writeTimeout
+func (c *client) write(message []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	return c.conn.Write(ctx, websocket.MessageText, message)
+}
+
+// writeLoop sends events until the client stops, then closes the connection
+func (h *Hub) writeLoop(c *client, expiresAt time.Time) {
+	var expired <-chan time.Time
+	if !expiresAt.IsZero() {
+		expired = time.After(h.nextCheck(expiresAt))
+	}
+
+	for running := true; running; {
+		select {
+		case message := <-c.send:
+			if err := c.write(message); err != nil {
+				c.stop(CloseNormal, "")
+			}
+		case <-expired:
+			// The session may have been extended since the handshake
+			next, ok := h.sessionExpiry(c)
+			if !ok {
+				c.stop(CloseSessionEnded, "sessão expirada")
+				continue
+			}
+			expired = time.After(h.nextCheck(next))
+		case <-c.stopped:
+			running = false
+		}
+	}
+
+	// Events published right before the stop (session.revoked) still go out
+	for drained := false; !drained; {
+		select {
+		case message := <-c.send:
+			drained = c.write(message) != nil
+		default:
+			drained = true
+		}
+	}
+	// Waits for the client's close frame, for a few seconds at most
+	_ = c.conn.Close(c.closeCode, c.closeReason)
+}
+
+// nextCheck returns how long to wait before checking a session expiring at
+// expiresAt
+func (h *Hub) nextCheck(expiresAt time.Time) time.Duration {
+	wait := time.Until(expiresAt)
+	if h.cfg.Sessions != nil {
+		wait = min(wait, sessionRecheck)
+	}
+	// Within the clock skew leeway a session is still valid past its expiry
+	return max(wait, time.Second)
+}
+
+// sessionExpiry returns when the session of c expires now, false once it
+// ended. Storage failures keep the connection until the next check.
+func (h *Hub) sessionExpiry(c *client) (time.Time, bool) {
+	if h.cfg.Sessions == nil {
+		return time.Time{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	session, err := h.cfg.Sessions.CheckSession(ctx, c.sessionID)
+	if err == nil {
+		return session.ExpiresAt, true
+	}
+	if sessionEnded(err) {
+		return time.Time{}, false
+	}
+	logger.Warn("Erro ao verificar sessão de conexão WebSocket", "error", err, "user_id", c.userID)
+	return time.Now().Add(sessionRecheck), true
+}
+
+func sessionEnded(err error) bool {
+	return errors.Is(err, auth.ErrSessionNotFound) || errors.Is(err, auth.ErrSessionExpired) || errors.Is(err, auth.ErrUserNotActive)
+}
+
+// stop ends the connection with code; only the first call counts
+func (c *client) stop(code websocket.StatusCode, reason string) {
+	c.stopOnce.Do(func() {
+		c.closeCode, c.closeReason = code, reason
+		close(c.stopped)
+	})
+}
+
+func (h *Hub) register(c *client) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrClosed
+	}
+	conns := h.clients[c.userID]
+	if len(conns) >= h.cfg.MaxConnectionsPerUser {
+		return ErrTooManyConnections
+	}
+	if conns == nil {
+		conns = make(map[*client]struct{})
+		h.clients[c.userID] = conns
+	}
+	conns[c] = struct{}{}
+	return nil
+}
+
+func (h *Hub) unregister(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients[c.userID], c)
+	if len(h.clients[c.userID]) == 0 {
+		delete(h.clients, c.userID)
+	}
+}
+
+// userClients returns a copy of the connections of userID
+func (h *Hub) userClients(userID string) []*client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clients := make([]*client, 0, len(h.clients[userID]))
+	for c := range h.clients[userID] {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// Publish implements Publisher. A connection whose buffer is full is closed
+// rather than slowing down the publisher.
+func (h *Hub) Publish(ctx context.Context, userID string, event Event) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao serializar evento em tempo real", "error", err, "type", event.Type)
+		return
+	}
+	for _, c := range h.userClients(userID) {
+		select {
+		case c.send <- message:
+		default:
+			logger.FromContext(ctx).Warn("Conexão WebSocket lenta encerrada", "user_id", userID, "type", event.Type)
+			c.stop(ClosePolicyViolated, "cliente lento")
+		}
+	}
+}
+
+// CheckSessions implements Publisher, closing with CloseSessionEnded
+func (h *Hub) CheckSessions(ctx context.Context, userID string) {
+	for _, c := range h.userClients(userID) {
+		if h.cfg.Sessions != nil {
+			_, err := h.cfg.Sessions.CheckSession(ctx, c.sessionID)
+			if err == nil {
+				continue
+			}
+			if !sessionEnded(err) {
+				logger.FromContext(ctx).Warn("Erro ao verificar sessão de conexão WebSocket", "error", err, "user_id", userID)
+				continue
+			}
+		}
+		c.stop(CloseSessionEnded, "sessão encerrada")
+	}
+}
+
+// Connections returns the number of open connections
+func (h *Hub) Connections() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for _, conns := range h.clients {
+		n += len(conns)
+	}
+	return n
+}
+
+// Close refuses new connections and closes the open ones with
+// CloseGoingAway, so clients reconnect to another instance. Meant for
+// http.Server.RegisterOnShutdown: Shutdown doesn't wait for hijacked
+// connections, the handlers blocked in Serve return once they are closed.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	var clients []*client
+	for _, conns := range h.clients {
+		for c := range conns {
+			clients = append(clients, c)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, c := range clients {
+		c.stop(CloseGoingAway, "servidor reiniciando")
+	}
+}
@@ -0,0 +1,236 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/auth"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSessions ends the sessions listed in ended
+type fakeSessions struct {
+	mu    sync.Mutex
+	ended map[string]bool
+}
+
+func (f *fakeSessions) end(sessionID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ended[sessionID] = true
+}
+
+func (f *fakeSessions) CheckSession(ctx context.Context, sessionID string) (*auth.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.ended[sessionID] {
+		return nil, auth.ErrSessionNotFound
+	}
+	return &auth.Session{ID: sessionID, ExpiresAt: time.Now().Add(time.Hour)}, nil
+}
+
+// serveHub serves hub on a test server, authenticating the user and session
+// named in the query string. Serve errors are sent on the returned channel.
+func serveHub(t *testing.T, hub *Hub, expiresAt time.Time) (*httptest.Server, <-chan error) {
+	t.Helper()
+	errs := make(chan error, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := hub.Serve(w, r, r.URL.Query().Get("user"), r.URL.Query().Get("session"), expiresAt); err != nil {
+			errs <- err
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, errs
+}
+
+// dial opens a client connection to server with the given extra headers. The
+// connection is nil when the handshake was refused.
+func dial(t *testing.T, server *httptest.Server, query string, header http.Header) (*websocket.Conn, *http.Response) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	conn, resp, err := websocket.Dial(ctx, server.URL+"/ws?"+query, &websocket.DialOptions{HTTPHeader: header})
+	if err != nil {
+		require.NotNil(t, resp, "dial: %v", err)
+		return nil, resp
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+	return conn, resp
+}
+
+// readEvent reads the next event sent to c
+func readEvent(t *testing.T, c *websocket.Conn) Event {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, message, err := c.Read(ctx)
+	require.NoError(t, err)
+	var event Event
+	require.NoError(t, json.Unmarshal(message, &event))
+	return event
+}
+
+// readClose reads until the close frame and returns its code
+func readClose(t *testing.T, c *websocket.Conn) websocket.StatusCode {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	for {
+		if _, _, err := c.Read(ctx); err != nil {
+			return websocket.CloseStatus(err)
+		}
+	}
+}
+
+func waitConnections(t *testing.T, hub *Hub, n int) {
+	t.Helper()
+	assert.Eventually(t, func() bool { return hub.Connections() == n }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestHub_Publish(t *testing.T) {
+	hub := NewHub(Config{})
+	server, _ := serveHub(t, hub, time.Now().Add(time.Hour))
+
+	first, _ := dial(t, server, "user=1&session=a", nil)
+	require.NotNil(t, first)
+	second, _ := dial(t, server, "user=1&session=b", nil)
+	other, _ := dial(t, server, "user=2&session=c", nil)
+	waitConnections(t, hub, 3)
+
+	hub.Publish(context.Background(), "1", Event{Type: EventPasswordChanged})
+	assert.Equal(t, Event{Type: EventPasswordChanged}, readEvent(t, first))
+	assert.Equal(t, Event{Type: EventPasswordChanged}, readEvent(t, second))
+
+	hub.Publish(context.Background(), "2", Event{Type: EventSessionRevoked, Data: map[string]any{"reason": "logout"}})
+	assert.Equal(t, Event{Type: EventSessionRevoked, Data: map[string]any{"reason": "logout"}}, readEvent(t, other))
+
+	// Closed by the client
+	require.NoError(t, first.Close(CloseNormal, ""))
+	waitConnections(t, hub, 2)
+}
+
+func TestHub_Keepalive(t *testing.T) {
+	hub := NewHub(Config{PingInterval: 50 * time.Millisecond, PongTimeout: 50 * time.Millisecond})
+	server, _ := serveHub(t, hub, time.Now().Add(time.Hour))
+
+	alive, _ := dial(t, server, "user=1&session=a", nil)
+	dial(t, server, "user=1&session=b", nil) // never reads, so never answers pings
+	waitConnections(t, hub, 2)
+
+	// Reading answers pings
+	go func() {
+		for {
+			if _, _, err := alive.Read(context.Background()); err != nil {
+				return
+			}
+		}
+	}()
+	waitConnections(t, hub, 1)
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, 1, hub.Connections())
+}
+
+func TestHub_CheckSessions(t *testing.T) {
+	sessions := &fakeSessions{ended: map[string]bool{}}
+	hub := NewHub(Config{Sessions: sessions})
+	server, _ := serveHub(t, hub, time.Now().Add(time.Hour))
+
+	kept, _ := dial(t, server, "user=1&session=kept", nil)
+	revoked, _ := dial(t, server, "user=1&session=revoked", nil)
+	waitConnections(t, hub, 2)
+
+	sessions.end("revoked")
+	hub.Publish(context.Background(), "1", Event{Type: EventSessionRevoked})
+	hub.CheckSessions(context.Background(), "1")
+
+	// The event published before the check still reaches the revoked session
+	assert.Equal(t, EventSessionRevoked, readEvent(t, revoked).Type)
+	assert.Equal(t, CloseSessionEnded, readClose(t, revoked))
+	assert.Equal(t, EventSessionRevoked, readEvent(t, kept).Type)
+	waitConnections(t, hub, 1)
+}
+
+func TestHub_SessionExpiry(t *testing.T) {
+	sessions := &fakeSessions{ended: map[string]bool{"a": true}}
+	hub := NewHub(Config{Sessions: sessions})
+	server, _ := serveHub(t, hub, time.Now())
+
+	c, _ := dial(t, server, "user=1&session=a", nil)
+	assert.Equal(t, CloseSessionEnded, readClose(t, c))
+	waitConnections(t, hub, 0)
+}
+
+func TestHub_Close(t *testing.T) {
+	hub := NewHub(Config{})
+	server, errs := serveHub(t, hub, time.Now().Add(time.Hour))
+
+	c, _ := dial(t, server, "user=1&session=a", nil)
+	waitConnections(t, hub, 1)
+	hub.Close()
+	assert.Equal(t, CloseGoingAway, readClose(t, c))
+	waitConnections(t, hub, 0)
+
+	rejected, resp := dial(t, server, "user=1&session=a", nil)
+	assert.Nil(t, rejected)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.ErrorIs(t, <-errs, ErrClosed)
+}
+
+func TestHub_Rejected(t *testing.T) {
+	hub := NewHub(Config{
+		MaxConnectionsPerUser: 1,
+		CheckOrigin:           func(origin string) bool { return origin == "https://app.example.com" },
+	})
+	server, errs := serveHub(t, hub, time.Now().Add(time.Hour))
+
+	c, _ := dial(t, server, "user=1&session=a", http.Header{"Origin": {"https://app.example.com"}})
+	require.NotNil(t, c)
+	waitConnections(t, hub, 1)
+
+	tests := []struct {
+		name   string
+		query  string
+		header http.Header
+		want   error
+	}{
+		{"limit", "user=1&session=b", nil, ErrTooManyConnections},
+		{"origin", "user=2&session=c", http.Header{"Origin": {"https://evil.example.com"}}, ErrOriginNotAllowed},
+		{"version", "user=2&session=c", http.Header{"Sec-Websocket-Version": {"8"}}, ErrBadHandshake},
+		{"key", "user=2&session=c", http.Header{"Sec-Websocket-Key": {"short"}}, ErrBadHandshake},
+		{"not upgrade", "user=2&session=c", http.Header{"Upgrade": {"h2c"}}, ErrNotWebSocket},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Built by hand: the client only sends valid handshakes
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/ws?"+tt.query, nil)
+			require.NoError(t, err)
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Sec-WebSocket-Version", "13")
+			req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			err = <-errs
+			assert.True(t, errors.Is(err, tt.want), "got %v", err)
+		})
+	}
+
+	// Same-origin handshakes don't need CheckOrigin
+	same, _ := dial(t, server, "user=2&session=d", http.Header{"Origin": {server.URL}})
+	assert.NotNil(t, same)
+}
@@ -2,11 +2,65 @@
 package repository
 
 import (
+	"context"
+	"errors"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/database"
 	"gosveltekit/internal/models"
+	"gosveltekit/internal/pagination"
+	"gosveltekit/internal/query"
+	"gosveltekit/internal/tenant"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNotFound is returned when no user matches
+	ErrNotFound = errors.New("user not found")
+	// ErrLastAdmin is returned when a role change would leave no active admin
+	ErrLastAdmin = errors.New("the last active admin cannot be demoted")
+	// ErrUsernameTaken is returned when another user of the tenant has the username
+	ErrUsernameTaken = errors.New("username already exists")
+	// ErrEmailTaken is returned when another user of the tenant has the email
+	ErrEmailTaken = errors.New("email already exists")
+	// ErrNotScheduled is returned when cancelling the deletion of an account
+	// that isn't scheduled for deletion
+	ErrNotScheduled = errors.New("account is not scheduled for deletion")
 )
 
+// UserSortFields are the sort keys accepted when listing users
+var UserSortFields = pagination.SortFields{
+	"id":         "id",
+	"created_at": "created_at",
+	"username":   "username",
+	"email":      "email",
+	"last_login": "last_login",
+}
+
+// DefaultUserSort lists the newest users first
+var DefaultUserSort = pagination.Sort{Column: "created_at", Desc: true}
+
+// UserFilter narrows a user listing. Zero values match every user.
+type UserFilter struct {
+	Search string // case-insensitive substring of the username, email or display name
+	Role   string
+	Active *bool
+}
+
+// apply adds the conditions of the filter to db
+func (f UserFilter) apply(db *gorm.DB) *gorm.DB {
+	db = query.Search(db, f.Search, "username", "email", "display_name")
+	if f.Role != "" {
+		db = db.Where("role = ?", f.Role)
+	}
+	if f.Active != nil {
+		db = db.Where("active = ?", *f.Active)
+	}
+	return db
+}
+
 // UserRepository provides access to users in the database
 type UserRepository struct {
 	db *gorm.DB
@@ -21,16 +75,23 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 func (r *UserRepository) FindByID(id uint) (*models.User, error) {
 	var user models.User
 	if err := r.db.First(&user, id).Error; err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 	return &user, nil
 }
 
+// ResolveID returns the primary key of the user identified by id, either the
+// integer primary key or the user's UUID (see models.ResolveUserID)
+func (r *UserRepository) ResolveID(ctx context.Context, id string) (uint, error) {
+	resolved, err := models.ResolveUserID(database.Conn(ctx, r.db), id)
+	return resolved, notFound(err)
+}
+
 // FindByEmail finds a user by their email
 func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
 	var user models.User
 	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 	return &user, nil
 }
@@ -39,7 +100,7 @@ func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
 func (r *UserRepository) FindByResetToken(token string) (*models.User, error) {
 	var user models.User
 	if err := r.db.Where("reset_token = ?", token).First(&user).Error; err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 	return &user, nil
 }
@@ -48,7 +109,7 @@ func (r *UserRepository) FindByResetToken(token string) (*models.User, error) {
 func (r *UserRepository) FindByUsername(username string) (*models.User, error) {
 	var user models.User
 	if err := r.db.Where("username = ?", username).First(&user).Error; err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 	return &user, nil
 }
@@ -63,11 +124,57 @@ func (r *UserRepository) FindUsersWithResetTokens() ([]*models.User, error) {
 	return users, nil
 }
 
+// List returns a page of the users matching filter ordered by sort, along
+// with the total count of matches. sort must come from pagination.ParseSort
+// with UserSortFields.
+func (r *UserRepository) List(ctx context.Context, filter UserFilter, params pagination.Params, sort pagination.Sort) ([]models.User, int64, error) {
+	// Read-heavy and tolerant of replication lag
+	db := database.Conn(ctx, r.db).Clauses(database.ReadReplica()).Model(&models.User{})
+	return query.Paginate[models.User](filter.apply(db), query.PageOptions(params, sort))
+}
+
+// Each calls fn for every user ordered by sort, streaming them from the
+// database so the result never has to fit in memory. sort must come from
+// pagination.ParseSort with UserSortFields.
+func (r *UserRepository) Each(ctx context.Context, sort pagination.Sort, fn func(*models.User) error) error {
+	db := database.Conn(ctx, r.db).Clauses(database.ReadReplica()).Model(&models.User{}).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: sort.Column}, Desc: sort.Desc}).
+		Order("id")
+	return query.Each(db, fn)
+}
+
 // Create creates a new user in the database
 func (r *UserRepository) Create(user *models.User) error {
 	return r.db.Create(user).Error
 }
 
+// CreateUser creates the user in the tenant of ctx. Another user of the
+// tenant with the same username or email gives ErrUsernameTaken or
+// ErrEmailTaken.
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	user.TenantID = tenant.FromContext(ctx)
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		if err := tx.Model(&models.User{}).
+			Where("tenant_id = ? AND username = ?", user.TenantID, user.Username).
+			Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrUsernameTaken
+		}
+		if err := tx.Model(&models.User{}).
+			Where("tenant_id = ? AND email = ?", user.TenantID, user.Email).
+			Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrEmailTaken
+		}
+		return tx.Create(user).Error
+	})
+}
+
 // Update saves changes to a user in the database
 func (r *UserRepository) Update(user *models.User) error {
 	// Validate required fields
@@ -82,3 +189,138 @@ func (r *UserRepository) Update(user *models.User) error {
 	}
 	return r.db.Save(user).Error
 }
+
+// UpdateRole sets the role of a user and returns the previous one. Demoting an
+// admin fails with ErrLastAdmin unless another active admin exists; the check
+// is part of the UPDATE statement, so concurrent demotions can't both pass it.
+func (r *UserRepository) UpdateRole(ctx context.Context, id uint, role string) (string, error) {
+	var previous string
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, id).Error; err != nil {
+			return notFound(err)
+		}
+		previous = user.Role
+		if user.Role == role {
+			return nil
+		}
+
+		update := tx.Model(&models.User{}).Where("id = ?", id)
+		if user.Role == auth.AdminRole {
+			update = update.Where(
+				"EXISTS (SELECT 1 FROM users AS other WHERE other.id <> ? AND other.role = ? AND other.active = ? AND other.deleted_at IS NULL)",
+				id, auth.AdminRole, true,
+			)
+		}
+		result := update.Update("role", role)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrLastAdmin
+		}
+		return nil
+	})
+	return previous, err
+}
+
+// UpdateUsername renames the user. Usernames are unique per tenant, so
+// another user of the same tenant holding it gives ErrUsernameTaken.
+func (r *UserRepository) UpdateUsername(ctx context.Context, id uint, username string) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, id).Error; err != nil {
+			return notFound(err)
+		}
+		var taken int64
+		if err := tx.Model(&models.User{}).
+			Where("tenant_id = ? AND username = ? AND id <> ?", user.TenantID, username, id).
+			Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrUsernameTaken
+		}
+		return tx.Model(&models.User{}).Where("id = ?", id).Update("username", username).Error
+	})
+}
+
+// UpdateDisplayName sets the display name of the user
+func (r *UserRepository) UpdateDisplayName(ctx context.Context, id uint, displayName string) error {
+	result := database.Conn(ctx, r.db).Model(&models.User{}).Where("id = ?", id).Update("display_name", displayName)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetActive enables or disables the account of the user. Disabling an admin
+// fails with ErrLastAdmin unless another active admin exists, checked in the
+// UPDATE statement like UpdateRole.
+func (r *UserRepository) SetActive(ctx context.Context, id uint, active bool) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Select("id", "role", "active").First(&user, id).Error; err != nil {
+			return notFound(err)
+		}
+		if user.Active == active {
+			return nil
+		}
+
+		update := tx.Model(&models.User{}).Where("id = ?", id)
+		if !active && user.Role == auth.AdminRole {
+			update = update.Where(
+				"EXISTS (SELECT 1 FROM users AS other WHERE other.id <> ? AND other.role = ? AND other.active = ? AND other.deleted_at IS NULL)",
+				id, auth.AdminRole, true,
+			)
+		}
+		result := update.Update("active", active)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrLastAdmin
+		}
+		return nil
+	})
+}
+
+// ExpirePassword flags the user to change their password at next login
+func (r *UserRepository) ExpirePassword(ctx context.Context, id uint) error {
+	result := database.Conn(ctx, r.db).Model(&models.User{}).Where("id = ?", id).Update("must_change_password", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CancelDeletion clears the scheduled deletion of the user and its restore
+// token. Returns ErrNotScheduled if no deletion is scheduled.
+func (r *UserRepository) CancelDeletion(ctx context.Context, id uint) error {
+	var user models.User
+	if err := database.Conn(ctx, r.db).Select("id", "scheduled_deletion_at").First(&user, id).Error; err != nil {
+		return notFound(err)
+	}
+	if user.ScheduledDeletionAt == nil {
+		return ErrNotScheduled
+	}
+	return database.Conn(ctx, r.db).Model(&models.User{}).Where("id = ?", id).Updates(map[string]any{
+		"scheduled_deletion_at": nil,
+		"restore_token":         "",
+	}).Error
+}
+
+// notFound translates gorm's missing record error into ErrNotFound, so
+// callers don't depend on gorm
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
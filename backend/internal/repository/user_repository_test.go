@@ -2,10 +2,12 @@
 package repository
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"gosveltekit/internal/models"
+	"gosveltekit/internal/pagination"
 
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
@@ -328,3 +330,161 @@ func TestUserRepository_Update(t *testing.T) {
 	err = repo.Update(user)
 	assert.Error(t, err)
 }
+
+func TestUserRepository_List(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewUserRepository(db)
+
+	for _, username := range []string{"carol", "alice", "bob"} {
+		user := &models.User{Username: username, Email: username + "@example.com", DisplayName: username, PasswordHash: "hash", Role: "user"}
+		if username == "alice" {
+			user.Role, user.DisplayName = "admin", "Alice 100%"
+		}
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+	}
+	if err := db.Model(&models.User{}).Where("username = ?", "bob").Update("active", false).Error; err != nil {
+		t.Fatalf("failed to deactivate user: %v", err)
+	}
+	inactive := false
+
+	usernames := func(users []models.User) []string {
+		names := make([]string, len(users))
+		for i, user := range users {
+			names[i] = user.Username
+		}
+		return names
+	}
+
+	tests := []struct {
+		name     string
+		filter   UserFilter
+		params   pagination.Params
+		sort     pagination.Sort
+		total    int64
+		expected []string
+	}{
+		{name: "username ascending", params: pagination.Params{Page: 1, PageSize: 10}, sort: pagination.Sort{Column: "username"}, total: 3, expected: []string{"alice", "bob", "carol"}},
+		{name: "username descending", params: pagination.Params{Page: 1, PageSize: 10}, sort: pagination.Sort{Column: "username", Desc: true}, total: 3, expected: []string{"carol", "bob", "alice"}},
+		{name: "second page", params: pagination.Params{Page: 2, PageSize: 2}, sort: pagination.Sort{Column: "username"}, total: 3, expected: []string{"carol"}},
+		{name: "search", filter: UserFilter{Search: "CAROL@"}, params: pagination.Params{Page: 1, PageSize: 10}, sort: pagination.Sort{Column: "username"}, total: 1, expected: []string{"carol"}},
+		{name: "search display name", filter: UserFilter{Search: "100%"}, params: pagination.Params{Page: 1, PageSize: 10}, sort: pagination.Sort{Column: "username"}, total: 1, expected: []string{"alice"}},
+		{name: "role", filter: UserFilter{Role: "user"}, params: pagination.Params{Page: 1, PageSize: 10}, sort: pagination.Sort{Column: "username"}, total: 2, expected: []string{"bob", "carol"}},
+		{name: "inactive", filter: UserFilter{Active: &inactive}, params: pagination.Params{Page: 1, PageSize: 10}, sort: pagination.Sort{Column: "username"}, total: 1, expected: []string{"bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, total, err := repo.List(context.Background(), tt.filter, tt.params, tt.sort)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.total, total)
+			assert.Equal(t, tt.expected, usernames(users))
+		})
+	}
+}
+
+func TestUserRepository_UpdateRole(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	create := func(username, role string) *models.User {
+		user := &models.User{Username: username, Email: username + "@example.com", DisplayName: username, PasswordHash: "hash", Role: role}
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		return user
+	}
+	root := create("root", "admin")
+	other := create("other", "admin")
+	member := create("member", "user")
+
+	// Inactive admins can't log in, so they don't count
+	if err := db.Model(other).Update("active", false).Error; err != nil {
+		t.Fatalf("failed to deactivate user: %v", err)
+	}
+	_, err := repo.UpdateRole(ctx, root.ID, "user")
+	assert.ErrorIs(t, err, ErrLastAdmin)
+
+	// Promote a second admin, then the first one can be demoted
+	previous, err := repo.UpdateRole(ctx, member.ID, "admin")
+	assert.NoError(t, err)
+	assert.Equal(t, "user", previous)
+
+	previous, err = repo.UpdateRole(ctx, root.ID, "user")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", previous)
+
+	// member is now the last active admin
+	_, err = repo.UpdateRole(ctx, member.ID, "user")
+	assert.ErrorIs(t, err, ErrLastAdmin)
+
+	found, err := repo.FindByID(member.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", found.Role)
+
+	_, err = repo.UpdateRole(ctx, 9999, "user")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestUserRepository_CreateUser(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+	createTestUser(t, db)
+
+	user := &models.User{Username: "alice", Email: "alice@example.com", DisplayName: "Alice", PasswordHash: "hash", Role: "user"}
+	assert.NoError(t, repo.CreateUser(ctx, user))
+	assert.NotZero(t, user.ID)
+
+	err := repo.CreateUser(ctx, &models.User{Username: "testuser", Email: "other@example.com", DisplayName: "Other", PasswordHash: "hash"})
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+	err = repo.CreateUser(ctx, &models.User{Username: "other", Email: "test@example.com", DisplayName: "Other", PasswordHash: "hash"})
+	assert.ErrorIs(t, err, ErrEmailTaken)
+}
+
+func TestUserRepository_UpdateDisplayName(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewUserRepository(db)
+	user := createTestUser(t, db)
+
+	assert.NoError(t, repo.UpdateDisplayName(context.Background(), user.ID, "Renamed"))
+	found, err := repo.FindByID(user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Renamed", found.DisplayName)
+
+	assert.ErrorIs(t, repo.UpdateDisplayName(context.Background(), 9999, "Renamed"), ErrNotFound)
+}
+
+func TestUserRepository_SetActive(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	create := func(username, role string) *models.User {
+		user := &models.User{Username: username, Email: username + "@example.com", DisplayName: username, PasswordHash: "hash", Role: role}
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		return user
+	}
+	root := create("root", "admin")
+	other := create("other", "admin")
+	member := create("member", "user")
+
+	assert.NoError(t, repo.SetActive(ctx, member.ID, false))
+	found, err := repo.FindByID(member.ID)
+	assert.NoError(t, err)
+	assert.False(t, found.Active)
+	assert.NoError(t, repo.SetActive(ctx, member.ID, false), "already disabled")
+
+	// An admin can be disabled while another one is active
+	assert.NoError(t, repo.SetActive(ctx, other.ID, false))
+	assert.ErrorIs(t, repo.SetActive(ctx, root.ID, false), ErrLastAdmin)
+
+	assert.NoError(t, repo.SetActive(ctx, other.ID, true))
+	assert.NoError(t, repo.SetActive(ctx, root.ID, false))
+
+	assert.ErrorIs(t, repo.SetActive(ctx, 9999, false), ErrNotFound)
+}
@@ -0,0 +1,68 @@
+package router
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"gosveltekit/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerFallbacks answers unknown routes and wrong methods with the same
+// problem body as the rest of the API, instead of Gin's plain text
+func registerFallbacks(r *gin.Engine) {
+	r.HandleMethodNotAllowed = true
+
+	r.NoRoute(func(c *gin.Context) {
+		apierror.Respond(c, apierror.NotFound("rota não encontrada"))
+	})
+
+	r.NoMethod(func(c *gin.Context) {
+		if allowed := allowedMethods(r.Routes(), c.Request.URL.Path); len(allowed) > 0 {
+			c.Header("Allow", strings.Join(allowed, ", "))
+		}
+		apierror.Respond(c, apierror.New(http.StatusMethodNotAllowed, "método não permitido"))
+	})
+}
+
+// allowedMethods returns the sorted methods registered for path
+func allowedMethods(routes gin.RoutesInfo, path string) []string {
+	seen := make(map[string]bool)
+	for _, route := range routes {
+		if matchRoute(route.Path, path) {
+			seen[route.Method] = true
+		}
+	}
+
+	methods := make([]string, 0, len(seen))
+	for method := range seen {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// matchRoute reports whether path matches a Gin route pattern, where ":name"
+// matches a single segment and "*name" matches the rest of the path
+func matchRoute(pattern, path string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if strings.HasPrefix(part, ":") {
+			continue
+		}
+		if part != pathParts[i] {
+			return false
+		}
+	}
+	return len(patternParts) == len(pathParts)
+}
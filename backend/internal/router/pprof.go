@@ -0,0 +1,32 @@
+package router
+
+import (
+	"net/http/pprof"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerPprof serves the net/http/pprof handlers under /debug/pprof,
+// reachable with the maintenance:run permission only. It is meant for
+// temporary investigations: CPU profiles and traces keep the request open for
+// their whole duration.
+func registerPprof(base *gin.RouterGroup, policy *auth.Policy) {
+	debug := base.Group("/debug/pprof")
+	debug.Use(middleware.RequirePermission(policy, "maintenance:run"))
+	{
+		debug.GET("/", gin.WrapF(pprof.Index))
+		debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+		debug.GET("/profile", gin.WrapF(pprof.Profile))
+		debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+		debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/trace", gin.WrapF(pprof.Trace))
+		// Named profiles (heap, goroutine, allocs, ...); pprof.Index only
+		// recognizes them without a base path
+		debug.GET("/:profile", func(c *gin.Context) {
+			pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package router
+
+import (
+	"strings"
+	"time"
+
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Built-in rate limits, used for rules missing from rate_limit. Impersonation
+// is not configurable: a handful per hour per IP.
+var (
+	defaultAuthRateLimit   = middleware.RateLimitRule{Requests: 3, Window: 3 * time.Second}
+	defaultAPIRateLimit    = middleware.RateLimitRule{Requests: 20, Window: 2 * time.Second}
+	impersonationRateLimit = middleware.RateLimitRule{Requests: 3, Window: 30 * time.Minute}
+	rateLimitKeys          = map[string]middleware.RateLimitKeyFunc{
+		config.RateLimitKeyIP:     middleware.RateLimitByIP,
+		config.RateLimitKeyUser:   middleware.RateLimitByUser,
+		config.RateLimitKeyAPIKey: middleware.RateLimitByAPIKey,
+	}
+)
+
+// rateLimits holds the rules of the route groups and the per-route overrides
+type rateLimits struct {
+	auth, api middleware.RateLimitRule
+	routes    map[string]middleware.RateLimitRule
+}
+
+// buildRateLimits reads rate_limit from cfg, keeping the built-in rules it
+// leaves out. Override routes are prefixed with the base path.
+func buildRateLimits(cfg *config.Config, basePath string) rateLimits {
+	limits := rateLimits{auth: defaultAuthRateLimit, api: defaultAPIRateLimit, routes: map[string]middleware.RateLimitRule{}}
+	if cfg == nil {
+		return limits
+	}
+
+	rule := func(rule config.RateLimitRuleConfig, fallback middleware.RateLimitRule) middleware.RateLimitRule {
+		if rule.Requests > 0 {
+			fallback = middleware.RateLimitRule{Requests: rule.Requests, Window: rule.Window}
+		}
+		key := cfg.RateLimit.Key
+		if _, ok := rateLimitKeys[rule.Key]; ok {
+			key = rule.Key
+		}
+		fallback.Key = rateLimitKeys[key]
+		fallback.Authenticated = key == config.RateLimitKeyUser || key == config.RateLimitKeyAPIKey
+		return fallback
+	}
+
+	limits.auth = rule(cfg.RateLimit.Auth, defaultAuthRateLimit)
+	limits.api = rule(cfg.RateLimit.API, defaultAPIRateLimit)
+	for route, override := range cfg.RateLimit.RouteRules() {
+		if override.Requests <= 0 {
+			continue
+		}
+		// Same joining rule as gin, see prefixRoutes
+		method, path, _ := strings.Cut(route, " ")
+		limits.routes[method+" "+basePath+path] = rule(override, middleware.RateLimitRule{})
+	}
+	return limits
+}
+
+// limitBeforeAuth applies the limits of the /auth and /api groups (and /ws,
+// counted as api) ahead of authentication, so requests without a valid
+// session are limited too. Rules keyed by the caller are counted later by
+// the groups, see middleware.RateLimiter.AfterAuth.
+func limitBeforeAuth(limiter *middleware.RateLimiter, basePath string, limits rateLimits) gin.HandlerFunc {
+	authLimit := limiter.BeforeAuth("auth", limits.auth)
+	apiLimit := limiter.BeforeAuth("api", limits.api)
+	return func(c *gin.Context) {
+		switch route := c.FullPath(); {
+		case strings.HasPrefix(route, basePath+"/auth/"):
+			authLimit(c)
+		case strings.HasPrefix(route, basePath+"/api/"), route == basePath+"/ws":
+			apiLimit(c)
+		default:
+			c.Next()
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// cachingGroup is a route group whose GET routes get the response cache as
+// their last middleware, right before the handler. A cache hit ends the
+// request, so it must only be served once the group's rate limit and the
+// route's own checks (permissions, impersonation) let the request through.
+type cachingGroup struct {
+	*gin.RouterGroup
+	cache gin.HandlerFunc // nil without http_cache.routes
+}
+
+// Group creates a cachingGroup under g
+func (g cachingGroup) Group(relativePath string, handlers ...gin.HandlerFunc) cachingGroup {
+	return cachingGroup{RouterGroup: g.RouterGroup.Group(relativePath, handlers...), cache: g.cache}
+}
+
+// GET registers a GET route, the cache going before its handler. The cache
+// itself only answers the routes listed in http_cache.routes.
+func (g cachingGroup) GET(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	if g.cache != nil && len(handlers) > 0 {
+		last := len(handlers) - 1
+		handlers = append(append(handlers[:last:last], g.cache), handlers[last])
+	}
+	return g.RouterGroup.GET(relativePath, handlers...)
+}
@@ -3,65 +3,524 @@ package router
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"gosveltekit/internal/auth"
+	"gosveltekit/internal/config"
 	"gosveltekit/internal/handlers"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/metrics"
 	"gosveltekit/internal/middleware"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-// SetupRouter configures all routes for the application
+// publicRoutes are the routes reachable without a session, as "METHOD /path"
+// relative to the base path. Every other route requires authentication, so a
+// new public endpoint must be added here explicitly.
+var publicRoutes = []string{
+	"GET /",
+	"GET /ping",
+	"GET /health",
+	"GET /healthz",
+	"GET /readyz",
+	"GET /version",
+	"GET /metrics",
+	"POST /auth/login",
+	"POST /auth/login/2fa",
+	"POST /auth/login/2fa/sms",
+	"POST /auth/refresh",
+	"POST /auth/register",
+	"POST /auth/check-email",
+	"POST /auth/password-reset-request",
+	"POST /auth/password-reset",
+	"GET /auth/password-reset/validate",
+	"GET /auth/reset/validate",
+	"GET /auth/password-policy",
+	"POST /auth/account/restore",
+	"POST /auth/verify-email",
+	"POST /auth/resend-verification",
+	"POST /auth/magic-link",
+	"POST /auth/magic-link/verify",
+	"GET /auth/oauth/:provider/login",
+	"GET /auth/oauth/:provider/callback",
+	"GET /auth/csrf",
+	"GET /files/*key",
+}
+
+// passwordChangeRoutes are the routes still available to users who must
+// change their password, relative to the base path
+var passwordChangeRoutes = []string{
+	"GET /api/me",
+	"POST /api/me/password",
+	"POST /api/logout",
+}
+
+// unverifiedEmailRoutes are the routes still available to users who haven't
+// verified their email with auth.unverified_login restricted, relative to the
+// base path
+var unverifiedEmailRoutes = []string{
+	"GET /api/me",
+	"POST /api/logout",
+}
+
+// maintenanceRoutes stay available to everyone during maintenance, relative
+// to the base path, so admins can still log in and turn it off
+var maintenanceRoutes = []string{
+	"GET /",
+	"GET /ping",
+	"GET /health",
+	"GET /healthz",
+	"GET /readyz",
+	"GET /version",
+	"GET /metrics",
+	"GET /auth/csrf",
+	"POST /auth/login",
+	"POST /auth/login/2fa",
+	"POST /auth/login/2fa/sms",
+	"GET /api/me",
+	"POST /api/logout",
+}
+
+// apiKeyRoutes are the routes reachable with an API key, relative to the
+// base path; every other route needs a session. Permission-checked routes
+// also need the permission among the scopes of the key. Managing sessions,
+// credentials and API keys themselves stays out of reach.
+var apiKeyRoutes = []string{
+	"GET /api/me",
+	"GET /api/protected",
+	"GET /api/admin/users",
+	"GET /api/admin/users/export",
+	"GET /api/admin/users/:id",
+	"POST /api/admin/users",
+	"PATCH /api/admin/users/:id",
+	"POST /api/admin/users/batch",
+	"POST /api/admin/users/:id/disable",
+	"POST /api/admin/users/:id/enable",
+	"PATCH /api/admin/users/:id/role",
+	"PATCH /api/admin/users/:id/username",
+	"POST /api/admin/users/:id/expire-password",
+	"POST /api/admin/users/:id/restore",
+	"POST /api/admin/users/:id/reset-password",
+	"POST /api/admin/users/:id/unlock",
+	"GET /api/admin/roles",
+	"GET /api/admin/lockouts",
+	"DELETE /api/admin/lockouts/:kind/:key",
+	"POST /api/admin/cleanup-tokens",
+	"GET /api/admin/jobs",
+	"GET /api/admin/jobs/stats",
+	"GET /api/admin/jobs/:id",
+	"GET /api/admin/audit-logs",
+	"GET /api/admin/audit/export",
+	"GET /api/admin/sessions",
+}
+
+// options holds optional settings for SetupRouter
+type options struct {
+	cfg           *config.Config
+	healthHandler *handlers.HealthHandler
+	userHandler   *handlers.UserHandler
+	maintenance   *handlers.MaintenanceHandler
+	diagnostics   *handlers.DiagnosticsHandler
+	settings      *handlers.SettingsHandler
+	sessions      *handlers.SessionHandler
+	roles         *handlers.RoleHandler
+	lockouts      *handlers.LockoutHandler
+	jobs          *handlers.JobHandler
+	audit         *handlers.AuditHandler
+	files         *handlers.FileHandler
+	realtime      *handlers.RealtimeHandler
+	apiKeys       *handlers.APIKeyHandler
+	permissions   *auth.Policy
+	oauth         *handlers.OAuthHandler
+	maintenanceOn func() bool
+	inFlight      *middleware.InFlight
+	publicRoutes  []string
+	rateLimits    middleware.RateLimitStore
+	responseCache middleware.ResponseCacheStore
+}
+
+// Option configures optional behavior of SetupRouter
+type Option func(*options)
+
+// WithConfig makes the router honor the application configuration
+// (e.g. mounting every route under cfg.Server.BasePath)
+func WithConfig(cfg *config.Config) Option {
+	return func(o *options) {
+		o.cfg = cfg
+	}
+}
+
+// WithHealthHandler sets the handler serving the readiness endpoint.
+// Without it, readiness reports ok with no components.
+func WithHealthHandler(h *handlers.HealthHandler) Option {
+	return func(o *options) {
+		o.healthHandler = h
+	}
+}
+
+// WithUserHandler enables the admin user management routes
+func WithUserHandler(h *handlers.UserHandler) Option {
+	return func(o *options) {
+		o.userHandler = h
+	}
+}
+
+// WithMaintenanceHandler enables the admin maintenance routes
+func WithMaintenanceHandler(h *handlers.MaintenanceHandler) Option {
+	return func(o *options) {
+		o.maintenance = h
+	}
+}
+
+// WithFileHandler enables the route serving the signed URLs of local storage
+func WithFileHandler(h *handlers.FileHandler) Option {
+	return func(o *options) {
+		o.files = h
+	}
+}
+
+// WithRealtimeHandler enables the WebSocket of real-time notifications at /ws
+func WithRealtimeHandler(h *handlers.RealtimeHandler) Option {
+	return func(o *options) {
+		o.realtime = h
+	}
+}
+
+// WithAPIKeyHandler enables the routes issuing and revoking API keys, for
+// the current user and for admins
+func WithAPIKeyHandler(h *handlers.APIKeyHandler) Option {
+	return func(o *options) {
+		o.apiKeys = h
+	}
+}
+
+// WithDiagnosticsHandler enables the admin runtime diagnostics route
+func WithDiagnosticsHandler(h *handlers.DiagnosticsHandler) Option {
+	return func(o *options) {
+		o.diagnostics = h
+	}
+}
+
+// WithSettingsHandler enables the admin runtime settings routes
+func WithSettingsHandler(h *handlers.SettingsHandler) Option {
+	return func(o *options) {
+		o.settings = h
+	}
+}
+
+// WithSessionHandler enables the admin route listing the sessions of all users
+func WithSessionHandler(h *handlers.SessionHandler) Option {
+	return func(o *options) {
+		o.sessions = h
+	}
+}
+
+// WithJobHandler enables the admin routes showing the background jobs
+func WithJobHandler(h *handlers.JobHandler) Option {
+	return func(o *options) {
+		o.jobs = h
+	}
+}
+
+// WithAuditHandler enables the admin route querying the audit log
+func WithAuditHandler(h *handlers.AuditHandler) Option {
+	return func(o *options) {
+		o.audit = h
+	}
+}
+
+// WithRoleHandler enables the admin route listing the roles users can be
+// given
+func WithRoleHandler(h *handlers.RoleHandler) Option {
+	return func(o *options) {
+		o.roles = h
+	}
+}
+
+// WithLockoutHandler enables the admin routes listing and lifting failed
+// login lockouts
+func WithLockoutHandler(h *handlers.LockoutHandler) Option {
+	return func(o *options) {
+		o.lockouts = h
+	}
+}
+
+// WithPermissionPolicy makes admin routes require the permissions they
+// declare (users:read, users:write...) from the user's role, whatever its
+// name. Without it only the admin role reaches them.
+func WithPermissionPolicy(policy *auth.Policy) Option {
+	return func(o *options) {
+		o.permissions = policy
+	}
+}
+
+// WithOAuthHandler enables social login through the OAuth providers of h
+func WithOAuthHandler(h *handlers.OAuthHandler) Option {
+	return func(o *options) {
+		o.oauth = h
+	}
+}
+
+// WithRateLimitStore sets where rate limits are counted, e.g. a
+// middleware.RedisRateLimitStore shared by every instance. Default: a
+// middleware.MemoryRateLimitStore per router.
+func WithRateLimitStore(store middleware.RateLimitStore) Option {
+	return func(o *options) {
+		o.rateLimits = store
+	}
+}
+
+// WithResponseCacheStore sets where the routes of http_cache.routes are
+// cached, e.g. a middleware.RedisResponseCache shared by every instance.
+// Default: a middleware.MemoryResponseCache per router.
+func WithResponseCacheStore(store middleware.ResponseCacheStore) Option {
+	return func(o *options) {
+		o.responseCache = store
+	}
+}
+
+// WithMaintenanceMode makes the router answer 503 to everyone but admins
+// while enabled reports true, which is checked on every request
+func WithMaintenanceMode(enabled func() bool) Option {
+	return func(o *options) {
+		o.maintenanceOn = enabled
+	}
+}
+
+// WithInFlight makes the router count in-flight requests in f, so shutdown
+// can drain them
+func WithInFlight(f *middleware.InFlight) Option {
+	return func(o *options) {
+		o.inFlight = f
+	}
+}
+
+// WithPublicRoutes marks additional routes ("METHOD /path", relative to the
+// base path, with the same :param patterns used to register them) as
+// reachable without a session
+func WithPublicRoutes(routes ...string) Option {
+	return func(o *options) {
+		o.publicRoutes = append(o.publicRoutes, routes...)
+	}
+}
+
+// SetupRouter configures all routes for the application.
+//
+// All routes are registered relative to the configured base path, so with
+// base_path "/api" the health check is served at "/api/health".
+//
+// Authentication is applied to every route, including routes registered on
+// the returned engine later, except those listed in publicRoutes,
+// server.public_routes or WithPublicRoutes.
 func SetupRouter(
 	authHandler *handlers.AuthHandler,
 	authManager *auth.AuthManager,
+	opts ...Option,
 ) *gin.Engine {
-	r := gin.Default()
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.healthHandler == nil {
+		o.healthHandler = handlers.NewHealthHandler(nil)
+	}
 
-	// Add CORS middleware
-	r.Use(middleware.CorsMiddleware())
+	// Secure cookies and internal error details depend on the environment.
+	// Without a config, play safe as if in production.
+	exposeErrorDetails := false
+	maxURLLength := config.DefaultMaxURLLength
+	metricsEnabled := o.cfg == nil || o.cfg.Metrics.Enabled
+	cookieTransport := o.cfg != nil && o.cfg.Auth.TokenTransport == config.TokenTransportCookie
+	cookies := middleware.DefaultCookieOptions()
+	var authOpts []middleware.AuthOption
+	if o.cfg != nil {
+		cookies = middleware.NewCookieOptions(o.cfg.Auth, !o.cfg.Environment.IsDevelopment())
+		authOpts = append(authOpts, middleware.WithRefreshRecommendedWithin(o.cfg.Auth.RefreshRecommendedWithin))
+		if cookieTransport {
+			// Tokens only live in cookies, which the CSRF check below covers
+			authOpts = append(authOpts, middleware.WithTokenSources([]string{config.TokenSourceCookie}))
+		} else {
+			authOpts = append(authOpts, middleware.WithTokenSources(o.cfg.Auth.TokenSources))
+		}
+		if o.cfg.Resilience.ReadCacheFallback {
+			authOpts = append(authOpts, middleware.WithReadFallback(o.cfg.Resilience.ReadCacheTTL))
+		}
+		exposeErrorDetails = o.cfg.VerboseErrors()
+		if o.cfg.Server.MaxURLLength > 0 {
+			maxURLLength = o.cfg.Server.MaxURLLength
+		}
+	}
+
+	r := gin.New()
+	if o.inFlight != nil {
+		r.Use(o.inFlight.Middleware())
+	}
+	if o.cfg != nil && o.cfg.Telemetry.Enabled {
+		r.Use(otelgin.Middleware(o.cfg.Telemetry.ServiceName))
+	}
+	if metricsEnabled {
+		r.Use(metrics.Middleware())
+	}
+	r.Use(middleware.RequestID(), middleware.AuditClient(), middleware.AccessLog(), middleware.RecoveryMiddleware(exposeErrorDetails))
+	if exposeErrorDetails {
+		r.Use(middleware.ErrorDetails())
+	}
+	r.Use(middleware.Cookies(cookies))
+	r.Use(middleware.MaxURLLength(maxURLLength))
+	registerFallbacks(r)
+
+	// CORS, for frontends on another origin
+	corsConfig := middleware.DefaultCORSConfig()
+	if o.cfg != nil {
+		corsConfig = o.cfg.Server.CORS
+	}
+	if cors := middleware.CORS(corsConfig); cors != nil {
+		r.Use(cors)
+	}
+	if cookieTransport {
+		r.Use(middleware.CSRF(authManager.Tokens()))
+	}
+
+	basePath := ""
+	if o.cfg != nil {
+		basePath = o.cfg.Server.NormalizedBasePath()
+		o.publicRoutes = append(o.publicRoutes, o.cfg.Server.PublicRoutes...)
+		if o.cfg.Tenancy.Enabled {
+			r.Use(middleware.Tenant(o.cfg.Tenancy))
+		}
+	}
+
+	// Rate limits per group (rate_limit.auth, strict against brute force, and
+	// the more permissive rate_limit.api), replaced on rate_limit.routes.
+	// Counted before authentication, so rejected requests are limited too
+	if o.rateLimits == nil {
+		o.rateLimits = middleware.NewMemoryRateLimitStore()
+	}
+	limits := buildRateLimits(o.cfg, basePath)
+	limiter := middleware.NewRateLimiter(o.rateLimits, limits.routes)
+	r.Use(limitBeforeAuth(limiter, basePath, limits))
+
+	// Fail-closed authentication: everything but the public routes
+	r.Use(middleware.RequireAuthExcept(authManager, buildPublicRoutes(basePath, o.publicRoutes), authOpts...))
+	r.Use(middleware.RequireSessionExcept(prefixRoutes(basePath, apiKeyRoutes)))
+	r.Use(middleware.RequirePasswordChangeExcept(prefixRoutes(basePath, passwordChangeRoutes)))
+	if o.cfg != nil && o.cfg.Auth.UnverifiedLogin == config.UnverifiedLoginRestricted {
+		r.Use(middleware.RequireVerifiedEmailExcept(prefixRoutes(basePath, unverifiedEmailRoutes)))
+	}
+	if o.maintenanceOn != nil {
+		r.Use(middleware.MaintenanceModeExcept(o.maintenanceOn, prefixRoutes(basePath, maintenanceRoutes)))
+	}
+
+	// HTTP caching, after authentication so responses are told apart by user.
+	// The response cache runs per route, see cachingGroup.
+	if o.cfg == nil || o.cfg.HTTPCache.ETags {
+		r.Use(middleware.ETag())
+	}
+	var responseCache gin.HandlerFunc
+	if o.cfg != nil && len(o.cfg.HTTPCache.Routes) > 0 {
+		if o.responseCache == nil {
+			o.responseCache = middleware.NewMemoryResponseCache(o.cfg.HTTPCache.MaxEntries)
+		}
+		ttls := make(map[string]time.Duration, len(o.cfg.HTTPCache.Routes))
+		for route, ttl := range o.cfg.HTTPCache.RouteTTLs() {
+			// Same joining rule as gin, see prefixRoutes
+			method, path, _ := strings.Cut(route, " ")
+			ttls[method+" "+basePath+path] = ttl
+		}
+		r.Use(middleware.InvalidateResponseCache(o.responseCache))
+		responseCache = middleware.ResponseCache(o.responseCache, ttls)
+	}
+
+	base := cachingGroup{RouterGroup: r.Group(basePath), cache: responseCache}
 
 	// Root route
-	r.GET("/", func(c *gin.Context) {
+	base.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Hello GoSvelteKit",
 		})
 	})
 
-	r.GET("/ping", func(c *gin.Context) {
+	base.GET("/ping", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "pong",
 		})
 	})
 
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "ok",
-		})
-	})
+	// Liveness, also at /health for existing monitors
+	base.GET("/healthz", o.healthHandler.Liveness)
+	base.GET("/health", o.healthHandler.Liveness)
 
-	// Rate limiter for auth routes (brute force prevention)
-	authLimiter := middleware.NewIPRateLimiter(rate.Limit(1), 3, time.Hour)
+	// Readiness with per-component status
+	base.GET("/readyz", o.healthHandler.Readiness)
+	base.GET("/version", o.healthHandler.Version)
+
+	// Prometheus metrics
+	if metricsEnabled {
+		base.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+
+	if o.cfg != nil && o.cfg.Debug.Pprof {
+		registerPprof(base.RouterGroup, o.permissions)
+	}
 
 	// Public auth routes
-	authRoutes := r.Group("/auth")
-	authRoutes.Use(middleware.RateLimitMiddleware(authLimiter))
+	authRoutes := base.Group("/auth")
+	authRoutes.Use(limiter.AfterAuth("auth", limits.auth), middleware.RequireJSON())
 	{
 		authRoutes.POST("/login", authHandler.Login)
+		authRoutes.POST("/login/2fa", authHandler.CompleteTwoFactorLogin)
+		authRoutes.POST("/login/2fa/sms", authHandler.ResendTwoFactorCode)
+		authRoutes.POST("/refresh", authHandler.Refresh)
+		if cookieTransport {
+			authRoutes.GET("/csrf", authHandler.CSRFToken)
+		}
 		authRoutes.POST("/register", authHandler.Register)
+		authRoutes.POST("/check-email", authHandler.CheckEmail)
 		authRoutes.POST("/password-reset-request", authHandler.RequestPasswordReset)
 		authRoutes.POST("/password-reset", authHandler.ResetPassword)
+		authRoutes.GET("/password-reset/validate", authHandler.ValidateResetToken)
+		// Alias of /password-reset/validate
+		authRoutes.GET("/reset/validate", authHandler.ValidateResetToken)
+		authRoutes.GET("/password-policy", authHandler.PasswordPolicy)
+		authRoutes.POST("/account/restore", authHandler.RestoreAccount)
+		authRoutes.POST("/verify-email", authHandler.VerifyEmail)
+		authRoutes.POST("/resend-verification", authHandler.ResendVerification)
+		authRoutes.POST("/magic-link", authHandler.RequestMagicLink)
+		authRoutes.POST("/magic-link/verify", authHandler.VerifyMagicLink)
+		// Requires a session: the caller must own the token
+		authRoutes.POST("/revoke", authHandler.RevokeToken)
+		if o.oauth != nil {
+			authRoutes.GET("/oauth/:provider/login", o.oauth.Login)
+			authRoutes.GET("/oauth/:provider/callback", o.oauth.Callback)
+		}
+	}
+
+	// Signed links to stored files, checked by the handler
+	if o.files != nil {
+		base.GET("/files/*key", o.files.Serve)
+	}
+
+	// WebSocket handshakes have no body; the rate limit only counts
+	// connection attempts
+	if o.realtime != nil {
+		base.GET("/ws", limiter.AfterAuth("api", limits.api), o.realtime.Connect)
 	}
 
-	// Rate limiter for API (more permissive)
-	apiLimiter := middleware.NewIPRateLimiter(rate.Limit(10), 20, time.Hour)
+	// Avatar uploads are multipart/form-data, so outside the RequireJSON of
+	// the api group
+	if authHandler.AvatarsEnabled() {
+		avatar := base.Group("/api/me/avatar")
+		avatar.Use(limiter.AfterAuth("api", limits.api), middleware.BlockDuringImpersonation())
+		avatar.PUT("", authHandler.UploadAvatar)
+		avatar.DELETE("", authHandler.DeleteAvatar)
+	}
 
 	// Protected routes
-	api := r.Group("/api")
-	api.Use(middleware.RateLimitMiddleware(apiLimiter))
-	api.Use(middleware.AuthMiddleware(authManager))
+	api := base.Group("/api")
+	api.Use(limiter.AfterAuth("api", limits.api), middleware.RequireJSON())
 	{
 		// Test protected route
 		api.GET("/protected", func(c *gin.Context) {
@@ -71,19 +530,128 @@ func SetupRouter(
 		})
 
 		api.GET("/me", authHandler.GetCurrentUser)
+		api.POST("/me/password", middleware.BlockDuringImpersonation(), authHandler.ChangePassword)
+		api.PUT("/me/locale", middleware.BlockDuringImpersonation(), authHandler.UpdateLocale)
+		api.GET("/me/export", middleware.BlockDuringImpersonation(), authHandler.ExportAccount)
+		api.GET("/me/sessions", middleware.BlockDuringImpersonation(), authHandler.ListSessions)
+		api.DELETE("/me/sessions/:id", middleware.BlockDuringImpersonation(), authHandler.RevokeSession)
+		api.POST("/me/sessions/revoke-others", middleware.BlockDuringImpersonation(), authHandler.RevokeOtherSessions)
+		api.DELETE("/me", middleware.BlockDuringImpersonation(), authHandler.DeleteAccount)
+		api.POST("/me/2fa/totp", middleware.BlockDuringImpersonation(), authHandler.EnrollTOTP)
+		api.POST("/me/2fa/totp/verify", middleware.BlockDuringImpersonation(), authHandler.VerifyTOTP)
+		api.DELETE("/me/2fa/totp", middleware.BlockDuringImpersonation(), authHandler.DisableTOTP)
+		api.PUT("/me/phone", middleware.BlockDuringImpersonation(), authHandler.SetPhoneNumber)
+		api.POST("/me/phone/verify", middleware.BlockDuringImpersonation(), authHandler.VerifyPhoneNumber)
+		api.POST("/me/2fa/sms", middleware.BlockDuringImpersonation(), authHandler.EnableSMSTwoFactor)
+		api.DELETE("/me/2fa/sms", middleware.BlockDuringImpersonation(), authHandler.DisableSMSTwoFactor)
+		if o.apiKeys != nil {
+			api.GET("/me/api-keys", middleware.BlockDuringImpersonation(), o.apiKeys.List)
+			api.POST("/me/api-keys", middleware.BlockDuringImpersonation(), o.apiKeys.Create)
+			api.DELETE("/me/api-keys/:id", middleware.BlockDuringImpersonation(), o.apiKeys.Revoke)
+		}
+		api.POST("/impersonation/end", authHandler.EndImpersonation)
 		api.POST("/logout", authHandler.Logout)
 
-		// Admin only routes
+		// Admin routes, each declaring the permissions it needs
 		admin := api.Group("/admin")
-		admin.Use(middleware.RoleMiddleware("admin"))
 		{
-			admin.GET("/dashboard", func(c *gin.Context) {
+			require := func(permissions ...string) gin.HandlerFunc {
+				return middleware.RequirePermission(o.permissions, permissions...)
+			}
+
+			admin.GET("/dashboard", require("users:read"), func(c *gin.Context) {
 				c.JSON(http.StatusOK, gin.H{
 					"message": "Admin Dashboard",
 				})
 			})
+
+			if o.userHandler != nil {
+				admin.GET("/users", require("users:read"), o.userHandler.List)
+				admin.GET("/users/export", require("users:read"), o.userHandler.Export)
+				admin.GET("/users/:id", require("users:read"), o.userHandler.Get)
+				admin.POST("/users", require("users:write"), o.userHandler.Create)
+				admin.PATCH("/users/:id", require("users:write"), o.userHandler.Update)
+				admin.POST("/users/batch", require("users:write"), o.userHandler.Batch)
+				admin.POST("/users/:id/disable", require("users:write"), o.userHandler.Disable)
+				admin.POST("/users/:id/enable", require("users:write"), o.userHandler.Enable)
+				admin.PATCH("/users/:id/role", require("users:write"), o.userHandler.UpdateRole)
+				admin.PATCH("/users/:id/username", require("users:write"), o.userHandler.UpdateUsername)
+				admin.POST("/users/:id/expire-password", require("users:write"), o.userHandler.ExpirePassword)
+				admin.POST("/users/:id/restore", require("users:write"), o.userHandler.RestoreAccount)
+			}
+
+			if o.apiKeys != nil {
+				admin.GET("/users/:id/api-keys", require("users:read"), o.apiKeys.AdminList)
+				admin.POST("/users/:id/api-keys", require("users:write", auth.PermissionImpersonate), o.apiKeys.AdminCreate)
+				admin.DELETE("/users/:id/api-keys/:key_id", require("users:write"), o.apiKeys.AdminRevoke)
+			}
+
+			if o.roles != nil {
+				admin.GET("/roles", require("users:read"), o.roles.List)
+			}
+
+			if o.lockouts != nil {
+				admin.GET("/lockouts", require("users:read"), o.lockouts.List)
+				admin.DELETE("/lockouts/:kind/:key", require("users:write"), o.lockouts.Unlock)
+				admin.POST("/users/:id/unlock", require("users:write"), o.lockouts.UnlockUser)
+			}
+
+			if o.maintenance != nil {
+				admin.POST("/cleanup-tokens", require("maintenance:run"), o.maintenance.CleanupTokens)
+			}
+
+			if o.jobs != nil {
+				admin.GET("/jobs", require("maintenance:run"), o.jobs.List)
+				admin.GET("/jobs/stats", require("maintenance:run"), o.jobs.Stats)
+				admin.GET("/jobs/:id", require("maintenance:run"), o.jobs.Get)
+			}
+
+			if o.audit != nil {
+				admin.GET("/audit-logs", require("audit:read"), o.audit.List)
+				admin.GET("/audit/export", require("audit:read"), o.audit.Export)
+			}
+
+			if o.diagnostics != nil {
+				admin.GET("/diagnostics", require("maintenance:run"), o.diagnostics.Diagnostics)
+			}
+
+			if o.settings != nil {
+				admin.GET("/settings", require("settings:write"), o.settings.List)
+				admin.PUT("/settings/:key", require("settings:write"), o.settings.UpdateSetting)
+			}
+
+			if o.sessions != nil {
+				admin.GET("/sessions", require("users:read"), o.sessions.List)
+			}
+
+			// Heavily rate limited, on top of the api limit
+			admin.POST("/impersonate/:user_id", require(auth.PermissionImpersonate), limiter.Extra("impersonation", impersonationRateLimit), authHandler.Impersonate)
+			admin.POST("/users/:id/reset-password", require("users:write"), authHandler.AdminRequestPasswordReset)
 		}
 	}
 
 	return r
 }
+
+// buildPublicRoutes prefixes the built-in and extra public routes with the
+// base path. Malformed entries are skipped, which leaves the route protected.
+func buildPublicRoutes(basePath string, extra []string) middleware.PublicRoutes {
+	return prefixRoutes(basePath, append(append([]string{}, publicRoutes...), extra...))
+}
+
+// prefixRoutes builds a route set from "METHOD /path" entries relative to the
+// base path, skipping malformed ones
+func prefixRoutes(basePath string, routes []string) middleware.PublicRoutes {
+	public := middleware.NewPublicRoutes()
+	for _, route := range routes {
+		method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+		path = strings.TrimSpace(path)
+		if !ok || method == "" || !strings.HasPrefix(path, "/") {
+			logger.Warn("Rota pública ignorada: use o formato \"MÉTODO /caminho\"", "route", route)
+			continue
+		}
+		// Same joining rule as gin: "/" under "/api" is "/api/"
+		public[strings.ToUpper(method)+" "+basePath+path] = true
+	}
+	return public
+}
@@ -2,17 +2,35 @@
 package router
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"gosveltekit/internal/apierror"
+	"gosveltekit/internal/audit"
 	"gosveltekit/internal/auth"
 	gormadapter "gosveltekit/internal/auth/adapter/gorm"
+	"gosveltekit/internal/auth/oauth"
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/email"
 	"gosveltekit/internal/handlers"
+	"gosveltekit/internal/jobs"
+	"gosveltekit/internal/middleware"
 	"gosveltekit/internal/models"
+	"gosveltekit/internal/realtime"
+	"gosveltekit/internal/repository"
 	"gosveltekit/internal/service"
+	"gosveltekit/internal/settings"
+	"gosveltekit/internal/storage"
+	"gosveltekit/internal/validation"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/sqlite"
@@ -22,7 +40,7 @@ import (
 // MockAuthService implements service.AuthServiceInterface
 type MockAuthService struct{}
 
-func (m *MockAuthService) Login(username, password, ip, userAgent string) (*service.LoginResponse, error) {
+func (m *MockAuthService) Login(ctx context.Context, username, password, ip, userAgent string) (*service.LoginResponse, error) {
 	return &service.LoginResponse{
 		SessionID: "mock-session-id",
 		ExpiresAt: time.Now().Add(time.Hour),
@@ -33,7 +51,7 @@ func (m *MockAuthService) Login(username, password, ip, userAgent string) (*serv
 	}, nil
 }
 
-func (m *MockAuthService) ValidateSession(sessionID string) (*auth.Session, *auth.UserData, error) {
+func (m *MockAuthService) ValidateSession(ctx context.Context, sessionID string) (*auth.Session, *auth.UserData, error) {
 	return &auth.Session{
 			ID:        sessionID,
 			UserID:    "1",
@@ -45,23 +63,143 @@ func (m *MockAuthService) ValidateSession(sessionID string) (*auth.Session, *aut
 		}, nil
 }
 
-func (m *MockAuthService) Logout(sessionID string) error {
+func (m *MockAuthService) Logout(ctx context.Context, sessionID string) error {
 	return nil
 }
 
-func (m *MockAuthService) LogoutAll(userID string) error {
+func (m *MockAuthService) LogoutAll(ctx context.Context, userID string) error {
 	return nil
 }
 
-func (m *MockAuthService) Register(username, email, password, displayName string) (*models.User, error) {
-	return &models.User{}, nil
+func (m *MockAuthService) Register(ctx context.Context, username, email, password, displayName, captchaToken, ip string) (*models.User, bool, error) {
+	return &models.User{}, false, nil
 }
 
-func (m *MockAuthService) RequestPasswordReset(email string) error {
+func (m *MockAuthService) EmailAvailable(ctx context.Context, email string) (bool, error) {
+	return true, nil
+}
+
+func (m *MockAuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	return nil
+}
+
+func (m *MockAuthService) RevokeToken(ctx context.Context, userID, token string) error {
+	return nil
+}
+
+func (m *MockAuthService) AdminRequestPasswordReset(ctx context.Context, actorID, userID string) error {
+	return nil
+}
+
+func (m *MockAuthService) VerifyEmail(ctx context.Context, token string) error {
+	return nil
+}
+
+func (m *MockAuthService) ResendVerificationEmail(ctx context.Context, email string) error {
+	return nil
+}
+
+func (m *MockAuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	return nil
+}
+
+func (m *MockAuthService) ValidateResetToken(ctx context.Context, token string) error {
+	return nil
+}
+
+func (m *MockAuthService) ChangePassword(ctx context.Context, userID, sessionID, currentPassword, newPassword string) error {
+	return nil
+}
+
+func (m *MockAuthService) SetLocale(ctx context.Context, userID, locale string) error {
+	return nil
+}
+
+func (m *MockAuthService) SetAvatar(ctx context.Context, userID, key string) (string, error) {
+	return "", nil
+}
+
+func (m *MockAuthService) ExportAccount(ctx context.Context, userID string) (*service.AccountExport, error) {
+	return &service.AccountExport{User: &models.User{}}, nil
+}
+
+func (m *MockAuthService) Impersonate(ctx context.Context, adminSessionID, targetUserID, ip, userAgent string) (*service.LoginResponse, error) {
+	return &service.LoginResponse{}, nil
+}
+
+func (m *MockAuthService) EndImpersonation(ctx context.Context, sessionID string) (*service.LoginResponse, error) {
+	return &service.LoginResponse{}, nil
+}
+
+func (m *MockAuthService) PasswordPolicy() validation.PasswordPolicy {
+	return validation.DefaultPasswordPolicy()
+}
+
+func (m *MockAuthService) DeleteAccount(ctx context.Context, userID, password string) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func (m *MockAuthService) RestoreAccount(ctx context.Context, token string) error {
+	return nil
+}
+
+func (m *MockAuthService) CompleteTwoFactorLogin(ctx context.Context, challengeToken, code, ip string) (*service.LoginResponse, error) {
+	return nil, service.ErrInvalidTwoFactorChallenge
+}
+
+func (m *MockAuthService) Refresh(ctx context.Context, refreshToken, ip, userAgent string) (*service.LoginResponse, error) {
+	return nil, service.ErrInvalidRefreshToken
+}
+
+func (m *MockAuthService) RequestMagicLink(ctx context.Context, email, ip string) error {
+	return nil
+}
+
+func (m *MockAuthService) LoginWithMagicLink(ctx context.Context, token, ip, userAgent string) (*service.LoginResponse, error) {
+	return nil, service.ErrInvalidMagicLink
+}
+
+func (m *MockAuthService) ListSessions(ctx context.Context, userID string) ([]*auth.Session, error) {
+	return nil, nil
+}
+
+func (m *MockAuthService) RevokeSession(ctx context.Context, userID, handle string) error {
+	return nil
+}
+
+func (m *MockAuthService) RevokeOtherSessions(ctx context.Context, userID, currentSessionID string) ([]auth.SessionRevocation, error) {
+	return nil, nil
+}
+
+func (m *MockAuthService) EnrollTOTP(ctx context.Context, userID string) (*auth.TOTPEnrollment, error) {
+	return nil, nil
+}
+
+func (m *MockAuthService) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	return nil, nil
+}
+
+func (m *MockAuthService) DisableTOTP(ctx context.Context, userID, password string) error {
 	return nil
 }
 
-func (m *MockAuthService) ResetPassword(token, newPassword string) error {
+func (m *MockAuthService) SetPhoneNumber(ctx context.Context, userID, phoneNumber string) error {
+	return nil
+}
+
+func (m *MockAuthService) VerifyPhoneNumber(ctx context.Context, userID, code string) error {
+	return nil
+}
+
+func (m *MockAuthService) SetTwoFactorMethod(ctx context.Context, userID, method string) error {
+	return nil
+}
+
+func (m *MockAuthService) DisableSMSTwoFactor(ctx context.Context, userID, password string) error {
+	return nil
+}
+
+func (m *MockAuthService) ResendTwoFactorCode(ctx context.Context, challengeToken string) error {
 	return nil
 }
 
@@ -73,7 +211,7 @@ func NewMockAuthHandler() *handlers.AuthHandler {
 func NewMockAuthManager() *auth.AuthManager {
 	// Create in-memory database for testing
 	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	db.AutoMigrate(&models.User{}, &models.Session{})
+	db.AutoMigrate(&models.User{}, &models.Session{}, &models.TOTPSecret{}, &models.LoginAttempt{})
 
 	userAdapter := gormadapter.NewUserAdapter(db)
 	sessionAdapter := gormadapter.NewSessionAdapter(db)
@@ -118,6 +256,13 @@ func TestSetupRouter(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			expectedBody:   map[string]string{"status": "ok"},
 		},
+		{
+			name:           "Liveness endpoint",
+			method:         "GET",
+			path:           "/healthz",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]string{"status": "ok"},
+		},
 	}
 
 	// Run test cases
@@ -178,6 +323,90 @@ func TestRateLimiting(t *testing.T) {
 			}
 		}
 	})
+
+	// Checking emails shares the auth limit, so it can't be used to enumerate
+	// accounts at speed
+	t.Run("Email check rate limiting", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/auth/check-email", strings.NewReader(`{"email":"someone@example.com"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = "192.0.2.2:1234"
+			router.ServeHTTP(w, req)
+
+			if i < 3 {
+				if w.Code != http.StatusOK {
+					t.Errorf("Request %d: expected status 200, got %d", i+1, w.Code)
+				}
+			} else if w.Code != http.StatusTooManyRequests {
+				t.Errorf("Request %d should be rate limited", i+1)
+			}
+		}
+	})
+
+	// Route overrides replace the group rule, relative to the base path
+	t.Run("Route override", func(t *testing.T) {
+		cfg := &config.Config{
+			Server: config.ServerConfig{BasePath: "/api"},
+			RateLimit: config.RateLimitConfig{Routes: map[string]config.RateLimitRuleConfig{
+				"post /auth/login": {Requests: 1, Window: time.Minute},
+			}},
+		}
+		router := SetupRouter(NewMockAuthHandler(), NewMockAuthManager(), WithConfig(cfg))
+
+		for i, path := range []string{"/api/auth/login", "/api/auth/login", "/api/auth/check-email"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", path, strings.NewReader(`{"email":"someone@example.com"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = "192.0.2.3:1234"
+			router.ServeHTTP(w, req)
+
+			limited := w.Code == http.StatusTooManyRequests
+			if limited != (i == 1) {
+				t.Errorf("Request %d to %s: got status %d", i+1, path, w.Code)
+			}
+		}
+	})
+
+	// Requests without a session use up the limit before being rejected
+	t.Run("Unauthenticated requests", func(t *testing.T) {
+		for i := 0; i < 25; i++ {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/protected", nil)
+			req.Header.Set("Authorization", "Bearer unknown")
+			req.RemoteAddr = "192.0.2.4:1234"
+			router.ServeHTTP(w, req)
+
+			if i < 20 {
+				if w.Code != http.StatusUnauthorized {
+					t.Errorf("Request %d: expected status 401, got %d", i+1, w.Code)
+				}
+			} else if w.Code != http.StatusTooManyRequests {
+				t.Errorf("Request %d should be rate limited, got %d", i+1, w.Code)
+			}
+		}
+	})
+
+	// Rules keyed by user are counted once the user is known
+	t.Run("Keyed by user", func(t *testing.T) {
+		db, authManager := newTestAuthManager()
+		cfg := &config.Config{RateLimit: config.RateLimitConfig{Key: config.RateLimitKeyUser, API: config.RateLimitRuleConfig{Requests: 1, Window: time.Minute}}}
+		router := SetupRouter(NewMockAuthHandler(), authManager, WithConfig(cfg))
+		sessions := []string{loginAs(t, db, authManager, "alice", "user"), loginAs(t, db, authManager, "bob", "user")}
+
+		for i, sessionID := range []string{sessions[0], sessions[1], sessions[0]} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/protected", nil)
+			req.Header.Set("Authorization", "Bearer "+sessionID)
+			req.RemoteAddr = "192.0.2.5:1234"
+			router.ServeHTTP(w, req)
+
+			limited := w.Code == http.StatusTooManyRequests
+			if limited != (i == 2) {
+				t.Errorf("Request %d: got status %d", i+1, w.Code)
+			}
+		}
+	})
 }
 
 func TestProtectedRoutes(t *testing.T) {
@@ -210,6 +439,49 @@ func TestProtectedRoutes(t *testing.T) {
 			withAuth:       false,
 			expectedStatus: http.StatusUnauthorized,
 		},
+		{
+			name:           "List sessions without auth",
+			method:         "GET",
+			path:           "/api/me/sessions",
+			withAuth:       false,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Revoke session without auth",
+			method:         "DELETE",
+			path:           "/api/me/sessions/abc",
+			withAuth:       false,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Revoke other sessions without auth",
+			method:         "POST",
+			path:           "/api/me/sessions/revoke-others",
+			withAuth:       false,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Enroll TOTP without auth",
+			method:         "POST",
+			path:           "/api/me/2fa/totp",
+			withAuth:       false,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			// Public: reaches the handler, which rejects the empty body
+			name:           "Complete two-factor login without auth",
+			method:         "POST",
+			path:           "/auth/login/2fa",
+			withAuth:       false,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Refresh without auth",
+			method:         "POST",
+			path:           "/auth/refresh",
+			withAuth:       false,
+			expectedStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -229,3 +501,1163 @@ func TestProtectedRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestSetupRouter_BasePath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{Server: config.ServerConfig{BasePath: "/api/"}}
+	router := SetupRouter(NewMockAuthHandler(), NewMockAuthManager(), WithConfig(cfg))
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{name: "Ping under base path", path: "/api/ping", expectedStatus: http.StatusOK},
+		{name: "Health under base path", path: "/api/health", expectedStatus: http.StatusOK},
+		{name: "Ping at root", path: "/ping", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestSetupRouter_HTTPCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, authManager := newTestAuthManager()
+	cfg := &config.Config{
+		Server:    config.ServerConfig{BasePath: "/v1"},
+		HTTPCache: config.HTTPCacheConfig{ETags: true, Routes: map[string]time.Duration{"get /api/me": time.Minute}},
+	}
+	router := SetupRouter(NewMockAuthHandler(), authManager, WithConfig(cfg))
+	sessionID := loginAs(t, db, authManager, "alice", "user")
+
+	get := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+sessionID)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("/v1/api/me", nil)
+	if first.Code != http.StatusOK || first.Header().Get(middleware.CacheHeader) != "MISS" {
+		t.Fatalf("expected a cache miss, got %d %q: %s", first.Code, first.Header().Get(middleware.CacheHeader), first.Body.String())
+	}
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+	if got := first.Header().Get("Cache-Control"); got != "private, no-cache" {
+		t.Errorf("expected a private response, got Cache-Control %q", got)
+	}
+
+	second := get("/v1/api/me", nil)
+	if second.Header().Get(middleware.CacheHeader) != "HIT" || second.Body.String() != first.Body.String() {
+		t.Errorf("expected the cached response, got %q: %s", second.Header().Get(middleware.CacheHeader), second.Body.String())
+	}
+	if w := get("/v1/api/me", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %d", w.Code)
+	}
+
+	// Routes outside http_cache.routes only get an ETag
+	w := get("/v1/ping", nil)
+	if w.Header().Get(middleware.CacheHeader) != "" || w.Header().Get("ETag") == "" {
+		t.Errorf("expected only an ETag on /v1/ping, got X-Cache %q, ETag %q", w.Header().Get(middleware.CacheHeader), w.Header().Get("ETag"))
+	}
+
+	cfg = &config.Config{HTTPCache: config.HTTPCacheConfig{ETags: false}}
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+	SetupRouter(NewMockAuthHandler(), authManager, WithConfig(cfg)).ServeHTTP(w, req)
+	if w.Header().Get("ETag") != "" {
+		t.Errorf("expected no ETag with http_cache.etags off, got %q", w.Header().Get("ETag"))
+	}
+}
+
+func TestSetupRouter_HTTPCacheAfterRouteChecks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, authManager := newTestAuthManager()
+	cfg := &config.Config{
+		HTTPCache: config.HTTPCacheConfig{Routes: map[string]time.Duration{"GET /api/admin/dashboard": time.Minute}},
+		RateLimit: config.RateLimitConfig{API: config.RateLimitRuleConfig{Requests: 2, Window: time.Minute}},
+	}
+	router := SetupRouter(NewMockAuthHandler(), authManager, WithConfig(cfg))
+	sessionID := loginAs(t, db, authManager, "root", "admin")
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/admin/dashboard", nil)
+		req.Header.Set("Authorization", "Bearer "+sessionID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get(); w.Code != http.StatusOK || w.Header().Get(middleware.CacheHeader) != "MISS" {
+		t.Fatalf("expected a cache miss, got %d %q", w.Code, w.Header().Get(middleware.CacheHeader))
+	}
+
+	// A cached response still goes through the route's permission check
+	db.Model(&models.User{}).Where("username = ?", "root").Update("role", "user")
+	if w := get(); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 once the permission is gone, got %d %q", w.Code, w.Header().Get(middleware.CacheHeader))
+	}
+
+	// and through the rate limit
+	if w := get(); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 past the api rate limit, got %d", w.Code)
+	}
+}
+
+func TestSetupRouter_Metrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := SetupRouter(NewMockAuthHandler(), NewMockAuthManager())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	for _, name := range []string{"gosveltekit_auth_login_failures_total", "gosveltekit_auth_registration_conflicts_total", "gosveltekit_auth_login_successes_total"} {
+		if !strings.Contains(w.Body.String(), "# HELP "+name) {
+			t.Errorf("expected metrics output to describe %s", name)
+		}
+	}
+
+	// The request above was measured by the middleware
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `gosveltekit_http_requests_total{method="GET",route="/metrics",status="200"}`) {
+		t.Errorf("expected HTTP requests to be counted")
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		router := SetupRouter(NewMockAuthHandler(), NewMockAuthManager(), WithConfig(&config.Config{}))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
+
+func TestSetupRouter_Environment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		environment  config.Environment
+		secureCookie bool
+		exposeStack  bool
+	}{
+		{name: "production", environment: config.EnvProduction, secureCookie: true, exposeStack: false},
+		{name: "development", environment: config.EnvDevelopment, secureCookie: false, exposeStack: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Environment: tt.environment}
+			router := SetupRouter(NewMockAuthHandler(), NewMockAuthManager(), WithConfig(cfg), WithPublicRoutes("GET /panic"))
+			router.GET("/panic", func(c *gin.Context) {
+				panic("database password is hunter2")
+			})
+
+			// Session cookie set on login
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/auth/login", strings.NewReader(`{"username":"testuser","password":"Password123!"}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected login status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+			cookie := w.Header().Get("Set-Cookie")
+			if strings.Contains(cookie, "Secure") != tt.secureCookie {
+				t.Errorf("Expected Secure=%v in cookie, got %q", tt.secureCookie, cookie)
+			}
+
+			// Internal error details
+			w = httptest.NewRecorder()
+			req, _ = http.NewRequest("GET", "/panic", nil)
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+			}
+			body := w.Body.String()
+			if strings.Contains(body, "hunter2") != tt.exposeStack || strings.Contains(body, "stack") != tt.exposeStack {
+				t.Errorf("Expected internals exposed=%v, got %s", tt.exposeStack, body)
+			}
+		})
+	}
+}
+
+func TestSetupRouter_Fallbacks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := SetupRouter(NewMockAuthHandler(), NewMockAuthManager())
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedError  string
+		expectedAllow  string
+	}{
+		{name: "Unknown path", method: "GET", path: "/does-not-exist", expectedStatus: http.StatusNotFound, expectedError: "rota não encontrada"},
+		{name: "Wrong method on known path", method: "DELETE", path: "/ping", expectedStatus: http.StatusMethodNotAllowed, expectedError: "método não permitido", expectedAllow: "GET"},
+		{name: "GET on POST-only route", method: "GET", path: "/auth/login", expectedStatus: http.StatusMethodNotAllowed, expectedError: "método não permitido", expectedAllow: "POST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, apierror.ContentType) {
+				t.Errorf("Expected problem response, got Content-Type %q", contentType)
+			}
+
+			var response map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response["error"] != tt.expectedError || response["detail"] != tt.expectedError {
+				t.Errorf("Expected error %q, got %v", tt.expectedError, response)
+			}
+			if response["status"] != float64(tt.expectedStatus) || response["instance"] != tt.path {
+				t.Errorf("Expected status %d and instance %q, got %v", tt.expectedStatus, tt.path, response)
+			}
+			if allow := w.Header().Get("Allow"); allow != tt.expectedAllow {
+				t.Errorf("Expected Allow %q, got %q", tt.expectedAllow, allow)
+			}
+		})
+	}
+}
+
+func TestSetupRouter_PublicRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ok := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+
+	tests := []struct {
+		name string
+		cfg  *config.Config
+		opts []Option
+		// prefix is where the extra routes are mounted
+		prefix string
+	}{
+		{name: "option", opts: []Option{WithPublicRoutes("GET /listed/:id")}},
+		{name: "config", cfg: &config.Config{Server: config.ServerConfig{PublicRoutes: []string{"GET /listed/:id"}}}},
+		{name: "config with base path", cfg: &config.Config{Server: config.ServerConfig{BasePath: "/v1", PublicRoutes: []string{"get /listed/:id"}}}, prefix: "/v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			if tt.cfg != nil {
+				opts = append(opts, WithConfig(tt.cfg))
+			}
+			router := SetupRouter(NewMockAuthHandler(), NewMockAuthManager(), opts...)
+			router.GET(tt.prefix+"/unlisted", ok)
+			router.GET(tt.prefix+"/listed/:id", ok)
+
+			cases := []struct {
+				method         string
+				path           string
+				expectedStatus int
+			}{
+				// New routes are protected unless listed
+				{method: "GET", path: tt.prefix + "/unlisted", expectedStatus: http.StatusUnauthorized},
+				{method: "GET", path: tt.prefix + "/listed/42", expectedStatus: http.StatusOK},
+				// Listing is per method
+				{method: "POST", path: tt.prefix + "/listed/42", expectedStatus: http.StatusMethodNotAllowed},
+				// Built-in public routes stay open
+				{method: "GET", path: tt.prefix + "/health", expectedStatus: http.StatusOK},
+				{method: "GET", path: tt.prefix + "/auth/password-reset/validate?token=abc", expectedStatus: http.StatusOK},
+				{method: "GET", path: tt.prefix + "/auth/reset/validate?token=abc", expectedStatus: http.StatusOK},
+				{method: "GET", path: tt.prefix + "/api/me", expectedStatus: http.StatusUnauthorized},
+			}
+			for _, c := range cases {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest(c.method, c.path, nil)
+				router.ServeHTTP(w, req)
+
+				if w.Code != c.expectedStatus {
+					t.Errorf("%s %s: expected status %d, got %d", c.method, c.path, c.expectedStatus, w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestSetupRouter_RequestSizeLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{Server: config.ServerConfig{MaxURLLength: 256, MaxHeaderBytes: 4096}}
+	router := SetupRouter(NewMockAuthHandler(), NewMockAuthManager(), WithConfig(cfg))
+
+	server := httptest.NewUnstartedServer(router)
+	server.Config.MaxHeaderBytes = cfg.Server.MaxHeaderBytes
+	server.Start()
+	defer server.Close()
+
+	get := func(path string, header http.Header) int {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := get("/ping?q="+strings.Repeat("a", 100), nil); status != http.StatusOK {
+		t.Errorf("Expected status %d within the limit, got %d", http.StatusOK, status)
+	}
+	if status := get("/ping?q="+strings.Repeat("a", 300), nil); status != http.StatusRequestURITooLong {
+		t.Errorf("Expected status %d for an over-long query string, got %d", http.StatusRequestURITooLong, status)
+	}
+	// net/http allows a little slack over MaxHeaderBytes, so go well beyond it
+	big := http.Header{"X-Filler": []string{strings.Repeat("a", 16<<10)}}
+	if status := get("/ping", big); status != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("Expected status %d for oversized headers, got %d", http.StatusRequestHeaderFieldsTooLarge, status)
+	}
+}
+
+func TestSetupRouter_PasswordChangeRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db.AutoMigrate(&models.User{}, &models.Session{}, &models.TOTPSecret{}, &models.LoginAttempt{})
+	userAdapter := gormadapter.NewUserAdapter(db)
+	authManager := auth.NewAuthManager(userAdapter, gormadapter.NewSessionAdapter(db), auth.DefaultAuthConfig())
+	router := SetupRouter(NewMockAuthHandler(), authManager)
+
+	ctx := context.Background()
+	if _, err := userAdapter.CreateUser(ctx, auth.CreateUserInput{Identifier: "bob", Email: "bob@example.com", Password: "Passw0rd!", DisplayName: "Bob"}); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	db.Model(&models.User{}).Where("username = ?", "bob").Update("must_change_password", true)
+
+	// Login succeeds, the session is restricted
+	session, user, err := authManager.Login(ctx, "bob", "Passw0rd!", auth.SessionMetadata{})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if !user.MustChangePassword() {
+		t.Fatal("expected the user to be flagged")
+	}
+
+	tests := []struct {
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{http.MethodGet, "/api/protected", "", http.StatusForbidden},
+		{http.MethodGet, "/api/admin/dashboard", "", http.StatusForbidden},
+		{http.MethodGet, "/api/me", "", http.StatusOK},
+		{http.MethodPost, "/api/me/password", `{"current_password":"Passw0rd!","new_password":"N3w-Passw0rd!","confirm_password":"N3w-Passw0rd!"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer "+session.ID)
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.expectedStatus {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tt.method, tt.path, tt.expectedStatus, w.Code, w.Body.String())
+		}
+		if tt.expectedStatus == http.StatusForbidden && !strings.Contains(w.Body.String(), `"code":"password_change_required"`) {
+			t.Errorf("%s %s: expected password_change_required, got %s", tt.method, tt.path, w.Body.String())
+		}
+	}
+
+	// Once the password is changed the session is no longer restricted
+	if err := authManager.UpdatePassword(ctx, user.ID, "N3w-Passw0rd!"); err != nil {
+		t.Fatalf("failed to update password: %v", err)
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+session.ID)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 after the password change, got %d", w.Code)
+	}
+}
+
+func TestSetupRouter_UnverifiedEmailRestricted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, authManager := newTestAuthManager()
+	cfg := &config.Config{Auth: config.AuthConfig{UnverifiedLogin: config.UnverifiedLoginRestricted}}
+	router := SetupRouter(NewMockAuthHandler(), authManager, WithConfig(cfg))
+
+	sessionID := loginAs(t, db, authManager, "carol", "user")
+	tests := []struct {
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{http.MethodGet, "/api/protected", http.StatusForbidden},
+		{http.MethodGet, "/api/me", http.StatusOK},
+		{http.MethodPost, "/auth/resend-verification", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(`{"email":"carol@example.com"}`))
+		req.Header.Set("Authorization", "Bearer "+sessionID)
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != tt.expectedStatus {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tt.method, tt.path, tt.expectedStatus, w.Code, w.Body.String())
+		}
+		if tt.expectedStatus == http.StatusForbidden && !strings.Contains(w.Body.String(), `"code":"email_verification_required"`) {
+			t.Errorf("%s %s: expected email_verification_required, got %s", tt.method, tt.path, w.Body.String())
+		}
+	}
+
+	// Verified users are no longer restricted
+	db.Model(&models.User{}).Where("username = ?", "carol").Update("email_verified", true)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+sessionID)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 once verified, got %d", w.Code)
+	}
+}
+
+func TestSetupRouter_CookieTransport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, authManager := newTestAuthManager()
+	cfg := &config.Config{Auth: config.AuthConfig{TokenTransport: config.TokenTransportCookie}}
+	router := SetupRouter(NewMockAuthHandler(), authManager, WithConfig(cfg))
+	sessionID := loginAs(t, db, authManager, "dave", "user")
+
+	send := func(method, path, csrfToken string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(`{"username":"dave","password":"Passw0rd!"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if csrfToken != "" {
+			req.Header.Set(middleware.CSRFHeaderName, csrfToken)
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The token comes both in the body and in the cookie
+	w := send(http.MethodGet, "/auth/csrf", "")
+	var csrf struct {
+		CSRFToken string `json:"csrf_token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &csrf); err != nil || csrf.CSRFToken == "" {
+		t.Fatalf("expected a CSRF token, got %d: %s", w.Code, w.Body.String())
+	}
+	csrfCookie := &http.Cookie{Name: middleware.CSRFCookieName, Value: csrf.CSRFToken}
+
+	if w := send(http.MethodPost, "/auth/login", "", csrfCookie); w.Code != http.StatusForbidden {
+		t.Errorf("expected login without the CSRF header to be rejected, got %d", w.Code)
+	}
+	w = send(http.MethodPost, "/auth/login", csrf.CSRFToken, csrfCookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected login to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "mock-session-id") {
+		t.Errorf("expected the session only in the cookie, got %s", w.Body.String())
+	}
+	var session *http.Cookie
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == middleware.SessionCookieName {
+			session = cookie
+		}
+	}
+	if session == nil || session.Value != "mock-session-id" || !session.HttpOnly || session.SameSite != http.SameSiteLaxMode {
+		t.Errorf("expected an HttpOnly Lax session cookie, got %+v", session)
+	}
+
+	// Sessions are only read from the cookie
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/me", nil)
+	req.Header.Set("Authorization", "Bearer "+sessionID)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the Authorization header to be ignored, got %d", w.Code)
+	}
+	sessionCookie := &http.Cookie{Name: middleware.SessionCookieName, Value: sessionID}
+	if w := send(http.MethodGet, "/api/me", "", sessionCookie); w.Code != http.StatusOK {
+		t.Errorf("expected the session cookie to authenticate, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The settings belong to the router: another one keeps the defaults
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/api/me", nil)
+	req.Header.Set("Authorization", "Bearer "+sessionID)
+	SetupRouter(NewMockAuthHandler(), authManager).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the Authorization header to work on a default router, got %d", w.Code)
+	}
+
+	if w := send(http.MethodPost, "/api/logout", "", sessionCookie, csrfCookie); w.Code != http.StatusForbidden {
+		t.Errorf("expected logout without the CSRF header to be rejected, got %d", w.Code)
+	}
+	if w := send(http.MethodPost, "/api/logout", csrf.CSRFToken, sessionCookie, csrfCookie); w.Code != http.StatusOK {
+		t.Errorf("expected logout to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// newTestAuthManager returns an AuthManager backed by a fresh in-memory database
+func newTestAuthManager() (*gorm.DB, *auth.AuthManager) {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db.AutoMigrate(&models.User{}, &models.Session{}, &models.TOTPSecret{}, &models.LoginAttempt{}, &models.APIKey{}, &models.RefreshToken{})
+	return db, auth.NewAuthManager(gormadapter.NewUserAdapter(db), gormadapter.NewSessionAdapter(db), auth.DefaultAuthConfig())
+}
+
+// loginAs creates a user with role and returns a session ID for it
+func loginAs(t *testing.T, db *gorm.DB, authManager *auth.AuthManager, username, role string) string {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := gormadapter.NewUserAdapter(db).CreateUser(ctx, auth.CreateUserInput{Identifier: username, Email: username + "@example.com", Password: "Passw0rd!", DisplayName: username}); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	db.Model(&models.User{}).Where("username = ?", username).Update("role", role)
+	session, _, err := authManager.Login(ctx, username, "Passw0rd!", auth.SessionMetadata{})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	return session.ID
+}
+
+func TestSetupRouter_Avatars(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, authManager := newTestAuthManager()
+	store := storage.NewLocal(t.TempDir(), "/files", []byte("0123456789abcdef0123456789abcdef"))
+	authHandler := handlers.NewAuthHandler(&MockAuthService{}, handlers.WithAvatarStorage(store, config.StorageConfig{
+		SignedURLTTL: time.Hour,
+		Avatar:       config.AvatarConfig{MaxBytes: 1 << 20, Size: 64},
+	}))
+	router := SetupRouter(authHandler, authManager, WithFileHandler(handlers.NewFileHandler(store)))
+	sessionID := loginAs(t, db, authManager, "erin", "user")
+
+	upload := func(sessionID string) *httptest.ResponseRecorder {
+		var picture, body bytes.Buffer
+		if err := png.Encode(&picture, image.NewRGBA(image.Rect(0, 0, 100, 100))); err != nil {
+			t.Fatalf("failed to encode picture: %v", err)
+		}
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("avatar", "me.png")
+		part.Write(picture.Bytes())
+		writer.Close()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPut, "/api/me/avatar", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		if sessionID != "" {
+			req.Header.Set("Authorization", "Bearer "+sessionID)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := upload(""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected anonymous upload to be rejected, got %d", w.Code)
+	}
+	// Multipart, despite the JSON-only api group
+	w := upload(sessionID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp struct {
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.AvatarURL == "" {
+		t.Fatalf("expected an avatar_url, got %s", w.Body.String())
+	}
+
+	// The signed link works without a session
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, resp.AvatarURL, nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("expected the avatar, got %d: %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestSetupRouter_Realtime(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, authManager := newTestAuthManager()
+	authHandler := handlers.NewAuthHandler(&MockAuthService{})
+	hub := realtime.NewHub(realtime.Config{})
+	router := SetupRouter(authHandler, authManager, WithRealtimeHandler(handlers.NewRealtimeHandler(hub)))
+	sessionID := loginAs(t, db, authManager, "frank", "user")
+
+	connect := func(sessionID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ws", nil)
+		if sessionID != "" {
+			req.Header.Set("Authorization", "Bearer "+sessionID)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := connect(""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected anonymous connection to be rejected, got %d", w.Code)
+	}
+	// Authenticated, but not a WebSocket handshake
+	if w := connect(sessionID); w.Code != http.StatusUpgradeRequired {
+		t.Errorf("expected status %d, got %d: %s", http.StatusUpgradeRequired, w.Code, w.Body.String())
+	}
+}
+
+func TestSetupRouter_APIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, authManager := newTestAuthManager()
+	router := SetupRouter(NewMockAuthHandler(), authManager,
+		WithAPIKeyHandler(handlers.NewAPIKeyHandler(authManager)),
+		WithLockoutHandler(handlers.NewLockoutHandler(authManager)),
+	)
+	sessionID := loginAs(t, db, authManager, "root", "admin")
+	var admin models.User
+	db.Where("username = ?", "root").First(&admin)
+
+	// Created with a session, then used without one
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/me/api-keys", strings.NewReader(`{"name":"cron","scopes":["users:read"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+sessionID)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	auditKey, _, err := authManager.CreateAPIKey(context.Background(), admin.PublicID(), "audit", []string{"audit:read"}, nil)
+	if err != nil {
+		t.Fatalf("failed to create api key: %v", err)
+	}
+
+	// Keys for other users follow the rules of impersonation
+	loginAs(t, db, authManager, "other-admin", "admin")
+	loginAs(t, db, authManager, "bob", "user")
+	var otherAdmin, bob models.User
+	db.Where("username = ?", "other-admin").First(&otherAdmin)
+	db.Where("username = ?", "bob").First(&bob)
+	for _, tt := range []struct {
+		name   string
+		userID string
+		status int
+	}{
+		{"admin target", otherAdmin.PublicID(), http.StatusForbidden},
+		{"self", admin.PublicID(), http.StatusForbidden},
+		{"scope not granted to the role", bob.PublicID(), http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/api/admin/users/"+tt.userID+"/api-keys", strings.NewReader(`{"name":"cron","scopes":["users:read"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+sessionID)
+		router.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.status, w.Code, w.Body.String())
+		}
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		authorization  string
+		expectedStatus int
+	}{
+		{"identity", http.MethodGet, "/api/me", "ApiKey " + created.Key, http.StatusOK},
+		{"scheme is case-insensitive", http.MethodGet, "/api/admin/lockouts", "apikey " + created.Key, http.StatusOK},
+		{"missing scope", http.MethodGet, "/api/admin/lockouts", "ApiKey " + auditKey, http.StatusForbidden},
+		{"write with read scope", http.MethodPost, "/api/admin/users/1/unlock", "ApiKey " + created.Key, http.StatusForbidden},
+		{"session route", http.MethodGet, "/api/me/api-keys", "ApiKey " + created.Key, http.StatusForbidden},
+		{"logout", http.MethodPost, "/api/logout", "ApiKey " + created.Key, http.StatusForbidden},
+		{"unknown key", http.MethodGet, "/api/me", "ApiKey ak_0123456789abcdef_" + strings.Repeat("0", 64), http.StatusUnauthorized},
+		{"malformed key", http.MethodGet, "/api/me", "ApiKey ", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", tt.authorization)
+			router.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusUnauthorized && !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "ApiKey") {
+				t.Errorf("expected an ApiKey challenge, got %q", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	// Revoked keys stop working
+	var listed struct {
+		APIKeys []struct {
+			ID string `json:"id"`
+		} `json:"api_keys"`
+	}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/api/me/api-keys", nil)
+	req.Header.Set("Authorization", "Bearer "+sessionID)
+	router.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil || len(listed.APIKeys) != 2 {
+		t.Fatalf("expected 2 api keys, got %s", w.Body.String())
+	}
+	for _, key := range listed.APIKeys {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodDelete, "/api/me/api-keys/"+key.ID, nil)
+		req.Header.Set("Authorization", "Bearer "+sessionID)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/api/me", nil)
+	req.Header.Set("Authorization", "ApiKey "+created.Key)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected revoked key to be rejected, got %d", w.Code)
+	}
+}
+
+func TestSetupRouter_Diagnostics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, authManager := newTestAuthManager()
+	sqlDB, _ := db.DB()
+	router := SetupRouter(NewMockAuthHandler(), authManager,
+		WithDiagnosticsHandler(handlers.NewDiagnosticsHandler(sqlDB, time.Now())),
+	)
+	adminSession := loginAs(t, db, authManager, "root", "admin")
+	userSession := loginAs(t, db, authManager, "bob", "user")
+
+	tests := []struct {
+		name           string
+		sessionID      string
+		expectedStatus int
+	}{
+		{name: "anonymous", expectedStatus: http.StatusUnauthorized},
+		{name: "user", sessionID: userSession, expectedStatus: http.StatusForbidden},
+		{name: "admin", sessionID: adminSession, expectedStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/api/admin/diagnostics", nil)
+			if tt.sessionID != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.sessionID)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			for _, field := range []string{"started_at", "uptime_seconds", "runtime", "memory", "gc", "database"} {
+				if _, ok := body[field]; !ok {
+					t.Errorf("expected field %q in %s", field, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestSetupRouter_AdminSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, authManager := newTestAuthManager()
+	router := SetupRouter(NewMockAuthHandler(), authManager,
+		WithSessionHandler(handlers.NewSessionHandler(authManager)),
+	)
+	adminSession := loginAs(t, db, authManager, "root", "admin")
+	userSession := loginAs(t, db, authManager, "bob", "user")
+
+	tests := []struct {
+		name           string
+		sessionID      string
+		expectedStatus int
+	}{
+		{name: "anonymous", expectedStatus: http.StatusUnauthorized},
+		{name: "user", sessionID: userSession, expectedStatus: http.StatusForbidden},
+		{name: "admin", sessionID: adminSession, expectedStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/api/admin/sessions", nil)
+			if tt.sessionID != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.sessionID)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var body struct {
+				Data []map[string]any `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(body.Data) != 2 {
+				t.Errorf("expected the sessions of both users, got %s", w.Body.String())
+			}
+			if strings.Contains(w.Body.String(), adminSession) || strings.Contains(w.Body.String(), userSession) {
+				t.Errorf("session IDs leaked: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSetupRouter_RevokeToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, authManager := newTestAuthManager()
+	authService := service.NewAuthService(authManager, gormadapter.NewUserAdapter(db), email.NewMockEmailService())
+	router := SetupRouter(handlers.NewAuthHandler(authService), authManager)
+	current := loginAs(t, db, authManager, "bob", "user")
+	discarded, _, err := authManager.Login(context.Background(), "bob", "Passw0rd!", auth.SessionMetadata{})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	revoke := func(sessionID, token string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/auth/revoke", strings.NewReader(`{"token":"`+token+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if sessionID != "" {
+			req.Header.Set("Authorization", "Bearer "+sessionID)
+		}
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := revoke("", discarded.ID); code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 without a session, got %d", code)
+	}
+	for i := range 2 {
+		if code := revoke(current, discarded.ID); code != http.StatusOK {
+			t.Fatalf("revoke #%d: expected status 200, got %d", i+1, code)
+		}
+	}
+	if _, _, err := authManager.ValidateSession(context.Background(), discarded.ID); err == nil {
+		t.Error("expected the revoked session to be invalid")
+	}
+	if _, _, err := authManager.ValidateSession(context.Background(), current); err != nil {
+		t.Errorf("expected the current session to stay valid, got %v", err)
+	}
+}
+
+func TestSetupRouter_RevokeRefreshToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db.AutoMigrate(&models.User{}, &models.Session{}, &models.TOTPSecret{}, &models.LoginAttempt{}, &models.RefreshToken{})
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.RefreshTokenDuration = 24 * time.Hour
+	authManager := auth.NewAuthManager(gormadapter.NewUserAdapter(db), gormadapter.NewSessionAdapter(db), authConfig)
+	authService := service.NewAuthService(authManager, gormadapter.NewUserAdapter(db), email.NewMockEmailService())
+	router := SetupRouter(handlers.NewAuthHandler(authService), authManager)
+	current := loginAs(t, db, authManager, "bob", "user")
+	discarded, _, err := authManager.Login(context.Background(), "bob", "Passw0rd!", auth.SessionMetadata{})
+	if err != nil || discarded.RefreshToken == "" {
+		t.Fatalf("login failed: %v", err)
+	}
+	other := loginAs(t, db, authManager, "eve", "user")
+
+	post := func(path, sessionID, body string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if sessionID != "" {
+			req.Header.Set("Authorization", "Bearer "+sessionID)
+		}
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post("/auth/revoke", other, `{"token":"`+discarded.RefreshToken+`"}`); code != http.StatusForbidden {
+		t.Fatalf("expected status 403 for another user's refresh token, got %d", code)
+	}
+	if code := post("/auth/revoke", current, `{"token":"`+discarded.RefreshToken+`"}`); code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if code := post("/auth/refresh", "", `{"refresh_token":"`+discarded.RefreshToken+`"}`); code != http.StatusUnauthorized {
+		t.Errorf("expected the revoked refresh token to be rejected with 401, got %d", code)
+	}
+	if _, _, err := authManager.ValidateSession(context.Background(), discarded.ID); err == nil {
+		t.Error("expected the session of the revoked refresh token to end too")
+	}
+	if _, _, err := authManager.ValidateSession(context.Background(), current); err != nil {
+		t.Errorf("expected the current session to stay valid, got %v", err)
+	}
+}
+
+func TestSetupRouter_AdminPasswordReset(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, authManager := newTestAuthManager()
+	router := SetupRouter(NewMockAuthHandler(), authManager)
+	adminSession := loginAs(t, db, authManager, "root", "admin")
+	userSession := loginAs(t, db, authManager, "bob", "user")
+
+	tests := []struct {
+		name           string
+		sessionID      string
+		expectedStatus int
+	}{
+		{name: "anonymous", expectedStatus: http.StatusUnauthorized},
+		{name: "user", sessionID: userSession, expectedStatus: http.StatusForbidden},
+		{name: "admin", sessionID: adminSession, expectedStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/api/admin/users/2/reset-password", nil)
+			if tt.sessionID != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.sessionID)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// Sessions carry no role: it is read from the database on every request, so
+// a role change applies to the sessions already open
+func TestSetupRouter_RoleChangeTakesEffect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, authManager := newTestAuthManager()
+	router := SetupRouter(NewMockAuthHandler(), authManager)
+	sessionID := loginAs(t, db, authManager, "root", "admin")
+
+	dashboard := func() int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/admin/dashboard", nil)
+		req.Header.Set("Authorization", "Bearer "+sessionID)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := dashboard(); code != http.StatusOK {
+		t.Fatalf("expected status %d as admin, got %d", http.StatusOK, code)
+	}
+	db.Model(&models.User{}).Where("username = ?", "root").Update("role", "user")
+	if code := dashboard(); code != http.StatusForbidden {
+		t.Fatalf("expected status %d after the demotion, got %d", http.StatusForbidden, code)
+	}
+	db.Model(&models.User{}).Where("username = ?", "root").Update("role", "admin")
+	if code := dashboard(); code != http.StatusOK {
+		t.Fatalf("expected status %d after the promotion, got %d", http.StatusOK, code)
+	}
+}
+
+func TestSetupRouter_Pprof(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(router *gin.Engine, path, sessionID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		if sessionID != "" {
+			req.Header.Set("Authorization", "Bearer "+sessionID)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		db, authManager := newTestAuthManager()
+		router := SetupRouter(NewMockAuthHandler(), authManager, WithConfig(&config.Config{}))
+		adminSession := loginAs(t, db, authManager, "root", "admin")
+
+		for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/cmdline"} {
+			if w := request(router, path, adminSession); w.Code != http.StatusNotFound {
+				t.Errorf("%s: expected status 404, got %d", path, w.Code)
+			}
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		db, authManager := newTestAuthManager()
+		router := SetupRouter(NewMockAuthHandler(), authManager, WithConfig(&config.Config{Debug: config.DebugConfig{Pprof: true}}))
+		adminSession := loginAs(t, db, authManager, "root", "admin")
+		userSession := loginAs(t, db, authManager, "bob", "user")
+
+		for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap?debug=1", "/debug/pprof/cmdline"} {
+			if w := request(router, path, ""); w.Code != http.StatusUnauthorized {
+				t.Errorf("%s: expected status 401 without a session, got %d", path, w.Code)
+			}
+			if w := request(router, path, userSession); w.Code != http.StatusForbidden {
+				t.Errorf("%s: expected status 403 for a regular user, got %d", path, w.Code)
+			}
+			if w := request(router, path, adminSession); w.Code != http.StatusOK {
+				t.Errorf("%s: expected status 200 for an admin, got %d", path, w.Code)
+			}
+		}
+
+		if body := request(router, "/debug/pprof/heap?debug=1", adminSession).Body.String(); !strings.Contains(body, "heap profile") {
+			t.Errorf("expected a heap profile, got %.100s", body)
+		}
+	})
+}
+
+// stubOAuthProvider sends every login to a fixed provider page
+type stubOAuthProvider struct{}
+
+func (stubOAuthProvider) Name() string { return "github" }
+
+func (stubOAuthProvider) AuthCodeURL(ctx context.Context, state, verifier string) (string, error) {
+	return "https://provider.example.com/authorize", nil
+}
+
+func (stubOAuthProvider) Exchange(ctx context.Context, code, verifier string) (*oauth.Identity, error) {
+	return nil, context.Canceled
+}
+
+func TestSetupRouter_OAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Without providers there are no routes
+	router := SetupRouter(NewMockAuthHandler(), NewMockAuthManager())
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/auth/oauth/github/login", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	// Anonymous users reach the login route
+	router = SetupRouter(NewMockAuthHandler(), NewMockAuthManager(),
+		WithOAuthHandler(handlers.NewOAuthHandler(nil, []oauth.Provider{stubOAuthProvider{}}, "")),
+	)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/auth/oauth/github/login", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://provider.example.com/authorize" {
+		t.Errorf("expected a redirect to the provider, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestSetupRouter_PermissionPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, authManager := newTestAuthManager()
+	sqlDB, _ := db.DB()
+	policy := auth.NewPolicy([]auth.Role{
+		{Name: "admin", Permissions: []string{"users:read"}},
+		{Name: "user", Permissions: []string{"profile:read"}},
+	})
+	router := SetupRouter(NewMockAuthHandler(), authManager,
+		WithDiagnosticsHandler(handlers.NewDiagnosticsHandler(sqlDB, time.Now())),
+		WithSettingsHandler(handlers.NewSettingsHandler(settings.NewStore(db, settings.Config{}))),
+		WithSessionHandler(handlers.NewSessionHandler(authManager)),
+		WithRoleHandler(handlers.NewRoleHandler(policy)),
+		WithLockoutHandler(handlers.NewLockoutHandler(authManager)),
+		WithJobHandler(handlers.NewJobHandler(jobs.New(jobs.NewDBStore(db), jobs.Config{}))),
+		WithUserHandler(handlers.NewUserHandler(service.NewUserService(repository.NewUserRepository(db)))),
+		WithAuditHandler(handlers.NewAuditHandler(audit.NewStore(db))),
+		WithAPIKeyHandler(handlers.NewAPIKeyHandler(authManager)),
+		WithPermissionPolicy(policy),
+	)
+	adminSession := loginAs(t, db, authManager, "root", "admin")
+	userSession := loginAs(t, db, authManager, "bob", "user")
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		sessionID      string
+		expectedStatus int
+	}{
+		{name: "granted", method: http.MethodGet, path: "/api/admin/roles", sessionID: adminSession, expectedStatus: http.StatusOK},
+		{name: "granted sessions", method: http.MethodGet, path: "/api/admin/sessions", sessionID: adminSession, expectedStatus: http.StatusOK},
+		{name: "granted lockouts", method: http.MethodGet, path: "/api/admin/lockouts", sessionID: adminSession, expectedStatus: http.StatusOK},
+		{name: "granted user search", method: http.MethodGet, path: "/api/admin/users?q=root&active=true", sessionID: adminSession, expectedStatus: http.StatusOK},
+		{name: "disable needs users:write", method: http.MethodPost, path: "/api/admin/users/2/disable", sessionID: adminSession, expectedStatus: http.StatusForbidden},
+		{name: "batch needs users:write", method: http.MethodPost, path: "/api/admin/users/batch", sessionID: adminSession, expectedStatus: http.StatusForbidden},
+		{name: "audit log needs audit:read", method: http.MethodGet, path: "/api/admin/audit-logs", sessionID: adminSession, expectedStatus: http.StatusForbidden},
+		{name: "jobs need maintenance:run", method: http.MethodGet, path: "/api/admin/jobs", sessionID: adminSession, expectedStatus: http.StatusForbidden},
+		{name: "diagnostics need maintenance:run", method: http.MethodGet, path: "/api/admin/diagnostics", sessionID: adminSession, expectedStatus: http.StatusForbidden},
+		{name: "settings need settings:write", method: http.MethodGet, path: "/api/admin/settings", sessionID: adminSession, expectedStatus: http.StatusForbidden},
+		{name: "setting update needs settings:write", method: http.MethodPut, path: "/api/admin/settings/maintenance_mode", sessionID: adminSession, expectedStatus: http.StatusForbidden},
+		{name: "unlock needs users:write", method: http.MethodPost, path: "/api/admin/users/1/unlock", sessionID: adminSession, expectedStatus: http.StatusForbidden},
+		{name: "missing permission", method: http.MethodPost, path: "/api/admin/users/1/reset-password", sessionID: adminSession, expectedStatus: http.StatusForbidden},
+		{name: "api key issuance needs impersonate", method: http.MethodPost, path: "/api/admin/users/2/api-keys", sessionID: adminSession, expectedStatus: http.StatusForbidden},
+		{name: "admin role still required", method: http.MethodGet, path: "/api/admin/roles", sessionID: userSession, expectedStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.sessionID)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
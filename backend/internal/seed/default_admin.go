@@ -0,0 +1,44 @@
+package seed
+
+import (
+	"time"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultAdminUsername is the initial admin configs/app.yml shipped before
+// "server create-admin"
+const DefaultAdminUsername = "admin"
+
+// DisableDefaultAdmin deactivates the DefaultAdminUsername account once
+// another active admin has logged in at least once, so the well-known account
+// doesn't stay usable after real admins take over. It reports whether the
+// account was deactivated; a missing or already inactive account is left
+// alone. Deactivated users can't log in and their sessions stop validating.
+func DisableDefaultAdmin(db *gorm.DB) (bool, error) {
+	var admin models.User
+	err := db.Where("tenant_id = ? AND username = ? AND role = ? AND active = ?", "", DefaultAdminUsername, "admin", true).
+		Limit(1).Find(&admin).Error
+	if err != nil || admin.ID == 0 {
+		return false, err
+	}
+
+	var others int64
+	if err := db.Model(&models.User{}).
+		Where("tenant_id = ? AND role = ? AND active = ? AND id <> ? AND last_login > ?", "", "admin", true, admin.ID, time.Time{}).
+		Count(&others).Error; err != nil {
+		return false, err
+	}
+	if others == 0 {
+		return false, nil
+	}
+
+	if err := db.Model(&admin).Update("active", false).Error; err != nil {
+		return false, err
+	}
+	logger.Warn("Administrador padrão desativado: outro administrador já entrou", "username", DefaultAdminUsername, "user_id", admin.ID)
+	return true, nil
+}
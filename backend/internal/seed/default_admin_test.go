@@ -0,0 +1,42 @@
+package seed
+
+import (
+	"testing"
+	"time"
+
+	"gosveltekit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisableDefaultAdmin(t *testing.T) {
+	db := setupUsersDB(t)
+	defaultAdmin := models.User{Username: DefaultAdminUsername, Email: "admin@example.com", PasswordHash: "x", Role: "admin", Active: true}
+	realAdmin := models.User{Username: "root", Email: "root@example.com", PasswordHash: "x", Role: "admin", Active: true}
+	regular := models.User{Username: "bob", Email: "bob@example.com", PasswordHash: "x", Role: "user", Active: true, LastLogin: time.Now()}
+	for _, user := range []*models.User{&defaultAdmin, &realAdmin, &regular} {
+		require.NoError(t, db.Create(user).Error)
+	}
+	active := func() bool {
+		var user models.User
+		require.NoError(t, db.First(&user, defaultAdmin.ID).Error)
+		return user.Active
+	}
+
+	// Other users logging in, or admins that never did, don't count
+	disabled, err := DisableDefaultAdmin(db)
+	require.NoError(t, err)
+	assert.False(t, disabled)
+	assert.True(t, active())
+
+	require.NoError(t, db.Model(&realAdmin).Update("last_login", time.Now()).Error)
+	disabled, err = DisableDefaultAdmin(db)
+	require.NoError(t, err)
+	assert.True(t, disabled)
+	assert.False(t, active())
+
+	disabled, err = DisableDefaultAdmin(db)
+	require.NoError(t, err)
+	assert.False(t, disabled, "already inactive")
+}
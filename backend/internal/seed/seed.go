@@ -0,0 +1,92 @@
+// Package seed reconciles reference data declared in config with the
+// database at startup.
+//
+// Seeding is idempotent: running it again with the same config changes
+// nothing, and running it after editing the config applies only the
+// difference.
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrRoleNameRequired is returned for role definitions without a name
+var ErrRoleNameRequired = errors.New("papel sem nome na configuração")
+
+// EnsureRoles upserts every configured role and makes its stored permissions
+// match the config exactly: missing permissions are granted and permissions
+// no longer listed are revoked. Roles absent from the config are left alone,
+// since users may still hold them.
+func EnsureRoles(db *gorm.DB, roles []config.RoleConfig) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, rc := range roles {
+			if err := ensureRole(tx, rc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func ensureRole(tx *gorm.DB, rc config.RoleConfig) error {
+	name := strings.TrimSpace(rc.Name)
+	if name == "" {
+		return ErrRoleNameRequired
+	}
+
+	var role models.Role
+	if err := tx.Where(models.Role{Name: name}).
+		Assign(models.Role{Description: rc.Description}).
+		FirstOrCreate(&role).Error; err != nil {
+		return fmt.Errorf("falha ao salvar papel %q: %w", name, err)
+	}
+
+	wanted := make(map[string]bool, len(rc.Permissions))
+	for _, p := range rc.Permissions {
+		if p = strings.TrimSpace(p); p != "" {
+			wanted[p] = true
+		}
+	}
+
+	var stored []models.RolePermission
+	if err := tx.Where("role_id = ?", role.ID).Find(&stored).Error; err != nil {
+		return err
+	}
+
+	var revoked []uint
+	for _, rp := range stored {
+		if wanted[rp.Permission] {
+			delete(wanted, rp.Permission)
+			continue
+		}
+		revoked = append(revoked, rp.ID)
+	}
+	if len(revoked) > 0 {
+		if err := tx.Delete(&models.RolePermission{}, revoked).Error; err != nil {
+			return fmt.Errorf("falha ao revogar permissões do papel %q: %w", name, err)
+		}
+	}
+
+	granted := make([]models.RolePermission, 0, len(wanted))
+	for p := range wanted {
+		granted = append(granted, models.RolePermission{RoleID: role.ID, Permission: p})
+	}
+	if len(granted) > 0 {
+		if err := tx.Create(&granted).Error; err != nil {
+			return fmt.Errorf("falha ao conceder permissões ao papel %q: %w", name, err)
+		}
+	}
+
+	if len(granted) > 0 || len(revoked) > 0 {
+		logger.Info("Permissões do papel sincronizadas", "role", name, "granted", len(granted), "revoked", len(revoked))
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Package seed tests
+package seed
+
+import (
+	"sort"
+	"testing"
+
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Role{}, &models.RolePermission{}))
+	return db
+}
+
+func storedPermissions(t *testing.T, db *gorm.DB, name string) []string {
+	var role models.Role
+	require.NoError(t, db.Preload("Permissions").Where("name = ?", name).First(&role).Error)
+	perms := make([]string, len(role.Permissions))
+	for i, p := range role.Permissions {
+		perms[i] = p.Permission
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+func TestEnsureRoles(t *testing.T) {
+	db := setupTestDB(t)
+	roles := []config.RoleConfig{
+		{Name: "user", Permissions: []string{"profile:read"}},
+		{Name: "admin", Description: "Administrador", Permissions: []string{"users:read", "users:write"}},
+	}
+
+	require.NoError(t, EnsureRoles(db, roles))
+	assert.Equal(t, []string{"profile:read"}, storedPermissions(t, db, "user"))
+	assert.Equal(t, []string{"users:read", "users:write"}, storedPermissions(t, db, "admin"))
+
+	// Same config on the next boot: nothing is duplicated
+	require.NoError(t, EnsureRoles(db, roles))
+	var count int64
+	require.NoError(t, db.Model(&models.Role{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+	require.NoError(t, db.Model(&models.RolePermission{}).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+
+	// Adding a permission to config adds it to the stored role; removed ones are revoked
+	roles[0].Permissions = append(roles[0].Permissions, "profile:write")
+	roles[1].Permissions = []string{"users:read"}
+	roles[1].Description = "Admins"
+	require.NoError(t, EnsureRoles(db, roles))
+	assert.Equal(t, []string{"profile:read", "profile:write"}, storedPermissions(t, db, "user"))
+	assert.Equal(t, []string{"users:read"}, storedPermissions(t, db, "admin"))
+
+	var admin models.Role
+	require.NoError(t, db.Where("name = ?", "admin").First(&admin).Error)
+	assert.Equal(t, "Admins", admin.Description)
+
+	// Roles dropped from config are kept
+	require.NoError(t, EnsureRoles(db, roles[:1]))
+	assert.Equal(t, []string{"users:read"}, storedPermissions(t, db, "admin"))
+}
+
+func TestEnsureRoles_RequiresName(t *testing.T) {
+	db := setupTestDB(t)
+	err := EnsureRoles(db, []config.RoleConfig{{Name: "editor"}, {Name: " "}})
+	assert.ErrorIs(t, err, ErrRoleNameRequired)
+
+	// The whole seed is rolled back
+	var count int64
+	require.NoError(t, db.Model(&models.Role{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,135 @@
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/validation"
+
+	"gorm.io/gorm"
+)
+
+// DefaultPassword is the initial admin password configs/app.yml shipped
+// before "server create-admin". Users still holding it get a warning on every
+// startup.
+const DefaultPassword = "Tr0que-Esta-Senha!"
+
+var (
+	// ErrUserPasswordRequired is returned for initial users without a password
+	ErrUserPasswordRequired = errors.New("usuário inicial sem senha na configuração")
+	// ErrUserIdentityRequired is returned for initial users without a valid
+	// username or email
+	ErrUserIdentityRequired = errors.New("usuário inicial sem username ou email válido na configuração")
+	// ErrUserExists is returned by CreateUser for a username already taken,
+	// even by a soft-deleted user
+	ErrUserExists = errors.New("usuário já existe")
+)
+
+// EnsureUsers creates the configured initial users that don't exist yet,
+// matched by username, with their password hashed by hasher. Existing users,
+// even soft-deleted ones, are left alone, so changing a password or role in
+// the config has no effect once the user exists.
+//
+// Every password must follow the password policy; nothing is created when
+// one doesn't. Seeded users count as having a verified email.
+func EnsureUsers(db *gorm.DB, users []config.InitialUserConfig, hasher auth.PasswordHasher) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, uc := range users {
+			if err := ensureUser(tx, uc, hasher); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CreateUser creates one user with the same rules as EnsureUsers, but fails
+// with ErrUserExists instead of skipping a username that is already taken
+func CreateUser(db *gorm.DB, uc config.InitialUserConfig, hasher auth.PasswordHasher) error {
+	username, err := validateUser(uc)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Unscoped().Model(&models.User{}).Where("tenant_id = ? AND username = ?", "", username).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return fmt.Errorf("%w (%q)", ErrUserExists, username)
+		}
+		return createUser(tx, username, uc, hasher)
+	})
+}
+
+func ensureUser(tx *gorm.DB, uc config.InitialUserConfig, hasher auth.PasswordHasher) error {
+	username, err := validateUser(uc)
+	if err != nil {
+		return err
+	}
+
+	var existing models.User
+	err = tx.Unscoped().Where("tenant_id = ? AND username = ?", "", username).First(&existing).Error
+	switch {
+	case err == nil:
+		if hasher.Verify(existing.PasswordHash, DefaultPassword) {
+			logger.Warn("Usuário inicial ainda usa a senha padrão, troque-a", "username", username)
+		}
+		return nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	}
+	return createUser(tx, username, uc, hasher)
+}
+
+// validateUser checks the identity and password policy and returns the
+// trimmed username
+func validateUser(uc config.InitialUserConfig) (string, error) {
+	username := strings.TrimSpace(uc.Username)
+	if validation.ValidateUsername(username) != nil || validation.ValidateEmail(uc.Email) != nil {
+		return "", fmt.Errorf("%w (%q)", ErrUserIdentityRequired, username)
+	}
+	if uc.Password == "" {
+		return "", fmt.Errorf("%w (%q)", ErrUserPasswordRequired, username)
+	}
+	if err := validation.ValidatePassword(uc.Password, username); err != nil {
+		return "", fmt.Errorf("senha do usuário inicial %q fora da política de senhas: %w", username, err)
+	}
+	return username, nil
+}
+
+func createUser(tx *gorm.DB, username string, uc config.InitialUserConfig, hasher auth.PasswordHasher) error {
+	hash, err := hasher.Hash(uc.Password)
+	if err != nil {
+		return fmt.Errorf("falha ao gerar hash da senha do usuário inicial %q: %w", username, err)
+	}
+	user := models.User{
+		Username:      username,
+		Email:         uc.Email,
+		DisplayName:   uc.DisplayName,
+		PasswordHash:  hash,
+		Role:          uc.Role,
+		Active:        true,
+		EmailVerified: true,
+	}
+	if user.DisplayName == "" {
+		user.DisplayName = username
+	}
+	if user.Role == "" {
+		user.Role = "user"
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return fmt.Errorf("falha ao criar usuário inicial %q: %w", username, err)
+	}
+
+	logger.Info("Usuário inicial criado", "username", username, "role", user.Role)
+	if uc.Password == DefaultPassword {
+		logger.Warn("Usuário inicial criado com a senha padrão, troque-a", "username", username)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package seed
+
+import (
+	"testing"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupUsersDB(t *testing.T) *gorm.DB {
+	db := setupTestDB(t)
+	require.NoError(t, db.AutoMigrate(&models.User{}))
+	return db
+}
+
+func TestEnsureUsers(t *testing.T) {
+	db := setupUsersDB(t)
+	hasher := auth.NewBcryptHasher(4)
+	users := []config.InitialUserConfig{
+		{Username: "root", Email: "root@example.com", Role: "admin", Password: "R00t-Passw0rd!"},
+		{Username: "ops", Email: "ops@example.com", DisplayName: "Ops Team", Role: "admin", Password: DefaultPassword},
+	}
+
+	require.NoError(t, EnsureUsers(db, users, hasher))
+
+	var stored []models.User
+	require.NoError(t, db.Order("id").Find(&stored).Error)
+	require.Len(t, stored, 2)
+	assert.Equal(t, "root", stored[0].Username)
+	assert.Equal(t, "root", stored[0].DisplayName)
+	assert.Equal(t, "admin", stored[0].Role)
+	assert.True(t, stored[0].EmailVerified)
+	assert.True(t, hasher.Verify(stored[0].PasswordHash, "R00t-Passw0rd!"))
+	assert.Equal(t, "Ops Team", stored[1].DisplayName)
+
+	// Running it again, even with another password, changes nothing
+	users[0].Password = "An0ther-Passw0rd!"
+	require.NoError(t, EnsureUsers(db, users, hasher))
+	var count int64
+	require.NoError(t, db.Model(&models.User{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+	var root models.User
+	require.NoError(t, db.Where("username = ?", "root").First(&root).Error)
+	assert.Equal(t, stored[0].PasswordHash, root.PasswordHash)
+}
+
+func TestEnsureUsers_Invalid(t *testing.T) {
+	hasher := auth.NewBcryptHasher(4)
+	valid := config.InitialUserConfig{Username: "root", Email: "root@example.com", Password: "R00t-Passw0rd!"}
+
+	tests := []struct {
+		name string
+		user config.InitialUserConfig
+		err  error
+	}{
+		{name: "no password", user: config.InitialUserConfig{Username: "ops", Email: "ops@example.com"}, err: ErrUserPasswordRequired},
+		{name: "no email", user: config.InitialUserConfig{Username: "ops", Password: "0ps-Passw0rd!"}, err: ErrUserIdentityRequired},
+		{name: "weak password", user: config.InitialUserConfig{Username: "ops", Email: "ops@example.com", Password: "admin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupUsersDB(t)
+			err := EnsureUsers(db, []config.InitialUserConfig{valid, tt.user}, hasher)
+			require.Error(t, err)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+
+			// The whole seed is rolled back
+			var count int64
+			require.NoError(t, db.Model(&models.User{}).Count(&count).Error)
+			assert.Zero(t, count)
+		})
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	db := setupUsersDB(t)
+	hasher := auth.NewBcryptHasher(4)
+	admin := config.InitialUserConfig{Username: " root ", Email: "root@example.com", Role: "admin", Password: "R00t-Passw0rd!"}
+
+	require.NoError(t, CreateUser(db, admin, hasher))
+	var stored models.User
+	require.NoError(t, db.Where("username = ?", "root").First(&stored).Error)
+	assert.Equal(t, "admin", stored.Role)
+	assert.True(t, stored.Active)
+	assert.True(t, hasher.Verify(stored.PasswordHash, "R00t-Passw0rd!"))
+
+	// Unlike EnsureUsers, a taken username is an error, even if soft-deleted
+	require.NoError(t, db.Delete(&stored).Error)
+	admin.Email = "other@example.com"
+	assert.ErrorIs(t, CreateUser(db, admin, hasher), ErrUserExists)
+
+	admin = config.InitialUserConfig{Username: "ops", Email: "ops@example.com", Password: "admin"}
+	assert.Error(t, CreateUser(db, admin, hasher), "the password policy applies")
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gosveltekit/internal/auth"
+	gormadapter "gosveltekit/internal/auth/adapter/gorm"
+	"gosveltekit/internal/email"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/outbox"
+)
+
+// DefaultAccountDeletionGracePeriod is how long a deleted account can be
+// restored without WithAccountDeletionGracePeriod
+const DefaultAccountDeletionGracePeriod = 30 * 24 * time.Hour
+
+// ErrAccountPendingDeletion is returned by Login for accounts scheduled for
+// deletion
+var ErrAccountPendingDeletion = errors.New("conta agendada para exclusão, use o link enviado por email para restaurá-la")
+
+// WithAccountDeletionGracePeriod sets how long an account stays restorable
+// after DeleteAccount before it is purged
+func WithAccountDeletionGracePeriod(d time.Duration) Option {
+	return func(s *AuthService) {
+		if d > 0 {
+			s.deletionGracePeriod = d
+		}
+	}
+}
+
+// DeleteAccount schedules the deletion of the user's account, who must
+// confirm their password (ErrWrongPassword otherwise). Until the grace period
+// ends the account can't log in, and can be restored with the link emailed to
+// the user or by an admin; cleanup.AccountPurger deletes it afterwards.
+//
+// All sessions are revoked. Returns when the account will be purged; calling
+// it again keeps the original date.
+func (s *AuthService) DeleteAccount(ctx context.Context, userID, password string) (time.Time, error) {
+	ok, err := s.userAdapter.VerifyPassword(ctx, userID, password)
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao verificar senha para exclusão de conta", "error", err, "user_id", userID)
+		return time.Time{}, err
+	}
+	if !ok {
+		logger.FromContext(ctx).Warn("Tentativa de exclusão de conta com senha incorreta", "user_id", userID)
+		return time.Time{}, ErrWrongPassword
+	}
+
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if user.ScheduledDeletionAt != nil {
+		return *user.ScheduledDeletionAt, nil
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := s.generateSecureToken(tokenBytes); err != nil {
+		return time.Time{}, err
+	}
+	plaintextToken := hex.EncodeToString(tokenBytes)
+	hashedToken := s.hashToken(plaintextToken)
+	deleteAt := s.clock.Now().Add(s.deletionGracePeriod)
+	displayName := welcomeDisplayName(user.DisplayName, user.Username)
+	ctx = email.WithLocale(ctx, user.Locale)
+
+	if s.jobs != nil {
+		if err := s.userAdapter.Transaction(ctx, func(tx *gormadapter.UserAdapter) error {
+			if err := tx.ScheduleDeletion(ctx, userID, hashedToken, deleteAt); err != nil {
+				return err
+			}
+			return outbox.EnqueueAccountDeletion(ctx, s.jobs.InTx(tx.DB()), user.Email, plaintextToken, user.Username, displayName, deleteAt)
+		}); err != nil {
+			logger.FromContext(ctx).Error("Erro ao agendar exclusão de conta", "error", err, "user_id", userID)
+			return time.Time{}, err
+		}
+	} else {
+		if err := s.userAdapter.ScheduleDeletion(ctx, userID, hashedToken, deleteAt); err != nil {
+			logger.FromContext(ctx).Error("Erro ao agendar exclusão de conta", "error", err, "user_id", userID)
+			return time.Time{}, err
+		}
+		if err := s.emailService.SendAccountDeletionEmail(ctx, user.Email, plaintextToken, user.Username, displayName, deleteAt); err != nil {
+			logger.FromContext(ctx).Error("Erro ao enviar email de exclusão de conta", "error", err, "email", user.Email)
+		}
+	}
+
+	if err := s.authManager.LogoutAll(ctx, userID); err != nil {
+		logger.FromContext(ctx).Error("Erro ao revogar sessões após agendar exclusão de conta", "error", err, "user_id", userID)
+	}
+
+	logger.FromContext(ctx).Warn("Exclusão de conta agendada", "user_id", userID, "delete_at", deleteAt)
+	return deleteAt, nil
+}
+
+// RestoreAccount cancels the scheduled deletion of the account holding the
+// restore token, so it can log in again. Returns ErrInvalidToken for unknown
+// tokens (including those of purged accounts) and ErrExpiredToken once the
+// grace period is over.
+func (s *AuthService) RestoreAccount(ctx context.Context, tokenFromUser string) error {
+	user, err := s.userAdapter.FindByRestoreToken(ctx, s.hashToken(tokenFromUser))
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if errors.Is(err, auth.ErrUserNotFound) {
+			logger.FromContext(ctx).Warn("Token de restauração de conta inválido")
+			return ErrInvalidToken
+		}
+		return err
+	}
+	if !s.clock.Now().Before(*user.ScheduledDeletionAt) {
+		// Past the window: the purger will delete it on its next run
+		logger.FromContext(ctx).Warn("Token de restauração de conta expirado", "user_id", user.ID)
+		return ErrExpiredToken
+	}
+
+	if err := s.userAdapter.CancelDeletion(ctx, user.PublicID()); err != nil {
+		logger.FromContext(ctx).Error("Erro ao cancelar exclusão de conta", "error", err, "user_id", user.ID)
+		return err
+	}
+
+	logger.FromContext(ctx).Info("Exclusão de conta cancelada pelo usuário", "user_id", user.ID)
+	return nil
+}
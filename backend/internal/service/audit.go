@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+
+	"gosveltekit/internal/audit"
+	"gosveltekit/internal/metrics"
+	"gosveltekit/internal/realtime"
+	"gosveltekit/internal/webhooks"
+)
+
+// WithAudit makes the service record logins, password changes and resets,
+// revoked sessions and the admin actions it performs (password reset
+// requests, impersonation) in recorder
+func WithAudit(recorder audit.Recorder) Option {
+	return func(s *AuthService) {
+		s.audit = recorder
+	}
+}
+
+func (s *AuthService) record(ctx context.Context, event audit.Event) {
+	if s.audit != nil {
+		s.audit.Record(ctx, event)
+	}
+}
+
+// loginSucceeded counts a login of userID with method (one of the
+// metrics.LoginMethod constants) and records it
+func (s *AuthService) loginSucceeded(ctx context.Context, userID, ip, method string) {
+	metrics.LoginSuccesses.WithLabelValues(method).Inc()
+	s.record(ctx, audit.Event{
+		Type:     audit.EventLoginSucceeded,
+		ActorID:  userID,
+		TargetID: userID,
+		IP:       ip,
+		Data:     map[string]any{"method": method},
+	})
+}
+
+// sessionRevoked publishes and records the end of sessions of userID for
+// reason (one of the Revoked constants)
+func (s *AuthService) sessionRevoked(ctx context.Context, userID, reason string) {
+	s.publish(ctx, webhooks.EventSessionRevoked, map[string]any{"user_id": userID, "reason": reason})
+	s.record(ctx, audit.Event{
+		Type:     audit.EventSessionRevoked,
+		ActorID:  userID,
+		TargetID: userID,
+		Data:     map[string]any{"reason": reason},
+	})
+	s.notify(ctx, userID, realtime.Event{Type: realtime.EventSessionRevoked, Data: map[string]any{"reason": reason}}, true)
+}
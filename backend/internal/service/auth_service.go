@@ -2,6 +2,7 @@
 package service
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -9,13 +10,23 @@ import (
 	"strings"
 	"time"
 
+	"gosveltekit/internal/audit"
 	"gosveltekit/internal/auth"
 	gormadapter "gosveltekit/internal/auth/adapter/gorm"
+	"gosveltekit/internal/captcha"
+	"gosveltekit/internal/database"
 	"gosveltekit/internal/email"
+	"gosveltekit/internal/jobs"
 	"gosveltekit/internal/logger"
+	"gosveltekit/internal/metrics"
 	"gosveltekit/internal/models"
-
-	"golang.org/x/crypto/bcrypt"
+	"gosveltekit/internal/outbox"
+	"gosveltekit/internal/realtime"
+	"gosveltekit/internal/settings"
+	"gosveltekit/internal/sms"
+	"gosveltekit/internal/tokens"
+	"gosveltekit/internal/validation"
+	"gosveltekit/internal/webhooks"
 )
 
 var (
@@ -23,17 +34,78 @@ var (
 	ErrUserNotActive      = errors.New("usuário inativo")
 	ErrInvalidToken       = errors.New("token inválido")
 	ErrExpiredToken       = errors.New("token expirado")
+	ErrPasswordReused     = errors.New("a nova senha não pode ser igual a uma das senhas recentes")
+	ErrPasswordTooLong    = auth.ErrPasswordTooLong
+	ErrInvalidUsername    = auth.ErrInvalidUsername
+	ErrWrongPassword      = errors.New("senha atual incorreta")
+	ErrForbidden          = errors.New("acesso negado")
+	ErrUserNotFound       = errors.New("usuário não encontrado")
+	ErrCaptchaFailed      = errors.New("verificação de captcha falhou")
+	ErrNotImpersonating   = errors.New("sessão não é de impersonação")
+	ErrRegistrationClosed = errors.New("cadastro de novos usuários desativado")
+	ErrLoginThrottled     = errors.New("muitas tentativas de login a partir deste endereço, tente novamente mais tarde")
+	ErrTooManySessions    = errors.New("limite de sessões simultâneas atingido: saia de outro dispositivo ou redefina a senha para encerrar todas as sessões")
+	ErrRegistrationEmail  = errors.New("não foi possível enviar o email de cadastro, tente novamente mais tarde")
+	ErrSessionNotFound    = errors.New("sessão não encontrada")
+
+	ErrInvalidPhoneNumber     = errors.New("número de telefone inválido, use o formato internacional (+5511999999999)")
+	ErrPhoneNotVerified       = errors.New("telefone não verificado")
+	ErrPhoneLockedBy2FA       = errors.New("desative o 2FA por SMS antes de trocar o telefone")
+	ErrInvalidTwoFactorMethod = errors.New("método de 2FA inválido")
+	ErrTwoFactorSMSNotEnabled = errors.New("2FA por SMS não está ativado")
+	ErrInvalidSMSCode         = errors.New("código inválido ou expirado")
+	ErrSMSRateLimited         = errors.New("muitos códigos solicitados, tente novamente mais tarde")
+	ErrSMSUnavailable         = errors.New("envio de SMS não configurado")
+
+	ErrInvalidTwoFactorCode      = errors.New("código de verificação inválido")
+	ErrInvalidTwoFactorChallenge = errors.New("login expirado, entre com a senha novamente")
+	ErrTwoFactorLocked           = errors.New("muitos códigos inválidos, tente novamente mais tarde")
+	ErrTOTPAlreadyEnabled        = errors.New("o aplicativo autenticador já está ativado")
+	ErrTOTPNotEnabled            = errors.New("o aplicativo autenticador não está configurado")
+	ErrTOTPEnabled               = errors.New("desative o aplicativo autenticador antes de trocar o método de 2FA")
+
+	ErrInvalidRefreshToken = errors.New("refresh token inválido ou expirado, entre novamente")
 )
 
 // AuthServiceInterface defines the methods that an auth service must implement
 type AuthServiceInterface interface {
-	Login(username, password, ip, userAgent string) (*LoginResponse, error)
-	ValidateSession(sessionID string) (*auth.Session, *auth.UserData, error)
-	Logout(sessionID string) error
-	LogoutAll(userID string) error
-	Register(username, email, password, displayName string) (*models.User, error)
-	RequestPasswordReset(email string) error
-	ResetPassword(token, newPassword string) error
+	Login(ctx context.Context, username, password, ip, userAgent string) (*LoginResponse, error)
+	ValidateSession(ctx context.Context, sessionID string) (*auth.Session, *auth.UserData, error)
+	Logout(ctx context.Context, sessionID string) error
+	LogoutAll(ctx context.Context, userID string) error
+	RevokeToken(ctx context.Context, userID, token string) error
+	Register(ctx context.Context, username, email, password, displayName, captchaToken, ip string) (*models.User, bool, error)
+	EmailAvailable(ctx context.Context, email string) (bool, error)
+	RequestPasswordReset(ctx context.Context, email string) error
+	AdminRequestPasswordReset(ctx context.Context, actorID, userID string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerificationEmail(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	ValidateResetToken(ctx context.Context, token string) error
+	ChangePassword(ctx context.Context, userID, sessionID, currentPassword, newPassword string) error
+	SetLocale(ctx context.Context, userID, locale string) error
+	SetAvatar(ctx context.Context, userID, key string) (string, error)
+	ExportAccount(ctx context.Context, userID string) (*AccountExport, error)
+	Impersonate(ctx context.Context, adminSessionID, targetUserID, ip, userAgent string) (*LoginResponse, error)
+	EndImpersonation(ctx context.Context, sessionID string) (*LoginResponse, error)
+	PasswordPolicy() validation.PasswordPolicy
+	CompleteTwoFactorLogin(ctx context.Context, challengeToken, code, ip string) (*LoginResponse, error)
+	Refresh(ctx context.Context, refreshToken, ip, userAgent string) (*LoginResponse, error)
+	ListSessions(ctx context.Context, userID string) ([]*auth.Session, error)
+	RevokeSession(ctx context.Context, userID, handle string) error
+	RevokeOtherSessions(ctx context.Context, userID, currentSessionID string) ([]auth.SessionRevocation, error)
+	EnrollTOTP(ctx context.Context, userID string) (*auth.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID, password string) error
+	SetPhoneNumber(ctx context.Context, userID, phoneNumber string) error
+	VerifyPhoneNumber(ctx context.Context, userID, code string) error
+	SetTwoFactorMethod(ctx context.Context, userID, method string) error
+	DisableSMSTwoFactor(ctx context.Context, userID, password string) error
+	ResendTwoFactorCode(ctx context.Context, challengeToken string) error
+	DeleteAccount(ctx context.Context, userID, password string) (time.Time, error)
+	RestoreAccount(ctx context.Context, token string) error
+	RequestMagicLink(ctx context.Context, email, ip string) error
+	LoginWithMagicLink(ctx context.Context, token, ip, userAgent string) (*LoginResponse, error)
 }
 
 // AuthService handles authentication business logic
@@ -41,6 +113,129 @@ type AuthService struct {
 	authManager  *auth.AuthManager
 	userAdapter  *gormadapter.UserAdapter
 	emailService email.EmailServiceInterface
+
+	// jobs queues emails as background jobs (delivered by the handlers of
+	// outbox.Register) instead of sending them synchronously; nil sends them
+	// directly
+	jobs *jobs.Queue
+
+	// welcomeTrigger is when the welcome email is sent (WelcomeOnRegister or
+	// WelcomeOnVerification); empty disables it
+	welcomeTrigger string
+
+	// strictRegistrationEmail fails the registration when its welcome email
+	// can't be sent
+	strictRegistrationEmail bool
+
+	// notifyNewDevice emails users who log in from an unknown device
+	notifyNewDevice bool
+
+	// notifyOnLockout emails users whose account gets locked by failed logins
+	notifyOnLockout bool
+
+	// settings holds the runtime overrides of registration and new-device
+	// notifications; nil keeps the values set by options
+	settings *settings.Store
+
+	// captcha verifies the registration CAPTCHA; nil skips the check
+	captcha captcha.Verifier
+
+	// resetTokenSecret signs stateless reset links; nil stores reset tokens
+	// on the user instead
+	resetTokenSecret []byte
+
+	// verificationSecret signs email verification links, valid for
+	// verificationTTL; nil sends none
+	verificationSecret []byte
+	verificationTTL    time.Duration
+
+	// magicLinks enables passwordless login by emailed links
+	magicLinks bool
+
+	// deletionGracePeriod is how long DeleteAccount keeps the account restorable
+	deletionGracePeriod time.Duration
+
+	// revokeSessionsOnPasswordChange ends the user's sessions after a password
+	// reset or change; keepCurrentSession spares the one changing it
+	revokeSessionsOnPasswordChange bool
+	keepCurrentSession             bool
+
+	// webhooks is notified of registrations, failed logins and revoked
+	// sessions; nil disables them
+	webhooks webhooks.Publisher
+
+	// audit records security-relevant events; nil disables it
+	audit audit.Recorder
+
+	// realtime pushes revoked sessions and password changes to the user's
+	// connected clients; nil disables it
+	realtime realtime.Publisher
+
+	// smsSender delivers phone verification and 2FA codes; nil disables them
+	smsSender sms.Sender
+
+	// clock is the time source for reset token expiry, defaults to the auth
+	// manager's
+	clock auth.Clock
+
+	// tokens generates reset and deletion tokens, defaults to the auth
+	// manager's
+	tokens tokens.Generator
+}
+
+// Option configures optional behavior of AuthService
+type Option func(*AuthService)
+
+// WithJobs makes the service queue outgoing emails in q, within the
+// transaction of the operation that triggers them when there is one, instead
+// of sending them directly. q must have the outbox handlers registered
+// (outbox.Register) and be running to deliver them.
+func WithJobs(q *jobs.Queue) Option {
+	return func(s *AuthService) {
+		s.jobs = q
+	}
+}
+
+// WithCaptcha makes Register verify the client's CAPTCHA token before
+// creating the user
+func WithCaptcha(verifier captcha.Verifier) Option {
+	return func(s *AuthService) {
+		s.captcha = verifier
+	}
+}
+
+// WithSettings makes the service read registration_enabled and
+// notify_new_device from store on every use, so admins can toggle them at
+// runtime. The store's values take precedence over WithNewDeviceNotification.
+func WithSettings(store *settings.Store) Option {
+	return func(s *AuthService) {
+		s.settings = store
+	}
+}
+
+// WithPasswordChangeSessionRevocation sets whether ResetPassword and
+// ChangePassword revoke the user's existing sessions (the default). With
+// keepCurrent, ChangePassword spares the session making the change; a reset
+// has no current session and revokes them all.
+func WithPasswordChangeSessionRevocation(revoke, keepCurrent bool) Option {
+	return func(s *AuthService) {
+		s.revokeSessionsOnPasswordChange = revoke
+		s.keepCurrentSession = keepCurrent
+	}
+}
+
+// WithClock sets the time source for token expiry
+func WithClock(clock auth.Clock) Option {
+	return func(s *AuthService) {
+		s.clock = clock
+	}
+}
+
+// WithTokenGenerator sets the random source of reset and deletion tokens
+func WithTokenGenerator(g tokens.Generator) Option {
+	return func(s *AuthService) {
+		s.tokens = g
+	}
 }
 
 // NewAuthService creates a new AuthService instance
@@ -48,12 +243,24 @@ func NewAuthService(
 	authManager *auth.AuthManager,
 	userAdapter *gormadapter.UserAdapter,
 	emailService email.EmailServiceInterface,
+	opts ...Option,
 ) *AuthService {
-	return &AuthService{
+	s := &AuthService{
 		authManager:  authManager,
 		userAdapter:  userAdapter,
 		emailService: emailService,
+		clock:        authManager.Clock(),
+		tokens:       authManager.Tokens(),
+
+		deletionGracePeriod: DefaultAccountDeletionGracePeriod,
+
+		revokeSessionsOnPasswordChange: true,
+		keepCurrentSession:             true,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // LoginResponse represents the response from a successful login
@@ -61,57 +268,116 @@ type LoginResponse struct {
 	SessionID string        `json:"session_id"`
 	ExpiresAt time.Time     `json:"expires_at"`
 	User      auth.UserData `json:"user"`
+
+	// ImpersonatorID is the admin acting as User, for impersonation sessions
+	ImpersonatorID string `json:"impersonator_id,omitempty"`
+
+	// TwoFactor is set instead of the session (and user) for users with TOTP
+	// enabled
+	TwoFactor *TwoFactorChallenge `json:"two_factor,omitempty"`
+
+	// RefreshToken renews the session through Refresh, when refresh tokens
+	// are enabled
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// AccountExport holds everything stored about a user, for data-subject requests
+type AccountExport struct {
+	User           *models.User
+	Sessions       []*auth.Session
+	LinkedAccounts []models.ExternalIdentity
+	APIKeys        []*auth.APIKey
 }
 
-// Login authenticates a user and creates a session
-func (s *AuthService) Login(username, password, ip, userAgent string) (*LoginResponse, error) {
+// Login authenticates a user and creates a session. Users with a second factor
+// get a TwoFactor challenge instead, completed by CompleteTwoFactorLogin.
+func (s *AuthService) Login(ctx context.Context, username, password, ip, userAgent string) (*LoginResponse, error) {
 	metadata := auth.SessionMetadata{
 		UserAgent: userAgent,
 		IP:        ip,
 	}
 
-	session, user, err := s.authManager.Login(username, password, metadata)
+	session, user, err := s.authManager.Login(ctx, username, password, metadata)
+	if challenge, ok := s.twoFactorChallenge(ctx, err); ok {
+		logger.FromContext(ctx).Info("Login aguardando segundo fator", "username", username, "ip", ip)
+		return &LoginResponse{TwoFactor: challenge}, nil
+	}
 	if err != nil {
+		if errors.Is(err, auth.ErrLockoutStarted) {
+			logger.FromContext(ctx).Warn("Conta bloqueada por excesso de falhas de login", "username", username, "ip", ip)
+			s.notifyLockout(ctx, username)
+		}
 		switch {
 		case errors.Is(err, auth.ErrInvalidCredentials):
-			logger.Warn("Tentativa de login com credenciais inválidas", "username", username, "ip", ip)
+			logger.FromContext(ctx).Warn("Tentativa de login com credenciais inválidas", "username", username, "ip", ip)
+			s.loginFailed(ctx, username, ip, metrics.ReasonInvalidCredentials)
 			return nil, ErrInvalidCredentials
 		case errors.Is(err, auth.ErrUserNotActive):
-			logger.Warn("Tentativa de login com usuário inativo", "username", username, "ip", ip)
+			logger.FromContext(ctx).Warn("Tentativa de login com usuário inativo", "username", username, "ip", ip)
+			s.loginFailed(ctx, username, ip, metrics.ReasonUserInactive)
 			return nil, ErrUserNotActive
 		case errors.Is(err, auth.ErrAccountLocked):
-			logger.Warn("Tentativa de login com conta bloqueada", "username", username, "ip", ip)
+			logger.FromContext(ctx).Warn("Tentativa de login com conta bloqueada", "username", username, "ip", ip)
+			s.loginFailed(ctx, username, ip, metrics.ReasonAccountLocked)
 			return nil, errors.New("conta temporariamente bloqueada, tente novamente mais tarde")
+		case errors.Is(err, auth.ErrTooManyAttempts):
+			logger.FromContext(ctx).Warn("Tentativa de login de IP bloqueado por excesso de falhas", "username", username, "ip", ip)
+			s.loginFailed(ctx, username, ip, metrics.ReasonIPThrottled)
+			return nil, ErrLoginThrottled
+		case errors.Is(err, auth.ErrAccountPendingDeletion):
+			logger.FromContext(ctx).Warn("Tentativa de login com conta agendada para exclusão", "username", username, "ip", ip)
+			s.loginFailed(ctx, username, ip, metrics.ReasonPendingDeletion)
+			return nil, ErrAccountPendingDeletion
+		case errors.Is(err, auth.ErrEmailNotVerified):
+			logger.FromContext(ctx).Warn("Tentativa de login com email não verificado", "username", username, "ip", ip)
+			s.loginFailed(ctx, username, ip, metrics.ReasonEmailNotVerified)
+			return nil, ErrEmailNotVerified
+		case errors.Is(err, auth.ErrTooManySessions):
+			logger.FromContext(ctx).Warn("Login recusado por limite de sessões simultâneas", "username", username, "ip", ip)
+			s.loginFailed(ctx, username, ip, metrics.ReasonSessionLimit)
+			return nil, ErrTooManySessions
+		case errors.Is(err, context.Canceled):
+			logger.FromContext(ctx).Debug("Login cancelado pelo cliente", "username", username, "ip", ip)
+			return nil, err
 		default:
-			logger.Error("Erro ao fazer login", "error", err, "username", username, "ip", ip)
+			logger.FromContext(ctx).Error("Erro ao fazer login", "error", err, "username", username, "ip", ip)
 			return nil, err
 		}
 	}
 
-	logger.Info("Login realizado com sucesso", "user_id", user.ID, "username", username, "ip", ip)
+	logger.FromContext(ctx).Info("Login realizado com sucesso", "user_id", user.ID, "username", username, "ip", ip)
+	s.loginSucceeded(ctx, user.ID, ip, metrics.LoginMethodPassword)
+	if user.MustChangePassword() {
+		logger.FromContext(ctx).Info("Login com troca de senha obrigatória", "user_id", user.ID, "ip", ip)
+	}
+	s.notifyIfNewDevice(ctx, session, user)
 	return &LoginResponse{
-		SessionID: session.ID,
-		ExpiresAt: session.ExpiresAt,
-		User:      *user,
+		SessionID:    session.ID,
+		ExpiresAt:    session.ExpiresAt,
+		User:         *user,
+		RefreshToken: session.RefreshToken,
 	}, nil
 }
 
 // ValidateSession validates a session and returns user data
-func (s *AuthService) ValidateSession(sessionID string) (*auth.Session, *auth.UserData, error) {
-	session, user, err := s.authManager.ValidateSession(sessionID)
+func (s *AuthService) ValidateSession(ctx context.Context, sessionID string) (*auth.Session, *auth.UserData, error) {
+	session, user, err := s.authManager.ValidateSession(ctx, sessionID)
 	if err != nil {
 		switch {
 		case errors.Is(err, auth.ErrSessionNotFound):
-			logger.Debug("Sessão não encontrada durante validação", "session_id", sessionID)
+			logger.FromContext(ctx).Debug("Sessão não encontrada durante validação", "session_id", sessionID)
 			return nil, nil, ErrInvalidToken
 		case errors.Is(err, auth.ErrSessionExpired):
-			logger.Debug("Sessão expirada durante validação", "session_id", sessionID)
+			logger.FromContext(ctx).Debug("Sessão expirada durante validação", "session_id", sessionID)
 			return nil, nil, ErrExpiredToken
 		case errors.Is(err, auth.ErrUserNotActive):
-			logger.Warn("Usuário inativo durante validação de sessão", "session_id", sessionID)
+			logger.FromContext(ctx).Warn("Usuário inativo durante validação de sessão", "session_id", sessionID)
 			return nil, nil, ErrUserNotActive
+		case errors.Is(err, context.Canceled):
+			logger.FromContext(ctx).Debug("Validação de sessão cancelada pelo cliente", "session_id", sessionID)
+			return nil, nil, err
 		default:
-			logger.Error("Erro ao validar sessão", "error", err, "session_id", sessionID)
+			logger.FromContext(ctx).Error("Erro ao validar sessão", "error", err, "session_id", sessionID)
 			return nil, nil, err
 		}
 	}
@@ -119,165 +385,639 @@ func (s *AuthService) ValidateSession(sessionID string) (*auth.Session, *auth.Us
 }
 
 // Logout invalidates a session
-func (s *AuthService) Logout(sessionID string) error {
-	if err := s.authManager.Logout(sessionID); err != nil {
-		logger.Error("Erro ao fazer logout no service", "error", err, "session_id", sessionID)
+func (s *AuthService) Logout(ctx context.Context, sessionID string) error {
+	// Looked up first so the session.revoked event can name its user
+	var userID string
+	if s.webhooks != nil || s.audit != nil || s.realtime != nil {
+		if session, err := s.authManager.GetSessionAdapter().GetSession(ctx, sessionID); err == nil {
+			userID = session.UserID
+		}
+	}
+
+	if err := s.authManager.Logout(ctx, sessionID); err != nil {
+		logger.FromContext(ctx).Error("Erro ao fazer logout no service", "error", err, "session_id", sessionID)
+		return err
+	}
+	if userID != "" {
+		s.sessionRevoked(ctx, userID, RevokedByLogout)
+	}
+	return nil
+}
+
+// RevokeToken invalidates a session or refresh token on behalf of userID,
+// without touching their other sessions. A refresh token takes its whole
+// family with it: the tokens rotated from it and their sessions. Unknown,
+// expired and already revoked tokens succeed, so clients can safely retry; a
+// token of another user returns ErrForbidden and is left alone.
+func (s *AuthService) RevokeToken(ctx context.Context, userID, token string) error {
+	session, err := s.authManager.GetSessionAdapter().GetSession(ctx, token)
+	if err != nil && !errors.Is(err, auth.ErrSessionNotFound) {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		logger.FromContext(ctx).Error("Erro ao buscar sessão para revogação", "error", err, "user_id", userID)
+		return err
+	}
+	if session == nil {
+		return s.revokeRefreshToken(ctx, userID, token)
+	}
+	if err := s.checkTokenOwner(ctx, userID, session.UserID); err != nil {
+		return err
+	}
+
+	if err := s.authManager.Logout(ctx, token); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info("Sessão revogada pelo cliente", "user_id", userID)
+	s.sessionRevoked(ctx, userID, RevokedByClient)
+	return nil
+}
+
+// revokeRefreshToken is RevokeToken for a token that isn't a session
+func (s *AuthService) revokeRefreshToken(ctx context.Context, userID, token string) error {
+	stored, err := s.authManager.FindRefreshToken(ctx, token)
+	if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokensUnsupported) {
+		return nil
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		logger.FromContext(ctx).Error("Erro ao buscar refresh token para revogação", "error", err, "user_id", userID)
+		return err
+	}
+	if err := s.checkTokenOwner(ctx, userID, stored.UserID); err != nil {
+		return err
+	}
+
+	if err := s.authManager.RevokeRefreshTokens(ctx, stored.FamilyID); err != nil {
+		return err
+	}
+	logger.FromContext(ctx).Info("Refresh token revogado pelo cliente", "user_id", userID)
+	s.sessionRevoked(ctx, userID, RevokedByClient)
+	return nil
+}
+
+// checkTokenOwner returns ErrForbidden unless ownerID, the primary key a
+// token is stored with, is userID's
+func (s *AuthService) checkTokenOwner(ctx context.Context, userID, ownerID string) error {
+	// userID may be a UUID while tokens hold the primary key
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
 		return err
 	}
+	if ownerID != strconv.FormatUint(uint64(user.ID), 10) {
+		logger.FromContext(ctx).Warn("Tentativa de revogar token de outro usuário", "user_id", userID, "owner_id", ownerID)
+		return ErrForbidden
+	}
 	return nil
 }
 
 // LogoutAll invalidates all sessions for a user
-func (s *AuthService) LogoutAll(userID string) error {
-	if err := s.authManager.LogoutAll(userID); err != nil {
-		logger.Error("Erro ao fazer logout de todas as sessões no service", "error", err, "user_id", userID)
+func (s *AuthService) LogoutAll(ctx context.Context, userID string) error {
+	if err := s.authManager.LogoutAll(ctx, userID); err != nil {
+		logger.FromContext(ctx).Error("Erro ao fazer logout de todas as sessões no service", "error", err, "user_id", userID)
 		return err
 	}
+	s.sessionRevoked(ctx, userID, RevokedByLogoutAll)
 	return nil
 }
 
-// Register creates a new user account
-func (s *AuthService) Register(username, email, password, displayName string) (*models.User, error) {
+// Register creates a new user account. The username must follow the auth
+// manager's UsernamePolicy, reserved names included (ErrInvalidUsername). With
+// WithCaptcha, captchaToken must be a CAPTCHA solved by the client at ip,
+// otherwise ErrCaptchaFailed is returned. With WithEmailVerification, the
+// bool reports whether the verification link was sent: when it wasn't, the
+// account is kept and the user can ask for another one.
+func (s *AuthService) Register(ctx context.Context, username, emailAddr, password, displayName, captchaToken, ip string) (*models.User, bool, error) {
+	if s.settings != nil && !s.settings.Bool(settings.KeyRegistrationEnabled) {
+		logger.FromContext(ctx).Info("Registro rejeitado: cadastro desativado", "username", username, "ip", ip)
+		return nil, false, ErrRegistrationClosed
+	}
+
+	// Self-registration never gets a reserved name
+	if err := s.authManager.UsernamePolicy().Validate(username, false); err != nil {
+		logger.FromContext(ctx).Warn("Registro rejeitado pela política de nomes de usuário", "error", err, "username", username, "ip", ip)
+		return nil, false, err
+	}
+
+	if s.captcha != nil {
+		if err := s.captcha.Verify(ctx, captchaToken, ip); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, false, ctxErr
+			}
+			if errors.Is(err, captcha.ErrVerificationFailed) || errors.Is(err, captcha.ErrMissingToken) {
+				logger.FromContext(ctx).Warn("Registro rejeitado pelo captcha", "error", err, "username", username, "ip", ip)
+			} else {
+				// Fail closed: an unreachable provider must not let bots through
+				logger.FromContext(ctx).Error("Erro ao verificar captcha", "error", err, "username", username, "ip", ip)
+			}
+			return nil, false, ErrCaptchaFailed
+		}
+	}
+
 	// Check if username already exists
-	if _, err := s.userAdapter.FindUserByIdentifier(username); err == nil {
-		logger.Warn("Tentativa de registro com username já existente", "username", username)
-		return nil, errors.New("username already exists")
+	if _, err := s.userAdapter.FindUserByIdentifier(ctx, username); err == nil {
+		logger.FromContext(ctx).Warn("Tentativa de registro com username já existente", "username", username)
+		metrics.RegistrationConflicts.WithLabelValues(metrics.FieldUsername).Inc()
+		return nil, false, errors.New("username already exists")
 	}
 
 	// Check if email already exists
-	if _, err := s.userAdapter.FindByEmail(email); err == nil {
-		logger.Warn("Tentativa de registro com email já existente", "email", email)
-		return nil, errors.New("email already exists")
+	if _, err := s.userAdapter.FindByEmail(ctx, emailAddr); err == nil {
+		logger.FromContext(ctx).Warn("Tentativa de registro com email já existente", "email", emailAddr)
+		metrics.RegistrationConflicts.WithLabelValues(metrics.FieldEmail).Inc()
+		return nil, false, errors.New("email already exists")
 	}
 
 	// Create user via adapter
-	userData, err := s.userAdapter.CreateUser(auth.CreateUserInput{
+	input := auth.CreateUserInput{
 		Identifier:  username,
-		Email:       email,
+		Email:       emailAddr,
 		Password:    password,
 		DisplayName: displayName,
-	})
+		Locale:      email.LocaleFromContext(ctx),
+	}
+	var userData *auth.UserData
+	var err error
+	welcome := s.welcomeTrigger == WelcomeOnRegister
+	verification := len(s.verificationSecret) > 0
+	verificationSent := false
+	switch {
+	case s.strictRegistrationEmail && (welcome || verification):
+		userData, err = s.createUserWithEmails(ctx, input, welcome, verification)
+		verificationSent = verification
+		welcome, verification = false, false
+	case s.jobs != nil && (welcome || verification):
+		userData, err = s.createUserWithQueuedEmails(ctx, input, welcome, verification)
+		verificationSent = verification
+		welcome, verification = false, false
+	default:
+		userData, err = s.userAdapter.CreateUser(ctx, input)
+	}
 	if err != nil {
-		logger.Error("Erro ao criar usuário", "error", err, "username", username, "email", email)
-		return nil, err
+		if errors.Is(err, ErrRegistrationEmail) {
+			return nil, false, err
+		}
+		logger.FromContext(ctx).Error("Erro ao criar usuário", "error", err, "username", username, "email", emailAddr)
+		return nil, false, err
 	}
 
 	// Get the actual User model for response
-	user, err := s.userAdapter.GetUserModel(userData.ID)
+	user, err := s.userAdapter.GetUserModel(ctx, userData.ID)
 	if err != nil {
-		logger.Error("Erro ao buscar usuário criado", "error", err, "user_id", userData.ID)
-		return nil, err
+		logger.FromContext(ctx).Error("Erro ao buscar usuário criado", "error", err, "user_id", userData.ID)
+		return nil, false, err
 	}
 
-	logger.Info("Usuário registrado com sucesso", "user_id", user.ID, "username", username, "email", email)
-	return user, nil
+	logger.FromContext(ctx).Info("Usuário registrado com sucesso", "user_id", user.ID, "username", username, "email", emailAddr)
+	if welcome {
+		s.sendWelcomeEmail(ctx, user)
+	}
+	if verification {
+		verificationSent = s.sendVerificationEmail(ctx, user) == nil
+	}
+	s.publish(ctx, webhooks.EventUserRegistered, map[string]any{
+		"user_id":  user.PublicID(),
+		"username": user.Username,
+		"email":    user.Email,
+	})
+	return user, verificationSent, nil
+}
+
+// EmailAvailable reports whether email is still free to register in the
+// tenant of ctx. Callers facing anonymous users must not return the answer
+// as is, since it reveals which emails have an account.
+func (s *AuthService) EmailAvailable(ctx context.Context, email string) (bool, error) {
+	if _, err := s.userAdapter.FindByEmail(ctx, email); err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+	return false, nil
 }
 
 // RequestPasswordReset initiates a password reset flow
-func (s *AuthService) RequestPasswordReset(emailAddr string) error {
-	user, err := s.userAdapter.FindByEmail(emailAddr)
+func (s *AuthService) RequestPasswordReset(ctx context.Context, emailAddr string) error {
+	user, err := s.userAdapter.FindByEmail(ctx, emailAddr)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		// Don't reveal if email exists
-		logger.Debug("Solicitação de reset de senha para email não encontrado", "email", emailAddr)
+		logger.FromContext(ctx).Debug("Solicitação de reset de senha para email não encontrado", "email", emailAddr)
 		return nil
 	}
+	return s.startPasswordReset(ctx, user)
+}
+
+// AdminRequestPasswordReset emails a password reset link to the user userID
+// on behalf of the admin actorID, exactly as if the user had asked for it.
+// Admins are trusted, so unknown users are reported with ErrUserNotFound.
+func (s *AuthService) AdminRequestPasswordReset(ctx context.Context, actorID, userID string) error {
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		logger.FromContext(ctx).Error("Erro ao buscar usuário para reset de senha", "error", err, "actor_id", actorID, "user_id", userID)
+		return err
+	}
+
+	if err := s.startPasswordReset(ctx, user); err != nil {
+		logger.FromContext(ctx).Error("Erro ao iniciar reset de senha a pedido de administrador", "error", err, "actor_id", actorID, "user_id", userID)
+		return err
+	}
+	logger.FromContext(ctx).Warn("Reset de senha enviado a pedido de administrador", "actor_id", actorID, "user_id", userID)
+	s.record(ctx, audit.Event{Type: audit.EventPasswordResetRequested, ActorID: actorID, TargetID: strconv.FormatUint(uint64(user.ID), 10)})
+	return nil
+}
+
+// startPasswordReset issues a reset token for user and emails it
+func (s *AuthService) startPasswordReset(ctx context.Context, user *models.User) error {
+	ctx = email.WithLocale(ctx, user.Locale)
+	expiresAt := s.clock.Now().Add(1 * time.Hour)
+	displayName := user.DisplayName
+	if displayName == "" {
+		displayName = user.Username
+	}
+
+	if len(s.resetTokenSecret) > 0 {
+		// Signed links are stateless: only the email is persisted, if anything
+		return s.sendPasswordReset(ctx, user.ID, user.Email, s.signResetToken(user, expiresAt), user.Username, displayName)
+	}
 
 	// Generate reset token
 	tokenBytes := make([]byte, 32)
-	_, err = s.generateSecureToken(tokenBytes)
-	if err != nil {
+	if _, err := s.generateSecureToken(tokenBytes); err != nil {
 		return err
 	}
 
 	plaintextToken := hex.EncodeToString(tokenBytes)
 	hashedToken := s.hashToken(plaintextToken)
-	expiresAt := time.Now().Add(1 * time.Hour)
 
-	// Store hashed token
 	user.ResetToken = hashedToken
 	user.ResetTokenExpiry = expiresAt
-	if err := s.userAdapter.UpdateUser(user); err != nil {
+
+	if s.jobs != nil {
+		// Store hashed token and queue the email atomically
+		if err := s.userAdapter.Transaction(ctx, func(tx *gormadapter.UserAdapter) error {
+			if err := tx.UpdateUser(ctx, user); err != nil {
+				return err
+			}
+			return outbox.EnqueuePasswordReset(ctx, s.jobs.InTx(tx.DB()), user.Email, plaintextToken, user.Username, displayName)
+		}); err != nil {
+			logger.FromContext(ctx).Error("Erro ao enfileirar email de recuperação de senha", "error", err, "user_id", user.ID)
+			return err
+		}
+		logger.FromContext(ctx).Info("Email de recuperação de senha enfileirado", "email", user.Email, "user_id", user.ID)
+		return nil
+	}
+
+	// Store hashed token
+	if err := s.userAdapter.UpdateUser(ctx, user); err != nil {
 		return err
 	}
 
-	// Send email
-	displayName := user.DisplayName
-	if displayName == "" {
-		displayName = user.Username
+	return s.sendPasswordReset(ctx, user.ID, user.Email, plaintextToken, user.Username, displayName)
+}
+
+// sendPasswordReset delivers a reset link whose token is already persisted
+// (or needs no persistence), through the job queue when set
+func (s *AuthService) sendPasswordReset(ctx context.Context, userID uint, to, token, username, displayName string) error {
+	if s.jobs != nil {
+		if err := outbox.EnqueuePasswordReset(ctx, s.jobs, to, token, username, displayName); err != nil {
+			logger.FromContext(ctx).Error("Erro ao enfileirar email de recuperação de senha", "error", err, "user_id", userID)
+			return err
+		}
+		logger.FromContext(ctx).Info("Email de recuperação de senha enfileirado", "email", to, "user_id", userID)
+		return nil
 	}
 
-	if err := s.emailService.SendPasswordResetEmail(
-		user.Email,
-		plaintextToken,
-		user.Username,
-		displayName,
-	); err != nil {
-		logger.Error("Erro ao enviar email de recuperação de senha", "error", err, "email", user.Email)
+	if err := s.emailService.SendPasswordResetEmail(ctx, to, token, username, displayName); err != nil {
+		logger.FromContext(ctx).Error("Erro ao enviar email de recuperação de senha", "error", err, "email", to)
 	} else {
-		logger.Info("Email de recuperação de senha enviado", "email", user.Email, "user_id", user.ID)
+		logger.FromContext(ctx).Info("Email de recuperação de senha enviado", "email", to, "user_id", userID)
 	}
-
 	return nil
 }
 
 // ResetPassword resets a user's password using a reset token
-func (s *AuthService) ResetPassword(tokenFromUser, newPassword string) error {
-	// Hash the provided token and find matching user
-	hashedToken := s.hashToken(tokenFromUser)
-
-	// Find user with this reset token
-	// This is a simplified implementation - in production you might want
-	// to search by the hashed token directly
-	users, err := s.findUsersWithResetTokens()
+func (s *AuthService) ResetPassword(ctx context.Context, tokenFromUser, newPassword string) error {
+	user, err := s.findResetTokenUser(ctx, tokenFromUser)
 	if err != nil {
 		return err
 	}
+	return s.resetPassword(ctx, user, tokenFromUser, newPassword)
+}
 
-	var matchedUser *models.User
-	for _, user := range users {
-		if time.Now().After(user.ResetTokenExpiry) {
-			continue
+// resetPassword sets the password of user, found by findResetTokenUser from
+// tokenFromUser. The token is consumed again in the transaction: user may be
+// stale by then.
+func (s *AuthService) resetPassword(ctx context.Context, user *models.User, tokenFromUser, newPassword string) error {
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	// Consume the token and change the password in one transaction: of
+	// concurrent resets with the same token only one clears it, and a rejected
+	// password leaves the token usable
+	err := s.userAdapter.Transaction(ctx, func(tx *gormadapter.UserAdapter) error {
+		if isSignedResetToken(tokenFromUser) {
+			// Single use through the password version the link was signed for
+			consumed, err := tx.ConsumePasswordVersion(ctx, userID, user.PasswordVersion)
+			if err != nil {
+				return err
+			}
+			if !consumed {
+				return ErrInvalidToken
+			}
+		} else {
+			consumed, err := tx.ConsumeResetToken(ctx, s.hashToken(tokenFromUser))
+			if err != nil {
+				return err
+			}
+			if !consumed {
+				return ErrInvalidToken
+			}
 		}
-		if user.ResetToken == hashedToken {
-			matchedUser = user
-			break
+		return s.authManager.UpdatePassword(database.WithTx(ctx, tx.DB()), userID, newPassword)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidToken):
+			logger.FromContext(ctx).Warn("Token de reset de senha já utilizado", "user_id", user.ID)
+			return ErrInvalidToken
+		case errors.Is(err, auth.ErrPasswordReused):
+			logger.FromContext(ctx).Warn("Tentativa de reset de senha reutilizando senha recente", "user_id", user.ID)
+			return ErrPasswordReused
+		case errors.Is(err, auth.ErrPasswordTooLong):
+			return ErrPasswordTooLong
 		}
+		logger.FromContext(ctx).Error("Erro ao atualizar senha do usuário", "error", err, "user_id", user.ID)
+		return err
 	}
 
-	if matchedUser == nil {
-		logger.Warn("Tentativa de reset de senha com token inválido")
-		return ErrInvalidToken
+	logger.FromContext(ctx).Info("Senha resetada com sucesso", "user_id", user.ID)
+	s.record(ctx, audit.Event{Type: audit.EventPasswordReset, ActorID: userID, TargetID: userID})
+	s.passwordChanged(ctx, userID)
+
+	// Also invalidate all existing sessions for security. The password is
+	// already changed, but sessions left open are worth failing the request.
+	if s.revokeSessionsOnPasswordChange {
+		if err := s.authManager.LogoutAll(ctx, userID); err != nil {
+			logger.FromContext(ctx).Error("Erro ao revogar sessões após reset de senha", "error", err, "user_id", user.ID)
+			return err
+		}
 	}
+	return nil
+}
 
-	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+// ChangePassword changes the password of an authenticated user, who must
+// confirm the current one (ErrWrongPassword otherwise). It also clears a
+// forced password change set by an admin. The user's other sessions are
+// revoked unless disabled by WithPasswordChangeSessionRevocation; sessionID
+// is the session making the change.
+func (s *AuthService) ChangePassword(ctx context.Context, userID, sessionID, currentPassword, newPassword string) error {
+	ok, err := s.userAdapter.VerifyPassword(ctx, userID, currentPassword)
 	if err != nil {
-		logger.Error("Erro ao gerar hash da nova senha", "error", err, "user_id", matchedUser.ID)
+		logger.FromContext(ctx).Error("Erro ao verificar senha atual", "error", err, "user_id", userID)
+		return err
+	}
+	if !ok {
+		logger.FromContext(ctx).Warn("Tentativa de troca de senha com senha atual incorreta", "user_id", userID)
+		return ErrWrongPassword
+	}
+
+	if err := s.authManager.UpdatePassword(ctx, userID, newPassword); err != nil {
+		if errors.Is(err, auth.ErrPasswordReused) {
+			logger.FromContext(ctx).Warn("Tentativa de troca de senha reutilizando senha recente", "user_id", userID)
+			return ErrPasswordReused
+		}
+		if errors.Is(err, auth.ErrPasswordTooLong) {
+			return ErrPasswordTooLong
+		}
+		logger.FromContext(ctx).Error("Erro ao atualizar senha do usuário", "error", err, "user_id", userID)
 		return err
 	}
 
-	// Update password and clear reset token
-	matchedUser.PasswordHash = string(hashedPassword)
-	matchedUser.ResetToken = ""
-	matchedUser.ResetTokenExpiry = time.Time{}
+	if s.revokeSessionsOnPasswordChange {
+		var err error
+		if s.keepCurrentSession && sessionID != "" {
+			var revocations []auth.SessionRevocation
+			revocations, err = s.authManager.LogoutOthers(ctx, userID, sessionID)
+			for _, revocation := range revocations {
+				err = errors.Join(err, revocation.Err)
+			}
+		} else {
+			err = s.authManager.LogoutAll(ctx, userID)
+		}
+		if err != nil {
+			logger.FromContext(ctx).Error("Erro ao revogar sessões após troca de senha", "error", err, "user_id", userID)
+		}
+	}
+
+	logger.FromContext(ctx).Info("Senha alterada com sucesso", "user_id", userID)
+	s.record(ctx, audit.Event{Type: audit.EventPasswordChanged, ActorID: userID, TargetID: userID})
+	s.passwordChanged(ctx, userID)
+	return nil
+}
 
-	// Also invalidate all existing sessions for security
-	userID := strconv.FormatUint(uint64(matchedUser.ID), 10)
-	_ = s.authManager.LogoutAll(userID)
+// PasswordPolicy returns the password rules in effect: the ones checked by
+// validation.ValidatePassword plus the hasher's length limit and the
+// password history depth
+func (s *AuthService) PasswordPolicy() validation.PasswordPolicy {
+	policy := validation.DefaultPasswordPolicy()
+	policy.MaxLength = s.userAdapter.MaxPasswordBytes()
+	policy.HistoryDepth = s.authManager.PasswordHistoryDepth()
+	return policy
+}
 
-	if err := s.userAdapter.UpdateUser(matchedUser); err != nil {
-		logger.Error("Erro ao atualizar senha do usuário", "error", err, "user_id", matchedUser.ID)
-		return err
+// ValidateResetToken checks that a reset token exists and hasn't expired,
+// without consuming it. Returns ErrInvalidToken or ErrExpiredToken otherwise.
+func (s *AuthService) ValidateResetToken(ctx context.Context, tokenFromUser string) error {
+	_, err := s.findResetTokenUser(ctx, tokenFromUser)
+	return err
+}
+
+// findResetTokenUser returns the user holding a valid, unexpired reset token,
+// either stored or signed
+func (s *AuthService) findResetTokenUser(ctx context.Context, tokenFromUser string) (*models.User, error) {
+	if isSignedResetToken(tokenFromUser) {
+		return s.findSignedResetTokenUser(ctx, tokenFromUser)
+	}
+
+	// Only the hash of the token is stored
+	hashedToken := s.hashToken(tokenFromUser)
+
+	user, err := s.userAdapter.FindByResetToken(ctx, hashedToken)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		logger.FromContext(ctx).Warn("Token de reset de senha inválido")
+		return nil, ErrInvalidToken
 	}
+	if s.clock.Now().After(user.ResetTokenExpiry) {
+		logger.FromContext(ctx).Warn("Token de reset de senha expirado", "user_id", user.ID)
+		return nil, ErrExpiredToken
+	}
+	return user, nil
+}
+
+// RegenerateRecoveryCodes issues a fresh set of 2FA recovery codes for the user,
+// invalidating the old ones. The plaintext codes must be shown to the user once.
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes, err := s.authManager.GenerateRecoveryCodes(ctx, userID)
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao gerar códigos de recuperação", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("Códigos de recuperação regenerados", "user_id", userID, "count", len(codes))
+	return codes, nil
+}
+
+// RecoveryCodesRemaining returns how many unused recovery codes the user has,
+// so the UI can warn when they are running low
+func (s *AuthService) RecoveryCodesRemaining(ctx context.Context, userID string) (int, error) {
+	return s.authManager.RemainingRecoveryCodes(ctx, userID)
+}
 
-	logger.Info("Senha resetada com sucesso", "user_id", matchedUser.ID)
+// SetLocale sets the language the user's emails are sent in; "" goes back to
+// email.default_locale. locale is expected to be validated by the caller.
+func (s *AuthService) SetLocale(ctx context.Context, userID, locale string) error {
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		return err
+	}
+	user.Locale = locale
+	if err := s.userAdapter.UpdateUser(ctx, user); err != nil {
+		logger.FromContext(ctx).Error("Erro ao alterar idioma do usuário", "error", err, "user_id", userID)
+		return err
+	}
+	logger.FromContext(ctx).Info("Idioma do usuário alterado", "user_id", userID, "locale", locale)
 	return nil
 }
 
+// SetAvatar sets the storage key of the user's avatar ("" removes it) and
+// returns the previous one, so the caller can delete that file
+func (s *AuthService) SetAvatar(ctx context.Context, userID, key string) (string, error) {
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	previous := user.AvatarKey
+	user.AvatarKey = key
+	if err := s.userAdapter.UpdateUser(ctx, user); err != nil {
+		logger.FromContext(ctx).Error("Erro ao alterar avatar do usuário", "error", err, "user_id", userID)
+		return "", err
+	}
+	logger.FromContext(ctx).Info("Avatar do usuário alterado", "user_id", userID, "removed", key == "")
+	return previous, nil
+}
+
+// ExportAccount collects the user's profile, session metadata, linked social
+// logins and API keys so they can download a copy of their data. Formatting
+// (and leaving out secrets) is up to the caller's DTO.
+func (s *AuthService) ExportAccount(ctx context.Context, userID string) (*AccountExport, error) {
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao buscar usuário para exportação", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	sessions, err := s.authManager.ListSessions(ctx, userID)
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao listar sessões para exportação", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	linked, err := s.userAdapter.ListExternalIdentities(ctx, userID)
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao listar contas vinculadas para exportação", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	apiKeys, err := s.authManager.ListAPIKeys(ctx, userID)
+	if err != nil && !errors.Is(err, auth.ErrAPIKeysUnsupported) {
+		logger.FromContext(ctx).Error("Erro ao listar chaves de API para exportação", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("Exportação de dados da conta gerada", "user_id", userID)
+	return &AccountExport{
+		User:           user,
+		Sessions:       sessions,
+		LinkedAccounts: linked,
+		APIKeys:        apiKeys,
+	}, nil
+}
+
+// Impersonate starts an impersonation session in which the admin owning
+// adminSessionID acts as targetUserID. Every attempt is logged with both
+// identities for auditing.
+func (s *AuthService) Impersonate(ctx context.Context, adminSessionID, targetUserID, ip, userAgent string) (*LoginResponse, error) {
+	session, user, err := s.authManager.Impersonate(ctx, adminSessionID, targetUserID, auth.SessionMetadata{
+		UserAgent: userAgent,
+		IP:        ip,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrImpersonationForbidden):
+			logger.FromContext(ctx).Warn("Tentativa de impersonação negada", "target_user_id", targetUserID, "ip", ip)
+			return nil, ErrForbidden
+		case errors.Is(err, auth.ErrUserNotActive):
+			return nil, ErrUserNotActive
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			return nil, ErrUserNotFound
+		case errors.Is(err, auth.ErrSessionNotFound), errors.Is(err, auth.ErrSessionExpired):
+			return nil, ErrInvalidToken
+		default:
+			logger.FromContext(ctx).Error("Erro ao iniciar impersonação", "error", err, "target_user_id", targetUserID, "ip", ip)
+			return nil, err
+		}
+	}
+
+	logger.FromContext(ctx).Warn("Impersonação iniciada", "admin_id", session.ImpersonatorID, "target_user_id", user.ID, "ip", ip, "expires_at", session.ExpiresAt)
+	s.record(ctx, audit.Event{Type: audit.EventImpersonationStarted, ActorID: session.ImpersonatorID, TargetID: user.ID, IP: ip, UserAgent: userAgent})
+	return &LoginResponse{
+		SessionID:      session.ID,
+		ExpiresAt:      session.ExpiresAt,
+		User:           *user,
+		ImpersonatorID: session.ImpersonatorID,
+	}, nil
+}
+
+// EndImpersonation ends an impersonation session and returns the admin
+// session it was started from
+func (s *AuthService) EndImpersonation(ctx context.Context, sessionID string) (*LoginResponse, error) {
+	session, user, err := s.authManager.EndImpersonation(ctx, sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrNotImpersonating):
+			return nil, ErrNotImpersonating
+		case errors.Is(err, auth.ErrSessionNotFound), errors.Is(err, auth.ErrSessionExpired):
+			return nil, ErrInvalidToken
+		default:
+			logger.FromContext(ctx).Error("Erro ao encerrar impersonação", "error", err)
+			return nil, err
+		}
+	}
+
+	logger.FromContext(ctx).Warn("Impersonação encerrada", "admin_id", user.ID)
+	return &LoginResponse{
+		SessionID: session.ID,
+		ExpiresAt: session.ExpiresAt,
+		User:      *user,
+	}, nil
+}
+
 // Helper methods
 
 func (s *AuthService) generateSecureToken(b []byte) (int, error) {
-	return auth.GenerateRandomBytes(b)
+	return s.tokens.Read(b)
 }
 
 func (s *AuthService) hashToken(token string) string {
@@ -285,12 +1025,6 @@ func (s *AuthService) hashToken(token string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-func (s *AuthService) findUsersWithResetTokens() ([]*models.User, error) {
-	// This method would need to be added to the userAdapter
-	// For now, we'll use a workaround
-	return nil, errors.New("not implemented - use direct DB query")
-}
-
 // ConvertToPublicUser strips sensitive fields from user
 func ConvertToPublicUser(user *models.User) *models.User {
 	user.PasswordHash = ""
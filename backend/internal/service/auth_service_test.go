@@ -2,13 +2,38 @@
 package service
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"gosveltekit/internal/audit"
 	"gosveltekit/internal/auth"
 	gormadapter "gosveltekit/internal/auth/adapter/gorm"
+	"gosveltekit/internal/auth/oauth"
+	"gosveltekit/internal/captcha"
 	"gosveltekit/internal/email"
+	"gosveltekit/internal/jobs"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/metrics"
 	"gosveltekit/internal/models"
+	"gosveltekit/internal/outbox"
+	"gosveltekit/internal/realtime"
+	"gosveltekit/internal/settings"
+	"gosveltekit/internal/tenant"
+	"gosveltekit/internal/tokens"
+	"gosveltekit/internal/validation"
+	"gosveltekit/internal/webhooks"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
@@ -21,7 +46,7 @@ func setupTest(t *testing.T) (*AuthService, *auth.AuthManager, *gormadapter.User
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
 
-	err = db.AutoMigrate(&models.User{}, &models.Session{})
+	err = db.AutoMigrate(&models.User{}, &models.Session{}, &models.RecoveryCode{}, &models.PasswordHistory{}, &models.SMSCode{}, &models.ExternalIdentity{}, &models.TOTPSecret{}, &models.TwoFactorChallenge{}, &models.RefreshToken{}, &models.LoginAttempt{}, &models.MagicLink{})
 	require.NoError(t, err)
 
 	userAdapter := gormadapter.NewUserAdapter(db)
@@ -66,7 +91,7 @@ func TestAuthService_Login_Success(t *testing.T) {
 	authService, _, _, _, _, db := setupTest(t)
 	user := createTestUser(t, db)
 
-	response, err := authService.Login("testuser", "password123", "127.0.0.1", "test-agent")
+	response, err := authService.Login(context.Background(), "testuser", "password123", "127.0.0.1", "test-agent")
 
 	require.NoError(t, err)
 	assert.NotNil(t, response)
@@ -107,7 +132,7 @@ func TestAuthService_Login_InvalidCredentials(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			response, err := authService.Login(tc.username, tc.password, "127.0.0.1", "test-agent")
+			response, err := authService.Login(context.Background(), tc.username, tc.password, "127.0.0.1", "test-agent")
 			assert.Nil(t, response)
 			assert.ErrorIs(t, err, tc.wantErr)
 		})
@@ -120,11 +145,11 @@ func TestAuthService_Login_AccountLocked(t *testing.T) {
 
 	// Attempt to login with wrong password 5 times
 	for i := 0; i < 5; i++ {
-		_, _ = authService.Login("testuser", "wrongpass", "127.0.0.1", "test-agent")
+		_, _ = authService.Login(context.Background(), "testuser", "wrongpass", "127.0.0.1", "test-agent")
 	}
 
 	// Try one more time
-	response, err := authService.Login("testuser", "wrongpass", "127.0.0.1", "test-agent")
+	response, err := authService.Login(context.Background(), "testuser", "wrongpass", "127.0.0.1", "test-agent")
 	assert.Nil(t, response)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "bloqueada")
@@ -138,7 +163,7 @@ func TestAuthService_Login_InactiveUser(t *testing.T) {
 	user.Active = false
 	db.Save(user)
 
-	response, err := authService.Login("testuser", "password123", "127.0.0.1", "test-agent")
+	response, err := authService.Login(context.Background(), "testuser", "password123", "127.0.0.1", "test-agent")
 	assert.Nil(t, response)
 	assert.ErrorIs(t, err, ErrUserNotActive)
 }
@@ -148,11 +173,11 @@ func TestAuthService_ValidateSession_Success(t *testing.T) {
 	user := createTestUser(t, db)
 
 	// First login to get a session
-	loginResp, err := authService.Login("testuser", "password123", "127.0.0.1", "test-agent")
+	loginResp, err := authService.Login(context.Background(), "testuser", "password123", "127.0.0.1", "test-agent")
 	require.NoError(t, err)
 
 	// Validate the session
-	session, userData, err := authService.ValidateSession(loginResp.SessionID)
+	session, userData, err := authService.ValidateSession(context.Background(), loginResp.SessionID)
 
 	require.NoError(t, err)
 	assert.NotNil(t, session)
@@ -163,7 +188,7 @@ func TestAuthService_ValidateSession_Success(t *testing.T) {
 func TestAuthService_ValidateSession_Invalid(t *testing.T) {
 	authService, _, _, _, _, _ := setupTest(t)
 
-	session, userData, err := authService.ValidateSession("invalid-session-id")
+	session, userData, err := authService.ValidateSession(context.Background(), "invalid-session-id")
 	assert.Nil(t, session)
 	assert.Nil(t, userData)
 	assert.ErrorIs(t, err, ErrInvalidToken)
@@ -174,22 +199,22 @@ func TestAuthService_Logout(t *testing.T) {
 	_ = createTestUser(t, db)
 
 	// First login to get a session
-	loginResp, err := authService.Login("testuser", "password123", "127.0.0.1", "test-agent")
+	loginResp, err := authService.Login(context.Background(), "testuser", "password123", "127.0.0.1", "test-agent")
 	require.NoError(t, err)
 
 	// Logout
-	err = authService.Logout(loginResp.SessionID)
+	err = authService.Logout(context.Background(), loginResp.SessionID)
 	require.NoError(t, err)
 
 	// Verify session is invalid
-	_, _, err = authService.ValidateSession(loginResp.SessionID)
+	_, _, err = authService.ValidateSession(context.Background(), loginResp.SessionID)
 	assert.Error(t, err)
 }
 
 func TestAuthService_Register_Success(t *testing.T) {
 	authService, _, _, _, _, _ := setupTest(t)
 
-	user, err := authService.Register("newuser", "new@example.com", "password123", "New User")
+	user, _, err := authService.Register(context.Background(), "newuser", "new@example.com", "password123", "New User", "", "127.0.0.1")
 
 	require.NoError(t, err)
 	assert.NotNil(t, user)
@@ -205,23 +230,36 @@ func TestAuthService_Register_DuplicateUser(t *testing.T) {
 	_ = createTestUser(t, db)
 
 	// Try to register with same username
-	user, err := authService.Register("testuser", "another@example.com", "password123", "Another User")
+	user, _, err := authService.Register(context.Background(), "testuser", "another@example.com", "password123", "Another User", "", "127.0.0.1")
 	assert.Nil(t, user)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "username already exists")
 
 	// Try to register with same email
-	user, err = authService.Register("anotheruser", "test@example.com", "password123", "Another User")
+	user, _, err = authService.Register(context.Background(), "anotheruser", "test@example.com", "password123", "Another User", "", "127.0.0.1")
 	assert.Nil(t, user)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "email already exists")
 }
 
+func TestAuthService_EmailAvailable(t *testing.T) {
+	authService, _, _, _, _, db := setupTest(t)
+	user := createTestUser(t, db)
+
+	available, err := authService.EmailAvailable(context.Background(), user.Email)
+	require.NoError(t, err)
+	assert.False(t, available)
+
+	available, err = authService.EmailAvailable(context.Background(), "free@example.com")
+	require.NoError(t, err)
+	assert.True(t, available)
+}
+
 func TestAuthService_RequestPasswordReset(t *testing.T) {
 	authService, _, _, _, mockEmailService, db := setupTest(t)
 	user := createTestUser(t, db)
 
-	err := authService.RequestPasswordReset(user.Email)
+	err := authService.RequestPasswordReset(context.Background(), user.Email)
 	require.NoError(t, err)
 
 	// Verify that reset token was set
@@ -239,3 +277,1746 @@ func TestAuthService_RequestPasswordReset(t *testing.T) {
 	assert.Equal(t, user.DisplayName, sentEmails[0].DisplayName)
 	assert.NotEmpty(t, sentEmails[0].Token)
 }
+
+func TestAuthService_RevokeToken(t *testing.T) {
+	authService, authManager, _, _, _, db := setupTest(t)
+	user := createTestUser(t, db)
+	ctx := context.Background()
+
+	login := func() string {
+		response, err := authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test-agent")
+		require.NoError(t, err)
+		return response.SessionID
+	}
+	discarded, kept := login(), login()
+
+	// Identified the way the auth middleware does
+	require.NoError(t, authService.RevokeToken(ctx, user.PublicID(), discarded))
+	_, _, err := authManager.ValidateSession(ctx, discarded)
+	assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+	_, _, err = authManager.ValidateSession(ctx, kept)
+	assert.NoError(t, err, "other sessions are untouched")
+
+	// Idempotent, and unknown tokens reveal nothing
+	assert.NoError(t, authService.RevokeToken(ctx, user.PublicID(), discarded))
+	assert.NoError(t, authService.RevokeToken(ctx, user.PublicID(), "unknown-token"))
+
+	// Only the owner can revoke a session
+	other := models.User{Username: "other", Email: "other@example.com", PasswordHash: "x", Active: true}
+	require.NoError(t, db.Create(&other).Error)
+	assert.ErrorIs(t, authService.RevokeToken(ctx, other.PublicID(), kept), ErrForbidden)
+	_, _, err = authManager.ValidateSession(ctx, kept)
+	assert.NoError(t, err)
+}
+
+func TestAuthService_TokenGenerator(t *testing.T) {
+	resetToken := func(t *testing.T) string {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&models.User{}, &models.Session{}))
+
+		// The service uses the generator of the auth manager
+		userAdapter := gormadapter.NewUserAdapter(db)
+		authConfig := auth.DefaultAuthConfig()
+		authConfig.Tokens = tokens.NewDeterministic("reset")
+		authManager := auth.NewAuthManager(userAdapter, gormadapter.NewSessionAdapter(db), authConfig)
+		mockEmailService := email.NewMockEmailService()
+		authService := NewAuthService(authManager, userAdapter, mockEmailService)
+		user := createTestUser(t, db)
+
+		require.NoError(t, authService.RequestPasswordReset(context.Background(), user.Email))
+		sentEmails := mockEmailService.GetSentEmails()
+		require.Len(t, sentEmails, 1)
+		return sentEmails[0].Token
+	}
+
+	token := resetToken(t)
+	assert.Len(t, token, 64)
+	assert.Equal(t, token, resetToken(t), "same seed, same reset token")
+}
+
+func TestAuthService_AdminRequestPasswordReset(t *testing.T) {
+	authService, _, _, _, mockEmailService, db := setupTest(t)
+	user := createTestUser(t, db)
+	ctx := context.Background()
+
+	require.NoError(t, authService.AdminRequestPasswordReset(ctx, "99", strconv.FormatUint(uint64(user.ID), 10)))
+
+	sentEmails := mockEmailService.GetSentEmails()
+	require.Len(t, sentEmails, 1)
+	assert.Equal(t, "password_reset", sentEmails[0].Kind)
+	assert.Equal(t, user.Email, sentEmails[0].To)
+	require.NotEmpty(t, sentEmails[0].Token)
+
+	// The link works like a self-service one
+	require.NoError(t, authService.ValidateResetToken(ctx, sentEmails[0].Token))
+
+	assert.ErrorIs(t, authService.AdminRequestPasswordReset(ctx, "99", "12345"), ErrUserNotFound)
+	assert.Len(t, mockEmailService.GetSentEmails(), 1)
+}
+
+func TestAuthService_RecoveryCodes_SingleUse(t *testing.T) {
+	authService, authManager, _, _, _, db := setupTest(t)
+	user := createTestUser(t, db)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	ctx := context.Background()
+
+	codes, err := authService.RegenerateRecoveryCodes(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, codes, auth.RecoveryCodeCount)
+
+	remaining, err := authService.RecoveryCodesRemaining(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, auth.RecoveryCodeCount, remaining)
+
+	// First use succeeds, second use of the same code fails
+	require.NoError(t, authManager.VerifyRecoveryCode(ctx, userID, codes[0]))
+	assert.ErrorIs(t, authManager.VerifyRecoveryCode(ctx, userID, codes[0]), auth.ErrInvalidRecoveryCode)
+
+	// Codes are accepted regardless of case and separators
+	require.NoError(t, authManager.VerifyRecoveryCode(ctx, userID, strings.ToUpper(strings.ReplaceAll(codes[1], "-", ""))))
+
+	remaining, err = authService.RecoveryCodesRemaining(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, auth.RecoveryCodeCount-2, remaining)
+
+	// Regenerating invalidates the old set
+	newCodes, err := authService.RegenerateRecoveryCodes(ctx, userID)
+	require.NoError(t, err)
+	assert.ErrorIs(t, authManager.VerifyRecoveryCode(ctx, userID, codes[2]), auth.ErrInvalidRecoveryCode)
+	assert.NoError(t, authManager.VerifyRecoveryCode(ctx, userID, newCodes[2]))
+}
+
+// setupJobs returns a queue delivering emails through emailService, with its
+// table migrated in db
+func setupJobs(t *testing.T, db *gorm.DB, emailService email.EmailServiceInterface) *jobs.Queue {
+	require.NoError(t, db.AutoMigrate(&models.Job{}))
+	q := jobs.New(jobs.NewDBStore(db), jobs.Config{})
+	outbox.Register(q, emailService)
+	return q
+}
+
+func TestAuthService_RequestPasswordReset_Jobs(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Session{}))
+
+	userAdapter := gormadapter.NewUserAdapter(db)
+	authManager := auth.NewAuthManager(userAdapter, gormadapter.NewSessionAdapter(db), auth.DefaultAuthConfig())
+	mockEmailService := email.NewMockEmailService()
+	queue := setupJobs(t, db, mockEmailService)
+	authService := NewAuthService(authManager, userAdapter, mockEmailService, WithJobs(queue))
+	user := createTestUser(t, db)
+
+	ctx := logger.WithRequestID(context.Background(), "req-123")
+	require.NoError(t, authService.RequestPasswordReset(ctx, user.Email))
+
+	// The operation committed exactly one pending job and sent nothing yet
+	var queued []models.Job
+	require.NoError(t, db.Find(&queued).Error)
+	require.Len(t, queued, 1)
+	assert.Equal(t, models.JobStatusPending, queued[0].Status)
+	assert.Equal(t, outbox.JobType(outbox.KindPasswordReset), queued[0].Type)
+	assert.Contains(t, queued[0].Payload, user.Email)
+	assert.Equal(t, "req-123", queued[0].RequestID, "the job carries the originating request")
+	assert.Empty(t, mockEmailService.GetSentEmails())
+
+	var updatedUser models.User
+	require.NoError(t, db.First(&updatedUser, user.ID).Error)
+	assert.NotEmpty(t, updatedUser.ResetToken)
+
+	// The queue delivers it and marks it succeeded
+	ran, err := queue.RunDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, ran)
+
+	sentEmails := mockEmailService.GetSentEmails()
+	require.Len(t, sentEmails, 1)
+	assert.Equal(t, user.Email, sentEmails[0].To)
+	assert.Equal(t, updatedUser.ResetToken, authService.hashToken(sentEmails[0].Token))
+	assert.Equal(t, "req-123", sentEmails[0].RequestID, "delivery runs under the originating request")
+
+	var job models.Job
+	require.NoError(t, db.First(&job, queued[0].ID).Error)
+	assert.Equal(t, models.JobStatusSucceeded, job.Status)
+	assert.NotNil(t, job.FinishedAt)
+	assert.Empty(t, job.Payload)
+}
+
+func TestAuthService_ExportAccount(t *testing.T) {
+	authService, _, _, _, _, db := setupTest(t)
+	user := createTestUser(t, db)
+
+	// Two sessions of the user and one of someone else
+	first, err := authService.Login(context.Background(), "testuser", "password123", "10.0.0.1", "browser")
+	require.NoError(t, err)
+	second, err := authService.Login(context.Background(), "testuser", "password123", "10.0.0.2", "phone")
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&models.Session{ID: "someone-else", UserID: user.ID + 1, ExpiresAt: first.ExpiresAt}).Error)
+
+	require.NoError(t, db.AutoMigrate(&models.APIKey{}))
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	require.NoError(t, authService.userAdapter.LinkExternalIdentity(context.Background(), userID, "github", "42", user.Email))
+	_, _, err = authService.authManager.CreateAPIKey(context.Background(), userID, "ci", []string{"users:read"}, nil)
+	require.NoError(t, err)
+
+	export, err := authService.ExportAccount(context.Background(), userID)
+	require.NoError(t, err)
+
+	assert.Equal(t, user.ID, export.User.ID)
+	assert.Equal(t, user.Email, export.User.Email)
+	require.Len(t, export.LinkedAccounts, 1)
+	assert.Equal(t, "github", export.LinkedAccounts[0].Provider)
+	require.Len(t, export.APIKeys, 1)
+	assert.Equal(t, "ci", export.APIKeys[0].Name)
+
+	ids := make([]string, 0, len(export.Sessions))
+	for _, session := range export.Sessions {
+		ids = append(ids, session.ID)
+	}
+	assert.ElementsMatch(t, []string{first.SessionID, second.SessionID}, ids)
+}
+
+func TestAuthService_FailureMetrics(t *testing.T) {
+	authService, _, _, _, _, db := setupTest(t)
+	_ = createTestUser(t, db)
+
+	loginFailures := func(reason string) float64 {
+		return testutil.ToFloat64(metrics.LoginFailures.WithLabelValues(reason))
+	}
+	conflicts := func(field string) float64 {
+		return testutil.ToFloat64(metrics.RegistrationConflicts.WithLabelValues(field))
+	}
+
+	t.Run("registration conflicts by field", func(t *testing.T) {
+		usernameBefore, emailBefore := conflicts(metrics.FieldUsername), conflicts(metrics.FieldEmail)
+
+		_, _, err := authService.Register(context.Background(), "testuser", "another@example.com", "password123", "Another User", "", "127.0.0.1")
+		require.Error(t, err)
+		assert.Equal(t, usernameBefore+1, conflicts(metrics.FieldUsername))
+		assert.Equal(t, emailBefore, conflicts(metrics.FieldEmail))
+
+		_, _, err = authService.Register(context.Background(), "anotheruser", "test@example.com", "password123", "Another User", "", "127.0.0.1")
+		require.Error(t, err)
+		assert.Equal(t, usernameBefore+1, conflicts(metrics.FieldUsername))
+		assert.Equal(t, emailBefore+1, conflicts(metrics.FieldEmail))
+	})
+
+	t.Run("bad password then locked", func(t *testing.T) {
+		invalidBefore, lockedBefore := loginFailures(metrics.ReasonInvalidCredentials), loginFailures(metrics.ReasonAccountLocked)
+
+		maxAttempts := auth.DefaultAuthConfig().MaxFailedAttempts
+		for i := 0; i < maxAttempts; i++ {
+			_, err := authService.Login(context.Background(), "testuser", "wrongpassword", "127.0.0.1", "test-agent")
+			require.ErrorIs(t, err, ErrInvalidCredentials)
+		}
+		assert.Equal(t, invalidBefore+float64(maxAttempts), loginFailures(metrics.ReasonInvalidCredentials))
+
+		_, err := authService.Login(context.Background(), "testuser", "password123", "127.0.0.1", "test-agent")
+		require.Error(t, err)
+		assert.Equal(t, lockedBefore+1, loginFailures(metrics.ReasonAccountLocked))
+		assert.Equal(t, invalidBefore+float64(maxAttempts), loginFailures(metrics.ReasonInvalidCredentials))
+	})
+
+	t.Run("inactive user", func(t *testing.T) {
+		authService, _, _, _, _, db := setupTest(t)
+		inactive := createTestUser(t, db)
+		require.NoError(t, db.Model(inactive).Update("active", false).Error)
+		inactiveBefore := loginFailures(metrics.ReasonUserInactive)
+
+		_, err := authService.Login(context.Background(), "testuser", "password123", "127.0.0.1", "test-agent")
+		require.ErrorIs(t, err, ErrUserNotActive)
+		assert.Equal(t, inactiveBefore+1, loginFailures(metrics.ReasonUserInactive))
+	})
+}
+
+func TestAuthService_ResetPassword_PasswordHistory(t *testing.T) {
+	_, _, userAdapter, sessionAdapter, mockEmailService, db := setupTest(t)
+	user := createTestUser(t, db) // password123
+
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.PasswordHistoryDepth = 2
+	authManager := auth.NewAuthManager(userAdapter, sessionAdapter, authConfig)
+	authService := NewAuthService(authManager, userAdapter, mockEmailService)
+
+	resetTo := func(newPassword string) error {
+		mockEmailService.ClearSentEmails()
+		require.NoError(t, authService.RequestPasswordReset(context.Background(), user.Email))
+		sent := mockEmailService.GetSentEmails()
+		require.Len(t, sent, 1)
+		return authService.ResetPassword(context.Background(), sent[0].Token, newPassword)
+	}
+
+	// password123 -> Password2! -> Password3!
+	require.NoError(t, resetTo("Password2!"))
+	require.NoError(t, resetTo("Password3!"))
+
+	// The immediately previous password is rejected, and so is the current one
+	assert.ErrorIs(t, resetTo("Password2!"), ErrPasswordReused)
+	assert.ErrorIs(t, resetTo("Password3!"), ErrPasswordReused)
+
+	// Older than the depth is allowed again
+	require.NoError(t, resetTo("password123"))
+	_, err := authService.Login(context.Background(), "testuser", "password123", "127.0.0.1", "test-agent")
+	require.NoError(t, err)
+
+	// History is pruned to depth-1 previous passwords
+	var count int64
+	require.NoError(t, db.Model(&models.PasswordHistory{}).Where("user_id = ?", user.ID).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	// The token can't be used twice
+	mockEmailService.ClearSentEmails()
+	require.NoError(t, authService.RequestPasswordReset(context.Background(), user.Email))
+	token := mockEmailService.GetSentEmails()[0].Token
+	require.NoError(t, authService.ResetPassword(context.Background(), token, "Password4!"))
+	assert.ErrorIs(t, authService.ResetPassword(context.Background(), token, "Password5!"), ErrInvalidToken)
+
+	// A rejected password rolls the consumed token back, so it can be retried
+	mockEmailService.ClearSentEmails()
+	require.NoError(t, authService.RequestPasswordReset(context.Background(), user.Email))
+	token = mockEmailService.GetSentEmails()[0].Token
+	assert.ErrorIs(t, authService.ResetPassword(context.Background(), token, "Password4!"), ErrPasswordReused)
+	require.NoError(t, authService.ResetPassword(context.Background(), token, "Password6!"))
+}
+
+func TestAuthService_Login_RehashesOutdatedHash(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Session{}, &models.TOTPSecret{}, &models.LoginAttempt{}))
+
+	oldParams := auth.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	newParams := auth.Argon2Params{Memory: 2048, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+	oldHash, err := auth.NewArgon2idHasher(oldParams).Hash("password123")
+	require.NoError(t, err)
+	user := &models.User{Username: "testuser", Email: "test@example.com", PasswordHash: oldHash, Active: true, Role: "user"}
+	require.NoError(t, db.Create(user).Error)
+
+	hasher := auth.NewArgon2idHasher(newParams)
+	userAdapter := gormadapter.NewUserAdapter(db, gormadapter.WithPasswordHasher(hasher))
+	authManager := auth.NewAuthManager(userAdapter, gormadapter.NewSessionAdapter(db), auth.DefaultAuthConfig())
+	authService := NewAuthService(authManager, userAdapter, email.NewMockEmailService())
+
+	_, err = authService.Login(context.Background(), "testuser", "password123", "127.0.0.1", "test-agent")
+	require.NoError(t, err)
+
+	var stored models.User
+	require.NoError(t, db.First(&stored, user.ID).Error)
+	assert.NotEqual(t, oldHash, stored.PasswordHash)
+	assert.Contains(t, stored.PasswordHash, "$m=2048,t=2,p=1$")
+	assert.False(t, hasher.NeedsRehash(stored.PasswordHash))
+
+	// The upgraded hash still accepts the same password
+	_, err = authService.Login(context.Background(), "testuser", "password123", "127.0.0.1", "test-agent")
+	assert.NoError(t, err)
+}
+
+func TestAuthService_WelcomeEmail(t *testing.T) {
+	setup := func(t *testing.T, queued bool, opts ...Option) (*AuthService, *email.MockEmailService, *gorm.DB, *jobs.Queue) {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&models.User{}, &models.Session{}))
+
+		userAdapter := gormadapter.NewUserAdapter(db)
+		authManager := auth.NewAuthManager(userAdapter, gormadapter.NewSessionAdapter(db), auth.DefaultAuthConfig())
+		mockEmailService := email.NewMockEmailService()
+		var queue *jobs.Queue
+		if queued {
+			queue = setupJobs(t, db, mockEmailService)
+			opts = append(opts, WithJobs(queue))
+		}
+		return NewAuthService(authManager, userAdapter, mockEmailService, opts...), mockEmailService, db, queue
+	}
+	welcomeJobs := func(t *testing.T, db *gorm.DB) []models.Job {
+		var queued []models.Job
+		require.NoError(t, db.Where("type = ?", outbox.JobType(outbox.KindWelcome)).Order("id").Find(&queued).Error)
+		return queued
+	}
+	ctx := context.Background()
+
+	t.Run("Queued once per registration", func(t *testing.T) {
+		authService, mockEmailService, db, queue := setup(t, true, WithWelcomeEmail(WelcomeOnRegister))
+
+		_, _, err := authService.Register(ctx, "alice", "alice@example.com", "Password123!", "Alice", "", "127.0.0.1")
+		require.NoError(t, err)
+		_, _, err = authService.Register(ctx, "bob", "bob@example.com", "Password123!", "", "", "127.0.0.1")
+		require.NoError(t, err)
+
+		// A rejected registration queues nothing
+		_, _, err = authService.Register(ctx, "alice", "other@example.com", "Password123!", "", "", "127.0.0.1")
+		require.Error(t, err)
+
+		queued := welcomeJobs(t, db)
+		require.Len(t, queued, 2)
+		assert.Contains(t, queued[0].Payload, "alice@example.com")
+		assert.Contains(t, queued[1].Payload, "bob@example.com")
+		assert.Empty(t, mockEmailService.GetSentEmails())
+
+		ran, err := queue.RunDue(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, ran)
+
+		sentEmails := mockEmailService.GetSentEmails()
+		require.Len(t, sentEmails, 2)
+		assert.Equal(t, "welcome", sentEmails[0].Kind)
+		assert.Equal(t, "Alice", sentEmails[0].DisplayName)
+		assert.Equal(t, "bob", sentEmails[1].DisplayName, "falls back to the username")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		authService, _, db, _ := setup(t, true)
+
+		_, _, err := authService.Register(ctx, "alice", "alice@example.com", "Password123!", "Alice", "", "127.0.0.1")
+		require.NoError(t, err)
+		assert.Empty(t, welcomeJobs(t, db))
+	})
+
+	t.Run("After verification", func(t *testing.T) {
+		authService, _, db, _ := setup(t, true, WithWelcomeEmail(WelcomeOnVerification))
+
+		user, _, err := authService.Register(ctx, "alice", "alice@example.com", "Password123!", "Alice", "", "127.0.0.1")
+		require.NoError(t, err)
+		assert.Empty(t, welcomeJobs(t, db), "not sent on registration")
+
+		userID := strconv.FormatUint(uint64(user.ID), 10)
+		require.NoError(t, authService.MarkEmailVerified(ctx, userID))
+		require.NoError(t, authService.MarkEmailVerified(ctx, userID))
+
+		assert.Len(t, welcomeJobs(t, db), 1, "sent on the first verification only")
+
+		var stored models.User
+		require.NoError(t, db.First(&stored, user.ID).Error)
+		assert.True(t, stored.EmailVerified)
+	})
+
+	t.Run("Without jobs", func(t *testing.T) {
+		authService, mockEmailService, _, _ := setup(t, false, WithWelcomeEmail(WelcomeOnRegister))
+
+		_, _, err := authService.Register(ctx, "alice", "alice@example.com", "Password123!", "Alice", "", "127.0.0.1")
+		require.NoError(t, err)
+
+		// Sent in the background
+		assert.Eventually(t, func() bool {
+			return len(mockEmailService.GetSentEmails()) == 1
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, "welcome", mockEmailService.GetSentEmails()[0].Kind)
+	})
+
+	t.Run("Send failure keeps the account by default", func(t *testing.T) {
+		authService, mockEmailService, db, _ := setup(t, false, WithWelcomeEmail(WelcomeOnRegister))
+		mockEmailService.SetSendEmailError(errors.New("smtp down"))
+
+		user, _, err := authService.Register(ctx, "alice", "alice@example.com", "Password123!", "Alice", "", "127.0.0.1")
+		require.NoError(t, err)
+		assert.False(t, user.EmailVerified)
+
+		assert.Eventually(t, func() bool {
+			return len(mockEmailService.GetSentEmails()) == 1
+		}, time.Second, 10*time.Millisecond, "the send was attempted")
+		var count int64
+		require.NoError(t, db.Model(&models.User{}).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Send failure undoes a strict registration, even with jobs", func(t *testing.T) {
+		authService, mockEmailService, db, _ := setup(t, true, WithWelcomeEmail(WelcomeOnRegister), WithStrictRegistrationEmail())
+		mockEmailService.SetSendEmailError(errors.New("smtp down"))
+
+		_, _, err := authService.Register(ctx, "alice", "alice@example.com", "Password123!", "Alice", "", "127.0.0.1")
+		assert.ErrorIs(t, err, ErrRegistrationEmail)
+		var count int64
+		require.NoError(t, db.Unscoped().Model(&models.User{}).Count(&count).Error)
+		assert.Zero(t, count)
+
+		// The same registration goes through once email works again
+		mockEmailService.SetSendEmailError(nil)
+		mockEmailService.ClearSentEmails()
+		_, _, err = authService.Register(ctx, "alice", "alice@example.com", "Password123!", "Alice", "", "127.0.0.1")
+		require.NoError(t, err)
+		sentEmails := mockEmailService.GetSentEmails()
+		require.Len(t, sentEmails, 1, "sent before answering")
+		assert.Equal(t, "welcome", sentEmails[0].Kind)
+	})
+}
+
+func TestAuthService_ValidateResetToken(t *testing.T) {
+	authService, _, _, _, mockEmailService, db := setupTest(t)
+	user := createTestUser(t, db)
+	ctx := context.Background()
+
+	require.NoError(t, authService.RequestPasswordReset(ctx, user.Email))
+	token := mockEmailService.GetSentEmails()[0].Token
+
+	// Valid, and checking it has no side effects
+	require.NoError(t, authService.ValidateResetToken(ctx, token))
+	require.NoError(t, authService.ValidateResetToken(ctx, token))
+
+	assert.ErrorIs(t, authService.ValidateResetToken(ctx, "unknown-token"), ErrInvalidToken)
+
+	// The token still works for the actual reset
+	require.NoError(t, authService.ResetPassword(ctx, token, "NewPassword1!"))
+	assert.ErrorIs(t, authService.ValidateResetToken(ctx, token), ErrInvalidToken, "consumed by the reset")
+
+	// Expired
+	mockEmailService.ClearSentEmails()
+	require.NoError(t, authService.RequestPasswordReset(ctx, user.Email))
+	token = mockEmailService.GetSentEmails()[0].Token
+	require.NoError(t, db.Model(&models.User{}).Where("id = ?", user.ID).Update("reset_token_expiry", time.Now().Add(-time.Minute)).Error)
+	assert.ErrorIs(t, authService.ValidateResetToken(ctx, token), ErrExpiredToken)
+}
+
+// mockCaptchaVerifier accepts only "human" tokens
+type mockCaptchaVerifier struct {
+	calls int
+}
+
+func (m *mockCaptchaVerifier) Verify(ctx context.Context, token, clientIP string) error {
+	m.calls++
+	if token != "human" {
+		return captcha.ErrVerificationFailed
+	}
+	return nil
+}
+
+func TestAuthService_Register_Captcha(t *testing.T) {
+	_, authManager, userAdapter, _, mockEmailService, db := setupTest(t)
+	verifier := &mockCaptchaVerifier{}
+	authService := NewAuthService(authManager, userAdapter, mockEmailService, WithCaptcha(verifier))
+	ctx := context.Background()
+
+	t.Run("Pass", func(t *testing.T) {
+		user, _, err := authService.Register(ctx, "human", "human@example.com", "Password123!", "Human", "human", "10.0.0.1")
+		require.NoError(t, err)
+		assert.Equal(t, "human", user.Username)
+	})
+
+	t.Run("Fail", func(t *testing.T) {
+		_, _, err := authService.Register(ctx, "bot", "bot@example.com", "Password123!", "Bot", "forged", "10.0.0.2")
+		assert.ErrorIs(t, err, ErrCaptchaFailed)
+
+		// Rejected before the user is created
+		var count int64
+		require.NoError(t, db.Model(&models.User{}).Where("username = ?", "bot").Count(&count).Error)
+		assert.Zero(t, count)
+	})
+
+	assert.Equal(t, 2, verifier.calls)
+}
+
+// mockSMSSender records sent messages
+type mockSMSSender struct {
+	to       []string
+	messages []string
+}
+
+func (m *mockSMSSender) Send(ctx context.Context, to, message string) error {
+	m.to = append(m.to, to)
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+var smsCodePattern = regexp.MustCompile(`\b[0-9]{6}\b`)
+
+// lastCode extracts the code from the last message sent
+func (m *mockSMSSender) lastCode(t *testing.T) string {
+	require.NotEmpty(t, m.messages)
+	code := smsCodePattern.FindString(m.messages[len(m.messages)-1])
+	require.NotEmpty(t, code)
+	return code
+}
+
+func TestAuthService_SMSTwoFactor(t *testing.T) {
+	_, _, userAdapter, sessionAdapter, mockEmailService, db := setupTest(t)
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.SMSCodeInterval = 0
+	authConfig.SMSCodesPerHour = 4
+	authManager := auth.NewAuthManager(userAdapter, sessionAdapter, authConfig)
+	sender := &mockSMSSender{}
+	authService := NewAuthService(authManager, userAdapter, mockEmailService, WithSMSSender(sender))
+
+	user := createTestUser(t, db)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, authService.SetPhoneNumber(ctx, userID, "11999999999"), ErrInvalidPhoneNumber)
+
+	// SMS 2FA requires a verified phone
+	require.NoError(t, authService.SetPhoneNumber(ctx, userID, "+5511999999999"))
+	assert.Equal(t, []string{"+5511999999999"}, sender.to)
+	assert.ErrorIs(t, authService.SetTwoFactorMethod(ctx, userID, TwoFactorSMS), ErrPhoneNotVerified)
+
+	// Codes are single-use and bound to their purpose
+	code := sender.lastCode(t)
+	assert.ErrorIs(t, authService.VerifyTwoFactorCode(ctx, userID, code), ErrInvalidSMSCode)
+	require.NoError(t, authService.VerifyPhoneNumber(ctx, userID, code))
+	assert.ErrorIs(t, authService.VerifyPhoneNumber(ctx, userID, code), ErrInvalidSMSCode)
+
+	assert.ErrorIs(t, authService.SendTwoFactorCode(ctx, userID), ErrTwoFactorSMSNotEnabled)
+	require.NoError(t, authService.SetTwoFactorMethod(ctx, userID, TwoFactorSMS))
+	assert.ErrorIs(t, authService.SetPhoneNumber(ctx, userID, "+5511888888888"), ErrPhoneLockedBy2FA)
+
+	t.Run("Expiry", func(t *testing.T) {
+		require.NoError(t, authService.SendTwoFactorCode(ctx, userID))
+		code := sender.lastCode(t)
+		require.NoError(t, db.Model(&models.SMSCode{}).Where("user_id = ?", user.ID).
+			Update("expires_at", time.Now().Add(-time.Second)).Error)
+		assert.ErrorIs(t, authService.VerifyTwoFactorCode(ctx, userID, code), ErrInvalidSMSCode)
+	})
+
+	t.Run("AttemptLimit", func(t *testing.T) {
+		require.NoError(t, authService.SendTwoFactorCode(ctx, userID))
+		code := sender.lastCode(t)
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+		for i := 0; i < authConfig.SMSCodeMaxAttempts; i++ {
+			assert.ErrorIs(t, authService.VerifyTwoFactorCode(ctx, userID, wrong), ErrInvalidSMSCode)
+		}
+		// The right code is no longer accepted once the attempts are used up
+		assert.ErrorIs(t, authService.VerifyTwoFactorCode(ctx, userID, code), ErrInvalidSMSCode)
+	})
+
+	t.Run("RateLimit", func(t *testing.T) {
+		// 4 codes were sent within the hour
+		require.NoError(t, authService.SendTwoFactorCode(ctx, userID))
+		assert.ErrorIs(t, authService.SendTwoFactorCode(ctx, userID), ErrSMSRateLimited)
+		assert.Len(t, sender.messages, 4)
+
+		// A new code replaces the pending one
+		require.NoError(t, db.Where("user_id = ?", user.ID).Delete(&models.SMSCode{}).Error)
+		require.NoError(t, authService.SendTwoFactorCode(ctx, userID))
+		first := sender.lastCode(t)
+		require.NoError(t, authService.SendTwoFactorCode(ctx, userID))
+		second := sender.lastCode(t)
+		if first != second {
+			assert.ErrorIs(t, authService.VerifyTwoFactorCode(ctx, userID, first), ErrInvalidSMSCode)
+		}
+		assert.NoError(t, authService.VerifyTwoFactorCode(ctx, userID, second))
+	})
+
+	t.Run("Login", func(t *testing.T) {
+		sent := len(sender.messages)
+		response, err := authService.Login(ctx, "testuser", "password123", "203.0.113.7", "test-agent")
+		require.NoError(t, err)
+		require.NotNil(t, response.TwoFactor, "the password alone gives no session")
+		assert.Empty(t, response.SessionID)
+		assert.Equal(t, TwoFactorSMS, response.TwoFactor.Method)
+		require.Len(t, sender.messages, sent+1, "the code is sent with the challenge")
+
+		response, err = authService.CompleteTwoFactorLogin(ctx, response.TwoFactor.Token, sender.lastCode(t), "203.0.113.7")
+		require.NoError(t, err)
+		assert.NotEmpty(t, response.SessionID)
+	})
+}
+
+// totpNow computes the current code of a base32 TOTP secret, as an
+// authenticator app would
+func totpNow(t *testing.T, secret string) string {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	require.NoError(t, err)
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func TestAuthService_TOTPTwoFactor(t *testing.T) {
+	authService, _, _, _, _, db := setupTest(t)
+	user := createTestUser(t, db)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, authService.SetTwoFactorMethod(ctx, userID, TwoFactorTOTP), ErrTOTPNotEnabled)
+
+	enrollment, err := authService.EnrollTOTP(ctx, userID)
+	require.NoError(t, err)
+	assert.Contains(t, enrollment.URI, "GoSvelteKit:test@example.com")
+
+	// A pending enrollment doesn't protect logins yet
+	response, err := authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test-agent")
+	require.NoError(t, err)
+	assert.Nil(t, response.TwoFactor)
+	assert.NotEmpty(t, response.SessionID)
+
+	_, err = authService.ConfirmTOTP(ctx, userID, "000000x")
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+	codes, err := authService.ConfirmTOTP(ctx, userID, totpNow(t, enrollment.Secret))
+	require.NoError(t, err)
+	require.NotEmpty(t, codes)
+	_, err = authService.EnrollTOTP(ctx, userID)
+	assert.ErrorIs(t, err, ErrTOTPAlreadyEnabled)
+
+	var updated models.User
+	require.NoError(t, db.First(&updated, user.ID).Error)
+	assert.Equal(t, TwoFactorTOTP, updated.TwoFactorMethod)
+	assert.ErrorIs(t, authService.SetTwoFactorMethod(ctx, userID, TwoFactorNone), ErrTOTPEnabled)
+
+	// The password alone only opens a challenge
+	response, err = authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test-agent")
+	require.NoError(t, err)
+	require.NotNil(t, response.TwoFactor)
+	assert.Empty(t, response.SessionID)
+
+	_, err = authService.CompleteTwoFactorLogin(ctx, response.TwoFactor.Token, "not-a-code", "127.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+	_, err = authService.CompleteTwoFactorLogin(ctx, "unknown", codes[0], "127.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorChallenge)
+
+	completed, err := authService.CompleteTwoFactorLogin(ctx, response.TwoFactor.Token, codes[0], "127.0.0.1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, completed.SessionID)
+	assert.Equal(t, "testuser", completed.User.Identifier)
+
+	// The challenge is single-use
+	_, err = authService.CompleteTwoFactorLogin(ctx, response.TwoFactor.Token, codes[1], "127.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorChallenge)
+
+	assert.ErrorIs(t, authService.DisableTOTP(ctx, userID, "wrong-password"), ErrWrongPassword)
+	require.NoError(t, authService.DisableTOTP(ctx, userID, "password123"))
+	assert.ErrorIs(t, authService.DisableTOTP(ctx, userID, "password123"), ErrTOTPNotEnabled)
+	require.NoError(t, db.First(&updated, user.ID).Error)
+	assert.Equal(t, TwoFactorNone, updated.TwoFactorMethod)
+
+	response, err = authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test-agent")
+	require.NoError(t, err)
+	assert.Nil(t, response.TwoFactor)
+}
+
+func TestAuthService_Refresh(t *testing.T) {
+	_, _, userAdapter, sessionAdapter, mockEmail, db := setupTest(t)
+	createTestUser(t, db)
+	ctx := context.Background()
+
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.RefreshTokenDuration = 30 * 24 * time.Hour
+	authService := NewAuthService(auth.NewAuthManager(userAdapter, sessionAdapter, authConfig), userAdapter, mockEmail)
+
+	login, err := authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test-agent")
+	require.NoError(t, err)
+	require.NotEmpty(t, login.RefreshToken)
+
+	refreshed, err := authService.Refresh(ctx, login.RefreshToken, "127.0.0.1", "test-agent")
+	require.NoError(t, err)
+	assert.NotEqual(t, login.SessionID, refreshed.SessionID)
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+	assert.Equal(t, "testuser", refreshed.User.Identifier)
+	_, _, err = authService.ValidateSession(ctx, login.SessionID)
+	assert.Error(t, err)
+
+	// Replaying the first token ends the refreshed session too
+	_, err = authService.Refresh(ctx, login.RefreshToken, "127.0.0.1", "test-agent")
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	_, _, err = authService.ValidateSession(ctx, refreshed.SessionID)
+	assert.Error(t, err)
+	_, err = authService.Refresh(ctx, refreshed.RefreshToken, "127.0.0.1", "test-agent")
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestAuthService_UserSessions(t *testing.T) {
+	authService, _, _, _, _, db := setupTest(t)
+	user := createTestUser(t, db)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	ctx := context.Background()
+
+	current, err := authService.Login(ctx, "testuser", "password123", "127.0.0.1", "laptop")
+	require.NoError(t, err)
+	phone, err := authService.Login(ctx, "testuser", "password123", "127.0.0.2", "phone")
+	require.NoError(t, err)
+	_, err = authService.Login(ctx, "testuser", "password123", "127.0.0.3", "tablet")
+	require.NoError(t, err)
+
+	sessions, err := authService.ListSessions(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+
+	assert.ErrorIs(t, authService.RevokeSession(ctx, userID, "unknown"), ErrSessionNotFound)
+	require.NoError(t, authService.RevokeSession(ctx, userID, auth.SessionHandle(phone.SessionID)))
+	_, _, err = authService.ValidateSession(ctx, phone.SessionID)
+	assert.Error(t, err)
+
+	revocations, err := authService.RevokeOtherSessions(ctx, userID, current.SessionID)
+	require.NoError(t, err)
+	require.Len(t, revocations, 1, "the tablet; the phone was already revoked")
+	assert.NoError(t, revocations[0].Err)
+	sessions, err = authService.ListSessions(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, current.SessionID, sessions[0].ID)
+}
+
+func TestAuthManager_IssueSMSCode_Interval(t *testing.T) {
+	_, authManager, _, _, _, db := setupTest(t)
+	user := createTestUser(t, db)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	ctx := context.Background()
+
+	code, err := authManager.IssueSMSCode(ctx, userID, auth.SMSPurposeTwoFactor)
+	require.NoError(t, err)
+	assert.Len(t, code, auth.SMSCodeLength)
+
+	// The default config allows one code per minute
+	_, err = authManager.IssueSMSCode(ctx, userID, auth.SMSPurposePhoneVerification)
+	assert.ErrorIs(t, err, auth.ErrSMSCodeRateLimited)
+}
+
+func TestAuthService_UUIDStrategy(t *testing.T) {
+	require.NoError(t, models.SetIDStrategy(models.IDStrategyUUID))
+	defer models.SetIDStrategy(models.IDStrategyInteger)
+
+	authService, _, _, _, _, db := setupTest(t)
+	user := createTestUser(t, db)
+	require.NotNil(t, user.UUID)
+	ctx := context.Background()
+
+	response, err := authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test-agent")
+	require.NoError(t, err)
+	assert.Equal(t, *user.UUID, response.User.ID, "the UUID is the exposed ID")
+
+	_, userData, err := authService.ValidateSession(ctx, response.SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, *user.UUID, userData.ID)
+
+	// Adapters accept the UUID wherever a user ID is expected
+	codes, err := authService.RegenerateRecoveryCodes(ctx, userData.ID)
+	require.NoError(t, err)
+	assert.Len(t, codes, auth.RecoveryCodeCount)
+
+	require.NoError(t, authService.LogoutAll(ctx, userData.ID))
+	_, _, err = authService.ValidateSession(ctx, response.SessionID)
+	assert.Error(t, err)
+}
+
+func TestAuthService_FakeClock(t *testing.T) {
+	_, _, _, sessionAdapter, mockEmailService, db := setupTest(t)
+	clock := auth.NewFakeClock(time.Now())
+	userAdapter := gormadapter.NewUserAdapter(db, gormadapter.WithClock(clock))
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.Clock = clock
+	authManager := auth.NewAuthManager(userAdapter, sessionAdapter, authConfig)
+	sender := &mockSMSSender{}
+	authService := NewAuthService(authManager, userAdapter, mockEmailService, WithSMSSender(sender))
+
+	user := createTestUser(t, db)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	ctx := context.Background()
+
+	// Reset tokens expire after an hour without touching the database
+	require.NoError(t, authService.RequestPasswordReset(ctx, user.Email))
+	token := mockEmailService.GetSentEmails()[0].Token
+	clock.Advance(59 * time.Minute)
+	require.NoError(t, authService.ValidateResetToken(ctx, token))
+	clock.Advance(2 * time.Minute)
+	assert.ErrorIs(t, authService.ValidateResetToken(ctx, token), ErrExpiredToken)
+
+	// SMS codes expire after SMSCodeTTL
+	require.NoError(t, authService.SetPhoneNumber(ctx, userID, "+5511999999999"))
+	code := sender.lastCode(t)
+	clock.Advance(authConfig.SMSCodeTTL + time.Second)
+	assert.ErrorIs(t, authService.VerifyPhoneNumber(ctx, userID, code), ErrInvalidSMSCode)
+}
+
+func TestAuthService_ResetPassword_TooLong(t *testing.T) {
+	authService, _, _, _, mockEmailService, db := setupTest(t)
+	user := createTestUser(t, db)
+	ctx := context.Background()
+
+	require.NoError(t, authService.RequestPasswordReset(ctx, user.Email))
+	token := mockEmailService.GetSentEmails()[0].Token
+
+	long := strings.Repeat("Aa1!", 25) // 100 bytes
+	assert.ErrorIs(t, authService.ResetPassword(ctx, token, long), ErrPasswordTooLong)
+	require.NoError(t, authService.ValidateResetToken(ctx, token), "the token is not consumed")
+}
+
+func TestAuthService_ResetPassword_SessionRevocationFails(t *testing.T) {
+	authService, _, _, _, mockEmailService, db := setupTest(t)
+	user := createTestUser(t, db)
+	ctx := context.Background()
+
+	require.NoError(t, authService.RequestPasswordReset(ctx, user.Email))
+	token := mockEmailService.GetSentEmails()[0].Token
+
+	require.NoError(t, db.Migrator().DropTable(&models.Session{}))
+	assert.Error(t, authService.ResetPassword(ctx, token, "NewPassw0rd!"))
+
+	// The password is changed all the same
+	var updated models.User
+	require.NoError(t, db.First(&updated, user.ID).Error)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(updated.PasswordHash), []byte("NewPassw0rd!")))
+}
+
+func TestAuthService_ForcedPasswordChange(t *testing.T) {
+	authService, _, _, _, _, db := setupTest(t)
+	user := createTestUser(t, db)
+	ctx := context.Background()
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+
+	require.NoError(t, db.Model(user).Update("must_change_password", true).Error)
+
+	// Login still succeeds, flagging the user
+	response, err := authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.True(t, response.User.MustChangePassword())
+
+	assert.ErrorIs(t, authService.ChangePassword(ctx, userID, "", "wrong", "NewPassw0rd!"), ErrWrongPassword)
+	require.NoError(t, authService.ChangePassword(ctx, userID, "", "password123", "NewPassw0rd!"))
+
+	response, err = authService.Login(ctx, "testuser", "NewPassw0rd!", "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.False(t, response.User.MustChangePassword(), "changing the password clears the flag")
+}
+
+func TestAuthService_PasswordChangeSessionRevocation(t *testing.T) {
+	ctx := context.Background()
+	login := func(t *testing.T, s *AuthService, password string) string {
+		response, err := s.Login(ctx, "testuser", password, "127.0.0.1", "test")
+		require.NoError(t, err)
+		return response.SessionID
+	}
+	valid := func(s *AuthService, sessionID string) bool {
+		_, _, err := s.ValidateSession(ctx, sessionID)
+		return err == nil
+	}
+
+	t.Run("RevokeOn", func(t *testing.T) {
+		authService, _, _, _, mockEmailService, db := setupTest(t)
+		user := createTestUser(t, db)
+		userID := strconv.FormatUint(uint64(user.ID), 10)
+
+		// Change password: the session making the change survives
+		current := login(t, authService, "password123")
+		other := login(t, authService, "password123")
+		require.NoError(t, authService.ChangePassword(ctx, userID, current, "password123", "NewPassw0rd!"))
+		assert.True(t, valid(authService, current))
+		assert.False(t, valid(authService, other))
+
+		// Reset: every session is revoked
+		require.NoError(t, authService.RequestPasswordReset(ctx, user.Email))
+		require.NoError(t, authService.ResetPassword(ctx, mockEmailService.GetSentEmails()[0].Token, "ResetPassw0rd!"))
+		assert.False(t, valid(authService, current))
+	})
+
+	t.Run("RevokeOnWithoutKeepingCurrent", func(t *testing.T) {
+		_, authManager, userAdapter, _, mockEmailService, db := setupTest(t)
+		authService := NewAuthService(authManager, userAdapter, mockEmailService, WithPasswordChangeSessionRevocation(true, false))
+		user := createTestUser(t, db)
+		userID := strconv.FormatUint(uint64(user.ID), 10)
+
+		current := login(t, authService, "password123")
+		require.NoError(t, authService.ChangePassword(ctx, userID, current, "password123", "NewPassw0rd!"))
+		assert.False(t, valid(authService, current))
+	})
+
+	t.Run("RevokeOff", func(t *testing.T) {
+		_, authManager, userAdapter, _, mockEmailService, db := setupTest(t)
+		authService := NewAuthService(authManager, userAdapter, mockEmailService, WithPasswordChangeSessionRevocation(false, false))
+		user := createTestUser(t, db)
+		userID := strconv.FormatUint(uint64(user.ID), 10)
+
+		current := login(t, authService, "password123")
+		other := login(t, authService, "password123")
+		require.NoError(t, authService.ChangePassword(ctx, userID, current, "password123", "NewPassw0rd!"))
+		require.NoError(t, authService.RequestPasswordReset(ctx, user.Email))
+		require.NoError(t, authService.ResetPassword(ctx, mockEmailService.GetSentEmails()[0].Token, "ResetPassw0rd!"))
+		assert.True(t, valid(authService, current))
+		assert.True(t, valid(authService, other))
+	})
+}
+
+func TestAuthService_MultiTenancy(t *testing.T) {
+	tenant.SetEnabled(true)
+	defer tenant.SetEnabled(false)
+
+	authService, _, _, _, _, db := setupTest(t)
+	acme := tenant.WithTenant(context.Background(), "acme")
+	globex := tenant.WithTenant(context.Background(), "globex")
+
+	// The same username and email are allowed in different tenants
+	acmeUser, _, err := authService.Register(acme, "alice", "alice@example.com", "Passw0rd!", "Alice", "", "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", acmeUser.TenantID)
+	globexUser, _, err := authService.Register(globex, "alice", "alice@example.com", "0therPassw0rd!", "Alice", "", "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "globex", globexUser.TenantID)
+
+	// But not twice in the same tenant, neither by the service nor the database
+	_, _, err = authService.Register(acme, "alice2", "alice@example.com", "Passw0rd!", "Alice", "", "127.0.0.1")
+	assert.ErrorContains(t, err, "email already exists")
+	err = db.Create(&models.User{TenantID: "acme", Username: "alice3", Email: "alice@example.com", DisplayName: "Alice", PasswordHash: "hash"}).Error
+	assert.Error(t, err, "the composite unique index rejects duplicates within a tenant")
+
+	// Login is scoped to the tenant of the request
+	response, err := authService.Login(acme, "alice@example.com", "Passw0rd!", "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Equal(t, acmeUser.PublicID(), response.User.ID)
+	response, err = authService.Login(globex, "alice", "0therPassw0rd!", "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Equal(t, globexUser.PublicID(), response.User.ID)
+
+	_, err = authService.Login(globex, "alice", "Passw0rd!", "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "acme's password doesn't log in to globex")
+	_, err = authService.Login(tenant.WithTenant(context.Background(), "initech"), "alice", "Passw0rd!", "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestAuthService_SingleTenant(t *testing.T) {
+	authService, _, _, _, _, _ := setupTest(t)
+	// Without multi-tenancy a tenant in the context is ignored
+	ctx := tenant.WithTenant(context.Background(), "acme")
+
+	user, _, err := authService.Register(ctx, "alice", "alice@example.com", "Passw0rd!", "Alice", "", "127.0.0.1")
+	require.NoError(t, err)
+	assert.Empty(t, user.TenantID)
+
+	_, _, err = authService.Register(tenant.WithTenant(context.Background(), "globex"), "bob", "alice@example.com", "Passw0rd!", "Bob", "", "127.0.0.1")
+	assert.ErrorContains(t, err, "email already exists")
+}
+
+func TestAuthService_NewDeviceNotification(t *testing.T) {
+	authService, _, _, _, mockEmailService, db := setupTest(t)
+	WithNewDeviceNotification()(authService)
+	createTestUser(t, db)
+	ctx := context.Background()
+	login := func(ip, userAgent string) {
+		_, err := authService.Login(ctx, "testuser", "password123", ip, userAgent)
+		require.NoError(t, err)
+	}
+	newDeviceEmails := func() []email.MockEmail {
+		var sent []email.MockEmail
+		for _, e := range mockEmailService.GetSentEmails() {
+			if e.Kind == "new_device" {
+				sent = append(sent, e)
+			}
+		}
+		return sent
+	}
+
+	// First login: nothing to compare with
+	login("203.0.113.10", "firefox")
+	// Known device, same subnet
+	login("203.0.113.20", "firefox")
+	// New device
+	login("198.51.100.7", "chrome")
+
+	require.Eventually(t, func() bool {
+		return len(newDeviceEmails()) == 1
+	}, time.Second, 10*time.Millisecond)
+	sent := newDeviceEmails()[0]
+	assert.Equal(t, "test@example.com", sent.To)
+	assert.Equal(t, "chrome", sent.UserAgent)
+	assert.Equal(t, "198.51.100.7", sent.IP)
+
+	// Now known as well
+	login("198.51.100.8", "chrome")
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, newDeviceEmails(), 1)
+}
+
+func TestAuthService_LockoutNotification(t *testing.T) {
+	authService, authManager, _, _, mockEmailService, db := setupTest(t)
+	WithLockoutNotification()(authService)
+	createTestUser(t, db)
+	ctx := context.Background()
+	lockedEmails := func() []email.MockEmail {
+		var sent []email.MockEmail
+		for _, e := range mockEmailService.GetSentEmails() {
+			if e.Kind == "account_locked" {
+				sent = append(sent, e)
+			}
+		}
+		return sent
+	}
+
+	// Crossing the threshold, then trying again while locked
+	for range auth.DefaultAuthConfig().MaxFailedAttempts + 3 {
+		_, err := authService.Login(ctx, "testuser", "wrong", "127.0.0.1", "test-agent")
+		require.Error(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		return len(lockedEmails()) == 1
+	}, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	sent := lockedEmails()
+	require.Len(t, sent, 1, "one notification per lockout")
+	assert.Equal(t, "test@example.com", sent[0].To)
+	assert.Equal(t, authManager.LockedUntil(ctx, "testuser"), sent[0].LockedUntil)
+
+	// Lockouts of unknown accounts send nothing
+	for range auth.DefaultAuthConfig().MaxFailedAttempts {
+		_, _ = authService.Login(ctx, "nobody", "wrong", "127.0.0.2", "test-agent")
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, lockedEmails(), 1)
+}
+
+func TestAuthService_Register_UsernamePolicy(t *testing.T) {
+	authService, _, _, _, _, _ := setupTest(t)
+	ctx := context.Background()
+
+	_, _, err := authService.Register(ctx, "Admin", "admin@example.com", "Password123!", "Admin", "", "127.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidUsername)
+	assert.ErrorIs(t, err, auth.ErrUsernameReserved)
+
+	_, _, err = authService.Register(ctx, "alice:smith", "alice@example.com", "Password123!", "Alice", "", "127.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidUsername)
+	assert.ErrorIs(t, err, auth.ErrUsernameCharacters)
+	assert.Contains(t, err.Error(), "caracteres não permitidos", "the error names the rule")
+
+	_, _, err = authService.Register(ctx, "alice", "alice@example.com", "Password123!", "Alice", "", "127.0.0.1")
+	assert.NoError(t, err)
+}
+
+func TestAuthService_Register_RuntimeSetting(t *testing.T) {
+	authService, _, _, _, _, db := setupTest(t)
+	require.NoError(t, db.AutoMigrate(&models.Setting{}))
+	ctx := context.Background()
+	defaults := settings.Config{Defaults: map[string]bool{settings.KeyRegistrationEnabled: true}}
+	store := settings.NewStore(db, defaults)
+	require.NoError(t, store.Refresh(ctx))
+	WithSettings(store)(authService)
+
+	_, _, err := authService.Register(ctx, "alice", "alice@example.com", "Password123!", "Alice", "", "127.0.0.1")
+	require.NoError(t, err)
+
+	// An admin on another instance closes registration
+	_, err = settings.NewStore(db, defaults).Set(ctx, settings.KeyRegistrationEnabled, false, "1")
+	require.NoError(t, err)
+
+	// Still open until this instance refreshes its cache
+	_, _, err = authService.Register(ctx, "bob", "bob@example.com", "Password123!", "Bob", "", "127.0.0.1")
+	require.NoError(t, err)
+
+	require.NoError(t, store.Refresh(ctx))
+	_, _, err = authService.Register(ctx, "carol", "carol@example.com", "Password123!", "Carol", "", "127.0.0.1")
+	assert.ErrorIs(t, err, ErrRegistrationClosed)
+}
+
+func TestAuthService_PasswordPolicy(t *testing.T) {
+	_, _, _, sessionAdapter, mockEmailService, db := setupTest(t)
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.PasswordHistoryDepth = 3
+
+	bcryptAdapter := gormadapter.NewUserAdapter(db)
+	authService := NewAuthService(auth.NewAuthManager(bcryptAdapter, sessionAdapter, authConfig), bcryptAdapter, mockEmailService)
+	policy := authService.PasswordPolicy()
+	assert.Equal(t, validation.PasswordMinLength, policy.MinLength)
+	assert.Equal(t, auth.BcryptMaxPasswordBytes, policy.MaxLength)
+	assert.Equal(t, 3, policy.HistoryDepth)
+	assert.Equal(t, []string{validation.ClassUppercase, validation.ClassLowercase, validation.ClassNumber, validation.ClassSpecial}, policy.RequiredClasses)
+	assert.True(t, policy.RejectCommon)
+	assert.True(t, policy.RejectUsername)
+
+	// Without bcrypt's 72-byte limit there is no maximum
+	argonAdapter := gormadapter.NewUserAdapter(db, gormadapter.WithPasswordHasher(auth.NewArgon2idHasher(auth.DefaultArgon2Params())))
+	authService = NewAuthService(auth.NewAuthManager(argonAdapter, sessionAdapter, auth.DefaultAuthConfig()), argonAdapter, mockEmailService)
+	policy = authService.PasswordPolicy()
+	assert.Equal(t, 0, policy.MaxLength)
+	assert.Equal(t, 0, policy.HistoryDepth)
+}
+
+func TestAuthService_DeleteAccount(t *testing.T) {
+	_, _, _, sessionAdapter, mockEmailService, db := setupTest(t)
+	clock := auth.NewFakeClock(time.Now())
+	userAdapter := gormadapter.NewUserAdapter(db, gormadapter.WithClock(clock))
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.Clock = clock
+	authManager := auth.NewAuthManager(userAdapter, sessionAdapter, authConfig)
+	authService := NewAuthService(authManager, userAdapter, mockEmailService, WithAccountDeletionGracePeriod(7*24*time.Hour))
+
+	user := createTestUser(t, db)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	ctx := context.Background()
+
+	login, err := authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test")
+	require.NoError(t, err)
+
+	_, err = authService.DeleteAccount(ctx, userID, "wrong")
+	assert.ErrorIs(t, err, ErrWrongPassword)
+
+	deleteAt, err := authService.DeleteAccount(ctx, userID, "password123")
+	require.NoError(t, err)
+	assert.WithinDuration(t, clock.Now().Add(7*24*time.Hour), deleteAt, time.Second)
+
+	// Sessions are revoked and the account can't log in anymore
+	_, _, err = authManager.ValidateSession(ctx, login.SessionID)
+	assert.Error(t, err)
+	_, err = authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrAccountPendingDeletion)
+
+	emails := mockEmailService.GetSentEmails()
+	require.Len(t, emails, 1)
+	assert.Equal(t, "account_deletion", emails[0].Kind)
+	assert.Equal(t, deleteAt, emails[0].DeleteAt)
+	token := emails[0].Token
+
+	// Deleting again keeps the original date
+	clock.Advance(time.Hour)
+	again, err := authService.DeleteAccount(ctx, userID, "password123")
+	require.NoError(t, err)
+	assert.True(t, deleteAt.Equal(again))
+
+	t.Run("RestoreWithToken", func(t *testing.T) {
+		assert.ErrorIs(t, authService.RestoreAccount(ctx, "bogus"), ErrInvalidToken)
+		require.NoError(t, authService.RestoreAccount(ctx, token))
+		assert.ErrorIs(t, authService.RestoreAccount(ctx, token), ErrInvalidToken, "the token is single use")
+
+		_, err := authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test")
+		require.NoError(t, err)
+	})
+
+	t.Run("WindowOver", func(t *testing.T) {
+		mockEmailService.ClearSentEmails()
+		_, err := authService.DeleteAccount(ctx, userID, "password123")
+		require.NoError(t, err)
+		token := mockEmailService.GetSentEmails()[0].Token
+
+		clock.Advance(7*24*time.Hour + time.Second)
+		assert.ErrorIs(t, authService.RestoreAccount(ctx, token), ErrExpiredToken)
+	})
+}
+
+func TestAuthService_SignedResetTokens(t *testing.T) {
+	_, _, _, sessionAdapter, mockEmailService, db := setupTest(t)
+	clock := auth.NewFakeClock(time.Now())
+	userAdapter := gormadapter.NewUserAdapter(db, gormadapter.WithClock(clock))
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.Clock = clock
+	authManager := auth.NewAuthManager(userAdapter, sessionAdapter, authConfig)
+	secret := []byte(strings.Repeat("s", 32))
+	authService := NewAuthService(authManager, userAdapter, mockEmailService, WithSignedResetTokens(secret))
+
+	user := createTestUser(t, db)
+	ctx := context.Background()
+
+	requestToken := func(t *testing.T) string {
+		mockEmailService.ClearSentEmails()
+		require.NoError(t, authService.RequestPasswordReset(ctx, user.Email))
+		emails := mockEmailService.GetSentEmails()
+		require.Len(t, emails, 1)
+		return emails[0].Token
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		token := requestToken(t)
+		require.NoError(t, db.First(user, user.ID).Error)
+		assert.Empty(t, user.ResetToken, "nothing is stored")
+
+		require.NoError(t, authService.ValidateResetToken(ctx, token))
+		require.NoError(t, authService.ResetPassword(ctx, token, "NewPassw0rd!"))
+		_, err := authService.Login(ctx, "testuser", "NewPassw0rd!", "127.0.0.1", "test")
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, authService.ResetPassword(ctx, token, "OtherPassw0rd!"), ErrInvalidToken, "the link is single use")
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		token := requestToken(t)
+		assert.ErrorIs(t, authService.ValidateResetToken(ctx, "x"+token), ErrInvalidToken)
+
+		other := NewAuthService(authManager, userAdapter, mockEmailService, WithSignedResetTokens([]byte(strings.Repeat("o", 32))))
+		assert.ErrorIs(t, other.ValidateResetToken(ctx, token), ErrInvalidToken, "signed with another secret")
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		token := requestToken(t)
+		clock.Advance(time.Hour + time.Second)
+		assert.ErrorIs(t, authService.ValidateResetToken(ctx, token), ErrExpiredToken)
+		assert.ErrorIs(t, authService.ResetPassword(ctx, token, "NewerPassw0rd!"), ErrExpiredToken)
+	})
+
+	t.Run("Replayed", func(t *testing.T) {
+		// Both requests pass the version check before either resets
+		token := requestToken(t)
+		first, err := authService.findResetTokenUser(ctx, token)
+		require.NoError(t, err)
+		second, err := authService.findResetTokenUser(ctx, token)
+		require.NoError(t, err)
+
+		require.NoError(t, authService.resetPassword(ctx, first, token, "ReplayedPassw0rd!"))
+		assert.ErrorIs(t, authService.resetPassword(ctx, second, token, "AttackerPassw0rd!"), ErrInvalidToken)
+		_, err = authService.Login(ctx, "testuser", "ReplayedPassw0rd!", "127.0.0.1", "test")
+		require.NoError(t, err, "the first reset stands")
+	})
+
+	t.Run("InvalidatedByPasswordChange", func(t *testing.T) {
+		token := requestToken(t)
+		userID := strconv.FormatUint(uint64(user.ID), 10)
+		require.NoError(t, authService.ChangePassword(ctx, userID, "", "ReplayedPassw0rd!", "ChangedPassw0rd!"))
+		assert.ErrorIs(t, authService.ValidateResetToken(ctx, token), ErrInvalidToken)
+	})
+}
+
+func TestAuthService_EmailVerification(t *testing.T) {
+	_, _, _, sessionAdapter, mockEmailService, db := setupTest(t)
+	queue := setupJobs(t, db, mockEmailService)
+	clock := auth.NewFakeClock(time.Now())
+	userAdapter := gormadapter.NewUserAdapter(db, gormadapter.WithClock(clock))
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.Clock = clock
+	authConfig.RequireVerifiedEmail = true
+	authManager := auth.NewAuthManager(userAdapter, sessionAdapter, authConfig)
+	secret := []byte(strings.Repeat("v", 32))
+	authService := NewAuthService(authManager, userAdapter, mockEmailService, WithEmailVerification(secret, time.Hour))
+	ctx := context.Background()
+
+	// sentToken returns the token of the verification email just sent
+	sentToken := func(t *testing.T) string {
+		t.Helper()
+		require.Len(t, mockEmailService.GetSentEmails(), 1)
+		sent := mockEmailService.GetSentEmails()[0]
+		mockEmailService.ClearSentEmails()
+		assert.Equal(t, "email_verification", sent.Kind)
+		return sent.Token
+	}
+
+	user, sent, err := authService.Register(ctx, "alice", "alice@example.com", "Password123!", "Alice", "", "127.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, sent)
+	token := sentToken(t)
+
+	t.Run("LoginDeniedUntilVerified", func(t *testing.T) {
+		_, err := authService.Login(ctx, "alice", "Password123!", "127.0.0.1", "test")
+		assert.ErrorIs(t, err, ErrEmailNotVerified)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		assert.ErrorIs(t, authService.VerifyEmail(ctx, "x"+token), ErrInvalidToken)
+
+		other := NewAuthService(authManager, userAdapter, mockEmailService, WithEmailVerification([]byte(strings.Repeat("o", 32)), time.Hour))
+		assert.ErrorIs(t, other.VerifyEmail(ctx, token), ErrInvalidToken, "signed with another secret")
+
+		disabled := NewAuthService(authManager, userAdapter, mockEmailService)
+		assert.ErrorIs(t, disabled.VerifyEmail(ctx, token), ErrEmailVerificationDisabled)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		require.NoError(t, authService.ResendVerificationEmail(ctx, "alice@example.com"))
+		fresh := sentToken(t)
+		clock.Advance(time.Hour + time.Second)
+		assert.ErrorIs(t, authService.VerifyEmail(ctx, fresh), ErrExpiredToken)
+	})
+
+	t.Run("InvalidatedByEmailChange", func(t *testing.T) {
+		require.NoError(t, authService.ResendVerificationEmail(ctx, "alice@example.com"))
+		fresh := sentToken(t)
+		require.NoError(t, db.Model(&models.User{}).Where("id = ?", user.ID).Update("email", "alice@example.org").Error)
+		assert.ErrorIs(t, authService.VerifyEmail(ctx, fresh), ErrInvalidToken)
+		require.NoError(t, db.Model(&models.User{}).Where("id = ?", user.ID).Update("email", "alice@example.com").Error)
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		require.NoError(t, authService.ResendVerificationEmail(ctx, "alice@example.com"))
+		fresh := sentToken(t)
+		require.NoError(t, authService.VerifyEmail(ctx, fresh))
+
+		var stored models.User
+		require.NoError(t, db.First(&stored, user.ID).Error)
+		assert.True(t, stored.EmailVerified)
+		require.NotNil(t, stored.EmailVerifiedAt)
+		assert.WithinDuration(t, clock.Now(), *stored.EmailVerifiedAt, time.Second)
+
+		require.NoError(t, authService.VerifyEmail(ctx, fresh), "verifying again succeeds")
+		_, err := authService.Login(ctx, "alice", "Password123!", "127.0.0.1", "test")
+		require.NoError(t, err)
+	})
+
+	t.Run("ResendIgnoresUnknownAndVerified", func(t *testing.T) {
+		require.NoError(t, authService.ResendVerificationEmail(ctx, "nobody@example.com"))
+		require.NoError(t, authService.ResendVerificationEmail(ctx, "alice@example.com"))
+		assert.Empty(t, mockEmailService.GetSentEmails())
+	})
+
+	t.Run("Jobs", func(t *testing.T) {
+		queued := NewAuthService(authManager, userAdapter, mockEmailService, WithJobs(queue), WithEmailVerification(secret, time.Hour))
+		_, _, err := queued.Register(ctx, "bob", "bob@example.com", "Password123!", "", "", "127.0.0.1")
+		require.NoError(t, err)
+
+		var verifications []models.Job
+		require.NoError(t, db.Where("type = ?", outbox.JobType(outbox.KindEmailVerification)).Find(&verifications).Error)
+		require.Len(t, verifications, 1)
+		assert.Contains(t, verifications[0].Payload, "bob@example.com")
+
+		_, err = queue.RunDue(ctx)
+		require.NoError(t, err)
+		require.NoError(t, queued.VerifyEmail(ctx, sentToken(t)))
+	})
+
+	t.Run("SendFailureKeepsTheAccountByDefault", func(t *testing.T) {
+		mockEmailService.SetSendEmailError(errors.New("smtp down"))
+		defer mockEmailService.SetSendEmailError(nil)
+		defer mockEmailService.ClearSentEmails()
+
+		user, sent, err := authService.Register(ctx, "carol", "carol@example.com", "Password123!", "", "", "127.0.0.1")
+		require.NoError(t, err)
+		assert.False(t, sent)
+		assert.False(t, user.EmailVerified)
+		assert.Len(t, mockEmailService.GetSentEmails(), 1, "the send was attempted")
+	})
+
+	t.Run("SendFailureUndoesAStrictRegistration", func(t *testing.T) {
+		strict := NewAuthService(authManager, userAdapter, mockEmailService, WithJobs(queue), WithEmailVerification(secret, time.Hour), WithStrictRegistrationEmail())
+		mockEmailService.SetSendEmailError(errors.New("smtp down"))
+
+		_, _, err := strict.Register(ctx, "dave", "dave@example.com", "Password123!", "", "", "127.0.0.1")
+		assert.ErrorIs(t, err, ErrRegistrationEmail)
+		var count int64
+		require.NoError(t, db.Unscoped().Model(&models.User{}).Where("username = ?", "dave").Count(&count).Error)
+		assert.Zero(t, count)
+
+		// Sent during the request, even with jobs
+		mockEmailService.SetSendEmailError(nil)
+		mockEmailService.ClearSentEmails()
+		_, sent, err := strict.Register(ctx, "dave", "dave@example.com", "Password123!", "", "", "127.0.0.1")
+		require.NoError(t, err)
+		assert.True(t, sent)
+		require.NoError(t, strict.VerifyEmail(ctx, sentToken(t)))
+	})
+
+	t.Run("QueuedInTheUserTransaction", func(t *testing.T) {
+		queued := NewAuthService(authManager, userAdapter, mockEmailService, WithJobs(queue), WithEmailVerification(secret, time.Hour))
+		require.NoError(t, db.Migrator().DropTable(&models.Job{}))
+
+		_, _, err := queued.Register(ctx, "erin", "erin@example.com", "Password123!", "", "", "127.0.0.1")
+		require.Error(t, err)
+		var count int64
+		require.NoError(t, db.Unscoped().Model(&models.User{}).Where("username = ?", "erin").Count(&count).Error)
+		assert.Zero(t, count, "no account without its verification email")
+	})
+}
+
+type recordingPublisher struct {
+	events []string
+	data   []map[string]any
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event string, data map[string]any) {
+	p.events = append(p.events, event)
+	p.data = append(p.data, data)
+}
+
+func TestAuthService_Webhooks(t *testing.T) {
+	_, authManager, userAdapter, _, mockEmailService, _ := setupTest(t)
+	publisher := &recordingPublisher{}
+	authService := NewAuthService(authManager, userAdapter, mockEmailService, WithWebhooks(publisher))
+	ctx := context.Background()
+
+	user, _, err := authService.Register(ctx, "hooked", "hooked@example.com", "Passw0rd!", "Hooked", "", "127.0.0.1")
+	require.NoError(t, err)
+	_, err = authService.Login(ctx, "hooked", "wrong", "127.0.0.1", "test")
+	require.Error(t, err)
+	login, err := authService.Login(ctx, "hooked", "Passw0rd!", "127.0.0.1", "test")
+	require.NoError(t, err)
+	require.NoError(t, authService.Logout(ctx, login.SessionID))
+
+	assert.Equal(t, []string{webhooks.EventUserRegistered, webhooks.EventLoginFailed, webhooks.EventSessionRevoked}, publisher.events)
+	assert.Equal(t, user.PublicID(), publisher.data[0]["user_id"])
+	assert.Equal(t, metrics.ReasonInvalidCredentials, publisher.data[1]["reason"])
+	assert.Equal(t, map[string]any{"user_id": user.PublicID(), "reason": RevokedByLogout}, publisher.data[2])
+}
+
+type recordingRealtime struct {
+	events  []string
+	users   []string
+	checked []string
+}
+
+func (p *recordingRealtime) Publish(ctx context.Context, userID string, event realtime.Event) {
+	p.events = append(p.events, event.Type)
+	p.users = append(p.users, userID)
+}
+
+func (p *recordingRealtime) CheckSessions(ctx context.Context, userID string) {
+	p.checked = append(p.checked, userID)
+}
+
+func TestAuthService_Realtime(t *testing.T) {
+	_, authManager, userAdapter, _, mockEmailService, _ := setupTest(t)
+	publisher := &recordingRealtime{}
+	authService := NewAuthService(authManager, userAdapter, mockEmailService, WithRealtime(publisher))
+	ctx := context.Background()
+
+	user, _, err := authService.Register(ctx, "live", "live@example.com", "Passw0rd!", "Live", "", "127.0.0.1")
+	require.NoError(t, err)
+	login, err := authService.Login(ctx, "live", "Passw0rd!", "127.0.0.1", "test")
+	require.NoError(t, err)
+	require.NoError(t, authService.Logout(ctx, login.SessionID))
+	require.NoError(t, authService.ChangePassword(ctx, user.PublicID(), "", "Passw0rd!", "NewPassw0rd!"))
+
+	assert.Equal(t, []string{realtime.EventSessionRevoked, realtime.EventPasswordChanged}, publisher.events)
+	assert.Equal(t, []string{user.PublicID(), user.PublicID()}, publisher.users)
+	// Both ended sessions, so connections are checked each time
+	assert.Equal(t, []string{user.PublicID(), user.PublicID()}, publisher.checked)
+}
+
+type recordingRecorder struct {
+	events []audit.Event
+}
+
+func (r *recordingRecorder) Record(ctx context.Context, event audit.Event) {
+	r.events = append(r.events, event)
+}
+
+func TestAuthService_Audit(t *testing.T) {
+	_, authManager, userAdapter, _, mockEmailService, db := setupTest(t)
+	recorder := &recordingRecorder{}
+	authService := NewAuthService(authManager, userAdapter, mockEmailService, WithAudit(recorder))
+	ctx := context.Background()
+	user := createTestUser(t, db)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+
+	_, err := authService.Login(ctx, "testuser", "wrong", "203.0.113.7", "test")
+	require.Error(t, err)
+	login, err := authService.Login(ctx, "testuser", "password123", "203.0.113.7", "test")
+	require.NoError(t, err)
+	require.NoError(t, authService.ChangePassword(ctx, userID, login.SessionID, "password123", "N3w-Passw0rd!"))
+	require.NoError(t, authService.LogoutAll(ctx, userID))
+
+	types := make([]string, len(recorder.events))
+	for i, event := range recorder.events {
+		types[i] = event.Type
+	}
+	assert.Equal(t, []string{audit.EventLoginFailed, audit.EventLoginSucceeded, audit.EventPasswordChanged, audit.EventSessionRevoked}, types)
+
+	failed := recorder.events[0]
+	assert.Empty(t, failed.ActorID)
+	assert.Equal(t, "203.0.113.7", failed.IP)
+	assert.Equal(t, map[string]any{"identifier": "testuser", "reason": metrics.ReasonInvalidCredentials}, failed.Data)
+	assert.Equal(t, userID, recorder.events[1].ActorID)
+	assert.Equal(t, userID, recorder.events[2].TargetID)
+	assert.Equal(t, RevokedByLogoutAll, recorder.events[3].Data["reason"])
+	for _, event := range recorder.events {
+		assert.NotContains(t, fmt.Sprint(event.Data), "password123", "credentials are never recorded")
+	}
+}
+
+func TestAuthService_OAuthLogin(t *testing.T) {
+	authService, _, _, _, _, db := setupTest(t)
+	ctx := context.Background()
+	identity := &oauth.Identity{Provider: "github", Subject: "42", Email: "Jane.Doe+gh@example.com", EmailVerified: true, Name: "Jane Doe"}
+
+	// First login creates a verified account
+	response, err := authService.OAuthLogin(ctx, identity, "127.0.0.1", "test-agent")
+	require.NoError(t, err)
+	assert.NotEmpty(t, response.SessionID)
+	assert.Equal(t, "jane.doegh", response.User.Identifier)
+	assert.Equal(t, "Jane Doe", response.User.DisplayName)
+	var created models.User
+	require.NoError(t, db.Where("email = ?", identity.Email).First(&created).Error)
+	assert.True(t, created.EmailVerified)
+
+	// Later logins find it by subject, even after the email changes
+	changed := *identity
+	changed.Email = "jane@elsewhere.example.com"
+	response, err = authService.OAuthLogin(ctx, &changed, "127.0.0.1", "test-agent")
+	require.NoError(t, err)
+	assert.Equal(t, created.PublicID(), response.User.ID)
+
+	var count int64
+	require.NoError(t, db.Model(&models.User{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestAuthService_OAuthLogin_LinksByEmail(t *testing.T) {
+	authService, _, _, _, _, db := setupTest(t)
+	ctx := context.Background()
+	user := createTestUser(t, db)
+	identity := &oauth.Identity{Provider: "google", Subject: "g-1", Email: user.Email, EmailVerified: true}
+
+	// An unverified local email could belong to someone who never owned it
+	_, err := authService.OAuthLogin(ctx, identity, "127.0.0.1", "test-agent")
+	assert.ErrorIs(t, err, ErrOAuthAccountConflict)
+
+	require.NoError(t, db.Model(user).Update("email_verified", true).Error)
+	response, err := authService.OAuthLogin(ctx, identity, "127.0.0.1", "test-agent")
+	require.NoError(t, err)
+	assert.Equal(t, user.PublicID(), response.User.ID)
+
+	var linked models.ExternalIdentity
+	require.NoError(t, db.Where("provider = ? AND subject = ?", "google", "g-1").First(&linked).Error)
+	assert.Equal(t, user.ID, linked.UserID)
+}
+
+func TestAuthService_OAuthLogin_Rejected(t *testing.T) {
+	authService, _, _, _, _, db := setupTest(t)
+	ctx := context.Background()
+
+	_, err := authService.OAuthLogin(ctx, &oauth.Identity{Provider: "github", Subject: "1", Email: "a@example.com"}, "127.0.0.1", "test-agent")
+	assert.ErrorIs(t, err, ErrOAuthEmailNotVerified)
+	_, err = authService.OAuthLogin(ctx, &oauth.Identity{Provider: "github", Subject: "1", EmailVerified: true}, "127.0.0.1", "test-agent")
+	assert.ErrorIs(t, err, ErrOAuthEmailNotVerified)
+
+	// Reserved and taken usernames get a suffix
+	createTestUser(t, db)
+	response, err := authService.OAuthLogin(ctx, &oauth.Identity{Provider: "github", Subject: "2", Email: "testuser@other.example.com", EmailVerified: true}, "127.0.0.1", "test-agent")
+	require.NoError(t, err)
+	assert.Regexp(t, `^testuser-\d{4}$`, response.User.Identifier)
+	response, err = authService.OAuthLogin(ctx, &oauth.Identity{Provider: "github", Subject: "3", Email: "admin@other.example.com", EmailVerified: true}, "127.0.0.1", "test-agent")
+	require.NoError(t, err)
+	assert.Regexp(t, `^admin-\d{4}$`, response.User.Identifier)
+
+	// Deactivated accounts stay locked out
+	require.NoError(t, db.Model(&models.User{}).Where("username = ?", response.User.Identifier).Update("active", false).Error)
+	_, err = authService.OAuthLogin(ctx, &oauth.Identity{Provider: "github", Subject: "3"}, "127.0.0.1", "test-agent")
+	assert.ErrorIs(t, err, ErrUserNotActive)
+
+	// Closed registration only stops new accounts
+	require.NoError(t, db.AutoMigrate(&models.Setting{}))
+	store := settings.NewStore(db, settings.Config{Defaults: map[string]bool{settings.KeyRegistrationEnabled: false}})
+	require.NoError(t, store.Refresh(ctx))
+	WithSettings(store)(authService)
+	_, err = authService.OAuthLogin(ctx, &oauth.Identity{Provider: "github", Subject: "4", Email: "new@example.com", EmailVerified: true}, "127.0.0.1", "test-agent")
+	assert.ErrorIs(t, err, ErrRegistrationClosed)
+	_, err = authService.OAuthLogin(ctx, &oauth.Identity{Provider: "github", Subject: "2"}, "127.0.0.1", "test-agent")
+	assert.NoError(t, err)
+}
+
+func TestAuthService_MagicLink(t *testing.T) {
+	_, _, _, sessionAdapter, mockEmailService, db := setupTest(t)
+	clock := auth.NewFakeClock(time.Now())
+	userAdapter := gormadapter.NewUserAdapter(db, gormadapter.WithClock(clock))
+	authConfig := auth.DefaultAuthConfig()
+	authConfig.Clock = clock
+	authManager := auth.NewAuthManager(userAdapter, sessionAdapter, authConfig)
+	authService := NewAuthService(authManager, userAdapter, mockEmailService, WithMagicLinks())
+	ctx := context.Background()
+	user := createTestUser(t, db)
+
+	// sentToken returns the token of the only link sent since the last call
+	sentToken := func(t *testing.T) string {
+		t.Helper()
+		sent := mockEmailService.GetSentEmails()
+		require.Len(t, sent, 1)
+		mockEmailService.ClearSentEmails()
+		assert.Equal(t, "magic_link", sent[0].Kind)
+		assert.Equal(t, user.Email, sent[0].To)
+		return sent[0].Token
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		disabled := NewAuthService(authManager, userAdapter, mockEmailService)
+		assert.ErrorIs(t, disabled.RequestMagicLink(ctx, user.Email, "127.0.0.1"), ErrMagicLinkDisabled)
+		_, err := disabled.LoginWithMagicLink(ctx, "token", "127.0.0.1", "test")
+		assert.ErrorIs(t, err, ErrMagicLinkDisabled)
+	})
+
+	t.Run("UnknownEmail", func(t *testing.T) {
+		require.NoError(t, authService.RequestMagicLink(ctx, "nobody@example.com", "127.0.0.1"))
+		assert.Empty(t, mockEmailService.GetSentEmails())
+	})
+
+	t.Run("Login", func(t *testing.T) {
+		require.NoError(t, authService.RequestMagicLink(ctx, user.Email, "127.0.0.1"))
+		token := sentToken(t)
+
+		response, err := authService.LoginWithMagicLink(ctx, token, "127.0.0.1", "test")
+		require.NoError(t, err)
+		assert.NotEmpty(t, response.SessionID)
+		assert.Equal(t, user.PublicID(), response.User.ID)
+
+		var stored models.User
+		require.NoError(t, db.First(&stored, user.ID).Error)
+		assert.True(t, stored.EmailVerified, "following the link proves the address")
+
+		_, err = authService.LoginWithMagicLink(ctx, token, "127.0.0.1", "test")
+		assert.ErrorIs(t, err, ErrInvalidMagicLink, "links are single use")
+	})
+
+	t.Run("RateLimited", func(t *testing.T) {
+		require.NoError(t, authService.RequestMagicLink(ctx, user.Email, "127.0.0.1"))
+		assert.Empty(t, mockEmailService.GetSentEmails(), "sent again before the interval")
+
+		clock.Advance(authConfig.MagicLinkInterval)
+		require.NoError(t, authService.RequestMagicLink(ctx, user.Email, "127.0.0.1"))
+		sentToken(t)
+	})
+
+	t.Run("ReplacedAndExpired", func(t *testing.T) {
+		clock.Advance(authConfig.MagicLinkInterval)
+		require.NoError(t, authService.RequestMagicLink(ctx, user.Email, "127.0.0.1"))
+		older := sentToken(t)
+		clock.Advance(authConfig.MagicLinkInterval)
+		require.NoError(t, authService.RequestMagicLink(ctx, user.Email, "127.0.0.1"))
+		newer := sentToken(t)
+
+		_, err := authService.LoginWithMagicLink(ctx, older, "127.0.0.1", "test")
+		assert.ErrorIs(t, err, ErrInvalidMagicLink)
+
+		clock.Advance(authConfig.MagicLinkTTL + time.Second)
+		_, err = authService.LoginWithMagicLink(ctx, newer, "127.0.0.1", "test")
+		assert.ErrorIs(t, err, ErrInvalidMagicLink)
+	})
+
+	t.Run("InvalidatedByEmailChange", func(t *testing.T) {
+		clock.Advance(time.Hour)
+		require.NoError(t, authService.RequestMagicLink(ctx, user.Email, "127.0.0.1"))
+		token := sentToken(t)
+		require.NoError(t, db.Model(&models.User{}).Where("id = ?", user.ID).Update("email", "moved@example.com").Error)
+		_, err := authService.LoginWithMagicLink(ctx, token, "127.0.0.1", "test")
+		assert.ErrorIs(t, err, ErrInvalidMagicLink)
+		require.NoError(t, db.Model(&models.User{}).Where("id = ?", user.ID).Update("email", user.Email).Error)
+	})
+
+	t.Run("Inactive", func(t *testing.T) {
+		clock.Advance(time.Hour)
+		require.NoError(t, db.Model(&models.User{}).Where("id = ?", user.ID).Update("active", false).Error)
+		require.NoError(t, authService.RequestMagicLink(ctx, user.Email, "127.0.0.1"))
+		assert.Empty(t, mockEmailService.GetSentEmails())
+	})
+}
+
+func TestAuthService_Locale(t *testing.T) {
+	authService, _, _, _, mockEmailService, db := setupTest(t)
+	queue := setupJobs(t, db, mockEmailService)
+	ctx := context.Background()
+
+	// Register keeps the language of the request
+	user, _, err := authService.Register(email.WithLocale(ctx, "en"), "alice", "alice@example.com", "Password123!", "Alice", "", "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "en", user.Locale)
+
+	// Later emails use it, whatever the language of the request
+	require.NoError(t, authService.RequestPasswordReset(email.WithLocale(ctx, "pt-BR"), "alice@example.com"))
+	sent := mockEmailService.GetSentEmails()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "en", sent[0].Locale)
+
+	// ...and queued ones keep it too
+	queuedService := NewAuthService(authService.authManager, authService.userAdapter, mockEmailService, WithJobs(queue))
+	require.NoError(t, queuedService.RequestPasswordReset(ctx, "alice@example.com"))
+	var job models.Job
+	require.NoError(t, db.First(&job).Error)
+	assert.Contains(t, job.Payload, `"locale":"en"`)
+
+	// Clearing it goes back to the default
+	require.NoError(t, authService.SetLocale(ctx, user.PublicID(), ""))
+	mockEmailService.ClearSentEmails()
+	require.NoError(t, authService.RequestPasswordReset(ctx, "alice@example.com"))
+	sent = mockEmailService.GetSentEmails()
+	require.Len(t, sent, 1)
+	assert.Empty(t, sent[0].Locale)
+}
+
+func TestAuthService_SetAvatar(t *testing.T) {
+	authService, _, _, _, _, _ := setupTest(t)
+	ctx := context.Background()
+	user, _, err := authService.Register(ctx, "alice", "alice@example.com", "Password123!", "Alice", "", "127.0.0.1")
+	require.NoError(t, err)
+
+	previous, err := authService.SetAvatar(ctx, user.PublicID(), "avatars/1/a.png")
+	require.NoError(t, err)
+	assert.Empty(t, previous)
+
+	// The session user carries the key, and replacing it returns the old one
+	data, err := authService.userAdapter.FindUserByID(ctx, user.PublicID())
+	require.NoError(t, err)
+	assert.Equal(t, "avatars/1/a.png", data.AvatarKey())
+	previous, err = authService.SetAvatar(ctx, user.PublicID(), "")
+	require.NoError(t, err)
+	assert.Equal(t, "avatars/1/a.png", previous)
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/email"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/outbox"
+)
+
+var (
+	// ErrEmailNotVerified is returned by Login for users who haven't verified
+	// their email when verification is required
+	ErrEmailNotVerified = errors.New("email não verificado, confirme pelo link enviado por email")
+	// ErrEmailVerificationDisabled is returned when no verification secret is set
+	ErrEmailVerificationDisabled = errors.New("verificação de email desativada")
+)
+
+// defaultVerificationTTL is how long verification links last by default
+const defaultVerificationTTL = 24 * time.Hour
+
+// WithEmailVerification makes Register email a verification link signed
+// with secret (HMAC-SHA256), valid for ttl (24 hours if zero). A link
+// carries the user ID, a hash of the address and its expiry, so it stops
+// working if the email changes. VerifyEmail checks it and
+// ResendVerificationEmail sends a new one.
+func WithEmailVerification(secret []byte, ttl time.Duration) Option {
+	return func(s *AuthService) {
+		if ttl <= 0 {
+			ttl = defaultVerificationTTL
+		}
+		s.verificationSecret = secret
+		s.verificationTTL = ttl
+	}
+}
+
+// signVerificationToken returns a verification token for user, valid until
+// expiresAt: base64url("<user id>:<email hash>:<expiry unix>") + "." + base64url(mac)
+func (s *AuthService) signVerificationToken(user *models.User, expiresAt time.Time) string {
+	claims := user.PublicID() + ":" + verificationEmailHash(user.Email) + ":" + strconv.FormatInt(expiresAt.Unix(), 10)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(claims))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.verificationMAC(payload))
+}
+
+func (s *AuthService) verificationMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, s.verificationSecret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// verificationEmailHash is a short hash of the address, which binds a token
+// to it without exposing it
+func verificationEmailHash(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// verificationEmail returns the recipient, a new token and the names of the
+// verification email for user
+func (s *AuthService) verificationEmail(user *models.User) (to, token, username, displayName string) {
+	token = s.signVerificationToken(user, s.clock.Now().Add(s.verificationTTL))
+	return user.Email, token, user.Username, welcomeDisplayName(user.DisplayName, user.Username)
+}
+
+// sendVerificationEmail emails user a new verification link, through the
+// job queue when set and otherwise during the request, ignoring the
+// cancellation of ctx. Failures are logged and returned: the user can ask for
+// another link. Register goes through it only when it doesn't queue or send
+// the email in the user creation transaction.
+func (s *AuthService) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	ctx = email.WithLocale(ctx, user.Locale)
+	to, token, username, displayName := s.verificationEmail(user)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+
+	if s.jobs != nil {
+		if err := outbox.EnqueueEmailVerification(ctx, s.jobs, to, token, username, displayName); err != nil {
+			logger.FromContext(ctx).Error("Erro ao enfileirar email de verificação", "error", err, "user_id", userID)
+			return err
+		}
+		logger.FromContext(ctx).Info("Email de verificação enfileirado", "email", to, "user_id", userID)
+		return nil
+	}
+
+	if err := s.emailService.SendVerificationEmail(context.WithoutCancel(ctx), to, token, username, displayName); err != nil {
+		logger.FromContext(ctx).Error("Erro ao enviar email de verificação", "error", err, "email", to, "user_id", userID)
+		return err
+	}
+	logger.FromContext(ctx).Info("Email de verificação enviado", "email", to, "user_id", userID)
+	return nil
+}
+
+// VerifyEmail marks the email of the user a verification token was sent to
+// as verified. Verifying an already verified address again succeeds.
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	if len(s.verificationSecret) == 0 {
+		return ErrEmailVerificationDisabled
+	}
+
+	payload, signature, _ := strings.Cut(token, ".")
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.verificationMAC(payload)) {
+		logger.FromContext(ctx).Warn("Token de verificação de email com assinatura inválida")
+		return ErrInvalidToken
+	}
+
+	// The signature is valid, so the claims were issued by signVerificationToken
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	claims := strings.Split(string(raw), ":")
+	if len(claims) != 3 {
+		return ErrInvalidToken
+	}
+	expiry, err := strconv.ParseInt(claims[2], 10, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	user, err := s.userAdapter.GetUserModel(ctx, claims[0])
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if errors.Is(err, auth.ErrUserNotFound) {
+			logger.FromContext(ctx).Warn("Token de verificação de email para usuário inexistente")
+			return ErrInvalidToken
+		}
+		return err
+	}
+	if verificationEmailHash(user.Email) != claims[1] {
+		logger.FromContext(ctx).Warn("Token de verificação invalidado por troca de email", "user_id", user.ID)
+		return ErrInvalidToken
+	}
+	if user.EmailVerified {
+		return nil
+	}
+	if s.clock.Now().After(time.Unix(expiry, 0)) {
+		logger.FromContext(ctx).Warn("Token de verificação de email expirado", "user_id", user.ID)
+		return ErrExpiredToken
+	}
+
+	if err := s.MarkEmailVerified(ctx, user.PublicID()); err != nil {
+		logger.FromContext(ctx).Error("Erro ao marcar email como verificado", "error", err, "user_id", user.ID)
+		return err
+	}
+	logger.FromContext(ctx).Info("Email verificado", "user_id", user.ID)
+	return nil
+}
+
+// ResendVerificationEmail emails a new verification link to the user with
+// emailAddr. Unknown and already verified addresses are ignored, so callers
+// can't tell them apart.
+func (s *AuthService) ResendVerificationEmail(ctx context.Context, emailAddr string) error {
+	if len(s.verificationSecret) == 0 {
+		return ErrEmailVerificationDisabled
+	}
+
+	user, err := s.userAdapter.FindByEmail(ctx, emailAddr)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		logger.FromContext(ctx).Debug("Reenvio de verificação para email não encontrado", "email", emailAddr)
+		return nil
+	}
+	if user.EmailVerified {
+		logger.FromContext(ctx).Debug("Reenvio de verificação para email já verificado", "user_id", user.ID)
+		return nil
+	}
+	// Not returned either: the answer must not tell whether the address has
+	// an account
+	_ = s.sendVerificationEmail(ctx, user)
+	return nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+
+	"gosveltekit/internal/email"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/outbox"
+)
+
+// WithLockoutNotification makes Login email the owner of an account when
+// failed logins lock it, once per lockout, with how to get back in. The email
+// is queued as a job with WithJobs and otherwise sent in the background, so
+// it never delays the response.
+func WithLockoutNotification() Option {
+	return func(s *AuthService) {
+		s.notifyOnLockout = true
+	}
+}
+
+// notifyLockout emails the owner of the account identifier locked. Unknown
+// identifiers are ignored, so lockouts of made-up usernames send nothing.
+func (s *AuthService) notifyLockout(ctx context.Context, identifier string) {
+	if !s.notifyOnLockout {
+		return
+	}
+	lockedUntil := s.authManager.LockedUntil(ctx, identifier)
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		log := logger.FromContext(ctx)
+		user, err := s.userAdapter.FindUserByIdentifier(ctx, identifier)
+		if err != nil || user.Email == "" {
+			log.Debug("Bloqueio de conta sem email para avisar", "identifier", identifier)
+			return
+		}
+		displayName := welcomeDisplayName(user.DisplayName, user.Identifier)
+		ctx := email.WithLocale(ctx, user.Locale())
+		if s.jobs != nil {
+			if err := outbox.EnqueueAccountLocked(ctx, s.jobs, user.Email, user.Identifier, displayName, lockedUntil); err != nil {
+				log.Error("Erro ao enfileirar email de conta bloqueada", "error", err, "user_id", user.ID)
+			}
+			return
+		}
+		if err := s.emailService.SendAccountLockedEmail(ctx, user.Email, user.Identifier, displayName, lockedUntil); err != nil {
+			log.Error("Erro ao enviar email de conta bloqueada", "error", err, "email", user.Email, "user_id", user.ID)
+		}
+	}()
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/email"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/metrics"
+	"gosveltekit/internal/outbox"
+)
+
+var (
+	// ErrMagicLinkDisabled is returned when passwordless login is off
+	ErrMagicLinkDisabled = errors.New("login por link desativado")
+	// ErrInvalidMagicLink means the link is unknown, expired, already used,
+	// replaced by a newer one or was sent to an address the account no longer has
+	ErrInvalidMagicLink = errors.New("link de acesso inválido ou expirado, solicite um novo")
+)
+
+// WithMagicLinks enables passwordless login: RequestMagicLink emails a
+// single-use link and LoginWithMagicLink exchanges it for a session. Lifetime
+// and sending limits come from the auth manager's config.
+func WithMagicLinks() Option {
+	return func(s *AuthService) {
+		s.magicLinks = true
+	}
+}
+
+// RequestMagicLink emails a login link to the account with emailAddr, through
+// the job queue when set. Unknown addresses, accounts that can't log in and
+// requests over the per-account sending limit are only logged, so the answer
+// never reveals whether the address has an account.
+func (s *AuthService) RequestMagicLink(ctx context.Context, emailAddr, ip string) error {
+	if !s.magicLinks {
+		return ErrMagicLinkDisabled
+	}
+	log := logger.FromContext(ctx)
+
+	user, err := s.userAdapter.FindByEmail(ctx, emailAddr)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		log.Debug("Link de acesso solicitado para email não encontrado", "email", emailAddr, "ip", ip)
+		return nil
+	}
+	if !user.Active || user.ScheduledDeletionAt != nil {
+		log.Warn("Link de acesso solicitado para conta que não pode entrar", "user_id", user.ID, "ip", ip)
+		return nil
+	}
+
+	token, _, err := s.authManager.IssueMagicLink(ctx, user.PublicID(), user.Email)
+	if err != nil {
+		if errors.Is(err, auth.ErrMagicLinkRateLimited) {
+			log.Warn("Link de acesso recusado por limite de envios", "user_id", user.ID, "ip", ip)
+			return nil
+		}
+		return err
+	}
+
+	ctx = email.WithLocale(ctx, user.Locale)
+	displayName := welcomeDisplayName(user.DisplayName, user.Username)
+	if s.jobs != nil {
+		if err := outbox.EnqueueMagicLink(ctx, s.jobs, user.Email, token, user.Username, displayName); err != nil {
+			log.Error("Erro ao enfileirar link de acesso", "error", err, "user_id", user.ID)
+			return err
+		}
+		log.Info("Link de acesso enfileirado", "email", user.Email, "user_id", user.ID)
+		return nil
+	}
+
+	if err := s.emailService.SendMagicLinkEmail(ctx, user.Email, token, user.Username, displayName); err != nil {
+		log.Error("Erro ao enviar link de acesso", "error", err, "email", user.Email, "user_id", user.ID)
+	} else {
+		log.Info("Link de acesso enviado", "email", user.Email, "user_id", user.ID)
+	}
+	return nil
+}
+
+// LoginWithMagicLink consumes a link sent by RequestMagicLink and creates a
+// session for its user, like Login (including its two-factor challenge).
+// Following the link proves the user owns the address, so an unverified
+// email becomes verified.
+func (s *AuthService) LoginWithMagicLink(ctx context.Context, token, ip, userAgent string) (*LoginResponse, error) {
+	if !s.magicLinks {
+		return nil, ErrMagicLinkDisabled
+	}
+	log := logger.FromContext(ctx)
+
+	link, err := s.authManager.ConsumeMagicLink(ctx, token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidMagicLink) {
+			log.Warn("Link de acesso inválido ou expirado", "ip", ip)
+			metrics.LoginFailures.WithLabelValues(metrics.ReasonInvalidCredentials).Inc()
+			return nil, ErrInvalidMagicLink
+		}
+		log.Error("Erro ao verificar link de acesso", "error", err, "ip", ip)
+		return nil, err
+	}
+
+	user, err := s.userAdapter.GetUserModel(ctx, link.UserID)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, ErrInvalidMagicLink
+		}
+		return nil, err
+	}
+	if !strings.EqualFold(user.Email, link.Email) {
+		log.Warn("Link de acesso invalidado por troca de email", "user_id", user.ID, "ip", ip)
+		return nil, ErrInvalidMagicLink
+	}
+	if !user.EmailVerified {
+		if err := s.MarkEmailVerified(ctx, link.UserID); err != nil {
+			log.Error("Erro ao verificar email após login por link", "error", err, "user_id", user.ID)
+			return nil, err
+		}
+	}
+
+	metadata := auth.SessionMetadata{
+		UserAgent: userAgent,
+		IP:        ip,
+	}
+	session, loggedIn, err := s.authManager.LoginExternal(ctx, link.UserID, metadata)
+	if challenge, ok := s.twoFactorChallenge(ctx, err); ok {
+		log.Info("Login por link aguardando segundo fator", "user_id", user.ID, "ip", ip)
+		return &LoginResponse{TwoFactor: challenge}, nil
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrUserNotActive):
+			log.Warn("Login por link com usuário inativo", "user_id", user.ID, "ip", ip)
+			s.loginFailed(ctx, user.Username, ip, metrics.ReasonUserInactive)
+			return nil, ErrUserNotActive
+		case errors.Is(err, auth.ErrAccountPendingDeletion):
+			log.Warn("Login por link com conta agendada para exclusão", "user_id", user.ID, "ip", ip)
+			s.loginFailed(ctx, user.Username, ip, metrics.ReasonPendingDeletion)
+			return nil, ErrAccountPendingDeletion
+		case errors.Is(err, auth.ErrTooManySessions):
+			log.Warn("Login por link recusado por limite de sessões simultâneas", "user_id", user.ID, "ip", ip)
+			s.loginFailed(ctx, user.Username, ip, metrics.ReasonSessionLimit)
+			return nil, ErrTooManySessions
+		default:
+			log.Error("Erro ao fazer login por link", "error", err, "user_id", user.ID)
+			return nil, err
+		}
+	}
+
+	log.Info("Login por link realizado com sucesso", "user_id", loggedIn.ID, "ip", ip)
+	s.loginSucceeded(ctx, loggedIn.ID, ip, metrics.LoginMethodMagicLink)
+	s.notifyIfNewDevice(ctx, session, loggedIn)
+	return &LoginResponse{
+		SessionID:    session.ID,
+		ExpiresAt:    session.ExpiresAt,
+		User:         *loggedIn,
+		RefreshToken: session.RefreshToken,
+	}, nil
+}
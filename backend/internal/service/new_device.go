@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/email"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/outbox"
+	"gosveltekit/internal/settings"
+)
+
+// WithNewDeviceNotification makes Login email the user when they sign in from
+// a device none of their other sessions came from (see auth.IsNewDevice). The
+// email is queued as a job with WithJobs and otherwise sent in the background,
+// so it never delays the response.
+func WithNewDeviceNotification() Option {
+	return func(s *AuthService) {
+		s.notifyNewDevice = true
+	}
+}
+
+// newDeviceNotificationEnabled reports whether Login should check for new
+// devices, preferring the runtime setting when a store is configured
+func (s *AuthService) newDeviceNotificationEnabled() bool {
+	if s.settings != nil {
+		return s.settings.Bool(settings.KeyNotifyNewDevice)
+	}
+	return s.notifyNewDevice
+}
+
+// notifyIfNewDevice emails the user when session comes from a new device.
+// Failures are only logged: the login already succeeded.
+func (s *AuthService) notifyIfNewDevice(ctx context.Context, session *auth.Session, user *auth.UserData) {
+	if !s.newDeviceNotificationEnabled() || user.Email == "" {
+		return
+	}
+	log := logger.FromContext(ctx)
+
+	newDevice, err := s.authManager.IsNewDeviceSession(ctx, session)
+	if err != nil {
+		log.Error("Erro ao verificar dispositivo do login", "error", err, "user_id", user.ID)
+		return
+	}
+	if !newDevice {
+		return
+	}
+	log.Info("Login a partir de novo dispositivo", "user_id", user.ID, "ip", session.IP)
+
+	ctx = email.WithLocale(context.WithoutCancel(ctx), user.Locale())
+	to := user.Email
+	username := user.Identifier
+	displayName := welcomeDisplayName(user.DisplayName, user.Identifier)
+	userAgent, ip, userID := session.UserAgent, session.IP, user.ID
+	if s.jobs != nil {
+		if err := outbox.EnqueueNewDevice(ctx, s.jobs, to, username, displayName, userAgent, ip); err != nil {
+			log.Error("Erro ao enfileirar email de novo dispositivo", "error", err, "user_id", userID)
+		}
+		return
+	}
+	go func() {
+		if err := s.emailService.SendNewDeviceEmail(ctx, to, username, displayName, userAgent, ip); err != nil {
+			logger.FromContext(ctx).Error("Erro ao enviar email de novo dispositivo", "error", err, "email", to, "user_id", userID)
+		}
+	}()
+}
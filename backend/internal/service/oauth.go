@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"gosveltekit/internal/auth"
+	gormadapter "gosveltekit/internal/auth/adapter/gorm"
+	"gosveltekit/internal/auth/oauth"
+	"gosveltekit/internal/email"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/metrics"
+	"gosveltekit/internal/outbox"
+	"gosveltekit/internal/settings"
+	"gosveltekit/internal/tokens"
+	"gosveltekit/internal/webhooks"
+)
+
+var (
+	// ErrOAuthEmailNotVerified means the provider didn't vouch for an email,
+	// so the identity can't be matched to or create an account
+	ErrOAuthEmailNotVerified = errors.New("o provedor não informou um email verificado para esta conta")
+	// ErrOAuthAccountConflict means a local account has the email but never
+	// verified it, so linking could hand it to whoever registered it first
+	ErrOAuthAccountConflict = errors.New("já existe uma conta com este email: entre com a senha e verifique o email antes de usar o login social")
+)
+
+// usernameAttempts bounds the tries to find a free username for a new
+// OAuth user
+const usernameAttempts = 5
+
+// OAuthLogin logs in the user of an identity verified by an OAuth provider
+// and creates a session, like Login (including its two-factor challenge).
+//
+// The user is found by the identity's provider and subject. An unknown
+// identity is linked to the local account with the same email, provided both
+// sides have verified it, or else gets a new account with a verified email
+// and a random password (the user can set one with a password reset).
+func (s *AuthService) OAuthLogin(ctx context.Context, identity *oauth.Identity, ip, userAgent string) (*LoginResponse, error) {
+	log := logger.FromContext(ctx)
+
+	user, err := s.userAdapter.FindUserByExternalIdentity(ctx, identity.Provider, identity.Subject)
+	switch {
+	case err == nil:
+	case errors.Is(err, auth.ErrUserNotFound):
+		user, err = s.linkOAuthIdentity(ctx, identity, ip)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		log.Error("Erro ao buscar identidade externa", "error", err, "provider", identity.Provider)
+		return nil, err
+	}
+
+	metadata := auth.SessionMetadata{
+		UserAgent: userAgent,
+		IP:        ip,
+	}
+	session, loggedIn, err := s.authManager.LoginExternal(ctx, user.ID, metadata)
+	if challenge, ok := s.twoFactorChallenge(ctx, err); ok {
+		log.Info("Login social aguardando segundo fator", "user_id", user.ID, "provider", identity.Provider, "ip", ip)
+		return &LoginResponse{TwoFactor: challenge}, nil
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrUserNotActive):
+			log.Warn("Login social com usuário inativo", "user_id", user.ID, "provider", identity.Provider, "ip", ip)
+			s.loginFailed(ctx, user.Identifier, ip, metrics.ReasonUserInactive)
+			return nil, ErrUserNotActive
+		case errors.Is(err, auth.ErrAccountPendingDeletion):
+			log.Warn("Login social com conta agendada para exclusão", "user_id", user.ID, "provider", identity.Provider, "ip", ip)
+			s.loginFailed(ctx, user.Identifier, ip, metrics.ReasonPendingDeletion)
+			return nil, ErrAccountPendingDeletion
+		case errors.Is(err, auth.ErrTooManySessions):
+			log.Warn("Login social recusado por limite de sessões simultâneas", "user_id", user.ID, "ip", ip)
+			s.loginFailed(ctx, user.Identifier, ip, metrics.ReasonSessionLimit)
+			return nil, ErrTooManySessions
+		default:
+			log.Error("Erro ao fazer login social", "error", err, "user_id", user.ID, "provider", identity.Provider)
+			return nil, err
+		}
+	}
+
+	log.Info("Login social realizado com sucesso", "user_id", loggedIn.ID, "provider", identity.Provider, "ip", ip)
+	s.loginSucceeded(ctx, loggedIn.ID, ip, metrics.LoginMethodOAuth)
+	s.notifyIfNewDevice(ctx, session, loggedIn)
+	return &LoginResponse{
+		SessionID:    session.ID,
+		ExpiresAt:    session.ExpiresAt,
+		User:         *loggedIn,
+		RefreshToken: session.RefreshToken,
+	}, nil
+}
+
+// linkOAuthIdentity links an identity seen for the first time to the account
+// with its email, creating the account if there is none
+func (s *AuthService) linkOAuthIdentity(ctx context.Context, identity *oauth.Identity, ip string) (*auth.UserData, error) {
+	log := logger.FromContext(ctx)
+	email := strings.TrimSpace(identity.Email)
+	if email == "" || !identity.EmailVerified {
+		log.Warn("Login social sem email verificado", "provider", identity.Provider, "ip", ip)
+		return nil, ErrOAuthEmailNotVerified
+	}
+
+	existing, err := s.userAdapter.FindByEmail(ctx, email)
+	switch {
+	case err == nil:
+		if !existing.EmailVerified {
+			log.Warn("Login social recusado: conta com o mesmo email não verificada", "user_id", existing.ID, "provider", identity.Provider, "ip", ip)
+			return nil, ErrOAuthAccountConflict
+		}
+		userID := existing.PublicID()
+		if err := s.userAdapter.LinkExternalIdentity(ctx, userID, identity.Provider, identity.Subject, email); err != nil {
+			log.Error("Erro ao vincular identidade externa", "error", err, "user_id", existing.ID, "provider", identity.Provider)
+			return nil, err
+		}
+		log.Info("Identidade externa vinculada a conta existente", "user_id", existing.ID, "provider", identity.Provider)
+		return s.userAdapter.FindUserByID(ctx, userID)
+	case errors.Is(err, auth.ErrUserNotFound):
+		return s.createOAuthUser(ctx, identity, email, ip)
+	default:
+		log.Error("Erro ao buscar usuário por email no login social", "error", err, "provider", identity.Provider)
+		return nil, err
+	}
+}
+
+// createOAuthUser registers the user of identity, already verified by the
+// provider
+func (s *AuthService) createOAuthUser(ctx context.Context, identity *oauth.Identity, emailAddr, ip string) (*auth.UserData, error) {
+	log := logger.FromContext(ctx)
+	if s.settings != nil && !s.settings.Bool(settings.KeyRegistrationEnabled) {
+		log.Info("Registro por login social rejeitado: cadastro desativado", "provider", identity.Provider, "ip", ip)
+		return nil, ErrRegistrationClosed
+	}
+
+	username, err := s.oauthUsername(ctx, emailAddr)
+	if err != nil {
+		log.Error("Erro ao escolher nome de usuário para login social", "error", err, "email", emailAddr)
+		return nil, err
+	}
+
+	welcome := s.welcomeTrigger != ""
+	var userData *auth.UserData
+	err = s.userAdapter.Transaction(ctx, func(tx *gormadapter.UserAdapter) error {
+		// Unknown to anyone: the account logs in through the provider
+		password, err := tokens.Text(s.tokens, 32)
+		if err != nil {
+			return err
+		}
+		created, err := tx.CreateUser(ctx, auth.CreateUserInput{
+			Identifier:  username,
+			Email:       emailAddr,
+			Password:    password,
+			DisplayName: identity.Name,
+			Locale:      email.LocaleFromContext(ctx),
+		})
+		if err != nil {
+			return err
+		}
+		user, err := tx.GetUserModel(ctx, created.ID)
+		if err != nil {
+			return err
+		}
+		now := s.clock.Now()
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+		if err := tx.UpdateUser(ctx, user); err != nil {
+			return err
+		}
+		if err := tx.LinkExternalIdentity(ctx, created.ID, identity.Provider, identity.Subject, emailAddr); err != nil {
+			return err
+		}
+		if welcome && s.jobs != nil {
+			if err := outbox.EnqueueWelcome(ctx, s.jobs.InTx(tx.DB()), user.Email, user.Username, welcomeDisplayName(user.DisplayName, user.Username)); err != nil {
+				return err
+			}
+		}
+		userData = created
+		return nil
+	})
+	if err != nil {
+		log.Error("Erro ao criar usuário por login social", "error", err, "email", emailAddr, "provider", identity.Provider)
+		return nil, err
+	}
+
+	user, err := s.userAdapter.GetUserModel(ctx, userData.ID)
+	if err != nil {
+		log.Error("Erro ao buscar usuário criado", "error", err, "user_id", userData.ID)
+		return nil, err
+	}
+	log.Info("Usuário registrado por login social", "user_id", user.ID, "username", username, "provider", identity.Provider)
+	if welcome && s.jobs == nil {
+		s.sendWelcomeEmail(ctx, user)
+	}
+	s.publish(ctx, webhooks.EventUserRegistered, map[string]any{
+		"user_id":  user.PublicID(),
+		"username": user.Username,
+		"email":    user.Email,
+	})
+	return s.userAdapter.FindUserByID(ctx, userData.ID)
+}
+
+// oauthUsername derives a free username from the local part of email,
+// adding a random suffix when it is taken or not allowed
+func (s *AuthService) oauthUsername(ctx context.Context, email string) (string, error) {
+	policy := s.authManager.UsernamePolicy()
+	local, _, _ := strings.Cut(email, "@")
+	base := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return -1
+		}
+	}, local)
+	if base == "" {
+		base = "user"
+	}
+	// Leave room for the suffix
+	if policy.MaxLength > 0 && len(base) > policy.MaxLength-5 {
+		base = base[:max(policy.MaxLength-5, 1)]
+	}
+
+	candidate := base
+	for range usernameAttempts {
+		if policy.Validate(candidate, false) == nil {
+			_, err := s.userAdapter.FindUserByIdentifier(ctx, candidate)
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				return candidate, nil
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+		n, err := rand.Int(s.tokens, big.NewInt(10000))
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s-%04d", base, n.Int64())
+	}
+	return "", fmt.Errorf("nenhum nome de usuário livre a partir de %q", base)
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/realtime"
+)
+
+// WithRealtime makes the service push session.revoked and password.changed
+// events to the user's connected clients through publisher, and close the
+// connections of the sessions it ends
+func WithRealtime(publisher realtime.Publisher) Option {
+	return func(s *AuthService) {
+		s.realtime = publisher
+	}
+}
+
+// notify publishes event to userID, which may be the primary key sessions
+// hold or the public ID connections are registered with. With
+// checkSessions, connections whose session just ended are closed.
+func (s *AuthService) notify(ctx context.Context, userID string, event realtime.Event, checkSessions bool) {
+	if s.realtime == nil {
+		return
+	}
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		logger.FromContext(ctx).Warn("Erro ao buscar usuário para notificação em tempo real", "error", err, "user_id", userID, "type", event.Type)
+		return
+	}
+	publicID := user.PublicID()
+	s.realtime.Publish(ctx, publicID, event)
+	if checkSessions {
+		s.realtime.CheckSessions(ctx, publicID)
+	}
+}
+
+// passwordChanged notifies userID of a password change or reset, which may
+// have revoked sessions
+func (s *AuthService) passwordChanged(ctx context.Context, userID string) {
+	s.notify(ctx, userID, realtime.Event{Type: realtime.EventPasswordChanged}, s.revokeSessionsOnPasswordChange)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/logger"
+)
+
+// Refresh exchanges a refresh token for a new session and refresh token. A
+// token presented twice ends every session renewed from it.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken, ip, userAgent string) (*LoginResponse, error) {
+	log := logger.FromContext(ctx)
+	metadata := auth.SessionMetadata{
+		UserAgent: userAgent,
+		IP:        ip,
+	}
+
+	session, user, err := s.authManager.Refresh(ctx, refreshToken, metadata)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidRefreshToken),
+			errors.Is(err, auth.ErrRefreshTokensUnsupported):
+			return nil, ErrInvalidRefreshToken
+		case errors.Is(err, auth.ErrRefreshTokenReused):
+			log.Warn("Refresh token reutilizado, sessões encerradas", "ip", ip)
+			return nil, ErrInvalidRefreshToken
+		case errors.Is(err, auth.ErrUserNotActive):
+			return nil, ErrUserNotActive
+		case errors.Is(err, auth.ErrAccountPendingDeletion):
+			return nil, ErrAccountPendingDeletion
+		case errors.Is(err, context.Canceled):
+			return nil, err
+		default:
+			log.Error("Erro ao renovar sessão", "error", err, "ip", ip)
+			return nil, err
+		}
+	}
+
+	log.Debug("Sessão renovada com refresh token", "user_id", user.ID, "ip", ip)
+	return &LoginResponse{
+		SessionID:    session.ID,
+		ExpiresAt:    session.ExpiresAt,
+		User:         *user,
+		RefreshToken: session.RefreshToken,
+	}, nil
+}
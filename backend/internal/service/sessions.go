@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/logger"
+)
+
+// ListSessions returns the active sessions of a user, newest first.
+// Sessions are identified to the user by auth.SessionHandle.
+func (s *AuthService) ListSessions(ctx context.Context, userID string) ([]*auth.Session, error) {
+	sessions, err := s.authManager.ActiveSessions(ctx, userID)
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao listar sessões do usuário", "error", err, "user_id", userID)
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one of the user's sessions, identified by its
+// auth.SessionHandle. Returns ErrSessionNotFound if the user has no such
+// session.
+func (s *AuthService) RevokeSession(ctx context.Context, userID, handle string) error {
+	if err := s.authManager.RevokeSessionByHandle(ctx, userID, handle); err != nil {
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	logger.FromContext(ctx).Info("Sessão revogada pelo usuário", "user_id", userID)
+	s.sessionRevoked(ctx, userID, RevokedByClient)
+	return nil
+}
+
+// RevokeOtherSessions ends every session of the user except currentSessionID,
+// e.g. after noticing an unknown device, and reports each session ended. A
+// session that can't be ended doesn't stop the others.
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, userID, currentSessionID string) ([]auth.SessionRevocation, error) {
+	revocations, err := s.authManager.LogoutOthers(ctx, userID, currentSessionID)
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao revogar demais sessões", "error", err, "user_id", userID)
+		return nil, err
+	}
+	s.sessionRevoked(ctx, userID, RevokedByLogoutOthers)
+	return revocations, nil
+}
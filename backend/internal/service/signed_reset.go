@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+)
+
+// WithSignedResetTokens makes RequestPasswordReset send stateless links
+// signed with secret (HMAC-SHA256) instead of storing a token on the user.
+// A link carries the user ID, the user's password version and its expiry, so
+// it stops working when the password changes, including by using the link.
+//
+// Stored tokens already sent keep working until they expire.
+func WithSignedResetTokens(secret []byte) Option {
+	return func(s *AuthService) {
+		s.resetTokenSecret = secret
+	}
+}
+
+// signResetToken returns a reset token for user, valid until expiresAt:
+// base64url("<user id>:<password version>:<expiry unix>") + "." + base64url(mac)
+func (s *AuthService) signResetToken(user *models.User, expiresAt time.Time) string {
+	claims := user.PublicID() + ":" + strconv.FormatUint(uint64(user.PasswordVersion), 10) + ":" + strconv.FormatInt(expiresAt.Unix(), 10)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(claims))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.resetTokenMAC(payload))
+}
+
+func (s *AuthService) resetTokenMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, s.resetTokenSecret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// isSignedResetToken tells signed tokens apart from stored ones, which are hex
+func isSignedResetToken(token string) bool {
+	return strings.Contains(token, ".")
+}
+
+// findSignedResetTokenUser returns the user of a signed reset token after
+// checking its signature, expiry and password version
+func (s *AuthService) findSignedResetTokenUser(ctx context.Context, token string) (*models.User, error) {
+	if len(s.resetTokenSecret) == 0 {
+		logger.FromContext(ctx).Warn("Token de reset de senha assinado recebido sem chave configurada")
+		return nil, ErrInvalidToken
+	}
+
+	payload, signature, _ := strings.Cut(token, ".")
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.resetTokenMAC(payload)) {
+		logger.FromContext(ctx).Warn("Token de reset de senha com assinatura inválida")
+		return nil, ErrInvalidToken
+	}
+
+	// The signature is valid, so the claims were issued by signResetToken
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims := strings.Split(string(raw), ":")
+	if len(claims) != 3 {
+		return nil, ErrInvalidToken
+	}
+	version, err := strconv.ParseUint(claims[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	expiry, err := strconv.ParseInt(claims[2], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userAdapter.GetUserModel(ctx, claims[0])
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if errors.Is(err, auth.ErrUserNotFound) {
+			logger.FromContext(ctx).Warn("Token de reset de senha assinado para usuário inexistente")
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if uint64(user.PasswordVersion) != version {
+		logger.FromContext(ctx).Warn("Token de reset de senha invalidado por troca de senha", "user_id", user.ID)
+		return nil, ErrInvalidToken
+	}
+	if s.clock.Now().After(time.Unix(expiry, 0)) {
+		logger.FromContext(ctx).Warn("Token de reset de senha expirado", "user_id", user.ID)
+		return nil, ErrExpiredToken
+	}
+	return user, nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/metrics"
+)
+
+// TwoFactorChallenge is returned by Login instead of a session to users with
+// a second factor: Token goes back with the code to CompleteTwoFactorLogin
+// before ExpiresAt. Method tells which code to ask for.
+type TwoFactorChallenge struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Method    string    `json:"method"`
+}
+
+// twoFactorChallenge extracts the challenge of an auth login error that asks
+// for the second factor, sending the code to users with SMS 2FA
+func (s *AuthService) twoFactorChallenge(ctx context.Context, err error) (*TwoFactorChallenge, bool) {
+	var required *auth.TwoFactorRequiredError
+	if !errors.As(err, &required) {
+		return nil, false
+	}
+	if required.Method == TwoFactorSMS {
+		// The challenge stays open: the code can be sent again, and recovery
+		// codes still work
+		if err := s.SendTwoFactorCode(ctx, required.UserID); err != nil {
+			logger.FromContext(ctx).Error("Erro ao enviar código de 2FA por SMS no login", "error", err, "user_id", required.UserID)
+		}
+	}
+	return &TwoFactorChallenge{Token: required.Token, ExpiresAt: required.ExpiresAt, Method: required.Method}, true
+}
+
+// CompleteTwoFactorLogin creates the session of a login challenged by Login,
+// given a code of the user's authenticator app or one of their recovery codes
+func (s *AuthService) CompleteTwoFactorLogin(ctx context.Context, challengeToken, code, ip string) (*LoginResponse, error) {
+	log := logger.FromContext(ctx)
+
+	session, user, err := s.authManager.CompleteTwoFactorLogin(ctx, challengeToken, code)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+			log.Warn("Código de 2FA inválido no login", "ip", ip)
+			metrics.LoginFailures.WithLabelValues(metrics.ReasonInvalidTwoFactor).Inc()
+			return nil, ErrInvalidTwoFactorCode
+		case errors.Is(err, auth.ErrInvalidTwoFactorChallenge):
+			log.Warn("Desafio de 2FA inválido ou expirado", "ip", ip)
+			return nil, ErrInvalidTwoFactorChallenge
+		case errors.Is(err, auth.ErrAccountLocked):
+			log.Warn("Login com 2FA bloqueado por excesso de códigos inválidos", "ip", ip)
+			metrics.LoginFailures.WithLabelValues(metrics.ReasonAccountLocked).Inc()
+			return nil, ErrTwoFactorLocked
+		case errors.Is(err, auth.ErrUserNotActive):
+			return nil, ErrUserNotActive
+		case errors.Is(err, auth.ErrAccountPendingDeletion):
+			return nil, ErrAccountPendingDeletion
+		case errors.Is(err, auth.ErrTooManySessions):
+			metrics.LoginFailures.WithLabelValues(metrics.ReasonSessionLimit).Inc()
+			return nil, ErrTooManySessions
+		case errors.Is(err, context.Canceled):
+			return nil, err
+		default:
+			log.Error("Erro ao concluir login com 2FA", "error", err, "ip", ip)
+			return nil, err
+		}
+	}
+
+	log.Info("Login com 2FA realizado com sucesso", "user_id", user.ID, "ip", ip)
+	s.loginSucceeded(ctx, user.ID, ip, metrics.LoginMethodTwoFactor)
+	s.notifyIfNewDevice(ctx, session, user)
+	return &LoginResponse{
+		SessionID:    session.ID,
+		ExpiresAt:    session.ExpiresAt,
+		User:         *user,
+		RefreshToken: session.RefreshToken,
+	}, nil
+}
+
+// EnrollTOTP starts setting up an authenticator app: the returned secret and
+// provisioning URI (for a QR code) only protect logins after ConfirmTOTP
+func (s *AuthService) EnrollTOTP(ctx context.Context, userID string) (*auth.TOTPEnrollment, error) {
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollment, err := s.authManager.EnrollTOTP(ctx, userID, user.Email)
+	if errors.Is(err, auth.ErrTOTPAlreadyEnabled) {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao iniciar configuração de TOTP", "error", err, "user_id", userID)
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("Configuração de TOTP iniciada", "user_id", userID)
+	return enrollment, nil
+}
+
+// ConfirmTOTP enables TOTP with a first code from the app, making it the
+// user's 2FA method, and returns the new recovery codes, shown only once
+func (s *AuthService) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	codes, err := s.authManager.ConfirmTOTP(ctx, userID, code)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+			logger.FromContext(ctx).Warn("Código inválido ao confirmar TOTP", "user_id", userID)
+			return nil, ErrInvalidTwoFactorCode
+		case errors.Is(err, auth.ErrTOTPNotEnrolled):
+			return nil, ErrTOTPNotEnabled
+		case errors.Is(err, auth.ErrTOTPAlreadyEnabled):
+			return nil, ErrTOTPAlreadyEnabled
+		default:
+			logger.FromContext(ctx).Error("Erro ao confirmar TOTP", "error", err, "user_id", userID)
+			return nil, err
+		}
+	}
+
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	user.TwoFactorMethod = TwoFactorTOTP
+	if err := s.userAdapter.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns TOTP off, confirmed with the user's password, and drops
+// their recovery codes
+func (s *AuthService) DisableTOTP(ctx context.Context, userID, password string) error {
+	ok, err := s.userAdapter.VerifyPassword(ctx, userID, password)
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao verificar senha para desativar TOTP", "error", err, "user_id", userID)
+		return err
+	}
+	if !ok {
+		logger.FromContext(ctx).Warn("Tentativa de desativar TOTP com senha incorreta", "user_id", userID)
+		return ErrWrongPassword
+	}
+
+	enabled, err := s.authManager.TOTPEnabled(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return ErrTOTPNotEnabled
+	}
+	if err := s.authManager.DisableTOTP(ctx, userID); err != nil {
+		return err
+	}
+
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.TwoFactorMethod == TwoFactorTOTP {
+		user.TwoFactorMethod = TwoFactorNone
+		if err := s.userAdapter.UpdateUser(ctx, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
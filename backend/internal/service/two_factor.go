@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/sms"
+)
+
+// Two-factor methods, selected per user
+const (
+	TwoFactorNone = ""
+	TwoFactorTOTP = auth.TwoFactorMethodTOTP
+	TwoFactorSMS  = auth.TwoFactorMethodSMS
+)
+
+// e164Pattern matches phone numbers in E.164 format
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// WithSMSSender enables phone verification and SMS-based 2FA, sending codes
+// through sender
+func WithSMSSender(sender sms.Sender) Option {
+	return func(s *AuthService) {
+		s.smsSender = sender
+	}
+}
+
+// SetPhoneNumber stores the user's phone number (E.164) as unverified and
+// sends a verification code to it. The number can't be changed while it is
+// used for SMS 2FA, or the user could lose access to their second factor.
+func (s *AuthService) SetPhoneNumber(ctx context.Context, userID, phoneNumber string) error {
+	if !e164Pattern.MatchString(phoneNumber) {
+		return ErrInvalidPhoneNumber
+	}
+	if s.smsSender == nil {
+		return ErrSMSUnavailable
+	}
+
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.TwoFactorMethod == TwoFactorSMS {
+		return ErrPhoneLockedBy2FA
+	}
+
+	user.PhoneNumber = phoneNumber
+	user.PhoneVerified = false
+	if err := s.userAdapter.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	return s.sendSMSCode(ctx, userID, phoneNumber, auth.SMSPurposePhoneVerification)
+}
+
+// VerifyPhoneNumber confirms the user's phone number with the code sent by
+// SetPhoneNumber
+func (s *AuthService) VerifyPhoneNumber(ctx context.Context, userID, code string) error {
+	if err := s.verifySMSCode(ctx, userID, auth.SMSPurposePhoneVerification, code); err != nil {
+		return err
+	}
+
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		return err
+	}
+	user.PhoneVerified = true
+	if err := s.userAdapter.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).Info("Telefone verificado", "user_id", userID)
+	return nil
+}
+
+// SetTwoFactorMethod selects the user's second factor. TwoFactorSMS requires a
+// verified phone number and TwoFactorTOTP a confirmed authenticator app (see
+// ConfirmTOTP); TwoFactorNone disables 2FA. While TOTP is enabled it stays the
+// method: only DisableTOTP, with the password, turns it off.
+func (s *AuthService) SetTwoFactorMethod(ctx context.Context, userID, method string) error {
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	switch method {
+	case TwoFactorNone, TwoFactorTOTP, TwoFactorSMS:
+	default:
+		return ErrInvalidTwoFactorMethod
+	}
+	totpEnabled, err := s.authManager.TOTPEnabled(ctx, userID)
+	if err != nil && !errors.Is(err, auth.ErrTOTPUnsupported) {
+		return err
+	}
+	switch {
+	case method == TwoFactorTOTP && !totpEnabled:
+		return ErrTOTPNotEnabled
+	case method != TwoFactorTOTP && totpEnabled:
+		return ErrTOTPEnabled
+	case method == TwoFactorSMS && (user.PhoneNumber == "" || !user.PhoneVerified):
+		return ErrPhoneNotVerified
+	}
+
+	user.TwoFactorMethod = method
+	if err := s.userAdapter.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	logger.FromContext(ctx).Info("Método de 2FA alterado", "user_id", userID, "method", method)
+	return nil
+}
+
+// DisableSMSTwoFactor turns SMS 2FA off, confirmed with the user's password.
+// The phone number stays verified.
+func (s *AuthService) DisableSMSTwoFactor(ctx context.Context, userID, password string) error {
+	ok, err := s.userAdapter.VerifyPassword(ctx, userID, password)
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao verificar senha para desativar 2FA por SMS", "error", err, "user_id", userID)
+		return err
+	}
+	if !ok {
+		logger.FromContext(ctx).Warn("Tentativa de desativar 2FA por SMS com senha incorreta", "user_id", userID)
+		return ErrWrongPassword
+	}
+
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.TwoFactorMethod != TwoFactorSMS {
+		return ErrTwoFactorSMSNotEnabled
+	}
+	return s.SetTwoFactorMethod(ctx, userID, TwoFactorNone)
+}
+
+// ResendTwoFactorCode sends a new SMS code for a login challenged by Login,
+// as with SendTwoFactorCode. The challenge must still be pending
+// (ErrInvalidTwoFactorChallenge otherwise).
+func (s *AuthService) ResendTwoFactorCode(ctx context.Context, challengeToken string) error {
+	userID, err := s.authManager.TwoFactorChallengeUser(ctx, challengeToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidTwoFactorChallenge) {
+			return ErrInvalidTwoFactorChallenge
+		}
+		return err
+	}
+	return s.SendTwoFactorCode(ctx, userID)
+}
+
+// SendTwoFactorCode sends a 2FA code to the verified phone of a user with SMS
+// 2FA enabled
+func (s *AuthService) SendTwoFactorCode(ctx context.Context, userID string) error {
+	if s.smsSender == nil {
+		return ErrSMSUnavailable
+	}
+
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.TwoFactorMethod != TwoFactorSMS {
+		return ErrTwoFactorSMSNotEnabled
+	}
+	if user.PhoneNumber == "" || !user.PhoneVerified {
+		return ErrPhoneNotVerified
+	}
+
+	return s.sendSMSCode(ctx, userID, user.PhoneNumber, auth.SMSPurposeTwoFactor)
+}
+
+// VerifyTwoFactorCode checks a code sent by SendTwoFactorCode. Each code can
+// only be used once.
+func (s *AuthService) VerifyTwoFactorCode(ctx context.Context, userID, code string) error {
+	return s.verifySMSCode(ctx, userID, auth.SMSPurposeTwoFactor, code)
+}
+
+func (s *AuthService) sendSMSCode(ctx context.Context, userID, phoneNumber, purpose string) error {
+	code, err := s.authManager.IssueSMSCode(ctx, userID, purpose)
+	if err != nil {
+		if errors.Is(err, auth.ErrSMSCodeRateLimited) {
+			return ErrSMSRateLimited
+		}
+		return err
+	}
+
+	message := fmt.Sprintf("Seu código de verificação GoSvelteKit é %s. Não compartilhe este código.", code)
+	if err := s.smsSender.Send(ctx, phoneNumber, message); err != nil {
+		logger.FromContext(ctx).Error("Erro ao enviar SMS", "error", err, "user_id", userID, "purpose", purpose)
+		return err
+	}
+
+	logger.FromContext(ctx).Info("Código SMS enviado", "user_id", userID, "purpose", purpose)
+	return nil
+}
+
+func (s *AuthService) verifySMSCode(ctx context.Context, userID, purpose, code string) error {
+	if err := s.authManager.VerifySMSCode(ctx, userID, purpose, code); err != nil {
+		if errors.Is(err, auth.ErrInvalidSMSCode) {
+			logger.FromContext(ctx).Warn("Código SMS inválido", "user_id", userID, "purpose", purpose)
+			return ErrInvalidSMSCode
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/pagination"
+	"gosveltekit/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidRole      = errors.New("papel inválido")
+	ErrLastAdmin        = errors.New("não é possível rebaixar o último administrador ativo")
+	ErrDisableLastAdmin = errors.New("não é possível desativar o último administrador ativo")
+	ErrUsernameTaken    = errors.New("nome de usuário já está em uso")
+	ErrEmailTaken       = errors.New("email já está em uso")
+	ErrNotScheduled     = errors.New("conta não está agendada para exclusão")
+)
+
+// DefaultRoles are the roles accepted by UpdateRole without WithAllowedRoles
+var DefaultRoles = []string{"user", auth.AdminRole}
+
+// CreateUserInput is a user created by an admin
+type CreateUserInput struct {
+	Username    string
+	Email       string
+	DisplayName string
+	Password    string
+	// Role defaults to "user"
+	Role string
+	// MustChangePassword makes the user replace the password at first login
+	MustChangePassword bool
+	// EmailVerified skips the email verification, e.g. for addresses the
+	// admin already knows to be valid
+	EmailVerified bool
+}
+
+// UserServiceInterface defines the methods that a user service must implement
+type UserServiceInterface interface {
+	ListUsers(ctx context.Context, filter repository.UserFilter, params pagination.Params, sort pagination.Sort) ([]models.User, int64, error)
+	GetUser(ctx context.Context, userID string) (*models.User, error)
+	CreateUser(ctx context.Context, actorID string, input CreateUserInput) (*models.User, error)
+	UpdateDisplayName(ctx context.Context, actorID, userID, displayName string) (*models.User, error)
+	SetActive(ctx context.Context, actorID, userID string, active bool) (*models.User, error)
+	ExportUsers(ctx context.Context, sort pagination.Sort, fn func(*models.User) error) error
+	UpdateRole(ctx context.Context, actorID, userID, role string, revokeSessions bool) (*models.User, error)
+	ExpirePassword(ctx context.Context, actorID, userID string, revokeSessions bool) error
+	UpdateUsername(ctx context.Context, actorID, userID, username string) (*models.User, error)
+	RestoreAccount(ctx context.Context, actorID, userID string) error
+}
+
+// UserService handles user management business logic
+type UserService struct {
+	userRepository *repository.UserRepository
+
+	// allowedRoles are the roles an admin may assign
+	allowedRoles []string
+
+	// usernamePolicy is checked by UpdateUsername
+	usernamePolicy auth.UsernamePolicy
+
+	// authManager revokes sessions after a role change, password expiry
+	// or account disable; nil disables it
+	authManager *auth.AuthManager
+
+	// passwordHasher hashes the password of users created by CreateUser
+	passwordHasher auth.PasswordHasher
+}
+
+// UserServiceOption configures optional behavior of UserService
+type UserServiceOption func(*UserService)
+
+// WithAllowedRoles sets the roles UpdateRole accepts. Empty keeps DefaultRoles.
+func WithAllowedRoles(roles ...string) UserServiceOption {
+	return func(s *UserService) {
+		if len(roles) > 0 {
+			s.allowedRoles = roles
+		}
+	}
+}
+
+// WithUsernamePolicy sets the rules UpdateUsername checks. Without it the
+// auth.DefaultUsernamePolicy applies.
+func WithUsernamePolicy(policy auth.UsernamePolicy) UserServiceOption {
+	return func(s *UserService) {
+		s.usernamePolicy = policy
+	}
+}
+
+// WithSessionRevocation lets UpdateRole and ExpirePassword log the affected
+// user out of all sessions, and SetActive log out disabled users
+func WithSessionRevocation(authManager *auth.AuthManager) UserServiceOption {
+	return func(s *UserService) {
+		s.authManager = authManager
+	}
+}
+
+// WithPasswordHasher sets the hasher of CreateUser, which should be the one
+// the auth adapter verifies with. Without it passwords are hashed with bcrypt.
+func WithPasswordHasher(hasher auth.PasswordHasher) UserServiceOption {
+	return func(s *UserService) {
+		s.passwordHasher = hasher
+	}
+}
+
+// NewUserService creates a new UserService instance
+func NewUserService(userRepository *repository.UserRepository, opts ...UserServiceOption) *UserService {
+	s := &UserService{
+		userRepository: userRepository,
+		allowedRoles:   DefaultRoles,
+		usernamePolicy: auth.DefaultUsernamePolicy(),
+		passwordHasher: auth.NewBcryptHasher(bcrypt.DefaultCost),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ListUsers returns a page of the users matching filter and their total number
+func (s *UserService) ListUsers(ctx context.Context, filter repository.UserFilter, params pagination.Params, sort pagination.Sort) ([]models.User, int64, error) {
+	users, total, err := s.userRepository.List(ctx, filter, params, sort)
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao listar usuários", "error", err)
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// GetUser returns the user identified by userID
+func (s *UserService) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	id, err := s.userRepository.ResolveID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	user, err := s.userRepository.FindByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateUser creates an active account on behalf of the admin actorID, in the
+// tenant of ctx. The username policy applies, except for reserved names, and
+// the role must be one of the allowed roles. Email, display name and password
+// are validated by the handler, as on registration.
+func (s *UserService) CreateUser(ctx context.Context, actorID string, input CreateUserInput) (*models.User, error) {
+	if input.Role == "" {
+		input.Role = "user"
+	}
+	if !slices.Contains(s.allowedRoles, input.Role) {
+		return nil, ErrInvalidRole
+	}
+	if err := s.usernamePolicy.Validate(input.Username, true); err != nil {
+		return nil, err
+	}
+
+	hash, err := s.passwordHasher.Hash(input.Password)
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao gerar hash da senha", "error", err, "actor_id", actorID)
+		return nil, err
+	}
+	user := &models.User{
+		Username:           input.Username,
+		Email:              input.Email,
+		DisplayName:        input.DisplayName,
+		PasswordHash:       hash,
+		Active:             true,
+		Role:               input.Role,
+		MustChangePassword: input.MustChangePassword,
+		EmailVerified:      input.EmailVerified,
+	}
+	if input.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := s.userRepository.CreateUser(ctx, user); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrUsernameTaken):
+			return nil, ErrUsernameTaken
+		case errors.Is(err, repository.ErrEmailTaken):
+			return nil, ErrEmailTaken
+		default:
+			logger.FromContext(ctx).Error("Erro ao criar usuário", "error", err, "actor_id", actorID)
+			return nil, err
+		}
+	}
+
+	logger.FromContext(ctx).Warn("Usuário criado por administrador", "actor_id", actorID, "user_id", user.ID, "role", user.Role)
+	return user, nil
+}
+
+// ExportUsers calls fn for every user ordered by sort, one at a time, so
+// exports of any size run in constant memory
+func (s *UserService) ExportUsers(ctx context.Context, sort pagination.Sort, fn func(*models.User) error) error {
+	return s.userRepository.Each(ctx, sort, fn)
+}
+
+// UpdateRole sets the role of userID on behalf of the admin actorID. The role
+// must be one of the allowed roles, and the last active admin can't be
+// demoted (ErrLastAdmin), so admins can't lock everyone out.
+//
+// Roles are read from the user on every request, so the change applies to
+// existing sessions right away; revokeSessions additionally logs the user out
+// everywhere, forcing a fresh login.
+func (s *UserService) UpdateRole(ctx context.Context, actorID, userID, role string, revokeSessions bool) (*models.User, error) {
+	if !slices.Contains(s.allowedRoles, role) {
+		return nil, ErrInvalidRole
+	}
+	id, err := s.userRepository.ResolveID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	previous, err := s.userRepository.UpdateRole(ctx, id, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, ErrUserNotFound
+		case errors.Is(err, repository.ErrLastAdmin):
+			logger.FromContext(ctx).Warn("Tentativa de rebaixar o último administrador", "actor_id", actorID, "user_id", userID, "role", role)
+			return nil, ErrLastAdmin
+		default:
+			logger.FromContext(ctx).Error("Erro ao alterar papel do usuário", "error", err, "actor_id", actorID, "user_id", userID)
+			return nil, err
+		}
+	}
+
+	logger.FromContext(ctx).Warn("Papel de usuário alterado", "actor_id", actorID, "user_id", userID, "old_role", previous, "new_role", role)
+
+	if revokeSessions && s.authManager != nil {
+		if err := s.authManager.LogoutAll(ctx, strconv.FormatUint(uint64(id), 10)); err != nil {
+			logger.FromContext(ctx).Error("Erro ao revogar sessões após alteração de papel", "error", err, "user_id", userID)
+			return nil, err
+		}
+		logger.FromContext(ctx).Info("Sessões revogadas após alteração de papel", "actor_id", actorID, "user_id", userID)
+	}
+
+	return s.userRepository.FindByID(id)
+}
+
+// UpdateUsername renames userID on behalf of the admin actorID. The username
+// policy applies, except for reserved names, which only admins may assign.
+func (s *UserService) UpdateUsername(ctx context.Context, actorID, userID, username string) (*models.User, error) {
+	if err := s.usernamePolicy.Validate(username, true); err != nil {
+		return nil, err
+	}
+	id, err := s.userRepository.ResolveID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if err := s.userRepository.UpdateUsername(ctx, id, username); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, ErrUserNotFound
+		case errors.Is(err, repository.ErrUsernameTaken):
+			return nil, ErrUsernameTaken
+		default:
+			logger.FromContext(ctx).Error("Erro ao alterar nome de usuário", "error", err, "actor_id", actorID, "user_id", userID)
+			return nil, err
+		}
+	}
+
+	logger.FromContext(ctx).Warn("Nome de usuário alterado", "actor_id", actorID, "user_id", userID, "username", username)
+	return s.userRepository.FindByID(id)
+}
+
+// UpdateDisplayName sets the display name of userID on behalf of the admin
+// actorID
+func (s *UserService) UpdateDisplayName(ctx context.Context, actorID, userID, displayName string) (*models.User, error) {
+	id, err := s.userRepository.ResolveID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if err := s.userRepository.UpdateDisplayName(ctx, id, displayName); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		logger.FromContext(ctx).Error("Erro ao alterar nome de exibição", "error", err, "actor_id", actorID, "user_id", userID)
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("Nome de exibição alterado por administrador", "actor_id", actorID, "user_id", userID)
+	return s.userRepository.FindByID(id)
+}
+
+// SetActive enables or disables the account of userID on behalf of the admin
+// actorID. Disabled users can't log in and their sessions stop working at the
+// next request; with session revocation they are also logged out everywhere.
+// The last active admin can't be disabled (ErrDisableLastAdmin).
+func (s *UserService) SetActive(ctx context.Context, actorID, userID string, active bool) (*models.User, error) {
+	id, err := s.userRepository.ResolveID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if err := s.userRepository.SetActive(ctx, id, active); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, ErrUserNotFound
+		case errors.Is(err, repository.ErrLastAdmin):
+			logger.FromContext(ctx).Warn("Tentativa de desativar o último administrador", "actor_id", actorID, "user_id", userID)
+			return nil, ErrDisableLastAdmin
+		default:
+			logger.FromContext(ctx).Error("Erro ao alterar status da conta", "error", err, "actor_id", actorID, "user_id", userID)
+			return nil, err
+		}
+	}
+
+	logger.FromContext(ctx).Warn("Status da conta alterado", "actor_id", actorID, "user_id", userID, "active", active)
+
+	if !active && s.authManager != nil {
+		if err := s.authManager.LogoutAll(ctx, strconv.FormatUint(uint64(id), 10)); err != nil {
+			logger.FromContext(ctx).Error("Erro ao revogar sessões após desativar conta", "error", err, "user_id", userID)
+			return nil, err
+		}
+	}
+
+	return s.userRepository.FindByID(id)
+}
+
+// ExpirePassword forces userID to change their password, on behalf of the
+// admin actorID. Existing sessions stay valid but are limited to changing the
+// password until the user does so; revokeSessions logs the user out
+// everywhere instead, so the change happens at next login.
+func (s *UserService) ExpirePassword(ctx context.Context, actorID, userID string, revokeSessions bool) error {
+	id, err := s.userRepository.ResolveID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.userRepository.ExpirePassword(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		logger.FromContext(ctx).Error("Erro ao expirar senha do usuário", "error", err, "actor_id", actorID, "user_id", userID)
+		return err
+	}
+
+	logger.FromContext(ctx).Warn("Troca de senha obrigatória definida", "actor_id", actorID, "user_id", userID)
+
+	if revokeSessions && s.authManager != nil {
+		if err := s.authManager.LogoutAll(ctx, strconv.FormatUint(uint64(id), 10)); err != nil {
+			logger.FromContext(ctx).Error("Erro ao revogar sessões após expirar senha", "error", err, "user_id", userID)
+			return err
+		}
+		logger.FromContext(ctx).Info("Sessões revogadas após expirar senha", "actor_id", actorID, "user_id", userID)
+	}
+	return nil
+}
+
+// RestoreAccount cancels the scheduled deletion of userID on behalf of the
+// admin actorID, e.g. when the user lost the restore link. Returns
+// ErrNotScheduled if the account isn't scheduled for deletion.
+func (s *UserService) RestoreAccount(ctx context.Context, actorID, userID string) error {
+	id, err := s.userRepository.ResolveID(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.userRepository.CancelDeletion(ctx, id); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return ErrUserNotFound
+		case errors.Is(err, repository.ErrNotScheduled):
+			return ErrNotScheduled
+		default:
+			logger.FromContext(ctx).Error("Erro ao cancelar exclusão de conta", "error", err, "actor_id", actorID, "user_id", userID)
+			return err
+		}
+	}
+
+	logger.FromContext(ctx).Warn("Exclusão de conta cancelada por administrador", "actor_id", actorID, "user_id", userID)
+	return nil
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_UpdateRole(t *testing.T) {
+	_, authManager, _, sessionAdapter, _, db := setupTest(t)
+	userService := NewUserService(repository.NewUserRepository(db), WithSessionRevocation(authManager))
+	ctx := context.Background()
+
+	admin := createTestUser(t, db)
+	require.NoError(t, db.Model(admin).Update("role", auth.AdminRole).Error)
+	adminID := strconv.FormatUint(uint64(admin.ID), 10)
+
+	t.Run("InvalidRole", func(t *testing.T) {
+		_, err := userService.UpdateRole(ctx, adminID, adminID, "superuser", false)
+		assert.ErrorIs(t, err, ErrInvalidRole)
+	})
+
+	t.Run("LastAdmin", func(t *testing.T) {
+		// Admins can't demote themselves when nobody else is left
+		_, err := userService.UpdateRole(ctx, adminID, adminID, "user", false)
+		assert.ErrorIs(t, err, ErrLastAdmin)
+	})
+
+	t.Run("UnknownUser", func(t *testing.T) {
+		_, err := userService.UpdateRole(ctx, adminID, "9999", "admin", false)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+
+	t.Run("RevokeSessions", func(t *testing.T) {
+		session, _, err := authManager.Login(ctx, "testuser", "password123", auth.SessionMetadata{})
+		require.NoError(t, err)
+
+		user, err := userService.UpdateRole(ctx, adminID, adminID, auth.AdminRole, true)
+		require.NoError(t, err)
+		assert.Equal(t, auth.AdminRole, user.Role)
+
+		_, err = sessionAdapter.GetSession(ctx, session.ID)
+		assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+	})
+}
+
+func TestUserService_UpdateUsername(t *testing.T) {
+	_, _, _, _, _, db := setupTest(t)
+	userService := NewUserService(repository.NewUserRepository(db))
+	ctx := context.Background()
+
+	user := createTestUser(t, db)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	other := &models.User{Username: "bob", Email: "bob@example.com", PasswordHash: "hash", Active: true, Role: "user"}
+	require.NoError(t, db.Create(other).Error)
+
+	// Admins may assign reserved names
+	updated, err := userService.UpdateUsername(ctx, "1", userID, "support")
+	require.NoError(t, err)
+	assert.Equal(t, "support", updated.Username)
+
+	_, err = userService.UpdateUsername(ctx, "1", userID, "a b")
+	assert.ErrorIs(t, err, ErrInvalidUsername)
+
+	_, err = userService.UpdateUsername(ctx, "1", userID, "bob")
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+
+	_, err = userService.UpdateUsername(ctx, "1", "9999", "carol")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestUserService_RestoreAccount(t *testing.T) {
+	authService, _, _, _, _, db := setupTest(t)
+	userService := NewUserService(repository.NewUserRepository(db))
+	ctx := context.Background()
+
+	user := createTestUser(t, db)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+
+	assert.ErrorIs(t, userService.RestoreAccount(ctx, "admin", userID), ErrNotScheduled)
+	assert.ErrorIs(t, userService.RestoreAccount(ctx, "admin", "9999"), ErrUserNotFound)
+
+	_, err := authService.DeleteAccount(ctx, userID, "password123")
+	require.NoError(t, err)
+	_, err = authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test")
+	require.ErrorIs(t, err, ErrAccountPendingDeletion)
+
+	require.NoError(t, userService.RestoreAccount(ctx, "admin", userID))
+	_, err = authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test")
+	assert.NoError(t, err)
+
+	require.NoError(t, db.First(user, user.ID).Error)
+	assert.Nil(t, user.ScheduledDeletionAt)
+	assert.Empty(t, user.RestoreToken)
+}
+
+func TestUserService_CreateUser(t *testing.T) {
+	authService, _, _, _, _, db := setupTest(t)
+	userService := NewUserService(repository.NewUserRepository(db), WithAllowedRoles("user", auth.AdminRole))
+	ctx := context.Background()
+	createTestUser(t, db)
+
+	input := CreateUserInput{Username: "support", Email: "support@example.com", DisplayName: "Support", Password: "Str0ng!Pass", MustChangePassword: true, EmailVerified: true}
+	user, err := userService.CreateUser(ctx, "1", input)
+	require.NoError(t, err)
+	assert.Equal(t, "user", user.Role, "the role defaults to user")
+	assert.True(t, user.Active)
+	assert.True(t, user.MustChangePassword)
+	assert.NotNil(t, user.EmailVerifiedAt)
+	assert.NotEqual(t, input.Password, user.PasswordHash)
+
+	// The new user logs in with the password the admin set
+	_, err = authService.Login(ctx, "support", "Str0ng!Pass", "127.0.0.1", "test")
+	assert.NoError(t, err)
+
+	_, err = userService.CreateUser(ctx, "1", CreateUserInput{Username: "root", Email: "root@example.com", DisplayName: "Root", Password: "Str0ng!Pass", Role: "superuser"})
+	assert.ErrorIs(t, err, ErrInvalidRole)
+	_, err = userService.CreateUser(ctx, "1", CreateUserInput{Username: "a b", Email: "ab@example.com", DisplayName: "AB", Password: "Str0ng!Pass"})
+	assert.ErrorIs(t, err, ErrInvalidUsername)
+	_, err = userService.CreateUser(ctx, "1", CreateUserInput{Username: "testuser", Email: "new@example.com", DisplayName: "New", Password: "Str0ng!Pass"})
+	assert.ErrorIs(t, err, ErrUsernameTaken)
+	_, err = userService.CreateUser(ctx, "1", CreateUserInput{Username: "newuser", Email: "support@example.com", DisplayName: "New", Password: "Str0ng!Pass"})
+	assert.ErrorIs(t, err, ErrEmailTaken)
+}
+
+func TestUserService_UpdateDisplayName(t *testing.T) {
+	_, _, _, _, _, db := setupTest(t)
+	userService := NewUserService(repository.NewUserRepository(db))
+	ctx := context.Background()
+
+	user := createTestUser(t, db)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+
+	updated, err := userService.UpdateDisplayName(ctx, "1", userID, "Renamed")
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", updated.DisplayName)
+
+	_, err = userService.UpdateDisplayName(ctx, "1", "9999", "Renamed")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestUserService_SetActive(t *testing.T) {
+	authService, authManager, _, sessionAdapter, _, db := setupTest(t)
+	userService := NewUserService(repository.NewUserRepository(db), WithSessionRevocation(authManager))
+	ctx := context.Background()
+
+	user := createTestUser(t, db)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+
+	session, _, err := authManager.Login(ctx, "testuser", "password123", auth.SessionMetadata{})
+	require.NoError(t, err)
+
+	// Disabling logs the user out and blocks new logins
+	disabled, err := userService.SetActive(ctx, "1", userID, false)
+	require.NoError(t, err)
+	assert.False(t, disabled.Active)
+	_, err = sessionAdapter.GetSession(ctx, session.ID)
+	assert.ErrorIs(t, err, auth.ErrSessionNotFound)
+	_, err = authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test")
+	assert.Error(t, err)
+
+	enabled, err := userService.SetActive(ctx, "1", userID, true)
+	require.NoError(t, err)
+	assert.True(t, enabled.Active)
+	_, err = authService.Login(ctx, "testuser", "password123", "127.0.0.1", "test")
+	assert.NoError(t, err)
+
+	// The last active admin stays enabled
+	require.NoError(t, db.Model(user).Update("role", auth.AdminRole).Error)
+	_, err = userService.SetActive(ctx, "1", userID, false)
+	assert.ErrorIs(t, err, ErrDisableLastAdmin)
+
+	_, err = userService.SetActive(ctx, "1", "9999", false)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
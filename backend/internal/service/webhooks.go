@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+
+	"gosveltekit/internal/audit"
+	"gosveltekit/internal/metrics"
+	"gosveltekit/internal/webhooks"
+)
+
+// Reasons of the session.revoked webhook
+const (
+	RevokedByLogout       = "logout"
+	RevokedByLogoutAll    = "logout_all"
+	RevokedByLogoutOthers = "logout_others"
+	RevokedByClient       = "revoke"
+)
+
+// WithWebhooks makes the service publish user.registered, login.failed and
+// session.revoked events to publisher. Session IDs are never included: they
+// are bearer credentials.
+func WithWebhooks(publisher webhooks.Publisher) Option {
+	return func(s *AuthService) {
+		s.webhooks = publisher
+	}
+}
+
+func (s *AuthService) publish(ctx context.Context, event string, data map[string]any) {
+	if s.webhooks != nil {
+		s.webhooks.Publish(ctx, event, data)
+	}
+}
+
+// loginFailed counts a failed login for reason (one of the metrics.Reason
+// constants), publishes and records it
+func (s *AuthService) loginFailed(ctx context.Context, identifier, ip, reason string) {
+	metrics.LoginFailures.WithLabelValues(reason).Inc()
+	s.publish(ctx, webhooks.EventLoginFailed, map[string]any{
+		"identifier": identifier,
+		"ip":         ip,
+		"reason":     reason,
+	})
+	s.record(ctx, audit.Event{
+		Type: audit.EventLoginFailed,
+		IP:   ip,
+		Data: map[string]any{"identifier": identifier, "reason": reason},
+	})
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"strconv"
+
+	"gosveltekit/internal/auth"
+	gormadapter "gosveltekit/internal/auth/adapter/gorm"
+	"gosveltekit/internal/email"
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+	"gosveltekit/internal/outbox"
+)
+
+// Welcome email triggers
+const (
+	WelcomeOnRegister     = "register"
+	WelcomeOnVerification = "verification"
+)
+
+// WithWelcomeEmail enables the welcome email, sent on registration or when the
+// user's email is verified depending on trigger. With WithJobs the email is
+// queued as a job; otherwise it is sent in the background so it never delays
+// the response.
+func WithWelcomeEmail(trigger string) Option {
+	return func(s *AuthService) {
+		s.welcomeTrigger = trigger
+	}
+}
+
+// WithStrictRegistrationEmail makes Register fail with ErrRegistrationEmail,
+// creating no user, when the WelcomeOnRegister or verification email can't be
+// sent. By default the account is kept: the user starts unverified, the
+// failure is logged and Register reports the verification link as not sent.
+// These emails are then sent during the request, even with WithJobs.
+func WithStrictRegistrationEmail() Option {
+	return func(s *AuthService) {
+		s.strictRegistrationEmail = true
+	}
+}
+
+// createUserWithEmails creates the user and sends the welcome and/or
+// verification email in the same transaction, so a failed send leaves no
+// account behind
+func (s *AuthService) createUserWithEmails(ctx context.Context, input auth.CreateUserInput, welcome, verification bool) (*auth.UserData, error) {
+	var userData *auth.UserData
+	err := s.userAdapter.Transaction(ctx, func(tx *gormadapter.UserAdapter) error {
+		created, err := tx.CreateUser(ctx, input)
+		if err != nil {
+			return err
+		}
+		if welcome {
+			if err := s.emailService.SendWelcomeEmail(ctx, created.Email, created.Identifier, welcomeDisplayName(created.DisplayName, created.Identifier)); err != nil {
+				logger.FromContext(ctx).Error("Erro ao enviar email de cadastro, cadastro desfeito", "error", err, "email", created.Email, "username", created.Identifier)
+				return ErrRegistrationEmail
+			}
+		}
+		if verification {
+			user, err := tx.GetUserModel(ctx, created.ID)
+			if err != nil {
+				return err
+			}
+			to, token, username, displayName := s.verificationEmail(user)
+			if err := s.emailService.SendVerificationEmail(ctx, to, token, username, displayName); err != nil {
+				logger.FromContext(ctx).Error("Erro ao enviar email de verificação, cadastro desfeito", "error", err, "email", user.Email, "username", user.Username)
+				return ErrRegistrationEmail
+			}
+		}
+		userData = created
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.FromContext(ctx).Info("Emails de cadastro enviados", "email", userData.Email, "user_id", userData.ID)
+	return userData, nil
+}
+
+// createUserWithQueuedEmails creates the user and queues the welcome and/or
+// verification email atomically
+func (s *AuthService) createUserWithQueuedEmails(ctx context.Context, input auth.CreateUserInput, welcome, verification bool) (*auth.UserData, error) {
+	var userData *auth.UserData
+	err := s.userAdapter.Transaction(ctx, func(tx *gormadapter.UserAdapter) error {
+		created, err := tx.CreateUser(ctx, input)
+		if err != nil {
+			return err
+		}
+		queue := s.jobs.InTx(tx.DB())
+		if welcome {
+			if err := outbox.EnqueueWelcome(ctx, queue, created.Email, created.Identifier, welcomeDisplayName(created.DisplayName, created.Identifier)); err != nil {
+				return err
+			}
+		}
+		if verification {
+			user, err := tx.GetUserModel(ctx, created.ID)
+			if err != nil {
+				return err
+			}
+			to, token, username, displayName := s.verificationEmail(user)
+			if err := outbox.EnqueueEmailVerification(ctx, queue, to, token, username, displayName); err != nil {
+				return err
+			}
+		}
+		userData = created
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return userData, nil
+}
+
+// MarkEmailVerified marks the user's email as verified. With the
+// WelcomeOnVerification trigger, the first verification sends the welcome email.
+func (s *AuthService) MarkEmailVerified(ctx context.Context, userID string) error {
+	user, err := s.userAdapter.GetUserModel(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+	ctx = email.WithLocale(ctx, user.Locale)
+
+	now := s.clock.Now()
+	user.EmailVerified = true
+	user.EmailVerifiedAt = &now
+	welcome := s.welcomeTrigger == WelcomeOnVerification
+	if welcome && s.jobs != nil {
+		// Mark verified and queue the welcome email atomically
+		return s.userAdapter.Transaction(ctx, func(tx *gormadapter.UserAdapter) error {
+			if err := tx.UpdateUser(ctx, user); err != nil {
+				return err
+			}
+			return outbox.EnqueueWelcome(ctx, s.jobs.InTx(tx.DB()), user.Email, user.Username, welcomeDisplayName(user.DisplayName, user.Username))
+		})
+	}
+
+	if err := s.userAdapter.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	if welcome {
+		s.sendWelcomeEmail(ctx, user)
+	}
+	return nil
+}
+
+// sendWelcomeEmail delivers the welcome email in the background, keeping the
+// request ID of ctx but not its cancellation. Callers using
+// the job queue enqueue it in their own transaction instead.
+func (s *AuthService) sendWelcomeEmail(ctx context.Context, user *models.User) {
+	ctx = email.WithLocale(context.WithoutCancel(ctx), user.Locale)
+	to := user.Email
+	username := user.Username
+	displayName := welcomeDisplayName(user.DisplayName, user.Username)
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+
+	go func() {
+		if err := s.emailService.SendWelcomeEmail(ctx, to, username, displayName); err != nil {
+			logger.FromContext(ctx).Error("Erro ao enviar email de boas-vindas", "error", err, "email", to, "user_id", userID)
+			return
+		}
+		logger.FromContext(ctx).Info("Email de boas-vindas enviado", "email", to, "user_id", userID)
+	}()
+}
+
+func welcomeDisplayName(displayName, username string) string {
+	if displayName == "" {
+		return username
+	}
+	return displayName
+}
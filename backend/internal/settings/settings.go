@@ -0,0 +1,201 @@
+// Package settings lets admins toggle a few runtime settings without a
+// redeploy.
+//
+// Only the keys listed in Keys can be overridden, and only with boolean
+// values: everything else stays in the config file. Overrides live in the
+// settings table; the config provides the value of every key without one.
+//
+// Store caches the table in memory so features can read settings on every
+// request. Set updates the cache of the instance that handled it right away;
+// other instances pick the change up on their next Refresh, which Run does
+// every RefreshInterval.
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gosveltekit/internal/logger"
+	"gosveltekit/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Keys of the settings that can be overridden at runtime
+const (
+	KeyMaintenanceMode     = "maintenance_mode"
+	KeyRegistrationEnabled = "registration_enabled"
+	KeyNotifyNewDevice     = "notify_new_device"
+)
+
+// Keys lists every setting that can be overridden
+var Keys = []string{KeyMaintenanceMode, KeyRegistrationEnabled, KeyNotifyNewDevice}
+
+// DefaultRefreshInterval is used when Config.RefreshInterval is not set
+const DefaultRefreshInterval = 30 * time.Second
+
+// ErrUnknownKey is returned by Set for keys not listed in Keys
+var ErrUnknownKey = errors.New("configuração desconhecida")
+
+// Config configures a Store
+type Config struct {
+	// Defaults holds the value of each key without an override, usually
+	// taken from the config file. Missing keys default to false.
+	Defaults        map[string]bool
+	RefreshInterval time.Duration // Default: DefaultRefreshInterval
+}
+
+// Setting is the effective value of a key
+type Setting struct {
+	Key        string     `json:"key"`
+	Value      bool       `json:"value"`
+	Default    bool       `json:"default"`
+	Overridden bool       `json:"overridden"`
+	UpdatedBy  string     `json:"updated_by,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+// Store serves settings from an in-memory cache of the settings table
+type Store struct {
+	db     *gorm.DB
+	config Config
+
+	mu        sync.RWMutex
+	overrides map[string]models.Setting
+}
+
+// NewStore creates a Store with an empty cache. Call Refresh to load the
+// overrides before serving requests.
+func NewStore(db *gorm.DB, config Config) *Store {
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = DefaultRefreshInterval
+	}
+	return &Store{db: db, config: config, overrides: map[string]models.Setting{}}
+}
+
+// Bool returns the value of key: its override, if any, or its default
+func (s *Store) Bool(key string) bool {
+	s.mu.RLock()
+	override, ok := s.overrides[key]
+	s.mu.RUnlock()
+	if ok {
+		// Values are validated by Set, rows edited by hand fall back below
+		if value, err := strconv.ParseBool(override.Value); err == nil {
+			return value
+		}
+	}
+	return s.config.Defaults[key]
+}
+
+// All returns the effective value of every key, sorted by key
+func (s *Store) All() []Setting {
+	keys := append([]string{}, Keys...)
+	sort.Strings(keys)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	all := make([]Setting, 0, len(keys))
+	for _, key := range keys {
+		setting := Setting{Key: key, Default: s.config.Defaults[key]}
+		setting.Value = setting.Default
+		if override, ok := s.overrides[key]; ok {
+			if value, err := strconv.ParseBool(override.Value); err == nil {
+				updatedAt := override.UpdatedAt
+				setting.Value = value
+				setting.Overridden = true
+				setting.UpdatedBy = override.UpdatedBy
+				setting.UpdatedAt = &updatedAt
+			}
+		}
+		all = append(all, setting)
+	}
+	return all
+}
+
+// Set stores an override for key and applies it to this instance's cache
+// right away. updatedBy identifies the admin making the change.
+func (s *Store) Set(ctx context.Context, key string, value bool, updatedBy string) (Setting, error) {
+	if !isKnown(key) {
+		return Setting{}, fmt.Errorf("%w: %s", ErrUnknownKey, key)
+	}
+
+	row := models.Setting{Key: key, Value: strconv.FormatBool(value), UpdatedBy: updatedBy}
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by", "updated_at"}),
+	}).Create(&row).Error
+	if err != nil {
+		return Setting{}, err
+	}
+
+	s.mu.Lock()
+	s.overrides[key] = row
+	s.mu.Unlock()
+
+	updatedAt := row.UpdatedAt
+	return Setting{
+		Key:        key,
+		Value:      value,
+		Default:    s.config.Defaults[key],
+		Overridden: true,
+		UpdatedBy:  updatedBy,
+		UpdatedAt:  &updatedAt,
+	}, nil
+}
+
+// Refresh reloads the overrides from the database. On error the cache is
+// left untouched.
+func (s *Store) Refresh(ctx context.Context) error {
+	var rows []models.Setting
+	if err := s.db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	overrides := make(map[string]models.Setting, len(rows))
+	for _, row := range rows {
+		if !isKnown(row.Key) {
+			continue
+		}
+		if _, err := strconv.ParseBool(row.Value); err != nil {
+			logger.Warn("Valor inválido na tabela settings, usando o padrão", "key", row.Key, "value", row.Value)
+		}
+		overrides[row.Key] = row
+	}
+
+	s.mu.Lock()
+	s.overrides = overrides
+	s.mu.Unlock()
+	return nil
+}
+
+// Run refreshes the cache every RefreshInterval until ctx is cancelled
+func (s *Store) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				logger.Error("Erro ao recarregar configurações", "error", err)
+			}
+		}
+	}
+}
+
+func isKnown(key string) bool {
+	for _, known := range Keys {
+		if key == known {
+			return true
+		}
+	}
+	return false
+}
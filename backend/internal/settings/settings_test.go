@@ -0,0 +1,82 @@
+// Package settings tests
+package settings
+
+import (
+	"context"
+	"testing"
+
+	"gosveltekit/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Setting{}))
+	return db
+}
+
+func TestStore(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	config := Config{Defaults: map[string]bool{KeyRegistrationEnabled: true}}
+	store := NewStore(db, config)
+	other := NewStore(db, config)
+
+	// Defaults from the config
+	assert.True(t, store.Bool(KeyRegistrationEnabled))
+	assert.False(t, store.Bool(KeyMaintenanceMode))
+
+	setting, err := store.Set(ctx, KeyMaintenanceMode, true, "1")
+	require.NoError(t, err)
+	assert.True(t, setting.Value)
+	assert.False(t, setting.Default)
+	assert.True(t, setting.Overridden)
+	assert.True(t, store.Bool(KeyMaintenanceMode), "the instance that set it applies it right away")
+	assert.False(t, other.Bool(KeyMaintenanceMode), "other instances wait for a refresh")
+
+	require.NoError(t, other.Refresh(ctx))
+	assert.True(t, other.Bool(KeyMaintenanceMode))
+
+	// Overriding again updates the row
+	_, err = other.Set(ctx, KeyMaintenanceMode, false, "2")
+	require.NoError(t, err)
+	require.NoError(t, store.Refresh(ctx))
+	assert.False(t, store.Bool(KeyMaintenanceMode))
+
+	var count int64
+	require.NoError(t, db.Model(&models.Setting{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	all := store.All()
+	require.Len(t, all, len(Keys))
+	for _, s := range all {
+		switch s.Key {
+		case KeyMaintenanceMode:
+			assert.True(t, s.Overridden)
+			assert.False(t, s.Value)
+			assert.Equal(t, "2", s.UpdatedBy)
+		case KeyRegistrationEnabled:
+			assert.False(t, s.Overridden)
+			assert.True(t, s.Value)
+		}
+	}
+
+	_, err = store.Set(ctx, "jwt_secret", true, "1")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestStore_InvalidRowFallsBackToDefault(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Create(&models.Setting{Key: KeyRegistrationEnabled, Value: "maybe"}).Error)
+	require.NoError(t, db.Create(&models.Setting{Key: "unknown", Value: "true"}).Error)
+
+	store := NewStore(db, Config{Defaults: map[string]bool{KeyRegistrationEnabled: true}})
+	require.NoError(t, store.Refresh(context.Background()))
+	assert.True(t, store.Bool(KeyRegistrationEnabled))
+	assert.False(t, store.Bool("unknown"))
+}
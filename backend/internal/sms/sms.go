@@ -0,0 +1,128 @@
+// Package sms sends text messages, used for phone verification and SMS-based
+// two-factor authentication.
+//
+// TwilioSender talks to Twilio's Messages API. NoopSender doesn't send
+// anything and logs the message instead, so codes can be read from the
+// console in development.
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gosveltekit/internal/logger"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Supported providers
+const (
+	ProviderNone   = "none"
+	ProviderTwilio = "twilio"
+)
+
+// TwilioAPIURL is the base URL of the Twilio REST API
+const TwilioAPIURL = "https://api.twilio.com/2010-04-01"
+
+var (
+	// ErrUnknownProvider is returned by New for unsupported providers
+	ErrUnknownProvider = errors.New("provedor de SMS desconhecido")
+	// ErrNotConfigured is returned by New when the provider credentials are missing
+	ErrNotConfigured = errors.New("provedor de SMS sem credenciais configuradas")
+)
+
+// Sender sends a text message to a phone number in E.164 format
+type Sender interface {
+	Send(ctx context.Context, to, message string) error
+}
+
+// TwilioConfig holds the Twilio credentials and sender number
+type TwilioConfig struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+}
+
+// New returns the sender for provider. An empty provider or ProviderNone
+// returns a NoopSender.
+func New(provider string, twilio TwilioConfig) (Sender, error) {
+	switch provider {
+	case "", ProviderNone:
+		return NoopSender{}, nil
+	case ProviderTwilio:
+		if twilio.AccountSID == "" || twilio.AuthToken == "" || twilio.From == "" {
+			return nil, fmt.Errorf("%w: %s", ErrNotConfigured, provider)
+		}
+		return NewTwilioSender(twilio), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+}
+
+// NoopSender logs messages instead of sending them
+type NoopSender struct{}
+
+// Send implements Sender
+func (NoopSender) Send(ctx context.Context, to, message string) error {
+	logger.Info("SMS não enviado (provedor desativado)", "to", to, "message", message)
+	return nil
+}
+
+// TwilioSender sends messages through the Twilio Messages API
+type TwilioSender struct {
+	APIURL string
+	Config TwilioConfig
+	Client *http.Client
+}
+
+// NewTwilioSender creates a TwilioSender with a 10 second HTTP timeout
+func NewTwilioSender(config TwilioConfig) *TwilioSender {
+	return &TwilioSender{
+		APIURL: TwilioAPIURL,
+		Config: config,
+		Client: &http.Client{Timeout: 10 * time.Second, Transport: otelhttp.NewTransport(nil)},
+	}
+}
+
+// twilioError is the error body returned by the Twilio API
+type twilioError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Send implements Sender
+func (s *TwilioSender) Send(ctx context.Context, to, message string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", s.Config.From)
+	form.Set("Body", message)
+
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", strings.TrimRight(s.APIURL, "/"), url.PathEscape(s.Config.AccountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.Config.AccountSID, s.Config.AuthToken)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro ao contatar o Twilio: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var body twilioError
+		if err := json.NewDecoder(resp.Body).Decode(&body); err == nil && body.Message != "" {
+			return fmt.Errorf("twilio respondeu com status %d: %s (código %d)", resp.StatusCode, body.Message, body.Code)
+		}
+		return fmt.Errorf("twilio respondeu com status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package sms
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwilioSender(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/Accounts/AC123/Messages.json", r.URL.Path)
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "AC123", user)
+		assert.Equal(t, "token", pass)
+
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "+15550000000", r.PostForm.Get("From"))
+		assert.Equal(t, "hello", r.PostForm.Get("Body"))
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("To") == "+15551111111" {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"sid": "SM1"}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code": 21211, "message": "Invalid 'To' Phone Number"}`))
+	}))
+	defer server.Close()
+
+	sender := NewTwilioSender(TwilioConfig{AccountSID: "AC123", AuthToken: "token", From: "+15550000000"})
+	sender.APIURL = server.URL
+	ctx := context.Background()
+
+	assert.NoError(t, sender.Send(ctx, "+15551111111", "hello"))
+	assert.ErrorContains(t, sender.Send(ctx, "+15552222222", "hello"), "Invalid 'To' Phone Number")
+}
+
+func TestNew(t *testing.T) {
+	sender, err := New("", TwilioConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, NoopSender{}, sender)
+	assert.NoError(t, sender.Send(context.Background(), "+15551111111", "hello"))
+
+	_, err = New(ProviderTwilio, TwilioConfig{AccountSID: "AC123"})
+	assert.ErrorIs(t, err, ErrNotConfigured)
+
+	sender, err = New(ProviderTwilio, TwilioConfig{AccountSID: "AC123", AuthToken: "token", From: "+15550000000"})
+	require.NoError(t, err)
+	assert.Equal(t, TwilioAPIURL, sender.(*TwilioSender).APIURL)
+
+	_, err = New("carrier-pigeon", TwilioConfig{})
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
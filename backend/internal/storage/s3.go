@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"gosveltekit/internal/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3MaxPresignTTL is the longest validity S3 accepts for presigned URLs
+const s3MaxPresignTTL = 7 * 24 * time.Hour
+
+// S3 stores files in an S3-compatible bucket through minio-go
+type S3 struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3 creates an S3 from the storage.s3 section. The endpoint is a scheme
+// and host: buckets don't live under a path.
+func NewS3(cfg config.S3StorageConfig) (*S3, error) {
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || endpoint.Path != "" {
+		return nil, fmt.Errorf("endpoint S3 inválido: %q", cfg.Endpoint)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	lookup := minio.BucketLookupDNS
+	if cfg.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure:       endpoint.Scheme == "https",
+		Region:       region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("endpoint S3 inválido: %w", err)
+	}
+	// Keep the configured host rather than AWS's dual-stack one
+	client.SetS3EnableDualstack(false)
+	return &S3{client: client, bucket: cfg.Bucket}, nil
+}
+
+// Put uploads the file with PUT Object. The body isn't hashed, so uploads are
+// streamed; TLS protects it in transit.
+func (s *S3) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, body, size, minio.PutObjectOptions{
+		ContentType:          contentType,
+		DisableContentSha256: true,
+	})
+	if err != nil {
+		return fmt.Errorf("S3 PUT %s: %w", key, err)
+	}
+	return nil
+}
+
+// Get downloads the file with GET Object
+func (s *S3) Get(ctx context.Context, key string) (*Object, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("S3 GET %s: %w", key, err)
+	}
+	// GetObject is lazy: Stat sends the request
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("S3 GET %s: %w", key, err)
+	}
+	return &Object{Body: object, ContentType: info.ContentType, Size: info.Size}, nil
+}
+
+// Delete removes the file with DELETE Object
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("S3 DELETE %s: %w", key, err)
+	}
+	return nil
+}
+
+// SignedURL returns a presigned GET URL, valid for at most 7 days
+func (s *S3) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	if ttl > s3MaxPresignTTL {
+		ttl = s3MaxPresignTTL
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, ttl, nil)
+	if err != nil {
+		return "", fmt.Errorf("S3 presign %s: %w", key, err)
+	}
+	return u.String(), nil
+}
+
+// isNotFound reports whether err is S3's answer for a missing object
+func isNotFound(err error) bool {
+	return minio.ToErrorResponse(err).Code == "NoSuchKey"
+}
@@ -0,0 +1,89 @@
+// Package storage keeps uploaded files, such as avatars, on the local disk
+// (Local) or in an S3-compatible bucket (S3: AWS S3, MinIO, Cloudflare R2...),
+// selected by the storage section of the config.
+//
+// Files are private. Clients download them through SignedURL, which stops
+// working once its TTL passes.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/logger"
+)
+
+var (
+	ErrNotFound         = errors.New("arquivo não encontrado")
+	ErrInvalidKey       = errors.New("chave de arquivo inválida")
+	ErrInvalidSignature = errors.New("link de arquivo inválido ou expirado")
+)
+
+// Object is a stored file opened by Get. The caller closes Body.
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+// Storage stores files under keys: slash-separated relative paths such as
+// "avatars/42/3f9c.jpg" (see ValidateKey)
+type Storage interface {
+	// Put stores the size bytes of body under key, replacing any file there
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get opens the file stored under key, ErrNotFound if there is none
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete removes the file stored under key. Deleting a missing file is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that downloads the file without credentials
+	// until ttl passes
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// ValidateKey checks that key is a relative path of non-empty segments made
+// of letters, digits, ".", "-" and "_", none of them "." or "..", so it can't
+// escape the storage directory or need escaping in URLs
+func ValidateKey(key string) error {
+	if key == "" || len(key) > 512 {
+		return ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return ErrInvalidKey
+		}
+		for _, r := range segment {
+			if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '.' && r != '-' && r != '_' {
+				return ErrInvalidKey
+			}
+		}
+	}
+	return nil
+}
+
+// New creates the Storage selected by cfg.Driver. localURL is where the files
+// route serving Local is reachable (e.g. "https://api.example.com/files").
+func New(cfg config.StorageConfig, localURL string) (Storage, error) {
+	switch cfg.Driver {
+	case config.StorageDriverLocal:
+		secret := []byte(cfg.Local.URLSecret)
+		if len(secret) == 0 {
+			logger.Warn("storage.local.url_secret vazio: links de arquivos deixam de valer ao reiniciar e não funcionam entre instâncias")
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, fmt.Errorf("falha ao gerar chave dos links de arquivos: %w", err)
+			}
+		}
+		return NewLocal(cfg.Local.Dir, localURL, secret), nil
+	case config.StorageDriverS3:
+		return NewS3(cfg.S3)
+	default:
+		return nil, fmt.Errorf("driver de armazenamento desconhecido: %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,29 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// TraceParentHeader carries the trace context between services, see
+// https://www.w3.org/TR/trace-context/
+const TraceParentHeader = "traceparent"
+
+// TraceParent returns the traceparent of the span carried by ctx, or "" if
+// there is none. Store it with queued work to continue the trace later with
+// ContextWithTraceParent.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get(TraceParentHeader)
+}
+
+// ContextWithTraceParent returns ctx with value as the remote parent of the
+// next span started. An empty or malformed value leaves ctx as is.
+func ContextWithTraceParent(ctx context.Context, value string) context.Context {
+	if value == "" {
+		return ctx
+	}
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{TraceParentHeader: value})
+}
@@ -0,0 +1,104 @@
+// Package telemetry sets up OpenTelemetry tracing: requests (otelgin),
+// database queries (the GORM plugin), emails, jobs and outgoing HTTP calls
+// (otelhttp) are exported to a collector over OTLP/HTTP.
+//
+// The trace context travels in the W3C traceparent header, so traces continue
+// across services that speak it. Until Setup is called, the global tracer
+// provider is a no-op.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"gosveltekit/internal/config"
+	"gosveltekit/internal/logger"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the spans started by this module
+const instrumentationName = "gosveltekit"
+
+// Tracer returns the tracer of the spans started by this module (jobs,
+// emails). It follows the global provider, so it may be taken before Setup.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// RecordError records err on span and marks the operation as failed, if err
+// is not nil
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Setup installs the global tracer provider and propagator as cfg describes
+// and returns the function that flushes the queued spans and stops. With
+// telemetry disabled it does nothing.
+func Setup(cfg config.TelemetryConfig) (func(context.Context) error, error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	headers, err := parseHeaders(cfg.Headers)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.Endpoint, "/")+"/v1/traces"),
+		otlptracehttp.WithHeaders(headers),
+	)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Traces continued from a traceparent follow the decision of the caller
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	// Spans are not worth retrying at the cost of memory, failures are logged
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warn("Falha ao exportar traces", "error", err)
+	}))
+
+	return provider.Shutdown, nil
+}
+
+// parseHeaders parses "key=value,key2=value2", the format of
+// OTEL_EXPORTER_OTLP_HEADERS
+func parseHeaders(value string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, errors.New("cabeçalho inválido em telemetry.headers: use chave=valor separados por vírgula")
+		}
+		headers[http.CanonicalHeaderKey(key)] = strings.TrimSpace(val)
+	}
+	return headers, nil
+}
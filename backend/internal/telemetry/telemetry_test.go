@@ -0,0 +1,124 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"gosveltekit/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// setupCollector sets telemetry up against a fake OTLP/HTTP endpoint,
+// returning the function that shuts it down and lists the spans received
+func setupCollector(t *testing.T, sampleRatio float64) func() []*tracepb.Span {
+	t.Helper()
+	var mu sync.Mutex
+	var spans []*tracepb.Span
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "Bearer otel", r.Header.Get("Authorization"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		var req coltracepb.ExportTraceServiceRequest
+		assert.NoError(t, proto.Unmarshal(body, &req))
+		mu.Lock()
+		defer mu.Unlock()
+		for _, resource := range req.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				spans = append(spans, scope.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	previous := otel.GetTracerProvider()
+	shutdown, err := Setup(config.TelemetryConfig{
+		Enabled:     true,
+		Endpoint:    server.URL,
+		ServiceName: "test",
+		SampleRatio: sampleRatio,
+		Headers:     "authorization=Bearer otel",
+	})
+	require.NoError(t, err)
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { require.NoError(t, shutdown(context.Background())) })
+	}
+	t.Cleanup(func() {
+		stop()
+		otel.SetTracerProvider(previous)
+	})
+
+	return func() []*tracepb.Span {
+		stop()
+		mu.Lock()
+		defer mu.Unlock()
+		return spans
+	}
+}
+
+func TestSetup_Disabled(t *testing.T) {
+	shutdown, err := Setup(config.TelemetryConfig{})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(config.TelemetryConfig{Enabled: true, Endpoint: "http://localhost:4318", Headers: "invalid"})
+	assert.Error(t, err)
+}
+
+func TestTraceParent(t *testing.T) {
+	valid := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := ContextWithTraceParent(context.Background(), valid)
+	assert.Equal(t, valid, TraceParent(ctx))
+	sc := trace.SpanContextFromContext(ctx)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+	assert.True(t, sc.IsSampled())
+	assert.True(t, sc.IsRemote())
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		assert.Empty(t, TraceParent(ContextWithTraceParent(context.Background(), invalid)), invalid)
+	}
+}
+
+func TestSetup_Exports(t *testing.T) {
+	spans := setupCollector(t, 0)
+
+	_, dropped := Tracer().Start(context.Background(), "dropped")
+	assert.False(t, dropped.SpanContext().IsSampled())
+	dropped.End()
+
+	// The caller's decision wins over the ratio
+	ctx := ContextWithTraceParent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, kept := Tracer().Start(ctx, "kept")
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+kept.SpanContext().SpanID().String()+"-01", TraceParent(ctx))
+	RecordError(kept, errors.New("boom"))
+	RecordError(kept, nil)
+	kept.End()
+
+	exported := spans()
+	require.Len(t, exported, 1)
+	assert.Equal(t, "kept", exported[0].Name)
+	assert.Equal(t, "00f067aa0ba902b7", hex.EncodeToString(exported[0].ParentSpanId))
+	assert.Equal(t, tracepb.Status_STATUS_CODE_ERROR, exported[0].Status.Code)
+	assert.Equal(t, "boom", exported[0].Status.Message)
+}
@@ -0,0 +1,72 @@
+// Package tenant scopes users to tenants in multi-tenant deployments.
+//
+// Multi-tenancy is off by default: every user belongs to the empty tenant, so
+// emails and usernames are unique across the whole application. With
+// SetEnabled(true) the tenant resolved for the request (see middleware.Tenant)
+// travels in the request context, and Scope restricts user lookups to it.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidID is returned by Validate for malformed tenant IDs
+var ErrInvalidID = errors.New("tenant inválido")
+
+// idPattern is a DNS label, so any tenant can also be served from a subdomain
+var idPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+var enabled atomic.Bool
+
+// SetEnabled turns multi-tenancy on or off
+func SetEnabled(on bool) {
+	enabled.Store(on)
+}
+
+// Enabled reports whether multi-tenancy is on
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Validate checks that id is a lowercase DNS label
+func Validate(id string) error {
+	if !idPattern.MatchString(id) {
+		return ErrInvalidID
+	}
+	return nil
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx carrying the tenant of the request
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext returns the tenant stored by WithTenant, or "" (the default
+// tenant). It is always "" when multi-tenancy is off.
+func FromContext(ctx context.Context) string {
+	if ctx == nil || !Enabled() {
+		return ""
+	}
+	id, _ := ctx.Value(tenantKey{}).(string)
+	return id
+}
+
+// Scope restricts a users query to the tenant of ctx. It is a no-op when
+// multi-tenancy is off.
+//
+//	db.Scopes(tenant.Scope(ctx)).Where("email = ?", email).First(&user)
+func Scope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !Enabled() {
+			return db
+		}
+		return db.Where("tenant_id = ?", FromContext(ctx))
+	}
+}
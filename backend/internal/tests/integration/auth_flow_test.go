@@ -9,44 +9,19 @@ import (
 	"testing"
 
 	"gosveltekit/internal/auth"
-	gormadapter "gosveltekit/internal/auth/adapter/gorm"
-	"gosveltekit/internal/email"
-	"gosveltekit/internal/handlers"
 	"gosveltekit/internal/models"
-	"gosveltekit/internal/router"
-	"gosveltekit/internal/service"
+	"gosveltekit/internal/testsupport"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 func setupIntegrationTest(t *testing.T) (*gin.Engine, *gorm.DB, *auth.AuthManager) {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	require.NoError(t, err)
-
-	err = db.AutoMigrate(&models.User{}, &models.Session{})
-	require.NoError(t, err)
-
-	// Setup adapters
-	userAdapter := gormadapter.NewUserAdapter(db)
-	sessionAdapter := gormadapter.NewSessionAdapter(db)
-
-	// Setup auth manager
-	authConfig := auth.DefaultAuthConfig()
-	authManager := auth.NewAuthManager(userAdapter, sessionAdapter, authConfig)
-
-	// Setup services
-	emailService := email.NewMockEmailService()
-	authService := service.NewAuthService(authManager, userAdapter, emailService)
-	authHandler := handlers.NewAuthHandler(authService)
-
-	// Setup router
-	r := router.SetupRouter(authHandler, authManager)
-	return r, db, authManager
+	s := testsupport.New(t)
+	return s.Router, s.DB, s.Auth
 }
 
 func TestCompleteAuthFlow(t *testing.T) {
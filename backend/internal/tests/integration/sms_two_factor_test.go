@@ -0,0 +1,81 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/service"
+	"gosveltekit/internal/testsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smsRecorder keeps the SMS messages sent
+type smsRecorder struct {
+	messages []string
+}
+
+func (r *smsRecorder) Send(ctx context.Context, to, message string) error {
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+var smsCode = regexp.MustCompile(`\b[0-9]{6}\b`)
+
+// lastCode returns the code of the last message sent
+func (r *smsRecorder) lastCode(t *testing.T) string {
+	t.Helper()
+	require.NotEmpty(t, r.messages)
+	return smsCode.FindString(r.messages[len(r.messages)-1])
+}
+
+func TestSMSTwoFactorFlow(t *testing.T) {
+	sender := &smsRecorder{}
+	s := testsupport.New(t,
+		testsupport.WithAuthConfig(func(c *auth.AuthConfig) { c.SMSCodeInterval = 0 }),
+		testsupport.WithServiceOptions(service.WithSMSSender(sender)),
+	)
+	user := s.CreateUser(testsupport.User{Username: "alice"})
+	client := s.Login(user)
+
+	// Verify the phone, then make it the second factor
+	assert.Equal(t, http.StatusBadRequest, client.Post("/api/me/2fa/sms", nil).Code, "the phone isn't verified yet")
+	res := client.Put("/api/me/phone", map[string]string{"phone_number": "+5511999999999"})
+	require.Equal(t, http.StatusAccepted, res.Code, res.Body.String())
+	assert.Equal(t, http.StatusBadRequest, client.Post("/api/me/phone/verify", map[string]string{"code": "00000a"}).Code)
+	res = client.Post("/api/me/phone/verify", map[string]string{"code": sender.lastCode(t)})
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	res = client.Post("/api/me/2fa/sms", nil)
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.Equal(t, http.StatusConflict, client.Put("/api/me/phone", map[string]string{"phone_number": "+5511888888888"}).Code)
+
+	// The password alone no longer gives a session
+	login := map[string]string{"username": "alice", "password": testsupport.DefaultPassword}
+	res = s.Client().Post("/auth/login", login)
+	require.Equal(t, http.StatusAccepted, res.Code, res.Body.String())
+	challenge := res.Map()
+	assert.Equal(t, "sms", challenge["method"])
+	assert.Nil(t, challenge["session_id"])
+	sent := len(sender.messages)
+
+	res = s.Client().Post("/auth/login/2fa/sms", map[string]any{"challenge_token": challenge["challenge_token"]})
+	require.Equal(t, http.StatusAccepted, res.Code, res.Body.String())
+	assert.Len(t, sender.messages, sent+1)
+	assert.Equal(t, http.StatusUnauthorized, s.Client().Post("/auth/login/2fa/sms", map[string]string{"challenge_token": "unknown"}).Code)
+
+	res = s.Client().Post("/auth/login/2fa", map[string]any{"challenge_token": challenge["challenge_token"], "code": sender.lastCode(t)})
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.NotEmpty(t, res.Map()["session_id"])
+
+	// Turning it off takes the password
+	assert.Equal(t, http.StatusBadRequest, client.Delete("/api/me/2fa/sms").Code)
+	assert.Equal(t, http.StatusBadRequest, client.Do(http.MethodDelete, "/api/me/2fa/sms", map[string]string{"password": "wrong"}).Code)
+	res = client.Do(http.MethodDelete, "/api/me/2fa/sms", map[string]string{"password": testsupport.DefaultPassword})
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	res = s.Client().Post("/auth/login", login)
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+}
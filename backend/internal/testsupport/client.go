@@ -0,0 +1,188 @@
+package testsupport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gosveltekit/internal/auth"
+	"gosveltekit/internal/models"
+)
+
+// DefaultPassword is the password of users created by CreateUser without one
+const DefaultPassword = "Test123!@#"
+
+// User is a user to seed with CreateUser. Empty fields get usable defaults:
+// the email is derived from the username, the role is "user" and the
+// password is DefaultPassword.
+type User struct {
+	Username    string
+	Email       string
+	DisplayName string
+	Password    string
+	Role        string
+	Inactive    bool
+	Unverified  bool // email not verified yet; seeded users are verified
+}
+
+// CreateUser seeds a user directly in the database, skipping registration
+func (s *Server) CreateUser(u User) *models.User {
+	s.t.Helper()
+	if u.Username == "" {
+		s.t.Fatalf("testsupport: CreateUser needs a username")
+	}
+	if u.Email == "" {
+		u.Email = u.Username + "@example.com"
+	}
+	if u.DisplayName == "" {
+		u.DisplayName = u.Username
+	}
+	if u.Password == "" {
+		u.Password = DefaultPassword
+	}
+	if u.Role == "" {
+		u.Role = "user"
+	}
+	hash, err := s.hasher.Hash(u.Password)
+	if err != nil {
+		s.t.Fatalf("testsupport: failed to hash password: %v", err)
+	}
+
+	user := &models.User{
+		Username:      u.Username,
+		Email:         u.Email,
+		DisplayName:   u.DisplayName,
+		PasswordHash:  hash,
+		Role:          u.Role,
+		Active:        true,
+		EmailVerified: !u.Unverified,
+	}
+	if err := s.DB.Create(user).Error; err != nil {
+		s.t.Fatalf("testsupport: failed to create user %q: %v", u.Username, err)
+	}
+	// Active defaults to true in the schema, so false must be updated
+	if u.Inactive {
+		user.Active = false
+		if err := s.DB.Model(user).Update("active", false).Error; err != nil {
+			s.t.Fatalf("testsupport: failed to disable user %q: %v", u.Username, err)
+		}
+	}
+	return user
+}
+
+// Login creates a session for user without checking the password, like a
+// login through an identity provider, and returns a client sending its
+// token. Users with two-factor authentication fail the test.
+func (s *Server) Login(user *models.User) *Client {
+	s.t.Helper()
+	session, _, err := s.Auth.LoginExternal(context.Background(), user.PublicID(), auth.SessionMetadata{
+		UserAgent: "testsupport",
+		IP:        "192.0.2.1",
+	})
+	if err != nil {
+		s.t.Fatalf("testsupport: failed to log in %q: %v", user.Username, err)
+	}
+	return s.ClientWithToken(session.ID)
+}
+
+// Client returns an unauthenticated client
+func (s *Server) Client() *Client {
+	return &Client{server: s, Header: http.Header{}}
+}
+
+// ClientWithToken returns a client sending token as a bearer token, e.g. a
+// session ID returned by /auth/login or an API key
+func (s *Server) ClientWithToken(token string) *Client {
+	c := s.Client()
+	c.Token = token
+	return c
+}
+
+// Client sends requests to the router of a Server
+type Client struct {
+	server *Server
+	// Token is sent as "Authorization: Bearer <token>" when set
+	Token string
+	// Header is sent with every request
+	Header http.Header
+}
+
+// Get sends a GET request
+func (c *Client) Get(path string) *Response {
+	return c.Do(http.MethodGet, path, nil)
+}
+
+// Post sends a POST request with body encoded as JSON
+func (c *Client) Post(path string, body any) *Response {
+	return c.Do(http.MethodPost, path, body)
+}
+
+// Put sends a PUT request with body encoded as JSON
+func (c *Client) Put(path string, body any) *Response {
+	return c.Do(http.MethodPut, path, body)
+}
+
+// Patch sends a PATCH request with body encoded as JSON
+func (c *Client) Patch(path string, body any) *Response {
+	return c.Do(http.MethodPatch, path, body)
+}
+
+// Delete sends a DELETE request
+func (c *Client) Delete(path string) *Response {
+	return c.Do(http.MethodDelete, path, nil)
+}
+
+// Do sends a request with body encoded as JSON, unless it is nil
+func (c *Client) Do(method, path string, body any) *Response {
+	t := c.server.t
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("testsupport: failed to encode request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	for key, values := range c.Header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	w := httptest.NewRecorder()
+	c.server.Router.ServeHTTP(w, req)
+	return &Response{ResponseRecorder: w, t: t}
+}
+
+// Response is the answer to a request of a Client
+type Response struct {
+	*httptest.ResponseRecorder
+	t testing.TB
+}
+
+// JSON decodes the body into v, failing the test when it isn't valid JSON
+func (r *Response) JSON(v any) {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		r.t.Fatalf("testsupport: invalid JSON response (status %d): %v\n%s", r.Code, err, r.Body.String())
+	}
+}
+
+// Map decodes the body as a JSON object
+func (r *Response) Map() map[string]any {
+	r.t.Helper()
+	var body map[string]any
+	r.JSON(&body)
+	return body
+}
@@ -0,0 +1,204 @@
+// Package testsupport runs the API in process for end-to-end tests: New
+// builds the router with the real handlers, services and adapters over an
+// in-memory SQLite database migrated like production, and captures the
+// emails it would send.
+//
+// Fixtures seed users (CreateUser) and sessions (Login), and a Client sends
+// requests to the router without opening a port:
+//
+//	s := testsupport.New(t)
+//	admin := s.CreateUser(testsupport.User{Username: "admin", Role: "admin"})
+//	res := s.Login(admin).Get("/api/admin/users")
+//	assert.Equal(t, http.StatusOK, res.Code)
+//
+// Every Server has its own database, so tests may run in parallel.
+package testsupport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosveltekit/internal/audit"
+	"gosveltekit/internal/auth"
+	gormadapter "gosveltekit/internal/auth/adapter/gorm"
+	"gosveltekit/internal/cleanup"
+	"gosveltekit/internal/email"
+	"gosveltekit/internal/handlers"
+	"gosveltekit/internal/middleware"
+	"gosveltekit/internal/migrations"
+	"gosveltekit/internal/repository"
+	"gosveltekit/internal/router"
+	"gosveltekit/internal/service"
+	"gosveltekit/internal/settings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Server is the API under test
+type Server struct {
+	Router   *gin.Engine
+	DB       *gorm.DB
+	Auth     *auth.AuthManager
+	Service  *service.AuthService
+	Settings *settings.Store
+	// Emails captures every email sent, nothing leaves the process
+	Emails *email.MockEmailService
+
+	t      testing.TB
+	hasher auth.PasswordHasher
+}
+
+type options struct {
+	authConfig     func(*auth.AuthConfig)
+	serviceOptions []service.Option
+	routerOptions  []router.Option
+	settings       map[string]bool
+}
+
+// Option configures a Server
+type Option func(*options)
+
+// WithAuthConfig adjusts the auth manager's config, which starts from
+// auth.DefaultAuthConfig
+func WithAuthConfig(fn func(*auth.AuthConfig)) Option {
+	return func(o *options) {
+		o.authConfig = fn
+	}
+}
+
+// WithServiceOptions enables features of the auth service, e.g.
+// service.WithMagicLinks
+func WithServiceOptions(opts ...service.Option) Option {
+	return func(o *options) {
+		o.serviceOptions = append(o.serviceOptions, opts...)
+	}
+}
+
+// WithRouterOptions adds router options, e.g. router.WithConfig or extra
+// handlers, after the ones New sets. Rate limits are off unless a store is
+// set with router.WithRateLimitStore.
+func WithRouterOptions(opts ...router.Option) Option {
+	return func(o *options) {
+		o.routerOptions = append(o.routerOptions, opts...)
+	}
+}
+
+// WithSetting sets the default of a runtime setting, see settings.Keys.
+// Registration is enabled and maintenance mode off unless changed here.
+func WithSetting(key string, value bool) Option {
+	return func(o *options) {
+		o.settings[key] = value
+	}
+}
+
+// New starts a Server for the test t. Its database is closed when t ends.
+func New(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	o := &options{settings: map[string]bool{settings.KeyRegistrationEnabled: true}}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	db := openDB(t)
+	// The cheapest cost keeps tests fast; hashes are still checked for real
+	hasher := auth.NewBcryptHasher(bcrypt.MinCost)
+	userAdapter := gormadapter.NewUserAdapter(db, gormadapter.WithPasswordHasher(hasher))
+	sessionAdapter := gormadapter.NewSessionAdapter(db)
+
+	authConfig := auth.DefaultAuthConfig()
+	if o.authConfig != nil {
+		o.authConfig(authConfig)
+	}
+	authManager := auth.NewAuthManager(userAdapter, sessionAdapter, authConfig)
+
+	settingsStore := settings.NewStore(db, settings.Config{Defaults: o.settings})
+	if err := settingsStore.Refresh(context.Background()); err != nil {
+		t.Fatalf("testsupport: failed to load settings: %v", err)
+	}
+
+	emails := email.NewMockEmailService()
+	auditLog := audit.NewStore(db)
+	serviceOpts := append([]service.Option{service.WithAudit(auditLog), service.WithSettings(settingsStore)}, o.serviceOptions...)
+	authService := service.NewAuthService(authManager, userAdapter, emails, serviceOpts...)
+
+	userHandler := handlers.NewUserHandler(service.NewUserService(repository.NewUserRepository(db),
+		service.WithUsernamePolicy(authManager.UsernamePolicy()),
+		service.WithSessionRevocation(authManager),
+		service.WithPasswordHasher(hasher),
+	), handlers.WithAuditRecorder(auditLog))
+
+	routerOpts := append([]router.Option{
+		router.WithRateLimitStore(unlimited{}),
+		router.WithUserHandler(userHandler),
+		router.WithMaintenanceHandler(handlers.NewMaintenanceHandler(cleanup.NewWorker(db, cleanup.WorkerConfig{Sessions: sessionAdapter}))),
+		router.WithSettingsHandler(handlers.NewSettingsHandler(settingsStore, handlers.WithAuditRecorder(auditLog))),
+		router.WithSessionHandler(handlers.NewSessionHandler(authManager)),
+		router.WithLockoutHandler(handlers.NewLockoutHandler(authManager, handlers.WithAuditRecorder(auditLog))),
+		router.WithAPIKeyHandler(handlers.NewAPIKeyHandler(authManager, handlers.WithAuditRecorder(auditLog))),
+		router.WithAuditHandler(handlers.NewAuditHandler(auditLog)),
+		router.WithMaintenanceMode(func() bool { return settingsStore.Bool(settings.KeyMaintenanceMode) }),
+	}, o.routerOptions...)
+
+	return &Server{
+		Router:   router.SetupRouter(handlers.NewAuthHandler(authService), authManager, routerOpts...),
+		DB:       db,
+		Auth:     authManager,
+		Service:  authService,
+		Settings: settingsStore,
+		Emails:   emails,
+		t:        t,
+		hasher:   hasher,
+	}
+}
+
+// unlimited is a rate limit store allowing every request, so tests aren't
+// throttled by the strict built-in limits of the auth routes
+type unlimited struct{}
+
+func (unlimited) Take(ctx context.Context, key string, requests int, window time.Duration) (bool, middleware.RateLimitStatus, error) {
+	return true, middleware.RateLimitStatus{Limit: requests, Remaining: requests}, nil
+}
+
+// openDB opens an in-memory database with the schema of the migrations
+func openDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("testsupport: failed to open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("testsupport: failed to open database: %v", err)
+	}
+	// Every connection of :memory: is a different database
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	migrator, err := migrations.New(db, "sqlite")
+	if err != nil {
+		t.Fatalf("testsupport: failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("testsupport: failed to migrate database: %v", err)
+	}
+	return db
+}
+
+// LastEmail returns the last email of kind sent to to, see email.MockEmail,
+// failing the test when there is none
+func (s *Server) LastEmail(kind, to string) email.MockEmail {
+	s.t.Helper()
+	sent := s.Emails.GetSentEmails()
+	for i := len(sent) - 1; i >= 0; i-- {
+		if sent[i].Kind == kind && sent[i].To == to {
+			return sent[i]
+		}
+	}
+	s.t.Fatalf("testsupport: no %s email sent to %s (sent: %d)", kind, to, len(sent))
+	return email.MockEmail{}
+}
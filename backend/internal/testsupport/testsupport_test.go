@@ -0,0 +1,77 @@
+package testsupport
+
+import (
+	"net/http"
+	"testing"
+
+	"gosveltekit/internal/settings"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_PasswordResetByEmail(t *testing.T) {
+	s := New(t)
+	user := s.CreateUser(User{Username: "alice"})
+
+	res := s.Client().Post("/auth/password-reset-request", map[string]string{"email": user.Email})
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	sent := s.LastEmail("password_reset", user.Email)
+	require.NotEmpty(t, sent.Token)
+
+	res = s.Client().Post("/auth/password-reset", map[string]string{
+		"token":            sent.Token,
+		"new_password":     "N3w-Passw0rd!",
+		"confirm_password": "N3w-Passw0rd!",
+	})
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	res = s.Client().Post("/auth/login", map[string]string{"username": "alice", "password": DefaultPassword})
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	res = s.Client().Post("/auth/login", map[string]string{"username": "alice", "password": "N3w-Passw0rd!"})
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	token, _ := res.Map()["session_id"].(string)
+	res = s.ClientWithToken(token).Get("/api/me")
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "alice", res.Map()["identifier"])
+}
+
+func TestServer_Login(t *testing.T) {
+	s := New(t)
+	admin := s.CreateUser(User{Username: "admin", Role: "admin"})
+	user := s.CreateUser(User{Username: "bob"})
+
+	assert.Equal(t, http.StatusUnauthorized, s.Client().Get("/api/admin/users").Code)
+	assert.Equal(t, http.StatusForbidden, s.Login(user).Get("/api/admin/users").Code)
+
+	res := s.Login(admin).Get("/api/admin/users")
+	require.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	var page struct {
+		Data []map[string]any `json:"data"`
+	}
+	res.JSON(&page)
+	assert.Len(t, page.Data, 2)
+}
+
+func TestServer_Fixtures(t *testing.T) {
+	s := New(t, WithSetting(settings.KeyRegistrationEnabled, false))
+
+	inactive := s.CreateUser(User{Username: "carol", Inactive: true, Unverified: true})
+	var stored struct {
+		Active        bool
+		EmailVerified bool
+	}
+	require.NoError(t, s.DB.Table("users").Select("active, email_verified").Where("id = ?", inactive.ID).Scan(&stored).Error)
+	assert.False(t, stored.Active)
+	assert.False(t, stored.EmailVerified)
+
+	res := s.Client().Post("/auth/register", map[string]string{
+		"username":     "dave",
+		"email":        "dave@example.com",
+		"password":     DefaultPassword,
+		"display_name": "Dave",
+	})
+	assert.Equal(t, http.StatusForbidden, res.Code, "registration is off")
+	assert.Empty(t, s.Emails.GetSentEmails())
+}
@@ -0,0 +1,65 @@
+// Package tokens is the single source of randomness for secret tokens
+// (session IDs, reset and deletion links, recovery and SMS codes), so they all
+// come from crypto/rand in production and can be made reproducible in tests.
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	mrand "math/rand/v2"
+	"sync"
+)
+
+// Generator fills b with random bytes, like io.Reader. Implementations always
+// fill b completely or return an error.
+type Generator interface {
+	Read(b []byte) (int, error)
+}
+
+// Secure reads from crypto/rand. It is the default of every component that
+// takes a Generator.
+type Secure struct{}
+
+// Read implements Generator
+func (Secure) Read(b []byte) (int, error) {
+	return rand.Read(b)
+}
+
+// Deterministic yields the same byte sequence for the same seed. For tests
+// only: its output is predictable by design.
+type Deterministic struct {
+	mu  sync.Mutex
+	rng *mrand.ChaCha8
+}
+
+// NewDeterministic returns a Deterministic generator seeded with seed
+func NewDeterministic(seed string) *Deterministic {
+	return &Deterministic{rng: mrand.NewChaCha8(sha256.Sum256([]byte(seed)))}
+}
+
+// Read implements Generator
+func (d *Deterministic) Read(b []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rng.Read(b)
+}
+
+// Text returns n random bytes from g, base64url-encoded without padding
+func Text(g Generator, n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := g.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hex returns n random bytes from g, hex-encoded
+func Hex(g Generator, n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := g.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,52 @@
+package tokens
+
+import (
+	"bytes"
+	"crypto/rand"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var urlSafe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func TestSecure(t *testing.T) {
+	a, err := Text(Secure{}, 32)
+	require.NoError(t, err)
+	b, err := Text(Secure{}, 32)
+	require.NoError(t, err)
+	assert.NotEqual(t, a, b)
+	assert.Len(t, a, 43, "32 bytes, base64url without padding")
+	assert.Regexp(t, urlSafe, a)
+
+	// The bytes come from crypto/rand
+	original := rand.Reader
+	rand.Reader = bytes.NewReader(bytes.Repeat([]byte{0xfb}, 3))
+	defer func() { rand.Reader = original }()
+	marked, err := Text(Secure{}, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "-_v7", marked)
+}
+
+func TestDeterministic(t *testing.T) {
+	sequence := func(seed string) []string {
+		g := NewDeterministic(seed)
+		var out []string
+		for range 3 {
+			token, err := Text(g, 32)
+			require.NoError(t, err)
+			assert.Regexp(t, urlSafe, token)
+			out = append(out, token)
+		}
+		code, err := Hex(g, 6)
+		require.NoError(t, err)
+		return append(out, code)
+	}
+
+	first := sequence("seed")
+	assert.Equal(t, first, sequence("seed"), "same seed, same tokens")
+	assert.NotEqual(t, first, sequence("other seed"))
+	assert.NotEqual(t, first[0], first[1], "tokens of one sequence differ")
+}
@@ -17,7 +17,7 @@ var (
 	ErrUsernameTooLong      = errors.New("nome de usuário não pode ter mais de 50 caracteres")
 	ErrUsernameFormat       = errors.New("nome de usuário pode conter apenas letras, números, pontos, hífens e underscores")
 	ErrEmailInvalid         = errors.New("endereço de email inválido")
-	ErrPasswordTooShort     = errors.New("senha deve ter pelo menos 8 caracteres")
+	ErrPasswordTooShort     = fmt.Errorf("senha deve ter pelo menos %d caracteres", PasswordMinLength)
 	ErrPasswordNoUppercase  = errors.New("senha deve conter pelo menos uma letra maiúscula")
 	ErrPasswordNoLowercase  = errors.New("senha deve conter pelo menos uma letra minúscula")
 	ErrPasswordNoNumber     = errors.New("senha deve conter pelo menos um número")
@@ -28,8 +28,46 @@ var (
 	ErrResetTokenInvalid    = errors.New("token de redefinição de senha inválido")
 	ErrDisplayNameInvalid   = errors.New("nome de exibição inválido")
 	ErrDisplayNameTooLong   = errors.New("nome de exibição não pode ter mais de 100 caracteres")
+	ErrLocaleInvalid        = errors.New("idioma inválido, use uma tag como pt-BR ou en")
 )
 
+// localeRegex matches language tags such as "pt", "pt-BR" or "zh-Hant-TW"
+var localeRegex = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// PasswordMinLength is the minimum password length enforced by ValidatePassword
+const PasswordMinLength = 8
+
+// Character classes every password must contain
+const (
+	ClassUppercase = "uppercase"
+	ClassLowercase = "lowercase"
+	ClassNumber    = "number"
+	ClassSpecial   = "special"
+)
+
+// PasswordPolicy describes the password rules, so clients can show them
+// before submission instead of hardcoding them
+type PasswordPolicy struct {
+	MinLength       int      `json:"min_length"`
+	MaxLength       int      `json:"max_length"` // in bytes, 0 means no limit
+	RequiredClasses []string `json:"required_classes"`
+	HistoryDepth    int      `json:"history_depth"` // recent passwords that can't be reused, 0 disables the check
+	RejectCommon    bool     `json:"reject_common"`
+	RejectUsername  bool     `json:"reject_username"`
+}
+
+// DefaultPasswordPolicy returns the rules checked by ValidatePassword.
+// MaxLength and HistoryDepth depend on the password hasher and the auth
+// config, and are left zero.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:       PasswordMinLength,
+		RequiredClasses: []string{ClassUppercase, ClassLowercase, ClassNumber, ClassSpecial},
+		RejectCommon:    true,
+		RejectUsername:  true,
+	}
+}
+
 // List of common passwords to deny
 var commonPasswords = map[string]bool{
 	"password":    true,
@@ -85,7 +123,7 @@ func ValidateEmail(email string) error {
 
 // ValidatePassword ensures the password meets complexity requirements
 func ValidatePassword(password string, username string) error {
-	if len(password) < 8 {
+	if len(password) < PasswordMinLength {
 		return ErrPasswordTooShort
 	}
 
@@ -146,6 +184,19 @@ func ValidateDisplayName(name string) error {
 	return nil
 }
 
+// ValidateLocale validates a preferred language tag; empty means the default
+func ValidateLocale(locale string) error {
+	if locale == "" {
+		return nil
+	}
+
+	if len(locale) > 35 || !localeRegex.MatchString(locale) {
+		return ErrLocaleInvalid
+	}
+
+	return nil
+}
+
 // ValidateRefreshToken performs basic validation on refresh tokens
 func ValidateRefreshToken(token string) error {
 	if token == "" || len(token) < 10 {
@@ -3,6 +3,7 @@
 package validation
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -58,6 +59,32 @@ func TestValidateEmail(t *testing.T) {
 	}
 }
 
+func TestValidateLocale(t *testing.T) {
+	tests := []struct {
+		name    string
+		locale  string
+		wantErr error
+	}{
+		{"Empty uses the default", "", nil},
+		{"Language", "en", nil},
+		{"Language and region", "pt-BR", nil},
+		{"Script and region", "zh-Hant-TW", nil},
+		{"Underscore", "pt_BR", ErrLocaleInvalid},
+		{"Too short", "p", ErrLocaleInvalid},
+		{"Markup", "<en>", ErrLocaleInvalid},
+		{"Too long", "en-" + strings.Repeat("abcdefgh-", 4), ErrLocaleInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLocale(tt.locale)
+			if err != tt.wantErr {
+				t.Errorf("ValidateLocale() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidatePassword(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,63 @@
+// Package version describes the running build.
+//
+// Version, Commit and BuildTime are set at build time, e.g.
+//
+//	go build -ldflags "-X gosveltekit/internal/version.Version=v1.2.0 \
+//	  -X gosveltekit/internal/version.Commit=$(git rev-parse HEAD)" ./cmd/server
+//
+// (see the build target of the Makefile). Unset, they fall back to what the
+// Go toolchain recorded in the binary.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Set with -ldflags -X
+var (
+	Version   = ""
+	Commit    = ""
+	BuildTime = "" // RFC 3339
+)
+
+// Info describes the build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"` // built from a tree with uncommitted changes
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build information, read once
+func Get() Info {
+	once.Do(func() {
+		info = Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+		if build, ok := debug.ReadBuildInfo(); ok {
+			if info.Version == "" && build.Main.Version != "(devel)" {
+				info.Version = build.Main.Version
+			}
+			for _, setting := range build.Settings {
+				switch setting.Key {
+				case "vcs.revision":
+					if info.Commit == "" {
+						info.Commit = setting.Value
+					}
+				case "vcs.modified":
+					info.Modified = setting.Value == "true"
+				}
+			}
+		}
+		if info.Version == "" {
+			info.Version = "dev"
+		}
+	})
+	return info
+}
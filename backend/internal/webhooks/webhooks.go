@@ -0,0 +1,249 @@
+// Package webhooks notifies external systems of auth events (registrations,
+// deletions, failed logins, revoked sessions).
+//
+// Each configured endpoint subscribes to a list of events. Events are
+// published without blocking the request that triggered them: the Dispatcher
+// queues them and POSTs a JSON payload to every subscribed endpoint,
+// retrying failures with exponential backoff. Payloads are signed with the
+// endpoint's secret in the X-Webhook-Signature header ("sha256=" followed by
+// the hex HMAC-SHA256 of the body), so receivers can check where they came
+// from.
+//
+// Deliveries still queued or retrying are lost on shutdown; receivers should
+// not rely on getting every event.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"gosveltekit/internal/logger"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Events that can be subscribed to
+const (
+	EventUserRegistered = "user.registered"
+	EventUserDeleted    = "user.deleted"
+	EventLoginFailed    = "login.failed"
+	EventSessionRevoked = "session.revoked"
+)
+
+// Events lists every event that can be subscribed to
+var Events = []string{EventUserRegistered, EventUserDeleted, EventLoginFailed, EventSessionRevoked}
+
+// Headers set on every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-ID"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+var (
+	// ErrUnknownEvent is returned by New for subscriptions to unknown events
+	ErrUnknownEvent = errors.New("evento de webhook desconhecido")
+	// ErrInvalidEndpoint is returned by New for endpoints without URL or events
+	ErrInvalidEndpoint = errors.New("endpoint de webhook inválido")
+)
+
+// Publisher publishes auth events. Publish must not block.
+type Publisher interface {
+	Publish(ctx context.Context, event string, data map[string]any)
+}
+
+// Endpoint is a URL receiving the events it subscribes to
+type Endpoint struct {
+	URL    string
+	Secret string // signs the payloads; empty sends them unsigned
+	Events []string
+}
+
+// Config configures a Dispatcher
+type Config struct {
+	Endpoints    []Endpoint
+	MaxAttempts  int           // Default: 5
+	RetryBackoff time.Duration // wait before the first retry, doubled after each one. Default: 1 second
+	Timeout      time.Duration // per request. Default: 10 seconds
+	QueueSize    int           // events waiting for delivery, newer ones are dropped when full. Default: 100
+}
+
+// Payload is the JSON body POSTed to endpoints
+type Payload struct {
+	ID        string         `json:"id"`
+	Event     string         `json:"event"`
+	CreatedAt time.Time      `json:"created_at"`
+	Data      map[string]any `json:"data"`
+}
+
+// Dispatcher delivers published events to the subscribed endpoints. Run must
+// be running for queued events to be delivered.
+type Dispatcher struct {
+	config Config
+	client *http.Client
+	queue  chan Payload
+}
+
+// New validates the endpoints and creates a Dispatcher
+func New(config Config) (*Dispatcher, error) {
+	for _, endpoint := range config.Endpoints {
+		if endpoint.URL == "" || len(endpoint.Events) == 0 {
+			return nil, fmt.Errorf("%w: informe url e eventos (%q)", ErrInvalidEndpoint, endpoint.URL)
+		}
+		for _, event := range endpoint.Events {
+			if !slices.Contains(Events, event) {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownEvent, event)
+			}
+		}
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 100
+	}
+	return &Dispatcher{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout, Transport: otelhttp.NewTransport(nil)},
+		queue:  make(chan Payload, config.QueueSize),
+	}, nil
+}
+
+// Publish queues event for the endpoints subscribed to it. It never blocks:
+// when the queue is full the event is dropped and logged.
+func (d *Dispatcher) Publish(ctx context.Context, event string, data map[string]any) {
+	if !d.subscribed(event) {
+		return
+	}
+	id, err := newID()
+	if err != nil {
+		logger.FromContext(ctx).Error("Erro ao gerar ID do webhook", "error", err, "event", event)
+		return
+	}
+
+	select {
+	case d.queue <- Payload{ID: id, Event: event, CreatedAt: time.Now().UTC(), Data: data}:
+	default:
+		logger.FromContext(ctx).Warn("Fila de webhooks cheia, evento descartado", "event", event, "webhook_id", id)
+	}
+}
+
+// Run delivers queued events until ctx is cancelled, then waits for the
+// deliveries in progress to give up
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case payload := <-d.queue:
+			for _, endpoint := range d.config.Endpoints {
+				if !slices.Contains(endpoint.Events, payload.Event) {
+					continue
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					d.deliver(ctx, endpoint, payload)
+				}()
+			}
+		}
+	}
+}
+
+// deliver sends payload to endpoint, retrying up to MaxAttempts times
+func (d *Dispatcher) deliver(ctx context.Context, endpoint Endpoint, payload Payload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("Erro ao serializar webhook", "error", err, "event", payload.Event, "webhook_id", payload.ID)
+		return
+	}
+
+	backoff := d.config.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := d.send(ctx, endpoint, payload, body)
+		if err == nil {
+			logger.Debug("Webhook entregue", "url", endpoint.URL, "event", payload.Event, "webhook_id", payload.ID, "attempt", attempt)
+			return
+		}
+		if attempt >= d.config.MaxAttempts {
+			logger.Error("Webhook descartado após esgotar as tentativas", "error", err, "url", endpoint.URL, "event", payload.Event, "webhook_id", payload.ID, "attempts", attempt)
+			return
+		}
+		logger.Warn("Falha ao entregar webhook, tentando novamente", "error", err, "url", endpoint.URL, "event", payload.Event, "webhook_id", payload.ID, "attempt", attempt, "retry_in", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, endpoint Endpoint, payload Payload, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, payload.Event)
+	req.Header.Set(HeaderID, payload.ID)
+	if endpoint.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(endpoint.Secret, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint respondeu com status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (d *Dispatcher) subscribed(event string) bool {
+	for _, endpoint := range d.config.Endpoints {
+		if slices.Contains(endpoint.Events, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the X-Webhook-Signature value of body for secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,102 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatcher_Deliver(t *testing.T) {
+	received := make(chan Payload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, EventUserRegistered, r.Header.Get(HeaderEvent))
+		assert.Equal(t, Sign("secret", body), r.Header.Get(HeaderSignature))
+
+		var payload Payload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, payload.ID, r.Header.Get(HeaderID))
+		received <- payload
+	}))
+	defer server.Close()
+
+	dispatcher, err := New(Config{Endpoints: []Endpoint{
+		{URL: server.URL, Secret: "secret", Events: []string{EventUserRegistered}},
+	}})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+
+	// Events nobody subscribed to are not delivered
+	dispatcher.Publish(ctx, EventLoginFailed, map[string]any{"identifier": "testuser"})
+	dispatcher.Publish(ctx, EventUserRegistered, map[string]any{"user_id": "1", "username": "testuser"})
+
+	select {
+	case payload := <-received:
+		assert.Equal(t, EventUserRegistered, payload.Event)
+		assert.NotEmpty(t, payload.ID)
+		assert.Equal(t, map[string]any{"user_id": "1", "username": "testuser"}, payload.Data)
+		assert.WithinDuration(t, time.Now(), payload.CreatedAt, time.Minute)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+	select {
+	case payload := <-received:
+		t.Fatalf("unexpected delivery of %s", payload.Event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDispatcher_Retry(t *testing.T) {
+	var calls atomic.Int32
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		close(done)
+	}))
+	defer server.Close()
+
+	dispatcher, err := New(Config{
+		Endpoints:    []Endpoint{{URL: server.URL, Events: []string{EventSessionRevoked}}},
+		RetryBackoff: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+
+	dispatcher.Publish(ctx, EventSessionRevoked, map[string]any{"user_id": "1"})
+	select {
+	case <-done:
+		assert.Equal(t, int32(3), calls.Load())
+	case <-time.After(5 * time.Second):
+		t.Fatalf("webhook not delivered after retries, %d calls", calls.Load())
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Endpoints: []Endpoint{{URL: "http://example.com", Events: []string{"user.renamed"}}}})
+	assert.ErrorIs(t, err, ErrUnknownEvent)
+
+	_, err = New(Config{Endpoints: []Endpoint{{URL: "http://example.com"}}})
+	assert.ErrorIs(t, err, ErrInvalidEndpoint)
+
+	_, err = New(Config{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,116 @@
+// Package worker coordinates the lifecycle of background workers (the job
+// queue, webhook delivery, account purges, ...).
+//
+// Workers are registered with a Manager, started together with a shared
+// context, and stopped together on shutdown: Stop cancels the context and
+// waits for every worker to return, so no goroutine outlives the process's
+// graceful shutdown and in-flight work gets a chance to finish.
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"gosveltekit/internal/logger"
+)
+
+// ErrStopTimeout is returned by Stop when workers don't return before the
+// stop deadline
+var ErrStopTimeout = errors.New("workers não encerraram dentro do prazo")
+
+// Worker is a long-running background task. Run must return once ctx is
+// cancelled.
+type Worker interface {
+	Run(ctx context.Context)
+}
+
+// Func adapts a function to the Worker interface
+type Func func(ctx context.Context)
+
+// Run implements Worker
+func (f Func) Run(ctx context.Context) {
+	f(ctx)
+}
+
+type namedWorker struct {
+	name   string
+	worker Worker
+}
+
+// Manager starts and stops a set of workers
+type Manager struct {
+	mu      sync.Mutex
+	workers []namedWorker
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewManager creates an empty Manager
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// Register adds a worker. Workers registered after Start are started
+// immediately.
+func (m *Manager) Register(name string, w Worker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nw := namedWorker{name: name, worker: w}
+	m.workers = append(m.workers, nw)
+	if m.ctx != nil {
+		m.run(nw)
+	}
+}
+
+// Start runs every registered worker in its own goroutine, with a context
+// derived from ctx that is cancelled by Stop. Calling Start twice is a no-op.
+func (m *Manager) Start(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ctx != nil {
+		return
+	}
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	for _, nw := range m.workers {
+		m.run(nw)
+	}
+}
+
+// run starts a worker; m.mu must be held
+func (m *Manager) run(nw namedWorker) {
+	m.wg.Add(1)
+	logger.Info("Worker iniciado", "worker", nw.name)
+	go func(ctx context.Context) {
+		defer m.wg.Done()
+		nw.worker.Run(ctx)
+		logger.Info("Worker encerrado", "worker", nw.name)
+	}(m.ctx)
+}
+
+// Stop cancels the workers and waits until all of them return or ctx is done,
+// in which case it returns ErrStopTimeout.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		logger.Error("Workers não encerraram dentro do prazo", "error", ctx.Err())
+		return ErrStopTimeout
+	}
+}
@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_StopCancelsAndWaits(t *testing.T) {
+	m := NewManager()
+
+	var finished atomic.Int32
+	started := make(chan struct{}, 2)
+	slowWorker := Func(func(ctx context.Context) {
+		started <- struct{}{}
+		<-ctx.Done()
+		// Simulate flushing in-flight work after cancellation
+		time.Sleep(50 * time.Millisecond)
+		finished.Add(1)
+	})
+	m.Register("first", slowWorker)
+	m.Register("second", slowWorker)
+
+	m.Start(context.Background())
+	<-started
+	<-started
+
+	require.NoError(t, m.Stop(context.Background()))
+	assert.Equal(t, int32(2), finished.Load(), "Stop returns only after every worker returned")
+}
+
+func TestManager_RegisterAfterStart(t *testing.T) {
+	m := NewManager()
+	m.Start(context.Background())
+
+	cancelled := make(chan struct{})
+	m.Register("late", Func(func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	}))
+
+	require.NoError(t, m.Stop(context.Background()))
+	select {
+	case <-cancelled:
+	default:
+		t.Fatal("late worker was not cancelled")
+	}
+}
+
+func TestManager_ParentContextCancels(t *testing.T) {
+	m := NewManager()
+	done := make(chan struct{})
+	m.Register("worker", Func(func(ctx context.Context) {
+		<-ctx.Done()
+		close(done)
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.Start(ctx)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not receive the parent cancellation")
+	}
+	require.NoError(t, m.Stop(context.Background()))
+}
+
+func TestManager_StopTimeout(t *testing.T) {
+	m := NewManager()
+	release := make(chan struct{})
+	m.Register("stuck", Func(func(ctx context.Context) {
+		<-release
+	}))
+	m.Start(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, m.Stop(ctx), ErrStopTimeout)
+
+	close(release)
+	require.NoError(t, m.Stop(context.Background()))
+}